@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,7 +12,6 @@ import (
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/exporter/otlpexporter"
 	"go.opentelemetry.io/collector/exporter/otlphttpexporter"
-	"go.opentelemetry.io/collector/exporter/prometheusexporter"
 	"go.opentelemetry.io/collector/extension"
 	"go.opentelemetry.io/collector/otelcol"
 	"go.opentelemetry.io/collector/processor"
@@ -24,11 +22,14 @@ import (
 	"go.opentelemetry.io/collector/service"
 	"go.uber.org/zap"
 
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusexporter"
+
 	// Import custom components
+	"github.com/yourusername/nrdot-mvp/src/plugins/adaptive_degradation_manager"
 	"github.com/yourusername/nrdot-mvp/src/plugins/adaptive_priority_queue"
 	"github.com/yourusername/nrdot-mvp/src/plugins/cardinality_limiter"
 	"github.com/yourusername/nrdot-mvp/src/plugins/enhanced_dlq"
-	"github.com/yourusername/nrdot-mvp/src/plugins/adaptive_degradation_manager"
+	"github.com/yourusername/nrdot-mvp/src/plugins/readiness"
 )
 
 func main() {
@@ -51,6 +52,14 @@ func main() {
 		configPath = "/etc/otel/config.yaml"
 	}
 
+	// "validate-config [path]" checks the configuration without running it
+	if len(os.Args) > 1 && os.Args[1] == "validate-config" {
+		if len(os.Args) > 2 {
+			configPath = os.Args[2]
+		}
+		os.Exit(validateConfig(ctx, factories, configPath))
+	}
+
 	info := component.BuildInfo{
 		Command:     "nrdot-collector",
 		Description: "NRDOT+ MVP OpenTelemetry Collector",
@@ -65,7 +74,7 @@ func main() {
 		},
 		ConfigProviderSettings: service.ConfigProviderSettings{
 			ConfigMapProvider: confmap.ProviderSettings{
-				URIs:      []string{fmt.Sprintf("file:%s", configPath)},
+				URIs: []string{fmt.Sprintf("file:%s", configPath)},
 			},
 		},
 	}
@@ -78,15 +87,17 @@ func main() {
 
 func components() (otelcol.Factories, error) {
 	factories := otelcol.Factories{
-		Extensions: map[component.Type]extension.Factory{},
+		Extensions: map[component.Type]extension.Factory{
+			"readiness": readiness.NewFactory(),
+		},
 		Receivers: map[component.Type]receiver.Factory{
 			"otlp": otlpreceiver.NewFactory(),
 		},
 		Processors: map[component.Type]processor.Factory{
-			"batch":                    batchprocessor.NewFactory(),
-			"memory_limiter":           memorylimiterprocessor.NewFactory(),
-			"cardinality_limiter":      cardinalitylimiter.NewFactory(),
-			"adaptive_priority_queue":  adaptivepriorityqueue.NewFactory(),
+			"batch":                      batchprocessor.NewFactory(),
+			"memory_limiter":             memorylimiterprocessor.NewFactory(),
+			"cardinality_limiter":        cardinalitylimiter.NewFactory(),
+			"adaptive_priority_queue":    adaptivepriorityqueue.NewFactory(),
 			"adaptiveDegradationManager": adaptivedegradationmanager.NewFactory(),
 		},
 		Exporters: map[component.Type]exporter.Factory{
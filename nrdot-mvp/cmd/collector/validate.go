@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/confmap/converter/expandconverter"
+	"go.opentelemetry.io/collector/confmap/provider/fileprovider"
+	"go.opentelemetry.io/collector/otelcol"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/adaptive_degradation_manager"
+)
+
+// validateConfig loads the configuration at configPath, runs each
+// component's Validate and prints warnings about degradation manager
+// settings that are valid but likely mistaken. It returns the exit code:
+// 0 if the configuration is valid, even with warnings, and 1 otherwise.
+func validateConfig(ctx context.Context, factories otelcol.Factories, configPath string) int {
+	provider, err := otelcol.NewConfigProvider(otelcol.ConfigProviderSettings{
+		ResolverSettings: confmap.ResolverSettings{
+			URIs:       []string{fmt.Sprintf("file:%s", configPath)},
+			Providers:  map[string]confmap.Provider{"file": fileprovider.New()},
+			Converters: []confmap.Converter{expandconverter.New()},
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create config provider: %v\n", err)
+		return 1
+	}
+
+	cfg, err := provider.Get(ctx, factories)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load %s: %v\n", configPath, err)
+		return 1
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration %s: %v\n", configPath, err)
+		return 1
+	}
+
+	warnings := 0
+	for id, processorCfg := range cfg.Processors {
+		admCfg, ok := processorCfg.(*adaptivedegradationmanager.Config)
+		if !ok {
+			continue
+		}
+		for _, warning := range admCfg.Lint() {
+			fmt.Printf("warning: %s: %s\n", id, warning)
+			warnings++
+		}
+	}
+
+	fmt.Printf("Configuration %s is valid (%d warnings)\n", configPath, warnings)
+	return 0
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what run prints to standard output.
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	run()
+	writer.Close()
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	return string(output)
+}
+
+func TestValidateConfigWarnsAboutNonMonotonicLevels(t *testing.T) {
+	factories, err := components()
+	if err != nil {
+		t.Fatalf("failed to build components: %v", err)
+	}
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	config := `
+receivers:
+  otlp:
+    protocols:
+      http:
+processors:
+  adaptiveDegradationManager:
+    levels:
+      - id: 1
+        actions: [inc_batch]
+      - id: 2
+        actions: [drop_debug]
+      - id: 3
+        actions: [enable_sampling]
+exporters:
+  otlp:
+    endpoint: localhost:4317
+service:
+  pipelines:
+    metrics:
+      receivers: [otlp]
+      processors: [adaptiveDegradationManager]
+      exporters: [otlp]
+`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	var code int
+	output := captureStdout(t, func() {
+		code = validateConfig(context.Background(), factories, configPath)
+	})
+
+	// Warnings don't make the configuration invalid
+	if code != 0 {
+		t.Fatalf("expected a valid configuration, got exit code %d:\n%s", code, output)
+	}
+	if !strings.Contains(output, "warning: adaptiveDegradationManager: level 3 is less aggressive than level 2") {
+		t.Fatalf("expected a warning about level 3, got:\n%s", output)
+	}
+	if !strings.Contains(output, "(1 warnings)") {
+		t.Fatalf("expected the warning to be counted, got:\n%s", output)
+	}
+}
+
+func TestValidateConfigRejectsMissingFile(t *testing.T) {
+	factories, err := components()
+	if err != nil {
+		t.Fatalf("failed to build components: %v", err)
+	}
+	if code := validateConfig(context.Background(), factories, filepath.Join(t.TempDir(), "missing.yaml")); code != 1 {
+		t.Fatalf("expected exit code 1 for a missing file, got %d", code)
+	}
+}
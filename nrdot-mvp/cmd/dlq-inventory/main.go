@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	enhanceddlq "github.com/yourusername/nrdot-mvp/src/plugins/enhanced_dlq"
+)
+
+// dlq-inventory lists what is in a DLQ directory: each file's signal, size,
+// record count and the timestamps of its earliest and latest records.
+// Partition directories below it are included.
+func main() {
+	directory := flag.String("dir", "/var/lib/otel/dlq", "DLQ directory")
+	filePrefix := flag.String("prefix", "otel-dlq", "DLQ file prefix")
+	asJSON := flag.Bool("json", false, "Print the inventory as JSON")
+	flag.Parse()
+
+	var inventory []enhanceddlq.DLQFileInfo
+	err := filepath.WalkDir(*directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+
+		files, err := enhanceddlq.ListInventory(path, *filePrefix)
+		if err != nil {
+			return err
+		}
+		inventory = append(inventory, files...)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to list DLQ inventory: %v", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(inventory); err != nil {
+			log.Fatalf("Failed to write DLQ inventory: %v", err)
+		}
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "PATH\tSIGNAL\tSIZE\tRECORDS\tEARLIEST\tLATEST")
+
+	var totalSize int64
+	var totalRecords int
+	for _, file := range inventory {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%s\t%s\n",
+			file.Path, file.Signal, file.SizeBytes, file.Records,
+			formatTime(file.Earliest), formatTime(file.Latest))
+		totalSize += file.SizeBytes
+		totalRecords += file.Records
+	}
+	fmt.Fprintf(writer, "TOTAL (%d files)\t\t%d\t%d\t\t\n", len(inventory), totalSize, totalRecords)
+	writer.Flush()
+}
+
+// formatTime formats a record timestamp, or "-" if the file has no records.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// otherLabel is the path or method label of requests outside the known set,
+// so clients hitting arbitrary paths can't grow the request metrics without
+// bound.
+const otherLabel = "other"
+
+// defaultKnownPaths are the paths the mock serves.
+var defaultKnownPaths = []string{
+	"/", "/metrics", "/traces", "/logs", "/profiles",
+	"/v1/metrics", "/v1/traces", "/v1/logs", "/v1/profiles",
+}
+
+// Paths and methods counted by name in the request metrics.
+var (
+	knownPaths   map[string]bool
+	knownMethods = map[string]bool{
+		http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+		http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+	}
+)
+
+// setKnownPaths sets the paths counted by name in the request metrics.
+func setKnownPaths(paths []string) {
+	knownPaths = make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if path = strings.TrimSpace(path); path != "" {
+			knownPaths[path] = true
+		}
+	}
+}
+
+// pathLabel returns the path label of a request.
+func pathLabel(r *http.Request) string {
+	if knownPaths[r.URL.Path] {
+		return r.URL.Path
+	}
+	return otherLabel
+}
+
+// methodLabel returns the method label of a request.
+func methodLabel(r *http.Request) string {
+	if knownMethods[r.Method] {
+		return r.Method
+	}
+	return otherLabel
+}
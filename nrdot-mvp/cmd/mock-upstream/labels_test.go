@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRandomPathsKeepLabelsBounded(t *testing.T) {
+	registerer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	t.Cleanup(func() { prometheus.DefaultRegisterer = registerer })
+	initPrometheusMetrics()
+	logger = log.New(io.Discard, "", 0)
+	config = Config{}
+
+	// Only the configured paths are counted by name
+	setKnownPaths([]string{"/v1/metrics", " /v1/logs ", ""})
+	t.Cleanup(func() { setKnownPaths(defaultKnownPaths) })
+
+	for i := 0; i < 200; i++ {
+		path := fmt.Sprintf("/%x", rand.Int63())
+		switch i % 10 {
+		case 0:
+			path = "/v1/metrics"
+		case 1:
+			path = "/v1/logs"
+		}
+		handleRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, strings.NewReader("payload")))
+	}
+
+	paths := make(map[string]bool)
+	for _, label := range []string{"/v1/metrics", "/v1/logs", otherLabel} {
+		if testutil.ToFloat64(promRequestsTotal.WithLabelValues(label, http.MethodPost, "none")) > 0 {
+			paths[label] = true
+		}
+	}
+	if got := testutil.CollectAndCount(promRequestsTotal); got != 3 || len(paths) != 3 {
+		t.Fatalf("expected the known paths and other alone to be labeled, got %d series for %v", got, paths)
+	}
+	if got := testutil.ToFloat64(promRequestsTotal.WithLabelValues(otherLabel, http.MethodPost, "none")); got != 160 {
+		t.Fatalf("expected 160 requests under other, got %v", got)
+	}
+}
@@ -9,27 +9,48 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/yourusername/nrdot-mvp/internal/mockutil"
 )
 
 // Configuration for the mock-upstream service
 type Config struct {
-	HTTPPort               int    `json:"http_port"`
-	MetricsPort            int    `json:"metrics_port"`
-	LatencyMin             int    `json:"latency_min"`
-	LatencyMax             int    `json:"latency_max"`
-	ErrorRate              int    `json:"error_rate"`
-	RateLimitErrorRate     int    `json:"rate_limit_error_rate"`
+	HTTPPort                int    `json:"http_port"`
+	MetricsPort             int    `json:"metrics_port"`
+	LatencyMin              int    `json:"latency_min"`
+	LatencyMax              int    `json:"latency_max"`
+	ErrorRate               int    `json:"error_rate"`
+	RateLimitErrorRate      int    `json:"rate_limit_error_rate"`
 	SupportOutageSimulation bool   `json:"support_outage_simulation"`
-	LogFile                string `json:"log_file"`
-	LogLevel               string `json:"log_level"`
-	VerboseLogging         bool   `json:"verbose_logging"`
+	LogFile                 string `json:"log_file"`
+	LogLevel                string `json:"log_level"`
+	VerboseLogging          bool   `json:"verbose_logging"`
+	StatsIntervalSec        int    `json:"stats_interval_sec"`
+
+	// Log only 1 in this many requests when verbose, so high request rates
+	// don't flood the output
+	VerboseLogSampleRate int `json:"verbose_log_sample_rate"`
+
+	// Error rates (0-100) by X-Priority value, overriding ErrorRate, to
+	// simulate a backend protecting its critical path
+	PriorityErrorRates map[string]int `json:"priority_error_rates"`
+
+	// Paths counted by name in the request metrics. Requests to other paths
+	// are counted under "other".
+	KnownPaths []string `json:"known_paths"`
 }
 
+// Priorities counted by name in the request metrics. Other X-Priority values
+// are counted as "unknown", and requests without one as "none".
+var knownPriorities = map[string]bool{"critical": true, "high": true, "normal": true}
+
 // Stats tracks service statistics
 type Stats struct {
 	RequestsTotal     atomic.Int64
@@ -39,6 +60,9 @@ type Stats struct {
 	BytesReceived     atomic.Int64
 	ProcessingTimeNs  atomic.Int64
 	LastRequestTimeNs atomic.Int64
+
+	// Keeps snapshots from seeing part of a group of updates
+	guard mockutil.StatsGuard
 }
 
 // Global variables
@@ -47,12 +71,21 @@ var (
 	stats  Stats
 	logger *log.Logger
 
+	// Picks the requests logged with verbose logging
+	requestLogs mockutil.LogSampler
+
 	// Outage state
 	inOutage       bool
+	outageStart    time.Time
 	outageEndTime  time.Time
 	outageLock     = make(chan struct{}, 1)
 	outageComplete = make(chan struct{})
 
+	// Time down and then up in each cycle of a flapping outage, zero for a
+	// steady outage
+	flapDown time.Duration
+	flapUp   time.Duration
+
 	// Prometheus metrics
 	promRequestsTotal      *prometheus.CounterVec
 	promRequestsFailed     *prometheus.CounterVec
@@ -73,6 +106,10 @@ func main() {
 	logFile := flag.String("log-file", "", "Log file (empty for stdout)")
 	logLevel := flag.String("log-level", "info", "Log level (debug, info, warn, error)")
 	verbose := flag.Bool("verbose", false, "Enable verbose logging")
+	verboseSampleRate := flag.Int("verbose-sample-rate", 1, "Log 1 in this many requests when verbose")
+	statsInterval := flag.Int("stats-interval", 30, "Seconds between stats summaries in the log (0 disables)")
+	priorityErrorRates := flag.String("priority-error-rates", "", "Error rates by priority overriding -error-rate, e.g. critical=0,normal=20")
+	knownPathList := flag.String("known-paths", strings.Join(defaultKnownPaths, ","), "Comma-separated paths counted by name in the request metrics, others count as \"other\"")
 	flag.Parse()
 
 	// Initialize outageLock (buffered channel used as mutex)
@@ -80,33 +117,39 @@ func main() {
 
 	// Initialize config
 	config = Config{
-		HTTPPort:               *httpPort,
-		MetricsPort:            *metricsPort,
-		LatencyMin:             *latencyMin,
-		LatencyMax:             *latencyMax,
-		ErrorRate:              *errorRate,
-		RateLimitErrorRate:     *rateLimitErrorRate,
+		HTTPPort:                *httpPort,
+		MetricsPort:             *metricsPort,
+		LatencyMin:              *latencyMin,
+		LatencyMax:              *latencyMax,
+		ErrorRate:               *errorRate,
+		RateLimitErrorRate:      *rateLimitErrorRate,
 		SupportOutageSimulation: *supportOutage,
-		LogFile:                *logFile,
-		LogLevel:               *logLevel,
-		VerboseLogging:         *verbose,
+		LogFile:                 *logFile,
+		LogLevel:                *logLevel,
+		VerboseLogging:          *verbose,
+		VerboseLogSampleRate:    *verboseSampleRate,
+		StatsIntervalSec:        *statsInterval,
+		KnownPaths:              strings.Split(*knownPathList, ","),
 	}
+	setKnownPaths(config.KnownPaths)
 
-	// Check environment variables
-	if port := os.Getenv("PORT"); port != "" {
-		if p, err := fmt.Sscanf(port, "%d", &config.HTTPPort); err != nil {
-			log.Printf("Invalid PORT environment variable: %s", port)
+	if *priorityErrorRates != "" {
+		rates, err := parsePriorityErrorRates(*priorityErrorRates)
+		if err != nil {
+			log.Fatalf("Invalid -priority-error-rates: %v", err)
 		}
+		config.PriorityErrorRates = rates
 	}
-	if port := os.Getenv("METRICS_PORT"); port != "" {
-		if p, err := fmt.Sscanf(port, "%d", &config.MetricsPort); err != nil {
-			log.Printf("Invalid METRICS_PORT environment variable: %s", port)
-		}
+
+	// Check environment variables
+	if port, ok := mockutil.EnvInt("PORT", 1, 65535, log.Printf); ok {
+		config.HTTPPort = port
 	}
-	if errRate := os.Getenv("ERROR_RATE"); errRate != "" {
-		if r, err := fmt.Sscanf(errRate, "%d", &config.ErrorRate); err != nil {
-			log.Printf("Invalid ERROR_RATE environment variable: %s", errRate)
-		}
+	if port, ok := mockutil.EnvInt("METRICS_PORT", 1, 65535, log.Printf); ok {
+		config.MetricsPort = port
+	}
+	if errRate, ok := mockutil.EnvInt("ERROR_RATE", 0, 100, log.Printf); ok {
+		config.ErrorRate = errRate
 	}
 	if outage := os.Getenv("SUPPORT_OUTAGE_SIMULATION"); outage != "" {
 		config.SupportOutageSimulation = (outage == "true" || outage == "1")
@@ -130,6 +173,11 @@ func main() {
 	// Start metrics server
 	go startMetricsServer()
 
+	// Log a periodic summary of the stats
+	if config.StatsIntervalSec > 0 {
+		go statsReporter(time.Duration(config.StatsIntervalSec) * time.Second)
+	}
+
 	// Start HTTP server
 	startHTTPServer()
 }
@@ -140,7 +188,7 @@ func initPrometheusMetrics() {
 			Name: "mock_upstream_requests_total",
 			Help: "Total number of requests received",
 		},
-		[]string{"path", "method"},
+		[]string{"path", "method", "priority"},
 	)
 
 	promRequestsFailed = prometheus.NewCounterVec(
@@ -208,7 +256,7 @@ func startHTTPServer() {
 	mux.HandleFunc("/v1/profiles", handleRequest)
 	mux.HandleFunc("/healthz", handleHealthCheck)
 	mux.HandleFunc("/readyz", handleReadyCheck)
-	
+
 	// Outage control endpoint
 	if config.SupportOutageSimulation {
 		mux.HandleFunc("/outage", handleOutageControl)
@@ -230,14 +278,16 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
 	// Increment request counter
+	priority := requestPriority(r)
 	stats.RequestsTotal.Add(1)
-	promRequestsTotal.WithLabelValues(r.URL.Path, r.Method).Inc()
+	promRequestsTotal.WithLabelValues(pathLabel(r), methodLabel(r), priority).Inc()
 
 	// Check if we're in an outage
 	if isInOutage() {
 		// We're in an outage, return 503
 		http.Error(w, "Service Unavailable: Simulated outage", http.StatusServiceUnavailable)
-		promRequestsFailed.WithLabelValues(r.URL.Path, r.Method, "outage").Inc()
+		stats.RequestsFailed.Add(1)
+		promRequestsFailed.WithLabelValues(pathLabel(r), methodLabel(r), "outage").Inc()
 		return
 	}
 
@@ -246,7 +296,8 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		logger.Printf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusBadRequest)
-		promRequestsFailed.WithLabelValues(r.URL.Path, r.Method, "read_error").Inc()
+		stats.RequestsFailed.Add(1)
+		promRequestsFailed.WithLabelValues(pathLabel(r), methodLabel(r), "read_error").Inc()
 		return
 	}
 
@@ -261,27 +312,32 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 	}
 	time.Sleep(time.Duration(latency) * time.Millisecond)
 
-	// Simulate errors based on error rate
-	if config.ErrorRate > 0 && rand.Intn(100) < config.ErrorRate {
+	// Simulate errors based on the error rate for the request's priority
+	if errorRate := priorityErrorRate(priority); errorRate > 0 && rand.Intn(100) < errorRate {
 		http.Error(w, "Internal Server Error: Simulated error", http.StatusInternalServerError)
-		promRequestsFailed.WithLabelValues(r.URL.Path, r.Method, "error").Inc()
+		stats.RequestsFailed.Add(1)
+		promRequestsFailed.WithLabelValues(pathLabel(r), methodLabel(r), "error").Inc()
 		return
 	}
 
 	// Simulate rate limiting errors
 	if config.RateLimitErrorRate > 0 && rand.Intn(100) < config.RateLimitErrorRate {
 		http.Error(w, "Too Many Requests: Rate limited", http.StatusTooManyRequests)
-		promRequestsFailed.WithLabelValues(r.URL.Path, r.Method, "rate_limited").Inc()
+		stats.RequestsFailed.Add(1)
+		promRequestsFailed.WithLabelValues(pathLabel(r), methodLabel(r), "rate_limited").Inc()
 		return
 	}
 
 	// Calculate processing time
 	processingTime := time.Since(startTime)
-	stats.ProcessingTimeNs.Add(processingTime.Nanoseconds())
-	promProcessingDuration.WithLabelValues(r.URL.Path, r.Method).Observe(processingTime.Seconds())
-
-	// Log request if verbose
-	if config.VerboseLogging {
+	stats.guard.Update(func() {
+		stats.ProcessingTimeNs.Add(processingTime.Nanoseconds())
+		stats.LastRequestTimeNs.Store(time.Now().UnixNano())
+	})
+	promProcessingDuration.WithLabelValues(pathLabel(r), methodLabel(r)).Observe(processingTime.Seconds())
+
+	// Log a sample of requests if verbose
+	if requestLogs.Sample(config.VerboseLogging, config.VerboseLogSampleRate) {
 		logger.Printf("Processed request: %s %s %d bytes in %v",
 			r.Method, r.URL.Path, len(body), processingTime)
 	}
@@ -292,6 +348,51 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status":"success"}`))
 }
 
+// requestPriority returns the priority label for a request's X-Priority header.
+func requestPriority(r *http.Request) string {
+	priority := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Priority")))
+	if priority == "" {
+		return "none"
+	}
+	if !knownPriorities[priority] {
+		return "unknown"
+	}
+	return priority
+}
+
+// priorityErrorRate returns the error rate for a priority, falling back to
+// the overall error rate.
+func priorityErrorRate(priority string) int {
+	if rate, exists := config.PriorityErrorRates[priority]; exists {
+		return rate
+	}
+	return config.ErrorRate
+}
+
+// parsePriorityErrorRates parses a comma-separated list of priority=rate
+// pairs.
+func parsePriorityErrorRates(value string) (map[string]int, error) {
+	rates := make(map[string]int)
+	for _, pair := range strings.Split(value, ",") {
+		name, rateStr, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return nil, fmt.Errorf("expected priority=rate, got %q", pair)
+		}
+
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !knownPriorities[name] && name != "none" && name != "unknown" {
+			return nil, fmt.Errorf("unknown priority %q", name)
+		}
+
+		rate, err := strconv.Atoi(strings.TrimSpace(rateStr))
+		if err != nil || rate < 0 || rate > 100 {
+			return nil, fmt.Errorf("error rate for %s must be between 0 and 100", name)
+		}
+		rates[name] = rate
+	}
+	return rates, nil
+}
+
 func handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	// Health check is always healthy, even during outage (to distinguish from readiness)
 	w.Header().Set("Content-Type", "application/json")
@@ -329,6 +430,13 @@ func handleOutageControl(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Action          string `json:"action"`
 		DurationSeconds int    `json:"duration_seconds"`
+
+		// Seconds the end of the outage is randomly delayed by, at most
+		RecoveryJitterSeconds int `json:"recovery_jitter_seconds"`
+
+		// Seconds a flapping outage is down and up in each cycle
+		FlapDownSeconds int `json:"flap_down_seconds"`
+		FlapUpSeconds   int `json:"flap_up_seconds"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -336,6 +444,11 @@ func handleOutageControl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.RecoveryJitterSeconds < 0 || req.FlapDownSeconds < 0 || req.FlapUpSeconds < 0 {
+		http.Error(w, "Invalid outage timing", http.StatusBadRequest)
+		return
+	}
+
 	// Process the action
 	switch req.Action {
 	case "start":
@@ -345,7 +458,7 @@ func handleOutageControl(w http.ResponseWriter, r *http.Request) {
 			durationSeconds = 300 // Default to 5 minutes
 		}
 
-		if startOutage(durationSeconds) {
+		if startOutage(durationSeconds, req.RecoveryJitterSeconds, 0, 0) {
 			// Outage started
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
@@ -355,6 +468,29 @@ func handleOutageControl(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Outage already in progress", http.StatusConflict)
 		}
 
+	case "flap":
+		// Start an outage that goes down and up on a schedule
+		durationSeconds := req.DurationSeconds
+		if durationSeconds <= 0 {
+			durationSeconds = 300 // Default to 5 minutes
+		}
+		down, up := req.FlapDownSeconds, req.FlapUpSeconds
+		if down <= 0 {
+			down = 10
+		}
+		if up <= 0 {
+			up = 10
+		}
+
+		if startOutage(durationSeconds, req.RecoveryJitterSeconds, time.Duration(down)*time.Second, time.Duration(up)*time.Second) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(fmt.Sprintf(`{"status":"flapping started","duration_seconds":%d,"flap_down_seconds":%d,"flap_up_seconds":%d}`,
+				durationSeconds, down, up)))
+		} else {
+			http.Error(w, "Outage already in progress", http.StatusConflict)
+		}
+
 	case "stop":
 		// Stop the outage
 		if stopOutage() {
@@ -372,7 +508,11 @@ func handleOutageControl(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func startOutage(durationSeconds int) bool {
+// startOutage starts an outage lasting durationSeconds plus a random delay
+// of up to jitterSeconds, so a fleet of clients doesn't see the backend
+// recover at the same instant. With a non-zero down time the outage flaps,
+// down for down and then up for up in each cycle until it ends.
+func startOutage(durationSeconds int, jitterSeconds int, down time.Duration, up time.Duration) bool {
 	// Try to acquire the outage lock
 	select {
 	case <-outageLock:
@@ -384,13 +524,19 @@ func startOutage(durationSeconds int) bool {
 		}
 
 		// Start the outage
+		duration := time.Duration(durationSeconds) * time.Second
+		if jitterSeconds > 0 {
+			duration += time.Duration(rand.Int63n(int64(jitterSeconds) * int64(time.Second)))
+		}
 		inOutage = true
-		outageEndTime = time.Now().Add(time.Duration(durationSeconds) * time.Second)
+		outageStart = time.Now()
+		outageEndTime = outageStart.Add(duration)
+		flapDown, flapUp = down, up
 		promOutageStatus.Set(1)
 		stats.Outages.Add(1)
 
-		logger.Printf("Starting outage for %d seconds (until %s)",
-			durationSeconds, outageEndTime.Format(time.RFC3339))
+		logger.Printf("Starting outage for %v (until %s, flapping %v down, %v up)",
+			duration, outageEndTime.Format(time.RFC3339), flapDown, flapUp)
 
 		// Release the lock
 		outageLock <- struct{}{}
@@ -399,7 +545,7 @@ func startOutage(durationSeconds int) bool {
 		outageComplete = make(chan struct{})
 		go func() {
 			select {
-			case <-time.After(time.Duration(durationSeconds) * time.Second):
+			case <-time.After(duration):
 				stopOutage()
 			case <-outageComplete:
 				// Outage manually stopped
@@ -472,6 +618,11 @@ func isInOutage() bool {
 			return false
 		}
 
+		// A flapping outage is only down for part of each cycle
+		if flapDown > 0 {
+			return time.Since(outageStart)%(flapDown+flapUp) < flapDown
+		}
+
 		return true
 
 	default:
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRequestsCountedByPriority(t *testing.T) {
+	registerer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	t.Cleanup(func() { prometheus.DefaultRegisterer = registerer })
+	initPrometheusMetrics()
+	logger = log.New(io.Discard, "", 0)
+	setKnownPaths(defaultKnownPaths)
+
+	// Normal requests always fail, protecting the critical path
+	config = Config{PriorityErrorRates: map[string]int{"normal": 100, "critical": 0}, ErrorRate: 100}
+
+	statuses := make(map[string][]int)
+	for header, count := range map[string]int{"critical": 3, "HIGH": 2, " normal ": 1, "urgent": 1, "": 2} {
+		for i := 0; i < count; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/v1/metrics", strings.NewReader("payload"))
+			if header != "" {
+				req.Header.Set("X-Priority", header)
+			}
+			rec := httptest.NewRecorder()
+			handleRequest(rec, req)
+			statuses[header] = append(statuses[header], rec.Code)
+		}
+	}
+
+	for priority, want := range map[string]float64{"critical": 3, "high": 2, "normal": 1, "unknown": 1, "none": 2} {
+		if got := testutil.ToFloat64(promRequestsTotal.WithLabelValues("/v1/metrics", http.MethodPost, priority)); got != want {
+			t.Errorf("expected %v %s requests, got %v", want, priority, got)
+		}
+	}
+
+	// Critical requests use their own error rate, the rest fall back to
+	// the overall one
+	for _, code := range statuses["critical"] {
+		if code != http.StatusOK {
+			t.Fatalf("expected critical requests to succeed, got %v", statuses["critical"])
+		}
+	}
+	for _, header := range []string{" normal ", "HIGH", ""} {
+		for _, code := range statuses[header] {
+			if code != http.StatusInternalServerError {
+				t.Fatalf("expected %q requests to fail, got %v", header, statuses[header])
+			}
+		}
+	}
+}
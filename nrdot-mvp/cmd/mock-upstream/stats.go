@@ -0,0 +1,56 @@
+package main
+
+import (
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/internal/mockutil"
+)
+
+// StatsSnapshot is a consistent copy of the service statistics.
+type StatsSnapshot struct {
+	RequestsTotal    int64     `json:"requests_total"`
+	RequestsFailed   int64     `json:"requests_failed"`
+	Outages          int64     `json:"outages"`
+	OutageDurationMs int64     `json:"outage_duration_ms"`
+	BytesReceived    int64     `json:"bytes_received"`
+	ProcessingTimeNs int64     `json:"processing_time_ns"`
+	LastRequestTime  time.Time `json:"last_request_time"`
+}
+
+// Snapshot returns a copy of all statistics taken while no update is in
+// progress.
+func (s *Stats) Snapshot() StatsSnapshot {
+	var snapshot StatsSnapshot
+	s.guard.Snapshot(func() {
+		snapshot = StatsSnapshot{
+			RequestsTotal:    s.RequestsTotal.Load(),
+			RequestsFailed:   s.RequestsFailed.Load(),
+			Outages:          s.Outages.Load(),
+			OutageDurationMs: s.OutageDuration.Load(),
+			BytesReceived:    s.BytesReceived.Load(),
+			ProcessingTimeNs: s.ProcessingTimeNs.Load(),
+		}
+		if ns := s.LastRequestTimeNs.Load(); ns > 0 {
+			snapshot.LastRequestTime = time.Unix(0, ns)
+		}
+	})
+	return snapshot
+}
+
+// statsReporter logs a summary of the statistics every interval, with the
+// totals and the change since the previous summary.
+func statsReporter(interval time.Duration) {
+	mockutil.ReportStats(interval, stats.Snapshot, func(previous StatsSnapshot, current StatsSnapshot) {
+		requests := current.RequestsTotal - previous.RequestsTotal
+		var avgProcessingMs float64
+		if served := requests - (current.RequestsFailed - previous.RequestsFailed); served > 0 {
+			avgProcessingMs = float64(current.ProcessingTimeNs-previous.ProcessingTimeNs) / float64(served) / 1e6
+		}
+
+		logger.Printf("Stats: requests=%d (+%d, %.1f/s) failed=%d (+%d) bytes=%d (+%d) outages=%d avgProcessing=%.2fms",
+			current.RequestsTotal, requests, float64(requests)/interval.Seconds(),
+			current.RequestsFailed, current.RequestsFailed-previous.RequestsFailed,
+			current.BytesReceived, current.BytesReceived-previous.BytesReceived,
+			current.Outages, avgProcessingMs)
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestRequestBodySizeHistogram(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registerer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = registry
+	t.Cleanup(func() {
+		prometheus.DefaultRegisterer = registerer
+		stats = Stats{}
+	})
+	initPrometheusMetrics()
+	logger = log.New(io.Discard, "", 0)
+	config = Config{}
+
+	mux := http.NewServeMux()
+	for _, signal := range []string{"metrics", "traces"} {
+		mux.HandleFunc("/v1/"+signal, handleOTLPRequest(signal))
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	post := func(signal string, size int) {
+		t.Helper()
+		resp, err := http.Post(server.URL+"/v1/"+signal, "application/x-protobuf", bytes.NewReader(make([]byte, size)))
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		resp.Body.Close()
+	}
+	post("metrics", 100)
+	post("metrics", 2000)
+	post("metrics", 50000)
+	post("traces", 5<<20)
+
+	histograms := make(map[string]*dto.Histogram)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "nr_ingest_request_body_size_bytes" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "type" {
+					histograms[label.GetValue()] = metric.GetHistogram()
+				}
+			}
+		}
+	}
+
+	// Bucket counts are cumulative, so each size lands at its first bound
+	for signal, want := range map[string]map[float64]uint64{
+		"metrics": {1024: 1, 4096: 2, 16384: 2, 65536: 3, 64 << 20: 3},
+		"traces":  {1 << 20: 0, 4 << 20: 0, 16 << 20: 1, 64 << 20: 1},
+	} {
+		histogram, ok := histograms[signal]
+		if !ok {
+			t.Fatalf("expected a %s histogram, got %v", signal, histograms)
+		}
+		got := make(map[float64]uint64)
+		for _, bucket := range histogram.GetBucket() {
+			got[bucket.GetUpperBound()] = bucket.GetCumulativeCount()
+		}
+		for bound, count := range want {
+			if got[bound] != count {
+				t.Errorf("expected %d %s bodies up to %.0f bytes, got %d", count, signal, bound, got[bound])
+			}
+		}
+	}
+	if got := histograms["metrics"].GetSampleSum(); got != 52100 {
+		t.Fatalf("expected the metrics sizes to sum to 52100, got %v", got)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errOutage is returned by the gRPC services during a simulated outage. The
+// Unavailable code makes OTLP exporters retry, as a 503 does over HTTP.
+var errOutage = status.Error(codes.Unavailable, "service unavailable: simulated outage")
+
+// startGRPCServer serves the OTLP metrics, traces and logs services over
+// gRPC, counting requests in the same stats as the HTTP endpoints.
+func startGRPCServer() {
+	addr := fmt.Sprintf(":%d", config.GRPCPort)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Fatalf("Failed to listen for gRPC on %s: %v", addr, err)
+	}
+
+	server := grpc.NewServer()
+	pmetricotlp.RegisterGRPCServer(server, &metricsGRPCService{})
+	ptraceotlp.RegisterGRPCServer(server, &tracesGRPCService{})
+	plogotlp.RegisterGRPCServer(server, &logsGRPCService{})
+
+	logger.Printf("Starting OTLP gRPC server on %s", addr)
+	if err := server.Serve(listener); err != nil {
+		logger.Fatalf("Failed to start gRPC server: %v", err)
+	}
+}
+
+// beginGRPCRequest rejects a request during a simulated outage, and
+// otherwise counts its size, measured as its protobuf encoding, as received.
+func beginGRPCRequest(signalType string, size int) error {
+	if isInOutage() {
+		stats.FailedRequests.Add(1)
+		return errOutage
+	}
+
+	stats.BytesReceived.Add(int64(size))
+	promBytesReceived.Add(float64(size))
+	promRequestBodySize.WithLabelValues(signalType).Observe(float64(size))
+	return nil
+}
+
+// metricsGRPCService implements the OTLP metrics service.
+type metricsGRPCService struct {
+	pmetricotlp.UnimplementedGRPCServer
+}
+
+// Export receives a metrics export request.
+func (s *metricsGRPCService) Export(_ context.Context, req pmetricotlp.ExportRequest) (pmetricotlp.ExportResponse, error) {
+	startTime := time.Now()
+	metrics := req.Metrics()
+
+	if err := beginGRPCRequest("metrics", (&pmetric.ProtoMarshaler{}).MetricsSize(metrics)); err != nil {
+		return pmetricotlp.NewExportResponse(), err
+	}
+
+	recordSignal("metrics", nil, requestLogs.Sample(config.VerboseLogging, config.VerboseLogSampleRate))
+	if config.VerifySequences {
+		recordMetricsSequences(metrics)
+	}
+	recordCompleted("metrics", startTime)
+
+	return pmetricotlp.NewExportResponse(), nil
+}
+
+// tracesGRPCService implements the OTLP traces service.
+type tracesGRPCService struct {
+	ptraceotlp.UnimplementedGRPCServer
+}
+
+// Export receives a traces export request.
+func (s *tracesGRPCService) Export(_ context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	startTime := time.Now()
+
+	if err := beginGRPCRequest("traces", (&ptrace.ProtoMarshaler{}).TracesSize(req.Traces())); err != nil {
+		return ptraceotlp.NewExportResponse(), err
+	}
+
+	recordSignal("traces", nil, requestLogs.Sample(config.VerboseLogging, config.VerboseLogSampleRate))
+	recordCompleted("traces", startTime)
+
+	return ptraceotlp.NewExportResponse(), nil
+}
+
+// logsGRPCService implements the OTLP logs service.
+type logsGRPCService struct {
+	plogotlp.UnimplementedGRPCServer
+}
+
+// Export receives a logs export request.
+func (s *logsGRPCService) Export(_ context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	startTime := time.Now()
+
+	if err := beginGRPCRequest("logs", (&plog.ProtoMarshaler{}).LogsSize(req.Logs())); err != nil {
+		return plogotlp.NewExportResponse(), err
+	}
+
+	recordSignal("logs", nil, requestLogs.Sample(config.VerboseLogging, config.VerboseLogSampleRate))
+	recordCompleted("logs", startTime)
+
+	return plogotlp.NewExportResponse(), nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -8,13 +10,24 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/yourusername/nrdot-mvp/internal/mockutil"
 )
 
+// SequenceAttribute is the data point attribute carrying the workload
+// generator's sequence ID.
+const SequenceAttribute = "nrdot.sequence"
+
 // Configuration for the nr-ingest mock service
 type Config struct {
 	HTTPPort       int    `json:"http_port"`
@@ -22,6 +35,19 @@ type Config struct {
 	LogFile        string `json:"log_file"`
 	LogLevel       string `json:"log_level"`
 	VerboseLogging bool   `json:"verbose_logging"`
+
+	// Log only 1 in this many requests when verbose, so high request rates
+	// don't flood the output
+	VerboseLogSampleRate int `json:"verbose_log_sample_rate"`
+
+	// Whether to track sequence IDs in received metrics
+	VerifySequences bool `json:"verify_sequences"`
+
+	// Port for the OTLP gRPC endpoint, 0 to serve OTLP/HTTP only
+	GRPCPort int `json:"grpc_port"`
+
+	// Seconds between stats summaries in the log, 0 to disable them
+	StatsIntervalSec int `json:"stats_interval_sec"`
 }
 
 // Stats tracks ingest statistics
@@ -35,6 +61,17 @@ type Stats struct {
 	FailedRequests    atomic.Int64
 	ProcessingTimeNs  atomic.Int64
 	LastRequestTimeNs atomic.Int64
+
+	// Keeps snapshots from seeing part of a group of updates
+	guard mockutil.StatsGuard
+}
+
+// SequenceTracker records the sequence IDs seen in received metrics so
+// delivery can be verified end to end.
+type SequenceTracker struct {
+	seen       map[int64]struct{}
+	duplicates int64
+	mutex      sync.Mutex
 }
 
 // Global variables
@@ -43,11 +80,22 @@ var (
 	stats  Stats
 	logger *log.Logger
 
+	sequences = &SequenceTracker{seen: make(map[int64]struct{})}
+
+	// Picks the requests logged with verbose logging
+	requestLogs mockutil.LogSampler
+
+	// Simulated outage state, as unix nanoseconds of the outage end
+	outageEndNs atomic.Int64
+
 	// Prometheus metrics
 	promRequestsTotal      *prometheus.CounterVec
-	promBytesReceived      *prometheus.Counter
+	promBytesReceived      prometheus.Counter
+	promRequestBodySize    *prometheus.HistogramVec
 	promProcessingDuration *prometheus.HistogramVec
 	promTelemetryItems     *prometheus.CounterVec
+	promSequencesReceived  prometheus.Counter
+	promSequenceDuplicates prometheus.Counter
 )
 
 func main() {
@@ -57,15 +105,34 @@ func main() {
 	logFile := flag.String("log-file", "", "Log file (empty for stdout)")
 	logLevel := flag.String("log-level", "info", "Log level (debug, info, warn, error)")
 	verbose := flag.Bool("verbose", false, "Enable verbose logging")
+	verboseSampleRate := flag.Int("verbose-sample-rate", 1, "Log 1 in this many requests when verbose")
+	verifySequences := flag.Bool("verify-sequences", false, "Track workload generator sequence IDs in received metrics")
+	statsInterval := flag.Int("stats-interval", 30, "Seconds between stats summaries in the log (0 disables)")
+	grpcPort := flag.Int("grpc-port", 0, "gRPC port for the OTLP endpoint (0 disables)")
 	flag.Parse()
 
 	// Initialize config
 	config = Config{
-		HTTPPort:       *httpPort,
-		MetricsPort:    *metricsPort,
-		LogFile:        *logFile,
-		LogLevel:       *logLevel,
-		VerboseLogging: *verbose,
+		HTTPPort:             *httpPort,
+		MetricsPort:          *metricsPort,
+		LogFile:              *logFile,
+		LogLevel:             *logLevel,
+		VerboseLogging:       *verbose,
+		VerboseLogSampleRate: *verboseSampleRate,
+		VerifySequences:      *verifySequences,
+		StatsIntervalSec:     *statsInterval,
+		GRPCPort:             *grpcPort,
+	}
+
+	// Override from environment
+	if port, ok := mockutil.EnvInt("PORT", 1, 65535, log.Printf); ok {
+		config.HTTPPort = port
+	}
+	if port, ok := mockutil.EnvInt("METRICS_PORT", 1, 65535, log.Printf); ok {
+		config.MetricsPort = port
+	}
+	if val := os.Getenv("VERIFY_SEQUENCES"); val == "true" || val == "1" {
+		config.VerifySequences = true
 	}
 
 	// Initialize logger
@@ -86,6 +153,16 @@ func main() {
 	// Start metrics server
 	go startMetricsServer()
 
+	// Log a periodic summary of the stats
+	if config.StatsIntervalSec > 0 {
+		go statsReporter(time.Duration(config.StatsIntervalSec) * time.Second)
+	}
+
+	// Serve OTLP over gRPC alongside OTLP/HTTP if configured
+	if config.GRPCPort > 0 {
+		go startGRPCServer()
+	}
+
 	// Start HTTP server
 	startHTTPServer()
 }
@@ -106,6 +183,16 @@ func initPrometheusMetrics() {
 		},
 	)
 
+	promRequestBodySize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "nr_ingest_request_body_size_bytes",
+			Help: "Size of request bodies by signal type",
+			// 1 KiB up to 64 MiB, quadrupling
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 9),
+		},
+		[]string{"type"},
+	)
+
 	promProcessingDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "nr_ingest_processing_duration_seconds",
@@ -123,9 +210,26 @@ func initPrometheusMetrics() {
 		[]string{"type"},
 	)
 
+	promSequencesReceived = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "nr_ingest_sequences_received_total",
+			Help: "Total number of distinct sequence IDs received",
+		},
+	)
+
+	promSequenceDuplicates = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "nr_ingest_sequence_duplicates_total",
+			Help: "Total number of sequence IDs received more than once",
+		},
+	)
+
 	// Register metrics
+	prometheus.MustRegister(promSequencesReceived)
+	prometheus.MustRegister(promSequenceDuplicates)
 	prometheus.MustRegister(promRequestsTotal)
 	prometheus.MustRegister(promBytesReceived)
+	prometheus.MustRegister(promRequestBodySize)
 	prometheus.MustRegister(promProcessingDuration)
 	prometheus.MustRegister(promTelemetryItems)
 }
@@ -150,6 +254,10 @@ func startHTTPServer() {
 	mux.HandleFunc("/v1/logs", handleOTLPRequest("logs"))
 	mux.HandleFunc("/v1/profiles", handleOTLPRequest("profiles"))
 
+	// Sequence verification and outage control
+	mux.HandleFunc("/sequences", handleSequences)
+	mux.HandleFunc("/outage", handleOutageControl)
+
 	// Health check
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -177,6 +285,13 @@ func handleOTLPRequest(signalType string) http.HandlerFunc {
 			return
 		}
 
+		// Reject requests during a simulated outage so the sender retries
+		if isInOutage() {
+			http.Error(w, "Service unavailable: simulated outage", http.StatusServiceUnavailable)
+			stats.FailedRequests.Add(1)
+			return
+		}
+
 		// Read request body
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
@@ -186,45 +301,30 @@ func handleOTLPRequest(signalType string) http.HandlerFunc {
 			return
 		}
 
-		// Decompress if needed (in a real implementation)
-		// For now, we'll just count the raw bytes
+		// Count the raw bytes as received on the wire
 		bodySize := int64(len(body))
 		stats.BytesReceived.Add(bodySize)
 		promBytesReceived.Add(float64(bodySize))
-
-		// Process based on signal type
-		switch signalType {
-		case "metrics":
-			stats.MetricsReceived.Add(1)
-			// Parse metrics (simplified for mock)
-			countMetrics(body)
-		case "traces":
-			stats.TracesReceived.Add(1)
-			// Parse traces (simplified for mock)
-			countTraces(body)
-		case "logs":
-			stats.LogsReceived.Add(1)
-			// Parse logs (simplified for mock)
-			countLogs(body)
-		case "profiles":
-			stats.ProfilesReceived.Add(1)
-			// Parse profiles (simplified for mock)
-			countProfiles(body)
+		promRequestBodySize.WithLabelValues(signalType).Observe(float64(bodySize))
+
+		// Process based on signal type, deciding once whether to log it
+		verbose := requestLogs.Sample(config.VerboseLogging, config.VerboseLogSampleRate)
+		recordSignal(signalType, body, verbose)
+		if signalType == "metrics" && config.VerifySequences {
+			if err := recordSequences(r, body); err != nil {
+				logger.Printf("Error decoding metrics for sequence verification: %v", err)
+				http.Error(w, "Error decoding metrics", http.StatusBadRequest)
+				stats.FailedRequests.Add(1)
+				return
+			}
 		}
 
 		// Update stats
-		stats.TotalRequests.Add(1)
-		promRequestsTotal.WithLabelValues(signalType).Inc()
-
-		// Calculate processing time
-		processingTime := time.Since(startTime)
-		stats.ProcessingTimeNs.Add(processingTime.Nanoseconds())
-		stats.LastRequestTimeNs.Store(time.Now().UnixNano())
-		promProcessingDuration.WithLabelValues(signalType).Observe(processingTime.Seconds())
+		processingTime := recordCompleted(signalType, startTime)
 
-		// Log request if verbose
-		if config.VerboseLogging {
-			logger.Printf("Received %s request: %d bytes, processed in %v", 
+		// Log request if sampled
+		if verbose {
+			logger.Printf("Received %s request: %d bytes, processed in %v",
 				signalType, bodySize, processingTime)
 		}
 
@@ -235,50 +335,280 @@ func handleOTLPRequest(signalType string) http.HandlerFunc {
 	}
 }
 
+// recordSignal counts a request for a signal type, logging the batch if
+// verbose.
+func recordSignal(signalType string, body []byte, verbose bool) {
+	switch signalType {
+	case "metrics":
+		stats.MetricsReceived.Add(1)
+		// Parse metrics (simplified for mock)
+		countMetrics(body, verbose)
+	case "traces":
+		stats.TracesReceived.Add(1)
+		// Parse traces (simplified for mock)
+		countTraces(body, verbose)
+	case "logs":
+		stats.LogsReceived.Add(1)
+		// Parse logs (simplified for mock)
+		countLogs(body, verbose)
+	case "profiles":
+		stats.ProfilesReceived.Add(1)
+		// Parse profiles (simplified for mock)
+		countProfiles(body, verbose)
+	}
+}
+
+// recordCompleted counts a successfully processed request and returns how
+// long it took.
+func recordCompleted(signalType string, startTime time.Time) time.Duration {
+	processingTime := time.Since(startTime)
+	stats.guard.Update(func() {
+		stats.TotalRequests.Add(1)
+		stats.ProcessingTimeNs.Add(processingTime.Nanoseconds())
+		stats.LastRequestTimeNs.Store(time.Now().UnixNano())
+	})
+	promRequestsTotal.WithLabelValues(signalType).Inc()
+	promProcessingDuration.WithLabelValues(signalType).Observe(processingTime.Seconds())
+	return processingTime
+}
+
 // Parse and count metrics (simplified implementation)
-func countMetrics(body []byte) {
+func countMetrics(body []byte, verbose bool) {
 	// In a real implementation, parse OTLP metrics protobuf
 	// For this mock, we'll just count as 1 batch
 	promTelemetryItems.WithLabelValues("metrics").Inc()
-	
+
 	// Log request data for debugging
-	if config.VerboseLogging {
+	if verbose {
 		logger.Printf("Processed metrics batch")
 	}
 }
 
 // Parse and count traces (simplified implementation)
-func countTraces(body []byte) {
+func countTraces(body []byte, verbose bool) {
 	// In a real implementation, parse OTLP traces protobuf
 	// For this mock, we'll just count as 1 batch
 	promTelemetryItems.WithLabelValues("traces").Inc()
-	
+
 	// Log request data for debugging
-	if config.VerboseLogging {
+	if verbose {
 		logger.Printf("Processed traces batch")
 	}
 }
 
 // Parse and count logs (simplified implementation)
-func countLogs(body []byte) {
+func countLogs(body []byte, verbose bool) {
 	// In a real implementation, parse OTLP logs protobuf
 	// For this mock, we'll just count as 1 batch
 	promTelemetryItems.WithLabelValues("logs").Inc()
-	
+
 	// Log request data for debugging
-	if config.VerboseLogging {
+	if verbose {
 		logger.Printf("Processed logs batch")
 	}
 }
 
 // Parse and count profiles (simplified implementation)
-func countProfiles(body []byte) {
+func countProfiles(body []byte, verbose bool) {
 	// In a real implementation, parse OTLP profiles protobuf
 	// For this mock, we'll just count as 1 batch
 	promTelemetryItems.WithLabelValues("profiles").Inc()
-	
+
 	// Log request data for debugging
-	if config.VerboseLogging {
+	if verbose {
 		logger.Printf("Processed profiles batch")
 	}
 }
+
+// recordSequences decodes an OTLP metrics request and records the sequence ID
+// of every data point that carries one.
+func recordSequences(r *http.Request, body []byte) error {
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to open gzip body: %w", err)
+		}
+		defer reader.Close()
+
+		body, err = io.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("failed to decompress body: %w", err)
+		}
+	} else if encoding := r.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+
+	var unmarshaler pmetric.Unmarshaler = &pmetric.ProtoUnmarshaler{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		unmarshaler = &pmetric.JSONUnmarshaler{}
+	}
+
+	metrics, err := unmarshaler.UnmarshalMetrics(body)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal metrics: %w", err)
+	}
+
+	recordMetricsSequences(metrics)
+	return nil
+}
+
+// recordMetricsSequences records the sequence ID of every data point in
+// decoded metrics that carries one.
+func recordMetricsSequences(metrics pmetric.Metrics) {
+	rms := metrics.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			ms := sms.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				recordMetricSequences(ms.At(k))
+			}
+		}
+	}
+}
+
+// recordMetricSequences records the sequence IDs of a metric's data points.
+func recordMetricSequences(metric pmetric.Metric) {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		dps := metric.Gauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			sequences.Record(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeSum:
+		dps := metric.Sum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			sequences.Record(dps.At(i).Attributes())
+		}
+	case pmetric.MetricTypeHistogram:
+		dps := metric.Histogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			sequences.Record(dps.At(i).Attributes())
+		}
+	}
+}
+
+// Record records the sequence ID in the attributes, if there is one.
+func (t *SequenceTracker) Record(attrs pcommon.Map) {
+	value, ok := attrs.Get(SequenceAttribute)
+	if !ok || value.Type() != pcommon.ValueTypeInt {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	seq := value.Int()
+	if _, exists := t.seen[seq]; exists {
+		t.duplicates++
+		promSequenceDuplicates.Inc()
+		return
+	}
+
+	t.seen[seq] = struct{}{}
+	promSequencesReceived.Inc()
+}
+
+// Missing returns the expected sequence IDs that have not been received.
+func (t *SequenceTracker) Missing(expected []int64) []int64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	missing := make([]int64, 0)
+	for _, seq := range expected {
+		if _, exists := t.seen[seq]; !exists {
+			missing = append(missing, seq)
+		}
+	}
+
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	return missing
+}
+
+// Summary returns the number of distinct and duplicate sequence IDs received.
+func (t *SequenceTracker) Summary() (int, int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.seen), t.duplicates
+}
+
+// handleSequences reports the received sequence IDs. A GET returns a summary;
+// a POST with a JSON array of expected sequence IDs also returns the ones
+// that have not arrived.
+func handleSequences(w http.ResponseWriter, r *http.Request) {
+	if !config.VerifySequences {
+		http.Error(w, "Sequence verification not enabled", http.StatusBadRequest)
+		return
+	}
+
+	received, duplicates := sequences.Summary()
+	response := map[string]interface{}{
+		"received":   received,
+		"duplicates": duplicates,
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var expected []int64
+		if err := json.NewDecoder(r.Body).Decode(&expected); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		missing := sequences.Missing(expected)
+		response["expected"] = len(expected)
+		response["missing"] = missing
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleOutageControl starts or stops a simulated outage, during which the
+// OTLP endpoints return 503.
+func handleOutageControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Action   string `json:"action"`
+		Duration int    `json:"duration_seconds"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	switch req.Action {
+	case "start":
+		if req.Duration <= 0 {
+			req.Duration = 60 // Default to 60 seconds
+		}
+
+		outageEndNs.Store(time.Now().Add(time.Duration(req.Duration) * time.Second).UnixNano())
+		logger.Printf("Started simulated outage for %d seconds", req.Duration)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf(`{"status":"outage_started","duration_seconds":%d}`, req.Duration)))
+
+	case "stop":
+		outageEndNs.Store(0)
+		logger.Printf("Stopped simulated outage")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"outage_stopped"}`))
+
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+	}
+}
+
+// isInOutage checks if the service is currently in a simulated outage.
+func isInOutage() bool {
+	return time.Now().UnixNano() < outageEndNs.Load()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/internal/mockutil"
+)
+
+// StatsSnapshot is a consistent copy of the ingest statistics.
+type StatsSnapshot struct {
+	MetricsReceived  int64     `json:"metrics_received"`
+	TracesReceived   int64     `json:"traces_received"`
+	LogsReceived     int64     `json:"logs_received"`
+	ProfilesReceived int64     `json:"profiles_received"`
+	BytesReceived    int64     `json:"bytes_received"`
+	TotalRequests    int64     `json:"total_requests"`
+	FailedRequests   int64     `json:"failed_requests"`
+	ProcessingTimeNs int64     `json:"processing_time_ns"`
+	LastRequestTime  time.Time `json:"last_request_time"`
+}
+
+// Snapshot returns a copy of all statistics taken while no update is in
+// progress.
+func (s *Stats) Snapshot() StatsSnapshot {
+	var snapshot StatsSnapshot
+	s.guard.Snapshot(func() {
+		snapshot = StatsSnapshot{
+			MetricsReceived:  s.MetricsReceived.Load(),
+			TracesReceived:   s.TracesReceived.Load(),
+			LogsReceived:     s.LogsReceived.Load(),
+			ProfilesReceived: s.ProfilesReceived.Load(),
+			BytesReceived:    s.BytesReceived.Load(),
+			TotalRequests:    s.TotalRequests.Load(),
+			FailedRequests:   s.FailedRequests.Load(),
+			ProcessingTimeNs: s.ProcessingTimeNs.Load(),
+		}
+		if ns := s.LastRequestTimeNs.Load(); ns > 0 {
+			snapshot.LastRequestTime = time.Unix(0, ns)
+		}
+	})
+	return snapshot
+}
+
+// statsReporter logs a summary of the statistics every interval, with the
+// totals and the change since the previous summary.
+func statsReporter(interval time.Duration) {
+	mockutil.ReportStats(interval, stats.Snapshot, func(previous StatsSnapshot, current StatsSnapshot) {
+		requests := current.TotalRequests - previous.TotalRequests
+		var avgProcessingMs float64
+		if requests > 0 {
+			avgProcessingMs = float64(current.ProcessingTimeNs-previous.ProcessingTimeNs) / float64(requests) / 1e6
+		}
+
+		logger.Printf("Stats: requests=%d (+%d, %.1f/s) failed=%d (+%d) metrics=%d traces=%d logs=%d profiles=%d bytes=%d (+%d) avgProcessing=%.2fms",
+			current.TotalRequests, requests, float64(requests)/interval.Seconds(),
+			current.FailedRequests, current.FailedRequests-previous.FailedRequests,
+			current.MetricsReceived, current.TracesReceived, current.LogsReceived, current.ProfilesReceived,
+			current.BytesReceived, current.BytesReceived-previous.BytesReceived,
+			avgProcessingMs)
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSnapshotMatchesRequestsServed(t *testing.T) {
+	registerer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	t.Cleanup(func() { prometheus.DefaultRegisterer = registerer })
+	initPrometheusMetrics()
+	logger = log.New(io.Discard, "", 0)
+	config = Config{}
+
+	mux := http.NewServeMux()
+	for _, signal := range []string{"metrics", "traces", "logs", "profiles"} {
+		mux.HandleFunc("/v1/"+signal, handleOTLPRequest(signal))
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	before := time.Now()
+	body := []byte("payload")
+	requests := map[string]int{"metrics": 5, "traces": 3, "logs": 2, "profiles": 1}
+	var wg sync.WaitGroup
+	for signal, count := range requests {
+		for i := 0; i < count; i++ {
+			wg.Add(1)
+			go func(signal string) {
+				defer wg.Done()
+				resp, err := http.Post(server.URL+"/v1/"+signal, "application/x-protobuf", bytes.NewReader(body))
+				if err != nil {
+					t.Errorf("failed to send request: %v", err)
+					return
+				}
+				resp.Body.Close()
+			}(signal)
+		}
+	}
+	wg.Wait()
+
+	// A request with the wrong method fails
+	resp, err := http.Get(server.URL + "/v1/metrics")
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	resp.Body.Close()
+
+	snapshot := stats.Snapshot()
+	if snapshot.MetricsReceived != 5 || snapshot.TracesReceived != 3 || snapshot.LogsReceived != 2 || snapshot.ProfilesReceived != 1 {
+		t.Fatalf("unexpected per-signal counts %+v", snapshot)
+	}
+	if snapshot.TotalRequests != 11 || snapshot.FailedRequests != 1 {
+		t.Fatalf("expected 11 requests and 1 failure, got %d and %d", snapshot.TotalRequests, snapshot.FailedRequests)
+	}
+	if want := int64(11 * len(body)); snapshot.BytesReceived != want {
+		t.Fatalf("expected %d bytes received, got %d", want, snapshot.BytesReceived)
+	}
+	if snapshot.ProcessingTimeNs <= 0 || snapshot.LastRequestTime.Before(before) {
+		t.Fatalf("expected processing time and the last request time to be recorded, got %+v", snapshot)
+	}
+}
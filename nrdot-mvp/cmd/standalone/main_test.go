@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDLQReplaysInInsertionOrder(t *testing.T) {
+	dlq := &DLQ{
+		storage:    make(map[string]string),
+		maxSize:    100,
+		replayRate: 1000,
+	}
+
+	// Ids that would come back in a different order if sorted or hashed
+	var written []string
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("item-%d", (i*7)%20)
+		dlq.Write(id, "data-"+id)
+		written = append(written, id)
+	}
+
+	// Rewriting an item keeps its position
+	dlq.Write(written[3], "rewritten")
+
+	var replayed []string
+	dlq.Replay(func(id, data string) {
+		replayed = append(replayed, id)
+		if id == written[3] && data != "rewritten" {
+			t.Errorf("expected %s to replay its latest data, got %q", id, data)
+		}
+	})
+
+	if len(replayed) != len(written) {
+		t.Fatalf("expected %d items to be replayed, got %d", len(written), len(replayed))
+	}
+	for i, id := range written {
+		if replayed[i] != id {
+			t.Fatalf("expected replay in insertion order %v, got %v", written, replayed)
+		}
+	}
+}
+
+func TestCardinalityThresholdsShiftDecisions(t *testing.T) {
+	// newLimiter returns a full limiter with the given thresholds
+	newLimiter := func(dropBelow, aggregateBelow float64) *CardinalityLimiter {
+		return &CardinalityLimiter{
+			maxKeys:        1,
+			keys:           map[string]float64{"existing": 0.2},
+			dropBelow:      dropBelow,
+			aggregateBelow: aggregateBelow,
+		}
+	}
+
+	// A medium entropy key is dropped by a high drop threshold
+	limiter := newLimiter(0.75, 0.9)
+	limiter.ProcessKey("service-a", 0.5)
+	if limiter.droppedCount != 1 || limiter.aggregatedCount != 0 {
+		t.Fatalf("expected the key to be dropped, got %d dropped and %d aggregated", limiter.droppedCount, limiter.aggregatedCount)
+	}
+
+	// and aggregated once the drop threshold is lowered
+	limiter = newLimiter(0.3, 0.9)
+	limiter.ProcessKey("service-a", 0.5)
+	if limiter.droppedCount != 0 || limiter.aggregatedCount != 1 {
+		t.Fatalf("expected the key to be aggregated, got %d dropped and %d aggregated", limiter.droppedCount, limiter.aggregatedCount)
+	}
+
+	// and kept in place of a lower entropy key once the aggregate threshold is
+	limiter = newLimiter(0.3, 0.4)
+	limiter.ProcessKey("service-a", 0.5)
+	if _, kept := limiter.keys["service-a"]; !kept || limiter.aggregatedCount != 0 {
+		t.Fatalf("expected the key to be kept, got keys %v", limiter.keys)
+	}
+}
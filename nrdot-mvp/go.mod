@@ -3,6 +3,7 @@ module github.com/yourusername/nrdot-mvp
 go 1.21
 
 require (
+	github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusexporter v0.83.0
 	github.com/prometheus/client_golang v1.17.0
 	go.opentelemetry.io/collector v0.83.0
 	go.opentelemetry.io/collector/component v0.83.0
@@ -11,7 +12,6 @@ require (
 	go.opentelemetry.io/collector/exporter v0.83.0
 	go.opentelemetry.io/collector/exporter/otlpexporter v0.83.0
 	go.opentelemetry.io/collector/exporter/otlphttpexporter v0.83.0
-	go.opentelemetry.io/collector/exporter/prometheusexporter v0.83.0
 	go.opentelemetry.io/collector/extension v0.83.0
 	go.opentelemetry.io/collector/featuregate v1.0.0-rcv0014
 	go.opentelemetry.io/collector/pdata v1.0.0-rcv0014
@@ -22,6 +22,7 @@ require (
 	go.opentelemetry.io/collector/receiver/otlpreceiver v0.83.0
 	go.uber.org/atomic v1.10.0
 	go.uber.org/zap v1.25.0
+	google.golang.org/grpc v1.57.0
 )
 
 require (
@@ -55,7 +56,6 @@ require (
 	golang.org/x/sys v0.11.0 // indirect
 	golang.org/x/text v0.12.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20230530153820-e85fd2cbaebc // indirect
-	google.golang.org/grpc v1.57.0 // indirect
 	google.golang.org/protobuf v1.31.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
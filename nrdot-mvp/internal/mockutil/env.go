@@ -0,0 +1,27 @@
+// Package mockutil holds the helpers shared by the mock services that stand
+// in for New Relic and other backends in tests and demos.
+package mockutil
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EnvInt returns the integer in the environment variable name, and whether
+// it was set to a valid value between low and high inclusive. An invalid
+// value is reported through warnf and ignored, keeping the configured value.
+func EnvInt(name string, low int, high int, warnf func(format string, args ...interface{})) (int, bool) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, false
+	}
+
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || parsed < low || parsed > high {
+		warnf("Ignoring invalid %s environment variable %q: must be an integer between %d and %d",
+			name, value, low, high)
+		return 0, false
+	}
+	return parsed, true
+}
@@ -0,0 +1,40 @@
+package mockutil
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEnvIntAppliesOnlyValidPorts(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		port  int
+		ok    bool
+	}{
+		{value: "", ok: false},
+		{value: "8080", port: 8080, ok: true},
+		{value: " 9090 ", port: 9090, ok: true},
+		{value: "65535", port: 65535, ok: true},
+		{value: "0", ok: false},
+		{value: "65536", ok: false},
+		{value: "-1", ok: false},
+		{value: "80abc", ok: false},
+		{value: "port", ok: false},
+	} {
+		t.Setenv("PORT", tc.value)
+
+		var warnings []string
+		warnf := func(format string, args ...interface{}) {
+			warnings = append(warnings, fmt.Sprintf(format, args...))
+		}
+		port, ok := EnvInt("PORT", 1, 65535, warnf)
+		if ok != tc.ok || port != tc.port {
+			t.Errorf("PORT=%q: expected (%d, %v), got (%d, %v)", tc.value, tc.port, tc.ok, port, ok)
+		}
+
+		// Only a value that is set but rejected is reported
+		if rejected := tc.value != "" && !tc.ok; (len(warnings) == 1) != rejected {
+			t.Errorf("PORT=%q: expected a warning %v, got %v", tc.value, rejected, warnings)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package mockutil
+
+import (
+	"sync/atomic"
+)
+
+// LogSampler picks the requests to log verbosely. The zero value is ready
+// to use.
+type LogSampler struct {
+	// Requests eligible for verbose logging
+	count atomic.Int64
+}
+
+// Sample returns whether to log a request verbosely: with verbose logging
+// on, 1 in every rate requests is logged, evenly spaced. A rate of 1 or less
+// logs every request.
+func (s *LogSampler) Sample(verbose bool, rate int) bool {
+	if !verbose {
+		return false
+	}
+	n := s.count.Add(1)
+	return rate <= 1 || (n-1)%int64(rate) == 0
+}
@@ -0,0 +1,31 @@
+package mockutil
+
+import (
+	"testing"
+)
+
+func TestLogSamplerSpacesLoggedRequests(t *testing.T) {
+	for _, tc := range []struct {
+		verbose bool
+		rate    int
+		logged  int
+	}{
+		{verbose: true, rate: 0, logged: 1000},
+		{verbose: true, rate: 1, logged: 1000},
+		{verbose: true, rate: 10, logged: 100},
+		{verbose: true, rate: 3, logged: 334},
+		{verbose: false, rate: 10, logged: 0},
+	} {
+		var sampler LogSampler
+		logged := 0
+		for i := 0; i < 1000; i++ {
+			if sampler.Sample(tc.verbose, tc.rate) {
+				logged++
+			}
+		}
+		if logged != tc.logged {
+			t.Errorf("verbose %v, rate %d: expected %d of 1000 requests to be logged, got %d",
+				tc.verbose, tc.rate, tc.logged, logged)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package mockutil
+
+import (
+	"sync"
+	"time"
+)
+
+// StatsGuard keeps snapshots of a set of counters consistent: a group of
+// counter updates applied through Update is seen by a snapshot either all or
+// none. The zero value is ready to use.
+type StatsGuard struct {
+	// Held for reading while updating counters together and for writing
+	// while taking a snapshot
+	mutex sync.RWMutex
+}
+
+// Update applies a group of counter updates that a snapshot must see either
+// all or none of. Updates run concurrently with each other.
+func (g *StatsGuard) Update(fn func()) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	fn()
+}
+
+// Snapshot runs fn, which copies the counters, while no update is in
+// progress.
+func (g *StatsGuard) Snapshot(fn func()) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	fn()
+}
+
+// ReportStats calls report every interval with the snapshot taken then and
+// the one taken an interval earlier, so a summary can show the totals and
+// the change since the previous summary. It never returns.
+func ReportStats[T any](interval time.Duration, snapshot func() T, report func(previous T, current T)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	previous := snapshot()
+	for range ticker.C {
+		current := snapshot()
+		report(previous, current)
+		previous = current
+	}
+}
@@ -0,0 +1,51 @@
+package mockutil
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStatsGuardSnapshotsWholeUpdates(t *testing.T) {
+	var guard StatsGuard
+	var requests, bytes atomic.Int64
+
+	// Every update counts a request and its 10 bytes together
+	var updaters sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		updaters.Add(1)
+		go func() {
+			defer updaters.Done()
+			for j := 0; j < 1000; j++ {
+				guard.Update(func() {
+					requests.Add(1)
+					bytes.Add(10)
+				})
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		updaters.Wait()
+		close(done)
+	}()
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		var gotRequests, gotBytes int64
+		guard.Snapshot(func() {
+			gotRequests = requests.Load()
+			gotBytes = bytes.Load()
+		})
+		if gotBytes != gotRequests*10 {
+			t.Fatalf("expected the snapshot to see whole updates, got %d requests and %d bytes", gotRequests, gotBytes)
+		}
+	}
+	if got := requests.Load(); got != 8000 {
+		t.Fatalf("expected 8000 requests, got %d", got)
+	}
+}
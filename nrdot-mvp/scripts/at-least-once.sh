@@ -0,0 +1,69 @@
+#!/bin/bash
+# Verify at-least-once delivery through a backend outage.
+#
+# Runs the workload generator with sequence IDs against the collector, takes
+# nr-ingest down with the outage simulator part way through, then replays the
+# DLQ the collector wrote during the outage and checks that every sequence ID
+# the collector accepted eventually arrives at nr-ingest.
+
+set -e
+
+DURATION=${1:-120}
+OUTAGE_DURATION=${2:-30}
+DRAIN_TIMEOUT=${3:-180}
+SEQUENCE_FILE=data/sequences.json
+
+echo "Running at-least-once delivery harness..."
+echo "Workload: ${DURATION}s, outage: ${OUTAGE_DURATION}s, drain timeout: ${DRAIN_TIMEOUT}s"
+
+# Start the collector and nr-ingest with sequence verification
+mkdir -p data
+rm -f $SEQUENCE_FILE
+export COLLECTOR_CONFIG=./otel-config/at-least-once.yaml
+export VERIFY_SEQUENCES=true
+docker-compose up -d --build collector nr-ingest
+
+# Run the workload in the background
+docker-compose run --rm -e SEQUENCE_IDS=true -e SEQUENCE_FILE=/data/sequences.json \
+  -e SEND_TRACES=false -e SEND_LOGS=false -e DURATION=$DURATION \
+  workload-generator --profile=default &
+GENERATOR_PID=$!
+
+# Take nr-ingest down once the workload is under way
+sleep $((DURATION / 3))
+echo "Starting ${OUTAGE_DURATION}s outage of nr-ingest..."
+docker-compose run --rm -e TARGET_SERVICE=nr-ingest -e OUTAGE_TYPE=api \
+  -e TARGET_URL=http://nr-ingest:4317/outage \
+  outage-simulator --duration=$OUTAGE_DURATION
+
+wait $GENERATOR_PID
+
+if [ ! -f $SEQUENCE_FILE ]; then
+  echo "Error: The workload generator did not write $SEQUENCE_FILE"
+  exit 1
+fi
+
+# Replay the DLQ into nr-ingest. A replay that is still running answers 409;
+# once it has finished, the next request replays the DLQ again, so records
+# that failed to send are retried until they arrive.
+echo "Replaying the DLQ, waiting up to ${DRAIN_TIMEOUT}s for delivery..."
+DEADLINE=$((SECONDS + DRAIN_TIMEOUT))
+while true; do
+  curl -s -o /dev/null -X POST 'http://localhost:13140/replay?signal=metrics'
+
+  RESULT=$(curl -s -X POST --data-binary @$SEQUENCE_FILE http://localhost:4317/sequences)
+  MISSING=$(echo "$RESULT" | python3 -c 'import json,sys; print(len(json.load(sys.stdin)["missing"]))')
+
+  if [ "$MISSING" -eq 0 ]; then
+    echo "All accepted sequence IDs were delivered after replay: $RESULT" | cut -c1-200
+    exit 0
+  fi
+
+  if [ $SECONDS -ge $DEADLINE ]; then
+    echo "Error: $MISSING accepted sequence IDs never arrived"
+    echo "$RESULT" | cut -c1-500
+    exit 1
+  fi
+
+  sleep 5
+done
@@ -4,6 +4,9 @@ import (
 	"fmt"
 
 	"go.opentelemetry.io/collector/component"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/droplog"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/priority"
 )
 
 // DegradationLevel represents a degradation level with specific actions
@@ -19,6 +22,11 @@ type Triggers struct {
 	CPUUtilizationHigh    int `mapstructure:"cpu_utilization_high"`
 	LatencyP99High        int `mapstructure:"latency_p99_high"`
 	ErrorRateHigh         int `mapstructure:"error_rate_high"`
+
+	// InFlightMiBHigh is a ceiling on the telemetry held in memory by the
+	// priority queues and DLQ buffers, in MiB, independent of memory
+	// utilization (0 disables it)
+	InFlightMiBHigh int `mapstructure:"in_flight_mib_high"`
 }
 
 // Config defines the configuration for the AdaptiveDegradationManager processor.
@@ -34,6 +42,28 @@ type Config struct {
 
 	// How long to wait before reducing degradation level (in seconds)
 	CooldownPeriod int `mapstructure:"cooldown_period"`
+
+	// How long after startup conditions are observed without raising the
+	// degradation level, so cold-start GC churn doesn't trigger degradation
+	// (in seconds, 0 disables)
+	StartupGracePeriod int `mapstructure:"startup_grace_period"`
+
+	// Component ID of the adaptive_priority_queue processor whose downstream
+	// successes and errors drive the error rate trigger (empty disables it)
+	ErrorRateSource string `mapstructure:"error_rate_source"`
+
+	// Window over which the error rate is computed (in seconds)
+	ErrorRateWindow int `mapstructure:"error_rate_window"`
+
+	// Sampling rate and protected resources of the enable_sampling action
+	Sampling SamplingConfig `mapstructure:"sampling"`
+
+	// CriticalData keeps data classified critical by a resource attribute
+	// from being dropped or sampled, whatever the degradation level.
+	CriticalData priority.Config `mapstructure:"critical_data"`
+
+	// Sampled log of data dropped by degradation actions
+	DropLog droplog.Config `mapstructure:"drop_log"`
 }
 
 // Validate validates the processor configuration.
@@ -46,6 +76,28 @@ func (cfg *Config) Validate() error {
 		cfg.CooldownPeriod = 60
 	}
 
+	if cfg.StartupGracePeriod < 0 {
+		return fmt.Errorf("startup_grace_period must not be negative")
+	}
+
+	if cfg.ErrorRateWindow <= 0 {
+		cfg.ErrorRateWindow = 60
+	}
+
+	if cfg.Sampling.Rate == 0 {
+		cfg.Sampling.Rate = 0.5
+	} else if cfg.Sampling.Rate < 0 || cfg.Sampling.Rate > 1 {
+		return fmt.Errorf("sampling rate must be between 0 and 1")
+	}
+
+	if err := cfg.CriticalData.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.DropLog.Validate(); err != nil {
+		return err
+	}
+
 	// Ensure we have at least one degradation level
 	if len(cfg.Levels) == 0 {
 		return fmt.Errorf("at least one degradation level must be configured")
@@ -95,6 +147,10 @@ func (cfg *Config) Validate() error {
 		return fmt.Errorf("error_rate_high must be <= 100")
 	}
 
+	if cfg.Triggers.InFlightMiBHigh < 0 {
+		return fmt.Errorf("in_flight_mib_high must not be negative")
+	}
+
 	return nil
 }
 
@@ -122,7 +178,13 @@ func CreateDefaultConfig() component.Config {
 				Actions: []string{"drop_debug", "drop_metrics"},
 			},
 		},
-		CheckInterval:  5,
-		CooldownPeriod: 60,
+		CheckInterval:      5,
+		CooldownPeriod:     60,
+		StartupGracePeriod: 30,
+		ErrorRateSource:    "adaptive_priority_queue",
+		ErrorRateWindow:    60,
+		Sampling:           SamplingConfig{Rate: 0.5},
+		CriticalData:       priority.DefaultConfig(),
+		DropLog:            droplog.DefaultConfig(),
 	}
 }
@@ -0,0 +1,67 @@
+package adaptivedegradationmanager
+
+import (
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/health"
+)
+
+// outcomeSample is a snapshot of a source's cumulative outcomes.
+type outcomeSample struct {
+	at        time.Time
+	successes int64
+	errors    int64
+}
+
+// errorRateProbe computes the downstream error rate over a rolling window
+// from the cumulative outcomes a plugin publishes to the health registry.
+type errorRateProbe struct {
+	source  string
+	window  time.Duration
+	clock   clock.Clock
+	samples []outcomeSample
+}
+
+// newErrorRateProbe creates a probe for the outcomes published under source.
+func newErrorRateProbe(source string, window time.Duration, clk clock.Clock) *errorRateProbe {
+	return &errorRateProbe{
+		source: source,
+		window: window,
+		clock:  clk,
+	}
+}
+
+// sample takes a snapshot of the source's outcomes and returns the
+// percentage of sends that failed within the window. It returns 0 until the
+// source is registered or while there have been no sends in the window.
+func (e *errorRateProbe) sample() float64 {
+	successes, errors, ok := health.Outcomes(e.source)
+	if !ok {
+		e.samples = nil
+		return 0
+	}
+
+	now := e.clock.Now()
+	e.samples = append(e.samples, outcomeSample{at: now, successes: successes, errors: errors})
+
+	// Keep the newest sample at or before the start of the window as the baseline
+	cutoff := now.Add(-e.window)
+	for len(e.samples) > 1 && !e.samples[1].at.After(cutoff) {
+		e.samples = e.samples[1:]
+	}
+
+	baseline := e.samples[0]
+	deltaErrors := errors - baseline.errors
+	deltaTotal := successes + errors - baseline.successes - baseline.errors
+
+	// Counts went backwards, such as when a source was replaced
+	if deltaErrors < 0 || deltaTotal < 0 {
+		e.samples = e.samples[len(e.samples)-1:]
+		return 0
+	}
+	if deltaTotal == 0 {
+		return 0
+	}
+	return float64(deltaErrors) / float64(deltaTotal) * 100
+}
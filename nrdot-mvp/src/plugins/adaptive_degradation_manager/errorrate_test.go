@@ -0,0 +1,52 @@
+package adaptivedegradationmanager
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	adaptivepriorityqueue "github.com/yourusername/nrdot-mvp/src/plugins/adaptive_priority_queue"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/health"
+)
+
+func TestQueueErrorsTriggerDegradation(t *testing.T) {
+	p, _, fake := newTestProcessor(t, func(config *Config) {
+		config.ErrorRateSource = "adaptive_priority_queue/errors"
+		config.Triggers.ErrorRateHigh = 10
+	})
+	fake.Advance(time.Duration(p.config.StartupGracePeriod) * time.Second)
+
+	queueConfig := adaptivepriorityqueue.CreateDefaultConfig().(*adaptivepriorityqueue.Config)
+	queue := adaptivepriorityqueue.NewAdaptivePriorityQueue(zap.NewNop(), queueConfig, nil)
+	unregister := health.Register("adaptive_priority_queue/errors", queue)
+	defer unregister()
+
+	// Sends before the window opens don't count
+	queue.RecordError()
+	p.errorRate = p.errorRateProbe.sample()
+
+	for i := 0; i < 19; i++ {
+		queue.RecordSuccess()
+	}
+	fake.Advance(5 * time.Second)
+	p.errorRate = p.errorRateProbe.sample()
+	p.assessDegradationLevel()
+	if level := p.currentLevel.Load(); level != 0 {
+		t.Fatalf("expected no degradation while every send succeeds, got level %d at an error rate of %v", level, p.errorRate)
+	}
+
+	// 5 failures out of 24 sends in the window
+	for i := 0; i < 5; i++ {
+		queue.RecordError()
+	}
+	fake.Advance(5 * time.Second)
+	p.errorRate = p.errorRateProbe.sample()
+	if p.errorRate < 20 || p.errorRate > 21 {
+		t.Fatalf("expected an error rate of about 20.8%%, got %v", p.errorRate)
+	}
+	p.assessDegradationLevel()
+	if level := p.currentLevel.Load(); level != 1 {
+		t.Fatalf("expected the error rate trigger to raise the level to 1, got %d", level)
+	}
+}
@@ -5,7 +5,7 @@ import (
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
-	"go.opentelemetry.io/collector/processor"
+	otelprocessor "go.opentelemetry.io/collector/processor"
 )
 
 const (
@@ -14,45 +14,45 @@ const (
 )
 
 // NewFactory creates a new factory for the AdaptiveDegradationManager processor.
-func NewFactory() processor.Factory {
-	return processor.NewFactory(
+func NewFactory() otelprocessor.Factory {
+	return otelprocessor.NewFactory(
 		typeStr,
 		CreateDefaultConfig,
-		processor.WithMetrics(createMetricsProcessor, component.StabilityLevelAlpha),
-		processor.WithTraces(createTracesProcessor, component.StabilityLevelAlpha),
-		processor.WithLogs(createLogsProcessor, component.StabilityLevelAlpha),
+		otelprocessor.WithMetrics(createMetricsProcessor, component.StabilityLevelAlpha),
+		otelprocessor.WithTraces(createTracesProcessor, component.StabilityLevelAlpha),
+		otelprocessor.WithLogs(createLogsProcessor, component.StabilityLevelAlpha),
 	)
 }
 
 // createMetricsProcessor creates a new metrics processor based on the config.
 func createMetricsProcessor(
 	ctx context.Context,
-	set processor.CreateSettings,
+	set otelprocessor.CreateSettings,
 	cfg component.Config,
 	nextConsumer consumer.Metrics,
-) (processor.Metrics, error) {
+) (otelprocessor.Metrics, error) {
 	processorConfig := cfg.(*Config)
-	return newProcessor(set.Logger, processorConfig, nextConsumer)
+	return newProcessor(set.Logger, processorConfig, set.ID, nextConsumer)
 }
 
 // createTracesProcessor creates a new traces processor based on the config.
 func createTracesProcessor(
 	ctx context.Context,
-	set processor.CreateSettings,
+	set otelprocessor.CreateSettings,
 	cfg component.Config,
 	nextConsumer consumer.Traces,
-) (processor.Traces, error) {
+) (otelprocessor.Traces, error) {
 	processorConfig := cfg.(*Config)
-	return newProcessor(set.Logger, processorConfig, nextConsumer)
+	return newProcessor(set.Logger, processorConfig, set.ID, nextConsumer)
 }
 
 // createLogsProcessor creates a new logs processor based on the config.
 func createLogsProcessor(
 	ctx context.Context,
-	set processor.CreateSettings,
+	set otelprocessor.CreateSettings,
 	cfg component.Config,
 	nextConsumer consumer.Logs,
-) (processor.Logs, error) {
+) (otelprocessor.Logs, error) {
 	processorConfig := cfg.(*Config)
-	return newProcessor(set.Logger, processorConfig, nextConsumer)
+	return newProcessor(set.Logger, processorConfig, set.ID, nextConsumer)
 }
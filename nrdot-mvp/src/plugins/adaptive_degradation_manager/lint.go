@@ -0,0 +1,134 @@
+package adaptivedegradationmanager
+
+import (
+	"fmt"
+)
+
+// maxAssessedLevel is the highest level the triggers can raise degradation
+// to.
+const maxAssessedLevel = 3
+
+// actionSeverity ranks the actions by how much data they give up, to check
+// that higher levels degrade at least as much as lower ones.
+var actionSeverity = map[string]int{
+	"inc_batch":       1,
+	"stretch_scrape":  1,
+	"enable_sampling": 2,
+	"drop_debug":      3,
+	"drop_metrics":    3,
+}
+
+// Lint returns warnings about settings that are valid but probably not what
+// was intended, such as a level that degrades less than the one below it or
+// a trigger that can never fire. It expects a configuration that passed
+// Validate.
+func (cfg *Config) Lint() []string {
+	var warnings []string
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	// Levels are applied by position, so their IDs should match it
+	for i, level := range cfg.Levels {
+		if level.ID != i+1 {
+			warn("level at position %d has id %d, but is applied as level %d", i+1, level.ID, i+1)
+		}
+		if len(level.Actions) == 0 {
+			warn("level %d has no actions", i+1)
+		}
+	}
+
+	if len(cfg.Levels) < maxAssessedLevel {
+		warn("only %d levels are configured, so degradation levels %d to %d take no action",
+			len(cfg.Levels), len(cfg.Levels)+1, maxAssessedLevel)
+	}
+	if len(cfg.Levels) > maxAssessedLevel {
+		warn("levels above %d are never reached", maxAssessedLevel)
+	}
+
+	// Each level replaces the actions of the one below, so it should be at
+	// least as severe and not a strict subset of it
+	for i := 1; i < len(cfg.Levels); i++ {
+		lower, higher := cfg.Levels[i-1].Actions, cfg.Levels[i].Actions
+		if maxSeverity(higher) < maxSeverity(lower) {
+			warn("level %d is less aggressive than level %d", i+1, i)
+		} else if isStrictSubset(higher, lower) {
+			warn("level %d only takes some of the actions of level %d", i+1, i)
+		}
+	}
+
+	// Memory and queue utilization at or above 90% go straight to level 3,
+	// and at or above 80% to level 2
+	for _, trigger := range []struct {
+		name  string
+		value int
+	}{
+		{"memory_utilization_high", cfg.Triggers.MemoryUtilizationHigh},
+		{"queue_utilization_high", cfg.Triggers.QueueUtilizationHigh},
+	} {
+		if trigger.value >= 90 {
+			warn("%s is %d, so it skips levels 1 and 2", trigger.name, trigger.value)
+		} else if trigger.value >= 80 {
+			warn("%s is %d, so it skips level 1", trigger.name, trigger.value)
+		}
+	}
+
+	if cfg.Triggers.CPUUtilizationHigh > 100 {
+		warn("cpu_utilization_high is %d, so it can never fire", cfg.Triggers.CPUUtilizationHigh)
+	}
+
+	if cfg.ErrorRateSource == "" {
+		warn("error_rate_source is empty, so error_rate_high can never fire")
+	}
+
+	if cfg.CooldownPeriod < cfg.CheckInterval {
+		warn("cooldown_period (%ds) is shorter than check_interval (%ds), so levels can drop on every check",
+			cfg.CooldownPeriod, cfg.CheckInterval)
+	}
+
+	if cfg.Sampling.Rate >= 1 && usesAction(cfg.Levels, "enable_sampling") {
+		warn("sampling rate is 1, so enable_sampling keeps all data")
+	}
+
+	return warnings
+}
+
+// maxSeverity returns the severity of the most severe action.
+func maxSeverity(actions []string) int {
+	severity := 0
+	for _, action := range actions {
+		if actionSeverity[action] > severity {
+			severity = actionSeverity[action]
+		}
+	}
+	return severity
+}
+
+// isStrictSubset returns whether every action in a is also in b, and b has
+// more.
+func isStrictSubset(a []string, b []string) bool {
+	inB := make(map[string]bool, len(b))
+	for _, action := range b {
+		inB[action] = true
+	}
+	inA := make(map[string]bool, len(a))
+	for _, action := range a {
+		if !inB[action] {
+			return false
+		}
+		inA[action] = true
+	}
+	return len(inA) < len(inB)
+}
+
+// usesAction returns whether any level takes the action.
+func usesAction(levels []DegradationLevel, action string) bool {
+	for _, level := range levels {
+		for _, a := range level.Actions {
+			if a == action {
+				return true
+			}
+		}
+	}
+	return false
+}
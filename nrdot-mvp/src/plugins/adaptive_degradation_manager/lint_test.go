@@ -0,0 +1,109 @@
+package adaptivedegradationmanager
+
+import (
+	"testing"
+)
+
+func TestDefaultConfigLintsClean(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid default config: %v", err)
+	}
+	if warnings := config.Lint(); len(warnings) != 0 {
+		t.Fatalf("expected no warnings for the default config, got %v", warnings)
+	}
+}
+
+func TestLintWarnsAboutMistakenLevels(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		configure func(*Config)
+		warnings  []string
+	}{
+		{
+			name: "non-monotonic levels",
+			configure: func(config *Config) {
+				config.Levels[1].Actions = []string{"drop_debug"}
+				config.Levels[2].Actions = []string{"enable_sampling"}
+			},
+			warnings: []string{"level 3 is less aggressive than level 2"},
+		},
+		{
+			name: "higher level takes fewer actions",
+			configure: func(config *Config) {
+				config.Levels[1].Actions = []string{"enable_sampling", "drop_debug"}
+				config.Levels[2].Actions = []string{"drop_debug"}
+			},
+			warnings: []string{"level 3 only takes some of the actions of level 2"},
+		},
+		{
+			name: "ids out of order",
+			configure: func(config *Config) {
+				config.Levels[0].ID, config.Levels[1].ID = 2, 1
+			},
+			warnings: []string{
+				"level at position 1 has id 2, but is applied as level 1",
+				"level at position 2 has id 1, but is applied as level 2",
+			},
+		},
+		{
+			name: "unreachable level",
+			configure: func(config *Config) {
+				config.Levels = append(config.Levels, DegradationLevel{ID: 4, Actions: []string{"drop_debug", "drop_metrics"}})
+			},
+			warnings: []string{"levels above 3 are never reached"},
+		},
+		{
+			name: "triggers skipping levels",
+			configure: func(config *Config) {
+				config.Triggers.MemoryUtilizationHigh = 95
+				config.Triggers.QueueUtilizationHigh = 85
+				config.Triggers.CPUUtilizationHigh = 120
+			},
+			warnings: []string{
+				"memory_utilization_high is 95, so it skips levels 1 and 2",
+				"queue_utilization_high is 85, so it skips level 1",
+				"cpu_utilization_high is 120, so it can never fire",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := CreateDefaultConfig().(*Config)
+			tc.configure(config)
+			warnings := config.Lint()
+
+			// Only the expected warnings are produced, not the others too
+			found := 0
+			for _, want := range tc.warnings {
+				for _, warning := range warnings {
+					if warning == want {
+						found++
+						break
+					}
+				}
+			}
+			if found != len(tc.warnings) || len(warnings) != len(tc.warnings) {
+				t.Fatalf("expected warnings %q, got %q", tc.warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestIsStrictSubset(t *testing.T) {
+	for _, tc := range []struct {
+		a, b []string
+		want bool
+	}{
+		{a: []string{"drop_debug"}, b: []string{"drop_debug", "drop_metrics"}, want: true},
+		{a: []string{"drop_debug", "drop_metrics"}, b: []string{"drop_metrics", "drop_debug"}, want: false},
+		{a: []string{"enable_sampling"}, b: []string{"drop_debug", "drop_metrics"}, want: false},
+		{a: nil, b: []string{"drop_debug"}, want: true},
+	} {
+		if got := isStrictSubset(tc.a, tc.b); got != tc.want {
+			t.Errorf("isStrictSubset(%v, %v) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+	if got := maxSeverity([]string{"inc_batch", "drop_metrics"}); got != 3 {
+		t.Errorf("expected the most severe action to set the severity, got %d", got)
+	}
+}
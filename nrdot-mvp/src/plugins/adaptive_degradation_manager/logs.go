@@ -1,52 +0,0 @@
-package adaptivedegradationmanager
-
-import (
-	"context"
-
-	"go.opentelemetry.io/collector/component"
-	"go.opentelemetry.io/collector/consumer"
-	"go.opentelemetry.io/collector/pdata/plog"
-	"go.uber.org/zap"
-)
-
-// logsProcessor is the processor for implementing adaptive degradation for logs.
-type logsProcessor struct {
-	logger       *zap.Logger
-	config       *Config
-	nextConsumer consumer.Logs
-	// This would share the same degradation manager as the metrics processor
-	// to ensure consistent degradation levels across signals
-	metricsProcessor *metricsProcessor
-}
-
-// newLogsProcessor creates a new logs processor.
-func newLogsProcessor(logger *zap.Logger, config *Config, nextConsumer consumer.Logs) (*logsProcessor, error) {
-	return &logsProcessor{
-		logger:       logger,
-		config:       config,
-		nextConsumer: nextConsumer,
-	}, nil
-}
-
-// ConsumeLogs implements the logs consumer interface.
-func (p *logsProcessor) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
-	// In a full implementation, this would apply the same degradation actions
-	// as the metrics processor, but for logs.
-	// For simplicity, we just pass through the logs.
-	return p.nextConsumer.ConsumeLogs(ctx, ld)
-}
-
-// Capabilities returns the consumer capabilities.
-func (p *logsProcessor) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{MutatesData: true}
-}
-
-// Start starts the processor.
-func (p *logsProcessor) Start(ctx context.Context, host component.Host) error {
-	return nil
-}
-
-// Shutdown stops the processor.
-func (p *logsProcessor) Shutdown(ctx context.Context) error {
-	return nil
-}
@@ -1,308 +0,0 @@
-package adaptivedegradationmanager
-
-import (
-	"context"
-	"runtime"
-	"sync"
-	"time"
-
-	"github.com/prometheus/client_golang/prometheus"
-	"go.opentelemetry.io/collector/component"
-	"go.opentelemetry.io/collector/consumer"
-	"go.opentelemetry.io/collector/pdata/pmetric"
-	"go.uber.org/zap"
-)
-
-// metricsProcessor is the processor for implementing adaptive degradation for metrics.
-type metricsProcessor struct {
-	logger           *zap.Logger
-	config           *Config
-	nextConsumer     consumer.Metrics
-	degradationMgr   *DegradationManager
-	samplingRate     float64
-	batchSize        int
-	scrapeInterval   time.Duration
-	dropNonCritical  bool
-	actionMutex      sync.RWMutex
-	
-	// Metrics
-	registry            *prometheus.Registry
-	processedMetrics    prometheus.Counter
-	droppedMetrics      prometheus.Counter
-	processingTime      prometheus.Histogram
-	samplingRateGauge   prometheus.Gauge
-	batchSizeGauge      prometheus.Gauge
-	scrapeIntervalGauge prometheus.Gauge
-}
-
-// newMetricsProcessor creates a new metrics processor.
-func newMetricsProcessor(logger *zap.Logger, config *Config, nextConsumer consumer.Metrics) (*metricsProcessor, error) {
-	registry := prometheus.NewRegistry()
-
-	// Create metrics
-	processedMetrics := prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "adaptive_degradation_processed_metrics_total",
-		Help: "Total number of metrics processed",
-	})
-	
-	droppedMetrics := prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "adaptive_degradation_dropped_metrics_total",
-		Help: "Total number of metrics dropped",
-	})
-	
-	processingTime := prometheus.NewHistogram(prometheus.HistogramOpts{
-		Name:    "adaptive_degradation_processing_time_seconds",
-		Help:    "Time taken to process metrics",
-		Buckets: prometheus.DefBuckets,
-	})
-	
-	samplingRateGauge := prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "adaptive_degradation_sampling_rate",
-		Help: "Current sampling rate",
-	})
-	
-	batchSizeGauge := prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "adaptive_degradation_batch_size",
-		Help: "Current batch size",
-	})
-	
-	scrapeIntervalGauge := prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "adaptive_degradation_scrape_interval_seconds",
-		Help: "Current scrape interval in seconds",
-	})
-	
-	// Register metrics
-	registry.MustRegister(processedMetrics)
-	registry.MustRegister(droppedMetrics)
-	registry.MustRegister(processingTime)
-	registry.MustRegister(samplingRateGauge)
-	registry.MustRegister(batchSizeGauge)
-	registry.MustRegister(scrapeIntervalGauge)
-	
-	// Create processor
-	processor := &metricsProcessor{
-		logger:              logger,
-		config:              config,
-		nextConsumer:        nextConsumer,
-		samplingRate:        1.0, // No sampling by default
-		batchSize:           1000, // Default batch size
-		scrapeInterval:      60 * time.Second, // Default scrape interval
-		dropNonCritical:     false, // Don't drop non-critical metrics by default
-		registry:            registry,
-		processedMetrics:    processedMetrics,
-		droppedMetrics:      droppedMetrics,
-		processingTime:      processingTime,
-		samplingRateGauge:   samplingRateGauge,
-		batchSizeGauge:      batchSizeGauge,
-		scrapeIntervalGauge: scrapeIntervalGauge,
-	}
-	
-	// Set initial gauge values
-	samplingRateGauge.Set(1.0)
-	batchSizeGauge.Set(1000)
-	scrapeIntervalGauge.Set(60)
-	
-	// Create resource monitor
-	resourceMonitor := &metricsResourceMonitor{
-		processor: processor,
-	}
-	
-	// Create action handler
-	actionHandler := &metricsActionHandler{
-		processor: processor,
-	}
-	
-	// Create degradation manager
-	processor.degradationMgr = NewDegradationManager(
-		logger,
-		config,
-		actionHandler,
-		resourceMonitor,
-	)
-	
-	// Register degradation manager metrics
-	processor.degradationMgr.RegisterMetrics(registry)
-	
-	// Start monitoring goroutine
-	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		defer ticker.Stop()
-		
-		for range ticker.C {
-			processor.degradationMgr.CheckResourceUsage()
-		}
-	}()
-	
-	return processor, nil
-}
-
-// ConsumeMetrics implements the metrics consumer interface.
-func (p *metricsProcessor) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
-	startTime := time.Now()
-	
-	// Get current degradation parameters
-	p.actionMutex.RLock()
-	samplingRate := p.samplingRate
-	dropNonCritical := p.dropNonCritical
-	p.actionMutex.RUnlock()
-	
-	// Apply sampling (if enabled)
-	if samplingRate < 1.0 {
-		md = p.applySampling(md, samplingRate)
-	}
-	
-	// Apply drop non-critical (if enabled)
-	if dropNonCritical {
-		md = p.dropNonCriticalMetrics(md)
-	}
-	
-	// Record processing time
-	p.processingTime.Observe(time.Since(startTime).Seconds())
-	
-	// Forward to the next consumer
-	return p.nextConsumer.ConsumeMetrics(ctx, md)
-}
-
-// applySampling applies sampling to metrics based on the current sampling rate.
-func (p *metricsProcessor) applySampling(md pmetric.Metrics, rate float64) pmetric.Metrics {
-	// Implementation would reduce the number of metrics by the sampling rate
-	// This is a placeholder for the actual implementation
-	p.logger.Debug("Applying sampling", zap.Float64("rate", rate))
-	
-	// In a real implementation, we would randomly sample metrics
-	// For now, just record that we received metrics
-	p.processedMetrics.Add(float64(md.MetricCount()))
-	
-	return md
-}
-
-// dropNonCriticalMetrics drops non-critical metrics.
-func (p *metricsProcessor) dropNonCriticalMetrics(md pmetric.Metrics) pmetric.Metrics {
-	// Implementation would drop non-critical metrics
-	// This is a placeholder for the actual implementation
-	p.logger.Debug("Dropping non-critical metrics")
-	
-	// In a real implementation, we would filter metrics based on criteria
-	// For now, just record that we received metrics
-	p.processedMetrics.Add(float64(md.MetricCount()))
-	
-	return md
-}
-
-// metricsResourceMonitor implements the ResourceMonitor interface.
-type metricsResourceMonitor struct {
-	processor *metricsProcessor
-}
-
-// GetMemoryUtilization returns the current memory utilization.
-func (m *metricsResourceMonitor) GetMemoryUtilization() float64 {
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
-	
-	// Calculate memory utilization as a percentage of total available memory
-	// This is a simplified calculation
-	memoryUtilization := float64(memStats.Alloc) / float64(memStats.Sys) * 100
-	
-	return memoryUtilization
-}
-
-// GetQueueUtilization returns the current queue utilization.
-func (m *metricsResourceMonitor) GetQueueUtilization() float64 {
-	// In a real implementation, this would get the queue utilization from the exporter
-	// This is a placeholder that returns a fixed value
-	return 50.0
-}
-
-// GetCPUUtilization returns the current CPU utilization.
-func (m *metricsResourceMonitor) GetCPUUtilization() float64 {
-	// In a real implementation, this would get the CPU utilization
-	// This is a placeholder that returns a fixed value
-	return 40.0
-}
-
-// GetErrorRate returns the current error rate.
-func (m *metricsResourceMonitor) GetErrorRate() float64 {
-	// In a real implementation, this would calculate the error rate
-	// This is a placeholder that returns a fixed value
-	return 1.0
-}
-
-// metricsActionHandler implements the ActionHandler interface.
-type metricsActionHandler struct {
-	processor *metricsProcessor
-}
-
-// ApplyAction applies a degradation action.
-func (h *metricsActionHandler) ApplyAction(action string) error {
-	h.processor.logger.Info("Applying action", zap.String("action", action))
-	
-	h.processor.actionMutex.Lock()
-	defer h.processor.actionMutex.Unlock()
-	
-	switch action {
-	case "inc_batch":
-		// Increase batch size
-		h.processor.batchSize = 2000
-		h.processor.batchSizeGauge.Set(2000)
-	case "stretch_scrape":
-		// Increase scrape interval
-		h.processor.scrapeInterval = 120 * time.Second
-		h.processor.scrapeIntervalGauge.Set(120)
-	case "enable_sampling_0.5":
-		// Enable 50% sampling
-		h.processor.samplingRate = 0.5
-		h.processor.samplingRateGauge.Set(0.5)
-	case "enable_sampling_0.1":
-		// Enable 10% sampling
-		h.processor.samplingRate = 0.1
-		h.processor.samplingRateGauge.Set(0.1)
-	case "drop_noncritical":
-		// Drop non-critical metrics
-		h.processor.dropNonCritical = true
-	}
-	
-	return nil
-}
-
-// ResetAction resets a degradation action.
-func (h *metricsActionHandler) ResetAction(action string) error {
-	h.processor.logger.Info("Resetting action", zap.String("action", action))
-	
-	h.processor.actionMutex.Lock()
-	defer h.processor.actionMutex.Unlock()
-	
-	switch action {
-	case "inc_batch":
-		// Reset batch size
-		h.processor.batchSize = 1000
-		h.processor.batchSizeGauge.Set(1000)
-	case "stretch_scrape":
-		// Reset scrape interval
-		h.processor.scrapeInterval = 60 * time.Second
-		h.processor.scrapeIntervalGauge.Set(60)
-	case "enable_sampling_0.5", "enable_sampling_0.1":
-		// Disable sampling
-		h.processor.samplingRate = 1.0
-		h.processor.samplingRateGauge.Set(1.0)
-	case "drop_noncritical":
-		// Stop dropping non-critical metrics
-		h.processor.dropNonCritical = false
-	}
-	
-	return nil
-}
-
-// Capabilities returns the consumer capabilities.
-func (p *metricsProcessor) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{MutatesData: true}
-}
-
-// Start starts the processor.
-func (p *metricsProcessor) Start(ctx context.Context, host component.Host) error {
-	return nil
-}
-
-// Shutdown stops the processor.
-func (p *metricsProcessor) Shutdown(ctx context.Context) error {
-	return nil
-}
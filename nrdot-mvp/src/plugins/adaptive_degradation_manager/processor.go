@@ -3,13 +3,12 @@ package adaptivedegradationmanager
 import (
 	"context"
 	"math/rand"
-	"runtime"
+	"strconv"
 	"sync"
 	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
-	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/ptrace"
@@ -17,126 +16,210 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/droplog"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/health"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/sysmon"
 )
 
 // processor implements the AdaptiveDegradationManager processor.
 type processor struct {
-	logger            *zap.Logger
-	config            *Config
-	metricsConsumer   consumer.Metrics
-	tracesConsumer    consumer.Traces
-	logsConsumer      consumer.Logs
-	
+	logger          *zap.Logger
+	config          *Config
+	clock           clock.Clock
+	metricsConsumer consumer.Metrics
+	tracesConsumer  consumer.Traces
+	logsConsumer    consumer.Logs
+
 	// State
-	currentLevel      *atomic.Int32
-	lastLevelChange   time.Time
-	stateMutex        sync.RWMutex
-	
+	currentLevel    *atomic.Int32
+	lastLevelChange time.Time
+	startedAt       time.Time
+
+	// Time spent at each level, accrued up to levelAccountedAt
+	levelDurations   map[int]time.Duration
+	levelAccountedAt time.Time
+	stateMutex       sync.RWMutex
+
 	// Metrics
 	memoryUtilization float64
 	queueUtilization  float64
 	cpuUtilization    float64
 	errorRate         float64
 	latencyP99        float64
-	
-	// Action state
-	sampleRate        float64
-	batchMultiplier   int
-	scrapeMultiplier  int
-	dropDebug         bool
-	dropMetrics       bool
-	
+	inFlightBytes     int64
+
+	// Source of inFlightBytes, the registered in-flight sources by default
+	inFlight health.InFlightSource
+
+	// Computes errorRate from the priority queue's outcomes, nil if disabled
+	errorRateProbe *errorRateProbe
+
+	// Action state of the current level, replaced whole on a level change so
+	// consumers never see part of one level's state and part of another's
+	actions *atomic.Pointer[actionState]
+
 	// Prometheus metrics
-	levelGauge        prometheus.Gauge
-	actionsCounter    *prometheus.CounterVec
-	droppedCounter    *prometheus.CounterVec
-	stateGauge        *prometheus.GaugeVec
-	
+	levelGauge       prometheus.Gauge
+	actionsCounter   *prometheus.CounterVec
+	droppedCounter   *prometheus.CounterVec
+	stateGauge       *prometheus.GaugeVec
+	seenCounter      *prometheus.CounterVec
+	forwardedCounter *prometheus.CounterVec
+	levelSeconds     *prometheus.CounterVec
+	metrics          *promreg.Registry
+
 	// Metrics poller
-	cancelPoller      context.CancelFunc
+	cancelPoller context.CancelFunc
+
+	// Sampled log of dropped data, nil if disabled
+	dropLog *droplog.Logger
+
+	// Source of sampling decisions, guarded by rngMutex since *rand.Rand
+	// isn't safe for concurrent use
+	rng      *rand.Rand
+	rngMutex sync.Mutex
 }
 
 // newProcessor creates a new AdaptiveDegradationManager processor.
 func newProcessor(
 	logger *zap.Logger,
 	config *Config,
+	id component.ID,
 	nextConsumer interface{},
 ) (*processor, error) {
+	realClock := clock.Real()
 	p := &processor{
-		logger:          logger,
-		config:          config,
-		currentLevel:    atomic.NewInt32(0),
-		lastLevelChange: time.Now(),
-		sampleRate:      1.0,
-		batchMultiplier: 1,
-		scrapeMultiplier: 1,
-		dropDebug:       false,
-		dropMetrics:     false,
+		logger:           logger,
+		config:           config,
+		clock:            realClock,
+		currentLevel:     atomic.NewInt32(0),
+		lastLevelChange:  realClock.Now(),
+		levelDurations:   make(map[int]time.Duration),
+		levelAccountedAt: realClock.Now(),
+		actions:          atomic.NewPointer(newActionState(nil, config.Sampling.Rate)),
+		dropLog:          droplog.New(logger, typeStr, config.DropLog),
+		rng:              rand.New(rand.NewSource(realClock.Now().UnixNano())),
+		inFlight:         health.TotalInFlight(),
 	}
-	
+
+	if config.ErrorRateSource != "" {
+		window := time.Duration(config.ErrorRateWindow) * time.Second
+		p.errorRateProbe = newErrorRateProbe(config.ErrorRateSource, window, realClock)
+	}
+
 	// Set the appropriate consumer based on the type
+	var signal string
 	switch c := nextConsumer.(type) {
 	case consumer.Metrics:
 		p.metricsConsumer = c
+		signal = "metrics"
 	case consumer.Traces:
 		p.tracesConsumer = c
+		signal = "traces"
 	case consumer.Logs:
 		p.logsConsumer = c
+		signal = "logs"
 	}
-	
+
 	// Initialize Prometheus metrics
+	p.metrics = promreg.New(logger, prometheus.Labels{"processor": id.String(), "signal": signal})
 	p.initMetrics()
-	
+
 	return p, nil
 }
 
-// initMetrics initializes Prometheus metrics.
+// SetClock replaces the clock used for level changes, the cooldown and
+// startup grace periods, the error rate window and time-in-level
+// accounting. It must be called before Start.
+func (p *processor) SetClock(c clock.Clock) {
+	p.stateMutex.Lock()
+	defer p.stateMutex.Unlock()
+	p.clock = c
+	if p.errorRateProbe != nil {
+		p.errorRateProbe.clock = c
+	}
+	p.lastLevelChange = c.Now()
+	p.levelAccountedAt = c.Now()
+}
+
+// SetRand replaces the random number generator behind sampling decisions, so
+// tests can seed it and get reproducible results.
+func (p *processor) SetRand(rng *rand.Rand) {
+	p.rngMutex.Lock()
+	defer p.rngMutex.Unlock()
+	p.rng = rng
+}
+
+// randFloat64 returns the next sampling draw in [0.0, 1.0).
+func (p *processor) randFloat64() float64 {
+	p.rngMutex.Lock()
+	defer p.rngMutex.Unlock()
+	return p.rng.Float64()
+}
+
+// SetInFlightSource replaces the source of the in-flight bytes checked
+// against the in_flight_mib_high trigger. It must be called before Start.
+func (p *processor) SetInFlightSource(source health.InFlightSource) {
+	p.inFlight = source
+}
+
+// initMetrics initializes Prometheus metrics, labelled with the processor's
+// component ID and signal.
 func (p *processor) initMetrics() {
-	p.levelGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	p.levelGauge = p.metrics.Gauge(prometheus.GaugeOpts{
 		Name: "otelcol_adm_current_level",
 		Help: "Current adaptive degradation level (0 = normal, higher = more degraded)",
 	})
-	
-	p.actionsCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "otelcol_adm_actions_total",
-			Help: "Count of adaptive degradation actions taken",
-		},
-		[]string{"action"},
-	)
-	
-	p.droppedCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "otelcol_adm_dropped_total",
-			Help: "Count of items dropped due to adaptive degradation",
-		},
-		[]string{"telemetry_type"},
-	)
-	
-	p.stateGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "otelcol_adm_state",
-			Help: "Current state values monitored by adaptive degradation manager",
-		},
-		[]string{"metric"},
-	)
-	
-	// Register metrics
-	registry := prometheus.DefaultRegisterer
-	registry.MustRegister(p.levelGauge)
-	registry.MustRegister(p.actionsCounter)
-	registry.MustRegister(p.droppedCounter)
-	registry.MustRegister(p.stateGauge)
+
+	p.actionsCounter = p.metrics.CounterVec(prometheus.CounterOpts{
+		Name: "otelcol_adm_actions_total",
+		Help: "Count of adaptive degradation actions taken",
+	}, "action")
+
+	p.droppedCounter = p.metrics.CounterVec(prometheus.CounterOpts{
+		Name: "otelcol_adm_dropped_total",
+		Help: "Count of items dropped due to adaptive degradation",
+	}, "telemetry_type")
+
+	p.stateGauge = p.metrics.GaugeVec(prometheus.GaugeOpts{
+		Name: "otelcol_adm_state",
+		Help: "Current state values monitored by adaptive degradation manager",
+	}, "metric")
+
+	// Items seen and forwarded per signal; the achieved sample rate is
+	// forwarded / seen
+	p.seenCounter = p.metrics.CounterVec(prometheus.CounterOpts{
+		Name: "otelcol_adm_items_seen_total",
+		Help: "Count of data points, spans and log records received by the adaptive degradation manager",
+	}, "telemetry_type")
+
+	p.forwardedCounter = p.metrics.CounterVec(prometheus.CounterOpts{
+		Name: "otelcol_adm_items_forwarded_total",
+		Help: "Count of data points, spans and log records forwarded by the adaptive degradation manager",
+	}, "telemetry_type")
+
+	p.levelSeconds = p.metrics.CounterVec(prometheus.CounterOpts{
+		Name: "otelcol_adm_level_seconds_total",
+		Help: "Cumulative time spent at each adaptive degradation level, in seconds",
+	}, "level")
 }
 
 // Start starts the processor, including metrics collection.
 func (p *processor) Start(ctx context.Context, host component.Host) error {
 	ctx, cancel := context.WithCancel(ctx)
 	p.cancelPoller = cancel
-	
+
+	p.stateMutex.Lock()
+	p.startedAt = p.clock.Now()
+	p.levelAccountedAt = p.startedAt
+	p.stateMutex.Unlock()
+
 	// Start a goroutine to poll metrics and update degradation level
 	go p.pollMetrics(ctx)
-	
+
 	return nil
 }
 
@@ -145,6 +228,7 @@ func (p *processor) Shutdown(ctx context.Context) error {
 	if p.cancelPoller != nil {
 		p.cancelPoller()
 	}
+	p.metrics.Unregister()
 	return nil
 }
 
@@ -152,7 +236,7 @@ func (p *processor) Shutdown(ctx context.Context) error {
 func (p *processor) pollMetrics(ctx context.Context) {
 	ticker := time.NewTicker(time.Duration(p.config.CheckInterval) * time.Second)
 	defer ticker.Stop()
-	
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -166,52 +250,75 @@ func (p *processor) pollMetrics(ctx context.Context) {
 
 // updateMetrics updates the current metrics.
 func (p *processor) updateMetrics() {
-	// Get memory stats
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
-	
-	totalMemory := float64(memStats.Sys)
-	usedMemory := float64(memStats.HeapInuse + memStats.StackInuse)
-	p.memoryUtilization = (usedMemory / totalMemory) * 100
-	
+	// Get memory utilization from the monitor shared with the other plugins
+	p.memoryUtilization = sysmon.Shared().MemoryUtilization()
+
+	// Get the telemetry held in memory by the other plugins
+	p.inFlightBytes = p.inFlight.InFlightBytes()
+
+	// Get the downstream error rate
+	if p.errorRateProbe != nil {
+		p.errorRate = p.errorRateProbe.sample()
+	}
+
 	// Update metrics gauges
 	p.stateGauge.WithLabelValues("memory_utilization").Set(p.memoryUtilization)
 	p.stateGauge.WithLabelValues("queue_utilization").Set(p.queueUtilization)
 	p.stateGauge.WithLabelValues("cpu_utilization").Set(p.cpuUtilization)
 	p.stateGauge.WithLabelValues("error_rate").Set(p.errorRate)
 	p.stateGauge.WithLabelValues("latency_p99").Set(p.latencyP99)
+	p.stateGauge.WithLabelValues("in_flight_bytes").Set(float64(p.inFlightBytes))
 }
 
 // assessDegradationLevel determines the appropriate degradation level based on current metrics.
 func (p *processor) assessDegradationLevel() {
 	p.stateMutex.Lock()
 	defer p.stateMutex.Unlock()
-	
+
 	currentLevel := int(p.currentLevel.Load())
 	newLevel := 0
-	
+
+	// Keep the time-in-level counters current between transitions
+	p.accrueLevelTime()
+
+	// In-flight bytes crossing the ceiling precede running out of memory, so
+	// they raise the level to at least 2, and to 3 at twice the ceiling
+	inFlightCeiling := int64(p.config.Triggers.InFlightMiBHigh) * 1024 * 1024
+	inFlightHigh := inFlightCeiling > 0 && p.inFlightBytes >= inFlightCeiling
+
 	// Check triggers to determine the appropriate level
 	if p.memoryUtilization >= float64(p.config.Triggers.MemoryUtilizationHigh) ||
-	   p.queueUtilization >= float64(p.config.Triggers.QueueUtilizationHigh) ||
-	   p.cpuUtilization >= float64(p.config.Triggers.CPUUtilizationHigh) ||
-	   p.errorRate >= float64(p.config.Triggers.ErrorRateHigh) ||
-	   p.latencyP99 >= float64(p.config.Triggers.LatencyP99High) {
-		
+		p.queueUtilization >= float64(p.config.Triggers.QueueUtilizationHigh) ||
+		p.cpuUtilization >= float64(p.config.Triggers.CPUUtilizationHigh) ||
+		p.errorRate >= float64(p.config.Triggers.ErrorRateHigh) ||
+		p.latencyP99 >= float64(p.config.Triggers.LatencyP99High) ||
+		inFlightHigh {
+
 		// Determine the appropriate level based on severity
-		if p.memoryUtilization >= 90 || p.queueUtilization >= 90 {
+		if p.memoryUtilization >= 90 || p.queueUtilization >= 90 || (inFlightHigh && p.inFlightBytes >= 2*inFlightCeiling) {
 			newLevel = 3 // Most severe
-		} else if p.memoryUtilization >= 80 || p.queueUtilization >= 80 {
+		} else if p.memoryUtilization >= 80 || p.queueUtilization >= 80 || inFlightHigh {
 			newLevel = 2
 		} else {
 			newLevel = 1
 		}
 	}
-	
+
 	// Only decrease level if cooldown period has passed
-	if newLevel < currentLevel && time.Since(p.lastLevelChange) < time.Duration(p.config.CooldownPeriod)*time.Second {
+	if newLevel < currentLevel && p.clock.Since(p.lastLevelChange) < time.Duration(p.config.CooldownPeriod)*time.Second {
+		return
+	}
+
+	// Don't raise the level until the startup grace period has passed
+	if newLevel > currentLevel && p.clock.Since(p.startedAt) < time.Duration(p.config.StartupGracePeriod)*time.Second {
+		p.logger.Debug("Ignoring degradation trigger during startup grace period",
+			zap.Int("level", newLevel),
+			zap.Float64("memory_utilization", p.memoryUtilization),
+			zap.Float64("queue_utilization", p.queueUtilization),
+			zap.Int64("in_flight_bytes", p.inFlightBytes))
 		return
 	}
-	
+
 	// Update level if changed
 	if newLevel != currentLevel {
 		p.setDegradationLevel(newLevel)
@@ -221,109 +328,246 @@ func (p *processor) assessDegradationLevel() {
 // setDegradationLevel sets a new degradation level and applies the associated actions.
 func (p *processor) setDegradationLevel(level int) {
 	oldLevel := int(p.currentLevel.Load())
+	p.accrueLevelTime()
 	p.currentLevel.Store(int32(level))
-	p.lastLevelChange = time.Now()
+	p.lastLevelChange = p.clock.Now()
 	p.levelGauge.Set(float64(level))
-	
+
 	p.logger.Info("Changing adaptive degradation level",
 		zap.Int("old_level", oldLevel),
 		zap.Int("new_level", level),
 		zap.Float64("memory_utilization", p.memoryUtilization),
-		zap.Float64("queue_utilization", p.queueUtilization))
-	
-	// Reset all actions
-	p.sampleRate = 1.0
-	p.batchMultiplier = 1
-	p.scrapeMultiplier = 1
-	p.dropDebug = false
-	p.dropMetrics = false
-	
-	// Apply actions for the new level
-	if level > 0 && level <= len(p.config.Levels) {
-		levelIdx := level - 1
-		for _, action := range p.config.Levels[levelIdx].Actions {
-			p.applyAction(action)
+		zap.Float64("queue_utilization", p.queueUtilization),
+		zap.Int64("in_flight_bytes", p.inFlightBytes))
+
+	// Move straight to the new level's action state rather than resetting
+	// first, so state shared by both levels never passes through its reset
+	// value mid-transition
+	oldActions := p.levelActions(oldLevel)
+	newActions := p.levelActions(level)
+	p.actions.Store(newActionState(newActions, p.config.Sampling.Rate))
+
+	// Count the actions newly taken by this transition
+	previous := make(map[string]bool, len(oldActions))
+	for _, action := range oldActions {
+		previous[action] = true
+	}
+	for _, action := range newActions {
+		if !previous[action] {
 			p.actionsCounter.WithLabelValues(action).Inc()
 		}
 	}
 }
 
-// applyAction applies a specific degradation action.
-func (p *processor) applyAction(action string) {
+// accrueLevelTime adds the time since it was last called to the current
+// level. The caller must hold stateMutex.
+func (p *processor) accrueLevelTime() {
+	now := p.clock.Now()
+	elapsed := now.Sub(p.levelAccountedAt)
+	p.levelAccountedAt = now
+	if elapsed <= 0 {
+		return
+	}
+
+	level := int(p.currentLevel.Load())
+	p.levelDurations[level] += elapsed
+	p.levelSeconds.WithLabelValues(strconv.Itoa(level)).Add(elapsed.Seconds())
+}
+
+// LevelDurations returns the cumulative time spent at each degradation
+// level, up to now.
+func (p *processor) LevelDurations() map[int]time.Duration {
+	p.stateMutex.Lock()
+	defer p.stateMutex.Unlock()
+	p.accrueLevelTime()
+
+	durations := make(map[int]time.Duration, len(p.levelDurations))
+	for level, duration := range p.levelDurations {
+		durations[level] = duration
+	}
+	return durations
+}
+
+// levelActions returns the actions configured for a degradation level.
+func (p *processor) levelActions(level int) []string {
+	if level <= 0 || level > len(p.config.Levels) {
+		return nil
+	}
+	return p.config.Levels[level-1].Actions
+}
+
+// actionState is the action state resulting from a set of actions.
+type actionState struct {
+	sampleRate       float64
+	batchMultiplier  int
+	scrapeMultiplier int
+	dropDebug        bool
+	dropMetrics      bool
+}
+
+// newActionState computes the action state for a set of actions, starting
+// from the undegraded state. enable_sampling keeps sampleRate of the data.
+func newActionState(actions []string, sampleRate float64) *actionState {
+	state := &actionState{
+		sampleRate:       1.0,
+		batchMultiplier:  1,
+		scrapeMultiplier: 1,
+	}
+	for _, action := range actions {
+		state.apply(action, sampleRate)
+	}
+	return state
+}
+
+// apply applies a specific degradation action.
+func (s *actionState) apply(action string, sampleRate float64) {
 	switch action {
 	case "inc_batch":
-		p.batchMultiplier = 2
+		s.batchMultiplier = 2
 	case "stretch_scrape":
-		p.scrapeMultiplier = 2
+		s.scrapeMultiplier = 2
 	case "enable_sampling":
-		p.sampleRate = 0.5
+		s.sampleRate = sampleRate
 	case "drop_debug":
-		p.dropDebug = true
+		s.dropDebug = true
 	case "drop_metrics":
-		p.dropMetrics = true
+		s.dropMetrics = true
 	}
 }
 
 // ConsumeMetrics implements the metrics consumer interface.
 func (p *processor) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
 	level := int(p.currentLevel.Load())
-	
+	p.seenCounter.WithLabelValues("metrics").Add(float64(md.DataPointCount()))
+
 	// Apply degradation if level > 0
 	if level > 0 {
-		if p.dropMetrics {
+		actions := p.actions.Load()
+
+		// Forward critical data untouched by the level's actions
+		if critical := p.config.CriticalData.SplitMetrics(md); critical.ResourceMetrics().Len() > 0 {
+			p.forwardedCounter.WithLabelValues("metrics").Add(float64(critical.DataPointCount()))
+			if err := p.metricsConsumer.ConsumeMetrics(ctx, critical); err != nil {
+				return err
+			}
+			if md.ResourceMetrics().Len() == 0 {
+				return nil
+			}
+		}
+
+		if actions.dropMetrics {
 			p.droppedCounter.WithLabelValues("metrics").Inc()
+			p.dropLog.LogData("drop_metrics", md)
 			return nil
 		}
-		
-		// Apply sampling if enabled
-		if p.sampleRate < 1.0 && rand.Float64() > p.sampleRate {
+
+		// Apply sampling if enabled, keeping protected resources in full
+		if actions.sampleRate < 1.0 && len(p.config.Sampling.ProtectedAttributes) > 0 {
+			if dropped := p.sampleMetrics(md, actions.sampleRate); dropped.ResourceMetrics().Len() > 0 {
+				p.droppedCounter.WithLabelValues("metrics").Inc()
+				p.dropLog.LogData("sampling", dropped)
+			}
+			if md.ResourceMetrics().Len() == 0 {
+				return nil
+			}
+		} else if actions.sampleRate < 1.0 && p.randFloat64() > actions.sampleRate {
 			p.droppedCounter.WithLabelValues("metrics").Inc()
+			p.dropLog.LogData("sampling", md)
 			return nil
 		}
 	}
-	
+
+	p.forwardedCounter.WithLabelValues("metrics").Add(float64(md.DataPointCount()))
 	return p.metricsConsumer.ConsumeMetrics(ctx, md)
 }
 
 // ConsumeTraces implements the traces consumer interface.
 func (p *processor) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
 	level := int(p.currentLevel.Load())
-	
+	p.seenCounter.WithLabelValues("traces").Add(float64(td.SpanCount()))
+
 	// Apply degradation if level > 0
 	if level > 0 {
-		// Apply sampling if enabled
-		if p.sampleRate < 1.0 && rand.Float64() > p.sampleRate {
+		actions := p.actions.Load()
+
+		// Forward critical data untouched by the level's actions
+		if critical := p.config.CriticalData.SplitTraces(td); critical.ResourceSpans().Len() > 0 {
+			p.forwardedCounter.WithLabelValues("traces").Add(float64(critical.SpanCount()))
+			if err := p.tracesConsumer.ConsumeTraces(ctx, critical); err != nil {
+				return err
+			}
+			if td.ResourceSpans().Len() == 0 {
+				return nil
+			}
+		}
+
+		// Apply sampling if enabled, keeping protected resources in full
+		if actions.sampleRate < 1.0 && len(p.config.Sampling.ProtectedAttributes) > 0 {
+			if dropped := p.sampleTraces(td, actions.sampleRate); dropped.ResourceSpans().Len() > 0 {
+				p.droppedCounter.WithLabelValues("traces").Inc()
+				p.dropLog.LogData("sampling", dropped)
+			}
+			if td.ResourceSpans().Len() == 0 {
+				return nil
+			}
+		} else if actions.sampleRate < 1.0 && p.randFloat64() > actions.sampleRate {
 			p.droppedCounter.WithLabelValues("traces").Inc()
+			p.dropLog.LogData("sampling", td)
 			return nil
 		}
-		
+
 		// Filter debug spans if dropDebug is enabled
-		if p.dropDebug {
+		if actions.dropDebug {
 			td = filterDebugSpans(td)
 		}
 	}
-	
+
+	p.forwardedCounter.WithLabelValues("traces").Add(float64(td.SpanCount()))
 	return p.tracesConsumer.ConsumeTraces(ctx, td)
 }
 
 // ConsumeLogs implements the logs consumer interface.
 func (p *processor) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
 	level := int(p.currentLevel.Load())
-	
+	p.seenCounter.WithLabelValues("logs").Add(float64(ld.LogRecordCount()))
+
 	// Apply degradation if level > 0
 	if level > 0 {
-		// Apply sampling if enabled
-		if p.sampleRate < 1.0 && rand.Float64() > p.sampleRate {
+		actions := p.actions.Load()
+
+		// Forward critical data untouched by the level's actions
+		if critical := p.config.CriticalData.SplitLogs(ld); critical.ResourceLogs().Len() > 0 {
+			p.forwardedCounter.WithLabelValues("logs").Add(float64(critical.LogRecordCount()))
+			if err := p.logsConsumer.ConsumeLogs(ctx, critical); err != nil {
+				return err
+			}
+			if ld.ResourceLogs().Len() == 0 {
+				return nil
+			}
+		}
+
+		// Apply sampling if enabled, keeping protected resources in full
+		if actions.sampleRate < 1.0 && len(p.config.Sampling.ProtectedAttributes) > 0 {
+			if dropped := p.sampleLogs(ld, actions.sampleRate); dropped.ResourceLogs().Len() > 0 {
+				p.droppedCounter.WithLabelValues("logs").Inc()
+				p.dropLog.LogData("sampling", dropped)
+			}
+			if ld.ResourceLogs().Len() == 0 {
+				return nil
+			}
+		} else if actions.sampleRate < 1.0 && p.randFloat64() > actions.sampleRate {
 			p.droppedCounter.WithLabelValues("logs").Inc()
+			p.dropLog.LogData("sampling", ld)
 			return nil
 		}
-		
+
 		// Filter debug logs if dropDebug is enabled
-		if p.dropDebug {
+		if actions.dropDebug {
 			ld = filterDebugLogs(ld)
 		}
 	}
-	
+
+	p.forwardedCounter.WithLabelValues("logs").Add(float64(ld.LogRecordCount()))
 	return p.logsConsumer.ConsumeLogs(ctx, ld)
 }
 
@@ -339,41 +583,41 @@ func filterDebugSpans(td ptrace.Traces) ptrace.Traces {
 func filterDebugLogs(ld plog.Logs) plog.Logs {
 	// Create a new logs collection
 	filtered := plog.NewLogs()
-	
+
 	// Iterate through resource logs
 	for i := 0; i < ld.ResourceLogs().Len(); i++ {
 		resourceLogs := ld.ResourceLogs().At(i)
-		
+
 		// Create a new resource logs entry
 		newResourceLogs := filtered.ResourceLogs().AppendEmpty()
 		resourceLogs.Resource().CopyTo(newResourceLogs.Resource())
-		
+
 		// Iterate through scope logs
 		for j := 0; j < resourceLogs.ScopeLogs().Len(); j++ {
 			scopeLogs := resourceLogs.ScopeLogs().At(j)
-			
+
 			// Create a new scope logs entry
 			newScopeLogs := newResourceLogs.ScopeLogs().AppendEmpty()
 			scopeLogs.Scope().CopyTo(newScopeLogs.Scope())
-			
+
 			// Iterate through logs and keep only non-debug logs
 			for k := 0; k < scopeLogs.LogRecords().Len(); k++ {
 				logRecord := scopeLogs.LogRecords().At(k)
-				
+
 				// Check if this is a debug log (severity number <= 5)
 				// See: https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/logs/data-model.md#severity-fields
 				severityNumber := logRecord.SeverityNumber()
 				if severityNumber <= 5 { // Debug or lower
 					continue
 				}
-				
+
 				// Not a debug log, keep it
 				newLogRecord := newScopeLogs.LogRecords().AppendEmpty()
 				logRecord.CopyTo(newLogRecord)
 			}
 		}
 	}
-	
+
 	return filtered
 }
 
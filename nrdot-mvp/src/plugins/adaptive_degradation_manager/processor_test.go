@@ -0,0 +1,423 @@
+package adaptivedegradationmanager
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// metricsSink records the services of the metrics forwarded to it.
+type metricsSink struct {
+	mutex    sync.Mutex
+	services []string
+}
+
+func (s *metricsSink) ConsumeMetrics(_ context.Context, md pmetric.Metrics) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		service, _ := md.ResourceMetrics().At(i).Resource().Attributes().Get("service.name")
+		s.services = append(s.services, service.AsString())
+	}
+	return nil
+}
+
+func (s *metricsSink) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{}
+}
+
+func (s *metricsSink) forwarded() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.services...)
+}
+
+// newTestProcessor creates a metrics processor on a fake clock, named after
+// the test so its Prometheus metrics are the test's own.
+func newTestProcessor(t *testing.T, configure func(*Config)) (*processor, *metricsSink, *clock.FakeClock) {
+	t.Helper()
+
+	config := CreateDefaultConfig().(*Config)
+	if configure != nil {
+		configure(config)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+
+	sink := &metricsSink{}
+	p, err := newProcessor(zap.NewNop(), config, component.NewIDWithName(typeStr, t.Name()), sink)
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+	t.Cleanup(func() {
+		p.Shutdown(context.Background())
+	})
+	fake := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	p.SetClock(fake)
+	p.SetRand(rand.New(rand.NewSource(1)))
+	p.startedAt = fake.Now()
+	return p, sink, fake
+}
+
+// serviceMetrics returns a batch with one data point from each service.
+func serviceMetrics(services ...string) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	for _, service := range services {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr("service.name", service)
+		gauge := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		gauge.SetName("requests")
+		gauge.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+	}
+	return md
+}
+
+func TestSamplingForwardsConfiguredRate(t *testing.T) {
+	p, sink, _ := newTestProcessor(t, func(config *Config) {
+		config.Sampling.Rate = 0.5
+	})
+	p.setDegradationLevel(2)
+
+	const batches = 10000
+	for i := 0; i < batches; i++ {
+		if err := p.ConsumeMetrics(context.Background(), serviceMetrics("checkout")); err != nil {
+			t.Fatalf("failed to consume metrics: %v", err)
+		}
+	}
+
+	seen := testutil.ToFloat64(p.seenCounter.WithLabelValues("metrics"))
+	forwarded := testutil.ToFloat64(p.forwardedCounter.WithLabelValues("metrics"))
+	if seen != batches {
+		t.Fatalf("expected %d items seen, got %v", batches, seen)
+	}
+	if int(forwarded) != len(sink.forwarded()) {
+		t.Fatalf("expected the forwarded counter to match the %d items forwarded, got %v", len(sink.forwarded()), forwarded)
+	}
+	if ratio := forwarded / seen; math.Abs(ratio-0.5) > 0.03 {
+		t.Fatalf("expected about half the items to be forwarded, got a ratio of %.3f", ratio)
+	}
+}
+
+// tracesOnly hides every consumer interface but consumer.Traces.
+type tracesOnly struct {
+	consumer.Traces
+}
+
+// admSeries returns the number of series of an ADM metric registered for a
+// processor.
+func admSeries(t *testing.T, name string, processorID string) int {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	series := 0
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "processor" && label.GetValue() == processorID {
+					series++
+				}
+			}
+		}
+	}
+	return series
+}
+
+func TestInstancesRegisterMetricsSideBySide(t *testing.T) {
+	id := component.NewIDWithName(typeStr, "side-by-side")
+	config := CreateDefaultConfig().(*Config)
+
+	// The same processor in a metrics and a traces pipeline, plus a second
+	// metrics pipeline sharing the metrics instance's series
+	metrics, err := newProcessor(zap.NewNop(), config, id, consumertest.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+	traces, err := newProcessor(zap.NewNop(), config, id, tracesOnly{consumertest.NewNop()})
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+	again, err := newProcessor(zap.NewNop(), config, id, consumertest.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+	if got := admSeries(t, "otelcol_adm_current_level", id.String()); got != 2 {
+		t.Fatalf("expected a level gauge for each signal, got %d", got)
+	}
+
+	// A series stays registered until every instance sharing it has shut down
+	metrics.Shutdown(context.Background())
+	if got := admSeries(t, "otelcol_adm_current_level", id.String()); got != 2 {
+		t.Fatalf("expected the shared level gauge to stay registered, got %d series", got)
+	}
+	again.Shutdown(context.Background())
+	traces.Shutdown(context.Background())
+	if got := admSeries(t, "otelcol_adm_current_level", id.String()); got != 0 {
+		t.Fatalf("expected the level gauges to be unregistered, got %d series", got)
+	}
+}
+
+func TestTransitionKeepsOverlappingActions(t *testing.T) {
+	p, _, _ := newTestProcessor(t, func(config *Config) {
+		config.Sampling.Rate = 0.25
+		config.Levels[2].Actions = []string{"enable_sampling", "drop_debug"}
+	})
+
+	p.setDegradationLevel(2)
+	if actions := p.actions.Load(); actions.sampleRate != 0.25 {
+		t.Fatalf("expected level 2 to sample at 0.25, got %v", actions.sampleRate)
+	}
+
+	// Moving to level 3, which also samples, applies only drop_debug
+	p.setDegradationLevel(3)
+	if actions := p.actions.Load(); actions.sampleRate != 0.25 || !actions.dropDebug {
+		t.Fatalf("expected level 3 to keep sampling at 0.25 and drop debug data, got rate %v, dropDebug %v", actions.sampleRate, actions.dropDebug)
+	}
+	if got := testutil.ToFloat64(p.actionsCounter.WithLabelValues("enable_sampling")); got != 1 {
+		t.Fatalf("expected enable_sampling to be taken once, got %v", got)
+	}
+	if got := testutil.ToFloat64(p.actionsCounter.WithLabelValues("drop_debug")); got != 1 {
+		t.Fatalf("expected drop_debug to be taken once, got %v", got)
+	}
+
+	// The state for level 3 is computed directly, never from a reset state
+	target := newActionState(p.levelActions(3), p.config.Sampling.Rate)
+	if target.sampleRate != 0.25 {
+		t.Fatalf("expected the level 3 target to sample at 0.25, got %v", target.sampleRate)
+	}
+
+	p.setDegradationLevel(2)
+	if actions := p.actions.Load(); actions.sampleRate != 0.25 || actions.dropDebug {
+		t.Fatalf("expected level 2 to keep sampling and stop dropping debug data, got rate %v, dropDebug %v", actions.sampleRate, actions.dropDebug)
+	}
+
+	// Consumers reading the state while the level moves back and forth never
+	// see sampling switched off
+	stop := make(chan struct{})
+	unsampled := make(chan float64, 1)
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if rate := p.actions.Load().sampleRate; rate == 1.0 {
+				select {
+				case unsampled <- rate:
+				default:
+				}
+			}
+		}
+	}()
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := p.ConsumeMetrics(context.Background(), serviceMetrics("checkout")); err != nil {
+				t.Errorf("failed to consume metrics: %v", err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		p.setDegradationLevel(3)
+		p.setDegradationLevel(2)
+	}
+	close(stop)
+	readers.Wait()
+
+	select {
+	case rate := <-unsampled:
+		t.Fatalf("expected the sample rate to stay at 0.25 through every transition, read %v", rate)
+	default:
+	}
+}
+
+func TestNoDegradationDuringStartupGracePeriod(t *testing.T) {
+	p, _, fake := newTestProcessor(t, func(config *Config) {
+		config.StartupGracePeriod = 30
+	})
+	p.memoryUtilization = 95
+
+	for i := 0; i < 5; i++ {
+		fake.Advance(5 * time.Second)
+		p.assessDegradationLevel()
+		if level := p.currentLevel.Load(); level != 0 {
+			t.Fatalf("expected no degradation %v after startup, got level %d", fake.Since(p.startedAt), level)
+		}
+	}
+
+	fake.Advance(5 * time.Second)
+	p.assessDegradationLevel()
+	if level := p.currentLevel.Load(); level != 3 {
+		t.Fatalf("expected level 3 once the grace period has passed, got %d", level)
+	}
+}
+
+func TestLevelDropsOnlyAfterCooldown(t *testing.T) {
+	p, _, fake := newTestProcessor(t, func(config *Config) {
+		config.CooldownPeriod = 60
+	})
+	fake.Advance(time.Duration(p.config.StartupGracePeriod) * time.Second)
+
+	p.memoryUtilization = 95
+	p.assessDegradationLevel()
+	if level := p.currentLevel.Load(); level != 3 {
+		t.Fatalf("expected level 3 under memory pressure, got %d", level)
+	}
+
+	// The pressure is gone, but the level holds until the cooldown has passed
+	p.memoryUtilization = 10
+	fake.Advance(59 * time.Second)
+	p.assessDegradationLevel()
+	if level := p.currentLevel.Load(); level != 3 {
+		t.Fatalf("expected level 3 to hold during the cooldown, got %d", level)
+	}
+	fake.Advance(time.Second)
+	p.assessDegradationLevel()
+	if level := p.currentLevel.Load(); level != 0 {
+		t.Fatalf("expected level 0 once the cooldown has passed, got %d", level)
+	}
+}
+
+func TestSeededSamplingIsReproducible(t *testing.T) {
+	services := make([]string, 20)
+	for i := range services {
+		services[i] = string(rune('a' + i))
+	}
+
+	forward := func() []string {
+		p, sink, _ := newTestProcessor(t, nil)
+		p.SetRand(rand.New(rand.NewSource(42)))
+		p.setDegradationLevel(2)
+		for _, service := range services {
+			if err := p.ConsumeMetrics(context.Background(), serviceMetrics(service)); err != nil {
+				t.Fatalf("failed to consume metrics: %v", err)
+			}
+		}
+		return sink.forwarded()
+	}
+
+	// The same seed gives the draws of a generator seeded alike
+	rng := rand.New(rand.NewSource(42))
+	var expected []string
+	for _, service := range services {
+		if rng.Float64() <= 0.5 {
+			expected = append(expected, service)
+		}
+	}
+
+	for run := 0; run < 2; run++ {
+		got := forward()
+		if len(got) != len(expected) {
+			t.Fatalf("run %d: expected %v to be forwarded, got %v", run, expected, got)
+		}
+		for i := range expected {
+			if got[i] != expected[i] {
+				t.Fatalf("run %d: expected %v to be forwarded, got %v", run, expected, got)
+			}
+		}
+	}
+}
+
+func TestLevelDurations(t *testing.T) {
+	p, _, fake := newTestProcessor(t, nil)
+
+	fake.Advance(10 * time.Second)
+	p.setDegradationLevel(1)
+	fake.Advance(20 * time.Second)
+	p.setDegradationLevel(3)
+	fake.Advance(5 * time.Second)
+	p.setDegradationLevel(1)
+	fake.Advance(7 * time.Second)
+
+	expected := map[int]time.Duration{
+		0: 10 * time.Second,
+		1: 27 * time.Second,
+		3: 5 * time.Second,
+	}
+	durations := p.LevelDurations()
+	if len(durations) != len(expected) {
+		t.Fatalf("expected durations %v, got %v", expected, durations)
+	}
+	for level, duration := range expected {
+		if durations[level] != duration {
+			t.Fatalf("expected %v at level %d, got %v", duration, level, durations[level])
+		}
+	}
+	if got := testutil.ToFloat64(p.levelSeconds.WithLabelValues("1")); got != 27 {
+		t.Fatalf("expected 27 seconds counted at level 1, got %v", got)
+	}
+}
+
+// fixedInFlight is an in-flight byte source with a fixed value.
+type fixedInFlight int64
+
+func (f fixedInFlight) InFlightBytes() int64 { return int64(f) }
+
+func TestInFlightBytesEscalateLevel(t *testing.T) {
+	p, _, fake := newTestProcessor(t, func(config *Config) {
+		config.Triggers.InFlightMiBHigh = 64
+	})
+	fake.Advance(time.Duration(p.config.StartupGracePeriod) * time.Second)
+
+	for _, tc := range []struct {
+		bytes int64
+		level int32
+	}{
+		{bytes: 32 << 20, level: 0},
+		{bytes: 64 << 20, level: 2},
+		{bytes: 128 << 20, level: 3},
+	} {
+		p.SetInFlightSource(fixedInFlight(tc.bytes))
+		p.inFlightBytes = p.inFlight.InFlightBytes()
+		p.assessDegradationLevel()
+		if level := p.currentLevel.Load(); level != tc.level {
+			t.Fatalf("expected level %d with %d bytes in flight, got %d", tc.level, tc.bytes, level)
+		}
+	}
+}
+
+func TestCriticalDataSurvivesDropMetrics(t *testing.T) {
+	p, sink, _ := newTestProcessor(t, func(config *Config) {
+		config.CriticalData.NeverDrop = true
+	})
+	p.setDegradationLevel(3)
+
+	md := serviceMetrics("payments", "checkout")
+	md.ResourceMetrics().At(0).Resource().Attributes().PutStr("nrdot.priority", "critical")
+	if err := p.ConsumeMetrics(context.Background(), md); err != nil {
+		t.Fatalf("failed to consume metrics: %v", err)
+	}
+
+	if got := sink.forwarded(); len(got) != 1 || got[0] != "payments" {
+		t.Fatalf("expected only the critical payments metrics to be forwarded, got %v", got)
+	}
+}
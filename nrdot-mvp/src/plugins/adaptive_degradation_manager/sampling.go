@@ -0,0 +1,84 @@
+package adaptivedegradationmanager
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// SamplingConfig configures the enable_sampling action.
+type SamplingConfig struct {
+	// Rate is the fraction of data kept while sampling is enabled.
+	// Default: 0.5
+	Rate float64 `mapstructure:"rate"`
+
+	// ProtectedAttributes maps resource attribute names to values whose data
+	// is always kept in full, such as low-volume but important services.
+	// Only data from other resources is sampled.
+	ProtectedAttributes map[string][]string `mapstructure:"protected_attributes"`
+}
+
+// protected returns whether a resource matches the protected attribute set.
+func (cfg *SamplingConfig) protected(resource pcommon.Resource) bool {
+	for name, values := range cfg.ProtectedAttributes {
+		value, ok := resource.Attributes().Get(name)
+		if !ok {
+			continue
+		}
+		for _, protected := range values {
+			if value.AsString() == protected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sampled returns whether data from a resource is dropped by sampling at the
+// given rate.
+func (p *processor) sampled(resource pcommon.Resource, rate float64) bool {
+	return !p.config.Sampling.protected(resource) && p.randFloat64() > rate
+}
+
+// sampleMetrics removes the resources dropped by attribute-aware sampling at
+// the given rate and returns them.
+func (p *processor) sampleMetrics(md pmetric.Metrics, rate float64) pmetric.Metrics {
+	dropped := pmetric.NewMetrics()
+	md.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		if !p.sampled(rm.Resource(), rate) {
+			return false
+		}
+		rm.MoveTo(dropped.ResourceMetrics().AppendEmpty())
+		return true
+	})
+	return dropped
+}
+
+// sampleTraces removes the resources dropped by attribute-aware sampling at
+// the given rate and returns them.
+func (p *processor) sampleTraces(td ptrace.Traces, rate float64) ptrace.Traces {
+	dropped := ptrace.NewTraces()
+	td.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		if !p.sampled(rs.Resource(), rate) {
+			return false
+		}
+		rs.MoveTo(dropped.ResourceSpans().AppendEmpty())
+		return true
+	})
+	return dropped
+}
+
+// sampleLogs removes the resources dropped by attribute-aware sampling and
+// returns them.
+func (p *processor) sampleLogs(ld plog.Logs, rate float64) plog.Logs {
+	dropped := plog.NewLogs()
+	ld.ResourceLogs().RemoveIf(func(rl plog.ResourceLogs) bool {
+		if !p.sampled(rl.Resource(), rate) {
+			return false
+		}
+		rl.MoveTo(dropped.ResourceLogs().AppendEmpty())
+		return true
+	})
+	return dropped
+}
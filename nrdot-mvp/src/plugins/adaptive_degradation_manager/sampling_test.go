@@ -0,0 +1,34 @@
+package adaptivedegradationmanager
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSamplingKeepsProtectedServices(t *testing.T) {
+	p, sink, _ := newTestProcessor(t, func(config *Config) {
+		config.Sampling.Rate = 0.1
+		config.Sampling.ProtectedAttributes = map[string][]string{
+			"service.name": {"payments"},
+		}
+	})
+	p.setDegradationLevel(2)
+
+	const batches = 1000
+	for i := 0; i < batches; i++ {
+		if err := p.ConsumeMetrics(context.Background(), serviceMetrics("payments", "checkout")); err != nil {
+			t.Fatalf("failed to consume metrics: %v", err)
+		}
+	}
+
+	forwarded := make(map[string]int)
+	for _, service := range sink.forwarded() {
+		forwarded[service]++
+	}
+	if forwarded["payments"] != batches {
+		t.Fatalf("expected every payments item to be kept, got %d of %d", forwarded["payments"], batches)
+	}
+	if got := forwarded["checkout"]; got < 70 || got > 130 {
+		t.Fatalf("expected about 10%% of checkout items to be kept, got %d of %d", got, batches)
+	}
+}
@@ -1,52 +0,0 @@
-package adaptivedegradationmanager
-
-import (
-	"context"
-
-	"go.opentelemetry.io/collector/component"
-	"go.opentelemetry.io/collector/consumer"
-	"go.opentelemetry.io/collector/pdata/ptrace"
-	"go.uber.org/zap"
-)
-
-// tracesProcessor is the processor for implementing adaptive degradation for traces.
-type tracesProcessor struct {
-	logger       *zap.Logger
-	config       *Config
-	nextConsumer consumer.Traces
-	// This would share the same degradation manager as the metrics processor
-	// to ensure consistent degradation levels across signals
-	metricsProcessor *metricsProcessor
-}
-
-// newTracesProcessor creates a new traces processor.
-func newTracesProcessor(logger *zap.Logger, config *Config, nextConsumer consumer.Traces) (*tracesProcessor, error) {
-	return &tracesProcessor{
-		logger:       logger,
-		config:       config,
-		nextConsumer: nextConsumer,
-	}, nil
-}
-
-// ConsumeTraces implements the traces consumer interface.
-func (p *tracesProcessor) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
-	// In a full implementation, this would apply the same degradation actions
-	// as the metrics processor, but for traces.
-	// For simplicity, we just pass through the traces.
-	return p.nextConsumer.ConsumeTraces(ctx, td)
-}
-
-// Capabilities returns the consumer capabilities.
-func (p *tracesProcessor) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{MutatesData: true}
-}
-
-// Start starts the processor.
-func (p *tracesProcessor) Start(ctx context.Context, host component.Host) error {
-	return nil
-}
-
-// Shutdown stops the processor.
-func (p *tracesProcessor) Shutdown(ctx context.Context) error {
-	return nil
-}
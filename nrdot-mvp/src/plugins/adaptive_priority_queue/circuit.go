@@ -0,0 +1,92 @@
+package adaptivepriorityqueue
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
+)
+
+// CircuitState is the state of a queue's circuit breaker. Its value is the
+// one reported by the circuit breaker state gauge.
+type CircuitState int
+
+// Circuit breaker states.
+const (
+	// CircuitClosed lets data through while the error rate is below the
+	// threshold.
+	CircuitClosed CircuitState = 0
+	// CircuitOpen refuses data until the reset timeout has passed.
+	CircuitOpen CircuitState = 1
+	// CircuitHalfOpen lets data through after the reset timeout; the next
+	// success closes the circuit and the next error opens it again.
+	CircuitHalfOpen CircuitState = 2
+)
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitState returns the current state of the circuit breaker.
+func (q *AdaptivePriorityQueue) CircuitState() CircuitState {
+	q.circuitLock.Lock()
+	defer q.circuitLock.Unlock()
+	q.halfOpenIfDue()
+	return q.circuitState
+}
+
+// halfOpenIfDue moves an open circuit to half-open once the reset timeout
+// has passed. The caller must hold circuitLock.
+func (q *AdaptivePriorityQueue) halfOpenIfDue() {
+	if q.circuitState != CircuitOpen {
+		return
+	}
+	if q.clock.Since(q.lastCircuitTrip) <= time.Duration(q.config.CircuitBreakerResetTimeout)*time.Second {
+		return
+	}
+	q.successCount = 0
+	q.errorCount = 0
+	q.setCircuitState(CircuitHalfOpen)
+}
+
+// setCircuitState moves the circuit breaker to a state and logs the
+// transition with the given fields. The caller must hold circuitLock.
+func (q *AdaptivePriorityQueue) setCircuitState(state CircuitState, fields ...zap.Field) {
+	if state == q.circuitState {
+		return
+	}
+	previous := q.circuitState
+	q.circuitState = state
+
+	fields = append([]zap.Field{
+		zap.String("from", previous.String()),
+		zap.String("to", state.String()),
+	}, fields...)
+	if state == CircuitOpen {
+		q.lastCircuitTrip = q.clock.Now()
+		q.logger.Warn("Circuit breaker state changed", fields...)
+		return
+	}
+	q.logger.Info("Circuit breaker state changed", fields...)
+}
+
+// registerCircuitStateGauge publishes the state of the queue's circuit
+// breaker.
+func registerCircuitStateGauge(metrics *promreg.Registry, q *AdaptivePriorityQueue) {
+	metrics.GaugeFunc(prometheus.GaugeOpts{
+		Name: "otelcol_adaptive_priority_queue_circuit_breaker_state",
+		Help: "State of the circuit breaker: 0 closed, 1 open, 2 half-open",
+	}, func() float64 {
+		return float64(q.CircuitState())
+	})
+}
@@ -0,0 +1,119 @@
+package adaptivepriorityqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
+)
+
+// circuitGaugeValue returns the value of the circuit breaker state gauge
+// registered for a processor.
+func circuitGaugeValue(t *testing.T, processorID string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "otelcol_adaptive_priority_queue_circuit_breaker_state" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "processor" && label.GetValue() == processorID {
+					return metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	t.Fatal("expected the circuit breaker state gauge to be registered")
+	return 0
+}
+
+func TestCircuitStateGaugeAndTransitions(t *testing.T) {
+	q, fake := newTestQueue(t, func(config *Config) {
+		config.CircuitBreakerEnabled = true
+		config.CircuitBreakerErrorThreshold = 50
+		config.CircuitBreakerResetTimeout = 30
+	})
+	core, logs := observer.New(zapcore.InfoLevel)
+	q.logger = zap.New(core)
+
+	metrics := promreg.New(zap.NewNop(), prometheus.Labels{"processor": "adaptive_priority_queue/circuit", "signal": "metrics"})
+	registerCircuitStateGauge(metrics, q)
+	defer metrics.Unregister()
+
+	if got := circuitGaugeValue(t, "adaptive_priority_queue/circuit"); got != float64(CircuitClosed) {
+		t.Fatalf("expected the gauge to report closed, got %v", got)
+	}
+
+	// Enough errors trip the circuit
+	for i := 0; i < 10; i++ {
+		q.RecordError()
+	}
+	if got := circuitGaugeValue(t, "adaptive_priority_queue/circuit"); got != float64(CircuitOpen) {
+		t.Fatalf("expected the gauge to report open, got %v", got)
+	}
+
+	// The reset timeout half-opens it, and a success closes it
+	fake.Advance(31 * time.Second)
+	if got := circuitGaugeValue(t, "adaptive_priority_queue/circuit"); got != float64(CircuitHalfOpen) {
+		t.Fatalf("expected the gauge to report half-open, got %v", got)
+	}
+	q.RecordSuccess()
+	if got := circuitGaugeValue(t, "adaptive_priority_queue/circuit"); got != float64(CircuitClosed) {
+		t.Fatalf("expected the gauge to report closed again, got %v", got)
+	}
+
+	// Each transition is logged once, opening as a warning
+	want := []struct {
+		from, to string
+		level    zapcore.Level
+	}{
+		{"closed", "open", zapcore.WarnLevel},
+		{"open", "half-open", zapcore.InfoLevel},
+		{"half-open", "closed", zapcore.InfoLevel},
+	}
+	entries := logs.FilterMessage("Circuit breaker state changed").All()
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d transitions to be logged, got %d", len(want), len(entries))
+	}
+	for i, entry := range entries {
+		fields := entry.ContextMap()
+		if fields["from"] != want[i].from || fields["to"] != want[i].to || entry.Level != want[i].level {
+			t.Errorf("transition %d: expected %s to %s at %s, got %v to %v at %s",
+				i, want[i].from, want[i].to, want[i].level, fields["from"], fields["to"], entry.Level)
+		}
+	}
+}
+
+func TestCircuitHalfOpensAfterResetTimeout(t *testing.T) {
+	q, fake := newTestQueue(t, func(config *Config) {
+		config.CircuitBreakerEnabled = true
+		config.CircuitBreakerErrorThreshold = 50
+		config.CircuitBreakerResetTimeout = 30
+	})
+
+	for i := 0; i < 10; i++ {
+		q.RecordError()
+	}
+	if state := q.CircuitState(); state != CircuitOpen {
+		t.Fatalf("expected the circuit to open, got %v", state)
+	}
+
+	fake.Advance(30 * time.Second)
+	if state := q.CircuitState(); state != CircuitOpen {
+		t.Fatalf("expected the circuit to stay open until the reset timeout has passed, got %v", state)
+	}
+	fake.Advance(time.Millisecond)
+	if state := q.CircuitState(); state != CircuitHalfOpen {
+		t.Fatalf("expected the circuit to half-open once the reset timeout has passed, got %v", state)
+	}
+}
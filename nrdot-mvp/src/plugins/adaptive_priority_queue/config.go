@@ -1,16 +1,58 @@
 package adaptivepriorityqueue
 
 import (
+	"fmt"
+
 	"go.opentelemetry.io/collector/component"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/droplog"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/priority"
 )
 
 // Config defines the configuration for the AdaptivePriorityQueue processor.
 type Config struct {
 	// Priorities defines the weights for each priority level.
 	// The keys are the priority level names, and the values are the weights.
-	// Default: critical=5, high=3, normal=1
+	// Low is always present, with weight 0 unless configured, so it is only
+	// served when nothing else is queued or through min_service_ratios.
+	// Default: critical=5, high=3, normal=1, low=0
 	Priorities map[string]int `mapstructure:"priorities"`
 
+	// ReplayPriority is the priority data replayed from an enhanced_dlq
+	// exporter is queued at in place of its own, so fresh live data is
+	// served ahead of a replayed backlog.
+	// Default: "low"
+	ReplayPriority string `mapstructure:"replay_priority"`
+
+	// MinServiceRatios defines the minimum fraction of dequeues each priority
+	// level is guaranteed over the service window, regardless of its weight.
+	// This prevents lower priorities from starving during floods of higher
+	// priority data, and lets a priority with weight 0 still be serviced.
+	// Default: none
+	MinServiceRatios map[string]float64 `mapstructure:"min_service_ratios"`
+
+	// ServiceWindow is the number of most recent dequeues over which the
+	// minimum service ratios are enforced.
+	// Default: 100
+	ServiceWindow int `mapstructure:"service_window"`
+
+	// TraceBufferWindowMs is how long spans are buffered by trace ID before the
+	// trace is enqueued, so traces containing an error span can be given
+	// critical priority. 0 disables buffering.
+	// Default: 0
+	TraceBufferWindowMs int `mapstructure:"trace_buffer_window_ms"`
+
+	// MaxBufferedTraces is the maximum number of traces held in the buffer.
+	// The oldest traces are enqueued early when it is exceeded.
+	// Default: 10000
+	MaxBufferedTraces int `mapstructure:"max_buffered_traces"`
+
+	// LogSeverityPriorities maps ranges of log severity numbers to priority
+	// levels. The first range containing a record's severity wins, and records
+	// matching no range are normal priority.
+	// Default: ERROR and FATAL (17-24) critical, WARN (13-16) high
+	LogSeverityPriorities []SeverityRange `mapstructure:"log_severity_priorities"`
+
 	// MaxQueueSize is the maximum number of items that can be held in the queue.
 	// Default: 10000
 	MaxQueueSize int `mapstructure:"max_queue_size"`
@@ -20,11 +62,56 @@ type Config struct {
 	// Default: 95
 	QueueFullThreshold int `mapstructure:"queue_full_threshold"`
 
+	// ReservedCapacity is the percentage of the queue reserved above
+	// QueueFullThreshold for each priority level. Items of a priority may
+	// fill the queue past the threshold into its own reservation and those of
+	// the lower priorities, so a critical burst is admitted while lower
+	// priorities have filled the queue to the threshold. The threshold and
+	// the reservations must not sum to more than 100.
+	// Default: none
+	ReservedCapacity map[string]int `mapstructure:"reserved_capacity"`
+
+	// MemoryAdmissionThreshold is the process memory utilization percentage
+	// above which new items of any priority are refused and handed to the
+	// overflow strategy. Value should be between 0 and 100, 0 disables it.
+	// Default: 0
+	MemoryAdmissionThreshold int `mapstructure:"memory_admission_threshold"`
+
+	// BackpressureAfterOverflows is the number of consecutive enqueues that
+	// overflow after which the processors return an error instead of applying
+	// the overflow strategy, so receivers reject requests and senders slow
+	// down. It lifts once the queue accepts items again. 0 disables it.
+	// Default: 0
+	BackpressureAfterOverflows int `mapstructure:"backpressure_after_overflows"`
+
+	// MaxItemAgeSec is how long, in seconds, an item may wait in the queue.
+	// Older items are discarded instead of being dequeued, except critical
+	// items while critical_data.never_drop is set. 0 disables it.
+	// Default: 0
+	MaxItemAgeSec int `mapstructure:"max_item_age_sec"`
+
+	// OverflowRateWindowSec is the sliding window, in seconds, over which the
+	// overflow rate is averaged.
+	// Default: 60
+	OverflowRateWindowSec int `mapstructure:"overflow_rate_window_sec"`
+
+	// OverflowAlert raises an alert when overflow is sustained rather than a
+	// brief spike.
+	OverflowAlert OverflowAlertConfig `mapstructure:"overflow_alert"`
+
 	// OverflowStrategy defines what happens when the queue is full.
 	// Options: "drop", "dlq", "block"
 	// Default: "dlq"
 	OverflowStrategy string `mapstructure:"overflow_strategy"`
 
+	// DLQExporter is the ID of the exporter that overflowed batches are
+	// written to when the overflow strategy is "dlq", typically an
+	// enhanced_dlq. It must be part of a pipeline of the processor's signal,
+	// but not the pipeline the processor runs in, which would send it every
+	// batch; see the README.
+	// Default: "enhanced_dlq"
+	DLQExporter string `mapstructure:"dlq_exporter"`
+
 	// CircuitBreakerEnabled enables the circuit breaker to detect backend issues.
 	// Default: true
 	CircuitBreakerEnabled bool `mapstructure:"circuit_breaker_enabled"`
@@ -36,6 +123,39 @@ type Config struct {
 	// CircuitBreakerResetTimeout is the time in seconds after which to try closing the circuit.
 	// Default: 60
 	CircuitBreakerResetTimeout int `mapstructure:"circuit_breaker_reset_timeout"`
+
+	// StateMetricsIntervalSec is how often, in seconds, the metrics processor
+	// forwards an OTLP snapshot of the queue state (size and processed items
+	// per priority, overflow count, circuit breaker state) to the next
+	// consumer alongside the data. 0 disables the snapshot.
+	// Default: 0
+	StateMetricsIntervalSec int `mapstructure:"state_metrics_interval_sec"`
+
+	// CriticalData keeps data classified critical by a resource attribute
+	// from being dropped on overflow, writing it to the DLQ exporter even
+	// when the overflow strategy isn't "dlq".
+	CriticalData priority.Config `mapstructure:"critical_data"`
+
+	// DropLog configures the sampled log of items lost when overflow
+	// handling fails.
+	DropLog droplog.Config `mapstructure:"drop_log"`
+}
+
+// OverflowAlertConfig defines when sustained overflow raises an alert.
+type OverflowAlertConfig struct {
+	// ThresholdPerSec is the overflow rate, in items per second over the
+	// overflow rate window, at or above which overflow counts as sustained.
+	// 0 disables the alert.
+	ThresholdPerSec float64 `mapstructure:"threshold_per_sec"`
+
+	// DurationSec is how long the rate must stay at or above the threshold
+	// before the alert fires.
+	// Default: 60
+	DurationSec int `mapstructure:"duration_sec"`
+
+	// WebhookURL receives a JSON POST when the alert fires. If empty the
+	// alert is only logged.
+	WebhookURL string `mapstructure:"webhook_url"`
 }
 
 // Validate validates the processor configuration.
@@ -48,6 +168,63 @@ func (cfg *Config) Validate() error {
 			"normal":   1,
 		}
 	}
+	if _, exists := cfg.Priorities[string(PriorityLow)]; !exists {
+		cfg.Priorities[string(PriorityLow)] = 0
+	}
+	for priority := range cfg.Priorities {
+		if !knownPriority(PriorityLevel(priority)) {
+			return fmt.Errorf("unknown priority '%s', must be 'critical', 'high', 'normal' or 'low'", priority)
+		}
+	}
+
+	// Set default replay priority if not specified
+	if cfg.ReplayPriority == "" {
+		cfg.ReplayPriority = string(PriorityLow)
+	}
+	if !knownPriority(PriorityLevel(cfg.ReplayPriority)) {
+		return fmt.Errorf("unknown replay_priority '%s', must be 'critical', 'high', 'normal' or 'low'", cfg.ReplayPriority)
+	}
+
+	// Validate minimum service ratios
+	var totalRatio float64
+	for priority, ratio := range cfg.MinServiceRatios {
+		if _, exists := cfg.Priorities[priority]; !exists {
+			return fmt.Errorf("min_service_ratios references unknown priority '%s'", priority)
+		}
+		if ratio < 0 || ratio > 1 {
+			return fmt.Errorf("min_service_ratios for '%s' must be between 0 and 1", priority)
+		}
+		totalRatio += ratio
+	}
+	if totalRatio > 1 {
+		return fmt.Errorf("min_service_ratios must not sum to more than 1")
+	}
+
+	// Set default service window if not specified
+	if cfg.ServiceWindow <= 0 {
+		cfg.ServiceWindow = 100
+	}
+
+	// Validate trace buffering
+	if cfg.TraceBufferWindowMs < 0 {
+		return fmt.Errorf("trace_buffer_window_ms must not be negative")
+	}
+	if cfg.MaxBufferedTraces <= 0 {
+		cfg.MaxBufferedTraces = 10000
+	}
+
+	// Set default log severity priorities if not specified
+	if len(cfg.LogSeverityPriorities) == 0 {
+		cfg.LogSeverityPriorities = defaultSeverityPriorities()
+	}
+	for _, r := range cfg.LogSeverityPriorities {
+		if r.Min < 1 || r.Max > 24 || r.Min > r.Max {
+			return fmt.Errorf("log_severity_priorities range %d-%d must be within 1-24 with min <= max", r.Min, r.Max)
+		}
+		if _, exists := cfg.Priorities[r.Priority]; !exists {
+			return fmt.Errorf("log_severity_priorities references unknown priority '%s'", r.Priority)
+		}
+	}
 
 	// Set default max queue size if not specified
 	if cfg.MaxQueueSize <= 0 {
@@ -59,11 +236,60 @@ func (cfg *Config) Validate() error {
 		cfg.QueueFullThreshold = 95
 	}
 
+	// Validate reserved capacity
+	totalReserved := 0
+	for priority, percent := range cfg.ReservedCapacity {
+		if _, exists := cfg.Priorities[priority]; !exists {
+			return fmt.Errorf("reserved_capacity references unknown priority '%s'", priority)
+		}
+		if percent < 0 {
+			return fmt.Errorf("reserved_capacity for '%s' must not be negative", priority)
+		}
+		totalReserved += percent
+	}
+	if cfg.QueueFullThreshold+totalReserved > 100 {
+		return fmt.Errorf("queue_full_threshold (%d) and reserved_capacity (%d) must not sum to more than 100",
+			cfg.QueueFullThreshold, totalReserved)
+	}
+
+	// Validate memory admission threshold
+	if cfg.MemoryAdmissionThreshold < 0 || cfg.MemoryAdmissionThreshold > 100 {
+		return fmt.Errorf("memory_admission_threshold must be between 0 and 100")
+	}
+
+	if cfg.BackpressureAfterOverflows < 0 {
+		return fmt.Errorf("backpressure_after_overflows must not be negative")
+	}
+
+	if cfg.MaxItemAgeSec < 0 {
+		return fmt.Errorf("max_item_age_sec must not be negative")
+	}
+
+	// Set default overflow rate window and alert duration if not specified
+	if cfg.OverflowRateWindowSec <= 0 {
+		cfg.OverflowRateWindowSec = 60
+	}
+	if cfg.OverflowAlert.ThresholdPerSec < 0 {
+		return fmt.Errorf("overflow_alert threshold_per_sec must not be negative")
+	}
+	if cfg.OverflowAlert.DurationSec <= 0 {
+		cfg.OverflowAlert.DurationSec = 60
+	}
+
 	// Set default overflow strategy if not specified
 	if cfg.OverflowStrategy == "" {
 		cfg.OverflowStrategy = "dlq"
 	}
 
+	// Set default DLQ exporter if not specified
+	if cfg.DLQExporter == "" {
+		cfg.DLQExporter = "enhanced_dlq"
+	}
+	var dlqID component.ID
+	if err := dlqID.UnmarshalText([]byte(cfg.DLQExporter)); err != nil {
+		return fmt.Errorf("invalid dlq_exporter '%s': %w", cfg.DLQExporter, err)
+	}
+
 	// Set default circuit breaker error threshold if not specified or invalid
 	if cfg.CircuitBreakerErrorThreshold <= 0 || cfg.CircuitBreakerErrorThreshold > 100 {
 		cfg.CircuitBreakerErrorThreshold = 50
@@ -74,6 +300,18 @@ func (cfg *Config) Validate() error {
 		cfg.CircuitBreakerResetTimeout = 60
 	}
 
+	if cfg.StateMetricsIntervalSec < 0 {
+		return fmt.Errorf("state_metrics_interval_sec must not be negative")
+	}
+
+	if err := cfg.CriticalData.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.DropLog.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -84,12 +322,22 @@ func CreateDefaultConfig() component.Config {
 			"critical": 5,
 			"high":     3,
 			"normal":   1,
+			"low":      0,
 		},
-		MaxQueueSize:                10000,
-		QueueFullThreshold:          95,
-		OverflowStrategy:            "dlq",
-		CircuitBreakerEnabled:       true,
+		ReplayPriority:               "low",
+		ServiceWindow:                100,
+		MaxBufferedTraces:            10000,
+		LogSeverityPriorities:        defaultSeverityPriorities(),
+		MaxQueueSize:                 10000,
+		QueueFullThreshold:           95,
+		OverflowRateWindowSec:        60,
+		OverflowAlert:                OverflowAlertConfig{DurationSec: 60},
+		OverflowStrategy:             "dlq",
+		DLQExporter:                  "enhanced_dlq",
+		CircuitBreakerEnabled:        true,
 		CircuitBreakerErrorThreshold: 50,
 		CircuitBreakerResetTimeout:   60,
+		CriticalData:                 priority.DefaultConfig(),
+		DropLog:                      droplog.DefaultConfig(),
 	}
 }
@@ -0,0 +1,22 @@
+package adaptivepriorityqueue
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBogusPriorityNameRejected(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.Priorities = map[string]int{"critical": 5, "high": 3, "normal": 1, "urgent": 8}
+
+	err := config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "unknown priority 'urgent'") {
+		t.Fatalf("expected the bogus priority to be rejected, got %v", err)
+	}
+
+	// The known priorities alone are accepted
+	delete(config.Priorities, "urgent")
+	if err := config.Validate(); err != nil {
+		t.Fatalf("expected the known priorities to be accepted, got %v", err)
+	}
+}
@@ -32,7 +32,7 @@ func createMetricsProcessor(
 	nextConsumer consumer.Metrics,
 ) (processor.Metrics, error) {
 	processorConfig := cfg.(*Config)
-	return newMetricsProcessor(ctx, set.Logger, processorConfig, nextConsumer)
+	return newMetricsProcessor(ctx, set.Logger, processorConfig, set.ID, nextConsumer)
 }
 
 // createTracesProcessor creates a new traces processor based on the config.
@@ -43,7 +43,7 @@ func createTracesProcessor(
 	nextConsumer consumer.Traces,
 ) (processor.Traces, error) {
 	processorConfig := cfg.(*Config)
-	return newTracesProcessor(ctx, set.Logger, processorConfig, nextConsumer)
+	return newTracesProcessor(ctx, set.Logger, processorConfig, set.ID, nextConsumer)
 }
 
 // createLogsProcessor creates a new logs processor based on the config.
@@ -54,5 +54,5 @@ func createLogsProcessor(
 	nextConsumer consumer.Logs,
 ) (processor.Logs, error) {
 	processorConfig := cfg.(*Config)
-	return newLogsProcessor(ctx, set.Logger, processorConfig, nextConsumer)
+	return newLogsProcessor(ctx, set.Logger, processorConfig, set.ID, nextConsumer)
 }
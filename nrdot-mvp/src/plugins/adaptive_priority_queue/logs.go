@@ -0,0 +1,274 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/enhanced_dlq"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/health"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
+)
+
+// logsProcessor is the processor for applying priority queuing to logs.
+type logsProcessor struct {
+	id           component.ID
+	logger       *zap.Logger
+	config       *Config
+	nextConsumer consumer.Logs
+	queue        *AdaptivePriorityQueue
+	dlqHandler   *logsDLQHandler
+	dlqExporter  OverflowHandler
+	dropHandler  *dropOverflowHandler
+
+	// Assigns log records a priority from their severity
+	classifier *severityClassifier
+
+	// Stops the worker and waits for it to exit
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Unpublishes the queue's outcomes from the health registry
+	unregisterHealth func()
+
+	// Unpublishes the queue's in-flight bytes from the health registry
+	unregisterInFlight func()
+
+	// Publishes the queue's overflow rate, circuit breaker state and
+	// stale and dropped item counts
+	metrics *promreg.Registry
+}
+
+// newLogsProcessor creates a new logs processor for priority queuing.
+func newLogsProcessor(
+	ctx context.Context,
+	logger *zap.Logger,
+	config *Config,
+	id component.ID,
+	nextConsumer consumer.Logs,
+) (*logsProcessor, error) {
+	// Create the overflow handlers, the DLQ exporter is resolved in Start
+	dropHandler := &dropOverflowHandler{}
+	dlqHandler := &logsDLQHandler{
+		logger: logger,
+		drop:   dropHandler,
+	}
+
+	p := &logsProcessor{
+		id:           id,
+		logger:       logger,
+		config:       config,
+		nextConsumer: nextConsumer,
+		dlqHandler:   dlqHandler,
+		dropHandler:  dropHandler,
+		classifier:   newSeverityClassifier(config),
+	}
+
+	p.dlqExporter = config.overflowHandler(dlqHandler, dropHandler)
+
+	// Create the priority queue
+	p.queue = NewAdaptivePriorityQueue(logger, config, p.dlqExporter)
+
+	// Start the worker to process queued items
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.wg.Add(1)
+	go p.worker(ctx)
+
+	return p, nil
+}
+
+// ConsumeLogs splits the logs by the priority of their severity and enqueues
+// each part to be processed based on priority.
+func (p *logsProcessor) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	// Replayed logs are queued whole at the replay priority
+	if enhanceddlq.IsReplay(ctx) {
+		return p.enqueue(ctx, ld, PriorityLevel(p.config.ReplayPriority))
+	}
+
+	batches := p.classifier.split(ld)
+
+	// Enqueue higher priorities first
+	for _, priority := range priorityOrder {
+		if batch, exists := batches[priority]; exists {
+			if err := p.enqueue(ctx, batch, priority); err != nil {
+				return err
+			}
+			delete(batches, priority)
+		}
+	}
+
+	// Priorities outside the built-in levels are enqueued last
+	for priority, batch := range batches {
+		if err := p.enqueue(ctx, batch, priority); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enqueue adds logs to the queue, or sends them to the DLQ while the circuit
+// breaker is open.
+func (p *logsProcessor) enqueue(ctx context.Context, ld plog.Logs, priority PriorityLevel) error {
+	if p.queue.IsCircuitOpen() {
+		// Circuit is open, send directly to DLQ
+		item := &QueueItem{
+			Value:    ld,
+			Priority: priority,
+			Added:    p.queue.clock.Now(),
+		}
+		return p.dlqExporter.HandleOverflow(ctx, item)
+	}
+
+	// Push back on the receiver once overflow has been sustained
+	if p.queue.Backpressured() {
+		return ErrBackpressure
+	}
+
+	// Failed enqueues are already handled by the overflow handler, except
+	// for replayed data
+	if !p.queue.Enqueue(ctx, ld, priority) && enhanceddlq.IsReplay(ctx) {
+		return errReplayOverflow
+	}
+	return nil
+}
+
+// worker processes items from the queue and forwards them to the next consumer.
+func (p *logsProcessor) worker(ctx context.Context) {
+	defer p.wg.Done()
+
+	// The item being forwarded is finished even once shutdown has started
+	consumeCtx := context.WithoutCancel(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			// Dequeue the next item
+			item := p.queue.Dequeue()
+			if item == nil {
+				// Queue is empty, wait a bit before trying again
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+				}
+				continue
+			}
+
+			// Forward to the next consumer
+			err := p.nextConsumer.ConsumeLogs(consumeCtx, item.Value.(plog.Logs))
+			if err != nil {
+				p.logger.Error("Failed to process logs", zap.Error(err))
+				p.queue.RecordError()
+			} else {
+				p.queue.RecordSuccess()
+			}
+		}
+	}
+}
+
+// Capabilities returns the capabilities of the processor.
+func (p *logsProcessor) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: false}
+}
+
+// Start publishes the queue outcomes and resolves the DLQ exporter that
+// overflowed logs are written to.
+func (p *logsProcessor) Start(_ context.Context, host component.Host) error {
+	// Publish the queue's outcomes so a degradation manager can track the
+	// backend error rate
+	p.unregisterHealth = health.Register(p.id.String(), p.queue)
+	p.unregisterInFlight = health.RegisterInFlight(p.id.String(), p.queue)
+	p.metrics = promreg.New(p.logger, prometheus.Labels{"processor": p.id.String(), "signal": "logs"})
+	registerOverflowRateGauge(p.metrics, p.queue)
+	registerCircuitStateGauge(p.metrics, p.queue)
+	registerStaleCounter(p.metrics, p.queue)
+	registerOverflowDroppedCounter(p.metrics, p.dropHandler)
+
+	// Without the dlq strategy, the exporter is still needed for critical
+	// items when they must never be dropped
+	if p.config.OverflowStrategy != "dlq" {
+		if !p.config.CriticalData.NeverDrop {
+			return nil
+		}
+		p.dlqHandler.criticalOnly = true
+	}
+
+	var id component.ID
+	if err := id.UnmarshalText([]byte(p.config.DLQExporter)); err != nil {
+		return fmt.Errorf("invalid dlq_exporter '%s': %w", p.config.DLQExporter, err)
+	}
+
+	exp, exists := host.GetExporters()[component.DataTypeLogs][id]
+	if !exists {
+		return fmt.Errorf("dlq_exporter '%s' is not configured as a logs exporter", p.config.DLQExporter)
+	}
+
+	logsExporter, ok := exp.(consumer.Logs)
+	if !ok {
+		return fmt.Errorf("dlq_exporter '%s' does not accept logs", p.config.DLQExporter)
+	}
+
+	p.dlqHandler.exporter = logsExporter
+	return nil
+}
+
+// Shutdown stops the worker and waits for it to finish forwarding its
+// current item.
+func (p *logsProcessor) Shutdown(ctx context.Context) error {
+	if p.unregisterHealth != nil {
+		p.unregisterHealth()
+	}
+	if p.unregisterInFlight != nil {
+		p.unregisterInFlight()
+	}
+	if p.metrics != nil {
+		p.metrics.Unregister()
+	}
+
+	p.cancel()
+	return waitForWorkers(ctx, &p.wg)
+}
+
+// logsDLQHandler handles logs overflow by sending them to a DLQ.
+type logsDLQHandler struct {
+	logger   *zap.Logger
+	exporter consumer.Logs
+
+	// Only critical items are written to the DLQ, the rest are passed to
+	// drop
+	criticalOnly bool
+	drop         *dropOverflowHandler
+}
+
+// HandleOverflow implements the OverflowHandler interface.
+func (h *logsDLQHandler) HandleOverflow(ctx context.Context, item *QueueItem) error {
+	if enhanceddlq.IsReplay(ctx) {
+		return errReplayOverflow
+	}
+	if h.exporter == nil {
+		return fmt.Errorf("no DLQ exporter available for overflowed logs")
+	}
+	if h.criticalOnly && item.Priority != PriorityCritical {
+		return h.drop.HandleOverflow(ctx, item)
+	}
+
+	h.logger.Debug("Sending logs to DLQ",
+		zap.String("priority", string(item.Priority)),
+		zap.Time("added", item.Added),
+	)
+
+	// Carry the priority so the DLQ can replay higher priorities first
+	ctx = enhanceddlq.ContextWithPriority(ctx, string(item.Priority))
+	return h.exporter.ConsumeLogs(ctx, item.Value.(plog.Logs))
+}
@@ -0,0 +1,62 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// fixedMemory reports a fixed memory utilization.
+type fixedMemory struct {
+	utilization float64
+	limited     bool
+}
+
+func (m *fixedMemory) MemoryUtilization() float64 { return m.utilization }
+
+func (m *fixedMemory) MemoryLimited() bool { return m.limited }
+
+// overflowCounter counts the items handed to it on overflow.
+type overflowCounter struct {
+	items int
+}
+
+func (h *overflowCounter) HandleOverflow(context.Context, *QueueItem) error {
+	h.items++
+	return nil
+}
+
+func TestMemoryAdmissionRefusesAboveThreshold(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.MemoryAdmissionThreshold = 80
+	overflow := &overflowCounter{}
+	q := NewAdaptivePriorityQueue(zap.NewNop(), config, overflow)
+
+	memory := &fixedMemory{utilization: 85, limited: true}
+	q.SetMemorySource(memory)
+	if q.Enqueue(context.Background(), "critical", PriorityCritical) {
+		t.Fatal("expected the item to be refused above the memory threshold")
+	}
+	if overflow.items != 1 {
+		t.Fatalf("expected the refused item to go to the overflow strategy, got %d", overflow.items)
+	}
+
+	memory.utilization = 50
+	if !q.Enqueue(context.Background(), "normal", PriorityNormal) {
+		t.Fatal("expected the item to be queued below the memory threshold")
+	}
+}
+
+func TestMemoryAdmissionNeedsMemoryLimit(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.MemoryAdmissionThreshold = 80
+	q := NewAdaptivePriorityQueue(zap.NewNop(), config, &overflowCounter{})
+
+	// Without a soft limit, utilization is relative to memory obtained from
+	// the OS and says nothing about running out
+	q.SetMemorySource(&fixedMemory{utilization: 95})
+	if !q.Enqueue(context.Background(), "normal", PriorityNormal) {
+		t.Fatal("expected the item to be queued when no memory limit is set")
+	}
+}
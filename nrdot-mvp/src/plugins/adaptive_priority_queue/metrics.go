@@ -2,20 +2,46 @@ package adaptivepriorityqueue
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.uber.org/zap"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/enhanced_dlq"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/health"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
 )
 
 // metricsProcessor is the processor for applying priority queuing to metrics.
 type metricsProcessor struct {
+	id           component.ID
 	logger       *zap.Logger
 	config       *Config
 	nextConsumer consumer.Metrics
 	queue        *AdaptivePriorityQueue
+	dlqHandler   *metricsDLQHandler
 	dlqExporter  OverflowHandler
+	dropHandler  *dropOverflowHandler
+
+	// Stops the worker and waits for it to finish its current item
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Unpublishes the queue's outcomes from the health registry
+	unregisterHealth func()
+
+	// Unpublishes the queue's in-flight bytes from the health registry
+	unregisterInFlight func()
+
+	// Publishes the queue's overflow rate, circuit breaker state and
+	// stale and dropped item counts
+	metrics *promreg.Registry
 }
 
 // newMetricsProcessor creates a new metrics processor for priority queuing.
@@ -23,68 +49,96 @@ func newMetricsProcessor(
 	ctx context.Context,
 	logger *zap.Logger,
 	config *Config,
+	id component.ID,
 	nextConsumer consumer.Metrics,
 ) (*metricsProcessor, error) {
-	// Create the DLQ overflow handler
+	// Create the overflow handlers, the DLQ exporter is resolved in Start
+	dropHandler := &dropOverflowHandler{}
 	dlqHandler := &metricsDLQHandler{
 		logger: logger,
-		// The actual DLQ exporter would be injected here
+		drop:   dropHandler,
 	}
-	
+
 	p := &metricsProcessor{
+		id:           id,
 		logger:       logger,
 		config:       config,
 		nextConsumer: nextConsumer,
-		dlqExporter:  dlqHandler,
+		dlqHandler:   dlqHandler,
+		dropHandler:  dropHandler,
 	}
-	
+
+	p.dlqExporter = config.overflowHandler(dlqHandler, dropHandler)
+
 	// Create the priority queue
 	p.queue = NewAdaptivePriorityQueue(logger, config, p.dlqExporter)
-	
+
 	// Start the worker to process queued items
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.wg.Add(1)
 	go p.worker(ctx)
-	
+
+	// Emit snapshots of the queue state through the pipeline if enabled
+	if config.StateMetricsIntervalSec > 0 {
+		p.wg.Add(1)
+		go p.stateLoop(ctx)
+	}
+
 	return p, nil
 }
 
 // ConsumeMetrics enqueues metrics to be processed based on priority.
 func (p *metricsProcessor) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
-	// Determine the priority based on the metrics content
-	priority := p.determinePriority(md)
-	
+	// Determine the priority based on the metrics content, or whether they
+	// are replayed
+	priority := p.config.queuePriority(ctx, p.determinePriority(md))
+
 	// Check if the circuit breaker is open
 	if p.queue.IsCircuitOpen() {
 		// Circuit is open, send directly to DLQ
 		item := &QueueItem{
 			Value:    md,
 			Priority: priority,
-			Added:    time.Now(),
+			Added:    p.queue.clock.Now(),
 		}
 		return p.dlqExporter.HandleOverflow(ctx, item)
 	}
-	
+
+	// Push back on the receiver once overflow has been sustained
+	if p.queue.Backpressured() {
+		return ErrBackpressure
+	}
+
 	// Try to enqueue the metrics
 	if !p.queue.Enqueue(ctx, md, priority) {
-		// Failed to enqueue, already handled by overflow handler
+		// Failed to enqueue, already handled by overflow handler unless the
+		// metrics are replayed
+		if enhanceddlq.IsReplay(ctx) {
+			return errReplayOverflow
+		}
 		return nil
 	}
-	
+
 	// Successfully enqueued
 	return nil
 }
 
 // determinePriority determines the priority of the metrics.
 func (p *metricsProcessor) determinePriority(md pmetric.Metrics) PriorityLevel {
-	// Implementation placeholder
-	// This would analyze the metrics to determine their priority
-	// For example, based on resource attributes, metric names, or other criteria
-	
-	// Default implementation: assign normal priority
+	// Use the highest priority carried by the resources, normal if none does
+	if priority := p.config.CriticalData.MetricsPriority(md); priority != "" {
+		return PriorityLevel(priority)
+	}
 	return PriorityNormal
 }
 
 // worker processes items from the queue and forwards them to the next consumer.
 func (p *metricsProcessor) worker(ctx context.Context) {
+	defer p.wg.Done()
+
+	// The item being forwarded is finished even once shutdown has started
+	consumeCtx := context.WithoutCancel(ctx)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -94,15 +148,19 @@ func (p *metricsProcessor) worker(ctx context.Context) {
 			item := p.queue.Dequeue()
 			if item == nil {
 				// Queue is empty, wait a bit before trying again
-				time.Sleep(10 * time.Millisecond)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+				}
 				continue
 			}
-			
+
 			// Process the item
 			md := item.Value.(pmetric.Metrics)
-			
+
 			// Forward to the next consumer
-			err := p.nextConsumer.ConsumeMetrics(ctx, md)
+			err := p.nextConsumer.ConsumeMetrics(consumeCtx, md)
 			if err != nil {
 				p.logger.Error("Failed to process metrics", zap.Error(err))
 				p.queue.RecordError()
@@ -118,26 +176,110 @@ func (p *metricsProcessor) Capabilities() consumer.Capabilities {
 	return consumer.Capabilities{MutatesData: false}
 }
 
-// Shutdown stops the processor.
-func (p *metricsProcessor) Shutdown(context.Context) error {
-	// No cleanup needed
+// Start publishes the queue outcomes and resolves the DLQ exporter that
+// overflowed metrics are written to.
+func (p *metricsProcessor) Start(_ context.Context, host component.Host) error {
+	// Publish the queue's outcomes so a degradation manager can track the
+	// backend error rate
+	p.unregisterHealth = health.Register(p.id.String(), p.queue)
+	p.unregisterInFlight = health.RegisterInFlight(p.id.String(), p.queue)
+	p.metrics = promreg.New(p.logger, prometheus.Labels{"processor": p.id.String(), "signal": "metrics"})
+	registerOverflowRateGauge(p.metrics, p.queue)
+	registerCircuitStateGauge(p.metrics, p.queue)
+	registerStaleCounter(p.metrics, p.queue)
+	registerOverflowDroppedCounter(p.metrics, p.dropHandler)
+
+	// Without the dlq strategy, the exporter is still needed for critical
+	// items when they must never be dropped
+	if p.config.OverflowStrategy != "dlq" {
+		if !p.config.CriticalData.NeverDrop {
+			return nil
+		}
+		p.dlqHandler.criticalOnly = true
+	}
+
+	var id component.ID
+	if err := id.UnmarshalText([]byte(p.config.DLQExporter)); err != nil {
+		return fmt.Errorf("invalid dlq_exporter '%s': %w", p.config.DLQExporter, err)
+	}
+
+	exp, exists := host.GetExporters()[component.DataTypeMetrics][id]
+	if !exists {
+		return fmt.Errorf("dlq_exporter '%s' is not configured as a metrics exporter", p.config.DLQExporter)
+	}
+
+	metricsExporter, ok := exp.(consumer.Metrics)
+	if !ok {
+		return fmt.Errorf("dlq_exporter '%s' does not accept metrics", p.config.DLQExporter)
+	}
+
+	p.dlqHandler.exporter = metricsExporter
 	return nil
 }
 
+// Shutdown stops the worker and waits for it to finish forwarding its current
+// item, so nothing is sent to the next consumer after Shutdown returns.
+func (p *metricsProcessor) Shutdown(ctx context.Context) error {
+	if p.unregisterHealth != nil {
+		p.unregisterHealth()
+	}
+	if p.unregisterInFlight != nil {
+		p.unregisterInFlight()
+	}
+	if p.metrics != nil {
+		p.metrics.Unregister()
+	}
+
+	p.cancel()
+	return waitForWorkers(ctx, &p.wg)
+}
+
+// waitForWorkers waits for a processor's goroutines to exit, giving up when
+// the shutdown context is done.
+func waitForWorkers(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for worker to stop: %w", ctx.Err())
+	}
+}
+
 // metricsDLQHandler handles metrics overflow by sending them to a DLQ.
 type metricsDLQHandler struct {
-	logger *zap.Logger
-	// The actual DLQ exporter would be added here
+	logger   *zap.Logger
+	exporter consumer.Metrics
+
+	// Only critical items are written to the DLQ, the rest are passed to
+	// drop
+	criticalOnly bool
+	drop         *dropOverflowHandler
 }
 
 // HandleOverflow implements the OverflowHandler interface.
 func (h *metricsDLQHandler) HandleOverflow(ctx context.Context, item *QueueItem) error {
-	// This would send the metrics to the DLQ
-	// Implementation placeholder
-	h.logger.Info("Sending metrics to DLQ",
+	if enhanceddlq.IsReplay(ctx) {
+		return errReplayOverflow
+	}
+	if h.exporter == nil {
+		return fmt.Errorf("no DLQ exporter available for overflowed metrics")
+	}
+	if h.criticalOnly && item.Priority != PriorityCritical {
+		return h.drop.HandleOverflow(ctx, item)
+	}
+
+	h.logger.Debug("Sending metrics to DLQ",
 		zap.String("priority", string(item.Priority)),
 		zap.Time("added", item.Added),
 	)
-	
-	return nil
+
+	// Carry the priority so the DLQ can replay higher priorities first
+	ctx = enhanceddlq.ContextWithPriority(ctx, string(item.Priority))
+	return h.exporter.ConsumeMetrics(ctx, item.Value.(pmetric.Metrics))
 }
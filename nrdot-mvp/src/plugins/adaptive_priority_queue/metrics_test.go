@@ -0,0 +1,145 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+func TestOverflowWrittenWithPriority(t *testing.T) {
+	dlq := &metricsSink{}
+	handler := &metricsDLQHandler{logger: zap.NewNop(), exporter: dlq}
+
+	for _, priority := range []PriorityLevel{PriorityCritical, PriorityLow} {
+		if err := handler.HandleOverflow(context.Background(), &QueueItem{Value: pmetric.NewMetrics(), Priority: priority}); err != nil {
+			t.Fatalf("failed to handle %s overflow: %v", priority, err)
+		}
+	}
+
+	// The DLQ receives each batch with the priority it overflowed with
+	if dlq.batches != 2 || dlq.priorities[0] != string(PriorityCritical) || dlq.priorities[1] != string(PriorityLow) {
+		t.Fatalf("expected critical then low overflow in the DLQ, got %v", dlq.priorities)
+	}
+}
+
+// slowMetricsConsumer takes a while to consume each batch, counting the
+// batches it is still consuming and those it is given once stopped.
+type slowMetricsConsumer struct {
+	active  atomic.Int64
+	stopped atomic.Bool
+	late    atomic.Int64
+}
+
+func (c *slowMetricsConsumer) ConsumeMetrics(context.Context, pmetric.Metrics) error {
+	if c.stopped.Load() {
+		c.late.Add(1)
+	}
+	c.active.Add(1)
+	defer c.active.Add(-1)
+	time.Sleep(time.Millisecond)
+	return nil
+}
+
+func (c *slowMetricsConsumer) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{}
+}
+
+func TestShutdownUnderLoadWaitsForWorker(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.OverflowStrategy = "drop"
+	next := &slowMetricsConsumer{}
+	p, err := newMetricsProcessor(context.Background(), zap.NewNop(), config, component.NewID(typeStr), next)
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+
+	// Keep the queue busy while shutting down
+	done := make(chan struct{})
+	var producers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		producers.Add(1)
+		go func() {
+			defer producers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					p.ConsumeMetrics(context.Background(), pmetric.NewMetrics())
+				}
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("expected the worker to exit, got %v", err)
+	}
+
+	// Nothing is forwarded once Shutdown has returned, as if the next
+	// consumer had been torn down
+	next.stopped.Store(true)
+	if active := next.active.Load(); active != 0 {
+		t.Fatalf("expected no batch in flight after shutdown, got %d", active)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+	producers.Wait()
+	if late := next.late.Load(); late != 0 {
+		t.Fatalf("expected nothing to be forwarded after shutdown, got %d batches", late)
+	}
+}
+
+func TestSustainedOverflowReturnsBackpressure(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.OverflowStrategy = "drop"
+	config.MaxQueueSize = 2
+	config.QueueFullThreshold = 100
+	config.BackpressureAfterOverflows = 3
+	p, err := newMetricsProcessor(context.Background(), zap.NewNop(), config, component.NewID(typeStr), &metricsSink{})
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+
+	// Stop the worker so the queue stays full
+	p.cancel()
+	p.wg.Wait()
+
+	// Filling the queue and overflowing up to the threshold is accepted
+	for i := 0; i < 5; i++ {
+		if err := p.ConsumeMetrics(context.Background(), pmetric.NewMetrics()); err != nil {
+			t.Fatalf("expected batch %d to be accepted, got %v", i, err)
+		}
+	}
+	if got := p.queue.GetOverflowCount(); got != 3 {
+		t.Fatalf("expected 3 overflows, got %d", got)
+	}
+
+	// Past it, the receiver is pushed back on without overflowing again
+	for i := 0; i < 3; i++ {
+		if err := p.ConsumeMetrics(context.Background(), pmetric.NewMetrics()); !errors.Is(err, ErrBackpressure) {
+			t.Fatalf("expected ErrBackpressure, got %v", err)
+		}
+	}
+	if got := p.queue.GetOverflowCount(); got != 3 {
+		t.Fatalf("expected no further overflows, got %d", got)
+	}
+
+	// Backpressure lifts once the queue has room again
+	if p.queue.Dequeue() == nil {
+		t.Fatal("expected a queued item")
+	}
+	if err := p.ConsumeMetrics(context.Background(), pmetric.NewMetrics()); err != nil {
+		t.Fatalf("expected the batch to be accepted once the queue drained, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/enhanced_dlq"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
+)
+
+// dropOverflowHandler drops overflowed items and counts them. It handles
+// overflow for every strategy but "dlq", unless critical items must never be
+// dropped.
+type dropOverflowHandler struct {
+	dropped int64
+}
+
+// HandleOverflow implements the OverflowHandler interface. Dropping is the
+// configured behaviour, so it isn't an error, except for replayed items,
+// which are refused so the replay keeps them.
+func (h *dropOverflowHandler) HandleOverflow(ctx context.Context, _ *QueueItem) error {
+	if enhanceddlq.IsReplay(ctx) {
+		return errReplayOverflow
+	}
+	atomic.AddInt64(&h.dropped, 1)
+	return nil
+}
+
+// Dropped returns the number of overflowed items dropped.
+func (h *dropOverflowHandler) Dropped() int64 {
+	return atomic.LoadInt64(&h.dropped)
+}
+
+// overflowHandler returns the handler for items that overflow the queue or
+// arrive while the circuit breaker is open: the DLQ handler with the "dlq"
+// strategy or when critical items must never be dropped, and the drop
+// handler otherwise.
+func (cfg *Config) overflowHandler(dlq OverflowHandler, drop *dropOverflowHandler) OverflowHandler {
+	if cfg.OverflowStrategy == "dlq" || cfg.CriticalData.NeverDrop {
+		return dlq
+	}
+	return drop
+}
+
+// registerOverflowDroppedCounter publishes the number of overflowed items
+// dropped.
+func registerOverflowDroppedCounter(metrics *promreg.Registry, h *dropOverflowHandler) {
+	metrics.CounterFunc(prometheus.CounterOpts{
+		Name: "otelcol_adaptive_priority_queue_overflow_dropped_total",
+		Help: "Overflowed items dropped rather than written to the DLQ",
+	}, func() float64 {
+		return float64(h.Dropped())
+	})
+}
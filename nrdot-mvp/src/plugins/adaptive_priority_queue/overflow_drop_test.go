@@ -0,0 +1,59 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDropStrategyDropsOverflowWithoutError(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.OverflowStrategy = "drop"
+	config.MaxQueueSize = 1
+	config.QueueFullThreshold = 100
+	core, logs := observer.New(zapcore.WarnLevel)
+	p, err := newMetricsProcessor(context.Background(), zap.New(core), config, component.NewID(typeStr), &metricsSink{})
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+
+	// Stop the worker so the queue stays full
+	p.cancel()
+	p.wg.Wait()
+
+	// Overflow is accepted and dropped
+	for i := 0; i < 3; i++ {
+		if err := p.ConsumeMetrics(context.Background(), pmetric.NewMetrics()); err != nil {
+			t.Fatalf("expected batch %d to be accepted, got %v", i, err)
+		}
+	}
+	if got := p.dropHandler.Dropped(); got != 2 {
+		t.Fatalf("expected 2 dropped batches, got %d", got)
+	}
+
+	// So is data arriving while the circuit breaker is open
+	for i := 0; i < 10; i++ {
+		p.queue.RecordError()
+	}
+	if !p.queue.IsCircuitOpen() {
+		t.Fatal("expected the circuit breaker to open")
+	}
+	if err := p.ConsumeMetrics(context.Background(), pmetric.NewMetrics()); err != nil {
+		t.Fatalf("expected the batch to be dropped while the circuit is open, got %v", err)
+	}
+	if got := p.dropHandler.Dropped(); got != 3 {
+		t.Fatalf("expected 3 dropped batches, got %d", got)
+	}
+
+	// Dropping is the configured behaviour, not a failure
+	for _, entry := range logs.All() {
+		if entry.Level >= zapcore.ErrorLevel {
+			t.Fatalf("expected no error to be logged, got %q", entry.Message)
+		}
+	}
+}
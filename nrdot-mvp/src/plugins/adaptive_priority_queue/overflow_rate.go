@@ -0,0 +1,150 @@
+package adaptivepriorityqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
+)
+
+// overflowRate counts overflows in one-second buckets over a sliding window.
+type overflowRate struct {
+	counts  []int64
+	seconds []int64
+}
+
+// newOverflowRate creates an overflow rate over a window of the given number
+// of seconds.
+func newOverflowRate(windowSec int) *overflowRate {
+	return &overflowRate{
+		counts:  make([]int64, windowSec),
+		seconds: make([]int64, windowSec),
+	}
+}
+
+// record counts an overflow at the given time.
+func (r *overflowRate) record(now time.Time) {
+	sec := now.Unix()
+	i := int(sec % int64(len(r.counts)))
+	if r.seconds[i] != sec {
+		r.seconds[i] = sec
+		r.counts[i] = 0
+	}
+	r.counts[i]++
+}
+
+// perSecond returns the average overflows per second over the window ending
+// at the given time.
+func (r *overflowRate) perSecond(now time.Time) float64 {
+	sec := now.Unix()
+	window := int64(len(r.counts))
+
+	var total int64
+	for i, s := range r.seconds {
+		if s > sec-window && s <= sec {
+			total += r.counts[i]
+		}
+	}
+	return float64(total) / float64(window)
+}
+
+// OverflowRate returns the average overflows per second over the overflow
+// rate window.
+func (q *AdaptivePriorityQueue) OverflowRate() float64 {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.overflowRate.perSecond(q.clock.Now())
+}
+
+// SetOverflowAlertHandler replaces the function called when sustained
+// overflow crosses the alert threshold. By default the alert is logged and
+// posted to the configured webhook.
+func (q *AdaptivePriorityQueue) SetOverflowAlertHandler(handler func(rate float64)) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.overflowAlertHandler = handler
+}
+
+// recordOverflow counts an overflow and fires the alert once the rate has
+// stayed at or above the threshold for the alert duration. The alert fires
+// again only after the rate has dropped below the threshold. The caller must
+// hold the lock.
+func (q *AdaptivePriorityQueue) recordOverflow() {
+	now := q.clock.Now()
+	q.overflowRate.record(now)
+
+	alert := q.config.OverflowAlert
+	if alert.ThresholdPerSec <= 0 {
+		return
+	}
+
+	rate := q.overflowRate.perSecond(now)
+	if rate < alert.ThresholdPerSec {
+		q.overflowAboveSince = time.Time{}
+		q.overflowAlertFired = false
+		return
+	}
+
+	if q.overflowAboveSince.IsZero() {
+		q.overflowAboveSince = now
+	}
+	if q.overflowAlertFired || now.Sub(q.overflowAboveSince) < time.Duration(alert.DurationSec)*time.Second {
+		return
+	}
+
+	q.overflowAlertFired = true
+	if q.overflowAlertHandler != nil {
+		go q.overflowAlertHandler(rate)
+	}
+}
+
+// defaultOverflowAlert logs the alert and posts it to the webhook if one is
+// configured.
+func (q *AdaptivePriorityQueue) defaultOverflowAlert(rate float64) {
+	alert := q.config.OverflowAlert
+	q.logger.Warn("Sustained queue overflow",
+		zap.Float64("overflowsPerSec", rate),
+		zap.Float64("thresholdPerSec", alert.ThresholdPerSec),
+		zap.Int("durationSec", alert.DurationSec),
+	)
+
+	if alert.WebhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"alert":             "sustained_queue_overflow",
+		"overflows_per_sec": rate,
+		"threshold_per_sec": alert.ThresholdPerSec,
+		"duration_sec":      alert.DurationSec,
+	})
+	if err != nil {
+		q.logger.Error("Failed to encode overflow alert", zap.Error(err))
+		return
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(alert.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		q.logger.Error("Failed to send overflow alert", zap.Error(err))
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		q.logger.Error("Overflow alert webhook rejected the alert", zap.Int("status", resp.StatusCode))
+	}
+}
+
+// registerOverflowRateGauge publishes the queue's overflow rate.
+func registerOverflowRateGauge(metrics *promreg.Registry, q *AdaptivePriorityQueue) {
+	metrics.GaugeFunc(prometheus.GaugeOpts{
+		Name: "otelcol_adaptive_priority_queue_overflow_rate",
+		Help: "Average items per second handed to the overflow strategy over the overflow rate window",
+	}, q.OverflowRate)
+}
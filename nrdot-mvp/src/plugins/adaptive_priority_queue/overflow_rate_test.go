@@ -0,0 +1,70 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+func TestOverflowAlertFiresOnceSustained(t *testing.T) {
+	q, fake := newTestQueue(t, func(config *Config) {
+		config.MaxQueueSize = 1
+		config.QueueFullThreshold = 100
+		config.OverflowRateWindowSec = 5
+		config.OverflowAlert = OverflowAlertConfig{ThresholdPerSec: 2, DurationSec: 10}
+	})
+	q.overflowHandler = &metricsDLQHandler{logger: zap.NewNop(), exporter: &metricsSink{}}
+	alerts := make(chan float64, 10)
+	q.SetOverflowAlertHandler(func(rate float64) { alerts <- rate })
+
+	// overflowFor overflows 10 items a second for the given number of seconds
+	overflowFor := func(seconds int) {
+		for s := 0; s < seconds; s++ {
+			for i := 0; i < 10; i++ {
+				q.Enqueue(context.Background(), pmetric.NewMetrics(), PriorityNormal)
+			}
+			fake.Advance(time.Second)
+		}
+	}
+	expectNoAlert := func(when string) {
+		t.Helper()
+		select {
+		case rate := <-alerts:
+			t.Fatalf("expected no alert %s, got one at %v/s", when, rate)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	q.Enqueue(context.Background(), pmetric.NewMetrics(), PriorityNormal)
+
+	// A brief spike raises the rate without alerting
+	overflowFor(3)
+	if rate := q.OverflowRate(); rate < 2 {
+		t.Fatalf("expected the spike to raise the overflow rate to at least 2/s, got %v", rate)
+	}
+	expectNoAlert("for a brief spike")
+
+	// Once the spike has left the window, the rate is back to 0
+	fake.Advance(10 * time.Second)
+	if rate := q.OverflowRate(); rate != 0 {
+		t.Fatalf("expected the overflow rate to drop to 0, got %v", rate)
+	}
+
+	// Overflow sustained beyond the duration fires the alert once
+	overflowFor(9)
+	expectNoAlert("before the duration has passed")
+	overflowFor(6)
+	select {
+	case rate := <-alerts:
+		if rate < 2 {
+			t.Fatalf("expected the alert to report the rate above the threshold, got %v", rate)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the alert to fire once overflow was sustained")
+	}
+	overflowFor(5)
+	expectNoAlert("again while overflow stays sustained")
+}
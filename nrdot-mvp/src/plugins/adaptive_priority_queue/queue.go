@@ -3,10 +3,21 @@ package adaptivepriorityqueue
 import (
 	"container/heap"
 	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/droplog"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/sysmon"
 )
 
 // PriorityLevel represents a priority level in the queue.
@@ -16,34 +27,90 @@ const (
 	PriorityCritical PriorityLevel = "critical"
 	PriorityHigh     PriorityLevel = "high"
 	PriorityNormal   PriorityLevel = "normal"
+	PriorityLow      PriorityLevel = "low"
 )
 
+// priorityOrder lists the priority levels from highest to lowest.
+var priorityOrder = []PriorityLevel{PriorityCritical, PriorityHigh, PriorityNormal, PriorityLow}
+
+// knownPriority reports whether a priority is one of the defined levels.
+func knownPriority(priority PriorityLevel) bool {
+	for _, known := range priorityOrder {
+		if priority == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrBackpressure is returned by the processors instead of overflowing while
+// the queue has been overflowing for longer than the backpressure threshold,
+// so receivers push back on their senders.
+var ErrBackpressure = errors.New("priority queue is full, retry later")
+
 // QueueItem represents an item in the priority queue.
 type QueueItem struct {
 	Value    interface{}
 	Priority PriorityLevel
 	Index    int
 	Added    time.Time
+	Size     int // estimated size in bytes
 }
 
 // AdaptivePriorityQueue implements a weighted round-robin priority queue.
 type AdaptivePriorityQueue struct {
-	logger            *zap.Logger
-	config            *Config
-	items             []*QueueItem
-	lock              sync.RWMutex
-	priorityWeights   map[PriorityLevel]int
-	currentRound      int
-	roundSelections   map[PriorityLevel]int
-	circuitOpen       bool
-	lastCircuitTrip   time.Time
-	successCount      int64
-	errorCount        int64
-	circuitLock       sync.RWMutex
-	overflowHandler   OverflowHandler
-	overflowCount     int64
-	processedCount    map[PriorityLevel]int64
-	processedCountMux sync.Mutex
+	logger          *zap.Logger
+	config          *Config
+	clock           clock.Clock
+	items           []*QueueItem
+	lock            sync.RWMutex
+	priorityWeights map[PriorityLevel]int
+	currentRound    int
+	roundSelections map[PriorityLevel]int
+	circuitState    CircuitState
+	lastCircuitTrip time.Time
+	successCount    int64
+	errorCount      int64
+	circuitLock     sync.RWMutex
+	totalSuccesses  int64
+	totalErrors     int64
+	overflowHandler OverflowHandler
+	overflowCount   int64
+
+	// Items enqueued with a priority that has no weight, queued as normal
+	unknownPriorityCount int64
+
+	// Items discarded for waiting longer than MaxItemAgeSec
+	staleCount int64
+
+	// Estimated size of the queued items, in bytes
+	queuedBytes int64
+
+	// Overflows since an item was last queued, used to apply backpressure
+	consecutiveOverflows int
+
+	// Sliding window of overflows, and the sustained overflow alert state
+	overflowRate         *overflowRate
+	overflowAboveSince   time.Time
+	overflowAlertFired   bool
+	overflowAlertHandler func(rate float64)
+	processedCount       map[PriorityLevel]int64
+	processedCountMux    sync.Mutex
+
+	// Minimum service ratio enforcement over a sliding window of dequeues
+	minServiceRatios map[PriorityLevel]float64
+	serviceWindow    []PriorityLevel
+	serviceWindowPos int
+	serviceWindowLen int
+	serviceCounts    map[PriorityLevel]int
+
+	// Sampled log of items lost on overflow, nil if disabled
+	dropLog *droplog.Logger
+
+	// Memory signal consulted before admitting items, guarded by memoryLock
+	// so it's read without holding the queue lock
+	memory     sysmon.MemorySource
+	memoryLock sync.Mutex
 }
 
 // OverflowHandler defines the interface for handling queue overflow.
@@ -59,15 +126,30 @@ func NewAdaptivePriorityQueue(logger *zap.Logger, config *Config, overflowHandle
 		priorityWeights[PriorityLevel(k)] = v
 	}
 
+	minServiceRatios := make(map[PriorityLevel]float64, len(config.MinServiceRatios))
+	for k, v := range config.MinServiceRatios {
+		if v > 0 {
+			minServiceRatios[PriorityLevel(k)] = v
+		}
+	}
+
 	q := &AdaptivePriorityQueue{
-		logger:          logger,
-		config:          config,
-		items:           make([]*QueueItem, 0, config.MaxQueueSize),
-		priorityWeights: priorityWeights,
-		roundSelections: make(map[PriorityLevel]int),
-		overflowHandler: overflowHandler,
-		processedCount:  make(map[PriorityLevel]int64),
+		logger:           logger,
+		config:           config,
+		clock:            clock.Real(),
+		items:            make([]*QueueItem, 0, config.MaxQueueSize),
+		priorityWeights:  priorityWeights,
+		roundSelections:  make(map[PriorityLevel]int),
+		overflowHandler:  overflowHandler,
+		processedCount:   make(map[PriorityLevel]int64),
+		minServiceRatios: minServiceRatios,
+		serviceWindow:    make([]PriorityLevel, config.ServiceWindow),
+		serviceCounts:    make(map[PriorityLevel]int),
+		dropLog:          droplog.New(logger, typeStr, config.DropLog),
+		memory:           sysmon.Shared(),
+		overflowRate:     newOverflowRate(config.OverflowRateWindowSec),
 	}
+	q.overflowAlertHandler = q.defaultOverflowAlert
 
 	// Initialize selection counters
 	for priority := range priorityWeights {
@@ -77,72 +159,267 @@ func NewAdaptivePriorityQueue(logger *zap.Logger, config *Config, overflowHandle
 	return q
 }
 
+// SetClock replaces the clock used for item timestamps and the circuit breaker.
+func (q *AdaptivePriorityQueue) SetClock(c clock.Clock) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.circuitLock.Lock()
+	defer q.circuitLock.Unlock()
+	q.clock = c
+	q.dropLog.SetClock(c)
+}
+
+// SetMemorySource replaces the memory signal used for admission control.
+func (q *AdaptivePriorityQueue) SetMemorySource(m sysmon.MemorySource) {
+	q.memoryLock.Lock()
+	defer q.memoryLock.Unlock()
+	q.memory = m
+}
+
+// memoryExhausted returns whether memory use is above the admission
+// threshold. Without a soft memory limit there is no limit to be near, so
+// nothing is refused. The memory source samples at most once a second, and
+// the queue lock must not be held, so that sampling never blocks the queue.
+func (q *AdaptivePriorityQueue) memoryExhausted() bool {
+	threshold := q.config.MemoryAdmissionThreshold
+	if threshold <= 0 {
+		return false
+	}
+
+	q.memoryLock.Lock()
+	memory := q.memory
+	q.memoryLock.Unlock()
+	return memory.MemoryLimited() && memory.MemoryUtilization() >= float64(threshold)
+}
+
+// refusing returns whether new items of a priority would be refused,
+// because memory is nearly exhausted or the queue is full up to the
+// priority's limit. The caller must hold the lock.
+func (q *AdaptivePriorityQueue) refusing(priority PriorityLevel, memoryExhausted bool) bool {
+	return memoryExhausted || len(q.items) >= int(float64(q.config.MaxQueueSize)*float64(q.fullPercent(priority))/100.0)
+}
+
+// fullPercent returns the percentage of the queue items of a priority may
+// fill: the queue full threshold plus the capacity reserved for the
+// priority and every priority below it.
+func (q *AdaptivePriorityQueue) fullPercent(priority PriorityLevel) int {
+	percent := q.config.QueueFullThreshold
+	below := false
+	for _, level := range priorityOrder {
+		if level == priority {
+			below = true
+		}
+		if below {
+			percent += q.config.ReservedCapacity[string(level)]
+		}
+	}
+	return percent
+}
+
+// Backpressured returns whether the queue has overflowed on at least
+// BackpressureAfterOverflows consecutive enqueues and would still refuse new
+// normal items, in which case callers should return ErrBackpressure rather
+// than enqueue.
+func (q *AdaptivePriorityQueue) Backpressured() bool {
+	if q.config.BackpressureAfterOverflows <= 0 {
+		return false
+	}
+
+	exhausted := q.memoryExhausted()
+
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.consecutiveOverflows >= q.config.BackpressureAfterOverflows && q.refusing(PriorityNormal, exhausted)
+}
+
 // Enqueue adds an item to the queue with the specified priority.
-// Returns true if the item was added, false if it was rejected due to overflow.
+// Returns true if the item was added, false if it was rejected due to overflow
+// or memory pressure.
 func (q *AdaptivePriorityQueue) Enqueue(ctx context.Context, value interface{}, priority PriorityLevel) bool {
+	// Size the value and check memory before taking the lock, since both
+	// can take a while
+	size := itemSize(value)
+	exhausted := q.memoryExhausted()
+
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	// Check if queue is full
-	if len(q.items) >= int(float64(q.config.MaxQueueSize)*float64(q.config.QueueFullThreshold)/100.0) {
+	// Priorities without a weight would never be scheduled, so queue them as
+	// normal and count them to surface the misconfiguration
+	if _, exists := q.priorityWeights[priority]; !exists && priority != PriorityNormal {
+		if q.unknownPriorityCount == 0 {
+			q.logger.Warn("Queueing items with an unknown priority as normal",
+				zap.String("priority", string(priority)),
+			)
+		}
+		q.unknownPriorityCount++
+		priority = PriorityNormal
+	}
+
+	// Refuse every priority when memory is nearly exhausted, and check if
+	// queue is full for this priority
+	if q.refusing(priority, exhausted) {
 		// Queue is nearly full, apply overflow strategy
 		item := &QueueItem{
 			Value:    value,
 			Priority: priority,
-			Added:    time.Now(),
+			Added:    q.clock.Now(),
 		}
 
 		q.lock.Unlock() // Unlock before handling overflow
 		err := q.overflowHandler.HandleOverflow(ctx, item)
 		q.lock.Lock() // Lock again before returning
 
-		if err != nil {
+		// Replayed data isn't lost, the replay keeps it and counts a failure
+		if err != nil && !errors.Is(err, errReplayOverflow) {
 			q.logger.Error("Failed to handle queue overflow", zap.Error(err))
+			q.dropLog.LogData("overflow", value)
 		}
 
 		q.overflowCount++
+		q.consecutiveOverflows++
+		q.recordOverflow()
 		return false
 	}
+	q.consecutiveOverflows = 0
 
 	// Add item to the queue
 	item := &QueueItem{
 		Value:    value,
 		Priority: priority,
-		Index:    len(q.items),
-		Added:    time.Now(),
+		Added:    q.clock.Now(),
+		Size:     size,
 	}
-	q.items = append(q.items, item)
 	heap.Push(q, item)
+	q.queuedBytes += int64(item.Size)
 	return true
 }
 
 // Dequeue removes and returns the next item from the queue based on WRR scheduling.
-// Returns nil if the queue is empty.
+// Returns nil if the queue is empty. Items that waited longer than
+// MaxItemAgeSec are discarded as they come up instead of being returned.
 func (q *AdaptivePriorityQueue) Dequeue() *QueueItem {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
+	for {
+		item := q.take()
+		if item == nil {
+			return nil
+		}
+		if q.isStale(item) {
+			q.staleCount++
+			q.dropLog.LogData("stale", item.Value)
+			continue
+		}
+		q.incrementProcessedCount(item.Priority)
+		q.recordService(item.Priority)
+		return item
+	}
+}
+
+// take removes the next item from the queue based on WRR scheduling, or
+// returns nil if the queue is empty. The caller must hold the lock.
+func (q *AdaptivePriorityQueue) take() *QueueItem {
 	if len(q.items) == 0 {
 		return nil
 	}
 
-	// Determine which priority to dequeue based on WRR scheduling
-	priority := q.selectNextPriority()
+	// Service a priority that is below its minimum service ratio first,
+	// otherwise determine which priority to dequeue based on WRR scheduling
+	priority, starved := q.selectStarvedPriority()
+	if !starved {
+		priority = q.selectNextPriority()
+	}
 
 	// Find and remove the first item with the selected priority
 	for i, item := range q.items {
 		if item.Priority == priority {
-			q.incrementProcessedCount(priority)
+			q.queuedBytes -= int64(item.Size)
 			return heap.Remove(q, i).(*QueueItem)
 		}
 	}
 
 	// If no item with the selected priority is found, dequeue the highest priority item
 	item := heap.Pop(q).(*QueueItem)
-	q.incrementProcessedCount(item.Priority)
+	q.queuedBytes -= int64(item.Size)
 	return item
 }
 
+// isStale returns whether an item has waited longer than MaxItemAgeSec.
+// Critical items are never stale when the critical data policy never drops
+// them. The caller must hold the lock.
+func (q *AdaptivePriorityQueue) isStale(item *QueueItem) bool {
+	if q.config.MaxItemAgeSec <= 0 {
+		return false
+	}
+	if item.Priority == PriorityCritical && q.config.CriticalData.NeverDrop {
+		return false
+	}
+	return q.clock.Since(item.Added) > time.Duration(q.config.MaxItemAgeSec)*time.Second
+}
+
+// registerStaleCounter publishes the number of items the queue discarded as
+// stale.
+func registerStaleCounter(metrics *promreg.Registry, q *AdaptivePriorityQueue) {
+	metrics.CounterFunc(prometheus.CounterOpts{
+		Name: "otelcol_adaptive_priority_queue_stale_dropped_total",
+		Help: "Items discarded for waiting longer than max_item_age_sec",
+	}, func() float64 {
+		return float64(q.GetStaleCount())
+	})
+}
+
+// selectStarvedPriority returns the highest priority level with queued items
+// that has received less than its minimum service ratio over the window.
+func (q *AdaptivePriorityQueue) selectStarvedPriority() (PriorityLevel, bool) {
+	if len(q.minServiceRatios) == 0 {
+		return "", false
+	}
+
+	for _, priority := range priorityOrder {
+		ratio, exists := q.minServiceRatios[priority]
+		if !exists || !q.hasItems(priority) {
+			continue
+		}
+
+		// Nothing has been served yet, or the priority is below its floor
+		if q.serviceWindowLen == 0 || float64(q.serviceCounts[priority])/float64(q.serviceWindowLen) < ratio {
+			return priority, true
+		}
+	}
+
+	return "", false
+}
+
+// hasItems returns whether the queue holds any item with the given priority.
+func (q *AdaptivePriorityQueue) hasItems(priority PriorityLevel) bool {
+	for _, item := range q.items {
+		if item.Priority == priority {
+			return true
+		}
+	}
+	return false
+}
+
+// recordService records a dequeue in the sliding service window.
+func (q *AdaptivePriorityQueue) recordService(priority PriorityLevel) {
+	if len(q.serviceWindow) == 0 {
+		return
+	}
+
+	// Evict the oldest entry once the window is full
+	if q.serviceWindowLen == len(q.serviceWindow) {
+		q.serviceCounts[q.serviceWindow[q.serviceWindowPos]]--
+	} else {
+		q.serviceWindowLen++
+	}
+
+	q.serviceWindow[q.serviceWindowPos] = priority
+	q.serviceCounts[priority]++
+	q.serviceWindowPos = (q.serviceWindowPos + 1) % len(q.serviceWindow)
+}
+
 // selectNextPriority selects the next priority level based on WRR scheduling.
 func (q *AdaptivePriorityQueue) selectNextPriority() PriorityLevel {
 	// Reset round if all selections have been made
@@ -163,7 +440,6 @@ func (q *AdaptivePriorityQueue) selectNextPriority() PriorityLevel {
 
 	// Select the highest priority level that hasn't used up its allocation
 	var selectedPriority PriorityLevel
-	priorityOrder := []PriorityLevel{PriorityCritical, PriorityHigh, PriorityNormal}
 
 	for _, priority := range priorityOrder {
 		weight := q.priorityWeights[priority]
@@ -183,67 +459,81 @@ func (q *AdaptivePriorityQueue) selectNextPriority() PriorityLevel {
 	return selectedPriority
 }
 
-// IsCircuitOpen returns whether the circuit breaker is open.
+// IsCircuitOpen returns whether the circuit breaker is open. Once the reset
+// timeout has passed the circuit is half-open and lets data through again.
 func (q *AdaptivePriorityQueue) IsCircuitOpen() bool {
-	q.circuitLock.RLock()
-	defer q.circuitLock.RUnlock()
-	
-	// Check if the circuit is open and if the reset timeout has passed
-	if q.circuitOpen && time.Since(q.lastCircuitTrip) > time.Duration(q.config.CircuitBreakerResetTimeout)*time.Second {
-		// Reset the circuit (will be done properly by RecordSuccess/RecordError)
-		q.circuitLock.RUnlock()
-		q.circuitLock.Lock()
-		q.circuitOpen = false
-		q.successCount = 0
-		q.errorCount = 0
-		q.circuitLock.Unlock()
-		q.circuitLock.RLock()
-	}
-	
-	return q.circuitOpen
+	return q.CircuitState() == CircuitOpen
 }
 
 // RecordSuccess records a successful operation for the circuit breaker.
 func (q *AdaptivePriorityQueue) RecordSuccess() {
+	atomic.AddInt64(&q.totalSuccesses, 1)
+
 	if !q.config.CircuitBreakerEnabled {
 		return
 	}
-	
+
 	q.circuitLock.Lock()
 	defer q.circuitLock.Unlock()
-	
+
 	q.successCount++
-	
-	// Reset the circuit if it was previously open
-	if q.circuitOpen && time.Since(q.lastCircuitTrip) > time.Duration(q.config.CircuitBreakerResetTimeout)*time.Second {
-		q.circuitOpen = false
+
+	// Close the circuit once a request succeeds after the reset timeout
+	q.halfOpenIfDue()
+	if q.circuitState == CircuitHalfOpen {
 		q.successCount = 1
 		q.errorCount = 0
+		q.setCircuitState(CircuitClosed)
 	}
 }
 
 // RecordError records an error for the circuit breaker.
 func (q *AdaptivePriorityQueue) RecordError() {
+	atomic.AddInt64(&q.totalErrors, 1)
+
 	if !q.config.CircuitBreakerEnabled {
 		return
 	}
-	
+
 	q.circuitLock.Lock()
 	defer q.circuitLock.Unlock()
-	
+
 	q.errorCount++
-	
-	// Check if we need to trip the circuit
+
 	total := q.successCount + q.errorCount
-	if total >= 10 { // Need a minimum number of requests before tripping
-		errorPercentage := float64(q.errorCount) / float64(total) * 100.0
+	errorPercentage := float64(q.errorCount) / float64(total) * 100.0
+
+	// A failure while half-open opens the circuit again straight away
+	q.halfOpenIfDue()
+	if q.circuitState == CircuitHalfOpen {
+		q.setCircuitState(CircuitOpen,
+			zap.Float64("error_percentage", errorPercentage),
+			zap.Int64("errors", q.errorCount),
+			zap.Int64("requests", total),
+		)
+		return
+	}
+
+	// Check if we need to trip the circuit
+	if q.circuitState == CircuitClosed && total >= 10 { // Need a minimum number of requests before tripping
 		if errorPercentage >= float64(q.config.CircuitBreakerErrorThreshold) {
-			q.circuitOpen = true
-			q.lastCircuitTrip = time.Now()
+			q.setCircuitState(CircuitOpen,
+				zap.Float64("error_percentage", errorPercentage),
+				zap.Int64("errors", q.errorCount),
+				zap.Int64("requests", total),
+				zap.Int("threshold", q.config.CircuitBreakerErrorThreshold),
+			)
 		}
 	}
 }
 
+// Outcomes returns the total number of items successfully forwarded to the
+// next consumer and the number that failed, whether or not the circuit
+// breaker is enabled.
+func (q *AdaptivePriorityQueue) Outcomes() (successes, errors int64) {
+	return atomic.LoadInt64(&q.totalSuccesses), atomic.LoadInt64(&q.totalErrors)
+}
+
 // Size returns the current number of items in the queue.
 func (q *AdaptivePriorityQueue) Size() int {
 	q.lock.RLock()
@@ -251,25 +541,78 @@ func (q *AdaptivePriorityQueue) Size() int {
 	return len(q.items)
 }
 
+// InFlightBytes returns the estimated size of the queued items in bytes, so
+// a degradation manager can act before they exhaust memory.
+func (q *AdaptivePriorityQueue) InFlightBytes() int64 {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.queuedBytes
+}
+
+// itemSize estimates the in-memory size of a queued value as the size of
+// its protobuf encoding.
+func itemSize(value interface{}) int {
+	switch data := value.(type) {
+	case pmetric.Metrics:
+		return (&pmetric.ProtoMarshaler{}).MetricsSize(data)
+	case ptrace.Traces:
+		return (&ptrace.ProtoMarshaler{}).TracesSize(data)
+	case plog.Logs:
+		return (&plog.ProtoMarshaler{}).LogsSize(data)
+	}
+	return 0
+}
+
+// SizeByPriority returns the current number of items in the queue at each
+// priority level.
+func (q *AdaptivePriorityQueue) SizeByPriority() map[PriorityLevel]int {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+
+	sizes := make(map[PriorityLevel]int, len(q.priorityWeights))
+	for _, item := range q.items {
+		sizes[item.Priority]++
+	}
+	return sizes
+}
+
 // GetProcessedCount returns the number of items processed by priority.
 func (q *AdaptivePriorityQueue) GetProcessedCount() map[PriorityLevel]int64 {
 	q.processedCountMux.Lock()
 	defer q.processedCountMux.Unlock()
-	
+
 	// Create a copy to avoid data races
 	result := make(map[PriorityLevel]int64, len(q.processedCount))
 	for k, v := range q.processedCount {
 		result[k] = v
 	}
-	
+
 	return result
 }
 
 // GetOverflowCount returns the number of items that couldn't be queued.
 func (q *AdaptivePriorityQueue) GetOverflowCount() int64 {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
 	return q.overflowCount
 }
 
+// GetUnknownPriorityCount returns the number of items enqueued with an
+// unknown priority and queued as normal instead.
+func (q *AdaptivePriorityQueue) GetUnknownPriorityCount() int64 {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.unknownPriorityCount
+}
+
+// GetStaleCount returns the number of items discarded for waiting longer
+// than MaxItemAgeSec.
+func (q *AdaptivePriorityQueue) GetStaleCount() int64 {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.staleCount
+}
+
 // incrementProcessedCount increments the processed count for a priority.
 func (q *AdaptivePriorityQueue) incrementProcessedCount(priority PriorityLevel) {
 	q.processedCountMux.Lock()
@@ -284,7 +627,7 @@ func (q *AdaptivePriorityQueue) Less(i, j int) bool {
 	// Compare based on priority
 	pi := q.items[i].Priority
 	pj := q.items[j].Priority
-	
+
 	// Higher weight = higher priority
 	return q.priorityWeights[pi] > q.priorityWeights[pj]
 }
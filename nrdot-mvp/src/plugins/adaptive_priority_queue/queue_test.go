@@ -0,0 +1,219 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
+)
+
+// newTestQueue creates a queue on a fake clock. configure, if not nil,
+// adjusts the default configuration first.
+func newTestQueue(t *testing.T, configure func(*Config)) (*AdaptivePriorityQueue, *clock.FakeClock) {
+	t.Helper()
+
+	config := CreateDefaultConfig().(*Config)
+	if configure != nil {
+		configure(config)
+	}
+
+	q := NewAdaptivePriorityQueue(zap.NewNop(), config, nil)
+	fake := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	q.SetClock(fake)
+	return q, fake
+}
+
+func TestDequeueDiscardsStaleItems(t *testing.T) {
+	q, fake := newTestQueue(t, func(config *Config) {
+		config.MaxItemAgeSec = 10
+		config.CriticalData.NeverDrop = true
+	})
+
+	q.Enqueue(context.Background(), "old", PriorityNormal)
+	q.Enqueue(context.Background(), "old critical", PriorityCritical)
+	fake.Advance(11 * time.Second)
+	q.Enqueue(context.Background(), "new", PriorityNormal)
+
+	// Critical items are kept however long they wait
+	if item := q.Dequeue(); item == nil || item.Value != "old critical" {
+		t.Fatalf("expected the old critical item, got %v", item)
+	}
+	if item := q.Dequeue(); item == nil || item.Value != "new" {
+		t.Fatalf("expected the stale item to be skipped for the new one, got %v", item)
+	}
+	if item := q.Dequeue(); item != nil {
+		t.Fatalf("expected the queue to be empty, got %v", item.Value)
+	}
+	if got := q.GetStaleCount(); got != 1 {
+		t.Fatalf("expected 1 stale item, got %d", got)
+	}
+	if got := q.GetProcessedCount()[PriorityNormal]; got != 1 {
+		t.Fatalf("expected the stale item not to count as processed, got %d normal items", got)
+	}
+}
+
+func TestStaleCounterPublished(t *testing.T) {
+	q, fake := newTestQueue(t, func(config *Config) {
+		config.MaxItemAgeSec = 10
+	})
+	metrics := promreg.New(zap.NewNop(), prometheus.Labels{"processor": "adaptive_priority_queue/test", "signal": "metrics"})
+	registerStaleCounter(metrics, q)
+	defer metrics.Unregister()
+
+	q.Enqueue(context.Background(), "old", PriorityNormal)
+	fake.Advance(11 * time.Second)
+	q.Dequeue()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "otelcol_adaptive_priority_queue_stale_dropped_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if got := metric.GetCounter().GetValue(); got != 1 {
+				t.Fatalf("expected the counter to be 1, got %v", got)
+			}
+			return
+		}
+	}
+	t.Fatal("expected the stale item counter to be registered")
+}
+
+func TestCriticalEnqueuesIntoReservation(t *testing.T) {
+	q, _ := newTestQueue(t, func(config *Config) {
+		config.MaxQueueSize = 10
+		config.QueueFullThreshold = 80
+		config.ReservedCapacity = map[string]int{"critical": 20}
+	})
+	overflow := &overflowCounter{}
+	q.overflowHandler = overflow
+
+	// Normal items fill the queue up to the threshold
+	for i := 0; i < 8; i++ {
+		if !q.Enqueue(context.Background(), "normal", PriorityNormal) {
+			t.Fatalf("expected normal item %d to be queued below the threshold", i)
+		}
+	}
+	if q.Enqueue(context.Background(), "normal", PriorityNormal) {
+		t.Fatal("expected a normal item to overflow at the threshold")
+	}
+
+	// Critical items still fit in the reservation, until it is full too
+	for i := 0; i < 2; i++ {
+		if !q.Enqueue(context.Background(), "critical", PriorityCritical) {
+			t.Fatalf("expected critical item %d to be queued into the reservation", i)
+		}
+	}
+	if q.Enqueue(context.Background(), "critical", PriorityCritical) {
+		t.Fatal("expected a critical item to overflow once the reservation is full")
+	}
+	if overflow.items != 2 {
+		t.Fatalf("expected 2 items to overflow, got %d", overflow.items)
+	}
+}
+
+func TestCriticalOverflowWrittenToDLQ(t *testing.T) {
+	dlq := &metricsSink{}
+	drop := &dropOverflowHandler{}
+	handler := &metricsDLQHandler{logger: zap.NewNop(), exporter: dlq, criticalOnly: true, drop: drop}
+	q, _ := newTestQueue(t, func(config *Config) {
+		config.OverflowStrategy = "drop"
+		config.CriticalData.NeverDrop = true
+		config.MaxQueueSize = 1
+		config.QueueFullThreshold = 100
+	})
+	q.overflowHandler = handler
+	core, logs := observer.New(zapcore.ErrorLevel)
+	q.logger = zap.New(core)
+
+	// Fill the queue, leaving no room for either priority
+	if !q.Enqueue(context.Background(), pmetric.NewMetrics(), PriorityNormal) {
+		t.Fatal("expected the first metrics to be queued")
+	}
+	if q.Enqueue(context.Background(), pmetric.NewMetrics(), PriorityCritical) {
+		t.Fatal("expected the critical metrics to overflow")
+	}
+	if q.Enqueue(context.Background(), pmetric.NewMetrics(), PriorityNormal) {
+		t.Fatal("expected the normal metrics to overflow")
+	}
+
+	// Only the critical overflow survives, in the DLQ
+	if dlq.batches != 1 {
+		t.Fatalf("expected the critical overflow alone to be written to the DLQ, got %d batches", dlq.batches)
+	}
+	if got := drop.Dropped(); got != 1 {
+		t.Fatalf("expected the normal overflow to be counted as dropped, got %d", got)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("expected dropping the normal overflow not to be logged as an error, got %q", logs.All()[0].Message)
+	}
+	if got := q.Size(); got != 1 {
+		t.Fatalf("expected 1 queued item, got %d", got)
+	}
+}
+
+func TestMinServiceRatioDuringCriticalFlood(t *testing.T) {
+	q, _ := newTestQueue(t, func(config *Config) {
+		config.MaxQueueSize = 2000
+		config.MinServiceRatios = map[string]float64{"normal": 0.2}
+		config.ServiceWindow = 100
+	})
+
+	// Flood critical, with normal data waiting behind it
+	for i := 0; i < 1000; i++ {
+		q.Enqueue(context.Background(), "critical", PriorityCritical)
+	}
+	for i := 0; i < 500; i++ {
+		q.Enqueue(context.Background(), "normal", PriorityNormal)
+	}
+
+	normal := 0
+	for i := 0; i < 500; i++ {
+		item := q.Dequeue()
+		if item == nil {
+			t.Fatal("expected the queue to hold items")
+		}
+		if item.Priority == PriorityNormal {
+			normal++
+		}
+	}
+	if normal < 100 {
+		t.Fatalf("expected normal to get at least 20%% of 500 dequeues, got %d", normal)
+	}
+}
+
+func TestUnknownPriorityCountedAndQueuedAsNormal(t *testing.T) {
+	q, _ := newTestQueue(t, nil)
+	core, logs := observer.New(zapcore.WarnLevel)
+	q.logger = zap.New(core)
+
+	for i := 0; i < 3; i++ {
+		if !q.Enqueue(context.Background(), "item", PriorityLevel("urgent")) {
+			t.Fatal("expected the item to be queued")
+		}
+	}
+	q.Enqueue(context.Background(), "item", PriorityHigh)
+
+	if got := q.GetUnknownPriorityCount(); got != 3 {
+		t.Fatalf("expected 3 unknown priority enqueues, got %d", got)
+	}
+	if sizes := q.SizeByPriority(); sizes[PriorityNormal] != 3 || sizes[PriorityHigh] != 1 {
+		t.Fatalf("expected the unknown priority items to be queued as normal, got %v", sizes)
+	}
+
+	// The misconfiguration is logged once rather than per item
+	if got := logs.FilterMessage("Queueing items with an unknown priority as normal").Len(); got != 1 {
+		t.Fatalf("expected 1 warning, got %d", got)
+	}
+}
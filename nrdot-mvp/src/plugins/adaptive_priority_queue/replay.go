@@ -0,0 +1,23 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/enhanced_dlq"
+)
+
+// errReplayOverflow is returned for replayed data that doesn't fit in the
+// queue. Writing it back to the DLQ it is replayed from could cycle it
+// through the queue forever, so the replay is told it failed instead.
+var errReplayOverflow = errors.New("replayed data overflowed the queue and is not written back to the DLQ")
+
+// queuePriority returns the priority to queue data at: ReplayPriority for
+// data replayed from the DLQ, so live data is served ahead of the backlog,
+// and the data's own priority otherwise.
+func (cfg *Config) queuePriority(ctx context.Context, priority PriorityLevel) PriorityLevel {
+	if enhanceddlq.IsReplay(ctx) {
+		return PriorityLevel(cfg.ReplayPriority)
+	}
+	return priority
+}
@@ -0,0 +1,137 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/enhanced_dlq"
+)
+
+// metricsSink counts the metrics batches written to it, capturing the DLQ
+// priority each was written with.
+type metricsSink struct {
+	batches    int
+	priorities []string
+}
+
+func (s *metricsSink) ConsumeMetrics(ctx context.Context, _ pmetric.Metrics) error {
+	s.batches++
+	s.priorities = append(s.priorities, enhanceddlq.PriorityFromContext(ctx))
+	return nil
+}
+
+func (s *metricsSink) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{}
+}
+
+// replayedContext returns the context an OTLP receiver with include_metadata
+// passes along with data an enhanced_dlq exporter replays to it.
+func replayedContext() context.Context {
+	return client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{
+			enhanceddlq.ReplayHeader: {"true"},
+		}),
+	})
+}
+
+// namedMetrics returns a batch of metrics from a service with the given
+// name and priority.
+func namedMetrics(name string, priority PriorityLevel) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	attrs := md.ResourceMetrics().AppendEmpty().Resource().Attributes()
+	attrs.PutStr("service.name", name)
+	attrs.PutStr("nrdot.priority", string(priority))
+	return md
+}
+
+// newStoppedMetricsProcessor creates a metrics processor whose worker is
+// stopped, so consumed batches stay queued.
+func newStoppedMetricsProcessor(t *testing.T, configure func(*Config)) *metricsProcessor {
+	t.Helper()
+
+	config := CreateDefaultConfig().(*Config)
+	if configure != nil {
+		configure(config)
+	}
+	p, err := newMetricsProcessor(context.Background(), zap.NewNop(), config, component.NewID(typeStr), &metricsSink{})
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+	p.cancel()
+	p.wg.Wait()
+	return p
+}
+
+func TestLiveDequeuedAheadOfReplayed(t *testing.T) {
+	p := newStoppedMetricsProcessor(t, nil)
+
+	// The replayed backlog arrives first, even critical data
+	batches := []struct {
+		ctx      context.Context
+		name     string
+		priority PriorityLevel
+	}{
+		{replayedContext(), "replayed critical", PriorityCritical},
+		{replayedContext(), "replayed normal", PriorityNormal},
+		{context.Background(), "live normal", PriorityNormal},
+		{context.Background(), "live high", PriorityHigh},
+	}
+	for _, batch := range batches {
+		if err := p.ConsumeMetrics(batch.ctx, namedMetrics(batch.name, batch.priority)); err != nil {
+			t.Fatalf("failed to consume %s metrics: %v", batch.name, err)
+		}
+	}
+
+	var order []string
+	for item := p.queue.Dequeue(); item != nil; item = p.queue.Dequeue() {
+		name, _ := item.Value.(pmetric.Metrics).ResourceMetrics().At(0).Resource().Attributes().Get("service.name")
+		order = append(order, name.Str())
+	}
+
+	// Live data goes first, then the replayed items
+	if len(order) != 4 || order[0] != "live high" || order[1] != "live normal" {
+		t.Fatalf("expected the live items to be dequeued first, got %v", order)
+	}
+	for _, value := range order[2:] {
+		if !strings.HasPrefix(value, "replayed") {
+			t.Fatalf("expected the replayed items to be dequeued last, got %v", order)
+		}
+	}
+}
+
+func TestReplayedOverflowNotWrittenBackToDLQ(t *testing.T) {
+	p := newStoppedMetricsProcessor(t, func(config *Config) {
+		config.MaxQueueSize = 1
+		config.QueueFullThreshold = 100
+	})
+	dlq := &metricsSink{}
+	p.dlqHandler.exporter = dlq
+
+	if err := p.ConsumeMetrics(context.Background(), pmetric.NewMetrics()); err != nil {
+		t.Fatalf("failed to consume metrics: %v", err)
+	}
+
+	// Replayed data that doesn't fit is refused back to the replay
+	if err := p.ConsumeMetrics(replayedContext(), pmetric.NewMetrics()); !errors.Is(err, errReplayOverflow) {
+		t.Fatalf("expected the replayed overflow to be refused, got %v", err)
+	}
+	if dlq.batches != 0 {
+		t.Fatalf("expected nothing to be written back to the DLQ, got %d batches", dlq.batches)
+	}
+
+	// Live data that doesn't fit still goes to the DLQ
+	if err := p.ConsumeMetrics(context.Background(), pmetric.NewMetrics()); err != nil {
+		t.Fatalf("expected the live overflow to be accepted, got %v", err)
+	}
+	if dlq.batches != 1 {
+		t.Fatalf("expected the live overflow to be written to the DLQ, got %d batches", dlq.batches)
+	}
+}
@@ -0,0 +1,99 @@
+package adaptivepriorityqueue
+
+import (
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// SeverityRange maps an inclusive range of OpenTelemetry log severity numbers
+// to a priority level.
+type SeverityRange struct {
+	// Min is the lowest severity number in the range, 1 (TRACE) to 24 (FATAL4)
+	Min int `mapstructure:"min"`
+
+	// Max is the highest severity number in the range
+	Max int `mapstructure:"max"`
+
+	// Priority is the priority level assigned to log records in the range
+	Priority string `mapstructure:"priority"`
+}
+
+// defaultSeverityPriorities maps ERROR and FATAL to critical and WARN to high.
+// Everything else is normal.
+func defaultSeverityPriorities() []SeverityRange {
+	return []SeverityRange{
+		{Min: int(plog.SeverityNumberError), Max: int(plog.SeverityNumberFatal4), Priority: string(PriorityCritical)},
+		{Min: int(plog.SeverityNumberWarn), Max: int(plog.SeverityNumberWarn4), Priority: string(PriorityHigh)},
+	}
+}
+
+// severityClassifier assigns log records a priority from their severity number.
+type severityClassifier struct {
+	ranges []SeverityRange
+}
+
+// newSeverityClassifier creates a classifier from the configured ranges.
+func newSeverityClassifier(config *Config) *severityClassifier {
+	return &severityClassifier{ranges: config.LogSeverityPriorities}
+}
+
+// classify returns the priority of the first range containing the severity,
+// or normal priority if none does.
+func (c *severityClassifier) classify(severity plog.SeverityNumber) PriorityLevel {
+	for _, r := range c.ranges {
+		if int(severity) >= r.Min && int(severity) <= r.Max {
+			return PriorityLevel(r.Priority)
+		}
+	}
+	return PriorityNormal
+}
+
+// logsDestKey identifies where a log record is copied to within the batch for
+// its priority.
+type logsDestKey struct {
+	priority PriorityLevel
+	rl       int
+	sl       int
+}
+
+// split divides the logs into one batch per priority, keeping the resource
+// and scope each record was reported with.
+func (c *severityClassifier) split(ld plog.Logs) map[PriorityLevel]plog.Logs {
+	batches := make(map[PriorityLevel]plog.Logs)
+	dests := make(map[logsDestKey]plog.LogRecordSlice)
+
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		rl := rls.At(i)
+		sls := rl.ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			sl := sls.At(j)
+			records := sl.LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				record := records.At(k)
+				priority := c.classify(record.SeverityNumber())
+
+				key := logsDestKey{priority: priority, rl: i, sl: j}
+				dest, exists := dests[key]
+				if !exists {
+					batch, exists := batches[priority]
+					if !exists {
+						batch = plog.NewLogs()
+						batches[priority] = batch
+					}
+					destRL := batch.ResourceLogs().AppendEmpty()
+					rl.Resource().CopyTo(destRL.Resource())
+					destRL.SetSchemaUrl(rl.SchemaUrl())
+					destSL := destRL.ScopeLogs().AppendEmpty()
+					sl.Scope().CopyTo(destSL.Scope())
+					destSL.SetSchemaUrl(sl.SchemaUrl())
+					dest = destSL.LogRecords()
+					dests[key] = dest
+				}
+
+				record.CopyTo(dest.AppendEmpty())
+			}
+		}
+	}
+
+	return batches
+}
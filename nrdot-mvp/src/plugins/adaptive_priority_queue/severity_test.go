@@ -0,0 +1,65 @@
+package adaptivepriorityqueue
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestSeverityPriorities(t *testing.T) {
+	classifier := newSeverityClassifier(CreateDefaultConfig().(*Config))
+
+	ld := plog.NewLogs()
+	records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	expected := map[plog.SeverityNumber]PriorityLevel{
+		plog.SeverityNumberUnspecified: PriorityNormal,
+		plog.SeverityNumberDebug:       PriorityNormal,
+		plog.SeverityNumberInfo:        PriorityNormal,
+		plog.SeverityNumberWarn:        PriorityHigh,
+		plog.SeverityNumberWarn4:       PriorityHigh,
+		plog.SeverityNumberError:       PriorityCritical,
+		plog.SeverityNumberFatal:       PriorityCritical,
+	}
+	for severity, priority := range expected {
+		if got := classifier.classify(severity); got != priority {
+			t.Fatalf("expected severity %v to be %s, got %s", severity, priority, got)
+		}
+		records.AppendEmpty().SetSeverityNumber(severity)
+	}
+
+	// Splitting the batch puts each record in the batch for its priority
+	batches := classifier.split(ld)
+	counts := map[PriorityLevel]int{PriorityNormal: 3, PriorityHigh: 2, PriorityCritical: 2}
+	if len(batches) != len(counts) {
+		t.Fatalf("expected %d batches, got %d", len(counts), len(batches))
+	}
+	for priority, count := range counts {
+		if got := batches[priority].LogRecordCount(); got != count {
+			t.Fatalf("expected %d %s records, got %d", count, priority, got)
+		}
+	}
+}
+
+func TestCustomSeverityPriorities(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.LogSeverityPriorities = []SeverityRange{
+		{Min: int(plog.SeverityNumberFatal), Max: int(plog.SeverityNumberFatal4), Priority: string(PriorityCritical)},
+		{Min: int(plog.SeverityNumberError), Max: int(plog.SeverityNumberError4), Priority: string(PriorityHigh)},
+		{Min: int(plog.SeverityNumberTrace), Max: int(plog.SeverityNumberDebug4), Priority: string(PriorityLow)},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	classifier := newSeverityClassifier(config)
+
+	for severity, priority := range map[plog.SeverityNumber]PriorityLevel{
+		plog.SeverityNumberFatal2: PriorityCritical,
+		plog.SeverityNumberError:  PriorityHigh,
+		plog.SeverityNumberWarn:   PriorityNormal,
+		plog.SeverityNumberTrace:  PriorityLow,
+	} {
+		if got := classifier.classify(severity); got != priority {
+			t.Fatalf("expected severity %v to be %s, got %s", severity, priority, got)
+		}
+	}
+}
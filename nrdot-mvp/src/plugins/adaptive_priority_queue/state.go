@@ -0,0 +1,135 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// Names of the metrics in the queue state snapshot.
+const (
+	stateMetricQueueSize   = "apq.queue.size"
+	stateMetricProcessed   = "apq.processed"
+	stateMetricOverflow    = "apq.overflow"
+	stateMetricUnknown     = "apq.unknown_priority"
+	stateMetricStale       = "apq.stale_dropped"
+	stateMetricCircuitOpen = "apq.circuit_breaker.open"
+)
+
+// stateLoop forwards a snapshot of the queue state to the next consumer on
+// every state metrics interval, so it lands wherever the pipeline's metrics
+// do.
+func (p *metricsProcessor) stateLoop(ctx context.Context) {
+	defer p.wg.Done()
+
+	startTime := p.queue.clock.Now()
+	ticker := time.NewTicker(time.Duration(p.config.StateMetricsIntervalSec) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			md := buildStateMetrics(p.queue, p.id.String(), startTime, p.queue.clock.Now())
+			if err := p.nextConsumer.ConsumeMetrics(ctx, md); err != nil {
+				p.logger.Warn("Failed to emit queue state metrics", zap.Error(err))
+			}
+		}
+	}
+}
+
+// buildStateMetrics builds an OTLP snapshot of the queue: its size per
+// priority, the items processed per priority and overflowed since startTime,
+// and whether the circuit breaker is open.
+func buildStateMetrics(q *AdaptivePriorityQueue, componentID string, startTime time.Time, now time.Time) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("otelcol.component.id", componentID)
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName(typeStr)
+
+	start := pcommon.NewTimestampFromTime(startTime)
+	ts := pcommon.NewTimestampFromTime(now)
+
+	sizes := q.SizeByPriority()
+	size := sm.Metrics().AppendEmpty()
+	size.SetName(stateMetricQueueSize)
+	size.SetDescription("Items waiting in the priority queue")
+	size.SetUnit("{items}")
+	sizeGauge := size.SetEmptyGauge()
+	for _, priority := range priorityOrder {
+		dp := sizeGauge.DataPoints().AppendEmpty()
+		dp.SetTimestamp(ts)
+		dp.SetIntValue(int64(sizes[priority]))
+		dp.Attributes().PutStr("priority", string(priority))
+	}
+
+	processedCounts := q.GetProcessedCount()
+	processed := sm.Metrics().AppendEmpty()
+	processed.SetName(stateMetricProcessed)
+	processed.SetDescription("Items dequeued from the priority queue")
+	processed.SetUnit("{items}")
+	processedSum := processed.SetEmptySum()
+	processedSum.SetIsMonotonic(true)
+	processedSum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	for _, priority := range priorityOrder {
+		dp := processedSum.DataPoints().AppendEmpty()
+		dp.SetStartTimestamp(start)
+		dp.SetTimestamp(ts)
+		dp.SetIntValue(processedCounts[priority])
+		dp.Attributes().PutStr("priority", string(priority))
+	}
+
+	overflow := sm.Metrics().AppendEmpty()
+	overflow.SetName(stateMetricOverflow)
+	overflow.SetDescription("Items handed to the overflow strategy instead of being queued")
+	overflow.SetUnit("{items}")
+	overflowSum := overflow.SetEmptySum()
+	overflowSum.SetIsMonotonic(true)
+	overflowSum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	overflowDP := overflowSum.DataPoints().AppendEmpty()
+	overflowDP.SetStartTimestamp(start)
+	overflowDP.SetTimestamp(ts)
+	overflowDP.SetIntValue(q.GetOverflowCount())
+
+	unknown := sm.Metrics().AppendEmpty()
+	unknown.SetName(stateMetricUnknown)
+	unknown.SetDescription("Items enqueued with an unknown priority and queued as normal")
+	unknown.SetUnit("{items}")
+	unknownSum := unknown.SetEmptySum()
+	unknownSum.SetIsMonotonic(true)
+	unknownSum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	unknownDP := unknownSum.DataPoints().AppendEmpty()
+	unknownDP.SetStartTimestamp(start)
+	unknownDP.SetTimestamp(ts)
+	unknownDP.SetIntValue(q.GetUnknownPriorityCount())
+
+	stale := sm.Metrics().AppendEmpty()
+	stale.SetName(stateMetricStale)
+	stale.SetDescription("Items discarded for waiting longer than max_item_age_sec")
+	stale.SetUnit("{items}")
+	staleSum := stale.SetEmptySum()
+	staleSum.SetIsMonotonic(true)
+	staleSum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	staleDP := staleSum.DataPoints().AppendEmpty()
+	staleDP.SetStartTimestamp(start)
+	staleDP.SetTimestamp(ts)
+	staleDP.SetIntValue(q.GetStaleCount())
+
+	var circuitOpen int64
+	if q.IsCircuitOpen() {
+		circuitOpen = 1
+	}
+	circuit := sm.Metrics().AppendEmpty()
+	circuit.SetName(stateMetricCircuitOpen)
+	circuit.SetDescription("1 while the circuit breaker is open, 0 otherwise")
+	circuitDP := circuit.SetEmptyGauge().DataPoints().AppendEmpty()
+	circuitDP.SetTimestamp(ts)
+	circuitDP.SetIntValue(circuitOpen)
+
+	return md
+}
@@ -0,0 +1,98 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// stateValues returns the int value of each data point of a state metric,
+// keyed by its priority attribute, or "" when it has none.
+func stateValues(t *testing.T, md pmetric.Metrics, name string, kind pmetric.MetricType) map[string]int64 {
+	t.Helper()
+
+	metrics := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		if metric.Name() != name {
+			continue
+		}
+		if metric.Type() != kind {
+			t.Fatalf("expected %s to be a %s, got %s", name, kind, metric.Type())
+		}
+		var points pmetric.NumberDataPointSlice
+		if kind == pmetric.MetricTypeSum {
+			if !metric.Sum().IsMonotonic() {
+				t.Fatalf("expected %s to be a monotonic counter", name)
+			}
+			points = metric.Sum().DataPoints()
+		} else {
+			points = metric.Gauge().DataPoints()
+		}
+		values := make(map[string]int64, points.Len())
+		for j := 0; j < points.Len(); j++ {
+			priority, _ := points.At(j).Attributes().Get("priority")
+			values[priority.Str()] = points.At(j).IntValue()
+		}
+		return values
+	}
+	t.Fatalf("expected the snapshot to contain %s", name)
+	return nil
+}
+
+func TestStateMetricsSnapshot(t *testing.T) {
+	q, fake := newTestQueue(t, func(config *Config) {
+		config.MaxQueueSize = 3
+		config.QueueFullThreshold = 100
+		config.CircuitBreakerEnabled = true
+		config.CircuitBreakerErrorThreshold = 50
+	})
+	q.overflowHandler = &metricsDLQHandler{logger: zap.NewNop(), exporter: &metricsSink{}}
+
+	// Fill the queue, overflow once and process a critical item
+	ctx := context.Background()
+	q.Enqueue(ctx, pmetric.NewMetrics(), PriorityCritical)
+	q.Enqueue(ctx, pmetric.NewMetrics(), PriorityCritical)
+	q.Enqueue(ctx, pmetric.NewMetrics(), PriorityNormal)
+	if q.Enqueue(ctx, pmetric.NewMetrics(), PriorityNormal) {
+		t.Fatal("expected the fourth item to overflow")
+	}
+	if item := q.Dequeue(); item == nil || item.Priority != PriorityCritical {
+		t.Fatalf("expected a critical item to be dequeued, got %v", item)
+	}
+	for i := 0; i < 10; i++ {
+		q.RecordError()
+	}
+
+	start := fake.Now()
+	fake.Advance(time.Minute)
+	md := buildStateMetrics(q, "adaptive_priority_queue/state", start, fake.Now())
+
+	component, _ := md.ResourceMetrics().At(0).Resource().Attributes().Get("otelcol.component.id")
+	if component.Str() != "adaptive_priority_queue/state" {
+		t.Fatalf("expected the snapshot to name its component, got %q", component.Str())
+	}
+
+	sizes := stateValues(t, md, stateMetricQueueSize, pmetric.MetricTypeGauge)
+	if sizes[string(PriorityCritical)] != 1 || sizes[string(PriorityHigh)] != 0 || sizes[string(PriorityNormal)] != 1 {
+		t.Fatalf("unexpected queue sizes %v", sizes)
+	}
+	if processed := stateValues(t, md, stateMetricProcessed, pmetric.MetricTypeSum); processed[string(PriorityCritical)] != 1 {
+		t.Fatalf("expected 1 processed critical item, got %v", processed)
+	}
+	if overflow := stateValues(t, md, stateMetricOverflow, pmetric.MetricTypeSum); overflow[""] != 1 {
+		t.Fatalf("expected 1 overflowed item, got %v", overflow)
+	}
+	if stale := stateValues(t, md, stateMetricStale, pmetric.MetricTypeSum); stale[""] != 0 {
+		t.Fatalf("expected no stale items, got %v", stale)
+	}
+	if unknown := stateValues(t, md, stateMetricUnknown, pmetric.MetricTypeSum); unknown[""] != 0 {
+		t.Fatalf("expected no unknown priority items, got %v", unknown)
+	}
+	if circuit := stateValues(t, md, stateMetricCircuitOpen, pmetric.MetricTypeGauge); circuit[""] != 1 {
+		t.Fatalf("expected the circuit to be reported open, got %v", circuit)
+	}
+}
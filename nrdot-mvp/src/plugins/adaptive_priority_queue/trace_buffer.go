@@ -0,0 +1,153 @@
+package adaptivepriorityqueue
+
+import (
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// bufferedTrace holds the spans of a single trace seen so far.
+type bufferedTrace struct {
+	traces    ptrace.Traces
+	firstSeen time.Time
+	hasError  bool
+}
+
+// priority returns the priority a buffered trace is enqueued with.
+func (t *bufferedTrace) priority() PriorityLevel {
+	if t.hasError {
+		return PriorityCritical
+	}
+	return PriorityNormal
+}
+
+// traceBuffer groups spans by trace ID for a short window so a trace's
+// priority can reflect all of its spans rather than the batch they arrived in.
+type traceBuffer struct {
+	clock     clock.Clock
+	window    time.Duration
+	maxTraces int
+	traces    map[pcommon.TraceID]*bufferedTrace
+	mutex     sync.Mutex
+}
+
+// newTraceBuffer creates a trace buffer from the configuration.
+func newTraceBuffer(config *Config, clk clock.Clock) *traceBuffer {
+	return &traceBuffer{
+		clock:     clk,
+		window:    time.Duration(config.TraceBufferWindowMs) * time.Millisecond,
+		maxTraces: config.MaxBufferedTraces,
+		traces:    make(map[pcommon.TraceID]*bufferedTrace),
+	}
+}
+
+// spanDestKey identifies where a span is copied to within a buffered trace.
+type spanDestKey struct {
+	traceID pcommon.TraceID
+	rs      int
+	ss      int
+}
+
+// add splits the spans by trace ID into the buffer. If the buffer is over
+// capacity, the oldest traces are evicted and returned so they can be
+// enqueued right away.
+func (b *traceBuffer) add(td ptrace.Traces) []*bufferedTrace {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+	dests := make(map[spanDestKey]ptrace.SpanSlice)
+
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		sss := rs.ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			ss := sss.At(j)
+			spans := ss.Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				traceID := span.TraceID()
+
+				bt, exists := b.traces[traceID]
+				if !exists {
+					bt = &bufferedTrace{traces: ptrace.NewTraces(), firstSeen: now}
+					b.traces[traceID] = bt
+				}
+
+				// Keep the resource and scope the span was reported with
+				key := spanDestKey{traceID: traceID, rs: i, ss: j}
+				dest, exists := dests[key]
+				if !exists {
+					destRS := bt.traces.ResourceSpans().AppendEmpty()
+					rs.Resource().CopyTo(destRS.Resource())
+					destRS.SetSchemaUrl(rs.SchemaUrl())
+					destSS := destRS.ScopeSpans().AppendEmpty()
+					ss.Scope().CopyTo(destSS.Scope())
+					destSS.SetSchemaUrl(ss.SchemaUrl())
+					dest = destSS.Spans()
+					dests[key] = dest
+				}
+
+				span.CopyTo(dest.AppendEmpty())
+				if span.Status().Code() == ptrace.StatusCodeError {
+					bt.hasError = true
+				}
+			}
+		}
+	}
+
+	return b.evictOverCapacity()
+}
+
+// evictOverCapacity removes the oldest traces while the buffer holds more
+// than maxTraces. The caller must hold the mutex.
+func (b *traceBuffer) evictOverCapacity() []*bufferedTrace {
+	var evicted []*bufferedTrace
+	for len(b.traces) > b.maxTraces {
+		var oldestID pcommon.TraceID
+		var oldest *bufferedTrace
+		for id, bt := range b.traces {
+			if oldest == nil || bt.firstSeen.Before(oldest.firstSeen) {
+				oldestID, oldest = id, bt
+			}
+		}
+		delete(b.traces, oldestID)
+		evicted = append(evicted, oldest)
+	}
+	return evicted
+}
+
+// expired removes and returns the traces that have been buffered for at
+// least the buffer window.
+func (b *traceBuffer) expired() []*bufferedTrace {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+	var expired []*bufferedTrace
+	for id, bt := range b.traces {
+		if now.Sub(bt.firstSeen) >= b.window {
+			delete(b.traces, id)
+			expired = append(expired, bt)
+		}
+	}
+	return expired
+}
+
+// drain removes and returns all buffered traces.
+func (b *traceBuffer) drain() []*bufferedTrace {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	drained := make([]*bufferedTrace, 0, len(b.traces))
+	for id, bt := range b.traces {
+		delete(b.traces, id)
+		drained = append(drained, bt)
+	}
+	return drained
+}
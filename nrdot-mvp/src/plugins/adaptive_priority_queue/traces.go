@@ -0,0 +1,310 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/enhanced_dlq"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/health"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
+)
+
+// tracesProcessor is the processor for applying priority queuing to traces.
+type tracesProcessor struct {
+	id           component.ID
+	logger       *zap.Logger
+	config       *Config
+	nextConsumer consumer.Traces
+	queue        *AdaptivePriorityQueue
+	dlqHandler   *tracesDLQHandler
+	dlqExporter  OverflowHandler
+	dropHandler  *dropOverflowHandler
+
+	// Buffers spans by trace ID when trace-level prioritization is enabled
+	buffer *traceBuffer
+
+	// Stops the worker and flush loop and waits for them to exit
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Unpublishes the queue's outcomes from the health registry
+	unregisterHealth func()
+
+	// Unpublishes the queue's in-flight bytes from the health registry
+	unregisterInFlight func()
+
+	// Publishes the queue's overflow rate, circuit breaker state and
+	// stale and dropped item counts
+	metrics *promreg.Registry
+}
+
+// newTracesProcessor creates a new traces processor for priority queuing.
+func newTracesProcessor(
+	ctx context.Context,
+	logger *zap.Logger,
+	config *Config,
+	id component.ID,
+	nextConsumer consumer.Traces,
+) (*tracesProcessor, error) {
+	// Create the overflow handlers, the DLQ exporter is resolved in Start
+	dropHandler := &dropOverflowHandler{}
+	dlqHandler := &tracesDLQHandler{
+		logger: logger,
+		drop:   dropHandler,
+	}
+
+	p := &tracesProcessor{
+		id:           id,
+		logger:       logger,
+		config:       config,
+		nextConsumer: nextConsumer,
+		dlqHandler:   dlqHandler,
+		dropHandler:  dropHandler,
+	}
+
+	p.dlqExporter = config.overflowHandler(dlqHandler, dropHandler)
+
+	// Create the priority queue
+	p.queue = NewAdaptivePriorityQueue(logger, config, p.dlqExporter)
+
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	// Buffer spans so a trace's priority reflects all of its spans
+	if config.TraceBufferWindowMs > 0 {
+		p.buffer = newTraceBuffer(config, p.queue.clock)
+		p.wg.Add(1)
+		go p.flushLoop(ctx)
+	}
+
+	// Start the worker to process queued items
+	p.wg.Add(1)
+	go p.worker(ctx)
+
+	return p, nil
+}
+
+// ConsumeTraces enqueues traces to be processed based on priority.
+func (p *tracesProcessor) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	if p.buffer == nil || enhanceddlq.IsReplay(ctx) {
+		priority := PriorityNormal
+		if carried := p.config.CriticalData.TracesPriority(td); carried != "" {
+			priority = PriorityLevel(carried)
+		}
+		return p.enqueue(ctx, td, p.config.queuePriority(ctx, priority))
+	}
+
+	// Traces evicted to keep the buffer bounded are enqueued right away
+	for _, bt := range p.buffer.add(td) {
+		if err := p.enqueue(ctx, bt.traces, bt.priority()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enqueue adds traces to the queue, or sends them to the DLQ while the
+// circuit breaker is open.
+func (p *tracesProcessor) enqueue(ctx context.Context, td ptrace.Traces, priority PriorityLevel) error {
+	if p.queue.IsCircuitOpen() {
+		// Circuit is open, send directly to DLQ
+		item := &QueueItem{
+			Value:    td,
+			Priority: priority,
+			Added:    p.queue.clock.Now(),
+		}
+		return p.dlqExporter.HandleOverflow(ctx, item)
+	}
+
+	// Push back on the receiver once overflow has been sustained
+	if p.queue.Backpressured() {
+		return ErrBackpressure
+	}
+
+	// Failed enqueues are already handled by the overflow handler, except
+	// for replayed data
+	if !p.queue.Enqueue(ctx, td, priority) && enhanceddlq.IsReplay(ctx) {
+		return errReplayOverflow
+	}
+	return nil
+}
+
+// flushLoop enqueues buffered traces once they have been held for the buffer
+// window.
+func (p *tracesProcessor) flushLoop(ctx context.Context) {
+	defer p.wg.Done()
+
+	interval := p.buffer.window / 2
+	if interval <= 0 {
+		interval = p.buffer.window
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, bt := range p.buffer.expired() {
+				if err := p.enqueue(ctx, bt.traces, bt.priority()); err != nil {
+					p.logger.Error("Failed to enqueue buffered trace", zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+// worker processes items from the queue and forwards them to the next consumer.
+func (p *tracesProcessor) worker(ctx context.Context) {
+	defer p.wg.Done()
+
+	// The item being forwarded is finished even once shutdown has started
+	consumeCtx := context.WithoutCancel(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			// Dequeue the next item
+			item := p.queue.Dequeue()
+			if item == nil {
+				// Queue is empty, wait a bit before trying again
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+				}
+				continue
+			}
+
+			// Forward to the next consumer
+			err := p.nextConsumer.ConsumeTraces(consumeCtx, item.Value.(ptrace.Traces))
+			if err != nil {
+				p.logger.Error("Failed to process traces", zap.Error(err))
+				p.queue.RecordError()
+			} else {
+				p.queue.RecordSuccess()
+			}
+		}
+	}
+}
+
+// Capabilities returns the capabilities of the processor.
+func (p *tracesProcessor) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: false}
+}
+
+// Start publishes the queue outcomes and resolves the DLQ exporter that
+// overflowed traces are written to.
+func (p *tracesProcessor) Start(_ context.Context, host component.Host) error {
+	// Publish the queue's outcomes so a degradation manager can track the
+	// backend error rate
+	p.unregisterHealth = health.Register(p.id.String(), p.queue)
+	p.unregisterInFlight = health.RegisterInFlight(p.id.String(), p.queue)
+	p.metrics = promreg.New(p.logger, prometheus.Labels{"processor": p.id.String(), "signal": "traces"})
+	registerOverflowRateGauge(p.metrics, p.queue)
+	registerCircuitStateGauge(p.metrics, p.queue)
+	registerStaleCounter(p.metrics, p.queue)
+	registerOverflowDroppedCounter(p.metrics, p.dropHandler)
+
+	// Without the dlq strategy, the exporter is still needed for critical
+	// items when they must never be dropped
+	if p.config.OverflowStrategy != "dlq" {
+		if !p.config.CriticalData.NeverDrop {
+			return nil
+		}
+		p.dlqHandler.criticalOnly = true
+	}
+
+	var id component.ID
+	if err := id.UnmarshalText([]byte(p.config.DLQExporter)); err != nil {
+		return fmt.Errorf("invalid dlq_exporter '%s': %w", p.config.DLQExporter, err)
+	}
+
+	exp, exists := host.GetExporters()[component.DataTypeTraces][id]
+	if !exists {
+		return fmt.Errorf("dlq_exporter '%s' is not configured as a traces exporter", p.config.DLQExporter)
+	}
+
+	tracesExporter, ok := exp.(consumer.Traces)
+	if !ok {
+		return fmt.Errorf("dlq_exporter '%s' does not accept traces", p.config.DLQExporter)
+	}
+
+	p.dlqHandler.exporter = tracesExporter
+	return nil
+}
+
+// Shutdown stops the worker and flush loop and waits for them to finish,
+// then forwards any traces still buffered straight to the next consumer.
+func (p *tracesProcessor) Shutdown(ctx context.Context) error {
+	if p.unregisterHealth != nil {
+		p.unregisterHealth()
+	}
+	if p.unregisterInFlight != nil {
+		p.unregisterInFlight()
+	}
+	if p.metrics != nil {
+		p.metrics.Unregister()
+	}
+
+	p.cancel()
+	err := waitForWorkers(ctx, &p.wg)
+
+	// With the worker stopped nothing would dequeue buffered traces, so they
+	// bypass the queue
+	if p.buffer != nil {
+		for _, bt := range p.buffer.drain() {
+			if err := p.nextConsumer.ConsumeTraces(ctx, bt.traces); err != nil {
+				p.logger.Error("Failed to forward buffered trace on shutdown", zap.Error(err))
+			}
+		}
+	}
+
+	return err
+}
+
+// tracesDLQHandler handles traces overflow by sending them to a DLQ.
+type tracesDLQHandler struct {
+	logger   *zap.Logger
+	exporter consumer.Traces
+
+	// Only critical items are written to the DLQ, the rest are passed to
+	// drop
+	criticalOnly bool
+	drop         *dropOverflowHandler
+}
+
+// HandleOverflow implements the OverflowHandler interface.
+func (h *tracesDLQHandler) HandleOverflow(ctx context.Context, item *QueueItem) error {
+	if enhanceddlq.IsReplay(ctx) {
+		return errReplayOverflow
+	}
+	if h.exporter == nil {
+		return fmt.Errorf("no DLQ exporter available for overflowed traces")
+	}
+	if h.criticalOnly && item.Priority != PriorityCritical {
+		return h.drop.HandleOverflow(ctx, item)
+	}
+
+	h.logger.Debug("Sending traces to DLQ",
+		zap.String("priority", string(item.Priority)),
+		zap.Time("added", item.Added),
+	)
+
+	// Carry the priority so the DLQ can replay higher priorities first
+	ctx = enhanceddlq.ContextWithPriority(ctx, string(item.Priority))
+	return h.exporter.ConsumeTraces(ctx, item.Value.(ptrace.Traces))
+}
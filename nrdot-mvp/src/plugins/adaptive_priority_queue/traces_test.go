@@ -0,0 +1,70 @@
+package adaptivepriorityqueue
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// tracesSink records the traces forwarded to it.
+type tracesSink struct {
+	mutex sync.Mutex
+	spans int
+}
+
+func (s *tracesSink) ConsumeTraces(_ context.Context, td ptrace.Traces) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.spans += td.SpanCount()
+	return nil
+}
+
+func (s *tracesSink) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{}
+}
+
+func (s *tracesSink) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.spans
+}
+
+func TestTracesShutdownForwardsBufferedTraces(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.OverflowStrategy = "drop"
+	// Long enough that nothing is flushed before shutdown
+	config.TraceBufferWindowMs = 60 * 60 * 1000
+
+	sink := &tracesSink{}
+	p, err := newTracesProcessor(context.Background(), zap.NewNop(), config, component.NewID(typeStr), sink)
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	for i := 0; i < 3; i++ {
+		span := spans.AppendEmpty()
+		span.SetTraceID(pcommon.TraceID([16]byte{byte(i + 1)}))
+		span.SetName("checkout")
+	}
+	if err := p.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatalf("failed to consume traces: %v", err)
+	}
+	if got := sink.count(); got != 0 {
+		t.Fatalf("expected the spans to be buffered, %d were forwarded", got)
+	}
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if got := sink.count(); got != 3 {
+		t.Fatalf("expected the 3 buffered spans to be forwarded on shutdown, got %d", got)
+	}
+}
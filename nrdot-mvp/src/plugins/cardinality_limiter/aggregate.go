@@ -0,0 +1,132 @@
+package cardinalitylimiter
+
+import (
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// errBucketBoundaryMismatch is returned when two histogram data points can't
+// be merged because their explicit bucket boundaries differ.
+var errBucketBoundaryMismatch = errors.New("histogram bucket boundaries differ")
+
+// aggregateHistogramDataPoints collapses the data points into one per
+// combination of the aggregation dimensions, keeping only those dimensions as
+// attributes. Data points whose bucket boundaries don't match the aggregate
+// they belong to are dropped rather than merged, and passed to onDrop first
+// if it is set. It returns the number of data points dropped for mismatched
+// boundaries.
+func aggregateHistogramDataPoints(dataPoints pmetric.HistogramDataPointSlice, resourceAttrs pcommon.Map, dimensions []string, onDrop func(pmetric.HistogramDataPoint)) int {
+	keep := make(map[string]bool, len(dimensions))
+	for _, dim := range dimensions {
+		keep[dim] = true
+	}
+
+	aggregated := pmetric.NewHistogramDataPointSlice()
+	groups := make(map[string]pmetric.HistogramDataPoint)
+	mismatched := 0
+
+	for i := 0; i < dataPoints.Len(); i++ {
+		dp := dataPoints.At(i)
+		key := dimensionKey(resourceAttrs, dp.Attributes(), dimensions)
+
+		acc, exists := groups[key]
+		if !exists {
+			acc = aggregated.AppendEmpty()
+			dp.CopyTo(acc)
+			acc.Attributes().RemoveIf(func(k string, _ pcommon.Value) bool {
+				return !keep[k]
+			})
+			groups[key] = acc
+			continue
+		}
+
+		if err := mergeHistogramDataPoint(acc, dp); err != nil {
+			mismatched++
+			if onDrop != nil {
+				onDrop(dp)
+			}
+		}
+	}
+
+	aggregated.CopyTo(dataPoints)
+	return mismatched
+}
+
+// dimensionKey returns the values of the aggregation dimensions for a data
+// point, looking at the data point attributes before the resource attributes.
+func dimensionKey(resourceAttrs pcommon.Map, attrs pcommon.Map, dimensions []string) string {
+	values := make([]string, len(dimensions))
+	for i, dim := range dimensions {
+		if v, ok := attrs.Get(dim); ok {
+			values[i] = v.AsString()
+		} else if v, ok := resourceAttrs.Get(dim); ok {
+			values[i] = v.AsString()
+		}
+	}
+	return strings.Join(values, "|")
+}
+
+// mergeHistogramDataPoint merges src into dst. Counts are only merged when
+// both data points have identical explicit bucket boundaries; otherwise dst
+// is left unchanged and errBucketBoundaryMismatch is returned.
+func mergeHistogramDataPoint(dst, src pmetric.HistogramDataPoint) error {
+	if !equalBounds(dst.ExplicitBounds(), src.ExplicitBounds()) ||
+		dst.BucketCounts().Len() != src.BucketCounts().Len() {
+		return errBucketBoundaryMismatch
+	}
+
+	dst.SetCount(dst.Count() + src.Count())
+
+	for i := 0; i < dst.BucketCounts().Len(); i++ {
+		dst.BucketCounts().SetAt(i, dst.BucketCounts().At(i)+src.BucketCounts().At(i))
+	}
+
+	// The sum, min and max are only known if both sides have them
+	if dst.HasSum() && src.HasSum() {
+		dst.SetSum(dst.Sum() + src.Sum())
+	} else {
+		dst.RemoveSum()
+	}
+
+	if dst.HasMin() && src.HasMin() {
+		if src.Min() < dst.Min() {
+			dst.SetMin(src.Min())
+		}
+	} else {
+		dst.RemoveMin()
+	}
+
+	if dst.HasMax() && src.HasMax() {
+		if src.Max() > dst.Max() {
+			dst.SetMax(src.Max())
+		}
+	} else {
+		dst.RemoveMax()
+	}
+
+	if src.StartTimestamp() < dst.StartTimestamp() {
+		dst.SetStartTimestamp(src.StartTimestamp())
+	}
+	if src.Timestamp() > dst.Timestamp() {
+		dst.SetTimestamp(src.Timestamp())
+	}
+
+	return nil
+}
+
+// equalBounds returns whether two sets of explicit bucket boundaries are
+// identical.
+func equalBounds(a, b pcommon.Float64Slice) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	for i := 0; i < a.Len(); i++ {
+		if a.At(i) != b.At(i) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package cardinalitylimiter
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// appendHistogram appends a histogram data point for a user with the given
+// bucket boundaries and counts.
+func appendHistogram(dataPoints pmetric.HistogramDataPointSlice, user string, bounds []float64, counts []uint64, sum float64) {
+	dp := dataPoints.AppendEmpty()
+	dp.Attributes().PutStr("user.id", user)
+	dp.ExplicitBounds().FromRaw(bounds)
+	dp.BucketCounts().FromRaw(counts)
+	var count uint64
+	for _, c := range counts {
+		count += c
+	}
+	dp.SetCount(count)
+	dp.SetSum(sum)
+}
+
+func TestAggregateHistogramsChecksBoundaries(t *testing.T) {
+	p, _ := newTestMetricsProcessor(t, func(config *Config) {
+		config.AggregationDimensions = []string{"service.name"}
+	})
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	sm := rm.ScopeMetrics().AppendEmpty()
+	metric := sm.Metrics().AppendEmpty()
+	metric.SetName("http.server.duration")
+	dataPoints := metric.SetEmptyHistogram().DataPoints()
+	appendHistogram(dataPoints, "user-0", []float64{1, 2}, []uint64{1, 2, 3}, 10)
+	appendHistogram(dataPoints, "user-1", []float64{1, 2}, []uint64{4, 5, 6}, 20)
+	appendHistogram(dataPoints, "user-2", []float64{5}, []uint64{7, 8}, 30)
+
+	p.aggregateHistograms(rm, sm, metric, nil)
+
+	// The compatible histograms are merged, the mismatched one dropped
+	if dataPoints.Len() != 1 {
+		t.Fatalf("expected 1 aggregated data point, got %d", dataPoints.Len())
+	}
+	merged := dataPoints.At(0)
+	counts := merged.BucketCounts().AsRaw()
+	if len(counts) != 3 || counts[0] != 5 || counts[1] != 7 || counts[2] != 9 {
+		t.Fatalf("expected bucket counts [5 7 9], got %v", counts)
+	}
+	if merged.Count() != 21 || merged.Sum() != 30 {
+		t.Fatalf("expected a count of 21 and a sum of 30, got %d and %v", merged.Count(), merged.Sum())
+	}
+	if _, ok := merged.Attributes().Get("user.id"); ok {
+		t.Fatal("expected only the aggregation dimensions to be kept")
+	}
+	if got := testutil.ToFloat64(p.boundaryMismatchCounter); got != 1 {
+		t.Fatalf("expected 1 boundary mismatch drop counted, got %v", got)
+	}
+}
@@ -0,0 +1,81 @@
+package cardinalitylimiter
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// OverflowValue replaces the value of a span or log record attribute that
+// has more distinct values than allowed, when Action aggregates.
+const OverflowValue = "__overflow__"
+
+// attributeCardinality limits the number of distinct values of each span or
+// log record attribute. Unlike metrics, spans and log records aren't dropped
+// for their attributes; only the attributes with too many distinct values,
+// such as user IDs, are dropped, aggregated or tagged.
+type attributeCardinality struct {
+	action string
+	keep   []string
+
+	// Values seen for each attribute. The first maxValues distinct values of
+	// an attribute are tracked exactly; values beyond them are over the limit.
+	entropy *EntropyCalculator
+	lock    sync.Mutex
+}
+
+// newAttributeCardinality creates an attribute cardinality limit from the
+// configuration.
+func newAttributeCardinality(config *Config) *attributeCardinality {
+	return &attributeCardinality{
+		action:  config.Action,
+		keep:    config.KeepAttributes,
+		entropy: NewEntropyCalculator(config.MaxValuesPerAttribute),
+	}
+}
+
+// limit applies the configured action to the attributes whose value is over
+// the limit, and returns how many there were. With "drop" they are removed,
+// with "tag" they are kept and the overflow attribute is set, and otherwise
+// their value is replaced with OverflowValue. Attributes matching a keep
+// glob are never limited.
+func (a *attributeCardinality) limit(attrs pcommon.Map) int {
+	labels := make(map[string]string, attrs.Len())
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		if k != OverflowAttribute && !matchesAny(a.keep, k) {
+			labels[k] = v.AsString()
+		}
+		return true
+	})
+	if len(labels) == 0 {
+		return 0
+	}
+
+	var over []string
+	a.lock.Lock()
+	a.entropy.AddLabelSet(labels)
+	for name, value := range labels {
+		if !a.entropy.tracked(name, value) {
+			over = append(over, name)
+		}
+	}
+	a.lock.Unlock()
+
+	if len(over) == 0 {
+		return 0
+	}
+
+	switch a.action {
+	case "drop":
+		for _, name := range over {
+			attrs.Remove(name)
+		}
+	case "tag":
+		attrs.PutStr(OverflowAttribute, "true")
+	default:
+		for _, name := range over {
+			attrs.PutStr(name, OverflowValue)
+		}
+	}
+	return len(over)
+}
@@ -1,7 +1,14 @@
 package cardinalitylimiter
 
 import (
+	"fmt"
+	"path"
+	"regexp"
+
 	"go.opentelemetry.io/collector/component"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/droplog"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/priority"
 )
 
 // Config defines the configuration for the CardinalityLimiter processor.
@@ -10,24 +17,155 @@ type Config struct {
 	// Default: 65536
 	MaxUniqueKeySets int `mapstructure:"max_unique_keysets"`
 
+	// KeySetShards is the number of shards the key-set table is split into,
+	// each with its own lock, so concurrent batches rarely contend. The
+	// MaxUniqueKeySets limit applies across all shards.
+	// Default: 1
+	KeySetShards int `mapstructure:"keyset_shards"`
+
 	// Algorithm defines the cardinality control algorithm to use.
 	// Options: "entropy", "lru", "random"
 	// Default: "entropy"
 	Algorithm string `mapstructure:"algorithm"`
 
 	// Action defines what happens when cardinality exceeds the limit.
-	// Options: "drop", "aggregate", "drop_aggregate"
+	// Options: "drop", "aggregate", "drop_aggregate", "tag"
+	// With "tag", over-limit data points are forwarded with the
+	// nrdot.cardinality_overflow attribute set to "true" instead of dropped.
 	// Default: "drop_aggregate"
 	Action string `mapstructure:"action"`
 
+	// DropBelowEntropy is the entropy score below which key-sets beyond the
+	// limit are dropped rather than aggregated.
+	// Default: 0.3
+	DropBelowEntropy float64 `mapstructure:"drop_below_entropy"`
+
+	// AggregateBelowEntropy is the entropy score below which key-sets beyond
+	// the limit are aggregated. Key-sets scoring at or above it are kept even
+	// though the table is over the limit. 1 aggregates all of them.
+	// Default: 1
+	AggregateBelowEntropy float64 `mapstructure:"aggregate_below_entropy"`
+
+	// EvictionStrategy decides which key-sets beyond the limit the entropy
+	// algorithm evicts first.
+	// Options: "lowest_entropy", "least_recent", "least_accessed", "weighted"
+	// Default: "lowest_entropy"
+	EvictionStrategy string `mapstructure:"eviction_strategy"`
+
+	// EvictionWeights weigh the entropy score, recency and access count of
+	// key-sets against each other with the "weighted" strategy.
+	// Default: 1 each
+	EvictionWeights EvictionWeights `mapstructure:"eviction_weights"`
+
 	// AggregationDimensions defines the dimensions to preserve when aggregating.
 	// Only used when Action is "aggregate" or "drop_aggregate".
 	AggregationDimensions []string `mapstructure:"aggregation_dimensions"`
 
+	// DropAttributes are globs of attribute names that are stripped before
+	// key-sets are formed, such as request IDs and timestamps that are pure
+	// cardinality noise.
+	DropAttributes []string `mapstructure:"drop_attributes"`
+
+	// KeepAttributes are globs of attribute names that are always part of the
+	// key-set, even if they also match DropAttributes.
+	KeepAttributes []string `mapstructure:"keep_attributes"`
+
+	// CollisionSafeKeys escapes commas, equals signs and backslashes in
+	// attribute names and values when forming key-sets, so attribute sets
+	// like {a="1,b=2"} and {a="1", b="2"} get distinct keys. Collisions are
+	// counted either way.
+	CollisionSafeKeys bool `mapstructure:"collision_safe_keys"`
+
+	// MaxKeyLength is the maximum length in bytes of a key-set key. The tail
+	// of a longer key is replaced with its hash, bounding the memory each
+	// key-set takes. 0 means no limit.
+	MaxKeyLength int `mapstructure:"max_key_length"`
+
+	// MetricNamePatterns are regular expressions matching dynamic segments of
+	// metric names, such as IDs. Matches are replaced with
+	// MetricNamePlaceholder before cardinality control, so the metrics they
+	// tell apart become one.
+	MetricNamePatterns []string `mapstructure:"metric_name_patterns"`
+
+	// MetricNamePlaceholder replaces segments matching MetricNamePatterns.
+	// Default: "id"
+	MetricNamePlaceholder string `mapstructure:"metric_name_placeholder"`
+
+	// MaxAttributesPerPoint is the maximum number of attributes on a data
+	// point. Excess attributes are removed before the key-set is formed,
+	// keeping those matching KeepAttributes first. 0 means no limit.
+	MaxAttributesPerPoint int `mapstructure:"max_attributes_per_point"`
+
+	// MaxAttributeValueLen is the maximum length in bytes of a string
+	// attribute value on a data point. Longer values are truncated. 0 means
+	// no limit.
+	MaxAttributeValueLen int `mapstructure:"max_attribute_value_len"`
+
 	// MetricsOnly indicates whether to apply cardinality control only to metrics.
 	// If false, the processor will also analyze and limit trace and log attributes.
 	// Default: true
 	MetricsOnly bool `mapstructure:"metrics_only"`
+
+	// MaxValuesPerAttribute is the number of distinct values each span or
+	// log record attribute may take when MetricsOnly is false. Values beyond
+	// it are dropped, aggregated or tagged according to Action.
+	// Default: 100
+	MaxValuesPerAttribute int `mapstructure:"max_values_per_attribute"`
+
+	// MaxTrackedValuesPerLabel is the number of distinct values per label whose
+	// counts are tracked exactly for entropy scoring. Further values are
+	// counted approximately in a fixed-size sketch so memory stays bounded.
+	// Default: 1000
+	MaxTrackedValuesPerLabel int `mapstructure:"max_tracked_values_per_label"`
+
+	// EntropyHalfLifeSec is the time, in seconds, after which the label
+	// value counts used for entropy scoring are halved, so scores follow the
+	// recent distribution of values. 0 keeps counts forever.
+	// Default: 0
+	EntropyHalfLifeSec int `mapstructure:"entropy_half_life_sec"`
+
+	// DecisionCacheSize is the number of recently seen key-sets whose entropy
+	// score is reused instead of recomputed. 0 disables the cache.
+	// Default: 0
+	DecisionCacheSize int `mapstructure:"decision_cache_size"`
+
+	// DecisionCacheTTLSec is how long, in seconds, a cached score is reused.
+	// Default: 10
+	DecisionCacheTTLSec int `mapstructure:"decision_cache_ttl_sec"`
+
+	// ReportPath is the file to which a report of dropped series is written.
+	// An empty path disables the report.
+	ReportPath string `mapstructure:"report_path"`
+
+	// ReportFormat defines the format of the dropped series report.
+	// Options: "csv", "json"
+	// Default: "csv"
+	ReportFormat string `mapstructure:"report_format"`
+
+	// ReportIntervalMinutes is how often the dropped series report is written.
+	// Default: 5
+	ReportIntervalMinutes int `mapstructure:"report_interval_minutes"`
+
+	// ReportMaxFiles is the number of previous reports to keep when rotating.
+	// Default: 5
+	ReportMaxFiles int `mapstructure:"report_max_files"`
+
+	// CriticalData keeps data classified critical by a resource attribute
+	// from being dropped, by leaving it out of cardinality control.
+	CriticalData priority.Config `mapstructure:"critical_data"`
+
+	// DropLog configures the sampled log of dropped series.
+	DropLog droplog.Config `mapstructure:"drop_log"`
+
+	// DropSampleToDLQ is the fraction of dropped data points written to the
+	// DLQ exporter, so a sample of them survives for later analysis. 0
+	// disables it.
+	DropSampleToDLQ float64 `mapstructure:"drop_sample_to_dlq"`
+
+	// DLQExporter is the ID of the metrics exporter the dropped sample is
+	// written to, typically an enhanced_dlq in the same pipeline.
+	// Default: "enhanced_dlq"
+	DLQExporter string `mapstructure:"dlq_exporter"`
 }
 
 // Validate validates the processor configuration.
@@ -36,12 +174,131 @@ func (cfg *Config) Validate() error {
 		cfg.MaxUniqueKeySets = 65536
 	}
 
+	if cfg.KeySetShards <= 0 {
+		cfg.KeySetShards = 1
+	}
+
 	if cfg.Algorithm == "" {
 		cfg.Algorithm = "entropy"
 	}
 
-	if cfg.Action == "" {
+	switch cfg.Action {
+	case "":
 		cfg.Action = "drop_aggregate"
+	case "drop", "aggregate", "drop_aggregate", "tag":
+	default:
+		return fmt.Errorf("invalid action '%s', must be 'drop', 'aggregate', 'drop_aggregate' or 'tag'", cfg.Action)
+	}
+
+	if cfg.DropBelowEntropy < 0 || cfg.DropBelowEntropy > 1 {
+		return fmt.Errorf("drop_below_entropy must be between 0 and 1")
+	}
+
+	if cfg.AggregateBelowEntropy < 0 || cfg.AggregateBelowEntropy > 1 {
+		return fmt.Errorf("aggregate_below_entropy must be between 0 and 1")
+	}
+
+	if cfg.AggregateBelowEntropy < cfg.DropBelowEntropy {
+		return fmt.Errorf("aggregate_below_entropy must not be less than drop_below_entropy")
+	}
+
+	switch cfg.EvictionStrategy {
+	case "":
+		cfg.EvictionStrategy = EvictionLowestEntropy
+	case EvictionLowestEntropy, EvictionLeastRecent, EvictionLeastAccessed, EvictionWeighted:
+	default:
+		return fmt.Errorf("invalid eviction_strategy '%s', must be '%s', '%s', '%s' or '%s'",
+			cfg.EvictionStrategy, EvictionLowestEntropy, EvictionLeastRecent, EvictionLeastAccessed, EvictionWeighted)
+	}
+
+	weights := cfg.EvictionWeights
+	if weights.Entropy < 0 || weights.Recency < 0 || weights.Access < 0 {
+		return fmt.Errorf("eviction_weights must not be negative")
+	}
+	if weights.Entropy+weights.Recency+weights.Access == 0 {
+		cfg.EvictionWeights = EvictionWeights{Entropy: 1, Recency: 1, Access: 1}
+	}
+
+	if cfg.MaxAttributesPerPoint < 0 {
+		return fmt.Errorf("max_attributes_per_point must not be negative")
+	}
+
+	if cfg.MaxAttributeValueLen < 0 {
+		return fmt.Errorf("max_attribute_value_len must not be negative")
+	}
+
+	if cfg.MaxKeyLength != 0 && cfg.MaxKeyLength < minKeyLength {
+		return fmt.Errorf("max_key_length must be 0 or at least %d", minKeyLength)
+	}
+
+	if cfg.MaxValuesPerAttribute <= 0 {
+		cfg.MaxValuesPerAttribute = 100
+	}
+
+	if cfg.MaxTrackedValuesPerLabel <= 0 {
+		cfg.MaxTrackedValuesPerLabel = 1000
+	}
+
+	if cfg.EntropyHalfLifeSec < 0 {
+		return fmt.Errorf("entropy_half_life_sec must not be negative")
+	}
+
+	if cfg.DecisionCacheSize < 0 {
+		return fmt.Errorf("decision_cache_size must not be negative")
+	}
+
+	if cfg.DecisionCacheTTLSec <= 0 {
+		cfg.DecisionCacheTTLSec = 10
+	}
+
+	for _, glob := range append(append([]string{}, cfg.DropAttributes...), cfg.KeepAttributes...) {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid attribute glob '%s': %w", glob, err)
+		}
+	}
+
+	for _, pattern := range cfg.MetricNamePatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid metric_name_patterns entry '%s': %w", pattern, err)
+		}
+	}
+
+	if cfg.MetricNamePlaceholder == "" {
+		cfg.MetricNamePlaceholder = "id"
+	}
+
+	if cfg.ReportFormat == "" {
+		cfg.ReportFormat = "csv"
+	} else if cfg.ReportFormat != "csv" && cfg.ReportFormat != "json" {
+		return fmt.Errorf("invalid report_format '%s', must be 'csv' or 'json'", cfg.ReportFormat)
+	}
+
+	if cfg.ReportIntervalMinutes <= 0 {
+		cfg.ReportIntervalMinutes = 5
+	}
+
+	if cfg.ReportMaxFiles < 0 {
+		cfg.ReportMaxFiles = 5
+	}
+
+	if err := cfg.CriticalData.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.DropLog.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.DropSampleToDLQ < 0 || cfg.DropSampleToDLQ > 1 {
+		return fmt.Errorf("drop_sample_to_dlq must be between 0 and 1")
+	}
+
+	if cfg.DLQExporter == "" {
+		cfg.DLQExporter = "enhanced_dlq"
+	}
+	var dlqID component.ID
+	if err := dlqID.UnmarshalText([]byte(cfg.DLQExporter)); err != nil {
+		return fmt.Errorf("invalid dlq_exporter '%s': %w", cfg.DLQExporter, err)
 	}
 
 	return nil
@@ -51,9 +308,25 @@ func (cfg *Config) Validate() error {
 func CreateDefaultConfig() component.Config {
 	return &Config{
 		MaxUniqueKeySets:      65536,
+		KeySetShards:          1,
 		Algorithm:             "entropy",
 		Action:                "drop_aggregate",
 		AggregationDimensions: []string{"service.name", "host.name"},
 		MetricsOnly:           true,
+		ReportFormat:          "csv",
+		ReportIntervalMinutes: 5,
+		ReportMaxFiles:        5,
+
+		MaxTrackedValuesPerLabel: 1000,
+		MaxValuesPerAttribute:    100,
+		MetricNamePlaceholder:    "id",
+		DecisionCacheTTLSec:      10,
+		DropBelowEntropy:         0.3,
+		AggregateBelowEntropy:    1,
+		EvictionStrategy:         EvictionLowestEntropy,
+		EvictionWeights:          EvictionWeights{Entropy: 1, Recency: 1, Access: 1},
+		CriticalData:             priority.DefaultConfig(),
+		DropLog:                  droplog.DefaultConfig(),
+		DLQExporter:              "enhanced_dlq",
 	}
 }
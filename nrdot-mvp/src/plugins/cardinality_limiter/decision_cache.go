@@ -0,0 +1,98 @@
+package cardinalitylimiter
+
+import (
+	"container/list"
+	"time"
+)
+
+// decisionCache memoizes the entropy score of recently seen key-sets for a
+// TTL, so key-sets arriving many times a second aren't rescored each time.
+// It is least recently used first out once full. It is not safe for
+// concurrent use. A nil cache caches nothing.
+type decisionCache struct {
+	capacity int
+	ttl      time.Duration
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+// decisionCacheEntry is a cached score and when it stops being valid.
+type decisionCacheEntry struct {
+	key     string
+	score   float64
+	expires time.Time
+}
+
+// newDecisionCache creates a decision cache from the configuration, or
+// returns nil if it is disabled.
+func newDecisionCache(config *Config) *decisionCache {
+	if config.DecisionCacheSize <= 0 {
+		return nil
+	}
+	return &decisionCache{
+		capacity: config.DecisionCacheSize,
+		ttl:      time.Duration(config.DecisionCacheTTLSec) * time.Second,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element, config.DecisionCacheSize),
+	}
+}
+
+// get returns the cached score for a key-set if it hasn't expired.
+func (c *decisionCache) get(key string, now time.Time) (float64, bool) {
+	if c == nil {
+		return 0, false
+	}
+
+	elem, exists := c.entries[key]
+	if !exists {
+		return 0, false
+	}
+
+	entry := elem.Value.(*decisionCacheEntry)
+	if !now.Before(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return 0, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.score, true
+}
+
+// put caches the score for a key-set, evicting the least recently used entry
+// if the cache is full.
+func (c *decisionCache) put(key string, score float64, now time.Time) {
+	if c == nil {
+		return
+	}
+
+	if elem, exists := c.entries[key]; exists {
+		entry := elem.Value.(*decisionCacheEntry)
+		entry.score = score
+		entry.expires = now.Add(c.ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	if c.order.Len() >= c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*decisionCacheEntry).key)
+	}
+
+	c.entries[key] = c.order.PushFront(&decisionCacheEntry{
+		key:     key,
+		score:   score,
+		expires: now.Add(c.ttl),
+	})
+}
+
+// clear removes every cached score.
+func (c *decisionCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.order.Init()
+	c.entries = make(map[string]*list.Element, c.capacity)
+}
@@ -0,0 +1,107 @@
+package cardinalitylimiter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+func TestDecisionCacheExpiresAfterTTL(t *testing.T) {
+	cache := newDecisionCache(&Config{DecisionCacheSize: 2, DecisionCacheTTLSec: 10})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cache.put("a", 0.5, now)
+	if score, ok := cache.get("a", now.Add(9*time.Second)); !ok || score != 0.5 {
+		t.Fatalf("expected the cached score within the TTL, got %v, %v", score, ok)
+	}
+	if _, ok := cache.get("a", now.Add(10*time.Second)); ok {
+		t.Fatal("expected the score to be recomputed after the TTL")
+	}
+
+	// The least recently used key-set is evicted once full
+	cache.put("a", 0.5, now)
+	cache.put("b", 0.6, now)
+	cache.get("a", now)
+	cache.put("c", 0.7, now)
+	if _, ok := cache.get("b", now); ok {
+		t.Fatal("expected the least recently used key-set to be evicted")
+	}
+	if _, ok := cache.get("a", now); !ok {
+		t.Fatal("expected the recently used key-set to stay cached")
+	}
+}
+
+func TestRecordKeySetRescoresAfterTTL(t *testing.T) {
+	p, _ := newTestMetricsProcessor(t, func(config *Config) {
+		config.DecisionCacheSize = 16
+		config.DecisionCacheTTLSec = 10
+	})
+	fake := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	p.clock = fake
+
+	resourceAttrs := pcommon.NewMap()
+	resourceAttrs.PutStr("service.name", "checkout")
+	attrs := pcommon.NewMap()
+	attrs.PutStr("user.id", "user-1")
+	key := p.recordKeySet(resourceAttrs, attrs)
+
+	// A cached score is reused until it expires
+	p.keySets.shard(key).decisions.put(key, 42, fake.Now())
+	fake.Advance(9 * time.Second)
+	p.recordKeySet(resourceAttrs, attrs)
+	if score := p.keySets.snapshot()[key].entropyScore; score != 42 {
+		t.Fatalf("expected the cached score within the TTL, got %v", score)
+	}
+
+	fake.Advance(time.Second)
+	p.recordKeySet(resourceAttrs, attrs)
+	if score := p.keySets.snapshot()[key].entropyScore; score == 42 {
+		t.Fatal("expected the score to be recomputed after the TTL")
+	}
+}
+
+// benchmarkRecordKeySet records a few key-sets over and over, as a metric
+// with stable attributes arriving many times a second does.
+func benchmarkRecordKeySet(b *testing.B, cacheSize int) {
+	config := CreateDefaultConfig().(*Config)
+	config.DecisionCacheSize = cacheSize
+	if err := config.Validate(); err != nil {
+		b.Fatalf("invalid config: %v", err)
+	}
+	p, err := newMetricsProcessor(zap.NewNop(), config, component.NewID(typeStr), &metricsSink{})
+	if err != nil {
+		b.Fatalf("failed to create processor: %v", err)
+	}
+	defer p.Shutdown(context.Background())
+
+	resourceAttrs := pcommon.NewMap()
+	resourceAttrs.PutStr("service.name", "checkout")
+	resourceAttrs.PutStr("host.name", "host-1")
+	attrs := make([]pcommon.Map, 10)
+	for i := range attrs {
+		attrs[i] = pcommon.NewMap()
+		attrs[i].PutStr("http.route", fmt.Sprintf("/api/%d", i))
+		attrs[i].PutStr("http.method", "GET")
+		attrs[i].PutStr("http.status_code", "200")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.recordKeySet(resourceAttrs, attrs[i%len(attrs)])
+	}
+}
+
+func BenchmarkRecordKeySetUncached(b *testing.B) {
+	benchmarkRecordKeySet(b, 0)
+}
+
+func BenchmarkRecordKeySetCached(b *testing.B) {
+	benchmarkRecordKeySet(b, 64)
+}
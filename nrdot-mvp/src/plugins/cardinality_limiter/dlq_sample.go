@@ -0,0 +1,211 @@
+package cardinalitylimiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
+)
+
+// dropSampler writes a fraction of the data points the limiter drops to a
+// DLQ exporter, so a sample of the dropped data survives for post-incident
+// analysis. A nil dropSampler samples nothing.
+type dropSampler struct {
+	logger   *zap.Logger
+	fraction float64
+	exporter consumer.Metrics
+
+	// Dropped data points seen, used to sample a deterministic fraction of
+	// them
+	seen  int64
+	mutex sync.Mutex
+
+	sampledCounter prometheus.Counter
+}
+
+// newDropSampler creates a drop sampler, or returns nil if sampling dropped
+// data to the DLQ is disabled.
+func newDropSampler(logger *zap.Logger, config *Config, counters *promreg.Registry) *dropSampler {
+	if config.DropSampleToDLQ <= 0 {
+		return nil
+	}
+
+	s := &dropSampler{
+		logger:   logger,
+		fraction: config.DropSampleToDLQ,
+	}
+
+	s.sampledCounter = counters.Counter(prometheus.CounterOpts{
+		Name: "otelcol_cardinality_limiter_dropped_points_sampled_to_dlq_total",
+		Help: "Dropped data points written to the DLQ exporter by drop_sample_to_dlq",
+	})
+
+	return s
+}
+
+// start resolves the DLQ exporter the sample is written to.
+func (s *dropSampler) start(host component.Host, exporterID string) error {
+	if s == nil {
+		return nil
+	}
+
+	var id component.ID
+	if err := id.UnmarshalText([]byte(exporterID)); err != nil {
+		return fmt.Errorf("invalid dlq_exporter '%s': %w", exporterID, err)
+	}
+
+	exp, exists := host.GetExporters()[component.DataTypeMetrics][id]
+	if !exists {
+		return fmt.Errorf("dlq_exporter '%s' is not configured as a metrics exporter", exporterID)
+	}
+
+	metricsExporter, ok := exp.(consumer.Metrics)
+	if !ok {
+		return fmt.Errorf("dlq_exporter '%s' does not accept metrics", exporterID)
+	}
+
+	s.exporter = metricsExporter
+	return nil
+}
+
+// sample returns whether the next dropped data point is written to the DLQ,
+// each time the sampled fraction of drops seen reaches the next whole number.
+func (s *dropSampler) sample() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.seen++
+	return int64(float64(s.seen)*s.fraction) != int64(float64(s.seen-1)*s.fraction)
+}
+
+// newSpill returns an empty sample for a batch, or nil if sampling is
+// disabled.
+func (s *dropSampler) newSpill() *dropSpill {
+	if s == nil {
+		return nil
+	}
+	return &dropSpill{sampler: s, md: pmetric.NewMetrics()}
+}
+
+// write sends a batch's sample to the DLQ exporter. Failures are logged
+// rather than returned, since the sample is best effort and the batch itself
+// is unaffected.
+func (s *dropSampler) write(ctx context.Context, spill *dropSpill) {
+	if s == nil || spill == nil || spill.points == 0 {
+		return
+	}
+	if s.exporter == nil {
+		s.logger.Warn("No DLQ exporter available for the dropped data sample")
+		return
+	}
+
+	if err := s.exporter.ConsumeMetrics(ctx, spill.md); err != nil {
+		s.logger.Warn("Failed to write dropped data sample to the DLQ", zap.Error(err))
+		return
+	}
+	s.sampledCounter.Add(float64(spill.points))
+}
+
+// dropSpill collects the sample of a batch's dropped data points, under
+// copies of the resource, scope and metric they were dropped from.
+type dropSpill struct {
+	sampler *dropSampler
+	md      pmetric.Metrics
+	points  int
+}
+
+// metricCopier returns a function returning the copy of a metric in the
+// spill, under copies of its resource and scope, without data points. The
+// copy is made the first time the function is called.
+func (spill *dropSpill) metricCopier(rm pmetric.ResourceMetrics, sm pmetric.ScopeMetrics, metric pmetric.Metric) func() pmetric.Metric {
+	var spillMetric pmetric.Metric
+	created := false
+	return func() pmetric.Metric {
+		if created {
+			return spillMetric
+		}
+
+		spillRM := spill.md.ResourceMetrics().AppendEmpty()
+		rm.Resource().CopyTo(spillRM.Resource())
+		spillRM.SetSchemaUrl(rm.SchemaUrl())
+
+		spillSM := spillRM.ScopeMetrics().AppendEmpty()
+		sm.Scope().CopyTo(spillSM.Scope())
+		spillSM.SetSchemaUrl(sm.SchemaUrl())
+
+		spillMetric = spillSM.Metrics().AppendEmpty()
+		spillMetric.SetName(metric.Name())
+		spillMetric.SetDescription(metric.Description())
+		spillMetric.SetUnit(metric.Unit())
+		switch metric.Type() {
+		case pmetric.MetricTypeGauge:
+			spillMetric.SetEmptyGauge()
+		case pmetric.MetricTypeSum:
+			sum := spillMetric.SetEmptySum()
+			sum.SetAggregationTemporality(metric.Sum().AggregationTemporality())
+			sum.SetIsMonotonic(metric.Sum().IsMonotonic())
+		case pmetric.MetricTypeHistogram:
+			histogram := spillMetric.SetEmptyHistogram()
+			histogram.SetAggregationTemporality(metric.Histogram().AggregationTemporality())
+		case pmetric.MetricTypeSummary:
+			spillMetric.SetEmptySummary()
+		}
+
+		created = true
+		return spillMetric
+	}
+}
+
+// histogramSampler returns a function adding a sample of the histogram data
+// points dropped from a metric to the spill, or nil if the spill is nil.
+func (spill *dropSpill) histogramSampler(rm pmetric.ResourceMetrics, sm pmetric.ScopeMetrics, metric pmetric.Metric) func(pmetric.HistogramDataPoint) {
+	if spill == nil {
+		return nil
+	}
+
+	spillMetric := spill.metricCopier(rm, sm, metric)
+	return func(dp pmetric.HistogramDataPoint) {
+		if !spill.sampler.sample() {
+			return
+		}
+
+		dp.CopyTo(spillMetric().Histogram().DataPoints().AppendEmpty())
+		spill.points++
+	}
+}
+
+// dataPointSampler returns a function adding a sample of a metric's data
+// points, by index, to the spill, or nil if the spill is nil.
+func (spill *dropSpill) dataPointSampler(rm pmetric.ResourceMetrics, sm pmetric.ScopeMetrics, metric pmetric.Metric) func(int) {
+	if spill == nil {
+		return nil
+	}
+
+	spillMetric := spill.metricCopier(rm, sm, metric)
+	return func(i int) {
+		if !spill.sampler.sample() {
+			return
+		}
+
+		switch metric.Type() {
+		case pmetric.MetricTypeGauge:
+			metric.Gauge().DataPoints().At(i).CopyTo(spillMetric().Gauge().DataPoints().AppendEmpty())
+		case pmetric.MetricTypeSum:
+			metric.Sum().DataPoints().At(i).CopyTo(spillMetric().Sum().DataPoints().AppendEmpty())
+		case pmetric.MetricTypeHistogram:
+			metric.Histogram().DataPoints().At(i).CopyTo(spillMetric().Histogram().DataPoints().AppendEmpty())
+		case pmetric.MetricTypeSummary:
+			metric.Summary().DataPoints().At(i).CopyTo(spillMetric().Summary().DataPoints().AppendEmpty())
+		default:
+			return
+		}
+		spill.points++
+	}
+}
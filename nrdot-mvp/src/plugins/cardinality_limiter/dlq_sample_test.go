@@ -0,0 +1,168 @@
+package cardinalitylimiter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
+)
+
+// metricsSink records the metrics written to it, standing in for the DLQ
+// exporter.
+type metricsSink struct {
+	batches []pmetric.Metrics
+}
+
+func (s *metricsSink) ConsumeMetrics(_ context.Context, md pmetric.Metrics) error {
+	s.batches = append(s.batches, md)
+	return nil
+}
+
+func (s *metricsSink) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{}
+}
+
+func (s *metricsSink) dataPoints() int {
+	total := 0
+	for _, md := range s.batches {
+		total += md.DataPointCount()
+	}
+	return total
+}
+
+func TestDropSampleWritesFractionToDLQ(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.DropSampleToDLQ = 0.25
+
+	counters := promreg.New(zap.NewNop(), prometheus.Labels{"processor": component.NewID(typeStr).String()})
+	defer counters.Unregister()
+
+	sampler := newDropSampler(zap.NewNop(), config, counters)
+	sink := &metricsSink{}
+	sampler.exporter = sink
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	sm := rm.ScopeMetrics().AppendEmpty()
+	metric := sm.Metrics().AppendEmpty()
+	metric.SetName("http.server.duration")
+	metric.SetEmptyHistogram()
+
+	// Drop 100 data points over four batches
+	for batch := 0; batch < 4; batch++ {
+		spill := sampler.newSpill()
+		drop := spill.histogramSampler(rm, sm, metric)
+		for i := 0; i < 25; i++ {
+			dp := pmetric.NewHistogramDataPoint()
+			dp.SetCount(uint64(batch*25 + i))
+			drop(dp)
+		}
+		sampler.write(context.Background(), spill)
+	}
+
+	if got := sink.dataPoints(); got != 25 {
+		t.Fatalf("expected 25 of 100 dropped data points in the DLQ, got %d", got)
+	}
+
+	// Sampled data points keep the resource and metric they were dropped from
+	written := sink.batches[0].ResourceMetrics().At(0)
+	if name, _ := written.Resource().Attributes().Get("service.name"); name.Str() != "checkout" {
+		t.Errorf("expected the sampled resource to be kept, got %v", written.Resource().Attributes().AsRaw())
+	}
+	if name := written.ScopeMetrics().At(0).Metrics().At(0).Name(); name != "http.server.duration" {
+		t.Errorf("expected the sampled metric to be kept, got %q", name)
+	}
+}
+
+func TestDropSampleDisabled(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.DropSampleToDLQ = 0
+
+	sampler := newDropSampler(zap.NewNop(), config, newProcessorCounters(zap.NewNop(), "test"))
+	if sampler != nil {
+		t.Fatal("expected no drop sampler when drop_sample_to_dlq is 0")
+	}
+	if spill := sampler.newSpill(); spill != nil {
+		t.Fatal("expected no spill from a disabled sampler")
+	}
+}
+
+func TestConsumeMetricsSamplesEvictedSeries(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.Action = "drop"
+	config.MaxUniqueKeySets = 5
+	config.DropSampleToDLQ = 1
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+
+	next := &metricsSink{}
+	p, err := newMetricsProcessor(zap.NewNop(), config, component.NewID(typeStr), next)
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+	defer p.Shutdown(context.Background())
+	dlq := &metricsSink{}
+	p.dropSample.exporter = dlq
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	gauge := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	gauge.SetName("sessions")
+	dataPoints := gauge.SetEmptyGauge().DataPoints()
+	for i := 0; i < 20; i++ {
+		dp := dataPoints.AppendEmpty()
+		dp.Attributes().PutStr("user.id", fmt.Sprintf("user-%d", i))
+		dp.SetIntValue(int64(i))
+	}
+	if err := p.ConsumeMetrics(context.Background(), md); err != nil {
+		t.Fatalf("failed to consume metrics: %v", err)
+	}
+
+	evicted := 20 - p.keySets.len()
+	if evicted <= 0 {
+		t.Fatalf("expected series over the limit to be evicted, %d remain", p.keySets.len())
+	}
+	if got := dlq.dataPoints(); got != evicted {
+		t.Fatalf("expected the %d evicted series in the DLQ, got %d data points", evicted, got)
+	}
+
+	// Evicted series are removed from the batch, so only kept series are
+	// forwarded
+	if got := next.dataPoints(); got != p.keySets.len() {
+		t.Fatalf("expected the %d kept series to be forwarded, got %d data points", p.keySets.len(), got)
+	}
+	forwarded := next.batches[0].ResourceMetrics().At(0)
+	kept := forwarded.ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints()
+	for i := 0; i < kept.Len(); i++ {
+		key, _, _ := p.filter.buildKeySet(forwarded.Resource().Attributes(), kept.At(i).Attributes())
+		if !p.keySets.contains(key) {
+			t.Fatalf("expected only kept series to be forwarded, got %q", key)
+		}
+	}
+
+	// Only evicted series are sampled, under their resource and metric
+	written := dlq.batches[0].ResourceMetrics().At(0)
+	if name, _ := written.Resource().Attributes().Get("service.name"); name.Str() != "checkout" {
+		t.Errorf("expected the sampled resource to be kept, got %v", written.Resource().Attributes().AsRaw())
+	}
+	metric := written.ScopeMetrics().At(0).Metrics().At(0)
+	if metric.Name() != "sessions" || metric.Type() != pmetric.MetricTypeGauge {
+		t.Fatalf("expected the sessions gauge to be sampled, got %q of type %v", metric.Name(), metric.Type())
+	}
+	for i := 0; i < metric.Gauge().DataPoints().Len(); i++ {
+		key, _, _ := p.filter.buildKeySet(written.Resource().Attributes(), metric.Gauge().DataPoints().At(i).Attributes())
+		if p.keySets.contains(key) {
+			t.Fatalf("expected only evicted series to be sampled, got %q", key)
+		}
+	}
+}
@@ -2,38 +2,153 @@ package cardinalitylimiter
 
 import (
 	"math"
-	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// Counts decay in steps of a tenth of the half-life, and exactly tracked
+// values whose decayed count falls below entropyPruneBelow are forgotten.
+const (
+	entropyDecaySteps = 10
+	entropyPruneBelow = 0.5
 )
 
 // EntropyCalculator calculates the entropy score for attribute sets.
 type EntropyCalculator struct {
 	// Historical data for calculating entropy
-	labelValues map[string]map[string]int // Maps label name -> value -> count
-	totalCount  int
+	labelValues map[string]map[string]float64 // Maps label name -> value -> count
+	totalCount  float64
+
+	// Values seen after a label reaches maxValuesPerLabel are counted
+	// approximately so memory stays bounded
+	maxValuesPerLabel int
+	overflow          map[string]*countMinSketch
+
+	// With a half-life, counts decay exponentially so scores follow the
+	// recent distribution of values. 0 keeps counts forever.
+	halfLife  time.Duration
+	clock     clock.Clock
+	lastDecay time.Time
 }
 
-// NewEntropyCalculator creates a new entropy calculator.
-func NewEntropyCalculator() *EntropyCalculator {
+// NewEntropyCalculator creates a new entropy calculator that tracks at most
+// maxValuesPerLabel values exactly for each label.
+func NewEntropyCalculator(maxValuesPerLabel int) *EntropyCalculator {
+	return NewDecayingEntropyCalculator(maxValuesPerLabel, 0, clock.Real())
+}
+
+// NewDecayingEntropyCalculator creates an entropy calculator whose counts
+// halve every halfLife, so a value that stops appearing gradually regains a
+// high entropy score. A halfLife of 0 disables decay.
+func NewDecayingEntropyCalculator(maxValuesPerLabel int, halfLife time.Duration, c clock.Clock) *EntropyCalculator {
 	return &EntropyCalculator{
-		labelValues: make(map[string]map[string]int),
-		totalCount:  0,
+		labelValues:       make(map[string]map[string]float64),
+		totalCount:        0,
+		maxValuesPerLabel: maxValuesPerLabel,
+		overflow:          make(map[string]*countMinSketch),
+		halfLife:          halfLife,
+		clock:             c,
+		lastDecay:         c.Now(),
 	}
 }
 
 // AddLabelSet adds a set of labels to the historical data.
 func (e *EntropyCalculator) AddLabelSet(labelSet map[string]string) {
+	e.decay()
 	e.totalCount++
-	
+
 	for name, value := range labelSet {
-		if _, exists := e.labelValues[name]; !exists {
-			e.labelValues[name] = make(map[string]int)
+		valueMap, exists := e.labelValues[name]
+		if !exists {
+			valueMap = make(map[string]float64)
+			e.labelValues[name] = valueMap
+		}
+
+		// Values already tracked, or while under the cap, are counted exactly
+		if _, tracked := valueMap[value]; tracked || len(valueMap) < e.maxValuesPerLabel {
+			valueMap[value]++
+			continue
+		}
+
+		sketch, exists := e.overflow[name]
+		if !exists {
+			sketch = newCountMinSketch()
+			e.overflow[name] = sketch
+		}
+		sketch.add(value)
+	}
+}
+
+// decay scales every count down by the time elapsed since the last decay,
+// once at least a step of the half-life has passed. Exactly tracked values
+// whose count has decayed away are forgotten, making room for new values.
+func (e *EntropyCalculator) decay() {
+	if e.halfLife <= 0 {
+		return
+	}
+
+	now := e.clock.Now()
+	elapsed := now.Sub(e.lastDecay)
+	if elapsed < e.halfLife/entropyDecaySteps {
+		return
+	}
+	e.lastDecay = now
+
+	factor := math.Pow(0.5, float64(elapsed)/float64(e.halfLife))
+	e.totalCount *= factor
+
+	for name, valueMap := range e.labelValues {
+		for value, count := range valueMap {
+			count *= factor
+			if count < entropyPruneBelow {
+				delete(valueMap, value)
+				continue
+			}
+			valueMap[value] = count
+		}
+
+		// Forget labels with nothing left to remember
+		if len(valueMap) == 0 && e.overflow[name] == nil {
+			delete(e.labelValues, name)
+		}
+	}
+
+	for _, sketch := range e.overflow {
+		sketch.scale(factor)
+	}
+}
+
+// valueCount returns the number of times a value has been seen for a label,
+// which is approximate for values beyond the tracking cap.
+func (e *EntropyCalculator) valueCount(name string, value string) (float64, bool) {
+	valueMap, exists := e.labelValues[name]
+	if !exists {
+		return 0, false
+	}
+
+	if count, tracked := valueMap[value]; tracked {
+		return count, true
+	}
+
+	if sketch, exists := e.overflow[name]; exists {
+		if count := sketch.estimate(value); count > 0 {
+			return float64(count), true
 		}
-		
-		e.labelValues[name][value]++
 	}
+
+	return 0, true
+}
+
+// tracked returns whether a value is one of the values of a label counted
+// exactly, as opposed to in the overflow sketch.
+func (e *EntropyCalculator) tracked(name string, value string) bool {
+	_, tracked := e.labelValues[name][value]
+	return tracked
 }
 
 // AddAttributes adds a set of attributes to the historical data.
@@ -48,66 +163,65 @@ func (e *EntropyCalculator) CalculateEntropyScore(labelSet map[string]string) fl
 	if e.totalCount == 0 {
 		return 0
 	}
-	
+
 	// Calculate information content of each label based on historical data
 	labelScores := make(map[string]float64)
 	for name, value := range labelSet {
-		valueMap, exists := e.labelValues[name]
+		count, exists := e.valueCount(name, value)
 		if !exists {
 			// New label name, high entropy
 			labelScores[name] = 1.0
 			continue
 		}
-		
-		count, exists := valueMap[value]
-		if !exists {
+
+		if count == 0 {
 			// New value for this label, high entropy
 			labelScores[name] = 1.0
 			continue
 		}
-		
+
 		// Calculate probability of this value occurring
-		probability := float64(count) / float64(e.totalCount)
-		
+		probability := count / e.totalCount
+
 		// Calculate entropy (information content) of this label
 		// Rare values have higher entropy (more information)
 		entropy := -math.Log2(probability)
-		
+
 		// Normalize to 0-1 range
 		normalizedEntropy := math.Min(1.0, entropy/16.0) // Cap at 16 bits of entropy
-		
+
 		labelScores[name] = normalizedEntropy
 	}
-	
+
 	// Calculate the average entropy score across all labels
 	var totalScore float64
 	for _, score := range labelScores {
 		totalScore += score
 	}
-	
+
 	// Also consider the number of labels as a factor
 	// More labels might indicate more specificity
 	labelCount := float64(len(labelSet))
 	labelCountFactor := math.Min(1.0, labelCount/10.0) // Normalize to 0-1 range, cap at 10 labels
-	
+
 	// Combine both factors
 	if len(labelScores) > 0 {
 		averageScore := totalScore / float64(len(labelScores))
 		return averageScore * (0.8 + 0.2*labelCountFactor) // 80% entropy, 20% label count
 	}
-	
+
 	return 0
 }
 
 // attributesToMap converts attributes to a string map.
 func attributesToMap(attrs pcommon.Map) map[string]string {
 	result := make(map[string]string, attrs.Len())
-	
+
 	attrs.Range(func(k string, v pcommon.Value) bool {
 		result[k] = valueToString(v)
 		return true
 	})
-	
+
 	return result
 }
 
@@ -117,11 +231,11 @@ func valueToString(v pcommon.Value) string {
 	case pcommon.ValueTypeStr:
 		return v.Str()
 	case pcommon.ValueTypeInt:
-		return string(v.Int())
+		return strconv.FormatInt(v.Int(), 10)
 	case pcommon.ValueTypeDouble:
-		return string(v.Double())
+		return strconv.FormatFloat(v.Double(), 'g', -1, 64)
 	case pcommon.ValueTypeBool:
-		return string(v.Bool())
+		return strconv.FormatBool(v.Bool())
 	case pcommon.ValueTypeMap:
 		// Simplified handling of maps for entropy calculation
 		var parts []string
@@ -142,61 +256,73 @@ func valueToString(v pcommon.Value) string {
 	}
 }
 
+// EntropyThresholds decide what happens to key-sets beyond the limit by their
+// entropy score.
+type EntropyThresholds struct {
+	// Key-sets scoring below DropBelow are dropped
+	DropBelow float64
+
+	// Key-sets scoring below AggregateBelow are aggregated, and those at or
+	// above it are kept over the limit. 1 aggregates every remaining key-set.
+	AggregateBelow float64
+
+	// EvictionStrategy orders the key-sets considered for eviction, lowest
+	// entropy first if empty
+	EvictionStrategy string
+	EvictionWeights  EvictionWeights
+}
+
 // EntropyBasedCardinalityControl applies entropy-based cardinality control.
 func EntropyBasedCardinalityControl(
 	keySetTable map[string]keySetInfo,
 	maxKeySets int,
+	thresholds EntropyThresholds,
 ) ([]string, []string) {
 	// If we're under the limit, no need to drop/aggregate anything
 	if len(keySetTable) <= maxKeySets {
 		return nil, nil
 	}
-	
+
 	// Calculate how many to drop
 	toDrop := len(keySetTable) - maxKeySets
-	
+
 	// Convert map to slice for sorting
 	keySets := make([]keySetEntry, 0, len(keySetTable))
 	for key, info := range keySetTable {
 		keySets = append(keySets, keySetEntry{
-			key:         key,
+			key:          key,
 			entropyScore: info.entropyScore,
-			lastSeen:    info.lastSeen,
-			accessCount: info.accessCount,
+			lastSeen:     info.lastSeen,
+			accessCount:  info.accessCount,
 		})
 	}
-	
-	// Sort by entropy score (lowest first - these will be dropped)
-	sort.Slice(keySets, func(i, j int) bool {
-		// Primary sort by entropy score
-		if keySets[i].entropyScore != keySets[j].entropyScore {
-			return keySets[i].entropyScore < keySets[j].entropyScore
-		}
-		
-		// Secondary sort by access count
-		if keySets[i].accessCount != keySets[j].accessCount {
-			return keySets[i].accessCount < keySets[j].accessCount
-		}
-		
-		// Tertiary sort by last seen (older first)
-		return keySets[i].lastSeen < keySets[j].lastSeen
-	})
-	
+
+	// Sort the key-sets to evict first to the front
+	sortForEviction(keySets, thresholds.EvictionStrategy, thresholds.EvictionWeights)
+
 	// Select the keys to drop and aggregate
-	toDropKeys := make([]string, toDrop)
+	toDropKeys := make([]string, 0, toDrop)
 	toAggregateKeys := make([]string, 0, toDrop)
-	
-	// Take the first 'toDrop' entries for dropping or aggregation
-	for i := 0; i < toDrop; i++ {
-		toDropKeys[i] = keySets[i].key
-		
-		// If the entropy score is above a threshold, consider it for aggregation
-		// instead of dropping completely
-		if keySets[i].entropyScore > 0.3 { // Threshold for aggregation
+
+	// Take the first 'toDrop' entries that may be evicted for dropping or
+	// aggregation
+	for i := 0; i < len(keySets) && len(toDropKeys) < toDrop; i++ {
+		score := keySets[i].entropyScore
+
+		// High-entropy key-sets are kept over the limit
+		if thresholds.AggregateBelow < 1 && score >= thresholds.AggregateBelow {
+			continue
+		}
+
+		toDropKeys = append(toDropKeys, keySets[i].key)
+
+		// If the entropy score is above the drop threshold, consider it for
+		// aggregation instead of dropping completely
+		if score >= thresholds.DropBelow {
 			toAggregateKeys = append(toAggregateKeys, keySets[i].key)
 		}
 	}
-	
+
 	return toDropKeys, toAggregateKeys
 }
 
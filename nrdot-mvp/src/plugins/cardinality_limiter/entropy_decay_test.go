@@ -0,0 +1,78 @@
+package cardinalitylimiter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+func TestEntropyRisesAfterValueStopsAppearing(t *testing.T) {
+	fake := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	decaying := NewDecayingEntropyCalculator(1000, time.Hour, fake)
+	forever := NewEntropyCalculator(1000)
+
+	// A value that makes up most of the traffic scores low
+	common := map[string]string{"region": "us-east-1"}
+	for i := 0; i < 900; i++ {
+		decaying.AddLabelSet(common)
+		forever.AddLabelSet(common)
+	}
+	for i := 0; i < 100; i++ {
+		other := map[string]string{"region": fmt.Sprintf("region-%d", i)}
+		decaying.AddLabelSet(other)
+		forever.AddLabelSet(other)
+	}
+	initial := decaying.CalculateEntropyScore(common)
+
+	// It stops appearing while other values keep arriving
+	previous := initial
+	for hour := 1; hour <= 6; hour++ {
+		fake.Advance(time.Hour)
+		for i := 0; i < 100; i++ {
+			other := map[string]string{"region": fmt.Sprintf("region-%d-%d", hour, i)}
+			decaying.AddLabelSet(other)
+			forever.AddLabelSet(other)
+		}
+
+		score := decaying.CalculateEntropyScore(common)
+		if score <= previous {
+			t.Fatalf("expected the score to rise every hour, got %v after %v at hour %d", score, previous, hour)
+		}
+		previous = score
+	}
+
+	// Without decay the old counts keep suppressing it
+	if stale := forever.CalculateEntropyScore(common); previous <= stale || previous < 2*initial {
+		t.Fatalf("expected the decayed score %v to be well above the initial %v and the undecayed %v",
+			previous, initial, stale)
+	}
+}
+
+func TestEntropyDecayForgetsStaleValues(t *testing.T) {
+	fake := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	e := NewDecayingEntropyCalculator(1000, time.Hour, fake)
+
+	for i := 0; i < 500; i++ {
+		e.AddLabelSet(map[string]string{"user.id": fmt.Sprintf("user-%d", i)})
+	}
+	if got := len(e.labelValues["user.id"]); got != 500 {
+		t.Fatalf("expected 500 values tracked, got %d", got)
+	}
+
+	// Counts of 1 decay below the prune threshold within two half-lives, so
+	// only the value seen afterwards is remembered
+	fake.Advance(2 * time.Hour)
+	e.AddLabelSet(map[string]string{"user.id": "recent"})
+	if got := len(e.labelValues["user.id"]); got != 1 || !e.tracked("user.id", "recent") {
+		t.Fatalf("expected only the recent value to be tracked, got %d values", got)
+	}
+
+	// Less than a step of the half-life doesn't decay anything
+	fake.Advance(time.Hour / entropyDecaySteps / 2)
+	e.AddLabelSet(map[string]string{"user.id": "recent"})
+	if count, _ := e.valueCount("user.id", "recent"); count != 2 {
+		t.Fatalf("expected the recent value to be counted twice without decay, got %v", count)
+	}
+}
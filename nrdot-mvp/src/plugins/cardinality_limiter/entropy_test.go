@@ -0,0 +1,113 @@
+package cardinalitylimiter
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestValueToString(t *testing.T) {
+	for _, tc := range []struct {
+		value    pcommon.Value
+		expected string
+	}{
+		{value: pcommon.NewValueStr("checkout"), expected: "checkout"},
+		{value: pcommon.NewValueInt(404), expected: "404"},
+		{value: pcommon.NewValueDouble(0.25), expected: "0.25"},
+		{value: pcommon.NewValueBool(true), expected: "true"},
+	} {
+		if got := valueToString(tc.value); got != tc.expected {
+			t.Fatalf("expected %q, got %q", tc.expected, got)
+		}
+	}
+}
+
+func TestEntropyMemoryBoundedByCap(t *testing.T) {
+	const distinct = 2000000
+	e := NewEntropyCalculator(1000)
+
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	// Millions of distinct values, with one value making up a tenth of them
+	labelSet := map[string]string{}
+	for i := 0; i < distinct; i++ {
+		labelSet["user.id"] = fmt.Sprintf("user-%d", i)
+		if i%10 == 0 {
+			labelSet["user.id"] = "frequent"
+		}
+		e.AddLabelSet(labelSet)
+	}
+
+	var after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	if tracked := len(e.labelValues["user.id"]); tracked > 1000 {
+		t.Fatalf("expected at most 1000 values tracked exactly, got %d", tracked)
+	}
+	if len(e.overflow) != 1 {
+		t.Fatalf("expected the values beyond the cap to go to 1 sketch, got %d", len(e.overflow))
+	}
+	if grown := int64(after.HeapAlloc) - int64(before.HeapAlloc); grown > 16<<20 {
+		t.Fatalf("expected memory to stay bounded, the heap grew by %d bytes", grown)
+	}
+
+	// The frequent value still scores well below a rare one counted in the
+	// sketch
+	frequent := e.CalculateEntropyScore(map[string]string{"user.id": "frequent"})
+	rare := e.CalculateEntropyScore(map[string]string{"user.id": fmt.Sprintf("user-%d", distinct-1)})
+	if frequent >= rare/2 {
+		t.Fatalf("expected the frequent value to score well below a rare one, got %v and %v", frequent, rare)
+	}
+}
+
+func TestEntropyThresholdsShiftDecisions(t *testing.T) {
+	table := map[string]keySetInfo{
+		"a": {entropyScore: 0.1},
+		"b": {entropyScore: 0.2},
+		"c": {entropyScore: 0.5},
+		"d": {entropyScore: 0.6},
+		"e": {entropyScore: 0.95},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		dropBelow  float64
+		aggBelow   float64
+		dropped    []string
+		aggregated []string
+	}{
+		{name: "defaults", dropBelow: 0.3, aggBelow: 1, dropped: []string{"a", "b", "c", "d"}, aggregated: []string{"c", "d"}},
+		{name: "aggressive drop", dropBelow: 0.55, aggBelow: 1, dropped: []string{"a", "b", "c", "d"}, aggregated: []string{"d"}},
+		{name: "never drop", dropBelow: 0, aggBelow: 1, dropped: []string{"a", "b", "c", "d"}, aggregated: []string{"a", "b", "c", "d"}},
+		{name: "keep high entropy", dropBelow: 0.3, aggBelow: 0.55, dropped: []string{"a", "b", "c"}, aggregated: []string{"c"}},
+	} {
+		dropped, aggregated := EntropyBasedCardinalityControl(table, 1, EntropyThresholds{
+			DropBelow:      tc.dropBelow,
+			AggregateBelow: tc.aggBelow,
+		})
+		sort.Strings(dropped)
+		sort.Strings(aggregated)
+		if !reflect.DeepEqual(dropped, tc.dropped) || !reflect.DeepEqual(aggregated, tc.aggregated) {
+			t.Errorf("%s: expected %v removed and %v aggregated, got %v and %v",
+				tc.name, tc.dropped, tc.aggregated, dropped, aggregated)
+		}
+	}
+
+	// Thresholds outside 0 to 1 or out of order are rejected
+	for _, thresholds := range [][2]float64{{-0.1, 1}, {0.3, 1.5}, {0.6, 0.5}} {
+		config := CreateDefaultConfig().(*Config)
+		config.DropBelowEntropy = thresholds[0]
+		config.AggregateBelowEntropy = thresholds[1]
+		if err := config.Validate(); err == nil {
+			t.Errorf("expected drop_below_entropy %v and aggregate_below_entropy %v to be rejected",
+				thresholds[0], thresholds[1])
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package cardinalitylimiter
+
+import (
+	"sort"
+)
+
+// Eviction strategies deciding which key-sets beyond the limit are evicted
+// first.
+const (
+	EvictionLowestEntropy = "lowest_entropy"
+	EvictionLeastRecent   = "least_recent"
+	EvictionLeastAccessed = "least_accessed"
+	EvictionWeighted      = "weighted"
+)
+
+// EvictionWeights weigh the entropy score, recency and access count of
+// key-sets against each other with the "weighted" eviction strategy.
+type EvictionWeights struct {
+	Entropy float64 `mapstructure:"entropy"`
+	Recency float64 `mapstructure:"recency"`
+	Access  float64 `mapstructure:"access"`
+}
+
+// sortForEviction orders key-sets so those to evict first come first. Ties
+// fall back to the entropy score, then access count, then last seen.
+func sortForEviction(keySets []keySetEntry, strategy string, weights EvictionWeights) {
+	var blend map[string]float64
+	if strategy == EvictionWeighted {
+		blend = blendedScores(keySets, weights)
+	}
+
+	sort.Slice(keySets, func(i, j int) bool {
+		a, b := keySets[i], keySets[j]
+		switch strategy {
+		case EvictionLeastRecent:
+			if a.lastSeen != b.lastSeen {
+				return a.lastSeen < b.lastSeen
+			}
+		case EvictionLeastAccessed:
+			if a.accessCount != b.accessCount {
+				return a.accessCount < b.accessCount
+			}
+		case EvictionWeighted:
+			if blend[a.key] != blend[b.key] {
+				return blend[a.key] < blend[b.key]
+			}
+		}
+
+		if a.entropyScore != b.entropyScore {
+			return a.entropyScore < b.entropyScore
+		}
+		if a.accessCount != b.accessCount {
+			return a.accessCount < b.accessCount
+		}
+		return a.lastSeen < b.lastSeen
+	})
+}
+
+// blendedScores returns the weighted average of each key-set's entropy
+// score, recency and access count, the latter two scaled to between 0 and 1
+// across the key-sets. Lower scores are evicted first.
+func blendedScores(keySets []keySetEntry, weights EvictionWeights) map[string]float64 {
+	scores := make(map[string]float64, len(keySets))
+	if len(keySets) == 0 {
+		return scores
+	}
+
+	minSeen, maxSeen := keySets[0].lastSeen, keySets[0].lastSeen
+	minAccess, maxAccess := keySets[0].accessCount, keySets[0].accessCount
+	for _, entry := range keySets[1:] {
+		if entry.lastSeen < minSeen {
+			minSeen = entry.lastSeen
+		}
+		if entry.lastSeen > maxSeen {
+			maxSeen = entry.lastSeen
+		}
+		if entry.accessCount < minAccess {
+			minAccess = entry.accessCount
+		}
+		if entry.accessCount > maxAccess {
+			maxAccess = entry.accessCount
+		}
+	}
+
+	total := weights.Entropy + weights.Recency + weights.Access
+	for _, entry := range keySets {
+		score := weights.Entropy*entry.entropyScore +
+			weights.Recency*scale(entry.lastSeen, minSeen, maxSeen) +
+			weights.Access*scale(entry.accessCount, minAccess, maxAccess)
+		scores[entry.key] = score / total
+	}
+	return scores
+}
+
+// scale maps value from the range [low, high] to [0, 1].
+func scale(value int64, low int64, high int64) float64 {
+	if high == low {
+		return 0
+	}
+	return float64(value-low) / float64(high-low)
+}
@@ -0,0 +1,74 @@
+package cardinalitylimiter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvictionStrategiesChooseVictims(t *testing.T) {
+	// Each of a, b and c is the worst by one measure, while d is low by all
+	table := map[string]keySetInfo{
+		"a": {entropyScore: 0.1, lastSeen: 500, accessCount: 50},
+		"b": {entropyScore: 0.5, lastSeen: 100, accessCount: 45},
+		"c": {entropyScore: 0.6, lastSeen: 450, accessCount: 1},
+		"d": {entropyScore: 0.3, lastSeen: 150, accessCount: 10},
+		"e": {entropyScore: 0.9, lastSeen: 400, accessCount: 40},
+	}
+
+	for _, tc := range []struct {
+		strategy string
+		weights  EvictionWeights
+		evicted  []string
+	}{
+		{strategy: "", evicted: []string{"a", "d"}},
+		{strategy: EvictionLowestEntropy, evicted: []string{"a", "d"}},
+		{strategy: EvictionLeastRecent, evicted: []string{"b", "d"}},
+		{strategy: EvictionLeastAccessed, evicted: []string{"c", "d"}},
+		{strategy: EvictionWeighted, weights: EvictionWeights{Entropy: 1, Recency: 1, Access: 1}, evicted: []string{"d", "b"}},
+		// Weighing only recency matches least recent
+		{strategy: EvictionWeighted, weights: EvictionWeights{Recency: 1}, evicted: []string{"b", "d"}},
+	} {
+		dropped, _ := EntropyBasedCardinalityControl(table, 3, EntropyThresholds{
+			AggregateBelow:   1,
+			EvictionStrategy: tc.strategy,
+			EvictionWeights:  tc.weights,
+		})
+		if !reflect.DeepEqual(dropped, tc.evicted) {
+			t.Errorf("%q %+v: expected %v evicted in order, got %v", tc.strategy, tc.weights, tc.evicted, dropped)
+		}
+
+		// Evicting a single key-set takes the first victim alone
+		dropped, _ = EntropyBasedCardinalityControl(table, 4, EntropyThresholds{
+			AggregateBelow:   1,
+			EvictionStrategy: tc.strategy,
+			EvictionWeights:  tc.weights,
+		})
+		if !reflect.DeepEqual(dropped, tc.evicted[:1]) {
+			t.Errorf("%q %+v: expected %v evicted, got %v", tc.strategy, tc.weights, tc.evicted[:1], dropped)
+		}
+	}
+}
+
+func TestEvictionStrategyValidated(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.EvictionStrategy = "random"
+	if err := config.Validate(); err == nil {
+		t.Error("expected an unknown eviction_strategy to be rejected")
+	}
+
+	config = CreateDefaultConfig().(*Config)
+	config.EvictionStrategy = EvictionWeighted
+	config.EvictionWeights = EvictionWeights{Entropy: -1, Recency: 1}
+	if err := config.Validate(); err == nil {
+		t.Error("expected negative eviction_weights to be rejected")
+	}
+
+	// Weights that are all zero fall back to an even blend
+	config.EvictionWeights = EvictionWeights{}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("expected zero weights to be valid: %v", err)
+	}
+	if config.EvictionWeights != (EvictionWeights{Entropy: 1, Recency: 1, Access: 1}) {
+		t.Fatalf("expected even weights, got %+v", config.EvictionWeights)
+	}
+}
@@ -0,0 +1,151 @@
+package cardinalitylimiter
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"hash/fnv"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// attributeFilter decides which attributes take part in key-set formation.
+type attributeFilter struct {
+	drop []string
+	keep []string
+
+	// Whether separators in attribute names and values are escaped in keys
+	escape bool
+
+	// Maximum key length in bytes, 0 for no limit
+	maxKeyLength int
+}
+
+// newAttributeFilter creates an attribute filter from the drop and keep globs
+// in the configuration.
+func newAttributeFilter(config *Config) *attributeFilter {
+	return &attributeFilter{
+		drop:         config.DropAttributes,
+		keep:         config.KeepAttributes,
+		escape:       config.CollisionSafeKeys,
+		maxKeyLength: config.MaxKeyLength,
+	}
+}
+
+// include returns whether an attribute is part of the key-set. Attributes
+// matching a keep glob are always included, even if they also match a drop
+// glob.
+func (f *attributeFilter) include(name string) bool {
+	if matchesAny(f.keep, name) {
+		return true
+	}
+	return !matchesAny(f.drop, name)
+}
+
+// matchesAny returns whether the name matches any of the globs.
+func matchesAny(globs []string, name string) bool {
+	for _, glob := range globs {
+		if matched, _ := path.Match(glob, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// buildKeySet combines resource and data point attributes into a key-set,
+// leaving out the attributes the filter excludes. It returns the canonical
+// key, the labels that formed it and a signature of the attributes.
+//
+// Unless separators are escaped, the key is ambiguous: {a="1,b=2"} and
+// {a="1", b="2"} share it, as do values of different types with the same
+// string form. The signature tells such attribute sets apart.
+func (f *attributeFilter) buildKeySet(resourceAttrs pcommon.Map, attrs pcommon.Map) (string, map[string]string, uint64) {
+	labels := make(map[string]string, resourceAttrs.Len()+attrs.Len())
+	types := make(map[string]pcommon.ValueType, resourceAttrs.Len()+attrs.Len())
+
+	add := func(k string, v pcommon.Value) bool {
+		if f.include(k) {
+			labels[k] = v.AsString()
+			types[k] = v.Type()
+		}
+		return true
+	}
+	resourceAttrs.Range(add)
+	attrs.Range(add)
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if f.escape {
+			b.WriteString(keyEscaper.Replace(name))
+			b.WriteByte('=')
+			b.WriteString(keyEscaper.Replace(labels[name]))
+		} else {
+			b.WriteString(name)
+			b.WriteByte('=')
+			b.WriteString(labels[name])
+		}
+	}
+
+	return f.boundKey(b.String()), labels, attributeSignature(names, labels, types)
+}
+
+// minKeyLength is the smallest maximum key length, leaving room for a
+// prefix of the key before the hash of its tail.
+const minKeyLength = 64
+
+// keyTailHashLen is the length of the hash replacing the tail of a key over
+// the maximum length, including its '#' marker.
+const keyTailHashLen = 1 + 2*8
+
+// boundKey shortens a key longer than the maximum length by replacing its
+// tail with a hash of the tail, so no key takes more than maxKeyLength
+// bytes. Keys sharing a prefix but differing in their tails stay distinct
+// unless the hashes collide, which the attribute signature still detects.
+func (f *attributeFilter) boundKey(key string) string {
+	if f.maxKeyLength <= 0 || len(key) <= f.maxKeyLength {
+		return key
+	}
+
+	// Cut at the start of a character so the key stays valid UTF-8
+	cut := f.maxKeyLength - keyTailHashLen
+	for cut > 0 && !utf8.RuneStart(key[cut]) {
+		cut--
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key[cut:]))
+	return key[:cut] + "#" + hex.EncodeToString(h.Sum(nil))
+}
+
+// keyEscaper escapes the separators of a key in attribute names and values.
+var keyEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`)
+
+// attributeSignature hashes the sorted attribute names, types and values,
+// length-prefixed so no two distinct attribute sets are framed alike.
+func attributeSignature(names []string, labels map[string]string, types map[string]pcommon.ValueType) uint64 {
+	h := fnv.New64a()
+	var length [8]byte
+	write := func(s string) {
+		binary.BigEndian.PutUint64(length[:], uint64(len(s)))
+		h.Write(length[:])
+		h.Write([]byte(s))
+	}
+	for _, name := range names {
+		write(name)
+		h.Write([]byte{byte(types[name])})
+		write(labels[name])
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,140 @@
+package cardinalitylimiter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestDroppedAttributeCollapsesKeySets(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		drop     []string
+		keep     []string
+		expected int
+	}{
+		{name: "no filter", expected: 100},
+		{name: "dropped", drop: []string{"user.*"}, expected: 1},
+		{name: "kept", drop: []string{"user.*"}, keep: []string{"user.id"}, expected: 100},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, _ := newTestMetricsProcessor(t, func(config *Config) {
+				config.DropAttributes = tc.drop
+				config.KeepAttributes = tc.keep
+			})
+
+			if err := p.ConsumeMetrics(context.Background(), seriesMetrics("", 100)); err != nil {
+				t.Fatalf("failed to consume metrics: %v", err)
+			}
+			if got := p.keySets.len(); got != tc.expected {
+				t.Fatalf("expected %d key-sets, got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestKeySetCollisionsCounted(t *testing.T) {
+	// Each pair of attribute sets forms the same key unless separators are
+	// escaped, and the types pair forms it either way
+	collisions := map[string][2]func(pcommon.Map){
+		"separators": {
+			func(attrs pcommon.Map) { attrs.PutStr("a", "1,b=2") },
+			func(attrs pcommon.Map) { attrs.PutStr("a", "1"); attrs.PutStr("b", "2") },
+		},
+		"types": {
+			func(attrs pcommon.Map) { attrs.PutStr("a", "1") },
+			func(attrs pcommon.Map) { attrs.PutInt("a", 1) },
+		},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		safe       bool
+		keySets    int
+		collisions float64
+	}{
+		{name: "ambiguous keys", keySets: 2, collisions: 2},
+		{name: "collision safe keys", safe: true, keySets: 3, collisions: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, _ := newTestMetricsProcessor(t, func(config *Config) {
+				config.CollisionSafeKeys = tc.safe
+			})
+
+			md := pmetric.NewMetrics()
+			dataPoints := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge().DataPoints()
+			for _, pair := range collisions {
+				for _, set := range pair {
+					set(dataPoints.AppendEmpty().Attributes())
+				}
+			}
+			if err := p.ConsumeMetrics(context.Background(), md); err != nil {
+				t.Fatalf("failed to consume metrics: %v", err)
+			}
+
+			if got := p.keySets.len(); got != tc.keySets {
+				t.Fatalf("expected %d key-sets, got %d", tc.keySets, got)
+			}
+			if got := testutil.ToFloat64(p.collisionsCounter); got != tc.collisions {
+				t.Fatalf("expected %v collisions, got %v", tc.collisions, got)
+			}
+
+			// Every data point merged into another attribute set's key-set
+			// is counted, not just the first
+			if err := p.ConsumeMetrics(context.Background(), md); err != nil {
+				t.Fatalf("failed to consume metrics: %v", err)
+			}
+			if got := testutil.ToFloat64(p.collisionsCounter); got != 2*tc.collisions {
+				t.Fatalf("expected %v collisions, got %v", 2*tc.collisions, got)
+			}
+		})
+	}
+}
+
+func TestLongKeysBoundedAndDistinct(t *testing.T) {
+	filter := newAttributeFilter(&Config{MaxKeyLength: 128})
+
+	// Long attribute sets that differ only at the end of their keys
+	keys := make(map[string]bool)
+	resource := pcommon.NewMap()
+	resource.PutStr("service.name", strings.Repeat("checkout-", 20))
+	for i := 0; i < 1000; i++ {
+		attrs := pcommon.NewMap()
+		for j := 0; j < 10; j++ {
+			attrs.PutStr(fmt.Sprintf("label.%d", j), strings.Repeat("ü", 20))
+		}
+		attrs.PutStr("zz.request.id", fmt.Sprintf("request-%d", i))
+
+		key, _, _ := filter.buildKeySet(resource, attrs)
+		if len(key) > 128 {
+			t.Fatalf("expected keys of at most 128 bytes, got %d", len(key))
+		}
+		if !utf8.ValidString(key) {
+			t.Fatalf("expected the shortened key to stay valid UTF-8, got %q", key)
+		}
+		keys[key] = true
+	}
+	if len(keys) != 1000 {
+		t.Fatalf("expected 1000 distinct keys, got %d", len(keys))
+	}
+
+	// Keys within the limit are left whole
+	short := pcommon.NewMap()
+	short.PutStr("user.id", "42")
+	if key, _, _ := filter.buildKeySet(pcommon.NewMap(), short); key != "user.id=42" {
+		t.Fatalf("expected a short key to be unchanged, got %q", key)
+	}
+
+	// Too small a limit leaves no room for the hash
+	config := CreateDefaultConfig().(*Config)
+	config.MaxKeyLength = minKeyLength - 1
+	if err := config.Validate(); err == nil {
+		t.Fatalf("expected max_key_length below %d to be rejected", minKeyLength)
+	}
+}
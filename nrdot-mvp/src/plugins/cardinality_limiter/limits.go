@@ -0,0 +1,77 @@
+package cardinalitylimiter
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// attributeLimits bounds the number of attributes on a data point and the
+// length of their values, before key-sets are formed.
+type attributeLimits struct {
+	maxAttributes int
+	maxValueLen   int
+	keep          []string
+}
+
+// newAttributeLimits creates attribute limits from the configuration.
+func newAttributeLimits(config *Config) *attributeLimits {
+	return &attributeLimits{
+		maxAttributes: config.MaxAttributesPerPoint,
+		maxValueLen:   config.MaxAttributeValueLen,
+		keep:          config.KeepAttributes,
+	}
+}
+
+// enforce trims the attributes in place. Attributes over the count limit are
+// removed, keeping attributes that match a keep glob first and then the rest
+// in name order. String values over the length limit are truncated. It
+// returns the number of attributes removed and values truncated.
+func (l *attributeLimits) enforce(attrs pcommon.Map) (int, int) {
+	dropped := 0
+	if l.maxAttributes > 0 && attrs.Len() > l.maxAttributes {
+		names := make([]string, 0, attrs.Len())
+		attrs.Range(func(k string, _ pcommon.Value) bool {
+			names = append(names, k)
+			return true
+		})
+		sort.SliceStable(names, func(i, j int) bool {
+			ki, kj := matchesAny(l.keep, names[i]), matchesAny(l.keep, names[j])
+			if ki != kj {
+				return ki
+			}
+			return names[i] < names[j]
+		})
+
+		remove := make(map[string]bool, len(names)-l.maxAttributes)
+		for _, name := range names[l.maxAttributes:] {
+			remove[name] = true
+		}
+		attrs.RemoveIf(func(k string, _ pcommon.Value) bool {
+			return remove[k]
+		})
+		dropped = len(remove)
+	}
+
+	truncated := 0
+	if l.maxValueLen > 0 {
+		attrs.Range(func(_ string, v pcommon.Value) bool {
+			if v.Type() == pcommon.ValueTypeStr && len(v.Str()) > l.maxValueLen {
+				v.SetStr(truncateUTF8(v.Str(), l.maxValueLen))
+				truncated++
+			}
+			return true
+		})
+	}
+
+	return dropped, truncated
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,57 @@
+package cardinalitylimiter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestAttributeLimitsTrimAndCount(t *testing.T) {
+	p, sink := newTestMetricsProcessor(t, func(config *Config) {
+		config.MaxAttributesPerPoint = 3
+		config.MaxAttributeValueLen = 8
+		config.KeepAttributes = []string{"user.id"}
+	})
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	gauge := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	gauge.SetName("sessions")
+	dp := gauge.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.Attributes().PutStr("user.id", "user-with-a-long-id")
+	for i := 0; i < 5; i++ {
+		dp.Attributes().PutStr(fmt.Sprintf("attr.%d", i), "value")
+	}
+	dp.Attributes().PutStr("attr.0", strings.Repeat("x", 1<<20))
+
+	if err := p.ConsumeMetrics(context.Background(), md); err != nil {
+		t.Fatalf("failed to consume metrics: %v", err)
+	}
+
+	// The kept attribute survives, then the first by name, truncated
+	attrs := sink.batches[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0).Attributes()
+	if attrs.Len() != 3 {
+		t.Fatalf("expected 3 attributes, got %v", attrs.AsRaw())
+	}
+	for name, expected := range map[string]string{
+		"user.id": "user-wit",
+		"attr.0":  "xxxxxxxx",
+		"attr.1":  "value",
+	} {
+		if value, _ := attrs.Get(name); value.AsString() != expected {
+			t.Fatalf("expected %s to be %q, got %q", name, expected, value.AsString())
+		}
+	}
+
+	if got := testutil.ToFloat64(p.attributesDroppedCounter); got != 3 {
+		t.Fatalf("expected 3 attributes dropped, got %v", got)
+	}
+	if got := testutil.ToFloat64(p.valuesTruncatedCounter); got != 2 {
+		t.Fatalf("expected 2 values truncated, got %v", got)
+	}
+}
@@ -3,9 +3,13 @@ package cardinalitylimiter
 import (
 	"context"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/pdata/plog"
 	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
 )
 
 // logsProcessor is the processor for applying cardinality control to logs.
@@ -13,20 +17,40 @@ type logsProcessor struct {
 	logger       *zap.Logger
 	config       *Config
 	nextConsumer consumer.Logs
+
+	// Counters registered for this processor
+	counters *promreg.Registry
+
+	// Distinct values of log attributes, nil in metrics-only mode
+	attributes *attributeCardinality
+
+	// Log attributes dropped, aggregated or tagged for too many values
+	attributesLimitedCounter prometheus.Counter
 }
 
 // newLogsProcessor creates a new logs processor for cardinality control.
-func newLogsProcessor(logger *zap.Logger, config *Config, nextConsumer consumer.Logs) (*logsProcessor, error) {
+func newLogsProcessor(logger *zap.Logger, config *Config, id component.ID, nextConsumer consumer.Logs) (*logsProcessor, error) {
 	// Skip implementation if metrics-only mode is enabled
 	if config.MetricsOnly {
 		logger.Info("Cardinality limiter is in metrics-only mode, logs will pass through unchanged")
 	}
-	
-	return &logsProcessor{
+
+	p := &logsProcessor{
 		logger:       logger,
 		config:       config,
 		nextConsumer: nextConsumer,
-	}, nil
+		counters:     promreg.New(logger, prometheus.Labels{"processor": id.String()}),
+	}
+
+	if !config.MetricsOnly {
+		p.attributes = newAttributeCardinality(config)
+		p.attributesLimitedCounter = p.counters.Counter(prometheus.CounterOpts{
+			Name: "otelcol_cardinality_limiter_log_attributes_limited_total",
+			Help: "Log record attributes dropped, aggregated or tagged for exceeding max_values_per_attribute",
+		})
+	}
+
+	return p, nil
 }
 
 // ConsumeLogs applies cardinality control to the incoming logs.
@@ -35,14 +59,34 @@ func (p *logsProcessor) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
 	if p.config.MetricsOnly {
 		return p.nextConsumer.ConsumeLogs(ctx, ld)
 	}
-	
-	// Apply cardinality control to logs
-	// This would be similar to the metrics implementation but for log data
-	
+
+	// Limit the distinct values of log attributes, critical data excepted
+	limited := 0
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		if p.config.CriticalData.Protected(rl.Resource()) {
+			continue
+		}
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			records := rl.ScopeLogs().At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				limited += p.attributes.limit(records.At(k).Attributes())
+			}
+		}
+	}
+	if limited > 0 {
+		p.attributesLimitedCounter.Add(float64(limited))
+	}
+
 	// Forward the processed logs to the next consumer
 	return p.nextConsumer.ConsumeLogs(ctx, ld)
 }
 
+// Start starts the processor.
+func (p *logsProcessor) Start(context.Context, component.Host) error {
+	return nil
+}
+
 // Capabilities returns the capabilities of the processor.
 func (p *logsProcessor) Capabilities() consumer.Capabilities {
 	return consumer.Capabilities{MutatesData: !p.config.MetricsOnly}
@@ -50,5 +94,6 @@ func (p *logsProcessor) Capabilities() consumer.Capabilities {
 
 // Shutdown stops the processor.
 func (p *logsProcessor) Shutdown(context.Context) error {
+	p.counters.Unregister()
 	return nil
 }
@@ -0,0 +1,67 @@
+package cardinalitylimiter
+
+import (
+	"regexp"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// metricNameNormalizer rewrites dynamic segments of metric names, such as
+// IDs, to a placeholder so names like http.request.42 and http.request.43
+// become one metric.
+type metricNameNormalizer struct {
+	patterns    []*regexp.Regexp
+	placeholder string
+}
+
+// newMetricNameNormalizer creates a metric name normalizer from the
+// configuration, or returns nil if no patterns are configured. The patterns
+// must have been checked by Validate.
+func newMetricNameNormalizer(config *Config) *metricNameNormalizer {
+	if len(config.MetricNamePatterns) == 0 {
+		return nil
+	}
+
+	patterns := make([]*regexp.Regexp, len(config.MetricNamePatterns))
+	for i, pattern := range config.MetricNamePatterns {
+		patterns[i] = regexp.MustCompile(pattern)
+	}
+
+	return &metricNameNormalizer{
+		patterns:    patterns,
+		placeholder: config.MetricNamePlaceholder,
+	}
+}
+
+// normalize replaces every segment of the name matching a pattern with the
+// placeholder, applying the patterns in order.
+func (n *metricNameNormalizer) normalize(name string) string {
+	for _, pattern := range n.patterns {
+		name = pattern.ReplaceAllLiteralString(name, n.placeholder)
+	}
+	return name
+}
+
+// normalizeAll renames every metric in the batch, returning the number of
+// metrics renamed. A nil normalizer renames nothing.
+func (n *metricNameNormalizer) normalizeAll(md pmetric.Metrics) int {
+	if n == nil {
+		return 0
+	}
+
+	renamed := 0
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			metrics := rm.ScopeMetrics().At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+				if name := n.normalize(metric.Name()); name != metric.Name() {
+					metric.SetName(name)
+					renamed++
+				}
+			}
+		}
+	}
+	return renamed
+}
@@ -0,0 +1,85 @@
+package cardinalitylimiter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// requestMetrics returns one gauge data point for each of the dynamically
+// named metrics http.request.0 up to http.request.<count-1>.
+func requestMetrics(count int) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	for i := 0; i < count; i++ {
+		metric := metrics.AppendEmpty()
+		metric.SetName(fmt.Sprintf("http.request.%d", i))
+		dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("http.method", "GET")
+		dp.SetIntValue(int64(i))
+	}
+	return md
+}
+
+// distinctSeries returns the number of distinct metric name and attribute
+// combinations forwarded to the sink.
+func distinctSeries(sink *metricsSink) int {
+	series := make(map[string]bool)
+	for _, md := range sink.batches {
+		metrics := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+		for i := 0; i < metrics.Len(); i++ {
+			metric := metrics.At(i)
+			dataPoints := metric.Gauge().DataPoints()
+			for j := 0; j < dataPoints.Len(); j++ {
+				series[fmt.Sprintf("%s %v", metric.Name(), dataPoints.At(j).Attributes().AsRaw())] = true
+			}
+		}
+	}
+	return len(series)
+}
+
+func TestMetricNameNormalizationReducesSeries(t *testing.T) {
+	// Without patterns every name is its own series
+	p, sink := newTestMetricsProcessor(t, nil)
+	if err := p.ConsumeMetrics(context.Background(), requestMetrics(10)); err != nil {
+		t.Fatalf("failed to consume metrics: %v", err)
+	}
+	if got := distinctSeries(sink); got != 10 {
+		t.Fatalf("expected 10 distinct series without normalization, got %d", got)
+	}
+
+	p, sink = newTestMetricsProcessor(t, func(config *Config) {
+		config.MetricNamePatterns = []string{`\d+`}
+	})
+	if err := p.ConsumeMetrics(context.Background(), requestMetrics(10)); err != nil {
+		t.Fatalf("failed to consume metrics: %v", err)
+	}
+	if got := distinctSeries(sink); got != 1 {
+		t.Fatalf("expected the names to collapse to 1 series, got %d", got)
+	}
+	if name := sink.batches[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Name(); name != "http.request.id" {
+		t.Fatalf("expected the default placeholder in the name, got %q", name)
+	}
+	if got := testutil.ToFloat64(p.namesNormalizedCounter); got != 10 {
+		t.Fatalf("expected 10 names normalized, got %v", got)
+	}
+}
+
+func TestMetricNamePatternsValidated(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.MetricNamePatterns = []string{`(\d+`}
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected an invalid metric_name_patterns entry to be rejected")
+	}
+
+	normalizer := newMetricNameNormalizer(&Config{
+		MetricNamePatterns:    []string{`[0-9a-f]{8}(-[0-9a-f]{4}){3}-[0-9a-f]{12}`, `\d+`},
+		MetricNamePlaceholder: "{n}",
+	})
+	if got := normalizer.normalize("job.5d2e8a1c-0b7f-4c3e-9a6d-1f2e3d4c5b6a.retries.3"); got != "job.{n}.retries.{n}" {
+		t.Fatalf("expected every dynamic segment to be replaced, got %q", got)
+	}
+}
@@ -2,128 +2,401 @@ package cardinalitylimiter
 
 import (
 	"context"
-	"sync"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/droplog"
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
 )
 
 // metricsProcessor is the processor for applying cardinality control to metrics.
 type metricsProcessor struct {
 	logger       *zap.Logger
 	config       *Config
+	clock        clock.Clock
 	nextConsumer consumer.Metrics
-	
-	// Hash table to store unique key-sets and their metadata
-	keySetTable     map[string]keySetInfo
-	keySetTableLock sync.RWMutex
-	
+
+	// Rewrites dynamic metric names, nil if disabled
+	names *metricNameNormalizer
+
+	// Attributes taking part in key-set formation
+	filter *attributeFilter
+	limits *attributeLimits
+
+	// Sharded hash table to store unique key-sets, their metadata and the
+	// history they are scored with
+	keySets *keySetTable
+
 	// Metrics for self-observability
+	counters          *promreg.Registry
 	droppedKeysets    int64
 	aggregatedKeysets int64
+	taggedDataPoints  int64
+
+	// Histogram data points dropped because their bucket boundaries didn't
+	// match the aggregate they were merged into
+	boundaryMismatchCounter prometheus.Counter
+
+	// Attributes removed and values truncated by the attribute limits
+	attributesDroppedCounter prometheus.Counter
+	valuesTruncatedCounter   prometheus.Counter
+
+	// Metrics renamed by the metric name patterns
+	namesNormalizedCounter prometheus.Counter
+
+	// Data points whose attributes differ from those first recorded under
+	// the same key-set
+	collisionsCounter prometheus.Counter
+
+	// Optional report of dropped series
+	report *DropReport
+
+	// Sampled log of dropped series, nil if disabled
+	dropLog *droplog.Logger
+
+	// Sample of dropped data points written to the DLQ, nil if disabled
+	dropSample *dropSampler
 }
 
 // keySetInfo stores metadata about a particular key-set
 type keySetInfo struct {
-	lastSeen     int64  // unix timestamp
+	lastSeen     int64   // unix timestamp
 	entropyScore float64 // higher score means more important
-	accessCount  int64  // number of times this key-set has been seen
+	accessCount  int64   // number of times this key-set has been seen
+	signature    uint64  // attribute signature of the first data point seen
 }
 
 // newMetricsProcessor creates a new metrics processor for cardinality control.
-func newMetricsProcessor(logger *zap.Logger, config *Config, nextConsumer consumer.Metrics) (*metricsProcessor, error) {
+func newMetricsProcessor(logger *zap.Logger, config *Config, id component.ID, nextConsumer consumer.Metrics) (*metricsProcessor, error) {
 	p := &metricsProcessor{
 		logger:       logger,
 		config:       config,
+		clock:        clock.Real(),
 		nextConsumer: nextConsumer,
-		keySetTable:  make(map[string]keySetInfo, config.MaxUniqueKeySets),
+		counters:     promreg.New(logger, prometheus.Labels{"processor": id.String()}),
+		names:        newMetricNameNormalizer(config),
+		filter:       newAttributeFilter(config),
+		limits:       newAttributeLimits(config),
+		dropLog:      droplog.New(logger, typeStr, config.DropLog),
 	}
-	
+	p.dropSample = newDropSampler(logger, config, p.counters)
+	p.keySets = newKeySetTable(config.KeySetShards, config.MaxUniqueKeySets,
+		func() *EntropyCalculator {
+			return NewDecayingEntropyCalculator(config.MaxTrackedValuesPerLabel,
+				time.Duration(config.EntropyHalfLifeSec)*time.Second, p.clock)
+		},
+		func() *decisionCache {
+			return newDecisionCache(config)
+		},
+	)
+
+	p.boundaryMismatchCounter = p.counters.Counter(prometheus.CounterOpts{
+		Name: "otelcol_cardinality_limiter_histogram_boundary_mismatch_dropped_total",
+		Help: "Histogram data points dropped during aggregation because their bucket boundaries differed",
+	})
+
+	p.attributesDroppedCounter = p.counters.Counter(prometheus.CounterOpts{
+		Name: "otelcol_cardinality_limiter_attributes_dropped_total",
+		Help: "Data point attributes removed for exceeding max_attributes_per_point",
+	})
+
+	p.valuesTruncatedCounter = p.counters.Counter(prometheus.CounterOpts{
+		Name: "otelcol_cardinality_limiter_attribute_values_truncated_total",
+		Help: "Data point attribute values truncated for exceeding max_attribute_value_len",
+	})
+
+	p.namesNormalizedCounter = p.counters.Counter(prometheus.CounterOpts{
+		Name: "otelcol_cardinality_limiter_metric_names_normalized_total",
+		Help: "Metrics renamed by metric_name_patterns",
+	})
+
+	p.collisionsCounter = p.counters.Counter(prometheus.CounterOpts{
+		Name: "otelcol_cardinality_limiter_keyset_collisions_total",
+		Help: "Data points whose distinct attributes mapped to the key-set of another attribute set",
+	})
+
+	// Start the dropped series report if configured
+	if config.ReportPath != "" {
+		p.report = NewDropReport(logger, config, p.clock)
+		p.report.Start()
+	}
+
 	return p, nil
 }
 
 // ConsumeMetrics applies cardinality control to the incoming metrics.
 func (p *metricsProcessor) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
 	// Apply cardinality control
-	p.applyCardinalityControl(md)
-	
+	spill := p.applyCardinalityControl(md)
+
+	// Keep a sample of what was dropped for later analysis
+	p.dropSample.write(ctx, spill)
+
 	// Forward the processed metrics to the next consumer
 	return p.nextConsumer.ConsumeMetrics(ctx, md)
 }
 
-// applyCardinalityControl applies the configured cardinality control algorithm to the metrics.
-func (p *metricsProcessor) applyCardinalityControl(md pmetric.Metrics) {
-	// Implementation of the entropy-based cardinality control algorithm
-	// This is a placeholder for the actual implementation
-	
-	// 1. Extract key-sets from the metrics
-	// 2. Calculate entropy scores for each key-set
-	// 3. Apply the cardinality control algorithm based on the configuration
-	// 4. Update the metrics accordingly
-	
+// applyCardinalityControl applies the configured cardinality control
+// algorithm to the metrics. It returns the sample of dropped data points to
+// write to the DLQ, nil if there is none.
+func (p *metricsProcessor) applyCardinalityControl(md pmetric.Metrics) *dropSpill {
+	// Collapse dynamic metric names before their data points are counted
+	if renamed := p.names.normalizeAll(md); renamed > 0 {
+		p.namesNormalizedCounter.Add(float64(renamed))
+	}
+
+	// Data points to tag once the batch's key-sets have been admitted
+	var tagged []keyedAttributes
+
+	// Sample of the data points dropped from this batch, nil if disabled
+	spill := p.dropSample.newSpill()
+
 	// For each metric in the batch, extract key-sets and apply cardinality control
 	for i := 0; i < md.ResourceMetrics().Len(); i++ {
 		rm := md.ResourceMetrics().At(i)
-		
+
+		// Critical data is never limited, so its key-sets aren't tracked
+		if p.config.CriticalData.Protected(rm.Resource()) {
+			continue
+		}
+
 		// Process resource attributes (common to all metrics in this resource)
 		resourceAttrs := rm.Resource().Attributes()
-		
+
 		// For each scope in the resource
 		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
 			sm := rm.ScopeMetrics().At(j)
-			
+
 			// For each metric in the scope
 			for k := 0; k < sm.Metrics().Len(); k++ {
 				metric := sm.Metrics().At(k)
-				
+
 				// Handle different metric types
 				switch metric.Type() {
 				case pmetric.MetricTypeGauge:
-					p.processDataPoints(metric.Gauge().DataPoints(), resourceAttrs)
+					tagged = p.processDataPoints(metric.Gauge().DataPoints(), resourceAttrs, tagged)
 				case pmetric.MetricTypeSum:
-					p.processDataPoints(metric.Sum().DataPoints(), resourceAttrs)
+					tagged = p.processDataPoints(metric.Sum().DataPoints(), resourceAttrs, tagged)
 				case pmetric.MetricTypeHistogram:
-					p.processHistogramDataPoints(metric.Histogram().DataPoints(), resourceAttrs)
+					if p.shouldAggregate() {
+						p.aggregateHistograms(rm, sm, metric, spill)
+					}
+					tagged = p.processHistogramDataPoints(metric.Histogram().DataPoints(), resourceAttrs, tagged)
 				case pmetric.MetricTypeSummary:
-					p.processSummaryDataPoints(metric.Summary().DataPoints(), resourceAttrs)
+					tagged = p.processSummaryDataPoints(metric.Summary().DataPoints(), resourceAttrs, tagged)
 				}
 			}
 		}
 	}
-	
+
 	// Enforce cardinality limit if exceeded
 	p.enforceCardinalityLimit()
+
+	// Drop the data points of the key-sets that were just evicted
+	if p.config.Action == "drop" {
+		p.dropEvicted(md, spill)
+	}
+
+	// Flag rather than drop data points that didn't fit under the limit
+	if p.config.Action == "tag" {
+		p.tagOverflow(tagged)
+	}
+
+	return spill
 }
 
 // processDataPoints processes data points of gauge and sum metrics.
-func (p *metricsProcessor) processDataPoints(dataPoints interface{}, resourceAttrs interface{}) {
-	// Implementation placeholder
-	// 1. Extract attributes from datapoints
-	// 2. Combine with resource attributes to form key-sets
-	// 3. Add or update key-sets in the table
+func (p *metricsProcessor) processDataPoints(dataPoints pmetric.NumberDataPointSlice, resourceAttrs pcommon.Map, tagged []keyedAttributes) []keyedAttributes {
+	for i := 0; i < dataPoints.Len(); i++ {
+		tagged = p.recordDataPoint(resourceAttrs, dataPoints.At(i).Attributes(), tagged)
+	}
+	return tagged
 }
 
 // processHistogramDataPoints processes histogram data points.
-func (p *metricsProcessor) processHistogramDataPoints(dataPoints interface{}, resourceAttrs interface{}) {
-	// Implementation placeholder
+func (p *metricsProcessor) processHistogramDataPoints(dataPoints pmetric.HistogramDataPointSlice, resourceAttrs pcommon.Map, tagged []keyedAttributes) []keyedAttributes {
+	for i := 0; i < dataPoints.Len(); i++ {
+		tagged = p.recordDataPoint(resourceAttrs, dataPoints.At(i).Attributes(), tagged)
+	}
+	return tagged
 }
 
 // processSummaryDataPoints processes summary data points.
-func (p *metricsProcessor) processSummaryDataPoints(dataPoints interface{}, resourceAttrs interface{}) {
-	// Implementation placeholder
+func (p *metricsProcessor) processSummaryDataPoints(dataPoints pmetric.SummaryDataPointSlice, resourceAttrs pcommon.Map, tagged []keyedAttributes) []keyedAttributes {
+	for i := 0; i < dataPoints.Len(); i++ {
+		tagged = p.recordDataPoint(resourceAttrs, dataPoints.At(i).Attributes(), tagged)
+	}
+	return tagged
+}
+
+// recordDataPoint applies the attribute limits to a data point and records
+// its key-set, and when Action is "tag"
+// keeps the data point so it can be tagged if its key-set is over the limit.
+func (p *metricsProcessor) recordDataPoint(resourceAttrs pcommon.Map, attrs pcommon.Map, tagged []keyedAttributes) []keyedAttributes {
+	// Trim oversized attributes before they reach the key-set
+	dropped, truncated := p.limits.enforce(attrs)
+	if dropped > 0 {
+		p.attributesDroppedCounter.Add(float64(dropped))
+	}
+	if truncated > 0 {
+		p.valuesTruncatedCounter.Add(float64(truncated))
+	}
+
+	key := p.recordKeySet(resourceAttrs, attrs)
+	if p.config.Action == "tag" {
+		tagged = append(tagged, keyedAttributes{key: key, attrs: attrs})
+	}
+	return tagged
+}
+
+// shouldAggregate returns whether data should be aggregated onto the
+// aggregation dimensions, which happens once the key-set table is full and
+// the action allows aggregation.
+func (p *metricsProcessor) shouldAggregate() bool {
+	if p.config.Action == "drop" || p.config.Action == "tag" || len(p.config.AggregationDimensions) == 0 {
+		return false
+	}
+
+	return p.keySets.len() >= p.config.MaxUniqueKeySets
+}
+
+// aggregateHistograms collapses histogram data points onto the aggregation
+// dimensions, counting data points dropped for mismatched bucket boundaries
+// and adding a sample of them to the batch spilled to the DLQ.
+func (p *metricsProcessor) aggregateHistograms(rm pmetric.ResourceMetrics, sm pmetric.ScopeMetrics, metric pmetric.Metric, spill *dropSpill) {
+	mismatched := aggregateHistogramDataPoints(metric.Histogram().DataPoints(), rm.Resource().Attributes(), p.config.AggregationDimensions, spill.histogramSampler(rm, sm, metric))
+	if mismatched > 0 {
+		p.boundaryMismatchCounter.Add(float64(mismatched))
+		p.logger.Debug("Dropped histogram data points with mismatched bucket boundaries",
+			zap.Int("count", mismatched),
+		)
+	}
+}
+
+// dropEvicted removes the data points whose key-set isn't in the table,
+// having been evicted by the limit while the batch was processed, adding a
+// sample of them to the batch spilled to the DLQ. Metrics left without data
+// points are removed as well.
+func (p *metricsProcessor) dropEvicted(md pmetric.Metrics, spill *dropSpill) {
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		if p.config.CriticalData.Protected(rm.Resource()) {
+			continue
+		}
+		resourceAttrs := rm.Resource().Attributes()
+
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			sm.Metrics().RemoveIf(func(metric pmetric.Metric) bool {
+				if p.removeEvicted(resourceAttrs, metric, spill.dataPointSampler(rm, sm, metric)) == 0 {
+					return false
+				}
+				return len(dataPointAttributes(metric)) == 0
+			})
+		}
+	}
+}
+
+// removeEvicted removes a metric's data points whose key-set isn't in the
+// table, passing the index of each to sample first if it isn't nil. It
+// returns the number of data points removed.
+func (p *metricsProcessor) removeEvicted(resourceAttrs pcommon.Map, metric pmetric.Metric, sample func(int)) int {
+	evicted := make(map[int]bool)
+	for index, attrs := range dataPointAttributes(metric) {
+		key, _, _ := p.filter.buildKeySet(resourceAttrs, attrs)
+		if p.keySets.contains(key) {
+			continue
+		}
+		evicted[index] = true
+		if sample != nil {
+			sample(index)
+		}
+	}
+	if len(evicted) == 0 {
+		return 0
+	}
+
+	// Data points are visited in order, so a running index identifies them
+	index := -1
+	isEvicted := func() bool {
+		index++
+		return evicted[index]
+	}
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		metric.Gauge().DataPoints().RemoveIf(func(pmetric.NumberDataPoint) bool { return isEvicted() })
+	case pmetric.MetricTypeSum:
+		metric.Sum().DataPoints().RemoveIf(func(pmetric.NumberDataPoint) bool { return isEvicted() })
+	case pmetric.MetricTypeHistogram:
+		metric.Histogram().DataPoints().RemoveIf(func(pmetric.HistogramDataPoint) bool { return isEvicted() })
+	case pmetric.MetricTypeSummary:
+		metric.Summary().DataPoints().RemoveIf(func(pmetric.SummaryDataPoint) bool { return isEvicted() })
+	}
+	return len(evicted)
+}
+
+// dataPointAttributes returns the attributes of each of a metric's data
+// points, for the metric types the limiter tracks.
+func dataPointAttributes(metric pmetric.Metric) []pcommon.Map {
+	var attrs []pcommon.Map
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		for i := 0; i < metric.Gauge().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.Gauge().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeSum:
+		for i := 0; i < metric.Sum().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.Sum().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeHistogram:
+		for i := 0; i < metric.Histogram().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.Histogram().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeSummary:
+		for i := 0; i < metric.Summary().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.Summary().DataPoints().At(i).Attributes())
+		}
+	}
+	return attrs
+}
+
+// recordKeySet forms the key-set for a data point, leaving out filtered
+// attributes, and adds or updates it in the table. It returns the key-set.
+func (p *metricsProcessor) recordKeySet(resourceAttrs pcommon.Map, attrs pcommon.Map) string {
+	key, labels, signature := p.filter.buildKeySet(resourceAttrs, attrs)
+
+	if p.keySets.record(key, labels, signature, p.clock.Now()) {
+		p.collisionsCounter.Inc()
+		p.logger.Debug("Distinct attribute sets share a key-set", zap.String("key", key))
+	}
+	return key
 }
 
 // enforceCardinalityLimit enforces the cardinality limit by dropping or aggregating key-sets.
 func (p *metricsProcessor) enforceCardinalityLimit() {
-	p.keySetTableLock.Lock()
-	defer p.keySetTableLock.Unlock()
-	
-	// Check if we're over the limit
-	if len(p.keySetTable) <= p.config.MaxUniqueKeySets {
+	// Check if we're over the limit before stopping every shard
+	if p.keySets.len() <= p.config.MaxUniqueKeySets {
+		return
+	}
+
+	p.keySets.lockAll()
+	defer p.keySets.unlockAll()
+
+	// Another batch may have enforced the limit while the shards were locked
+	if p.keySets.len() <= p.config.MaxUniqueKeySets {
 		return
 	}
-	
+
+	// Scores cached before the table crossed the limit are recomputed
+	p.keySets.clearDecisions()
+
 	// We're over the limit, apply the configured action
 	switch p.config.Algorithm {
 	case "entropy":
@@ -139,10 +412,51 @@ func (p *metricsProcessor) enforceCardinalityLimit() {
 
 // applyEntropyBasedControl applies entropy-based cardinality control.
 func (p *metricsProcessor) applyEntropyBasedControl() {
-	// Implementation placeholder
-	// 1. Sort key-sets by entropy score
-	// 2. Keep the top N key-sets (where N is the max key-sets allowed)
-	// 3. Drop or aggregate the rest based on the configured action
+	// Select the lowest scoring key-sets beyond the limit
+	toDrop, toAggregate := EntropyBasedCardinalityControl(p.keySets.snapshot(), p.config.MaxUniqueKeySets, EntropyThresholds{
+		DropBelow:        p.config.DropBelowEntropy,
+		AggregateBelow:   p.config.AggregateBelowEntropy,
+		EvictionStrategy: p.config.EvictionStrategy,
+		EvictionWeights:  p.config.EvictionWeights,
+	})
+
+	aggregate := make(map[string]bool, len(toAggregate))
+	if p.config.Action != "drop" && p.config.Action != "tag" {
+		for _, key := range toAggregate {
+			aggregate[key] = true
+		}
+	}
+
+	for _, key := range toDrop {
+		reason := DropReasonLowEntropy
+		if p.config.Action == "tag" {
+			reason = DropReasonTagged
+		} else if aggregate[key] {
+			reason = DropReasonAggregated
+		}
+		p.evictKeySet(key, reason)
+	}
+}
+
+// evictKeySet removes a key-set from the table and records why it was removed.
+// The caller must hold every shard lock.
+func (p *metricsProcessor) evictKeySet(key string, reason string) {
+	info, exists := p.keySets.remove(key)
+	if !exists {
+		return
+	}
+
+	if reason == DropReasonAggregated {
+		p.aggregatedKeysets++
+	} else {
+		p.droppedKeysets++
+	}
+
+	if p.report != nil {
+		p.report.Record(key, info.entropyScore, reason)
+	}
+
+	p.dropLog.LogKeySet("metrics", reason, key)
 }
 
 // applyLRUBasedControl applies LRU-based cardinality control.
@@ -155,6 +469,12 @@ func (p *metricsProcessor) applyRandomBasedControl() {
 	// Implementation placeholder
 }
 
+// Start resolves the DLQ exporter the sample of dropped data points is
+// written to.
+func (p *metricsProcessor) Start(_ context.Context, host component.Host) error {
+	return p.dropSample.start(host, p.config.DLQExporter)
+}
+
 // Capabilities returns the capabilities of the processor.
 func (p *metricsProcessor) Capabilities() consumer.Capabilities {
 	return consumer.Capabilities{MutatesData: true}
@@ -162,5 +482,9 @@ func (p *metricsProcessor) Capabilities() consumer.Capabilities {
 
 // Shutdown stops the processor.
 func (p *metricsProcessor) Shutdown(context.Context) error {
+	p.counters.Unregister()
+	if p.report != nil {
+		return p.report.Stop()
+	}
 	return nil
 }
@@ -0,0 +1,148 @@
+package cardinalitylimiter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// newTestMetricsProcessor creates a metrics processor forwarding to a sink,
+// shut down when the test ends.
+func newTestMetricsProcessor(t *testing.T, configure func(*Config)) (*metricsProcessor, *metricsSink) {
+	t.Helper()
+
+	config := CreateDefaultConfig().(*Config)
+	if configure != nil {
+		configure(config)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+
+	sink := &metricsSink{}
+	p, err := newMetricsProcessor(zap.NewNop(), config, component.NewID(typeStr), sink)
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+	t.Cleanup(func() {
+		p.Shutdown(context.Background())
+	})
+	return p, sink
+}
+
+// seriesMetrics returns a gauge with one data point per user, from a
+// resource with the given priority, or none if it is empty.
+func seriesMetrics(priority string, users int) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	if priority != "" {
+		rm.Resource().Attributes().PutStr("nrdot.priority", priority)
+	}
+	gauge := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	gauge.SetName("sessions")
+	dataPoints := gauge.SetEmptyGauge().DataPoints()
+	for i := 0; i < users; i++ {
+		dp := dataPoints.AppendEmpty()
+		dp.Attributes().PutStr("user.id", fmt.Sprintf("user-%d", i))
+		dp.SetIntValue(int64(i))
+	}
+	return md
+}
+
+// overflowed returns the number of data points tagged as over the limit.
+func overflowed(md pmetric.Metrics) int {
+	count := 0
+	dataPoints := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints()
+	for i := 0; i < dataPoints.Len(); i++ {
+		if _, ok := dataPoints.At(i).Attributes().Get(OverflowAttribute); ok {
+			count++
+		}
+	}
+	return count
+}
+
+func TestCriticalSeriesNeverLimited(t *testing.T) {
+	p, sink := newTestMetricsProcessor(t, func(config *Config) {
+		config.Action = "tag"
+		config.MaxUniqueKeySets = 5
+		config.CriticalData.NeverDrop = true
+	})
+
+	for _, priority := range []string{"critical", "normal"} {
+		if err := p.ConsumeMetrics(context.Background(), seriesMetrics(priority, 20)); err != nil {
+			t.Fatalf("failed to consume metrics: %v", err)
+		}
+	}
+
+	if len(sink.batches) != 2 {
+		t.Fatalf("expected 2 batches to be forwarded, got %d", len(sink.batches))
+	}
+	if got := overflowed(sink.batches[0]); got != 0 {
+		t.Fatalf("expected no critical series to be limited, got %d", got)
+	}
+	if got := overflowed(sink.batches[1]); got == 0 {
+		t.Fatal("expected normal series over the limit to be tagged")
+	}
+}
+
+func TestCriticalSeriesSurviveDrop(t *testing.T) {
+	p, sink := newTestMetricsProcessor(t, func(config *Config) {
+		config.Action = "drop"
+		config.MaxUniqueKeySets = 5
+		config.CriticalData.NeverDrop = true
+	})
+
+	// A batch with a critical and a normal resource, each over the limit
+	md := seriesMetrics("critical", 20)
+	seriesMetrics("normal", 20).ResourceMetrics().At(0).CopyTo(md.ResourceMetrics().AppendEmpty())
+	if err := p.ConsumeMetrics(context.Background(), md); err != nil {
+		t.Fatalf("failed to consume metrics: %v", err)
+	}
+
+	forwarded := sink.batches[0].ResourceMetrics()
+	if got := forwarded.At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().Len(); got != 20 {
+		t.Fatalf("expected all 20 critical data points to be forwarded, got %d", got)
+	}
+	if got := forwarded.At(1).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().Len(); got != 5 {
+		t.Fatalf("expected the normal data points to be dropped down to the limit of 5, got %d", got)
+	}
+}
+
+func TestTagActionForwardsOverLimitPoints(t *testing.T) {
+	p, sink := newTestMetricsProcessor(t, func(config *Config) {
+		config.Action = "tag"
+		config.MaxUniqueKeySets = 5
+	})
+
+	// Under the limit nothing is tagged
+	if err := p.ConsumeMetrics(context.Background(), seriesMetrics("", 5)); err != nil {
+		t.Fatalf("failed to consume metrics: %v", err)
+	}
+	if got := overflowed(sink.batches[0]); got != 0 {
+		t.Fatalf("expected no data points under the limit to be tagged, got %d", got)
+	}
+
+	// Over the limit every data point is still forwarded, some tagged
+	if err := p.ConsumeMetrics(context.Background(), seriesMetrics("", 20)); err != nil {
+		t.Fatalf("failed to consume metrics: %v", err)
+	}
+	md := sink.batches[1]
+	if md.DataPointCount() != 20 {
+		t.Fatalf("expected all 20 data points to be forwarded, got %d", md.DataPointCount())
+	}
+	tagged := overflowed(md)
+	if tagged == 0 || tagged == 20 {
+		t.Fatalf("expected only the data points over the limit to be tagged, got %d of 20", tagged)
+	}
+	dataPoints := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints()
+	for i := 0; i < dataPoints.Len(); i++ {
+		if value, ok := dataPoints.At(i).Attributes().Get(OverflowAttribute); ok && value.AsString() != "true" {
+			t.Fatalf("expected the tag to be \"true\", got %q", value.AsString())
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package cardinalitylimiter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// Drop reasons recorded in the cardinality report.
+const (
+	DropReasonLowEntropy = "low_entropy"
+	DropReasonAggregated = "aggregated"
+	DropReasonLRU        = "lru"
+	DropReasonRandom     = "random"
+	DropReasonTagged     = "tagged"
+)
+
+// reportEntry describes a dropped series in the cardinality report.
+type reportEntry struct {
+	Key          string  `json:"key"`
+	EntropyScore float64 `json:"entropy_score"`
+	Reason       string  `json:"reason"`
+	Count        int64   `json:"count"`
+}
+
+// DropReport accumulates dropped series and periodically writes them to a
+// rotating report file.
+type DropReport struct {
+	logger   *zap.Logger
+	clock    clock.Clock
+	path     string
+	format   string
+	maxFiles int
+	interval time.Duration
+
+	entries map[string]*reportEntry
+	mutex   sync.Mutex
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewDropReport creates a new drop report writer.
+func NewDropReport(logger *zap.Logger, config *Config, clk clock.Clock) *DropReport {
+	return &DropReport{
+		logger:   logger,
+		clock:    clk,
+		path:     config.ReportPath,
+		format:   config.ReportFormat,
+		maxFiles: config.ReportMaxFiles,
+		interval: time.Duration(config.ReportIntervalMinutes) * time.Minute,
+		entries:  make(map[string]*reportEntry),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+// Record records a dropped series with the reason it was dropped.
+func (r *DropReport) Record(key string, entropyScore float64, reason string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Entries are keyed by series and reason so a series dropped for
+	// different reasons shows up once per reason
+	entryKey := reason + "|" + key
+	entry, exists := r.entries[entryKey]
+	if !exists {
+		entry = &reportEntry{
+			Key:    key,
+			Reason: reason,
+		}
+		r.entries[entryKey] = entry
+	}
+
+	entry.EntropyScore = entropyScore
+	entry.Count++
+}
+
+// Start starts the periodic report writer.
+func (r *DropReport) Start() {
+	go r.flushLoop()
+}
+
+// Stop stops the periodic report writer and writes a final report.
+func (r *DropReport) Stop() error {
+	close(r.stopCh)
+	<-r.doneCh
+	return r.Flush()
+}
+
+// flushLoop periodically writes the report.
+func (r *DropReport) flushLoop() {
+	defer close(r.doneCh)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stopCh:
+			return
+		case <-ticker.C:
+			if err := r.Flush(); err != nil {
+				r.logger.Error("Failed to write cardinality report", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Flush writes the accumulated entries to the report file, rotating the
+// previous report, and resets the accumulated entries.
+func (r *DropReport) Flush() error {
+	r.mutex.Lock()
+	entries := make([]*reportEntry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		entries = append(entries, entry)
+	}
+	r.entries = make(map[string]*reportEntry)
+	r.mutex.Unlock()
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// Most frequently dropped series first
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	if err := r.rotate(); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial report
+	tmpPath := r.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create cardinality report: %w", err)
+	}
+
+	switch r.format {
+	case "json":
+		err = writeJSONReport(file, entries, r.clock.Now().UTC())
+	default:
+		err = writeCSVReport(file, entries)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close cardinality report: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, r.path); err != nil {
+		return fmt.Errorf("failed to move cardinality report into place: %w", err)
+	}
+
+	r.logger.Info("Wrote cardinality report",
+		zap.String("path", r.path),
+		zap.Int("entries", len(entries)),
+	)
+
+	return nil
+}
+
+// rotate shifts existing reports to make room for a new one, keeping at
+// most maxFiles previous reports.
+func (r *DropReport) rotate() error {
+	if r.maxFiles <= 0 {
+		return nil
+	}
+
+	oldest := fmt.Sprintf("%s.%d", r.path, r.maxFiles)
+	if err := os.Remove(oldest); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove oldest cardinality report: %w", err)
+	}
+
+	for i := r.maxFiles - 1; i >= 0; i-- {
+		src := r.path
+		if i > 0 {
+			src = fmt.Sprintf("%s.%d", r.path, i)
+		}
+		dst := fmt.Sprintf("%s.%d", r.path, i+1)
+
+		if err := os.Rename(src, dst); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate cardinality report: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeCSVReport writes report entries as CSV.
+func writeCSVReport(file *os.File, entries []*reportEntry) error {
+	w := csv.NewWriter(file)
+	if err := w.Write([]string{"key", "entropy_score", "reason", "count"}); err != nil {
+		return fmt.Errorf("failed to write cardinality report header: %w", err)
+	}
+
+	for _, entry := range entries {
+		record := []string{
+			entry.Key,
+			strconv.FormatFloat(entry.EntropyScore, 'f', 4, 64),
+			entry.Reason,
+			strconv.FormatInt(entry.Count, 10),
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("failed to write cardinality report entry: %w", err)
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// writeJSONReport writes report entries as a JSON document.
+func writeJSONReport(file *os.File, entries []*reportEntry, generatedAt time.Time) error {
+	report := struct {
+		GeneratedAt time.Time      `json:"generated_at"`
+		Entries     []*reportEntry `json:"entries"`
+	}{
+		GeneratedAt: generatedAt,
+		Entries:     entries,
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("failed to write cardinality report: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package cardinalitylimiter
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readReport returns the rows of a CSV cardinality report, without its
+// header.
+func readReport(t *testing.T, path string) [][]string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open report: %v", err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	return rows[1:]
+}
+
+func TestReportListsDroppedSeries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dropped.csv")
+	p, _ := newTestMetricsProcessor(t, func(config *Config) {
+		config.Action = "drop"
+		config.MaxUniqueKeySets = 5
+		config.ReportPath = path
+		config.ReportMaxFiles = 2
+	})
+
+	if err := p.ConsumeMetrics(context.Background(), seriesMetrics("", 20)); err != nil {
+		t.Fatalf("failed to consume metrics: %v", err)
+	}
+	if err := p.report.Flush(); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+
+	rows := readReport(t, path)
+	if len(rows) == 0 {
+		t.Fatal("expected the report to list the dropped series")
+	}
+	for _, row := range rows {
+		if !strings.Contains(row[0], "user.id=user-") || row[2] != DropReasonLowEntropy || row[3] != "1" {
+			t.Fatalf("expected a dropped user series with its reason, got %v", row)
+		}
+	}
+
+	// The next report rotates the previous one
+	if err := p.ConsumeMetrics(context.Background(), seriesMetrics("", 40)); err != nil {
+		t.Fatalf("failed to consume metrics: %v", err)
+	}
+	if err := p.report.Flush(); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+	if previous := readReport(t, path+".1"); len(previous) != len(rows) {
+		t.Fatalf("expected the previous report to be kept with %d entries, got %d", len(rows), len(previous))
+	}
+}
@@ -0,0 +1,145 @@
+package cardinalitylimiter
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// keySetShard is one stripe of the key-set table, guarded by its own lock.
+type keySetShard struct {
+	keySets map[string]keySetInfo
+	lock    sync.Mutex
+
+	// Label value history of the key-sets recorded in the shard, used to
+	// score them, and recent scores reused for repeated key-sets
+	entropy   *EntropyCalculator
+	decisions *decisionCache
+}
+
+// keySetTable stores key-sets split into shards by a hash of the key, so
+// data points with different key-sets rarely contend for the same lock. The
+// global cap is enforced by locking every shard, always in index order.
+type keySetTable struct {
+	shards []*keySetShard
+
+	// Number of key-sets across all shards, readable without locking
+	size int64
+}
+
+// newKeySetTable creates a key-set table with the given number of shards,
+// sized for capacity key-sets in total. Each shard scores its key-sets with
+// an entropy calculator and decision cache of its own, so scoring doesn't
+// serialize shards either.
+func newKeySetTable(shards int, capacity int, newEntropy func() *EntropyCalculator, newDecisions func() *decisionCache) *keySetTable {
+	t := &keySetTable{shards: make([]*keySetShard, shards)}
+	for i := range t.shards {
+		t.shards[i] = &keySetShard{
+			keySets:   make(map[string]keySetInfo, capacity/shards),
+			entropy:   newEntropy(),
+			decisions: newDecisions(),
+		}
+	}
+	return t
+}
+
+// shard returns the shard a key belongs to.
+func (t *keySetTable) shard(key string) *keySetShard {
+	if len(t.shards) == 1 {
+		return t.shards[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return t.shards[h.Sum32()%uint32(len(t.shards))]
+}
+
+// len returns the number of key-sets in the table.
+func (t *keySetTable) len() int {
+	return int(atomic.LoadInt64(&t.size))
+}
+
+// record adds the labels of a key-set seen at now to its shard's history,
+// scores it unless the shard has a score cached for it, and adds or updates
+// it in the table. It returns true if the key-set was recorded before with a
+// different attribute signature, meaning two distinct attribute sets share
+// the key and their series are being merged.
+func (t *keySetTable) record(key string, labels map[string]string, signature uint64, now time.Time) bool {
+	s := t.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.entropy.AddLabelSet(labels)
+	score, cached := s.decisions.get(key, now)
+	if !cached {
+		score = s.entropy.CalculateEntropyScore(labels)
+		s.decisions.put(key, score, now)
+	}
+
+	info, exists := s.keySets[key]
+	if !exists {
+		atomic.AddInt64(&t.size, 1)
+		info.signature = signature
+	}
+	info.lastSeen = now.Unix()
+	info.entropyScore = score
+	info.accessCount++
+	s.keySets[key] = info
+	return exists && info.signature != signature
+}
+
+// contains returns whether a key-set is in the table.
+func (t *keySetTable) contains(key string) bool {
+	s := t.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, exists := s.keySets[key]
+	return exists
+}
+
+// lockAll locks every shard so the table can be enforced as a whole.
+func (t *keySetTable) lockAll() {
+	for _, s := range t.shards {
+		s.lock.Lock()
+	}
+}
+
+// unlockAll unlocks every shard.
+func (t *keySetTable) unlockAll() {
+	for _, s := range t.shards {
+		s.lock.Unlock()
+	}
+}
+
+// clearDecisions removes every cached score. The caller must hold every
+// shard lock.
+func (t *keySetTable) clearDecisions() {
+	for _, s := range t.shards {
+		s.decisions.clear()
+	}
+}
+
+// snapshot returns all key-sets in a single map. The caller must hold every
+// shard lock.
+func (t *keySetTable) snapshot() map[string]keySetInfo {
+	all := make(map[string]keySetInfo, t.len())
+	for _, s := range t.shards {
+		for key, info := range s.keySets {
+			all[key] = info
+		}
+	}
+	return all
+}
+
+// remove deletes a key-set and returns its metadata. The caller must hold
+// every shard lock.
+func (t *keySetTable) remove(key string) (keySetInfo, bool) {
+	s := t.shard(key)
+	info, exists := s.keySets[key]
+	if exists {
+		delete(s.keySets, key)
+		atomic.AddInt64(&t.size, -1)
+	}
+	return info, exists
+}
@@ -0,0 +1,117 @@
+package cardinalitylimiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// newShardedMetricsProcessor creates a metrics processor with the given
+// number of key-set shards, discarding what it forwards so it can be fed
+// concurrently.
+func newShardedMetricsProcessor(tb testing.TB, shards int, maxKeySets int) *metricsProcessor {
+	tb.Helper()
+
+	config := CreateDefaultConfig().(*Config)
+	config.Action = "drop"
+	config.KeySetShards = shards
+	config.MaxUniqueKeySets = maxKeySets
+	if err := config.Validate(); err != nil {
+		tb.Fatalf("invalid config: %v", err)
+	}
+
+	p, err := newMetricsProcessor(zap.NewNop(), config, component.NewID(typeStr), consumertest.NewNop())
+	if err != nil {
+		tb.Fatalf("failed to create processor: %v", err)
+	}
+	tb.Cleanup(func() {
+		p.Shutdown(context.Background())
+	})
+	return p
+}
+
+// userMetrics returns a gauge with one data point for each of a producer's
+// users, distinct from those of every other producer.
+func userMetrics(producer int, users int) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	gauge := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	gauge.SetName("sessions")
+	dataPoints := gauge.SetEmptyGauge().DataPoints()
+	for i := 0; i < users; i++ {
+		dp := dataPoints.AppendEmpty()
+		dp.Attributes().PutStr("user.id", fmt.Sprintf("user-%d-%d", producer, i))
+		dp.SetIntValue(int64(i))
+	}
+	return md
+}
+
+func TestKeySetLimitHoldsAcrossShards(t *testing.T) {
+	p := newShardedMetricsProcessor(t, 8, 50)
+
+	// Concurrent batches each bring more key-sets than the limit
+	var producers sync.WaitGroup
+	for producer := 0; producer < 8; producer++ {
+		producers.Add(1)
+		go func(producer int) {
+			defer producers.Done()
+			for batch := 0; batch < 10; batch++ {
+				p.ConsumeMetrics(context.Background(), userMetrics(producer*10+batch, 20))
+			}
+		}(producer)
+	}
+	producers.Wait()
+
+	if got := p.keySets.len(); got > 50 {
+		t.Fatalf("expected at most 50 key-sets across all shards, got %d", got)
+	}
+
+	// The key-sets are spread over the shards rather than held in one
+	used := 0
+	for _, s := range p.keySets.shards {
+		if len(s.keySets) > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Fatalf("expected the key-sets to be spread over the shards, got %d shards in use", used)
+	}
+}
+
+// benchmarkRecordKeySetParallel records distinct key-sets from every
+// goroutine at once, as concurrent batches from many series do.
+func benchmarkRecordKeySetParallel(b *testing.B, shards int) {
+	p := newShardedMetricsProcessor(b, shards, 100000)
+
+	resourceAttrs := pcommon.NewMap()
+	resourceAttrs.PutStr("service.name", "checkout")
+	attrs := make([]pcommon.Map, 1024)
+	for i := range attrs {
+		attrs[i] = pcommon.NewMap()
+		attrs[i].PutStr("http.route", fmt.Sprintf("/api/%d", i))
+		attrs[i].PutStr("http.method", "GET")
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			p.recordKeySet(resourceAttrs, attrs[i%len(attrs)])
+		}
+	})
+}
+
+func BenchmarkRecordKeySetParallel(b *testing.B) {
+	for _, shards := range []int{1, 16} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			benchmarkRecordKeySetParallel(b, shards)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package cardinalitylimiter
+
+import (
+	"hash/fnv"
+)
+
+// Dimensions of the count-min sketches used for label values beyond the
+// per-label tracking cap. 2048x4 counters is 32 KiB per label.
+const (
+	sketchWidth = 2048
+	sketchDepth = 4
+)
+
+// countMinSketch approximates value counts in fixed memory. Estimates never
+// undercount, and overcount by a small fraction of the total with high
+// probability.
+type countMinSketch struct {
+	counts [sketchDepth][sketchWidth]uint32
+}
+
+// newCountMinSketch creates an empty count-min sketch.
+func newCountMinSketch() *countMinSketch {
+	return &countMinSketch{}
+}
+
+// add increments the count for a value.
+func (s *countMinSketch) add(value string) {
+	h1, h2 := sketchHashes(value)
+	for i := 0; i < sketchDepth; i++ {
+		idx := (h1 + uint32(i)*h2) % sketchWidth
+		if s.counts[i][idx] < ^uint32(0) {
+			s.counts[i][idx]++
+		}
+	}
+}
+
+// scale multiplies every count by a factor, rounding down, so that old
+// occurrences weigh less than recent ones.
+func (s *countMinSketch) scale(factor float64) {
+	for i := range s.counts {
+		for j := range s.counts[i] {
+			s.counts[i][j] = uint32(float64(s.counts[i][j]) * factor)
+		}
+	}
+}
+
+// estimate returns the approximate count for a value.
+func (s *countMinSketch) estimate(value string) uint32 {
+	h1, h2 := sketchHashes(value)
+	min := ^uint32(0)
+	for i := 0; i < sketchDepth; i++ {
+		idx := (h1 + uint32(i)*h2) % sketchWidth
+		if s.counts[i][idx] < min {
+			min = s.counts[i][idx]
+		}
+	}
+	return min
+}
+
+// sketchHashes derives the two hashes used to index the sketch rows.
+func sketchHashes(value string) (uint32, uint32) {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	sum := h.Sum64()
+	return uint32(sum), uint32(sum>>32) | 1
+}
@@ -0,0 +1,29 @@
+package cardinalitylimiter
+
+import (
+	"sync/atomic"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// OverflowAttribute is the attribute set to "true" on data points whose
+// key-set is over the cardinality limit when Action is "tag".
+const OverflowAttribute = "nrdot.cardinality_overflow"
+
+// keyedAttributes pairs a data point's attributes with its key-set, so the
+// data point can be tagged once the batch's key-sets have been admitted.
+type keyedAttributes struct {
+	key   string
+	attrs pcommon.Map
+}
+
+// tagOverflow tags the data points whose key-set didn't fit in the key-set
+// table. Data points whose key-set was kept are left untagged.
+func (p *metricsProcessor) tagOverflow(dataPoints []keyedAttributes) {
+	for _, dp := range dataPoints {
+		if !p.keySets.contains(dp.key) {
+			dp.attrs.PutStr(OverflowAttribute, "true")
+			atomic.AddInt64(&p.taggedDataPoints, 1)
+		}
+	}
+}
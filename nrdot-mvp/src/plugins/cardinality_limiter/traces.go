@@ -3,9 +3,13 @@ package cardinalitylimiter
 import (
 	"context"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
 )
 
 // tracesProcessor is the processor for applying cardinality control to traces.
@@ -13,20 +17,40 @@ type tracesProcessor struct {
 	logger       *zap.Logger
 	config       *Config
 	nextConsumer consumer.Traces
+
+	// Counters registered for this processor
+	counters *promreg.Registry
+
+	// Distinct values of span attributes, nil in metrics-only mode
+	attributes *attributeCardinality
+
+	// Span attributes dropped, aggregated or tagged for too many values
+	attributesLimitedCounter prometheus.Counter
 }
 
 // newTracesProcessor creates a new traces processor for cardinality control.
-func newTracesProcessor(logger *zap.Logger, config *Config, nextConsumer consumer.Traces) (*tracesProcessor, error) {
+func newTracesProcessor(logger *zap.Logger, config *Config, id component.ID, nextConsumer consumer.Traces) (*tracesProcessor, error) {
 	// Skip implementation if metrics-only mode is enabled
 	if config.MetricsOnly {
 		logger.Info("Cardinality limiter is in metrics-only mode, traces will pass through unchanged")
 	}
-	
-	return &tracesProcessor{
+
+	p := &tracesProcessor{
 		logger:       logger,
 		config:       config,
 		nextConsumer: nextConsumer,
-	}, nil
+		counters:     promreg.New(logger, prometheus.Labels{"processor": id.String()}),
+	}
+
+	if !config.MetricsOnly {
+		p.attributes = newAttributeCardinality(config)
+		p.attributesLimitedCounter = p.counters.Counter(prometheus.CounterOpts{
+			Name: "otelcol_cardinality_limiter_span_attributes_limited_total",
+			Help: "Span attributes dropped, aggregated or tagged for exceeding max_values_per_attribute",
+		})
+	}
+
+	return p, nil
 }
 
 // ConsumeTraces applies cardinality control to the incoming traces.
@@ -35,14 +59,34 @@ func (p *tracesProcessor) ConsumeTraces(ctx context.Context, td ptrace.Traces) e
 	if p.config.MetricsOnly {
 		return p.nextConsumer.ConsumeTraces(ctx, td)
 	}
-	
-	// Apply cardinality control to traces
-	// This would be similar to the metrics implementation but for trace data
-	
+
+	// Limit the distinct values of span attributes, critical data excepted
+	limited := 0
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		if p.config.CriticalData.Protected(rs.Resource()) {
+			continue
+		}
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				limited += p.attributes.limit(spans.At(k).Attributes())
+			}
+		}
+	}
+	if limited > 0 {
+		p.attributesLimitedCounter.Add(float64(limited))
+	}
+
 	// Forward the processed traces to the next consumer
 	return p.nextConsumer.ConsumeTraces(ctx, td)
 }
 
+// Start starts the processor.
+func (p *tracesProcessor) Start(context.Context, component.Host) error {
+	return nil
+}
+
 // Capabilities returns the capabilities of the processor.
 func (p *tracesProcessor) Capabilities() consumer.Capabilities {
 	return consumer.Capabilities{MutatesData: !p.config.MetricsOnly}
@@ -50,5 +94,6 @@ func (p *tracesProcessor) Capabilities() consumer.Capabilities {
 
 // Shutdown stops the processor.
 func (p *tracesProcessor) Shutdown(context.Context) error {
+	p.counters.Unregister()
 	return nil
 }
@@ -0,0 +1,75 @@
+package cardinalitylimiter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// tracesSink records the traces forwarded to it.
+type tracesSink struct {
+	batches []ptrace.Traces
+}
+
+func (s *tracesSink) ConsumeTraces(_ context.Context, td ptrace.Traces) error {
+	s.batches = append(s.batches, td)
+	return nil
+}
+
+func (s *tracesSink) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{}
+}
+
+func TestTracesLimitSpanAttributes(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.MetricsOnly = false
+	config.MaxValuesPerAttribute = 10
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+
+	sink := &tracesSink{}
+	p, err := newTracesProcessor(zap.NewNop(), config, component.NewID(typeStr), sink)
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+	if err := p.Start(context.Background(), nil); err != nil {
+		t.Fatalf("failed to start processor: %v", err)
+	}
+	defer p.Shutdown(context.Background())
+
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	for i := 0; i < 50; i++ {
+		span := spans.AppendEmpty()
+		span.SetName("checkout")
+		span.Attributes().PutStr("user.id", fmt.Sprintf("user-%d", i))
+		span.Attributes().PutStr("http.method", "GET")
+	}
+	if err := p.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatalf("failed to consume traces: %v", err)
+	}
+
+	if len(sink.batches) != 1 || sink.batches[0].SpanCount() != 50 {
+		t.Fatalf("expected every span to be forwarded")
+	}
+	forwarded := sink.batches[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+	overflowed := 0
+	for i := 0; i < forwarded.Len(); i++ {
+		attrs := forwarded.At(i).Attributes()
+		if method, _ := attrs.Get("http.method"); method.AsString() != "GET" {
+			t.Fatalf("expected the low-cardinality attribute to be kept, got %q", method.AsString())
+		}
+		if userID, _ := attrs.Get("user.id"); userID.AsString() == OverflowValue {
+			overflowed++
+		}
+	}
+	if overflowed != 40 {
+		t.Fatalf("expected the 40 user IDs past the limit to be aggregated, got %d", overflowed)
+	}
+}
@@ -0,0 +1,99 @@
+package enhanceddlq
+
+import (
+	"math"
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// liveRateWindow is how often the live traffic estimate is updated.
+const liveRateWindow = time.Second
+
+// liveIdleAfter is how long without live traffic before replay stops waiting
+// for its turn.
+const liveIdleAfter = 2 * liveRateWindow
+
+// maxReplayShareBoost caps how many times InterleaveRatio replay records may
+// be processed per turn while live traffic is light.
+const maxReplayShareBoost = 16
+
+// liveRate estimates the live traffic rate, in batches per second, so the
+// interleave can let replay run freely while live traffic is idle and, with
+// AdaptiveInterleave, give replay a larger share while live traffic is light
+// and a smaller one while it surges. It is guarded by the interleave
+// controller's mutex.
+type liveRate struct {
+	clock clock.Clock
+
+	// Live batches per second at which replay gets exactly InterleaveRatio
+	// records per turn
+	high float64
+
+	rate        float64 // moving average of batches per second
+	windowStart time.Time
+	batches     int64
+	lastBatch   time.Time
+}
+
+// newLiveRate creates a live traffic estimate against the given high rate.
+func newLiveRate(clk clock.Clock, high float64) *liveRate {
+	return &liveRate{
+		clock:       clk,
+		high:        high,
+		windowStart: clk.Now(),
+	}
+}
+
+// reset forgets the live traffic seen so far.
+func (l *liveRate) reset() {
+	l.rate = 0
+	l.windowStart = l.clock.Now()
+	l.batches = 0
+	l.lastBatch = time.Time{}
+}
+
+// observe counts a live batch.
+func (l *liveRate) observe() {
+	now := l.clock.Now()
+	l.roll(now)
+	l.batches++
+	l.lastBatch = now
+}
+
+// roll folds the batches of the elapsed window into the moving average.
+func (l *liveRate) roll(now time.Time) {
+	elapsed := now.Sub(l.windowStart)
+	if elapsed < liveRateWindow {
+		return
+	}
+	l.rate = (l.rate + float64(l.batches)/elapsed.Seconds()) / 2
+	l.windowStart = now
+	l.batches = 0
+}
+
+// idle returns whether no live batch has arrived recently.
+func (l *liveRate) idle() bool {
+	return l.clock.Now().Sub(l.lastBatch) >= liveIdleAfter
+}
+
+// replayShare returns the number of replay records per turn: ratio when the
+// live rate is at the high rate, more when it is below and fewer, down to 1,
+// when it is above.
+func (l *liveRate) replayShare(ratio int) int {
+	l.roll(l.clock.Now())
+
+	limit := ratio * maxReplayShareBoost
+	if l.rate <= 0 {
+		return limit
+	}
+
+	share := int(math.Round(float64(ratio) * l.high / l.rate))
+	if share < 1 {
+		return 1
+	}
+	if share > limit {
+		return limit
+	}
+	return share
+}
@@ -0,0 +1,95 @@
+package enhanceddlq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// newTestInterleave creates an interleave controller with the given ratio,
+// tracking live traffic on a fake clock, and adapting the replay share to it
+// when adaptive is set.
+func newTestInterleave(ratio int, adaptive bool) (*InterleaveController, *clock.FakeClock) {
+	fake := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	i := &InterleaveController{
+		ratio:         ratio,
+		replayAllowed: true,
+		liveAllowed:   true,
+		live:          newLiveRate(fake, 10),
+		adaptive:      adaptive,
+	}
+	return i, fake
+}
+
+// sendLive feeds live batches at the given rate per second for a number of
+// seconds.
+func sendLive(i *InterleaveController, fake *clock.FakeClock, rate int, seconds int) {
+	for s := 0; s < seconds; s++ {
+		for b := 0; b < rate; b++ {
+			fake.Advance(time.Second / time.Duration(rate))
+			i.AllowLive()
+		}
+	}
+}
+
+// replayTurn returns the number of replay records allowed before replay has
+// to wait for live traffic, up to limit.
+func replayTurn(i *InterleaveController, limit int) int {
+	allowed := 0
+	for allowed < limit && i.AllowReplay() {
+		allowed++
+	}
+	return allowed
+}
+
+func TestIdleLiveStreamIncreasesReplayThroughput(t *testing.T) {
+	for _, adaptive := range []bool{false, true} {
+		i, fake := newTestInterleave(2, adaptive)
+
+		// Replay doesn't wait for live traffic that never arrives
+		if got := replayTurn(i, 1000); got != 1000 {
+			t.Fatalf("adaptive=%v: expected replay not to wait for an idle live stream, got %d records", adaptive, got)
+		}
+
+		sendLive(i, fake, 10, 3)
+		if got := replayTurn(i, 1000); got >= 1000 {
+			t.Fatalf("adaptive=%v: expected replay to wait its turn again once live traffic arrives", adaptive)
+		}
+		fake.Advance(liveIdleAfter)
+		if got := replayTurn(i, 1000); got != 1000 {
+			t.Fatalf("adaptive=%v: expected replay to stop waiting once live traffic stops, got %d records", adaptive, got)
+		}
+	}
+}
+
+func TestFixedReplayShareWithLiveTraffic(t *testing.T) {
+	i, fake := newTestInterleave(2, false)
+
+	// Without adaptation, the share stays at the ratio however light live
+	// traffic is
+	sendLive(i, fake, 1, 1)
+	if got := replayTurn(i, 1000); got != 2 {
+		t.Fatalf("expected replay to stop after its share of 2, got %d records", got)
+	}
+}
+
+func TestReplayShareFollowsLiveRate(t *testing.T) {
+	share := func(rate int) int {
+		t.Helper()
+		i, fake := newTestInterleave(4, true)
+		sendLive(i, fake, rate, 5)
+		return replayTurn(i, 1000)
+	}
+
+	light, high, surge := share(2), share(10), share(40)
+	if high != 4 {
+		t.Fatalf("expected the ratio of 4 per turn at the high rate, got %d", high)
+	}
+	if light <= high || light > 4*maxReplayShareBoost {
+		t.Fatalf("expected a larger, capped share while live traffic is light, got %d", light)
+	}
+	if surge >= high || surge < 1 {
+		t.Fatalf("expected a smaller share of at least 1 while live traffic surges, got %d", surge)
+	}
+}
@@ -0,0 +1,95 @@
+package enhanceddlq
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// adaptiveRateWindow is how often the adaptive replay rate is adjusted.
+const adaptiveRateWindow = time.Second
+
+// adaptiveRateSteps is the number of windows without errors it takes to ramp
+// from the minimum to the maximum replay rate.
+const adaptiveRateSteps = 10
+
+// adaptiveRate tunes the replay rate to backend health with additive increase,
+// multiplicative decrease: every window without consumer errors raises the
+// rate by a fixed step up to the configured maximum, and any window with
+// errors halves it down to the minimum.
+type adaptiveRate struct {
+	limiter *RateLimiter
+	clock   clock.Clock
+
+	minBytesPerSecond float64
+	maxBytesPerSecond float64
+	step              float64
+
+	rate        float64
+	windowStart time.Time
+	successes   int64
+	errors      int64
+	mutex       sync.Mutex
+}
+
+// newAdaptiveRate creates an adaptive rate controlling the limiter, between
+// minMiBSec and maxMiBSec.
+func newAdaptiveRate(limiter *RateLimiter, clk clock.Clock, minMiBSec float64, maxMiBSec float64) *adaptiveRate {
+	a := &adaptiveRate{
+		limiter:           limiter,
+		clock:             clk,
+		minBytesPerSecond: minMiBSec * 1024 * 1024,
+		maxBytesPerSecond: maxMiBSec * 1024 * 1024,
+	}
+	a.step = (a.maxBytesPerSecond - a.minBytesPerSecond) / adaptiveRateSteps
+	return a
+}
+
+// reset starts again from the minimum rate, as a replay after an outage
+// shouldn't assume the backend has recovered.
+func (a *adaptiveRate) reset() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.rate = a.minBytesPerSecond
+	a.windowStart = a.clock.Now()
+	a.successes = 0
+	a.errors = 0
+	a.limiter.SetRate(int64(a.rate))
+}
+
+// record counts the outcome of consuming a replayed record, adjusting the
+// rate once the window has elapsed.
+func (a *adaptiveRate) record(err error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err != nil {
+		a.errors++
+	} else {
+		a.successes++
+	}
+
+	now := a.clock.Now()
+	if now.Sub(a.windowStart) < adaptiveRateWindow {
+		return
+	}
+
+	if a.errors > 0 {
+		a.rate /= 2
+		if a.rate < a.minBytesPerSecond {
+			a.rate = a.minBytesPerSecond
+		}
+	} else {
+		a.rate += a.step
+		if a.rate > a.maxBytesPerSecond {
+			a.rate = a.maxBytesPerSecond
+		}
+	}
+
+	a.windowStart = now
+	a.successes = 0
+	a.errors = 0
+	a.limiter.SetRate(int64(a.rate))
+}
@@ -0,0 +1,78 @@
+package enhanceddlq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+func TestAdaptiveRateStartsEachReplayAtMinimum(t *testing.T) {
+	storage, fake := newTestStorage(t, func(config *Config) {
+		config.AdaptiveReplayRate = true
+		config.ReplayRateMiBSec = 2
+		config.ReplayMinRateMiBSec = 1
+	})
+
+	const mib = 1024 * 1024
+	if rate := storage.rateLimiter.Rate(); rate != 2*mib {
+		t.Fatalf("expected the configured rate before any replay, got %d", rate)
+	}
+
+	// A replay ramps the rate up from the minimum
+	storage.adaptiveRate.reset()
+	if rate := storage.rateLimiter.Rate(); rate != mib {
+		t.Fatalf("expected a replay to start at the minimum rate, got %d", rate)
+	}
+	fake.Advance(time.Second)
+	storage.adaptiveRate.record(nil)
+	if rate := storage.rateLimiter.Rate(); rate != mib+mib/adaptiveRateSteps {
+		t.Fatalf("expected one step up after an error-free window, got %d", rate)
+	}
+
+	// The next replay starts from the minimum again
+	storage.adaptiveRate.reset()
+	if rate := storage.rateLimiter.Rate(); rate != mib {
+		t.Fatalf("expected the next replay to start at the minimum rate, got %d", rate)
+	}
+}
+
+func TestAdaptiveRateHalvesOnErrors(t *testing.T) {
+	fake := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	limiter := &RateLimiter{clock: fake}
+	rate := newAdaptiveRate(limiter, fake, 1, 8)
+	rate.reset()
+
+	// One window more than the ramp takes, so the rate is capped
+	for i := 0; i <= adaptiveRateSteps; i++ {
+		fake.Advance(time.Second)
+		rate.record(nil)
+	}
+	if got := limiter.Rate(); got != 8*1024*1024 {
+		t.Fatalf("expected the maximum rate after an error-free ramp, got %d", got)
+	}
+
+	fake.Advance(time.Second)
+	rate.record(errors.New("backend unavailable"))
+	if got := limiter.Rate(); got != 4*1024*1024 {
+		t.Fatalf("expected the rate to halve after an error, got %d", got)
+	}
+}
+
+func TestReplayMinRateOnlyValidatedWhenAdaptive(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.Directory = t.TempDir()
+	config.ReplayRateMiBSec = 0.1
+	if err := config.Validate(); err != nil {
+		t.Fatalf("expected a rate below the minimum to be valid without the adaptive rate: %v", err)
+	}
+
+	config = CreateDefaultConfig().(*Config)
+	config.Directory = t.TempDir()
+	config.ReplayRateMiBSec = 0.1
+	config.AdaptiveReplayRate = true
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected a minimum above the adaptive maximum to be rejected")
+	}
+}
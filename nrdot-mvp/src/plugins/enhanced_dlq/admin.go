@@ -0,0 +1,251 @@
+package enhanceddlq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// adminTarget is an exporter operated through the admin endpoint.
+type adminTarget interface {
+	StartReplay(ctx context.Context) error
+	ReplayFile(ctx context.Context, path string) error
+	Compact() error
+	EstimateReplay() (ReplayEstimate, error)
+}
+
+// adminKey identifies an exporter on the admin endpoint: the exporter's
+// component ID, such as enhanced_dlq/a, and its signal.
+type adminKey struct {
+	exporter string
+	signal   string
+}
+
+// adminServer serves the DLQ admin endpoint. Every exporter configured with
+// the same endpoint, of any signal, shares one server.
+type adminServer struct {
+	logger *zap.Logger
+	server *http.Server
+
+	// Exporters by component ID and signal
+	targets map[adminKey]adminTarget
+	mutex   sync.Mutex
+}
+
+// Admin servers by endpoint.
+var (
+	adminServers      = make(map[string]*adminServer)
+	adminServersMutex sync.Mutex
+)
+
+// registerAdmin registers the exporter with a component ID and signal with
+// the admin server for the endpoint, starting the server for the first
+// exporter. The returned function unregisters the exporter, stopping the
+// server after the last one.
+func registerAdmin(endpoint string, logger *zap.Logger, exporter string, signal string, target adminTarget) (func(), error) {
+	adminServersMutex.Lock()
+	defer adminServersMutex.Unlock()
+
+	a, exists := adminServers[endpoint]
+	if !exists {
+		listener, err := net.Listen("tcp", endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", endpoint, err)
+		}
+
+		a = &adminServer{
+			logger:  logger,
+			targets: make(map[adminKey]adminTarget),
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/replay", a.handleReplay)
+		mux.HandleFunc("/replay/file", a.handleReplayFile)
+		mux.HandleFunc("/compact", a.handleCompact)
+		mux.HandleFunc("/replay/estimate", a.handleEstimateReplay)
+		a.server = &http.Server{Handler: mux}
+
+		go func() {
+			if err := a.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("DLQ admin endpoint stopped", zap.Error(err))
+			}
+		}()
+
+		adminServers[endpoint] = a
+		logger.Info("Serving DLQ admin endpoint", zap.String("endpoint", endpoint))
+	}
+
+	key := adminKey{exporter: exporter, signal: signal}
+	a.mutex.Lock()
+	if _, exists := a.targets[key]; exists {
+		a.mutex.Unlock()
+		return nil, fmt.Errorf("%s exporter %s is already registered on the admin endpoint %s", signal, exporter, endpoint)
+	}
+	a.targets[key] = target
+	a.mutex.Unlock()
+
+	return func() {
+		adminServersMutex.Lock()
+		defer adminServersMutex.Unlock()
+
+		a.mutex.Lock()
+		delete(a.targets, key)
+		remaining := len(a.targets)
+		a.mutex.Unlock()
+
+		if remaining == 0 {
+			delete(adminServers, endpoint)
+			if err := a.server.Close(); err != nil {
+				logger.Warn("Failed to stop DLQ admin endpoint", zap.Error(err))
+			}
+		}
+	}, nil
+}
+
+// target returns the exporter picked by the exporter and signal query
+// parameters of a request. Either can be left out as long as the other
+// narrows the exporters sharing the endpoint down to one.
+func (a *adminServer) target(r *http.Request) (adminTarget, error) {
+	exporter := r.URL.Query().Get("exporter")
+	signal := r.URL.Query().Get("signal")
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var matched []adminTarget
+	for key, target := range a.targets {
+		if (exporter == "" || key.exporter == exporter) && (signal == "" || key.signal == signal) {
+			matched = append(matched, target)
+		}
+	}
+
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("no DLQ exporter matches exporter %q and signal %q", exporter, signal)
+	case 1:
+		return matched[0], nil
+	}
+	return nil, fmt.Errorf("exporter and signal are required when several exporters share the endpoint")
+}
+
+// replayContext returns the context a replay requested by r runs with. The
+// replay outlives the request, and is a shadow replay with shadow=true.
+func replayContext(r *http.Request) context.Context {
+	ctx := context.Background()
+	if r.URL.Query().Get("shadow") == "true" {
+		ctx = ContextWithShadowReplay(ctx)
+	}
+	return ctx
+}
+
+// handleReplay starts replaying the DLQ of the exporter picked by the
+// exporter and signal query parameters, as a shadow replay with shadow=true.
+func (a *adminServer) handleReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	target, err := a.target(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := target.StartReplay(replayContext(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	a.logger.Info("DLQ replay requested")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleReplayFile starts replaying the DLQ file named by the file query
+// parameter, relative to the DLQ directory. The exporter and signal
+// parameters pick the exporter when several share the endpoint, and
+// shadow=true makes it a shadow replay.
+func (a *adminServer) handleReplayFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	file := r.URL.Query().Get("file")
+	if file == "" {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+
+	target, err := a.target(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := target.ReplayFile(replayContext(r), file); err != nil {
+		statusCode := http.StatusConflict
+		if errors.Is(err, errNotDLQFile) {
+			statusCode = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), statusCode)
+		return
+	}
+
+	a.logger.Info("DLQ file replay requested", zap.String("file", file))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleEstimateReplay answers with the estimate of the next replay of the
+// exporter picked by the exporter and signal query parameters, as JSON.
+func (a *adminServer) handleEstimateReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	target, err := a.target(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	estimate, err := target.EstimateReplay()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(estimate); err != nil {
+		a.logger.Warn("Failed to write replay estimate", zap.Error(err))
+	}
+}
+
+// handleCompact compacts the DLQ files of the exporter picked by the
+// exporter and signal query parameters, answering once compaction has
+// finished.
+func (a *adminServer) handleCompact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	target, err := a.target(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := target.Compact(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
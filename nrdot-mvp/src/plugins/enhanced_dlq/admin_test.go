@@ -0,0 +1,52 @@
+package enhanceddlq
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+// namedTarget is an admin target that only tells exporters apart.
+type namedTarget struct {
+	name string
+}
+
+func (n *namedTarget) StartReplay(context.Context) error { return nil }
+
+func (n *namedTarget) ReplayFile(context.Context, string) error { return nil }
+
+func (n *namedTarget) Compact() error { return nil }
+
+func (n *namedTarget) EstimateReplay() (ReplayEstimate, error) { return ReplayEstimate{}, nil }
+
+func TestAdminTargetsKeyedByExporter(t *testing.T) {
+	a := &adminServer{
+		targets: map[adminKey]adminTarget{
+			{exporter: "enhanced_dlq/a", signal: "metrics"}: &namedTarget{name: "a metrics"},
+			{exporter: "enhanced_dlq/b", signal: "metrics"}: &namedTarget{name: "b metrics"},
+			{exporter: "enhanced_dlq/b", signal: "logs"}:    &namedTarget{name: "b logs"},
+		},
+	}
+
+	for query, want := range map[string]string{
+		"exporter=enhanced_dlq/a":                "a metrics",
+		"exporter=enhanced_dlq/b&signal=metrics": "b metrics",
+		"signal=logs":                            "b logs",
+	} {
+		target, err := a.target(httptest.NewRequest("POST", "/compact?"+query, nil))
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+		if got := target.(*namedTarget).name; got != want {
+			t.Errorf("%s: expected %s, got %s", query, want, got)
+		}
+	}
+
+	// Ambiguous or unknown exporters are refused
+	for _, query := range []string{"", "signal=metrics", "exporter=enhanced_dlq/b", "exporter=enhanced_dlq/c"} {
+		if _, err := a.target(httptest.NewRequest("POST", "/compact?"+query, nil)); err == nil {
+			t.Errorf("%q: expected an error", query)
+		}
+	}
+}
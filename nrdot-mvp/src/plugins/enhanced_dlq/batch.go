@@ -0,0 +1,192 @@
+package enhanceddlq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Sync policies for DLQ writes.
+const (
+	SyncPolicyAlways   = "always"
+	SyncPolicyInterval = "interval"
+)
+
+// errStorageStopped is returned by writes that wait for room in the batch
+// after the storage has been shut down.
+var errStorageStopped = errors.New("DLQ storage is shut down")
+
+// writeBatch accumulates records written while SyncPolicy is "interval" so
+// they can be written and synced together.
+type writeBatch struct {
+	maxRecords int
+	maxBytes   int
+	interval   time.Duration
+
+	records []fallbackRecord
+	bytes   int
+	mutex   sync.Mutex
+
+	// Closed and replaced whenever the batch writer takes the batch, waking
+	// the writers waiting for room
+	room chan struct{}
+
+	// Signals the batch writer that the batch is full
+	flushCh chan struct{}
+
+	// Closed once the storage is shut down
+	stopped <-chan struct{}
+}
+
+// newWriteBatch creates a write batch from the configuration. Writers stop
+// waiting for room once stopped is closed.
+func newWriteBatch(config *Config, stopped <-chan struct{}) *writeBatch {
+	return &writeBatch{
+		maxRecords: config.MaxBatchRecords,
+		maxBytes:   config.MaxBatchBytes,
+		interval:   time.Duration(config.FlushIntervalMs) * time.Millisecond,
+		room:       make(chan struct{}),
+		flushCh:    make(chan struct{}, 1),
+		stopped:    stopped,
+	}
+}
+
+// add appends a record to the batch, signaling the batch writer once the
+// batch reaches its record or byte limit. While the batch holds max_batch_bytes
+// or more, for example because its records keep failing to write, add waits
+// for the batch writer to take it. It returns an error, without adding the
+// record, if ctx is done or the storage shut down first.
+func (b *writeBatch) add(ctx context.Context, data []byte, priority string) error {
+	b.mutex.Lock()
+	for b.bytes >= b.maxBytes {
+		room := b.room
+		b.mutex.Unlock()
+
+		b.signalFlush()
+		select {
+		case <-room:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.stopped:
+			return errStorageStopped
+		}
+
+		b.mutex.Lock()
+	}
+	b.records = append(b.records, fallbackRecord{data: data, priority: priority})
+	b.bytes += len(data)
+	full := len(b.records) >= b.maxRecords || b.bytes >= b.maxBytes
+	b.mutex.Unlock()
+
+	if full {
+		b.signalFlush()
+	}
+	return nil
+}
+
+// signalFlush signals the batch writer to write the batch without waiting for
+// the flush interval.
+func (b *writeBatch) signalFlush() {
+	select {
+	case b.flushCh <- struct{}{}:
+	default:
+	}
+}
+
+// take removes and returns the pending records, waking the writers waiting
+// for room.
+func (b *writeBatch) take() []fallbackRecord {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	records := b.records
+	b.records = nil
+	b.bytes = 0
+	close(b.room)
+	b.room = make(chan struct{})
+	return records
+}
+
+// pendingBytes returns the bytes waiting in the batch.
+func (b *writeBatch) pendingBytes() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.bytes
+}
+
+// putBack returns records that failed to write to the front of the batch so
+// they are retried on the next flush. None are dropped: once they fill
+// max_batch_bytes, add holds new records back until they are written or
+// handed to the fallback.
+func (b *writeBatch) putBack(records []fallbackRecord) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, record := range records {
+		b.bytes += len(record.data)
+	}
+	b.records = append(records, b.records...)
+}
+
+// batchLoop writes the pending batch every flush interval, or sooner when
+// the batch fills up.
+func (s *DLQStorage) batchLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.batch.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.flushBatch()
+		case <-s.batch.flushCh:
+			s.flushBatch()
+		}
+	}
+}
+
+// flushBatch writes the pending batch with a single write and sync. Records
+// that fail to write are kept for the next flush, or handed to the fallback
+// once the failures engage it.
+func (s *DLQStorage) flushBatch() {
+	records := s.batch.take()
+	if len(records) == 0 {
+		return
+	}
+
+	if s.fallback.IsActive() {
+		for _, record := range records {
+			s.fallback.Store(record.data, record.priority)
+		}
+		return
+	}
+
+	if err := s.writeRecords(records); err != nil {
+		if !s.fallback.RecordFailure() {
+			s.logger.Warn("Failed to write DLQ batch, retrying on next flush",
+				zap.Error(err),
+				zap.Int("records", len(records)),
+			)
+			s.batch.putBack(records)
+			return
+		}
+
+		s.logger.Error("DLQ directory is unwritable, engaging fallback",
+			zap.Error(err),
+			zap.String("directory", s.config.Directory),
+			zap.String("fallbackMode", s.config.FallbackMode),
+			zap.Int("retryIntervalSec", s.config.WriteRetryIntervalSec),
+		)
+		s.closeCurrentFile()
+		for _, record := range records {
+			s.fallback.Store(record.data, record.priority)
+		}
+		return
+	}
+
+	s.fallback.RecordSuccess()
+}
@@ -0,0 +1,90 @@
+package enhanceddlq
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBatchKeepsRecordsThroughWriteFailures(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.SyncPolicy = SyncPolicyInterval
+		config.FlushIntervalMs = 10
+		config.MaxBatchRecords = 100
+		config.MaxBatchBytes = 40
+		config.WriteFailureThreshold = 1000
+	})
+
+	storage.closeCurrentFile()
+	restore := makeUnwritable(t, storage.config.Directory)
+
+	// Far more than max_batch_bytes is written while every flush fails
+	written := make(chan error, 1)
+	go func() {
+		for i := 0; i < 20; i++ {
+			if err := storage.Write(context.Background(), []byte(fmt.Sprintf("record-%02d", i))); err != nil {
+				written <- err
+				return
+			}
+		}
+		written <- nil
+	}()
+
+	waitFor(t, "failed batch writes", func() bool {
+		return storage.fallback.Stats().WriteFailures >= 3
+	})
+
+	// Writes wait for room rather than growing the batch or dropping records
+	select {
+	case err := <-written:
+		restore()
+		t.Fatalf("expected writes to wait while the batch can't be written, got %v", err)
+	default:
+	}
+	if pending := storage.batch.pendingBytes(); pending > 2*40+10 {
+		restore()
+		t.Fatalf("expected the batch to stay bounded, got %d bytes pending", pending)
+	}
+
+	// Once the directory is writable again, every record reaches the disk
+	restore()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the writes to finish")
+	}
+	if err := storage.Shutdown(); err != nil {
+		t.Fatalf("failed to shut down storage: %v", err)
+	}
+
+	records := readAllRecords(t, storage)
+	if len(records) != 20 {
+		t.Fatalf("expected all 20 records on disk, got %d", len(records))
+	}
+	for i, record := range records {
+		if want := fmt.Sprintf("record-%02d", i); string(record.Data) != want {
+			t.Fatalf("expected %s at position %d, got %s", want, i, record.Data)
+		}
+	}
+}
+
+func TestBatchWriteStopsWaitingWhenContextIsDone(t *testing.T) {
+	batch := newWriteBatch(&Config{MaxBatchRecords: 10, MaxBatchBytes: 10, FlushIntervalMs: 200}, nil)
+	if err := batch.add(context.Background(), make([]byte, 10), "normal"); err != nil {
+		t.Fatalf("failed to add record: %v", err)
+	}
+
+	// The batch is full and nothing takes it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := batch.add(ctx, make([]byte, 10), "normal"); err != context.Canceled {
+		t.Fatalf("expected the write to give up with the context, got %v", err)
+	}
+	if pending := batch.pendingBytes(); pending != 10 {
+		t.Fatalf("expected the refused record to stay out of the batch, got %d bytes pending", pending)
+	}
+}
@@ -0,0 +1,68 @@
+package enhanceddlq
+
+// ReplayLimit bounds the records processed by a single replay run. A zero
+// field means that dimension is unlimited.
+type ReplayLimit struct {
+	// Records is the maximum number of records replayed
+	Records int64
+
+	// Bytes is the maximum amount of record data replayed. The record that
+	// reaches the limit is replayed in full.
+	Bytes int64
+}
+
+// replayCheckpoint is the position a limited replay stopped at, so the next
+// replay resumes from there rather than from the start of the DLQ.
+type replayCheckpoint struct {
+	// Index of the priority pass the replay stopped in
+	pass int
+
+	// DLQ file and byte offset of the next record to read
+	file   string
+	offset int64
+}
+
+// replayItem is a record queued for the replay workers, with the position
+// it was read from so a stopped replay can resume from the first record no
+// worker consumed.
+type replayItem struct {
+	record   *DLQRecord
+	position replayCheckpoint
+}
+
+// replayBudget tracks the records and bytes dispatched by a replay run
+// against its limit.
+type replayBudget struct {
+	limit   ReplayLimit
+	records int64
+	bytes   int64
+}
+
+// consume records a dispatched record of the given size.
+func (b *replayBudget) consume(size int) {
+	b.records++
+	b.bytes += int64(size)
+}
+
+// exhausted returns whether the run has reached either limit.
+func (b *replayBudget) exhausted() bool {
+	if b.limit.Records > 0 && b.records >= b.limit.Records {
+		return true
+	}
+	return b.limit.Bytes > 0 && b.bytes >= b.limit.Bytes
+}
+
+// skip returns whether a replay resuming from the checkpoint skips the
+// file in the pass, and the offset to start reading the file at otherwise.
+func (c *replayCheckpoint) skip(pass int, file string) (bool, int64) {
+	if c == nil || pass > c.pass {
+		return false, 0
+	}
+	if pass < c.pass || dlqFileLess(file, c.file) {
+		return true, 0
+	}
+	if file == c.file {
+		return false, c.offset
+	}
+	return false, 0
+}
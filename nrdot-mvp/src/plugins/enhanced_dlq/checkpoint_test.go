@@ -0,0 +1,202 @@
+package enhanceddlq
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// writeReplayRecords writes records named record-0 to record-(n-1) and
+// rotates, so they can be replayed.
+func writeReplayRecords(t *testing.T, storage *DLQStorage, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := storage.Write(context.Background(), []byte(fmt.Sprintf("record-%d", i))); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+	rotate(t, storage)
+}
+
+// waitFor polls until condition holds, failing the test after 5 seconds.
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// received returns a copy of the records a collector has received.
+func (c *recordCollector) received() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string(nil), c.data...)
+}
+
+// expectEachOnce fails the test unless got holds record-0 to record-(n-1)
+// exactly once each.
+func expectEachOnce(t *testing.T, got []string, n int) {
+	t.Helper()
+	seen := make(map[string]int, len(got))
+	for _, data := range got {
+		seen[data]++
+	}
+	for i := 0; i < n; i++ {
+		if count := seen[fmt.Sprintf("record-%d", i)]; count != 1 {
+			t.Fatalf("expected record-%d to be delivered once, got %d deliveries in %v", i, count, got)
+		}
+	}
+	if len(got) != n {
+		t.Fatalf("expected %d records, got %v", n, got)
+	}
+}
+
+func TestReplayLimitResumesFromCheckpoint(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.ReplayConcurrency = 1
+	})
+	storage.SetClock(clock.Real())
+	writeReplayRecords(t, storage, 10)
+
+	replay := func(limit ReplayLimit) []string {
+		t.Helper()
+		collector := &recordCollector{}
+		if err := storage.StartReplay(context.Background(), collector, limit); err != nil {
+			t.Fatalf("failed to start replay: %v", err)
+		}
+		waitFor(t, "the replay to finish", func() bool { return !storage.IsReplayActive() })
+		return collector.received()
+	}
+	expect := func(got []string, first, last int) {
+		t.Helper()
+		if len(got) != last-first+1 {
+			t.Fatalf("expected record-%d to record-%d, got %v", first, last, got)
+		}
+		for i, data := range got {
+			if data != fmt.Sprintf("record-%d", first+i) {
+				t.Fatalf("expected record-%d to record-%d, got %v", first, last, got)
+			}
+		}
+	}
+
+	// Each limited replay stops exactly at its limit, the next one resuming
+	// there
+	expect(replay(ReplayLimit{Records: 4}), 0, 3)
+	expect(replay(ReplayLimit{Records: 4}), 4, 7)
+
+	// The record reaching a byte limit is replayed in full
+	expect(replay(ReplayLimit{Bytes: int64(len("record-8")) + 1}), 8, 9)
+}
+
+func TestStopReplayWaitingForLiveTraffic(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.InterleaveRatio = 2
+	})
+	storage.SetClock(clock.Real())
+	writeReplayRecords(t, storage, 3)
+
+	// Without live traffic, the replay stalls after its share of 2 records
+	collector := &recordCollector{}
+	if err := storage.StartReplay(context.Background(), collector, ReplayLimit{}); err != nil {
+		t.Fatalf("failed to start replay: %v", err)
+	}
+	waitFor(t, "2 records to be replayed", func() bool { return len(collector.received()) == 2 })
+
+	stopped := make(chan struct{})
+	go func() {
+		storage.StopReplay()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("StopReplay didn't return")
+	}
+
+	// The next replay resumes at the record the stalled worker held
+	if err := storage.StartReplay(context.Background(), collector, ReplayLimit{}); err != nil {
+		t.Fatalf("failed to start replay: %v", err)
+	}
+	waitFor(t, "the replay to finish", func() bool { return !storage.IsReplayActive() })
+	expectEachOnce(t, collector.received(), 3)
+}
+
+// blockingConsumer collects records, except that it holds on to one record
+// until its context is cancelled.
+type blockingConsumer struct {
+	recordCollector
+	block   string
+	holding chan struct{}
+}
+
+func (c *blockingConsumer) ConsumeDLQRecord(ctx context.Context, record *DLQRecord) error {
+	if string(record.Data) == c.block {
+		close(c.holding)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return c.recordCollector.ConsumeDLQRecord(ctx, record)
+}
+
+// tick advances a fake clock a millisecond at a time until stopped, so rate
+// limiter waits on it pass.
+func tick(fake *clock.FakeClock) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(100 * time.Microsecond):
+				fake.Advance(time.Millisecond)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func TestReplayDeadlineCheckpointsHeldRecord(t *testing.T) {
+	storage, fake := newTestStorage(t, func(config *Config) {
+		config.InterleaveRatio = 1000
+		config.ReplayConcurrency = 1
+		config.MaxReplayDurationSec = 60
+	})
+	writeReplayRecords(t, storage, 3)
+
+	// The consumer never returns record-1 by itself
+	consumer := &blockingConsumer{block: "record-1", holding: make(chan struct{})}
+	if err := storage.StartReplay(context.Background(), consumer, ReplayLimit{}); err != nil {
+		t.Fatalf("failed to start replay: %v", err)
+	}
+	stopTicking := tick(fake)
+	select {
+	case <-consumer.holding:
+	case <-time.After(5 * time.Second):
+		t.Fatal("record-1 was never delivered")
+	}
+	stopTicking()
+
+	fake.Advance(60 * time.Second)
+	waitFor(t, "the replay to stop at the deadline", func() bool { return !storage.IsReplayActive() })
+	if got := consumer.received(); len(got) != 1 || got[0] != "record-0" {
+		t.Fatalf("expected only record-0 to be consumed, got %v", got)
+	}
+
+	// The next replay resumes at the record the consumer held
+	collector := &recordCollector{}
+	if err := storage.StartReplay(context.Background(), collector, ReplayLimit{}); err != nil {
+		t.Fatalf("failed to start replay: %v", err)
+	}
+	defer tick(fake)()
+	waitFor(t, "the replay to finish", func() bool { return !storage.IsReplayActive() })
+	if got := collector.received(); len(got) != 2 || got[0] != "record-1" || got[1] != "record-2" {
+		t.Fatalf("expected record-1 and record-2 to be replayed, got %v", got)
+	}
+}
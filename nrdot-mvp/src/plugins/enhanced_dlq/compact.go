@@ -0,0 +1,303 @@
+package enhanceddlq
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// compactingSuffix marks the temporary file a run of DLQ files is merged
+// into before it replaces the run's first file.
+const compactingSuffix = ".compacting"
+
+// compactGroup is a run of consecutive DLQ files merged into one by
+// compaction, with the size of each file.
+type compactGroup struct {
+	files []string
+	sizes []int64
+	size  int64
+}
+
+// Compact merges runs of consecutive small DLQ files into files of up to
+// FileSizeLimitMiB, so a DLQ that accumulated many small files from frequent
+// rotation or restarts lists and replays quickly. The records of each run
+// are copied byte for byte, in order, into the run's first file, which keeps
+// its name and so its place in the replay order, and the other files of the
+// run are removed. Only files this storage owns and whose records all parse
+// are merged, and the file being written is left alone. Compaction doesn't
+// run alongside a replay; the checkpoint of a stopped replay is moved to the
+// same record in the merged file.
+func (s *DLQStorage) Compact() error {
+	s.replayMutex.Lock()
+	if s.replayActive {
+		s.replayMutex.Unlock()
+		return fmt.Errorf("replay is active")
+	}
+	if s.compacting {
+		s.replayMutex.Unlock()
+		return fmt.Errorf("compaction is already running")
+	}
+	s.compacting = true
+	s.replayMutex.Unlock()
+
+	defer func() {
+		s.replayMutex.Lock()
+		s.compacting = false
+		s.replayMutex.Unlock()
+	}()
+
+	files, err := s.ListDLQFiles()
+	if err != nil {
+		return err
+	}
+
+	// Where each merged file's records now start
+	moved := make(map[string]replayCheckpoint)
+	merged, removed := 0, 0
+	for _, group := range s.compactGroups(files) {
+		offsets, err := s.mergeFiles(group)
+		if err != nil {
+			s.logger.Error("Failed to compact DLQ files",
+				zap.Error(err),
+				zap.String("file", group.files[0]),
+				zap.Int("fileCount", len(group.files)),
+			)
+			continue
+		}
+		for i, file := range group.files {
+			moved[file] = replayCheckpoint{file: group.files[0], offset: offsets[i]}
+		}
+		merged++
+		removed += len(group.files) - 1
+	}
+
+	s.replayMutex.Lock()
+	if c := s.replayCheckpoint; c != nil {
+		if m, ok := moved[c.file]; ok {
+			s.replayCheckpoint = &replayCheckpoint{pass: c.pass, file: m.file, offset: m.offset + c.offset}
+		}
+	}
+	s.replayMutex.Unlock()
+
+	if merged > 0 {
+		s.logger.Info("Compacted DLQ files",
+			zap.Int("mergedFiles", merged),
+			zap.Int("removedFiles", removed),
+			zap.Int("filesBefore", len(files)),
+		)
+	}
+	return nil
+}
+
+// compactGroups splits the DLQ files, oldest first, into runs of at least
+// two consecutive files that together fit within FileSizeLimitMiB. A file
+// that can't be merged ends the run before it, so merging never reorders
+// records.
+func (s *DLQStorage) compactGroups(files []string) []compactGroup {
+	limit := int64(s.config.FileSizeLimitMiB) * 1024 * 1024
+
+	// Read after listing, so a file created since is never in the list
+	s.currentFileMutex.Lock()
+	currentPath := s.currentFilePath
+	s.currentFileMutex.Unlock()
+
+	var groups []compactGroup
+	var group compactGroup
+	flush := func() {
+		if len(group.files) > 1 {
+			groups = append(groups, group)
+		}
+		group = compactGroup{}
+	}
+
+	for _, file := range files {
+		if file == currentPath || !s.ownsFile(file) {
+			flush()
+			continue
+		}
+
+		size, err := intactSize(file)
+		if err != nil {
+			s.logger.Warn("Leaving DLQ file out of compaction",
+				zap.Error(err),
+				zap.String("file", file),
+			)
+			flush()
+			continue
+		}
+		if size >= limit {
+			flush()
+			continue
+		}
+
+		if group.size+size > limit {
+			flush()
+		}
+		group.files = append(group.files, file)
+		group.sizes = append(group.sizes, size)
+		group.size += size
+	}
+	flush()
+
+	return groups
+}
+
+// intactSize returns the size of a DLQ file, or an error unless the file
+// consists entirely of complete records. A truncated record would swallow
+// the records appended after it, so such files are never merged.
+func intactSize(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	reader := bufio.NewReader(file)
+	for {
+		_, n, err := readStoredRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		size += n
+	}
+
+	if size != info.Size() {
+		return 0, fmt.Errorf("file has %d bytes after its last record", info.Size()-size)
+	}
+	return size, nil
+}
+
+// mergeFiles copies the files of a group, in order, into a temporary file
+// that then replaces the group's first file, and removes the other files.
+// The merged file keeps the newest modification time of the group, so no
+// record expires earlier than before; the older records of the group are
+// kept until the newest would expire. It returns the offset each file's
+// records start at in the merged file.
+//
+// The records are synced to disk before the first file is replaced, so a
+// crash leaves the records either in the original files or, if it comes
+// before the others are removed, duplicated in the merged file.
+func (s *DLQStorage) mergeFiles(group compactGroup) ([]int64, error) {
+	target := group.files[0]
+	tmpPath := target + compactingSuffix
+
+	out, err := s.openFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compacted file: %w", err)
+	}
+	abort := func(err error) ([]int64, error) {
+		s.closeFile(out)
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	offsets := make([]int64, len(group.files))
+	var offset int64
+	var modTime time.Time
+	for i, file := range group.files {
+		offsets[i] = offset
+
+		in, err := os.Open(file)
+		if err != nil {
+			return abort(fmt.Errorf("failed to open DLQ file: %w", err))
+		}
+		info, err := in.Stat()
+		if err == nil {
+			if info.ModTime().After(modTime) {
+				modTime = info.ModTime()
+			}
+			_, err = io.CopyN(out, in, group.sizes[i])
+		}
+		in.Close()
+		if err != nil {
+			return abort(fmt.Errorf("failed to copy DLQ file %s: %w", file, err))
+		}
+		offset += group.sizes[i]
+	}
+
+	if err := out.Sync(); err != nil {
+		return abort(fmt.Errorf("failed to sync compacted file: %w", err))
+	}
+	if err := s.closeFile(out); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to close compacted file: %w", err)
+	}
+	if err := os.Chtimes(tmpPath, modTime, modTime); err != nil {
+		s.logger.Warn("Failed to keep the modification time of compacted DLQ file", zap.Error(err))
+	}
+	if err := os.Rename(tmpPath, target); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to replace DLQ file with compacted file: %w", err)
+	}
+
+	for _, file := range group.files[1:] {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.logger.Warn("Failed to remove compacted DLQ file", zap.Error(err), zap.String("file", file))
+			continue
+		}
+		s.untrackFile(file)
+	}
+	return offsets, nil
+}
+
+// removeCompactionLeftovers removes the temporary files of compactions
+// interrupted by a crash. The originals are only replaced once a temporary
+// file is complete, so a leftover never holds the only copy of a record.
+func (s *DLQStorage) removeCompactionLeftovers() {
+	pattern := filepath.Join(s.config.Directory, s.filePrefix+"-*.dlq"+compactingSuffix)
+	files, err := filepath.Glob(pattern)
+	if err == nil && s.adoptUnsignaled {
+		var unsignaled []string
+		unsignaled, err = s.listUnsignaledFiles(compactingSuffix)
+		files = append(files, unsignaled...)
+	}
+	if err != nil {
+		s.logger.Warn("Failed to list leftover compaction files", zap.Error(err))
+		return
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.logger.Warn("Failed to remove leftover compaction file", zap.Error(err), zap.String("file", file))
+			continue
+		}
+		s.logger.Info("Removed leftover compaction file", zap.String("file", file))
+	}
+}
+
+// compactLoop compacts the DLQ files every CompactIntervalSec, skipping
+// runs that fall during a replay.
+func (s *DLQStorage) compactLoop(ctx context.Context) {
+	interval := time.Duration(s.config.CompactIntervalSec) * time.Second
+	for {
+		clk := s.getClock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-clk.After(interval):
+			if s.IsReplayActive() {
+				s.logger.Debug("Skipping DLQ compaction during replay")
+				continue
+			}
+			if err := s.Compact(); err != nil {
+				s.logger.Error("Failed to compact DLQ files", zap.Error(err))
+			}
+		}
+	}
+}
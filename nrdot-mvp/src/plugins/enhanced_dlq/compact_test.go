@@ -0,0 +1,91 @@
+package enhanceddlq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// rotate closes the storage's current file and starts a new one.
+func rotate(t *testing.T, storage *DLQStorage) {
+	t.Helper()
+
+	storage.currentFileMutex.Lock()
+	storage.currentFileSize = int64(storage.config.FileSizeLimitMiB) * 1024 * 1024
+	storage.currentFileMutex.Unlock()
+	if err := storage.rotateFileIfNeeded(); err != nil {
+		t.Fatalf("failed to rotate DLQ file: %v", err)
+	}
+}
+
+func TestCompactMergesFilesInOrder(t *testing.T) {
+	storage, _ := newTestStorage(t, nil)
+
+	// Four small files of three records each
+	var want []string
+	for file := 0; file < 4; file++ {
+		for i := 0; i < 3; i++ {
+			data := fmt.Sprintf("record-%d-%d", file, i)
+			if err := storage.Write(context.Background(), []byte(data)); err != nil {
+				t.Fatalf("failed to write record: %v", err)
+			}
+			want = append(want, data)
+		}
+		rotate(t, storage)
+	}
+
+	before, err := storage.ListDLQFiles()
+	if err != nil {
+		t.Fatalf("failed to list DLQ files: %v", err)
+	}
+
+	if err := storage.Compact(); err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+
+	after, err := storage.ListDLQFiles()
+	if err != nil {
+		t.Fatalf("failed to list DLQ files: %v", err)
+	}
+	// The four written files are merged into one, next to the current file
+	if len(after) != 2 || len(before) != 5 {
+		t.Fatalf("expected 5 files compacted into 2, got %d into %d", len(before), len(after))
+	}
+	if after[0] != before[0] {
+		t.Errorf("expected the merged file to keep the name %s, got %s", before[0], after[0])
+	}
+
+	records := readAllRecords(t, storage)
+	if len(records) != len(want) {
+		t.Fatalf("expected %d records after compaction, got %d", len(want), len(records))
+	}
+	for i, record := range records {
+		if string(record.Data) != want[i] {
+			t.Errorf("record %d: expected %q, got %q", i, want[i], record.Data)
+		}
+	}
+}
+
+func TestCompactionLeftoversRemovedAtStartup(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.Directory = t.TempDir()
+
+	leftover := filepath.Join(config.Directory, config.FilePrefix+"-metrics-20240101-000000.000.dlq"+compactingSuffix)
+	if err := os.WriteFile(leftover, []byte("partial"), 0644); err != nil {
+		t.Fatalf("failed to create leftover file: %v", err)
+	}
+
+	storage, err := NewDLQStorage(config, zap.NewNop(), "metrics")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Shutdown()
+
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Fatalf("expected the leftover compaction file to be removed, got %v", err)
+	}
+}
@@ -1,8 +1,10 @@
 package enhanceddlq
 
 import (
+	"fmt"
+	"net/url"
+	"path"
 	"path/filepath"
-	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
@@ -13,30 +15,203 @@ type Config struct {
 	// Directory is the path to store DLQ files
 	Directory string `mapstructure:"directory"`
 
+	// AllowedBaseDirectory, if set, is the directory Directory must resolve
+	// to or be under, after following symlinks
+	AllowedBaseDirectory string `mapstructure:"allowed_base_directory"`
+
 	// FileSizeLimitMiB is the maximum size of individual DLQ files in MiB
 	FileSizeLimitMiB int `mapstructure:"file_size_limit_mib"`
 
 	// VerifySHA256 enables SHA-256 verification for data integrity
 	VerifySHA256 bool `mapstructure:"verify_sha256"`
 
+	// VerifyTimestamps decodes each record after serializing it and rejects
+	// it unless every timestamp, including start, observed and exemplar
+	// timestamps, survived to the nanosecond
+	VerifyTimestamps bool `mapstructure:"verify_timestamps"`
+
+	// SerializationFormat is the encoding of new records, "protobuf" or
+	// "json". Each record's header names its format, so files holding records
+	// of both formats replay correctly.
+	// Default: "protobuf"
+	SerializationFormat string `mapstructure:"serialization_format"`
+
+	// RedactAttributes are the keys of resource, scope, data point, span,
+	// span event, span link and log record attributes redacted before data is
+	// written, so they never reach the disk. Keys may contain * and ?
+	// wildcards.
+	RedactAttributes []string `mapstructure:"redact_attributes"`
+
+	// RedactionMode is how redacted attributes are treated: "remove" drops
+	// them, "hash" replaces their value with its SHA-256 hash and "mask"
+	// replaces it with asterisks, keeping the first and last characters.
+	// Default: "remove"
+	RedactionMode string `mapstructure:"redaction_mode"`
+
+	// PartitionAttribute is a resource attribute, such as tenant.id, whose
+	// value selects a separate DLQ directory for the data, so each tenant is
+	// isolated on disk and can be replayed on its own. Empty disables it.
+	PartitionAttribute string `mapstructure:"partition_attribute"`
+
+	// MaxPartitions caps the number of partitions. Data for further values
+	// is written to the unpartitioned DLQ.
+	// Default: 32
+	MaxPartitions int `mapstructure:"max_partitions"`
+
 	// ReplayRateMiBSec is the maximum replay rate in MiB/s
 	ReplayRateMiBSec float64 `mapstructure:"replay_rate_mib_sec"`
 
+	// AdaptiveReplayRate starts each replay at ReplayMinRateMiBSec and ramps
+	// up towards ReplayRateMiBSec while the consumer succeeds, halving the
+	// rate whenever it returns errors
+	AdaptiveReplayRate bool `mapstructure:"adaptive_replay_rate"`
+
+	// ReplayMinRateMiBSec is the rate an adaptive replay starts at and never
+	// drops below, in MiB/s
+	ReplayMinRateMiBSec float64 `mapstructure:"replay_min_rate_mib_sec"`
+
 	// InterleaveRatio controls the ratio of replay:live traffic (1 means 1:1)
 	InterleaveRatio int `mapstructure:"interleave_ratio"`
 
+	// AdaptiveInterleave adapts the replay share to the live traffic rate:
+	// replay gets more than InterleaveRatio records per turn while live
+	// traffic is below InterleaveLiveRateHigh, and fewer while it is above.
+	// Whether or not it is enabled, replay doesn't wait for its turn while
+	// live traffic is idle.
+	AdaptiveInterleave bool `mapstructure:"adaptive_interleave"`
+
+	// InterleaveLiveRateHigh is the live rate, in batches per second, at which
+	// replay gets exactly InterleaveRatio records per turn.
+	// Default: 100
+	InterleaveLiveRateHigh float64 `mapstructure:"interleave_live_rate_high"`
+
 	// RetentionHours is the maximum retention period in hours
 	RetentionHours int `mapstructure:"retention_hours"`
 
-	// FilePrefix is the prefix for DLQ files
+	// MaxTotalSizeMiB caps the total size of the DLQ files. The oldest files
+	// are removed during cleanup until the DLQ fits. 0 means no cap.
+	MaxTotalSizeMiB int `mapstructure:"max_total_size_mib"`
+
+	// CompactIntervalSec is how often, in seconds, runs of small DLQ files
+	// are merged into files of up to FileSizeLimitMiB. 0 disables scheduled
+	// compaction.
+	CompactIntervalSec int `mapstructure:"compact_interval_sec"`
+
+	// FilePrefix is the prefix for DLQ files. The signal is appended, so
+	// files are named <prefix>-<signal>-<sequence>-<timestamp>.dlq
 	FilePrefix string `mapstructure:"file_prefix"`
 
+	// EnableMetrics, EnableTraces and EnableLogs select the signals the
+	// exporter accepts. Storage is only created for enabled signals.
+	EnableMetrics bool `mapstructure:"enable_metrics"`
+	EnableTraces  bool `mapstructure:"enable_traces"`
+	EnableLogs    bool `mapstructure:"enable_logs"`
+
+	// LegacyFilesSignal is the signal whose storage adopts the files written
+	// before the signal was part of the name, <prefix>-<timestamp>.dlq, so
+	// they are still replayed and expired. Empty leaves them alone.
+	LegacyFilesSignal string `mapstructure:"legacy_files_signal"`
+
 	// ReplayOnStart indicates whether to automatically replay DLQ on startup
 	ReplayOnStart bool `mapstructure:"replay_on_start"`
 
 	// ReplayConcurrency is the number of goroutines used for replay
 	ReplayConcurrency int `mapstructure:"replay_concurrency"`
 
+	// PreserveOrder delivers replayed records in timestamp order. With
+	// ReplayConcurrency above 1, that many files are read in parallel and
+	// records are delivered by a single worker.
+	PreserveOrder bool `mapstructure:"preserve_order"`
+
+	// ReorderBufferRecords is the number of records an ordered replay reads
+	// ahead to put them in timestamp order
+	ReorderBufferRecords int `mapstructure:"reorder_buffer_records"`
+
+	// MaxConcurrentReplays is the number of replays, across all exporters
+	// sharing the directory, that may run at once. Further replays wait for
+	// a running one to finish.
+	MaxConcurrentReplays int `mapstructure:"max_concurrent_replays"`
+
+	// ReplayPriorityOrder is the order in which priorities are replayed.
+	// Records with a priority not listed here are replayed last.
+	ReplayPriorityOrder []string `mapstructure:"replay_priority_order"`
+
+	// ReplayLimitRecords is the maximum number of records replayed per replay
+	// run, 0 for no limit. The next run resumes after the last record replayed.
+	ReplayLimitRecords int64 `mapstructure:"replay_limit_records"`
+
+	// ReplayLimitMiB is the maximum amount of data in MiB replayed per replay
+	// run, 0 for no limit
+	ReplayLimitMiB float64 `mapstructure:"replay_limit_mib"`
+
+	// MaxReplayDurationSec is how long, in seconds, a replay run may take
+	// before it is stopped and checkpointed, 0 for no limit
+	MaxReplayDurationSec int `mapstructure:"max_replay_duration_sec"`
+
+	// CaptureReplayFailures writes records the consumer rejects during replay
+	// to a separate failed file, so StartFailedReplay can retry only those.
+	CaptureReplayFailures bool `mapstructure:"capture_replay_failures"`
+
+	// ShadowReplay makes every replay read, verify and deserialize records
+	// without forwarding them, to check the DLQ replays cleanly before trusting
+	// it against the backend. Shadow replays don't move the replay checkpoint.
+	// A single replay is made a shadow replay with ContextWithShadowReplay.
+	ShadowReplay bool `mapstructure:"shadow_replay"`
+
+	// AdminEndpoint is the address of the HTTP admin endpoint used to replay
+	// a single DLQ file or compact the DLQ. Exporters of different signals
+	// may share it. Empty disables the endpoint.
+	AdminEndpoint string `mapstructure:"admin_endpoint"`
+
+	// WriteFailureThreshold is the number of consecutive write failures after
+	// which the DLQ directory is considered unwritable and the fallback engages
+	WriteFailureThreshold int `mapstructure:"write_failure_threshold"`
+
+	// FallbackMode defines what happens to data while the DLQ directory is unwritable.
+	// Options: "drop", "memory"
+	FallbackMode string `mapstructure:"fallback_mode"`
+
+	// FallbackMemoryLimitMiB is the maximum amount of data buffered in memory
+	// when FallbackMode is "memory"
+	FallbackMemoryLimitMiB int `mapstructure:"fallback_memory_limit_mib"`
+
+	// WriteRetryIntervalSec is how often the DLQ directory is retried while the
+	// fallback is engaged
+	WriteRetryIntervalSec int `mapstructure:"write_retry_interval_sec"`
+
+	// SyncPolicy defines when DLQ writes are synced to disk.
+	// Options: "always" (every record), "interval" (records are batched and
+	// written and synced together by a background writer)
+	SyncPolicy string `mapstructure:"sync_policy"`
+
+	// FlushIntervalMs is how often the batch is written when SyncPolicy is "interval"
+	FlushIntervalMs int `mapstructure:"flush_interval_ms"`
+
+	// MaxBatchRecords is the number of records that triggers an early batch write
+	MaxBatchRecords int `mapstructure:"max_batch_records"`
+
+	// MaxBatchBytes is the amount of record data that triggers an early batch write
+	MaxBatchBytes int `mapstructure:"max_batch_bytes"`
+
+	// MemoryBufferMiB is the size of an in-memory buffer that absorbs write
+	// bursts faster than the disk and is drained to disk in the background.
+	// When it is full the oldest records are dropped. 0 disables the buffer.
+	// Cannot be combined with SyncPolicy "interval".
+	MemoryBufferMiB int `mapstructure:"memory_buffer_mib"`
+
+	// DedupWindowSec is how long, in seconds, the hash of each written record
+	// is remembered so identical records written again are stored only once.
+	// 0 disables deduplication.
+	DedupWindowSec int `mapstructure:"dedup_window_sec"`
+
+	// DedupMaxEntries caps the number of hashes remembered for deduplication.
+	// Default: 10000
+	DedupMaxEntries int `mapstructure:"dedup_max_entries"`
+
+	// Upstream is the OTLP/HTTP endpoint data is exported to first. Data is
+	// only written to the DLQ when the export fails.
+	Upstream UpstreamConfig `mapstructure:"upstream"`
+
 	// Common exporter settings
 	exporterhelper.TimeoutSettings `mapstructure:",squash"`
 	exporterhelper.QueueSettings   `mapstructure:"sending_queue"`
@@ -49,46 +224,229 @@ func (cfg *Config) Validate() error {
 	if cfg.Directory == "" {
 		cfg.Directory = "/var/lib/otel/dlq"
 	}
-	
+
 	// Convert to absolute path
 	absPath, err := filepath.Abs(cfg.Directory)
 	if err == nil {
 		cfg.Directory = absPath
 	}
 
+	// Refuse directories where retention could reach unrelated files
+	if err := validateDirectory(cfg.Directory, cfg.AllowedBaseDirectory); err != nil {
+		return err
+	}
+
 	// Validate FileSizeLimitMiB
 	if cfg.FileSizeLimitMiB <= 0 {
 		cfg.FileSizeLimitMiB = 100
 	}
 
+	// Validate MaxPartitions
+	if cfg.MaxPartitions <= 0 {
+		cfg.MaxPartitions = 32
+	}
+
 	// Validate ReplayRateMiBSec
 	if cfg.ReplayRateMiBSec <= 0 {
 		cfg.ReplayRateMiBSec = 4
 	}
 
+	// Validate ReplayMinRateMiBSec, which only matters with the adaptive rate
+	if cfg.AdaptiveReplayRate {
+		if cfg.ReplayMinRateMiBSec <= 0 {
+			cfg.ReplayMinRateMiBSec = cfg.ReplayRateMiBSec / 16
+		} else if cfg.ReplayMinRateMiBSec > cfg.ReplayRateMiBSec {
+			return fmt.Errorf("replay_min_rate_mib_sec must not exceed replay_rate_mib_sec")
+		}
+	}
+
 	// Validate InterleaveRatio
 	if cfg.InterleaveRatio <= 0 {
 		cfg.InterleaveRatio = 1
 	}
+	if cfg.InterleaveLiveRateHigh < 0 {
+		return fmt.Errorf("interleave_live_rate_high must not be negative")
+	}
+	if cfg.InterleaveLiveRateHigh == 0 {
+		cfg.InterleaveLiveRateHigh = 100
+	}
 
 	// Validate RetentionHours
 	if cfg.RetentionHours <= 0 {
 		cfg.RetentionHours = 72
 	}
 
+	// Validate MaxTotalSizeMiB
+	if cfg.MaxTotalSizeMiB < 0 {
+		return fmt.Errorf("max_total_size_mib must not be negative")
+	}
+
+	// Validate signals
+	if !cfg.EnableMetrics && !cfg.EnableTraces && !cfg.EnableLogs {
+		return fmt.Errorf("at least one of enable_metrics, enable_traces and enable_logs must be true")
+	}
+
 	// Validate FilePrefix
 	if cfg.FilePrefix == "" {
 		cfg.FilePrefix = "otel-dlq"
 	}
 
+	// Validate LegacyFilesSignal
+	switch cfg.LegacyFilesSignal {
+	case "", "metrics", "traces", "logs":
+	default:
+		return fmt.Errorf("invalid legacy_files_signal '%s', must be 'metrics', 'traces', 'logs' or empty", cfg.LegacyFilesSignal)
+	}
+
 	// Validate ReplayConcurrency
 	if cfg.ReplayConcurrency <= 0 {
 		cfg.ReplayConcurrency = 1
 	}
 
+	// Validate ReorderBufferRecords
+	if cfg.ReorderBufferRecords <= 0 {
+		cfg.ReorderBufferRecords = 1000
+	}
+
+	// Validate ReplayPriorityOrder
+	if len(cfg.ReplayPriorityOrder) == 0 {
+		cfg.ReplayPriorityOrder = []string{"critical", "high", "normal"}
+	}
+
+	// Validate WriteFailureThreshold
+	if cfg.WriteFailureThreshold <= 0 {
+		cfg.WriteFailureThreshold = 3
+	}
+
+	// Validate SerializationFormat
+	if cfg.SerializationFormat == "" {
+		cfg.SerializationFormat = SerializationFormatProtobuf
+	} else if cfg.SerializationFormat != SerializationFormatProtobuf && cfg.SerializationFormat != SerializationFormatJSON {
+		return fmt.Errorf("invalid serialization_format '%s', must be '%s' or '%s'",
+			cfg.SerializationFormat, SerializationFormatProtobuf, SerializationFormatJSON)
+	}
+
+	// Validate RedactAttributes and RedactionMode
+	for _, pattern := range cfg.RedactAttributes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid redact_attributes pattern '%s': %w", pattern, err)
+		}
+	}
+	switch cfg.RedactionMode {
+	case "":
+		cfg.RedactionMode = RedactionModeRemove
+	case RedactionModeRemove, RedactionModeHash, RedactionModeMask:
+	default:
+		return fmt.Errorf("invalid redaction_mode '%s', must be '%s', '%s' or '%s'",
+			cfg.RedactionMode, RedactionModeRemove, RedactionModeHash, RedactionModeMask)
+	}
+
+	// Validate FallbackMode
+	if cfg.FallbackMode == "" {
+		cfg.FallbackMode = FallbackModeDrop
+	} else if cfg.FallbackMode != FallbackModeDrop && cfg.FallbackMode != FallbackModeMemory {
+		return fmt.Errorf("invalid fallback_mode '%s', must be '%s' or '%s'",
+			cfg.FallbackMode, FallbackModeDrop, FallbackModeMemory)
+	}
+
+	// Validate MaxConcurrentReplays
+	if cfg.MaxConcurrentReplays <= 0 {
+		cfg.MaxConcurrentReplays = 1
+	}
+
+	// Validate replay limits
+	if cfg.ReplayLimitRecords < 0 {
+		return fmt.Errorf("replay_limit_records must not be negative")
+	}
+	if cfg.ReplayLimitMiB < 0 {
+		return fmt.Errorf("replay_limit_mib must not be negative")
+	}
+	if cfg.MaxReplayDurationSec < 0 {
+		return fmt.Errorf("max_replay_duration_sec must not be negative")
+	}
+
+	// Validate CompactIntervalSec
+	if cfg.CompactIntervalSec < 0 {
+		return fmt.Errorf("compact_interval_sec must not be negative")
+	}
+
+	// Validate FallbackMemoryLimitMiB
+	if cfg.FallbackMemoryLimitMiB <= 0 {
+		cfg.FallbackMemoryLimitMiB = 64
+	}
+
+	// Validate WriteRetryIntervalSec
+	if cfg.WriteRetryIntervalSec <= 0 {
+		cfg.WriteRetryIntervalSec = 30
+	}
+
+	// Validate SyncPolicy
+	if cfg.SyncPolicy == "" {
+		cfg.SyncPolicy = SyncPolicyAlways
+	} else if cfg.SyncPolicy != SyncPolicyAlways && cfg.SyncPolicy != SyncPolicyInterval {
+		return fmt.Errorf("invalid sync_policy '%s', must be '%s' or '%s'",
+			cfg.SyncPolicy, SyncPolicyAlways, SyncPolicyInterval)
+	}
+
+	// Validate FlushIntervalMs
+	if cfg.FlushIntervalMs <= 0 {
+		cfg.FlushIntervalMs = 200
+	}
+
+	// Validate MaxBatchRecords
+	if cfg.MaxBatchRecords <= 0 {
+		cfg.MaxBatchRecords = 256
+	}
+
+	// Validate MaxBatchBytes
+	if cfg.MaxBatchBytes <= 0 {
+		cfg.MaxBatchBytes = 4 * 1024 * 1024
+	}
+
+	// Validate MemoryBufferMiB
+	if cfg.MemoryBufferMiB < 0 {
+		return fmt.Errorf("memory_buffer_mib must not be negative")
+	}
+	if cfg.MemoryBufferMiB > 0 && cfg.SyncPolicy == SyncPolicyInterval {
+		return fmt.Errorf("memory_buffer_mib cannot be combined with sync_policy '%s'", SyncPolicyInterval)
+	}
+
+	// Validate write deduplication
+	if cfg.DedupWindowSec < 0 {
+		return fmt.Errorf("dedup_window_sec must not be negative")
+	}
+	if cfg.DedupMaxEntries <= 0 {
+		cfg.DedupMaxEntries = 10000
+	}
+
+	// Validate Upstream
+	if cfg.Upstream.Endpoint != "" {
+		u, err := url.Parse(cfg.Upstream.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid upstream endpoint '%s', must be an http or https URL", cfg.Upstream.Endpoint)
+		}
+	}
+	if cfg.Upstream.TimeoutMs <= 0 {
+		cfg.Upstream.TimeoutMs = 5000
+	}
+
+	// Replayed records are sent to the upstream, so a replay at startup
+	// needs one unless it only verifies the records
+	if cfg.ReplayOnStart && cfg.Upstream.Endpoint == "" && !cfg.ShadowReplay {
+		return fmt.Errorf("replay_on_start requires an upstream endpoint to replay to")
+	}
+
 	return nil
 }
 
+// replayLimit returns the limit applied to each replay run.
+func (cfg *Config) replayLimit() ReplayLimit {
+	return ReplayLimit{
+		Records: cfg.ReplayLimitRecords,
+		Bytes:   int64(cfg.ReplayLimitMiB * 1024 * 1024),
+	}
+}
+
 // CreateDefaultConfig creates the default configuration for the exporter.
 func CreateDefaultConfig() component.Config {
 	return &Config{
@@ -99,10 +457,33 @@ func CreateDefaultConfig() component.Config {
 		InterleaveRatio:   1,
 		RetentionHours:    72,
 		FilePrefix:        "otel-dlq",
+		EnableMetrics:     true,
+		EnableTraces:      true,
+		EnableLogs:        true,
+		LegacyFilesSignal: "metrics",
 		ReplayOnStart:     false,
 		ReplayConcurrency: 1,
 		TimeoutSettings:   exporterhelper.NewDefaultTimeoutSettings(),
 		QueueSettings:     exporterhelper.NewDefaultQueueSettings(),
 		RetrySettings:     exporterhelper.NewDefaultRetrySettings(),
+
+		SerializationFormat:    SerializationFormatProtobuf,
+		RedactionMode:          RedactionModeRemove,
+		MaxPartitions:          32,
+		ReplayMinRateMiBSec:    0.25,
+		ReplayPriorityOrder:    []string{"critical", "high", "normal"},
+		MaxConcurrentReplays:   1,
+		ReorderBufferRecords:   1000,
+		WriteFailureThreshold:  3,
+		FallbackMode:           FallbackModeDrop,
+		FallbackMemoryLimitMiB: 64,
+		WriteRetryIntervalSec:  30,
+		SyncPolicy:             SyncPolicyAlways,
+		FlushIntervalMs:        200,
+		MaxBatchRecords:        256,
+		MaxBatchBytes:          4 * 1024 * 1024,
+		DedupMaxEntries:        10000,
+		InterleaveLiveRateHigh: 100,
+		Upstream:               UpstreamConfig{TimeoutMs: 5000},
 	}
 }
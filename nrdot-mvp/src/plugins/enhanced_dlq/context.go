@@ -0,0 +1,84 @@
+package enhanceddlq
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/client"
+)
+
+// ReplayHeader is the HTTP header set on data replayed to the upstream
+// endpoint. A collector receiving it with include_metadata set on its OTLP
+// receiver, and the header listed in the batch processor's metadata_keys,
+// sees the data as replayed too.
+const ReplayHeader = "X-Nrdot-Replay"
+
+// priorityContextKey is the context key for the priority of data written to the DLQ.
+type priorityContextKey struct{}
+
+// signalContextKey is the context key for the signal type of data written to the DLQ.
+type signalContextKey struct{}
+
+// replayContextKey is the context key marking data replayed from the DLQ.
+type replayContextKey struct{}
+
+// shadowReplayContextKey is the context key marking a replay as a shadow replay.
+type shadowReplayContextKey struct{}
+
+// ContextWithPriority returns a context carrying the priority of the data being
+// exported. Callers spilling prioritized data to the DLQ, such as the
+// adaptive_priority_queue overflow path, use this so replay can process higher
+// priorities first.
+func ContextWithPriority(ctx context.Context, priority string) context.Context {
+	return context.WithValue(ctx, priorityContextKey{}, priority)
+}
+
+// PriorityFromContext returns the priority carried by the context, or an empty
+// string if none was set.
+func PriorityFromContext(ctx context.Context) string {
+	priority, _ := ctx.Value(priorityContextKey{}).(string)
+	return priority
+}
+
+// ContextWithReplay returns a context marking the data passed with it as
+// replayed from the DLQ, so downstream components such as the
+// adaptive_priority_queue can serve live data ahead of it.
+func ContextWithReplay(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replayContextKey{}, true)
+}
+
+// IsReplay returns whether the context marks data replayed from the DLQ,
+// either in process or through the ReplayHeader of the request the data was
+// received with.
+func IsReplay(ctx context.Context) bool {
+	if replay, _ := ctx.Value(replayContextKey{}).(bool); replay {
+		return true
+	}
+	return len(client.FromContext(ctx).Metadata.Get(ReplayHeader)) > 0
+}
+
+// ContextWithShadowReplay returns a context that makes a replay started with
+// it a shadow replay, whatever shadow_replay is set to, so the DLQ can be
+// verified and then replayed for real without reconfiguring the collector.
+func ContextWithShadowReplay(ctx context.Context) context.Context {
+	return context.WithValue(ctx, shadowReplayContextKey{}, true)
+}
+
+// isShadowReplay returns whether a replay started with the context is a
+// shadow replay, either because shadow_replay is set or because the context
+// asks for one.
+func isShadowReplay(ctx context.Context, config *Config) bool {
+	shadow, _ := ctx.Value(shadowReplayContextKey{}).(bool)
+	return config.ShadowReplay || shadow
+}
+
+// contextWithSignal returns a context carrying the signal type being written,
+// used to attribute storage problems to a signal.
+func contextWithSignal(ctx context.Context, signal string) context.Context {
+	return context.WithValue(ctx, signalContextKey{}, signal)
+}
+
+// signalFromContext returns the signal type carried by the context.
+func signalFromContext(ctx context.Context) string {
+	signal, _ := ctx.Value(signalContextKey{}).(string)
+	return signal
+}
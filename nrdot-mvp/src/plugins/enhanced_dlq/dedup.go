@@ -0,0 +1,88 @@
+package enhanceddlq
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// writeDedup remembers the SHA-256 hashes of recently written records for a
+// window, so a batch written again, such as by an upstream retry while the
+// backend flaps, is stored only once. It holds at most capacity hashes,
+// forgetting the oldest first. A nil dedup remembers nothing.
+type writeDedup struct {
+	capacity int
+	window   time.Duration
+	order    *list.List
+	entries  map[[sha256.Size]byte]*list.Element
+	mutex    sync.Mutex
+}
+
+// dedupEntry is a remembered hash and when it was first written.
+type dedupEntry struct {
+	hash      [sha256.Size]byte
+	writtenAt time.Time
+}
+
+// newWriteDedup creates a write dedup from the configuration, or returns nil
+// if it is disabled.
+func newWriteDedup(config *Config) *writeDedup {
+	if config.DedupWindowSec <= 0 {
+		return nil
+	}
+	return &writeDedup{
+		capacity: config.DedupMaxEntries,
+		window:   time.Duration(config.DedupWindowSec) * time.Second,
+		order:    list.New(),
+		entries:  make(map[[sha256.Size]byte]*list.Element, config.DedupMaxEntries),
+	}
+}
+
+// duplicate returns whether identical data was written within the window,
+// along with the data's hash for remember.
+func (d *writeDedup) duplicate(data []byte, now time.Time) ([sha256.Size]byte, bool) {
+	if d == nil {
+		return [sha256.Size]byte{}, false
+	}
+
+	hash := sha256.Sum256(data)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// Forget hashes that have left the window, oldest first
+	for oldest := d.order.Back(); oldest != nil; oldest = d.order.Back() {
+		entry := oldest.Value.(*dedupEntry)
+		if now.Sub(entry.writtenAt) < d.window {
+			break
+		}
+		d.order.Remove(oldest)
+		delete(d.entries, entry.hash)
+	}
+
+	_, exists := d.entries[hash]
+	return hash, exists
+}
+
+// remember records data, by the hash duplicate returned for it, as written
+// now. It is only called once the data is stored, so a failed write can be
+// retried within the window.
+func (d *writeDedup) remember(hash [sha256.Size]byte, now time.Time) {
+	if d == nil {
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, exists := d.entries[hash]; exists {
+		return
+	}
+	if d.order.Len() >= d.capacity {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.entries, oldest.Value.(*dedupEntry).hash)
+	}
+	d.entries[hash] = d.order.PushFront(&dedupEntry{hash: hash, writtenAt: now})
+}
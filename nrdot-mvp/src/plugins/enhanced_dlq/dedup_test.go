@@ -0,0 +1,59 @@
+package enhanceddlq
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDedupStoresRepeatedPayloadOnce(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.DedupWindowSec = 60
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := storage.Write(context.Background(), []byte("batch")); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+
+	if records := readAllRecords(t, storage); len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	if got := storage.DedupedWrites(); got != 1 {
+		t.Fatalf("expected 1 deduplicated write, got %d", got)
+	}
+}
+
+func TestDedupRetriesFailedWrite(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.DedupWindowSec = 60
+	})
+	if err := storage.Write(context.Background(), []byte("first")); err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+
+	// Fail the next write, without enough failures to engage the fallback
+	storage.currentFileMutex.Lock()
+	storage.currentFile.Close()
+	storage.currentFileMutex.Unlock()
+	if err := storage.Write(context.Background(), []byte("retried")); err == nil {
+		t.Fatal("expected the write to fail")
+	}
+	if storage.fallback.IsActive() {
+		t.Fatal("expected the fallback not to be engaged")
+	}
+
+	// The retry within the window is written rather than skipped
+	storage.closeCurrentFile()
+	if err := storage.Write(context.Background(), []byte("retried")); err != nil {
+		t.Fatalf("failed to retry record: %v", err)
+	}
+
+	records := readAllRecords(t, storage)
+	if len(records) != 2 || string(records[1].Data) != "retried" {
+		t.Fatalf("expected the retried record to be stored, got %d records", len(records))
+	}
+	if got := storage.DedupedWrites(); got != 0 {
+		t.Fatalf("expected no deduplicated writes, got %d", got)
+	}
+}
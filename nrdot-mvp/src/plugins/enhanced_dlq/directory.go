@@ -0,0 +1,158 @@
+package enhanceddlq
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// systemDirectories are directories the DLQ must never be pointed at, since
+// retention would then consider unrelated files for deletion.
+var systemDirectories = []string{
+	"/", "/bin", "/boot", "/dev", "/etc", "/home", "/lib", "/lib64", "/opt",
+	"/proc", "/root", "/run", "/sbin", "/srv", "/sys", "/tmp", "/usr", "/var",
+	"/var/lib", "/var/log",
+}
+
+// validateDirectory rejects DLQ directories that are a system directory or
+// the user's home directory, or that resolve, following symlinks, to one or
+// to a path outside allowedBase when it is set.
+func validateDirectory(dir string, allowedBase string) error {
+	resolved, err := resolvePath(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve directory '%s': %w", dir, err)
+	}
+
+	for _, path := range []string{filepath.Clean(dir), resolved} {
+		if suspiciousDirectory(path) {
+			return fmt.Errorf("directory '%s' resolves to '%s', which must not be used for DLQ files", dir, path)
+		}
+	}
+
+	if allowedBase == "" {
+		return nil
+	}
+
+	base, err := resolvePath(allowedBase)
+	if err != nil {
+		return fmt.Errorf("failed to resolve allowed_base_directory '%s': %w", allowedBase, err)
+	}
+	if resolved != base && !strings.HasPrefix(resolved, base+string(filepath.Separator)) {
+		return fmt.Errorf("directory '%s' resolves to '%s', outside allowed_base_directory '%s'", dir, resolved, allowedBase)
+	}
+	return nil
+}
+
+// suspiciousDirectory returns whether a cleaned absolute path is a system
+// directory or the user's home directory.
+func suspiciousDirectory(path string) bool {
+	for _, system := range systemDirectories {
+		if path == system {
+			return true
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil && path == filepath.Clean(home) {
+		return true
+	}
+	return false
+}
+
+// resolvePath returns the absolute path with symlinks resolved. Components
+// that don't exist yet are appended to the nearest existing ancestor, which
+// is resolved.
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(path))
+		path = parent
+	}
+}
+
+// trackFile records a DLQ file as created by this storage, so retention may
+// delete it.
+func (s *DLQStorage) trackFile(path string) {
+	s.ownedMutex.Lock()
+	defer s.ownedMutex.Unlock()
+	s.ownedFiles[path] = true
+}
+
+// untrackFile forgets a deleted DLQ file.
+func (s *DLQStorage) untrackFile(path string) {
+	s.ownedMutex.Lock()
+	defer s.ownedMutex.Unlock()
+	delete(s.ownedFiles, path)
+}
+
+// ownsFile returns whether a file was created by this storage, or adopted
+// as a DLQ file at startup.
+func (s *DLQStorage) ownsFile(path string) bool {
+	s.ownedMutex.Lock()
+	defer s.ownedMutex.Unlock()
+	return s.ownedFiles[path]
+}
+
+// adoptExistingFiles tracks the files left by a previous run that are DLQ
+// files: named like one and either empty or starting with a DLQ record.
+// Anything else matching the file pattern is left alone by retention.
+func (s *DLQStorage) adoptExistingFiles(files []string) {
+	for _, file := range files {
+		if sequence, timestamp := parseDLQFileName(file); sequence < 0 && timestamp == "" {
+			continue
+		}
+		if !startsWithRecord(file) {
+			s.logger.Warn("Ignoring file in the DLQ directory that isn't a DLQ file",
+				zap.String("file", file),
+			)
+			continue
+		}
+		s.trackFile(file)
+	}
+}
+
+// startsWithRecord returns whether a regular file is empty or starts with a
+// DLQ record header.
+func startsWithRecord(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	header := "--- DLQ RECORD START "
+	prefix, err := bufio.NewReader(file).Peek(len(header))
+	if err == io.EOF && len(prefix) == 0 {
+		return true
+	}
+	return string(prefix) == header
+}
@@ -0,0 +1,126 @@
+package enhanceddlq
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestValidateDirectoryRejectsSuspiciousPaths(t *testing.T) {
+	base := t.TempDir()
+	link := filepath.Join(base, "etc-link")
+	if err := os.Symlink("/etc", link); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("failed to get home directory: %v", err)
+	}
+
+	for _, dir := range []string{"/", "/etc", "/var/lib", home, link, "/etc/../"} {
+		if err := validateDirectory(dir, ""); err == nil {
+			t.Errorf("expected directory %q to be rejected", dir)
+		}
+	}
+
+	// A directory outside the allowed base is rejected, one inside it isn't,
+	// even before it exists
+	if err := validateDirectory(t.TempDir(), base); err == nil {
+		t.Error("expected a directory outside the allowed base to be rejected")
+	}
+	if err := validateDirectory(filepath.Join(base, "dlq", "metrics"), base); err != nil {
+		t.Errorf("expected a directory inside the allowed base to be accepted, got %v", err)
+	}
+}
+
+func TestCleanupNeverDeletesForeignFiles(t *testing.T) {
+	var adoptedForeign string
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.RetentionHours = 1
+		// A file named like a DLQ file, left before startup, that isn't one
+		adoptedForeign = filepath.Join(config.Directory, config.FilePrefix+"-metrics-0000000099-20230101-000000.000.dlq")
+		if err := os.WriteFile(adoptedForeign, []byte("not a DLQ record"), 0644); err != nil {
+			t.Fatalf("failed to write foreign file: %v", err)
+		}
+	})
+
+	// Files matching the pattern that appear while running
+	dir := storage.config.Directory
+	foreign := []string{
+		adoptedForeign,
+		filepath.Join(dir, storage.filePrefix+"-notes.dlq"),
+		filepath.Join(dir, storage.filePrefix+"-0000000100-20230101-000000.000.dlq"),
+	}
+	for _, file := range foreign[1:] {
+		if err := os.WriteFile(file, []byte("not a DLQ record"), 0644); err != nil {
+			t.Fatalf("failed to write foreign file: %v", err)
+		}
+	}
+
+	if err := storage.Write(context.Background(), []byte("record-0")); err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+	rotate(t, storage)
+	files, err := storage.ListDLQFiles()
+	if err != nil {
+		t.Fatalf("failed to list DLQ files: %v", err)
+	}
+
+	listed := make(map[string]bool, len(files))
+	for _, file := range files {
+		listed[file] = true
+	}
+	for _, file := range foreign {
+		if !listed[file] {
+			t.Fatalf("expected foreign file %s to match the DLQ file pattern", filepath.Base(file))
+		}
+	}
+
+	// Everything is far past retention
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, file := range files {
+		if err := os.Chtimes(file, old, old); err != nil {
+			t.Fatalf("failed to age file: %v", err)
+		}
+	}
+	if err := storage.cleanupOldFiles(); err != nil {
+		t.Fatalf("failed to clean up: %v", err)
+	}
+
+	for _, file := range foreign {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("expected foreign file %s to survive cleanup, got %v", filepath.Base(file), err)
+		}
+	}
+
+	// Only the file this storage wrote and rotated away from was deleted
+	remaining, err := storage.ListDLQFiles()
+	if err != nil {
+		t.Fatalf("failed to list DLQ files: %v", err)
+	}
+	if deleted := len(files) - len(remaining); deleted != 1 {
+		t.Fatalf("expected 1 DLQ file to be deleted, got %d", deleted)
+	}
+
+	// Restarting neither adopts nor deletes the foreign files
+	if err := storage.Shutdown(); err != nil {
+		t.Fatalf("failed to shut down storage: %v", err)
+	}
+	restarted, err := NewDLQStorage(storage.config, zap.NewNop(), "metrics")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer restarted.Shutdown()
+	for _, file := range foreign {
+		if restarted.ownsFile(file) {
+			t.Errorf("expected foreign file %s not to be adopted", filepath.Base(file))
+		}
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("expected foreign file %s to survive startup cleanup, got %v", filepath.Base(file), err)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package enhanceddlq
+
+// availableBytes reports that free space can't be checked on this platform.
+func availableBytes(directory string) (int64, bool, error) {
+	return 0, false, nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package enhanceddlq
+
+import (
+	"syscall"
+)
+
+// availableBytes returns the space available to unprivileged users on the
+// filesystem holding the directory.
+func availableBytes(directory string) (int64, bool, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(directory, &stat); err != nil {
+		return 0, false, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), true, nil
+}
@@ -0,0 +1,103 @@
+package enhanceddlq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ReplayEstimate describes the data the next replay would process, without
+// replaying it.
+type ReplayEstimate struct {
+	// Records and bytes of record data left to replay, from the replay
+	// checkpoint on and within the replay limit
+	Records int64 `json:"records"`
+	Bytes   int64 `json:"bytes"`
+
+	// Time to replay the bytes at the configured replay rate. The adaptive
+	// rate and a slow consumer can only make the replay take longer.
+	ETASeconds float64 `json:"eta_seconds"`
+}
+
+// EstimateReplay returns the records and bytes StartReplay would replay with
+// the given limit, and how long that would take at ReplayRateMiBSec. It reads
+// the files the same way a replay does, in priority passes and resuming from
+// the checkpoint, but sends nothing and leaves the checkpoint unchanged.
+func (s *DLQStorage) EstimateReplay(limit ReplayLimit) (ReplayEstimate, error) {
+	s.replayMutex.Lock()
+	if s.compacting {
+		s.replayMutex.Unlock()
+		return ReplayEstimate{}, fmt.Errorf("DLQ files are being compacted")
+	}
+	checkpoint := s.replayCheckpoint
+	s.replayMutex.Unlock()
+
+	files, err := s.ListDLQFiles()
+	if err != nil {
+		return ReplayEstimate{}, err
+	}
+
+	budget := &replayBudget{limit: limit}
+	for passIndex, pass := range s.replayPasses() {
+		for _, file := range files {
+			if budget.exhausted() {
+				break
+			}
+			skip, offset := checkpoint.skip(passIndex, file)
+			if skip {
+				continue
+			}
+			if err := estimateFile(file, pass, offset, budget); err != nil {
+				return ReplayEstimate{}, err
+			}
+		}
+	}
+
+	return ReplayEstimate{
+		Records:    budget.records,
+		Bytes:      budget.bytes,
+		ETASeconds: replayETA(budget.bytes, s.config.ReplayRateMiBSec).Seconds(),
+	}, nil
+}
+
+// estimateFile counts the records of a file from the offset on that the
+// pass selects, until the budget is exhausted. A file removed since it was
+// listed is skipped, and a record still being written at its end isn't
+// counted.
+func estimateFile(path string, pass replayPass, offset int64, budget *replayBudget) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open DLQ file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek DLQ file: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+	for !budget.exhausted() {
+		record, _, err := readStoredRecord(reader)
+		if err != nil {
+			// The end of the file, or a partial record at it
+			return nil
+		}
+		if pass(record.Priority) {
+			budget.consume(len(record.Data))
+		}
+	}
+	return nil
+}
+
+// replayETA returns how long replaying the bytes takes at the rate.
+func replayETA(bytes int64, rateMiBSec float64) time.Duration {
+	if rateMiBSec <= 0 {
+		return 0
+	}
+	return time.Duration(float64(bytes) / (rateMiBSec * 1024 * 1024) * float64(time.Second))
+}
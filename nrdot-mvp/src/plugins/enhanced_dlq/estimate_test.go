@@ -0,0 +1,56 @@
+package enhanceddlq
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEstimateReplayMatchesContents(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.ReplayRateMiBSec = 2
+		config.ReplayPriorityOrder = []string{"critical"}
+	})
+
+	// Critical records are replayed in a pass of their own, and still counted
+	// once
+	sizes := []int{1024, 2048, 4096, 8192}
+	var total int64
+	for i, size := range sizes {
+		ctx := context.Background()
+		if i%2 == 0 {
+			ctx = ContextWithPriority(ctx, "critical")
+		}
+		if err := storage.Write(ctx, []byte(strings.Repeat("x", size))); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+		total += int64(size)
+		if i == 1 {
+			rotate(t, storage)
+		}
+	}
+	rotate(t, storage)
+
+	estimate, err := storage.EstimateReplay(ReplayLimit{})
+	if err != nil {
+		t.Fatalf("failed to estimate replay: %v", err)
+	}
+	if estimate.Records != int64(len(sizes)) || estimate.Bytes != total {
+		t.Fatalf("expected %d records of %d bytes, got %d records of %d bytes", len(sizes), total, estimate.Records, estimate.Bytes)
+	}
+	want := float64(total) / (2 * 1024 * 1024)
+	if math.Abs(estimate.ETASeconds-want) > 1e-6 {
+		t.Fatalf("expected an ETA of %vs at 2 MiB/s, got %vs", want, estimate.ETASeconds)
+	}
+
+	// The replay limit caps the estimate like it caps a replay, critical
+	// records first
+	estimate, err = storage.EstimateReplay(ReplayLimit{Records: 2})
+	if err != nil {
+		t.Fatalf("failed to estimate replay: %v", err)
+	}
+	if estimate.Records != 2 || estimate.Bytes != int64(sizes[0]+sizes[2]) {
+		t.Fatalf("expected the 2 critical records, got %d records of %d bytes", estimate.Records, estimate.Bytes)
+	}
+}
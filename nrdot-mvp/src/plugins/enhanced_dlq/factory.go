@@ -3,6 +3,7 @@ package enhanceddlq
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/exporter"
@@ -17,6 +18,10 @@ const (
 // ErrEmptyConfig is returned when the configuration provided is empty.
 var ErrEmptyConfig = errors.New("empty configuration for enhanced_dlq exporter")
 
+// ErrSignalDisabled is returned when the exporter is used in a pipeline for a
+// signal that is disabled in its configuration.
+var ErrSignalDisabled = errors.New("signal is disabled for enhanced_dlq exporter")
+
 // NewFactory creates a new factory for the EnhancedDLQ exporter.
 func NewFactory() exporter.Factory {
 	return exporter.NewFactory(
@@ -38,6 +43,9 @@ func createMetricsExporter(
 	if !ok {
 		return nil, ErrEmptyConfig
 	}
+	if !eCfg.EnableMetrics {
+		return nil, fmt.Errorf("%w: metrics", ErrSignalDisabled)
+	}
 
 	exporter, err := newMetricsExporter(ctx, set, eCfg)
 	if err != nil {
@@ -68,6 +76,9 @@ func createTracesExporter(
 	if !ok {
 		return nil, ErrEmptyConfig
 	}
+	if !eCfg.EnableTraces {
+		return nil, fmt.Errorf("%w: traces", ErrSignalDisabled)
+	}
 
 	exporter, err := newTracesExporter(ctx, set, eCfg)
 	if err != nil {
@@ -98,6 +109,9 @@ func createLogsExporter(
 	if !ok {
 		return nil, ErrEmptyConfig
 	}
+	if !eCfg.EnableLogs {
+		return nil, fmt.Errorf("%w: logs", ErrSignalDisabled)
+	}
 
 	exporter, err := newLogsExporter(ctx, set, eCfg)
 	if err != nil {
@@ -0,0 +1,246 @@
+package enhanceddlq
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// failedFilePath returns the path of the file that records rejected by the
+// consumer during replay are captured in. Its name doesn't match the DLQ
+// file pattern, so regular replays and retention leave it alone.
+func (s *DLQStorage) failedFilePath() string {
+	return filepath.Join(s.config.Directory, s.filePrefix+".failed")
+}
+
+// captureFailure appends a record the consumer rejected to the failed file,
+// keeping its original timestamp, priority and serialization format.
+func (s *DLQStorage) captureFailure(record *DLQRecord) {
+	s.failedMutex.Lock()
+	defer s.failedMutex.Unlock()
+
+	if err := s.appendFailed([]*DLQRecord{record}); err != nil {
+		s.logger.Error("Failed to capture rejected DLQ record",
+			zap.Error(err),
+			zap.Time("timestamp", record.Timestamp),
+		)
+	}
+}
+
+// appendFailed appends records to the failed file with a single write and a
+// single sync. The caller must hold failedMutex.
+func (s *DLQStorage) appendFailed(records []*DLQRecord) error {
+	var buf bytes.Buffer
+	for _, record := range records {
+		s.encodeRecordAt(&buf, record.Data, record.Priority, record.Format, record.Timestamp.UnixNano())
+	}
+
+	file, err := s.openFile(s.failedFilePath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open failed records file: %w", err)
+	}
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		s.closeFile(file)
+		return fmt.Errorf("failed to write failed records: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		s.closeFile(file)
+		return fmt.Errorf("failed to sync failed records file: %w", err)
+	}
+	return s.closeFile(file)
+}
+
+// FailedRecords returns the number of records waiting in the failed file.
+func (s *DLQStorage) FailedRecords() (int64, error) {
+	s.failedMutex.Lock()
+	defer s.failedMutex.Unlock()
+
+	file, err := os.Open(s.failedFilePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var count int64
+	reader := bufio.NewReader(file)
+	for {
+		_, _, err := readStoredRecord(reader)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// StartFailedReplay replays only the records captured in the failed file by
+// earlier replays, at the configured rate. The file is taken over by the
+// replay, so records that fail again are captured in a new failed file and
+// can be retried by the next failed replay. Records not consumed because
+// the replay was stopped or cancelled are put back in the failed file.
+func (s *DLQStorage) StartFailedReplay(ctx context.Context, consumer DLQConsumer) error {
+	s.replayMutex.Lock()
+	defer s.replayMutex.Unlock()
+
+	if s.replayActive {
+		return fmt.Errorf("replay is already active")
+	}
+	if s.compacting {
+		return fmt.Errorf("DLQ files are being compacted")
+	}
+	if isShadowReplay(ctx, s.config) {
+		return fmt.Errorf("failed replay is not supported in shadow mode")
+	}
+
+	// Take over the failed file, unless an interrupted failed replay left
+	// one behind
+	replayPath := s.failedFilePath() + ".replaying"
+	if _, err := os.Stat(replayPath); errors.Is(err, os.ErrNotExist) {
+		s.failedMutex.Lock()
+		err := os.Rename(s.failedFilePath(), replayPath)
+		s.failedMutex.Unlock()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil // Nothing to replay
+		}
+		if err != nil {
+			return fmt.Errorf("failed to take over failed records file: %w", err)
+		}
+	}
+
+	s.replayActive = true
+	s.replayInterleave.Reset()
+	s.rateLimiter.Reset()
+	if s.adaptiveRate != nil {
+		s.adaptiveRate.reset()
+	}
+
+	startedAt := s.getClock().Now()
+	totals := &replayTotals{}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	s.replayStop = stop
+	s.replayDone = done
+
+	go func() {
+		defer close(done)
+
+		release, err := acquireReplaySlot(ctx, stop, s.config.Directory, s.config.MaxConcurrentReplays)
+		if err != nil {
+			s.logger.Info("Failed DLQ record replay cancelled while waiting for a replay slot", zap.Error(err))
+			s.markReplayCompleted()
+			s.notifyReplayCompleted(ReplayOutcomeCancelled, startedAt, totals, false)
+			return
+		}
+		defer release()
+
+		s.logger.Info("Starting replay of failed DLQ records",
+			zap.String("file", s.failedFilePath()),
+			zap.Float64("rateMiBSec", s.config.ReplayRateMiBSec),
+		)
+
+		outcome, err := s.replayFailedFile(ctx, replayPath, consumer, totals, stop)
+		if err != nil {
+			// Leave the file in place so the next failed replay picks it up
+			s.logger.Error("Failed to replay failed DLQ records", zap.Error(err))
+		} else if err := os.Remove(replayPath); err != nil {
+			s.logger.Warn("Failed to remove replayed failed records file", zap.Error(err))
+		}
+
+		s.markReplayCompleted()
+		s.notifyReplayCompleted(outcome, startedAt, totals, false)
+	}()
+
+	return nil
+}
+
+// replayFailedFile consumes the records in a taken-over failed file one at a
+// time, capturing those that fail again. Once stopped or cancelled, the
+// records not yet consumed are captured as well, so none are lost.
+func (s *DLQStorage) replayFailedFile(ctx context.Context, path string, consumer DLQConsumer, totals *replayTotals, stop <-chan struct{}) (string, error) {
+	file, err := s.openFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return ReplayOutcomeCancelled, fmt.Errorf("failed to open failed records file: %w", err)
+	}
+	defer func() {
+		if err := s.closeFile(file); err != nil {
+			s.logger.Warn("Failed to close replayed failed records file", zap.Error(err))
+		}
+	}()
+
+	reader := bufio.NewReader(file)
+	outcome := ReplayOutcomeCompleted
+	var remaining []*DLQRecord
+	for {
+		record, _, err := readStoredRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return outcome, err
+		}
+
+		// Keep the rest for the next failed replay once stopped
+		if outcome != ReplayOutcomeCompleted {
+			remaining = append(remaining, record)
+			continue
+		}
+		select {
+		case <-stop:
+			outcome = ReplayOutcomeStopped
+			remaining = append(remaining, record)
+			continue
+		case <-ctx.Done():
+			outcome = ReplayOutcomeCancelled
+			remaining = append(remaining, record)
+			continue
+		default:
+		}
+
+		if s.config.VerifySHA256 && record.Hash != "" {
+			sum := sha256.Sum256(record.Data)
+			if hex.EncodeToString(sum[:]) != record.Hash {
+				atomic.AddInt64(&s.totalVerificationFailures, 1)
+				s.logger.Warn("Captured DLQ record failed SHA-256 verification",
+					zap.Time("timestamp", record.Timestamp),
+				)
+				continue
+			}
+		}
+
+		// Records that fail again are captured for the next failed replay
+		if !s.consumeReplayRecord(ctx, stop, consumer, record, totals, true, zap.Bool("captured", true)) {
+			outcome = ReplayOutcomeStopped
+			if ctx.Err() != nil {
+				outcome = ReplayOutcomeCancelled
+			}
+			remaining = append(remaining, record)
+		}
+	}
+
+	if len(remaining) > 0 {
+		s.failedMutex.Lock()
+		err := s.appendFailed(remaining)
+		s.failedMutex.Unlock()
+		if err != nil {
+			return outcome, err
+		}
+	}
+	return outcome, nil
+}
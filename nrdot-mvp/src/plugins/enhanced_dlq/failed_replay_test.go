@@ -0,0 +1,72 @@
+package enhanceddlq
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// rejectingConsumer collects records, rejecting those listed.
+type rejectingConsumer struct {
+	recordCollector
+	reject map[string]bool
+}
+
+func (c *rejectingConsumer) ConsumeDLQRecord(ctx context.Context, record *DLQRecord) error {
+	if c.reject[string(record.Data)] {
+		return fmt.Errorf("rejected %s", record.Data)
+	}
+	return c.recordCollector.ConsumeDLQRecord(ctx, record)
+}
+
+func TestFailedReplayRetriesOnlyRejectedRecords(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.CaptureReplayFailures = true
+		config.ReplayConcurrency = 1
+	})
+	storage.SetClock(clock.Real())
+	writeReplayRecords(t, storage, 6)
+
+	// The backend rejects every other record
+	consumer := &rejectingConsumer{reject: map[string]bool{"record-1": true, "record-3": true, "record-5": true}}
+	if err := storage.StartReplay(context.Background(), consumer, ReplayLimit{}); err != nil {
+		t.Fatalf("failed to start replay: %v", err)
+	}
+	waitFor(t, "the replay to finish", func() bool { return !storage.IsReplayActive() })
+
+	// The failed file holds exactly the rejected records
+	file, err := os.Open(storage.failedFilePath())
+	if err != nil {
+		t.Fatalf("failed to open failed records file: %v", err)
+	}
+	var failed []string
+	reader := bufio.NewReader(file)
+	for {
+		record, _, err := readStoredRecord(reader)
+		if err != nil {
+			break
+		}
+		failed = append(failed, string(record.Data))
+	}
+	file.Close()
+	if len(failed) != 3 || failed[0] != "record-1" || failed[1] != "record-3" || failed[2] != "record-5" {
+		t.Fatalf("expected the failed file to hold the rejected records, got %v", failed)
+	}
+
+	// A failed replay retries only those
+	collector := &recordCollector{}
+	if err := storage.StartFailedReplay(context.Background(), collector); err != nil {
+		t.Fatalf("failed to start failed replay: %v", err)
+	}
+	waitFor(t, "the failed replay to finish", func() bool { return !storage.IsReplayActive() })
+	if got := collector.received(); len(got) != 3 || got[0] != "record-1" || got[1] != "record-3" || got[2] != "record-5" {
+		t.Fatalf("expected only the rejected records to be retried, got %v", got)
+	}
+	if remaining, err := storage.FailedRecords(); err != nil || remaining != 0 {
+		t.Fatalf("expected no failed records left, got %d (%v)", remaining, err)
+	}
+}
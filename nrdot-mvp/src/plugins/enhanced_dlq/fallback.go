@@ -0,0 +1,165 @@
+package enhanceddlq
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// Fallback modes used when the DLQ directory cannot be written.
+const (
+	FallbackModeDrop   = "drop"
+	FallbackModeMemory = "memory"
+)
+
+// WriteFallback tracks persistent write failures to the DLQ directory and
+// holds or drops data while the directory is unwritable.
+type WriteFallback struct {
+	clock     clock.Clock
+	mode      string
+	threshold int
+	maxBytes  int64
+
+	consecutiveFailures int
+	active              bool
+	trippedAt           time.Time
+	buffer              []fallbackRecord
+	bufferBytes         int64
+
+	// Stats
+	writeFailures int64
+	droppedItems  int64
+	droppedBytes  int64
+	trips         int64
+
+	mutex sync.Mutex
+}
+
+// fallbackRecord is a record held in memory by the fallback.
+type fallbackRecord struct {
+	data     []byte
+	priority string
+}
+
+// FallbackStats is a snapshot of the fallback state.
+type FallbackStats struct {
+	Active        bool
+	TrippedAt     time.Time
+	BufferedItems int
+	BufferedBytes int64
+	WriteFailures int64
+	DroppedItems  int64
+	DroppedBytes  int64
+	Trips         int64
+}
+
+// NewWriteFallback creates a new write fallback from the configuration.
+func NewWriteFallback(config *Config, clk clock.Clock) *WriteFallback {
+	return &WriteFallback{
+		clock:     clk,
+		mode:      config.FallbackMode,
+		threshold: config.WriteFailureThreshold,
+		maxBytes:  int64(config.FallbackMemoryLimitMiB) * 1024 * 1024,
+	}
+}
+
+// RecordFailure records a failed write. It returns true if the failure
+// tripped the fallback.
+func (f *WriteFallback) RecordFailure() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.writeFailures++
+	f.consecutiveFailures++
+
+	if f.active || f.consecutiveFailures < f.threshold {
+		return false
+	}
+
+	f.active = true
+	f.trippedAt = f.clock.Now()
+	f.trips++
+	return true
+}
+
+// RecordSuccess records a successful write.
+func (f *WriteFallback) RecordSuccess() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.consecutiveFailures = 0
+}
+
+// IsActive returns whether the fallback is currently engaged.
+func (f *WriteFallback) IsActive() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.active
+}
+
+// Store hands data to the fallback. In memory mode the data is buffered up to
+// the configured cap; anything that doesn't fit, or everything in drop mode,
+// is dropped and counted.
+func (f *WriteFallback) Store(data []byte, priority string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	size := int64(len(data))
+	if f.mode == FallbackModeMemory && f.bufferBytes+size <= f.maxBytes {
+		f.buffer = append(f.buffer, fallbackRecord{data: data, priority: priority})
+		f.bufferBytes += size
+		return
+	}
+
+	f.droppedItems++
+	f.droppedBytes += size
+}
+
+// Recover disengages the fallback and returns any buffered data so it can be
+// written to disk.
+func (f *WriteFallback) Recover() []fallbackRecord {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	buffered := f.buffer
+	f.buffer = nil
+	f.bufferBytes = 0
+	f.active = false
+	f.consecutiveFailures = 0
+
+	return buffered
+}
+
+// Reengage re-engages the fallback after a failed recovery, putting back the
+// data that could not be written.
+func (f *WriteFallback) Reengage(remaining []fallbackRecord) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var remainingBytes int64
+	for _, record := range remaining {
+		remainingBytes += int64(len(record.data))
+	}
+
+	f.buffer = append(remaining, f.buffer...)
+	f.bufferBytes += remainingBytes
+	f.active = true
+	f.writeFailures++
+}
+
+// Stats returns a snapshot of the fallback state.
+func (f *WriteFallback) Stats() FallbackStats {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return FallbackStats{
+		Active:        f.active,
+		TrippedAt:     f.trippedAt,
+		BufferedItems: len(f.buffer),
+		BufferedBytes: f.bufferBytes,
+		WriteFailures: f.writeFailures,
+		DroppedItems:  f.droppedItems,
+		DroppedBytes:  f.droppedBytes,
+		Trips:         f.trips,
+	}
+}
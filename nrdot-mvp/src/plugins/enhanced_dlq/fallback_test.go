@@ -0,0 +1,91 @@
+package enhanceddlq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// makeUnwritable makes a directory read-only and returns a function that
+// makes it writable again. Permissions don't stop root, so when running as
+// root the directory is moved aside and a file put in its place instead.
+func makeUnwritable(t *testing.T, dir string) (restore func()) {
+	t.Helper()
+
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatalf("failed to make directory read-only: %v", err)
+	}
+	probe := filepath.Join(dir, "probe")
+	if err := os.WriteFile(probe, nil, 0644); err != nil {
+		return func() { os.Chmod(dir, 0755) }
+	}
+	os.Remove(probe)
+	os.Chmod(dir, 0755)
+
+	moved := dir + ".moved"
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatalf("failed to move directory aside: %v", err)
+	}
+	if err := os.WriteFile(dir, nil, 0444); err != nil {
+		t.Fatalf("failed to replace directory: %v", err)
+	}
+	return func() {
+		os.Remove(dir)
+		os.Rename(moved, dir)
+	}
+}
+
+func TestFallbackEngagesOnUnwritableDirectory(t *testing.T) {
+	e := newStartedMetricsExporter(t, "fallback", func(config *Config) {
+		config.WriteFailureThreshold = 2
+		config.FallbackMode = FallbackModeMemory
+		config.FallbackMemoryLimitMiB = 1
+	})
+	storage := e.storage
+
+	if err := storage.Write(context.Background(), []byte("before")); err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+	storage.closeCurrentFile()
+	restore := makeUnwritable(t, storage.config.Directory)
+
+	// The first failure is returned, the second trips the fallback
+	if err := storage.Write(context.Background(), []byte("record-0")); err == nil {
+		t.Fatal("expected the write to an unwritable directory to fail")
+	}
+	for i := 1; i < 3; i++ {
+		if err := storage.Write(context.Background(), []byte(fmt.Sprintf("record-%d", i))); err != nil {
+			t.Fatalf("expected the fallback to take the record, got %v", err)
+		}
+	}
+	if !storage.fallback.IsActive() {
+		t.Fatal("expected the fallback to be engaged")
+	}
+
+	if got, _ := dlqMetricValue(t, e.id, "nrdot_mvp_dlq_fallback_active"); got != 1 {
+		t.Fatalf("expected the fallback_active metric to fire, got %v", got)
+	}
+	if got, _ := dlqMetricValue(t, e.id, "nrdot_mvp_dlq_write_failures_total"); got != 2 {
+		t.Fatalf("expected the write failures metric to count 2 failures, got %v", got)
+	}
+	if stats := storage.fallback.Stats(); stats.WriteFailures != 2 || stats.BufferedItems != 2 {
+		t.Fatalf("expected 2 write failures and 2 buffered records, got %+v", stats)
+	}
+
+	// Once writable again, the buffered records reach the disk
+	restore()
+	storage.retryDirectory(context.Background())
+	if storage.fallback.IsActive() {
+		t.Fatal("expected the fallback to disengage")
+	}
+	rotate(t, storage)
+	var written []string
+	for _, record := range readAllRecords(t, storage) {
+		written = append(written, string(record.Data))
+	}
+	if len(written) != 3 || written[0] != "before" || written[1] != "record-1" || written[2] != "record-2" {
+		t.Fatalf("expected the buffered records to be flushed, got %v", written)
+	}
+}
@@ -0,0 +1,188 @@
+package enhanceddlq
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// errNotDLQFile is returned when a file asked to be replayed isn't one of
+// the DLQ files of the storage.
+var errNotDLQFile = errors.New("not a DLQ file")
+
+// dlqFile returns the DLQ file a path names. Relative paths are relative to
+// the DLQ directory. Symlinks are resolved before the file is matched
+// against the DLQ files, so the path can't reach outside the directory.
+func (s *DLQStorage) dlqFile(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.config.Directory, path)
+	}
+
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve '%s': %w", path, err)
+	}
+	directory, err := resolvePath(s.config.Directory)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve DLQ directory '%s': %w", s.config.Directory, err)
+	}
+	if filepath.Dir(resolved) != directory {
+		return "", fmt.Errorf("'%s' is outside the DLQ directory '%s': %w", path, s.config.Directory, errNotDLQFile)
+	}
+
+	files, err := s.ListDLQFiles()
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		if candidate, err := resolvePath(file); err == nil && candidate == resolved {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("'%s': %w", path, errNotDLQFile)
+}
+
+// ReplayFile replays only the records of one DLQ file at the configured
+// rate, for targeted recovery of data known to be in it. The path is
+// relative to the DLQ directory unless absolute, and must name one of its
+// DLQ files. The file is kept and the replay checkpoint isn't moved, so
+// other files are untouched and the next full replay still covers the file.
+func (s *DLQStorage) ReplayFile(ctx context.Context, path string, consumer DLQConsumer) error {
+	file, err := s.dlqFile(path)
+	if err != nil {
+		return err
+	}
+
+	s.replayMutex.Lock()
+	defer s.replayMutex.Unlock()
+
+	if s.replayActive {
+		return fmt.Errorf("replay is already active")
+	}
+	if s.compacting {
+		return fmt.Errorf("DLQ files are being compacted")
+	}
+
+	s.replayActive = true
+	s.replayInterleave.Reset()
+	s.rateLimiter.Reset()
+	if s.adaptiveRate != nil {
+		s.adaptiveRate.reset()
+	}
+
+	shadow := isShadowReplay(ctx, s.config)
+	startedAt := s.getClock().Now()
+	totals := &replayTotals{}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	s.replayStop = stop
+	s.replayDone = done
+
+	// Records are consumed under a context the deadline cancels
+	replayCtx, cancelReplay := context.WithCancel(ctx)
+	if s.config.MaxReplayDurationSec > 0 {
+		go s.enforceReplayDeadline(stop, done, cancelReplay)
+	}
+
+	go func() {
+		defer close(done)
+		defer cancelReplay()
+
+		release, err := acquireReplaySlot(ctx, stop, s.config.Directory, s.config.MaxConcurrentReplays)
+		if err != nil {
+			s.logger.Info("DLQ file replay cancelled while waiting for a replay slot", zap.Error(err))
+			s.markReplayCompleted()
+			s.notifyReplayCompleted(ReplayOutcomeCancelled, startedAt, totals, shadow)
+			return
+		}
+		defer release()
+
+		s.logger.Info("Starting replay of DLQ file",
+			zap.String("file", file),
+			zap.Float64("rateMiBSec", s.config.ReplayRateMiBSec),
+			zap.Bool("shadow", shadow),
+		)
+
+		outcome, err := s.consumeFile(replayCtx, file, consumer, totals, stop)
+		if err != nil {
+			s.logger.Error("Failed to replay DLQ file", zap.Error(err), zap.String("file", file))
+		}
+
+		s.markReplayCompleted()
+		s.notifyReplayCompleted(outcome, startedAt, totals, shadow)
+	}()
+
+	return nil
+}
+
+// consumeFile consumes the records of a DLQ file one at a time until the
+// end of the file, or until the replay is stopped or cancelled.
+func (s *DLQStorage) consumeFile(ctx context.Context, path string, consumer DLQConsumer, totals *replayTotals, stop <-chan struct{}) (string, error) {
+	file, err := s.openFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return ReplayOutcomeCancelled, fmt.Errorf("failed to open DLQ file: %w", err)
+	}
+	defer func() {
+		if err := s.closeFile(file); err != nil {
+			s.logger.Warn("Failed to close replayed DLQ file", zap.Error(err), zap.String("file", path))
+		}
+	}()
+
+	reader := bufio.NewReader(file)
+	for {
+		select {
+		case <-stop:
+			return ReplayOutcomeStopped, nil
+		case <-ctx.Done():
+			return haltedOutcome(stop), nil
+		default:
+		}
+
+		record, _, err := readStoredRecord(reader)
+		if err == io.EOF {
+			return ReplayOutcomeCompleted, nil
+		}
+		if err != nil {
+			// The rest of the file was never replayed
+			return ReplayOutcomeCancelled, err
+		}
+
+		if s.config.VerifySHA256 && record.Hash != "" {
+			sum := sha256.Sum256(record.Data)
+			if hex.EncodeToString(sum[:]) != record.Hash {
+				atomic.AddInt64(&s.totalVerificationFailures, 1)
+				s.logger.Warn("DLQ record failed SHA-256 verification",
+					zap.String("file", path),
+					zap.Time("timestamp", record.Timestamp),
+				)
+				continue
+			}
+		}
+
+		capture := s.config.CaptureReplayFailures && !isShadowReplay(ctx, s.config)
+		if !s.consumeReplayRecord(ctx, stop, consumer, record, totals, capture, zap.String("file", path)) {
+			return haltedOutcome(stop), nil
+		}
+	}
+}
+
+// haltedOutcome returns the outcome of a replay that ended early: stopped if
+// stop was closed, including at the replay deadline, and cancelled otherwise.
+func haltedOutcome(stop <-chan struct{}) string {
+	select {
+	case <-stop:
+		return ReplayOutcomeStopped
+	default:
+		return ReplayOutcomeCancelled
+	}
+}
@@ -0,0 +1,77 @@
+package enhanceddlq
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// recordCollector keeps the data of the records replayed to it.
+type recordCollector struct {
+	mutex sync.Mutex
+	data  []string
+}
+
+func (c *recordCollector) ConsumeDLQRecord(_ context.Context, record *DLQRecord) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.data = append(c.data, string(record.Data))
+	return nil
+}
+
+func TestReplayFileReplaysOnlyThatFile(t *testing.T) {
+	storage, _ := newTestStorage(t, nil)
+	storage.SetClock(clock.Real())
+
+	// Three files of two records each
+	for file := 0; file < 3; file++ {
+		for i := 0; i < 2; i++ {
+			if err := storage.Write(context.Background(), []byte(fmt.Sprintf("record-%d-%d", file, i))); err != nil {
+				t.Fatalf("failed to write record: %v", err)
+			}
+		}
+		rotate(t, storage)
+	}
+	files, err := storage.ListDLQFiles()
+	if err != nil {
+		t.Fatalf("failed to list DLQ files: %v", err)
+	}
+
+	finished := make(chan ReplaySummary, 1)
+	storage.SetReplayCompletedHandler(func(summary ReplaySummary) {
+		finished <- summary
+	})
+
+	consumer := &recordCollector{}
+	if err := storage.ReplayFile(context.Background(), filepath.Base(files[1]), consumer); err != nil {
+		t.Fatalf("failed to start file replay: %v", err)
+	}
+
+	select {
+	case summary := <-finished:
+		if summary.Outcome != ReplayOutcomeCompleted {
+			t.Errorf("expected the replay to complete, got %s", summary.Outcome)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("file replay didn't finish")
+	}
+
+	consumer.mutex.Lock()
+	defer consumer.mutex.Unlock()
+	if len(consumer.data) != 2 || consumer.data[0] != "record-1-0" || consumer.data[1] != "record-1-1" {
+		t.Fatalf("expected only the records of the second file, got %v", consumer.data)
+	}
+}
+
+func TestReplayFileRefusesOtherFiles(t *testing.T) {
+	storage, _ := newTestStorage(t, nil)
+
+	if err := storage.ReplayFile(context.Background(), "../outside.dlq", &recordCollector{}); err == nil {
+		t.Fatal("expected a file outside the DLQ directory to be refused")
+	}
+}
@@ -0,0 +1,98 @@
+package enhanceddlq
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+func TestSerializationFormatRoundTrip(t *testing.T) {
+	for _, format := range []string{SerializationFormatProtobuf, SerializationFormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			storage, _ := newTestStorage(t, func(config *Config) {
+				config.SerializationFormat = format
+			})
+
+			data, err := serializeMetrics(testMetrics(), format)
+			if err != nil {
+				t.Fatalf("failed to serialize metrics: %v", err)
+			}
+			if err := storage.Write(context.Background(), data); err != nil {
+				t.Fatalf("failed to write record: %v", err)
+			}
+			rotate(t, storage)
+
+			records := readAllRecords(t, storage)
+			if len(records) != 1 {
+				t.Fatalf("expected 1 record, got %d", len(records))
+			}
+			if records[0].Format != format {
+				t.Fatalf("expected the header to record %q, got %q", format, records[0].Format)
+			}
+
+			md, err := deserializeMetrics(records[0].Data, records[0].Format)
+			if err != nil {
+				t.Fatalf("failed to deserialize record: %v", err)
+			}
+			metric := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+			if metric.Name() != "requests" || md.DataPointCount() != 1 {
+				t.Fatalf("expected 1 requests data point, got %q with %d data points", metric.Name(), md.DataPointCount())
+			}
+			if got := metric.Sum().DataPoints().At(0).IntValue(); got != 42 {
+				t.Fatalf("expected the value 42, got %d", got)
+			}
+		})
+	}
+}
+
+func TestMixedFormatFileReplaysEveryRecord(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.SerializationFormat = SerializationFormatProtobuf
+	})
+	storage.SetClock(clock.Real())
+
+	// The format changes between records in the same file
+	for _, format := range []string{SerializationFormatProtobuf, SerializationFormatJSON, SerializationFormatProtobuf} {
+		storage.config.SerializationFormat = format
+		data, err := serializeMetrics(testMetrics(), format)
+		if err != nil {
+			t.Fatalf("failed to serialize metrics: %v", err)
+		}
+		if err := storage.Write(context.Background(), data); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+	rotate(t, storage)
+
+	upstream := newMetricsUpstream(t)
+	e := &metricsExporter{
+		logger:   zap.NewNop(),
+		config:   storage.config,
+		storage:  storage,
+		upstream: upstream.exporter(),
+	}
+	finished := make(chan ReplaySummary, 1)
+	e.SetReplayCompletedHandler(func(summary ReplaySummary) {
+		finished <- summary
+	})
+	if err := e.StartReplay(context.Background()); err != nil {
+		t.Fatalf("failed to start replay: %v", err)
+	}
+
+	select {
+	case summary := <-finished:
+		if summary.Records != 3 || summary.Failures != 0 {
+			t.Fatalf("expected 3 records to replay without failures, got %+v", summary)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("replay didn't finish")
+	}
+	if got := atomic.LoadInt64(&upstream.batches); got != 3 {
+		t.Fatalf("expected 3 batches to be forwarded, got %d", got)
+	}
+}
@@ -0,0 +1,162 @@
+package enhanceddlq
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	recordStartMarker = []byte("--- DLQ RECORD START ")
+	recordEndMarker   = []byte("--- DLQ RECORD END ")
+)
+
+// DLQFileInfo describes a DLQ file and the complete records in it.
+type DLQFileInfo struct {
+	Path      string    `json:"path"`
+	Signal    string    `json:"signal"`
+	SizeBytes int64     `json:"size_bytes"`
+	Records   int       `json:"records"`
+	Earliest  time.Time `json:"earliest"`
+	Latest    time.Time `json:"latest"`
+}
+
+// ListInventory describes each of the storage's DLQ files, oldest first.
+func (s *DLQStorage) ListInventory() ([]DLQFileInfo, error) {
+	files, err := s.ListDLQFiles()
+	if err != nil {
+		return nil, err
+	}
+	return inspectDLQFiles(files, s.config.FilePrefix)
+}
+
+// ListInventory describes the DLQ files of every signal in a directory, as
+// written with the given file prefix, oldest first within each signal. It
+// doesn't need a running exporter, so it can inspect the DLQ of a stopped
+// collector.
+func ListInventory(directory string, filePrefix string) ([]DLQFileInfo, error) {
+	files, err := filepath.Glob(filepath.Join(directory, fmt.Sprintf("%s-*.dlq", filePrefix)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list DLQ files: %w", err)
+	}
+
+	sortDLQFiles(files)
+	return inspectDLQFiles(files, filePrefix)
+}
+
+// inspectDLQFiles describes each of the files. A file removed since it was
+// listed, such as by retention, is left out.
+func inspectDLQFiles(files []string, filePrefix string) ([]DLQFileInfo, error) {
+	inventory := make([]DLQFileInfo, 0, len(files))
+	for _, file := range files {
+		info, err := inspectDLQFile(file, filePrefix)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		inventory = append(inventory, info)
+	}
+	return inventory, nil
+}
+
+// inspectDLQFile describes a DLQ file by scanning its record headers and
+// footers without decoding the data between them, skipping over the data
+// when the header gives its length. A record still being
+// written at the end of the file isn't counted.
+func inspectDLQFile(path string, filePrefix string) (DLQFileInfo, error) {
+	info := DLQFileInfo{
+		Path:   path,
+		Signal: signalOfFile(path, filePrefix),
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return info, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return info, fmt.Errorf("failed to stat DLQ file %s: %w", path, err)
+	}
+	info.SizeBytes = stat.Size()
+
+	reader := bufio.NewReader(file)
+	inRecord := false
+	lineStart := true
+	var timestamp time.Time
+	for {
+		line, err := reader.ReadSlice('\n')
+
+		// Only the start of a line can be a marker; long data lines are read
+		// in several slices
+		if lineStart {
+			if !inRecord && bytes.HasPrefix(line, recordStartMarker) {
+				fields := strings.Fields(string(line))
+				if len(fields) < 5 {
+					return info, fmt.Errorf("malformed DLQ record header in %s", path)
+				}
+				nanos, parseErr := strconv.ParseInt(fields[4], 10, 64)
+				if parseErr != nil {
+					return info, fmt.Errorf("malformed DLQ record timestamp in %s: %w", path, parseErr)
+				}
+				timestamp = time.Unix(0, nanos)
+				inRecord = true
+
+				// Skip data of known length, and the newline after it,
+				// without looking for markers in it
+				length, hasLength, lengthErr := recordDataLength(fields)
+				if lengthErr != nil {
+					return info, fmt.Errorf("malformed DLQ record header in %s: %w", path, lengthErr)
+				}
+				if hasLength && err == nil {
+					if _, discardErr := reader.Discard(int(length) + 1); discardErr == io.EOF {
+						return info, nil
+					} else if discardErr != nil {
+						return info, fmt.Errorf("failed to read DLQ file %s: %w", path, discardErr)
+					}
+					continue
+				}
+			} else if inRecord && bytes.HasPrefix(line, recordEndMarker) {
+				info.Records++
+				if info.Earliest.IsZero() || timestamp.Before(info.Earliest) {
+					info.Earliest = timestamp
+				}
+				if timestamp.After(info.Latest) {
+					info.Latest = timestamp
+				}
+				inRecord = false
+			}
+		}
+
+		lineStart = err != bufio.ErrBufferFull
+		if err == nil || err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			return info, nil
+		}
+		return info, fmt.Errorf("failed to read DLQ file %s: %w", path, err)
+	}
+}
+
+// signalOfFile returns the signal in a DLQ file name, which follows the file
+// prefix: <prefix>-<signal>-<sequence>-<timestamp>.dlq.
+func signalOfFile(path string, filePrefix string) string {
+	if isUnsignaledFile(path, filePrefix) {
+		return ""
+	}
+	name := strings.TrimPrefix(filepath.Base(path), filePrefix+"-")
+	if i := strings.Index(name, "-"); i > 0 {
+		return name[:i]
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package enhanceddlq
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestInventoryMatchesWrittenRecords(t *testing.T) {
+	storage, fake := newTestStorage(t, nil)
+	base := fake.Now()
+
+	write := func(s *DLQStorage, data string) {
+		t.Helper()
+		if err := s.Write(context.Background(), []byte(data)); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+
+	// Two records a second apart, then one more in the next file
+	write(storage, "first")
+	fake.Advance(time.Second)
+	write(storage, "second")
+	rotate(t, storage)
+	fake.Advance(4 * time.Second)
+	write(storage, "third")
+	rotate(t, storage)
+
+	// A record still being written at the end of a file isn't counted
+	files, err := storage.ListDLQFiles()
+	if err != nil {
+		t.Fatalf("failed to list DLQ files: %v", err)
+	}
+	current := files[len(files)-1]
+	partial := []byte("--- DLQ RECORD START 2 0 HASH:abc\npartial data\n")
+	if err := os.WriteFile(current, partial, 0644); err != nil {
+		t.Fatalf("failed to write partial record: %v", err)
+	}
+
+	inventory, err := storage.ListInventory()
+	if err != nil {
+		t.Fatalf("failed to list inventory: %v", err)
+	}
+	if len(inventory) != 3 {
+		t.Fatalf("expected 3 files, got %+v", inventory)
+	}
+	for i, want := range []struct {
+		records  int
+		earliest time.Time
+		latest   time.Time
+	}{
+		{records: 2, earliest: base, latest: base.Add(time.Second)},
+		{records: 1, earliest: base.Add(5 * time.Second), latest: base.Add(5 * time.Second)},
+		{records: 0},
+	} {
+		info := inventory[i]
+		if info.Path != files[i] || info.Signal != "metrics" {
+			t.Fatalf("expected file %d to be %s for metrics, got %s for %q", i, files[i], info.Path, info.Signal)
+		}
+		if info.Records != want.records || !info.Earliest.Equal(want.earliest) || !info.Latest.Equal(want.latest) {
+			t.Fatalf("expected file %d to hold %d records from %v to %v, got %d from %v to %v",
+				i, want.records, want.earliest, want.latest, info.Records, info.Earliest, info.Latest)
+		}
+		stat, err := os.Stat(info.Path)
+		if err != nil {
+			t.Fatalf("failed to stat %s: %v", info.Path, err)
+		}
+		if info.SizeBytes != stat.Size() {
+			t.Fatalf("expected file %d to be %d bytes, got %d", i, stat.Size(), info.SizeBytes)
+		}
+	}
+
+	// The directory inventory covers every signal, without a running exporter
+	traces, err := NewDLQStorage(storage.config, zap.NewNop(), "traces")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer traces.Shutdown()
+	write(traces, "span")
+	rotate(t, traces)
+
+	all, err := ListInventory(storage.config.Directory, storage.config.FilePrefix)
+	if err != nil {
+		t.Fatalf("failed to list inventory: %v", err)
+	}
+	records := make(map[string]int)
+	for _, info := range all {
+		records[info.Signal] += info.Records
+	}
+	if len(all) != 5 || records["metrics"] != 3 || records["traces"] != 1 {
+		t.Fatalf("expected 3 metrics and 1 traces record in 5 files, got %v in %d files", records, len(all))
+	}
+}
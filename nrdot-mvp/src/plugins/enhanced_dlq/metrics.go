@@ -2,21 +2,39 @@ package enhanceddlq
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/health"
 )
 
 // metricsExporter is the exporter for metrics.
 type metricsExporter struct {
-	logger    *zap.Logger
-	config    *Config
-	storage   *DLQStorage
-	forwarder component.Component // This would be the component to forward replayed data to
+	logger   *zap.Logger
+	config   *Config
+	storage  *DLQStorage
+	upstream *otlpUpstream // Exported to before writing to the DLQ, nil if not configured
+	redactor *redactor     // Redacts attributes before they are written, nil if none are
+
+	// Per-tenant storages, nil unless a partition attribute is configured
+	partitions *partitionedStorage
+
+	// Publishes the DLQ metrics, nil until started
+	metrics *MetricsCollector
+
+	// Publishes the replay state for readiness checks, and the data held
+	// in memory for a degradation manager
+	id                 component.ID
+	unregisterReplay   func()
+	unregisterInFlight func()
+	unregisterAdmin    func()
 }
 
 // newMetricsExporter creates a new metrics exporter.
@@ -25,20 +43,49 @@ func newMetricsExporter(
 	set exporter.CreateSettings,
 	config *Config,
 ) (*metricsExporter, error) {
-	storage, err := NewDLQStorage(config, set.Logger)
+	storage, err := NewDLQStorage(config, set.Logger, "metrics")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create DLQ storage: %w", err)
 	}
 
-	return &metricsExporter{
-		logger:  set.Logger,
-		config:  config,
-		storage: storage,
-	}, nil
+	e := &metricsExporter{
+		id:       set.ID,
+		logger:   set.Logger,
+		config:   config,
+		storage:  storage,
+		upstream: newOTLPUpstream(config.Upstream),
+		redactor: newRedactor(config),
+	}
+
+	if config.PartitionAttribute != "" {
+		e.partitions, err = newPartitionedStorage(config, set.Logger, "metrics", storage)
+		if err != nil {
+			storage.Shutdown()
+			return nil, err
+		}
+	}
+
+	return e, nil
 }
 
 // Start starts the exporter.
 func (e *metricsExporter) Start(ctx context.Context, host component.Host) error {
+	e.unregisterReplay = health.RegisterReplay(e.id.String(), e)
+	e.unregisterInFlight = health.RegisterInFlight(e.id.String(), e)
+
+	e.metrics = NewMetricsCollector(e.logger, e.storage, e.id, "metrics")
+	if err := e.metrics.Start(ctx); err != nil {
+		return err
+	}
+
+	if e.config.AdminEndpoint != "" {
+		unregister, err := registerAdmin(e.config.AdminEndpoint, e.logger, e.id.String(), "metrics", e)
+		if err != nil {
+			return err
+		}
+		e.unregisterAdmin = unregister
+	}
+
 	if e.config.ReplayOnStart {
 		return e.StartReplay(ctx)
 	}
@@ -47,6 +94,23 @@ func (e *metricsExporter) Start(ctx context.Context, host component.Host) error
 
 // Shutdown stops the exporter.
 func (e *metricsExporter) Shutdown(context.Context) error {
+	if e.unregisterReplay != nil {
+		e.unregisterReplay()
+	}
+	if e.unregisterInFlight != nil {
+		e.unregisterInFlight()
+	}
+	if e.unregisterAdmin != nil {
+		e.unregisterAdmin()
+	}
+	if e.metrics != nil {
+		e.metrics.Shutdown()
+	}
+	if e.partitions != nil {
+		if err := e.partitions.shutdown(); err != nil {
+			e.logger.Error("Failed to shut down DLQ partitions", zap.Error(err))
+		}
+	}
 	return e.storage.Shutdown()
 }
 
@@ -58,14 +122,53 @@ func (e *metricsExporter) ConsumeMetrics(ctx context.Context, md pmetric.Metrics
 		return nil
 	}
 
+	// Export upstream first, only persisting the data if that fails
+	if e.upstream != nil {
+		err := e.upstream.ExportMetrics(ctx, md)
+		if err == nil {
+			return nil
+		}
+		e.logger.Debug("Upstream export failed, writing metrics to DLQ", zap.Error(err))
+	}
+
+	// Write each tenant's data to its own partition
+	if e.partitions != nil {
+		var errs []error
+		for value, part := range splitMetricsByPartition(md, e.config.PartitionAttribute) {
+			if err := e.write(ctx, e.partitions.storageFor(value), part); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return writeErrors(e.logger, errs)
+	}
+
+	return e.write(ctx, e.storage, md)
+}
+
+// write serializes metrics and writes them to the DLQ storage.
+func (e *metricsExporter) write(ctx context.Context, storage *DLQStorage, md pmetric.Metrics) error {
+	// Redact attributes so they never reach the disk
+	md = e.redactor.metrics(md)
+
 	// Serialize metrics to bytes
-	serialized, err := serializeMetrics(md)
+	serialized, err := serializeMetrics(md, e.config.SerializationFormat)
 	if err != nil {
 		return fmt.Errorf("failed to serialize metrics: %w", err)
 	}
 
+	// Make sure the record would replay with the exact timestamps it was given
+	if e.config.VerifyTimestamps {
+		if err := verifyMetricTimestamps(md, serialized, e.config.SerializationFormat); err != nil {
+			return consumererror.NewPermanent(fmt.Errorf("metrics would not survive the DLQ intact: %w", err))
+		}
+	}
+
 	// Write to DLQ storage
-	if err := e.storage.Write(ctx, serialized); err != nil {
+	if err := storage.Write(contextWithSignal(ctx, "metrics"), serialized); err != nil {
+		if errors.Is(err, ErrRecordTooLarge) {
+			// Retrying can never succeed for an oversized record
+			return consumererror.NewPermanent(fmt.Errorf("failed to write metrics to DLQ: %w", err))
+		}
 		return fmt.Errorf("failed to write metrics to DLQ: %w", err)
 	}
 
@@ -77,55 +180,159 @@ func (e *metricsExporter) Capabilities() consumer.Capabilities {
 	return consumer.Capabilities{MutatesData: false}
 }
 
-// StartReplay starts the replay process.
+// replayConsumer returns the consumer replaying records to the upstream
+// endpoint, or only verifying them in a shadow replay. Without an upstream,
+// replayed records would have nowhere to go, so only shadow replays start.
+func (e *metricsExporter) replayConsumer(ctx context.Context) (*metricsReplayConsumer, error) {
+	shadow := isShadowReplay(ctx, e.config)
+	if e.upstream == nil && !shadow {
+		return nil, errNoReplayTarget
+	}
+	return &metricsReplayConsumer{logger: e.logger, upstream: e.upstream, shadow: shadow}, nil
+}
+
+// StartReplay starts the replay process, replaying every partition
+// independently when the DLQ is partitioned.
 func (e *metricsExporter) StartReplay(ctx context.Context) error {
-	consumer := &metricsReplayConsumer{
-		logger:    e.logger,
-		forwarder: e.forwarder,
+	consumer, err := e.replayConsumer(ctx)
+	if err != nil {
+		return err
+	}
+	if e.partitions != nil {
+		return e.partitions.startReplay(ctx, consumer, e.config.replayLimit())
 	}
-	return e.storage.StartReplay(ctx, consumer)
+	return e.storage.StartReplay(ctx, consumer, e.config.replayLimit())
+}
+
+// StartFailedReplay replays only the records rejected by earlier replays
+// with capture_replay_failures enabled, on every partition when the DLQ is
+// partitioned.
+func (e *metricsExporter) StartFailedReplay(ctx context.Context) error {
+	consumer, err := e.replayConsumer(ctx)
+	if err != nil {
+		return err
+	}
+	if e.partitions != nil {
+		return e.partitions.startFailedReplay(ctx, consumer)
+	}
+	return e.storage.StartFailedReplay(ctx, consumer)
+}
+
+// StartPartitionReplay replays only the partition for a value of the
+// partition attribute.
+func (e *metricsExporter) StartPartitionReplay(ctx context.Context, value string) error {
+	if e.partitions == nil {
+		return fmt.Errorf("DLQ is not partitioned")
+	}
+	storage, exists := e.partitions.partition(value)
+	if !exists {
+		return fmt.Errorf("no DLQ partition for %q", value)
+	}
+
+	consumer, err := e.replayConsumer(ctx)
+	if err != nil {
+		return err
+	}
+	return storage.StartReplay(ctx, consumer, e.config.replayLimit())
+}
+
+// ReplayFile replays only the records of one DLQ file, which may be in a
+// partition when the DLQ is partitioned.
+func (e *metricsExporter) ReplayFile(ctx context.Context, path string) error {
+	consumer, err := e.replayConsumer(ctx)
+	if err != nil {
+		return err
+	}
+	if e.partitions != nil {
+		return e.partitions.replayFile(ctx, path, consumer)
+	}
+	return e.storage.ReplayFile(ctx, path, consumer)
+}
+
+// Compact merges small DLQ files into larger ones, in every partition when
+// the DLQ is partitioned.
+func (e *metricsExporter) Compact() error {
+	if e.partitions != nil {
+		return e.partitions.compact()
+	}
+	return e.storage.Compact()
+}
+
+// EstimateReplay returns the records and bytes StartReplay would replay and
+// how long that would take, without replaying anything.
+func (e *metricsExporter) EstimateReplay() (ReplayEstimate, error) {
+	if e.partitions != nil {
+		return e.partitions.estimateReplay(e.config.replayLimit())
+	}
+	return e.storage.EstimateReplay(e.config.replayLimit())
+}
+
+// SetReplayCompletedHandler sets the handler called with a summary whenever
+// a replay, of the whole DLQ or of a partition, finishes.
+func (e *metricsExporter) SetReplayCompletedHandler(handler ReplayCompletedHandler) {
+	if e.partitions != nil {
+		e.partitions.setReplayCompletedHandler(handler)
+		return
+	}
+	e.storage.SetReplayCompletedHandler(handler)
+}
+
+// InFlightBytes returns the bytes the DLQ, including its partitions, holds
+// in memory waiting to be written.
+func (e *metricsExporter) InFlightBytes() int64 {
+	if e.partitions != nil {
+		return e.partitions.inFlightBytes()
+	}
+	return e.storage.InFlightBytes()
+}
+
+// IsReplayActive returns whether the DLQ, or any of its partitions, is
+// being replayed.
+func (e *metricsExporter) IsReplayActive() bool {
+	if e.partitions != nil {
+		return e.partitions.replayActive()
+	}
+	return e.storage.IsReplayActive()
 }
 
 // StopReplay stops the replay process.
 func (e *metricsExporter) StopReplay() {
+	if e.partitions != nil {
+		e.partitions.stopReplay()
+		return
+	}
 	e.storage.StopReplay()
 }
 
 // metricsReplayConsumer implements the DLQConsumer interface for metrics.
 type metricsReplayConsumer struct {
-	logger    *zap.Logger
-	forwarder component.Component
+	logger   *zap.Logger
+	upstream *otlpUpstream
+
+	// Deserialize records without forwarding them
+	shadow bool
 }
 
 // ConsumeDLQRecord implements the DLQConsumer interface.
 func (c *metricsReplayConsumer) ConsumeDLQRecord(ctx context.Context, record *DLQRecord) error {
 	// Deserialize the metrics
-	md, err := deserializeMetrics(record.Data)
+	md, err := deserializeMetrics(record.Data, record.Format)
 	if err != nil {
 		return fmt.Errorf("failed to deserialize metrics: %w", err)
 	}
 
-	// Forward to the next component in the pipeline
-	if c.forwarder != nil {
-		if consumer, ok := c.forwarder.(consumer.Metrics); ok {
-			return consumer.ConsumeMetrics(ctx, md)
-		}
+	if c.shadow {
+		c.logger.Debug("Shadow replayed metrics record",
+			zap.Time("timestamp", record.Timestamp),
+			zap.Int("dataPoints", md.DataPointCount()),
+		)
+		return nil
 	}
 
-	c.logger.Warn("No forwarder configured for metrics replay")
-	return nil
-}
-
-// serializeMetrics serializes metrics data to bytes.
-func serializeMetrics(md pmetric.Metrics) ([]byte, error) {
-	// In a real implementation, this would serialize the metrics to a binary format
-	// For simplicity, we'll just return a placeholder
-	return []byte("serialized_metrics_placeholder"), nil
-}
-
-// deserializeMetrics deserializes bytes to metrics data.
-func deserializeMetrics(data []byte) (pmetric.Metrics, error) {
-	// In a real implementation, this would deserialize the bytes to metrics
-	// For simplicity, we'll just return empty metrics
-	return pmetric.NewMetrics(), nil
+	// Send the data back to the upstream endpoint it failed to reach. A
+	// record with nowhere to go must not count as delivered.
+	if c.upstream == nil {
+		return errNoReplayTarget
+	}
+	return c.upstream.ExportMetrics(ContextWithReplay(ctx), md)
 }
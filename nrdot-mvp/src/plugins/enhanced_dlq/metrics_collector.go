@@ -2,12 +2,15 @@ package enhanceddlq
 
 import (
 	"context"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/collector/component"
 	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/promreg"
 )
 
 const (
@@ -16,138 +19,237 @@ const (
 )
 
 // MetricsCollector collects and exposes metrics for the EnhancedDLQ exporter.
+// Its metrics are registered with the default Prometheus registerer, labelled
+// with the exporter's component ID and signal.
 type MetricsCollector struct {
-	logger    *zap.Logger
-	storage   *DLQStorage
-	component component.Component
-	config    *Config
-	registry  *prometheus.Registry
-	
+	logger  *zap.Logger
+	storage *DLQStorage
+	metrics *promreg.Registry
+
 	// Metrics
-	dlqSizeBytes     prometheus.Gauge
-	dlqFilesCount    prometheus.Gauge
-	recordsWritten   prometheus.Counter
-	bytesWritten     prometheus.Counter
-	recordsReplayed  prometheus.Counter
-	bytesReplayed    prometheus.Counter
-	replayRateBytes  prometheus.Gauge
-	replayActive     prometheus.Gauge
-	verificationFail prometheus.Counter
-	
+	dlqSizeBytes    prometheus.Gauge
+	dlqFilesCount   prometheus.Gauge
+	recordsReplayed prometheus.Counter
+	bytesReplayed   prometheus.Counter
+
 	// Update tracking
-	lastUpdateTime time.Time
-	updateMutex    sync.Mutex
+	updateMutex sync.Mutex
+	stopUpdates context.CancelFunc
+	updatesDone chan struct{}
 }
 
-// NewMetricsCollector creates a new metrics collector for the EnhancedDLQ exporter.
+// NewMetricsCollector creates a new metrics collector for the storage of an
+// EnhancedDLQ exporter and registers its metrics.
 func NewMetricsCollector(
 	logger *zap.Logger,
 	storage *DLQStorage,
-	component component.Component,
-	config *Config,
+	id component.ID,
+	signal string,
 ) *MetricsCollector {
-	registry := prometheus.NewRegistry()
-	
+	metrics := promreg.New(logger, prometheus.Labels{
+		"exporter": id.String(),
+		"signal":   signal,
+	})
 	collector := &MetricsCollector{
-		logger:    logger,
-		storage:   storage,
-		component: component,
-		config:    config,
-		registry:  registry,
-		
-		dlqSizeBytes: prometheus.NewGauge(prometheus.GaugeOpts{
+		logger:  logger,
+		storage: storage,
+		metrics: metrics,
+
+		dlqSizeBytes: metrics.Gauge(prometheus.GaugeOpts{
 			Namespace: metricsNamespace,
 			Subsystem: metricsSubsystem,
 			Name:      "size_bytes",
 			Help:      "Total size of the DLQ in bytes",
 		}),
-		
-		dlqFilesCount: prometheus.NewGauge(prometheus.GaugeOpts{
+
+		dlqFilesCount: metrics.Gauge(prometheus.GaugeOpts{
 			Namespace: metricsNamespace,
 			Subsystem: metricsSubsystem,
 			Name:      "files_count",
 			Help:      "Number of DLQ files",
 		}),
-		
-		recordsWritten: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: metricsNamespace,
-			Subsystem: metricsSubsystem,
-			Name:      "records_written_total",
-			Help:      "Total number of records written to the DLQ",
-		}),
-		
-		bytesWritten: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: metricsNamespace,
-			Subsystem: metricsSubsystem,
-			Name:      "bytes_written_total",
-			Help:      "Total number of bytes written to the DLQ",
-		}),
-		
-		recordsReplayed: prometheus.NewCounter(prometheus.CounterOpts{
+
+		recordsReplayed: metrics.Counter(prometheus.CounterOpts{
 			Namespace: metricsNamespace,
 			Subsystem: metricsSubsystem,
 			Name:      "records_replayed_total",
 			Help:      "Total number of records replayed from the DLQ",
 		}),
-		
-		bytesReplayed: prometheus.NewCounter(prometheus.CounterOpts{
+
+		bytesReplayed: metrics.Counter(prometheus.CounterOpts{
 			Namespace: metricsNamespace,
 			Subsystem: metricsSubsystem,
 			Name:      "bytes_replayed_total",
 			Help:      "Total number of bytes replayed from the DLQ",
 		}),
-		
-		replayRateBytes: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Subsystem: metricsSubsystem,
-			Name:      "replay_rate_bytes",
-			Help:      "Current replay rate in bytes per second",
-		}),
-		
-		replayActive: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: metricsNamespace,
-			Subsystem: metricsSubsystem,
-			Name:      "replay_active",
-			Help:      "Whether replay is currently active (0 = inactive, 1 = active)",
-		}),
-		
-		verificationFail: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: metricsNamespace,
-			Subsystem: metricsSubsystem,
-			Name:      "verification_fails_total",
-			Help:      "Total number of SHA-256 verification failures",
-		}),
-		
-		lastUpdateTime: time.Now(),
 	}
-	
-	// Register metrics with registry
-	registry.MustRegister(collector.dlqSizeBytes)
-	registry.MustRegister(collector.dlqFilesCount)
-	registry.MustRegister(collector.recordsWritten)
-	registry.MustRegister(collector.bytesWritten)
-	registry.MustRegister(collector.recordsReplayed)
-	registry.MustRegister(collector.bytesReplayed)
-	registry.MustRegister(collector.replayRateBytes)
-	registry.MustRegister(collector.replayActive)
-	registry.MustRegister(collector.verificationFail)
-	
+
+	// The write and verification totals are read straight from the storage so
+	// they are current at scrape time
+	metrics.CounterFunc(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "records_written_total",
+		Help:      "Total number of records written to the DLQ",
+	}, func() float64 {
+		return float64(storage.WrittenRecords())
+	})
+	metrics.CounterFunc(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "bytes_written_total",
+		Help:      "Total number of bytes written to the DLQ",
+	}, func() float64 {
+		return float64(storage.WrittenBytes())
+	})
+	metrics.CounterFunc(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "verification_fails_total",
+		Help:      "Total number of replayed records that failed SHA-256 verification",
+	}, func() float64 {
+		return float64(storage.VerificationFailures())
+	})
+
+	// So is the state of the write fallback
+	metrics.GaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "fallback_active",
+		Help:      "Whether the DLQ directory is unwritable and the write fallback is engaged (0 = inactive, 1 = active)",
+	}, func() float64 {
+		if storage.fallback.Stats().Active {
+			return 1
+		}
+		return 0
+	})
+	metrics.GaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "fallback_buffered_bytes",
+		Help:      "Bytes held in memory by the write fallback",
+	}, func() float64 {
+		return float64(storage.fallback.Stats().BufferedBytes)
+	})
+
+	// Write failure counters are read straight from the fallback so they are
+	// current at scrape time
+	metrics.CounterFunc(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "write_failures_total",
+		Help:      "Total number of failed writes to the DLQ directory",
+	}, func() float64 {
+		return float64(storage.fallback.Stats().WriteFailures)
+	})
+	metrics.CounterFunc(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "oversized_records_dropped_total",
+		Help:      "Total number of records rejected for exceeding the maximum record size",
+	}, func() float64 {
+		return float64(storage.OversizedDropped())
+	})
+	metrics.CounterFunc(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "deduped_writes_total",
+		Help:      "Total number of writes skipped as duplicates of a recently written record",
+	}, func() float64 {
+		return float64(storage.DedupedWrites())
+	})
+	metrics.CounterFunc(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "fallback_dropped_records_total",
+		Help:      "Total number of records dropped while the DLQ directory was unwritable",
+	}, func() float64 {
+		return float64(storage.fallback.Stats().DroppedItems)
+	})
+	metrics.GaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "memory_buffer_bytes",
+		Help:      "Bytes waiting in the write buffer to be written to disk",
+	}, func() float64 {
+		bytes, _ := storage.MemoryBufferStats()
+		return float64(bytes)
+	})
+	metrics.CounterFunc(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "memory_buffer_dropped_records_total",
+		Help:      "Total number of records dropped because the write buffer was full",
+	}, func() float64 {
+		_, dropped := storage.MemoryBufferStats()
+		return float64(dropped)
+	})
+	metrics.Register(prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "write_duration_seconds"), storage.writeLatency)
+	metrics.GaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "open_files",
+		Help:      "Number of DLQ files currently open for writing or replay",
+	}, func() float64 {
+		return float64(storage.OpenFiles())
+	})
+
+	// The replay rate changes every second with adaptive_replay_rate, so it
+	// is read at scrape time too
+	metrics.GaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "replay_active",
+		Help:      "Whether replay is currently active (0 = inactive, 1 = active)",
+	}, func() float64 {
+		if storage.IsReplayActive() {
+			return 1
+		}
+		return 0
+	})
+	metrics.GaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "replay_rate_bytes",
+		Help:      "Current replay rate limit in bytes per second, 0 while no replay is active",
+	}, func() float64 {
+		if !storage.IsReplayActive() {
+			return 0
+		}
+		return float64(storage.ReplayRate())
+	})
+
 	return collector
 }
 
-// Start starts the metrics collector.
-func (c *MetricsCollector) Start(ctx context.Context) error {
-	// Start a background goroutine to update metrics periodically
+// Start updates the metrics, then keeps them updated until Shutdown.
+func (c *MetricsCollector) Start(context.Context) error {
+	c.updateMetrics()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stopUpdates = cancel
+	c.updatesDone = make(chan struct{})
 	go c.updateMetricsLoop(ctx)
-	
+
 	return nil
 }
 
+// Shutdown stops updating the metrics and unregisters them.
+func (c *MetricsCollector) Shutdown() {
+	if c.stopUpdates != nil {
+		c.stopUpdates()
+		<-c.updatesDone
+	}
+	c.metrics.Unregister()
+}
+
 // updateMetricsLoop periodically updates the metrics.
 func (c *MetricsCollector) updateMetricsLoop(ctx context.Context) {
+	defer close(c.updatesDone)
+
 	ticker := time.NewTicker(15 * time.Second)
 	defer ticker.Stop()
-	
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -162,7 +264,7 @@ func (c *MetricsCollector) updateMetricsLoop(ctx context.Context) {
 func (c *MetricsCollector) updateMetrics() {
 	c.updateMutex.Lock()
 	defer c.updateMutex.Unlock()
-	
+
 	// Update DLQ size and files count
 	totalSize, err := c.getDLQSize()
 	if err != nil {
@@ -170,35 +272,13 @@ func (c *MetricsCollector) updateMetrics() {
 	} else {
 		c.dlqSizeBytes.Set(float64(totalSize))
 	}
-	
+
 	files, err := c.storage.ListDLQFiles()
 	if err != nil {
 		c.logger.Error("Failed to list DLQ files", zap.Error(err))
 	} else {
 		c.dlqFilesCount.Set(float64(len(files)))
 	}
-	
-	// Update write metrics
-	c.recordsWritten.Add(float64(c.storage.totalWrittenItems))
-	c.bytesWritten.Add(float64(c.storage.totalWrittenBytes))
-	
-	// Update replay metrics
-	if c.storage.IsReplayActive() {
-		c.replayActive.Set(1)
-		
-		// Calculate replay rate
-		now := time.Now()
-		elapsed := now.Sub(c.lastUpdateTime).Seconds()
-		if elapsed > 0 {
-			replayRate := float64(c.storage.rateLimiter.bytesPerSecond)
-			c.replayRateBytes.Set(replayRate)
-		}
-	} else {
-		c.replayActive.Set(0)
-		c.replayRateBytes.Set(0)
-	}
-	
-	c.lastUpdateTime = time.Now()
 }
 
 // getDLQSize calculates the total size of all DLQ files.
@@ -207,7 +287,7 @@ func (c *MetricsCollector) getDLQSize() (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	
+
 	var totalSize int64
 	for _, file := range files {
 		info, err := c.getFileInfo(file)
@@ -215,27 +295,16 @@ func (c *MetricsCollector) getDLQSize() (int64, error) {
 			c.logger.Warn("Failed to get file info", zap.Error(err), zap.String("file", file))
 			continue
 		}
-		
+
 		totalSize += info.Size()
 	}
-	
+
 	return totalSize, nil
 }
 
 // getFileInfo gets file info for a file.
-func (c *MetricsCollector) getFileInfo(file string) (interface{}, error) {
-	// In a real implementation, this would use os.Stat to get file info
-	// For simplicity, we'll return a dummy size
-	return struct {
-		Size func() int64
-	}{
-		Size: func() int64 { return 1024 * 1024 }, // 1 MiB
-	}, nil
-}
-
-// RecordVerificationFailure records a SHA-256 verification failure.
-func (c *MetricsCollector) RecordVerificationFailure() {
-	c.verificationFail.Inc()
+func (c *MetricsCollector) getFileInfo(file string) (os.FileInfo, error) {
+	return os.Stat(file)
 }
 
 // RecordReplayedRecord records a replayed record.
@@ -243,8 +312,3 @@ func (c *MetricsCollector) RecordReplayedRecord(recordSize int) {
 	c.recordsReplayed.Inc()
 	c.bytesReplayed.Add(float64(recordSize))
 }
-
-// Registry returns the Prometheus registry.
-func (c *MetricsCollector) Registry() *prometheus.Registry {
-	return c.registry
-}
@@ -0,0 +1,294 @@
+package enhanceddlq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// metricsUpstream is an OTLP/HTTP endpoint counting the metrics batches
+// exported to it.
+type metricsUpstream struct {
+	server  *httptest.Server
+	batches int64
+}
+
+// newMetricsUpstream starts an endpoint accepting every batch, closed when
+// the test ends.
+func newMetricsUpstream(t *testing.T) *metricsUpstream {
+	t.Helper()
+	u := &metricsUpstream{}
+	u.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&u.batches, 1)
+	}))
+	t.Cleanup(u.server.Close)
+	return u
+}
+
+// exporter returns an upstream exporting to the endpoint.
+func (u *metricsUpstream) exporter() *otlpUpstream {
+	return newOTLPUpstream(UpstreamConfig{Endpoint: u.server.URL, TimeoutMs: 1000})
+}
+
+// newStartedMetricsExporter creates and starts a metrics exporter with the
+// given name, writing to a temporary directory. configure, if not nil,
+// adjusts the default configuration first.
+func newStartedMetricsExporter(t *testing.T, name string, configure func(*Config)) *metricsExporter {
+	t.Helper()
+
+	config := CreateDefaultConfig().(*Config)
+	config.Directory = t.TempDir()
+	if configure != nil {
+		configure(config)
+	}
+
+	set := exporter.CreateSettings{
+		ID:                component.NewIDWithName(typeStr, name),
+		TelemetrySettings: component.TelemetrySettings{Logger: zap.NewNop()},
+	}
+	e, err := newMetricsExporter(context.Background(), set, config)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+	if err := e.Start(context.Background(), nil); err != nil {
+		t.Fatalf("failed to start exporter: %v", err)
+	}
+	t.Cleanup(func() {
+		e.Shutdown(context.Background())
+	})
+	return e
+}
+
+// dlqMetricValue returns the value of a DLQ metric published by an exporter,
+// and whether it is registered.
+func dlqMetricValue(t *testing.T, id component.ID, name string) (float64, bool) {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() != "exporter" || label.GetValue() != id.String() {
+					continue
+				}
+				switch {
+				case metric.GetCounter() != nil:
+					return metric.GetCounter().GetValue(), true
+				case metric.GetGauge() != nil:
+					return metric.GetGauge().GetValue(), true
+				default:
+					return float64(metric.GetHistogram().GetSampleCount()), true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+func TestExporterPublishesDLQMetrics(t *testing.T) {
+	e := newStartedMetricsExporter(t, "published", nil)
+
+	for i := 0; i < 3; i++ {
+		if err := e.storage.Write(context.Background(), []byte(fmt.Sprintf("record-%d", i))); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+
+	for name, want := range map[string]float64{
+		"nrdot_mvp_dlq_records_written_total":           3,
+		"nrdot_mvp_dlq_write_duration_seconds":          3,
+		"nrdot_mvp_dlq_open_files":                      1,
+		"nrdot_mvp_dlq_write_failures_total":            0,
+		"nrdot_mvp_dlq_fallback_active":                 0,
+		"nrdot_mvp_dlq_oversized_records_dropped_total": 0,
+		"nrdot_mvp_dlq_deduped_writes_total":            0,
+		"nrdot_mvp_dlq_memory_buffer_bytes":             0,
+		"nrdot_mvp_dlq_replay_active":                   0,
+		"nrdot_mvp_dlq_replay_rate_bytes":               0,
+	} {
+		got, ok := dlqMetricValue(t, e.id, name)
+		if !ok {
+			t.Fatalf("expected %s to be registered", name)
+		}
+		if got != want {
+			t.Fatalf("expected %s to be %v, got %v", name, want, got)
+		}
+	}
+
+	// A second exporter publishes its own series alongside
+	other := newStartedMetricsExporter(t, "other", nil)
+	if _, ok := dlqMetricValue(t, other.id, "nrdot_mvp_dlq_records_written_total"); !ok {
+		t.Fatal("expected the second exporter's metrics to be registered")
+	}
+
+	// Shutting down unregisters the exporter's metrics
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatalf("failed to shut down exporter: %v", err)
+	}
+	if _, ok := dlqMetricValue(t, e.id, "nrdot_mvp_dlq_records_written_total"); ok {
+		t.Fatal("expected the metrics to be unregistered on shutdown")
+	}
+}
+
+func TestExporterPublishesVerificationFailures(t *testing.T) {
+	upstream := newMetricsUpstream(t)
+	e := newStartedMetricsExporter(t, "verification", func(config *Config) {
+		config.VerifySHA256 = true
+		config.Upstream.Endpoint = upstream.server.URL
+	})
+
+	if err := e.storage.Write(context.Background(), []byte("intact")); err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+	rotate(t, e.storage)
+
+	// Corrupt the stored data without touching its length or hash
+	files, err := e.storage.ListDLQFiles()
+	if err != nil {
+		t.Fatalf("failed to list DLQ files: %v", err)
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("failed to read DLQ file: %v", err)
+	}
+	if err := os.WriteFile(files[0], bytes.Replace(content, []byte("intact"), []byte("broken"), 1), 0644); err != nil {
+		t.Fatalf("failed to corrupt DLQ file: %v", err)
+	}
+
+	if err := e.StartReplay(context.Background()); err != nil {
+		t.Fatalf("failed to start replay: %v", err)
+	}
+	waitFor(t, "the replay to finish", func() bool { return !e.storage.IsReplayActive() })
+
+	if got, _ := dlqMetricValue(t, e.id, "nrdot_mvp_dlq_verification_fails_total"); got != 1 {
+		t.Fatalf("expected 1 verification failure to be published, got %v", got)
+	}
+}
+
+func TestShadowReplayIsPerReplay(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.VerifySHA256 = true
+	})
+	storage.SetClock(clock.Real())
+
+	for i := 0; i < 3; i++ {
+		md := pmetric.NewMetrics()
+		md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("requests")
+		data, err := serializeMetrics(md, SerializationFormatProtobuf)
+		if err != nil {
+			t.Fatalf("failed to serialize metrics: %v", err)
+		}
+		if err := storage.Write(context.Background(), data); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+	rotate(t, storage)
+
+	upstream := newMetricsUpstream(t)
+	e := &metricsExporter{
+		logger:   zap.NewNop(),
+		config:   storage.config,
+		storage:  storage,
+		upstream: upstream.exporter(),
+	}
+
+	finished := make(chan ReplaySummary, 1)
+	e.SetReplayCompletedHandler(func(summary ReplaySummary) {
+		finished <- summary
+	})
+	replay := func(ctx context.Context) ReplaySummary {
+		t.Helper()
+		if err := e.StartReplay(ctx); err != nil {
+			t.Fatalf("failed to start replay: %v", err)
+		}
+		select {
+		case summary := <-finished:
+			return summary
+		case <-time.After(5 * time.Second):
+			t.Fatal("replay didn't finish")
+		}
+		return ReplaySummary{}
+	}
+
+	// A shadow replay verifies and counts every record but forwards none
+	summary := replay(ContextWithShadowReplay(context.Background()))
+	if !summary.Shadow || summary.Records != 3 || summary.Failures != 0 {
+		t.Fatalf("expected a shadow replay of 3 records, got %+v", summary)
+	}
+	if got := storage.VerificationFailures(); got != 0 {
+		t.Fatalf("expected every record to pass verification, got %d failures", got)
+	}
+	if got := atomic.LoadInt64(&upstream.batches); got != 0 {
+		t.Fatalf("expected nothing to be forwarded by a shadow replay, got %d batches", got)
+	}
+
+	// The next replay, with the same configuration, forwards every record
+	summary = replay(context.Background())
+	if summary.Shadow || summary.Records != 3 {
+		t.Fatalf("expected a real replay of 3 records, got %+v", summary)
+	}
+	if got := atomic.LoadInt64(&upstream.batches); got != 3 {
+		t.Fatalf("expected 3 batches to be forwarded, got %d", got)
+	}
+}
+
+func TestReplayWithoutUpstreamIsRefused(t *testing.T) {
+	e := newStartedMetricsExporter(t, "no-upstream", nil)
+
+	// Replayed records would have nowhere to go
+	if err := e.StartReplay(context.Background()); !errors.Is(err, errNoReplayTarget) {
+		t.Fatalf("expected the replay to be refused, got %v", err)
+	}
+	if err := e.ReplayFile(context.Background(), e.storage.currentFilePath); !errors.Is(err, errNoReplayTarget) {
+		t.Fatalf("expected the file replay to be refused, got %v", err)
+	}
+	if e.storage.IsReplayActive() {
+		t.Fatal("expected no replay to start")
+	}
+
+	// A shadow replay delivers nothing, so it needs no upstream
+	if err := e.StartReplay(ContextWithShadowReplay(context.Background())); err != nil {
+		t.Fatalf("expected the shadow replay to start, got %v", err)
+	}
+	waitFor(t, "the shadow replay to finish", func() bool { return !e.storage.IsReplayActive() })
+
+	// Nor does a record count as delivered without one
+	data, err := serializeMetrics(testMetrics(), SerializationFormatProtobuf)
+	if err != nil {
+		t.Fatalf("failed to serialize metrics: %v", err)
+	}
+	replayConsumer := &metricsReplayConsumer{logger: zap.NewNop()}
+	record := &DLQRecord{Data: data, Format: SerializationFormatProtobuf}
+	if err := replayConsumer.ConsumeDLQRecord(context.Background(), record); !errors.Is(err, errNoReplayTarget) {
+		t.Fatalf("expected the record to be refused, got %v", err)
+	}
+
+	// Nor may a replay start with the collector
+	config := CreateDefaultConfig().(*Config)
+	config.Directory = t.TempDir()
+	config.ReplayOnStart = true
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected replay_on_start without an upstream to be invalid")
+	}
+}
@@ -0,0 +1,86 @@
+package enhanceddlq
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DLQ file names are "<prefix>-<sequence>-<timestamp>.dlq", where the
+// sequence is a zero-padded counter that keeps increasing across rotations
+// and restarts. Files written before sequences were added have no sequence
+// and sort before all others.
+var (
+	sequencedFileName = regexp.MustCompile(`-(\d{10})-(\d{8}-\d{6}\.\d{3})\.dlq$`)
+	legacyFileName    = regexp.MustCompile(`-(\d{8}-\d{6}\.\d{3})\.dlq$`)
+)
+
+// Files written before the signal was part of the name are
+// "<prefix>-<timestamp>.dlq", with nothing between the prefix and timestamp.
+var unsignaledFileName = regexp.MustCompile(`^\d{8}-\d{6}\.\d{3}\.dlq$`)
+
+// isUnsignaledFile returns whether a file is a DLQ file with the given
+// prefix written before the signal was part of the name.
+func isUnsignaledFile(path string, filePrefix string) bool {
+	name := filepath.Base(path)
+	if !strings.HasPrefix(name, filePrefix+"-") {
+		return false
+	}
+	return unsignaledFileName.MatchString(strings.TrimPrefix(name, filePrefix+"-"))
+}
+
+// dlqFileName returns the name of the DLQ file with the given sequence number
+// and timestamp.
+func dlqFileName(prefix string, sequence int64, timestamp string) string {
+	return fmt.Sprintf("%s-%010d-%s.dlq", prefix, sequence, timestamp)
+}
+
+// parseDLQFileName returns the sequence number and timestamp in a DLQ file
+// name. The sequence is -1 for files without one.
+func parseDLQFileName(path string) (int64, string) {
+	name := filepath.Base(path)
+	if m := sequencedFileName.FindStringSubmatch(name); m != nil {
+		sequence, _ := strconv.ParseInt(m[1], 10, 64)
+		return sequence, m[2]
+	}
+	if m := legacyFileName.FindStringSubmatch(name); m != nil {
+		return -1, m[1]
+	}
+	return -1, ""
+}
+
+// dlqFileLess returns whether file a was created before file b, ordering by
+// sequence number, then timestamp, then name.
+func dlqFileLess(a string, b string) bool {
+	seqA, tsA := parseDLQFileName(a)
+	seqB, tsB := parseDLQFileName(b)
+	if seqA != seqB {
+		return seqA < seqB
+	}
+	if tsA != tsB {
+		return tsA < tsB
+	}
+	return a < b
+}
+
+// sortDLQFiles sorts DLQ files oldest first.
+func sortDLQFiles(files []string) {
+	sort.Slice(files, func(i, j int) bool {
+		return dlqFileLess(files[i], files[j])
+	})
+}
+
+// lastFileSequence returns the highest sequence number among the files, or 0
+// if none has one.
+func lastFileSequence(files []string) int64 {
+	var last int64
+	for _, file := range files {
+		if sequence, _ := parseDLQFileName(file); sequence > last {
+			last = sequence
+		}
+	}
+	return last
+}
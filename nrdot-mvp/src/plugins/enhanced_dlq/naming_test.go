@@ -0,0 +1,117 @@
+package enhanceddlq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestUnsignaledFilesAdoptedByLegacySignal(t *testing.T) {
+	var legacy string
+	metrics, _ := newTestStorage(t, func(config *Config) {
+		legacy = filepath.Join(config.Directory, config.FilePrefix+"-20240101-000000.000.dlq")
+		if err := os.WriteFile(legacy, nil, 0644); err != nil {
+			t.Fatalf("failed to create legacy file: %v", err)
+		}
+	})
+
+	files, err := metrics.ListDLQFiles()
+	if err != nil {
+		t.Fatalf("failed to list DLQ files: %v", err)
+	}
+	if len(files) != 2 || files[0] != legacy {
+		t.Fatalf("expected the legacy file to be listed first, got %v", files)
+	}
+	if !metrics.ownsFile(legacy) {
+		t.Error("expected the legacy file to be adopted for retention")
+	}
+
+	// Neither the legacy file nor the metrics files belong to the traces storage
+	traces, err := NewDLQStorage(metrics.config, zap.NewNop(), "traces")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer traces.Shutdown()
+
+	files, err = traces.ListDLQFiles()
+	if err != nil {
+		t.Fatalf("failed to list DLQ files: %v", err)
+	}
+	if len(files) != 1 || files[0] == legacy {
+		t.Fatalf("expected only the traces storage's own file, got %v", files)
+	}
+}
+
+func TestIsUnsignaledFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		"otel-dlq-20240101-000000.000.dlq":                    true,
+		"otel-dlq-metrics-20240101-000000.000.dlq":            false,
+		"otel-dlq-metrics-0000000001-20240101-000000.000.dlq": false,
+		"other-20240101-000000.000.dlq":                       false,
+	} {
+		if got := isUnsignaledFile(name, "otel-dlq"); got != want {
+			t.Errorf("isUnsignaledFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestRapidRotationNamesFilesInOrder(t *testing.T) {
+	storage, _ := newTestStorage(t, nil)
+
+	// The fake clock never moves, so every file shares its timestamp
+	for i := 0; i < 20; i++ {
+		if err := storage.Write(context.Background(), []byte(fmt.Sprintf("record-%d", i))); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+		rotate(t, storage)
+	}
+
+	// Restarting continues the sequence rather than reusing a name
+	if err := storage.Shutdown(); err != nil {
+		t.Fatalf("failed to shut down storage: %v", err)
+	}
+	restarted, err := NewDLQStorage(storage.config, zap.NewNop(), "metrics")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer restarted.Shutdown()
+	for i := 20; i < 25; i++ {
+		if err := restarted.Write(context.Background(), []byte(fmt.Sprintf("record-%d", i))); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+		rotate(t, restarted)
+	}
+
+	files, err := restarted.ListDLQFiles()
+	if err != nil {
+		t.Fatalf("failed to list DLQ files: %v", err)
+	}
+	seen := make(map[string]bool, len(files))
+	last := int64(-1)
+	for _, file := range files {
+		if seen[file] {
+			t.Fatalf("expected unique file names, got %s twice", file)
+		}
+		seen[file] = true
+		sequence, _ := parseDLQFileName(file)
+		if sequence <= last {
+			t.Fatalf("expected increasing sequences, got %d after %d in %v", sequence, last, files)
+		}
+		last = sequence
+	}
+
+	// Nothing was overwritten, and the files replay in write order
+	records := readAllRecords(t, restarted)
+	if len(records) != 25 {
+		t.Fatalf("expected 25 records, got %d", len(records))
+	}
+	for i, record := range records {
+		if string(record.Data) != fmt.Sprintf("record-%d", i) {
+			t.Fatalf("expected record-%d at position %d, got %s", i, i, record.Data)
+		}
+	}
+}
@@ -0,0 +1,474 @@
+package enhanceddlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// partitionsDirectory is the subdirectory of the DLQ directory holding one
+// directory per partition.
+const partitionsDirectory = "partitions"
+
+// partitionedStorage keeps a separate DLQ storage for each value of the
+// partition resource attribute, so each tenant's data is isolated on disk and
+// can be replayed on its own. Data without the attribute, or with a value
+// beyond MaxPartitions, is kept in the base storage. MaxTotalSizeMiB caps the
+// base storage and the partitions together.
+type partitionedStorage struct {
+	config *Config
+	logger *zap.Logger
+	signal string
+	base   *DLQStorage
+
+	partitions map[string]*DLQStorage
+	mutex      sync.Mutex
+
+	// Size cap shared by the base storage and the partitions, nil if the
+	// size isn't capped
+	budget *sizeBudget
+
+	// Whether shutdown has run, after which no partition is created
+	closed bool
+
+	// Replay completion handler given to every partition
+	replayCompleted ReplayCompletedHandler
+
+	// Whether the partition limit has been logged
+	limitLogged bool
+}
+
+// newPartitionedStorage creates the partitioned storage around the base
+// storage, reopening partitions left on disk by a previous run so they can
+// still be replayed.
+func newPartitionedStorage(config *Config, logger *zap.Logger, signal string, base *DLQStorage) (*partitionedStorage, error) {
+	p := &partitionedStorage{
+		config:     config,
+		logger:     logger,
+		signal:     signal,
+		base:       base,
+		partitions: make(map[string]*DLQStorage),
+	}
+	if config.MaxTotalSizeMiB > 0 {
+		p.budget = newSizeBudget(int64(config.MaxTotalSizeMiB) * 1024 * 1024)
+		base.setSizeBudget(p.budget)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(config.Directory, partitionsDirectory))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list DLQ partitions: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || len(p.partitions) >= config.MaxPartitions {
+			continue
+		}
+		storage, err := p.newPartition(entry.Name())
+		if err != nil {
+			// Stop the partitions already reopened, the caller stops the base
+			p.shutdown()
+			return nil, err
+		}
+		p.partitions[entry.Name()] = storage
+	}
+
+	// Each storage only capped itself while starting, so fit them into the
+	// shared budget now that all of them are known
+	if p.budget != nil {
+		for _, storage := range p.all() {
+			if err := storage.cleanupOldFiles(); err != nil {
+				logger.Error("Failed to clean up DLQ partition", zap.Error(err))
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// newPartition creates the storage for a partition directory.
+func (p *partitionedStorage) newPartition(dirName string) (*DLQStorage, error) {
+	config := *p.config
+	config.Directory = filepath.Join(p.config.Directory, partitionsDirectory, dirName)
+
+	storage, err := NewDLQStorage(&config, p.logger.With(zap.String("partition", dirName)), p.signal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DLQ partition %s: %w", dirName, err)
+	}
+	if p.budget != nil {
+		storage.setSizeBudget(p.budget)
+	}
+	return storage, nil
+}
+
+// storageFor returns the storage for an attribute value, creating it on
+// first use. The base storage is returned for an empty value, once
+// MaxPartitions partitions exist, after shutdown, or if the partition can't
+// be created.
+func (p *partitionedStorage) storageFor(value string) *DLQStorage {
+	if value == "" {
+		return p.base
+	}
+	dirName := partitionDirName(value)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if storage, exists := p.partitions[dirName]; exists {
+		return storage
+	}
+	if p.closed {
+		return p.base
+	}
+
+	if len(p.partitions) >= p.config.MaxPartitions {
+		if !p.limitLogged {
+			p.logger.Warn("DLQ partition limit reached, writing further partitions to the base DLQ",
+				zap.Int("maxPartitions", p.config.MaxPartitions),
+				zap.String("value", value),
+			)
+			p.limitLogged = true
+		}
+		return p.base
+	}
+
+	storage, err := p.newPartition(dirName)
+	if err != nil {
+		p.logger.Error("Failed to create DLQ partition, writing to the base DLQ", zap.Error(err))
+		return p.base
+	}
+	storage.SetReplayCompletedHandler(p.replayCompleted)
+	p.partitions[dirName] = storage
+	return storage
+}
+
+// setReplayCompletedHandler sets the replay completion handler of the base
+// storage and every partition, including those created later.
+func (p *partitionedStorage) setReplayCompletedHandler(handler ReplayCompletedHandler) {
+	p.base.SetReplayCompletedHandler(handler)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.replayCompleted = handler
+	for _, storage := range p.partitions {
+		storage.SetReplayCompletedHandler(handler)
+	}
+}
+
+// partition returns the storage for an attribute value if it exists.
+func (p *partitionedStorage) partition(value string) (*DLQStorage, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	storage, exists := p.partitions[partitionDirName(value)]
+	return storage, exists
+}
+
+// all returns the base storage followed by the partitions in name order.
+func (p *partitionedStorage) all() []*DLQStorage {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	names := make([]string, 0, len(p.partitions))
+	for name := range p.partitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	storages := []*DLQStorage{p.base}
+	for _, name := range names {
+		storages = append(storages, p.partitions[name])
+	}
+	return storages
+}
+
+// startReplay replays the base storage and every partition, each on its own.
+// A storage that can't start, such as one already replaying, doesn't keep the
+// others from replaying; the errors of all of them are returned together.
+func (p *partitionedStorage) startReplay(ctx context.Context, consumer DLQConsumer, limit ReplayLimit) error {
+	var errs []error
+	for _, storage := range p.all() {
+		if err := storage.StartReplay(ctx, consumer, limit); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", storage.config.Directory, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// startFailedReplay replays the failed records of the base storage and
+// every partition, each on its own, carrying on past the storages that can't
+// start as startReplay does.
+func (p *partitionedStorage) startFailedReplay(ctx context.Context, consumer DLQConsumer) error {
+	var errs []error
+	for _, storage := range p.all() {
+		if err := storage.StartFailedReplay(ctx, consumer); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", storage.config.Directory, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// replayFile replays one DLQ file of the base storage or of a partition.
+// Relative paths are relative to the base DLQ directory, so partition files
+// are named by their path under it.
+func (p *partitionedStorage) replayFile(ctx context.Context, path string, consumer DLQConsumer) error {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.config.Directory, path)
+	}
+	for _, storage := range p.all() {
+		err := storage.ReplayFile(ctx, path, consumer)
+		if !errors.Is(err, errNotDLQFile) {
+			return err
+		}
+	}
+	return fmt.Errorf("'%s': %w", path, errNotDLQFile)
+}
+
+// compact compacts the files of the base storage and every partition,
+// carrying on past failures and returning the first.
+func (p *partitionedStorage) compact() error {
+	var firstErr error
+	for _, storage := range p.all() {
+		if err := storage.Compact(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// estimateReplay estimates the replay of the base storage and every
+// partition. Records and bytes add up, while the partitions replay side by
+// side, so the ETA is that of the longest.
+func (p *partitionedStorage) estimateReplay(limit ReplayLimit) (ReplayEstimate, error) {
+	var total ReplayEstimate
+	for _, storage := range p.all() {
+		estimate, err := storage.EstimateReplay(limit)
+		if err != nil {
+			return ReplayEstimate{}, err
+		}
+		total.Records += estimate.Records
+		total.Bytes += estimate.Bytes
+		if estimate.ETASeconds > total.ETASeconds {
+			total.ETASeconds = estimate.ETASeconds
+		}
+	}
+	return total, nil
+}
+
+// replayActive reports whether the base storage or any partition is
+// replaying.
+func (p *partitionedStorage) replayActive() bool {
+	for _, storage := range p.all() {
+		if storage.IsReplayActive() {
+			return true
+		}
+	}
+	return false
+}
+
+// inFlightBytes returns the bytes the base storage and every partition hold
+// in memory.
+func (p *partitionedStorage) inFlightBytes() int64 {
+	var total int64
+	for _, storage := range p.all() {
+		total += storage.InFlightBytes()
+	}
+	return total
+}
+
+// stopReplay stops the replays of the base storage and every partition.
+func (p *partitionedStorage) stopReplay() {
+	for _, storage := range p.all() {
+		storage.StopReplay()
+	}
+}
+
+// shutdown shuts down every partition. The base storage is shut down by its
+// exporter. Data written afterwards goes to the base storage, so no partition
+// is left running.
+func (p *partitionedStorage) shutdown() error {
+	p.mutex.Lock()
+	p.closed = true
+	p.mutex.Unlock()
+
+	var firstErr error
+	for _, storage := range p.all()[1:] {
+		if err := storage.Shutdown(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// writeErrors combines the errors of writing a batch's parts to their
+// partitions. Every part is written even if another fails, so one tenant's
+// failure doesn't keep the others' data out of the DLQ. The combined error is
+// permanent only if every failure is, so a transient failure is retried.
+func writeErrors(logger *zap.Logger, errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	var retryable []error
+	for _, err := range errs {
+		if !consumererror.IsPermanent(err) {
+			retryable = append(retryable, err)
+		}
+	}
+	if len(retryable) == 0 {
+		return consumererror.NewPermanent(errors.Join(errs...))
+	}
+	if len(retryable) < len(errs) {
+		logger.Warn("Some partitions rejected their data permanently, retrying the batch for the rest",
+			zap.Int("permanentErrors", len(errs)-len(retryable)),
+		)
+	}
+	return errors.Join(retryable...)
+}
+
+// sizeBudget is the MaxTotalSizeMiB cap shared by the base storage and the
+// partitions of a partitioned DLQ. Each storage still only removes its own
+// files, trimming itself during cleanup to what the others leave of the
+// budget, but never below an equal share, so a flooding tenant gives up its
+// own oldest files rather than a quiet tenant's.
+type sizeBudget struct {
+	maxBytes int64
+
+	// Size of each storage's files as of its last cleanup
+	sizes map[*DLQStorage]int64
+	mutex sync.Mutex
+}
+
+// newSizeBudget creates a size budget of maxBytes.
+func newSizeBudget(maxBytes int64) *sizeBudget {
+	return &sizeBudget{
+		maxBytes: maxBytes,
+		sizes:    make(map[*DLQStorage]int64),
+	}
+}
+
+// allowance records the size of a storage's files and returns how many bytes
+// of files it may keep.
+func (b *sizeBudget) allowance(storage *DLQStorage, size int64) int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.sizes[storage] = size
+
+	var others int64
+	for s, size := range b.sizes {
+		if s != storage {
+			others += size
+		}
+	}
+
+	share := b.maxBytes / int64(len(b.sizes))
+	if allowed := b.maxBytes - others; allowed > share {
+		return allowed
+	}
+	return share
+}
+
+// update records the size of a storage's files.
+func (b *sizeBudget) update(storage *DLQStorage, size int64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.sizes[storage] = size
+}
+
+// remove takes a storage that has shut down out of the budget.
+func (b *sizeBudget) remove(storage *DLQStorage) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.sizes, storage)
+}
+
+// partitionDirName turns an attribute value into a directory name. Values
+// that aren't safe as a file name are sanitized and suffixed with a hash so
+// distinct values never share a directory.
+func partitionDirName(value string) string {
+	safe := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, value)
+	if safe == value {
+		return value
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(value))
+	return fmt.Sprintf("%s-%08x", safe, h.Sum32())
+}
+
+// resourcePartition returns the partition attribute value of a resource, or
+// an empty string if it doesn't have the attribute.
+func resourcePartition(resource pcommon.Resource, attribute string) string {
+	if v, ok := resource.Attributes().Get(attribute); ok {
+		return v.AsString()
+	}
+	return ""
+}
+
+// splitMetricsByPartition groups resource metrics by their partition value.
+func splitMetricsByPartition(md pmetric.Metrics, attribute string) map[string]pmetric.Metrics {
+	parts := make(map[string]pmetric.Metrics)
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		value := resourcePartition(rms.At(i).Resource(), attribute)
+		part, exists := parts[value]
+		if !exists {
+			part = pmetric.NewMetrics()
+			parts[value] = part
+		}
+		rms.At(i).CopyTo(part.ResourceMetrics().AppendEmpty())
+	}
+	return parts
+}
+
+// splitTracesByPartition groups resource spans by their partition value.
+func splitTracesByPartition(td ptrace.Traces, attribute string) map[string]ptrace.Traces {
+	parts := make(map[string]ptrace.Traces)
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		value := resourcePartition(rss.At(i).Resource(), attribute)
+		part, exists := parts[value]
+		if !exists {
+			part = ptrace.NewTraces()
+			parts[value] = part
+		}
+		rss.At(i).CopyTo(part.ResourceSpans().AppendEmpty())
+	}
+	return parts
+}
+
+// splitLogsByPartition groups resource logs by their partition value.
+func splitLogsByPartition(ld plog.Logs, attribute string) map[string]plog.Logs {
+	parts := make(map[string]plog.Logs)
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		value := resourcePartition(rls.At(i).Resource(), attribute)
+		part, exists := parts[value]
+		if !exists {
+			part = plog.NewLogs()
+			parts[value] = part
+		}
+		rls.At(i).CopyTo(part.ResourceLogs().AppendEmpty())
+	}
+	return parts
+}
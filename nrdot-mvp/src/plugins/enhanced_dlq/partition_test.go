@@ -0,0 +1,106 @@
+package enhanceddlq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap"
+)
+
+// recordCounter counts the records replayed to it.
+type recordCounter struct {
+	mutex   sync.Mutex
+	records int
+}
+
+func (c *recordCounter) ConsumeDLQRecord(context.Context, *DLQRecord) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.records++
+	return nil
+}
+
+func TestSizeBudgetIsShared(t *testing.T) {
+	budget := newSizeBudget(100)
+	quiet, flooding := &DLQStorage{}, &DLQStorage{}
+
+	if got := budget.allowance(quiet, 10); got != 100 {
+		t.Errorf("expected a lone storage to get the whole budget, got %d", got)
+	}
+	// The flooding storage may keep what the quiet one leaves
+	if got := budget.allowance(flooding, 150); got != 90 {
+		t.Errorf("expected the flooding storage to be trimmed to 90, got %d", got)
+	}
+	// The quiet storage keeps at least an equal share
+	if got := budget.allowance(quiet, 10); got != 50 {
+		t.Errorf("expected the quiet storage to keep its share of 50, got %d", got)
+	}
+
+	budget.remove(flooding)
+	if got := budget.allowance(quiet, 10); got != 100 {
+		t.Errorf("expected the whole budget once the other storage is gone, got %d", got)
+	}
+}
+
+func TestStartReplayContinuesPastPartitionError(t *testing.T) {
+	base, _ := newTestStorage(t, func(config *Config) {
+		config.PartitionAttribute = "tenant.id"
+	})
+	partitions, err := newPartitionedStorage(base.config, zap.NewNop(), "metrics", base)
+	if err != nil {
+		t.Fatalf("failed to create partitioned storage: %v", err)
+	}
+	defer partitions.shutdown()
+
+	tenant := partitions.storageFor("tenant-a")
+	for i := 0; i < 3; i++ {
+		if err := tenant.Write(context.Background(), []byte("record")); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+
+	finished := make(chan ReplaySummary, 1)
+	tenant.SetReplayCompletedHandler(func(summary ReplaySummary) {
+		finished <- summary
+	})
+
+	// The base storage refuses to replay while it compacts
+	base.replayMutex.Lock()
+	base.compacting = true
+	base.replayMutex.Unlock()
+
+	consumer := &recordCounter{}
+	if err := partitions.startReplay(context.Background(), consumer, ReplayLimit{}); err == nil {
+		t.Fatal("expected the base storage's error to be returned")
+	}
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the partition to replay despite the base storage's error")
+	}
+	consumer.mutex.Lock()
+	defer consumer.mutex.Unlock()
+	if consumer.records != 3 {
+		t.Fatalf("expected 3 records replayed from the partition, got %d", consumer.records)
+	}
+}
+
+func TestWriteErrorsPermanentOnlyIfAllAre(t *testing.T) {
+	permanent := consumererror.NewPermanent(errors.New("record too large"))
+	transient := errors.New("disk full")
+
+	if err := writeErrors(zap.NewNop(), nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := writeErrors(zap.NewNop(), []error{permanent, transient}); err == nil || consumererror.IsPermanent(err) {
+		t.Fatalf("expected a retryable error when one partition failed transiently, got %v", err)
+	}
+	if err := writeErrors(zap.NewNop(), []error{permanent, permanent}); !consumererror.IsPermanent(err) {
+		t.Fatalf("expected a permanent error when every partition failed permanently, got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package enhanceddlq
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// Ways of redacting an attribute before it is written to the DLQ.
+const (
+	// RedactionModeRemove removes the attribute
+	RedactionModeRemove = "remove"
+	// RedactionModeHash replaces the value with its SHA-256 hash, so equal
+	// values stay equal
+	RedactionModeHash = "hash"
+	// RedactionModeMask replaces the value with asterisks, keeping the first
+	// and last characters, as the pii_masker plugin does
+	RedactionModeMask = "mask"
+)
+
+// redactor redacts the attributes whose keys match any of its patterns.
+type redactor struct {
+	patterns []string
+	mode     string
+}
+
+// newRedactor creates the redactor for the configuration, nil if no
+// attributes are redacted.
+func newRedactor(config *Config) *redactor {
+	if len(config.RedactAttributes) == 0 {
+		return nil
+	}
+	return &redactor{
+		patterns: config.RedactAttributes,
+		mode:     config.RedactionMode,
+	}
+}
+
+// matches returns whether an attribute key is redacted.
+func (r *redactor) matches(key string) bool {
+	for _, pattern := range r.patterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// attributes redacts the matching attributes of a map in place.
+func (r *redactor) attributes(attrs pcommon.Map) {
+	if r.mode == RedactionModeRemove {
+		attrs.RemoveIf(func(key string, _ pcommon.Value) bool {
+			return r.matches(key)
+		})
+		return
+	}
+
+	attrs.Range(func(key string, value pcommon.Value) bool {
+		if r.matches(key) {
+			value.SetStr(r.replacement(value.AsString()))
+		}
+		return true
+	})
+}
+
+// replacement returns what a redacted value is replaced with.
+func (r *redactor) replacement(value string) string {
+	if r.mode == RedactionModeHash {
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}
+	return maskValue(value)
+}
+
+// maskValue replaces a value with asterisks, keeping the first and last
+// characters.
+func maskValue(value string) string {
+	if len(value) <= 2 {
+		return "**"
+	}
+	return value[:1] + strings.Repeat("*", len(value)-2) + value[len(value)-1:]
+}
+
+// metrics returns a copy of the metrics with the resource, scope, data point
+// and exemplar attributes redacted. The metrics passed in are left intact,
+// since the exporter doesn't mutate data.
+func (r *redactor) metrics(md pmetric.Metrics) pmetric.Metrics {
+	if r == nil {
+		return md
+	}
+	redacted := pmetric.NewMetrics()
+	md.CopyTo(redacted)
+
+	exemplars := func(exemplars pmetric.ExemplarSlice) {
+		for i := 0; i < exemplars.Len(); i++ {
+			r.attributes(exemplars.At(i).FilteredAttributes())
+		}
+	}
+
+	rms := redacted.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		r.attributes(rms.At(i).Resource().Attributes())
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			r.attributes(sms.At(j).Scope().Attributes())
+			metrics := sms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+				switch metric.Type() {
+				case pmetric.MetricTypeGauge:
+					dps := metric.Gauge().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						r.attributes(dps.At(l).Attributes())
+						exemplars(dps.At(l).Exemplars())
+					}
+				case pmetric.MetricTypeSum:
+					dps := metric.Sum().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						r.attributes(dps.At(l).Attributes())
+						exemplars(dps.At(l).Exemplars())
+					}
+				case pmetric.MetricTypeHistogram:
+					dps := metric.Histogram().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						r.attributes(dps.At(l).Attributes())
+						exemplars(dps.At(l).Exemplars())
+					}
+				case pmetric.MetricTypeExponentialHistogram:
+					dps := metric.ExponentialHistogram().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						r.attributes(dps.At(l).Attributes())
+						exemplars(dps.At(l).Exemplars())
+					}
+				case pmetric.MetricTypeSummary:
+					dps := metric.Summary().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						r.attributes(dps.At(l).Attributes())
+					}
+				}
+			}
+		}
+	}
+	return redacted
+}
+
+// traces returns a copy of the traces with the resource, scope, span, span
+// event and span link attributes redacted.
+func (r *redactor) traces(td ptrace.Traces) ptrace.Traces {
+	if r == nil {
+		return td
+	}
+	redacted := ptrace.NewTraces()
+	td.CopyTo(redacted)
+
+	rss := redacted.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		r.attributes(rss.At(i).Resource().Attributes())
+		sss := rss.At(i).ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			r.attributes(sss.At(j).Scope().Attributes())
+			spans := sss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				r.attributes(span.Attributes())
+				for l := 0; l < span.Events().Len(); l++ {
+					r.attributes(span.Events().At(l).Attributes())
+				}
+				for l := 0; l < span.Links().Len(); l++ {
+					r.attributes(span.Links().At(l).Attributes())
+				}
+			}
+		}
+	}
+	return redacted
+}
+
+// logs returns a copy of the logs with the resource, scope and log record
+// attributes redacted.
+func (r *redactor) logs(ld plog.Logs) plog.Logs {
+	if r == nil {
+		return ld
+	}
+	redacted := plog.NewLogs()
+	ld.CopyTo(redacted)
+
+	rls := redacted.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		r.attributes(rls.At(i).Resource().Attributes())
+		sls := rls.At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			r.attributes(sls.At(j).Scope().Attributes())
+			records := sls.At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				r.attributes(records.At(k).Attributes())
+			}
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,78 @@
+package enhanceddlq
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+func TestRedactedAttributesNeverWritten(t *testing.T) {
+	const email = "alice@example.com"
+
+	for _, tc := range []struct {
+		mode     string
+		expected string // The redacted value written, empty if removed
+	}{
+		{mode: RedactionModeRemove},
+		{mode: RedactionModeHash, expected: "ff8d9819fc0e12bf0d24892e45987e249a28dce836a85cad60e28eaaa8c6d976"},
+		{mode: RedactionModeMask, expected: "a***************m"},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			storage, _ := newTestStorage(t, func(config *Config) {
+				config.RedactAttributes = []string{"user.*"}
+				config.RedactionMode = tc.mode
+			})
+			e := &metricsExporter{
+				logger:   zap.NewNop(),
+				config:   storage.config,
+				storage:  storage,
+				redactor: newRedactor(storage.config),
+			}
+
+			md := pmetric.NewMetrics()
+			rm := md.ResourceMetrics().AppendEmpty()
+			rm.Resource().Attributes().PutStr("service.name", "checkout")
+			rm.Resource().Attributes().PutStr("user.email", email)
+			rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("requests")
+			if err := e.ConsumeMetrics(context.Background(), md); err != nil {
+				t.Fatalf("failed to consume metrics: %v", err)
+			}
+			rotate(t, storage)
+
+			// The data passed in is left intact
+			if value, _ := rm.Resource().Attributes().Get("user.email"); value.AsString() != email {
+				t.Fatalf("expected the consumed metrics to keep the email, got %q", value.AsString())
+			}
+
+			records := readAllRecords(t, storage)
+			if len(records) != 1 {
+				t.Fatalf("expected 1 record, got %d", len(records))
+			}
+			if bytes.Contains(records[0].Data, []byte(email)) {
+				t.Fatal("expected the email to be absent from the written record")
+			}
+
+			written, err := deserializeMetrics(records[0].Data, records[0].Format)
+			if err != nil {
+				t.Fatalf("failed to deserialize record: %v", err)
+			}
+			attrs := written.ResourceMetrics().At(0).Resource().Attributes()
+			if service, _ := attrs.Get("service.name"); service.AsString() != "checkout" {
+				t.Fatalf("expected service.name to survive, got %q", service.AsString())
+			}
+			value, ok := attrs.Get("user.email")
+			if tc.expected == "" {
+				if ok {
+					t.Fatalf("expected user.email to be removed, got %q", value.AsString())
+				}
+				return
+			}
+			if value.AsString() != tc.expected {
+				t.Fatalf("expected user.email to be written as %q, got %q", tc.expected, value.AsString())
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package enhanceddlq
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// orderedReplay returns whether replays read files in parallel and deliver
+// records in timestamp order through a single worker.
+func (s *DLQStorage) orderedReplay() bool {
+	return s.config.PreserveOrder && s.config.ReplayConcurrency > 1
+}
+
+// checkpointLess returns whether position a comes before position b in the
+// order a replay reads records in.
+func checkpointLess(a replayCheckpoint, b replayCheckpoint) bool {
+	if a.pass != b.pass {
+		return a.pass < b.pass
+	}
+	if a.file != b.file {
+		return dlqFileLess(a.file, b.file)
+	}
+	return a.offset < b.offset
+}
+
+// earliestCheckpoint returns the earlier of two positions, either of which
+// may be nil.
+func earliestCheckpoint(a *replayCheckpoint, b *replayCheckpoint) *replayCheckpoint {
+	if a == nil {
+		return b
+	}
+	if b == nil || checkpointLess(*a, *b) {
+		return a
+	}
+	return b
+}
+
+// replayHeap orders records read ahead by timestamp, then by position.
+type replayHeap []replayItem
+
+func (h replayHeap) Len() int { return len(h) }
+
+func (h replayHeap) Less(i, j int) bool {
+	if !h[i].record.Timestamp.Equal(h[j].record.Timestamp) {
+		return h[i].record.Timestamp.Before(h[j].record.Timestamp)
+	}
+	return checkpointLess(h[i].position, h[j].position)
+}
+
+func (h replayHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *replayHeap) Push(x interface{}) { *h = append(*h, x.(replayItem)) }
+
+func (h *replayHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// replayPassOrdered reads the files of a pass with up to ReplayConcurrency
+// readers, holds up to ReorderBufferRecords records read ahead and sends
+// them to the channel earliest timestamp first. It stops early once the
+// budget is exhausted or the replay is stopped, returning the earliest
+// position not yet sent, and whether it stopped early. Records after that
+// position that were already sent are delivered again by the next replay.
+func (s *DLQStorage) replayPassOrdered(ctx context.Context, files []string, passIndex int, pass replayPass, checkpoint *replayCheckpoint, recordCh chan<- replayItem, budget *replayBudget, stop <-chan struct{}) (*replayCheckpoint, bool, error) {
+	// Files left to read in this pass, and where each starts
+	var starts []replayCheckpoint
+	for _, file := range files {
+		if skip, offset := checkpoint.skip(passIndex, file); !skip {
+			starts = append(starts, replayCheckpoint{pass: passIndex, file: file, offset: offset})
+		}
+	}
+	if len(starts) == 0 {
+		return nil, false, nil
+	}
+
+	// Where each file's reader stopped, nil once the file has been read to
+	// the end. Files no reader got to keep their starting position.
+	stopped := make([]*replayCheckpoint, len(starts))
+	for i := range starts {
+		start := starts[i]
+		stopped[i] = &start
+	}
+
+	readCh := make(chan replayItem, s.config.ReorderBufferRecords)
+	readStop := make(chan struct{})
+	var next int
+	var nextMutex sync.Mutex
+	var readers sync.WaitGroup
+
+	for i := 0; i < s.config.ReplayConcurrency; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				nextMutex.Lock()
+				index := next
+				next++
+				nextMutex.Unlock()
+				if index >= len(starts) {
+					return
+				}
+
+				// Readers aren't limited, the budget applies to records sent on
+				start := starts[index]
+				offset, halted, err := s.replayFile(ctx, start.file, passIndex, readCh, pass, start.offset, &replayBudget{}, readStop)
+				if err != nil {
+					s.logger.Error("Failed to replay DLQ file",
+						zap.Error(err),
+						zap.String("file", start.file),
+					)
+				}
+				if halted {
+					stopped[index] = &replayCheckpoint{pass: passIndex, file: start.file, offset: offset}
+					return
+				}
+				stopped[index] = nil
+			}
+		}()
+	}
+	go func() {
+		readers.Wait()
+		close(readCh)
+	}()
+
+	buffer := &replayHeap{}
+	reading := true
+	halted := false
+	var cancelled error
+	for !halted && cancelled == nil && (reading || buffer.Len() > 0) {
+		if budget.exhausted() {
+			halted = true
+			break
+		}
+
+		// Read ahead until the buffer is full or every file has been read
+		if reading && buffer.Len() < s.config.ReorderBufferRecords {
+			select {
+			case item, ok := <-readCh:
+				if !ok {
+					reading = false
+					continue
+				}
+				heap.Push(buffer, item)
+			case <-stop:
+				halted = true
+			case <-ctx.Done():
+				cancelled = ctx.Err()
+			}
+			continue
+		}
+
+		earliest := (*buffer)[0]
+		select {
+		case recordCh <- earliest:
+			heap.Pop(buffer)
+			budget.consume(len(earliest.record.Data))
+		case <-stop:
+			halted = true
+		case <-ctx.Done():
+			cancelled = ctx.Err()
+		}
+	}
+
+	// Stop the readers, keeping whatever they had read
+	close(readStop)
+	for item := range readCh {
+		heap.Push(buffer, item)
+	}
+
+	if cancelled != nil {
+		return nil, false, cancelled
+	}
+	if !halted {
+		return nil, false, nil
+	}
+
+	var resume *replayCheckpoint
+	for _, position := range stopped {
+		resume = earliestCheckpoint(resume, position)
+	}
+	for i := range *buffer {
+		resume = earliestCheckpoint(resume, &(*buffer)[i].position)
+	}
+
+	// Everything in the pass was sent, so resume with the next pass
+	if resume == nil {
+		resume = &replayCheckpoint{pass: passIndex + 1}
+	}
+	return resume, true, nil
+}
@@ -0,0 +1,65 @@
+package enhanceddlq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// timestampCollector collects the timestamps of the records it consumes.
+type timestampCollector struct {
+	mutex      sync.Mutex
+	timestamps []time.Time
+}
+
+func (c *timestampCollector) ConsumeDLQRecord(_ context.Context, record *DLQRecord) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.timestamps = append(c.timestamps, record.Timestamp)
+	return nil
+}
+
+func TestPreserveOrderDeliversRecordsInTimestampOrder(t *testing.T) {
+	const files, perFile = 4, 25
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.ReplayConcurrency = 4
+		config.PreserveOrder = true
+		config.ReorderBufferRecords = files * perFile
+	})
+
+	// Each file's records interleave in time with every other file's, so
+	// reading the files in parallel mixes up their order
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for f := 0; f < files; f++ {
+		for i := 0; i < perFile; i++ {
+			storage.SetClock(clock.NewFakeClock(base.Add(time.Duration(i*files+f) * time.Second)))
+			if err := storage.Write(context.Background(), []byte(fmt.Sprintf("file-%d-%d", f, i))); err != nil {
+				t.Fatalf("failed to write record: %v", err)
+			}
+		}
+		rotate(t, storage)
+	}
+
+	storage.SetClock(clock.Real())
+	collector := &timestampCollector{}
+	if err := storage.StartReplay(context.Background(), collector, ReplayLimit{}); err != nil {
+		t.Fatalf("failed to start replay: %v", err)
+	}
+	waitFor(t, "the replay to finish", func() bool { return !storage.IsReplayActive() })
+
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	if len(collector.timestamps) != files*perFile {
+		t.Fatalf("expected %d records, got %d", files*perFile, len(collector.timestamps))
+	}
+	for i := 1; i < len(collector.timestamps); i++ {
+		if collector.timestamps[i].Before(collector.timestamps[i-1]) {
+			t.Fatalf("expected records in timestamp order, got %v after %v at position %d",
+				collector.timestamps[i], collector.timestamps[i-1], i)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package enhanceddlq
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errReplayStopped is returned when a replay is stopped while waiting for a
+// replay slot.
+var errReplayStopped = errors.New("replay stopped")
+
+// replaySlots bounds the replays running at once against each DLQ directory,
+// so the metrics, traces and logs exporters sharing a directory don't all
+// replay together and saturate the disk and the backend.
+var (
+	replaySlots      = make(map[string]chan struct{})
+	replaySlotsMutex sync.Mutex
+)
+
+// acquireReplaySlot waits for a replay slot for the directory and returns a
+// function that releases it. The number of slots is fixed by the first
+// replay against the directory. It returns an error if the context is done
+// or the replay is stopped before a slot frees up.
+func acquireReplaySlot(ctx context.Context, stop <-chan struct{}, directory string, maxConcurrent int) (func(), error) {
+	replaySlotsMutex.Lock()
+	slots, exists := replaySlots[directory]
+	if !exists {
+		slots = make(chan struct{}, maxConcurrent)
+		replaySlots[directory] = slots
+	}
+	replaySlotsMutex.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-stop:
+		return nil, errReplayStopped
+	}
+}
@@ -0,0 +1,73 @@
+package enhanceddlq
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// concurrencyConsumer takes a while to consume each record, tracking the most
+// records consumed at once.
+type concurrencyConsumer struct {
+	active   atomic.Int64
+	peak     atomic.Int64
+	consumed atomic.Int64
+}
+
+func (c *concurrencyConsumer) ConsumeDLQRecord(context.Context, *DLQRecord) error {
+	active := c.active.Add(1)
+	defer c.active.Add(-1)
+	for {
+		peak := c.peak.Load()
+		if active <= peak || c.peak.CompareAndSwap(peak, active) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	c.consumed.Add(1)
+	return nil
+}
+
+func TestReplaysBoundedAcrossExporters(t *testing.T) {
+	metrics, _ := newTestStorage(t, func(config *Config) {
+		config.MaxConcurrentReplays = 1
+		config.ReplayConcurrency = 1
+	})
+
+	// The three signals share the directory
+	storages := []*DLQStorage{metrics}
+	for _, signal := range []string{"traces", "logs"} {
+		storage, err := NewDLQStorage(metrics.config, zap.NewNop(), signal)
+		if err != nil {
+			t.Fatalf("failed to create %s storage: %v", signal, err)
+		}
+		t.Cleanup(func() { storage.Shutdown() })
+		storages = append(storages, storage)
+	}
+	for _, storage := range storages {
+		storage.SetClock(clock.Real())
+		writeReplayRecords(t, storage, 2)
+	}
+
+	consumer := &concurrencyConsumer{}
+	for _, storage := range storages {
+		if err := storage.StartReplay(context.Background(), consumer, ReplayLimit{}); err != nil {
+			t.Fatalf("failed to start replay: %v", err)
+		}
+	}
+	for _, storage := range storages {
+		waitFor(t, "the replays to finish", func() bool { return !storage.IsReplayActive() })
+	}
+
+	if consumed := consumer.consumed.Load(); consumed != 6 {
+		t.Fatalf("expected all 6 records to be replayed, got %d", consumed)
+	}
+	if peak := consumer.peak.Load(); peak != 1 {
+		t.Fatalf("expected at most 1 replay at a time, got %d records consumed at once", peak)
+	}
+}
@@ -0,0 +1,97 @@
+package enhanceddlq
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Outcomes of a replay reported in its summary.
+const (
+	ReplayOutcomeCompleted = "completed"
+	ReplayOutcomeStopped   = "stopped"
+	ReplayOutcomeCancelled = "cancelled"
+)
+
+// ReplaySummary describes a finished replay.
+type ReplaySummary struct {
+	// Directory of the storage that was replayed
+	Directory string
+
+	// How the replay ended: completed, stopped (by StopReplay or the replay
+	// limit, to be resumed by the next replay) or cancelled (by its context)
+	Outcome string
+
+	// Records and bytes consumed successfully, and records that failed
+	Records  int64
+	Bytes    int64
+	Failures int64
+
+	// Whether records were only verified and deserialized, not forwarded
+	Shadow bool
+
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// ReplayCompletedHandler is called once a replay has finished.
+type ReplayCompletedHandler func(summary ReplaySummary)
+
+// replayTotals counts what a replay's workers consumed.
+type replayTotals struct {
+	records  int64
+	bytes    int64
+	failures int64
+}
+
+// record counts a consumed record.
+func (t *replayTotals) record(size int, err error) {
+	if err != nil {
+		atomic.AddInt64(&t.failures, 1)
+		return
+	}
+	atomic.AddInt64(&t.records, 1)
+	atomic.AddInt64(&t.bytes, int64(size))
+}
+
+// SetReplayCompletedHandler sets the handler called with a summary whenever
+// a replay finishes, so external systems know when to resume normal
+// operation.
+func (s *DLQStorage) SetReplayCompletedHandler(handler ReplayCompletedHandler) {
+	s.replayMutex.Lock()
+	defer s.replayMutex.Unlock()
+	s.replayCompleted = handler
+}
+
+// notifyReplayCompleted logs the summary of a finished replay and passes it
+// to the handler, if set. The workers must have exited.
+func (s *DLQStorage) notifyReplayCompleted(outcome string, startedAt time.Time, totals *replayTotals, shadow bool) {
+	summary := ReplaySummary{
+		Directory: s.config.Directory,
+		Outcome:   outcome,
+		Records:   atomic.LoadInt64(&totals.records),
+		Bytes:     atomic.LoadInt64(&totals.bytes),
+		Failures:  atomic.LoadInt64(&totals.failures),
+		Shadow:    shadow,
+		StartedAt: startedAt,
+		Duration:  s.getClock().Now().Sub(startedAt),
+	}
+
+	s.logger.Info("DLQ replay finished",
+		zap.String("outcome", summary.Outcome),
+		zap.Int64("records", summary.Records),
+		zap.Int64("bytes", summary.Bytes),
+		zap.Int64("failures", summary.Failures),
+		zap.Bool("shadow", summary.Shadow),
+		zap.Duration("duration", summary.Duration),
+	)
+
+	s.replayMutex.Lock()
+	handler := s.replayCompleted
+	s.replayMutex.Unlock()
+
+	if handler != nil {
+		handler(summary)
+	}
+}
@@ -0,0 +1,57 @@
+package enhanceddlq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+func TestReplayCompletedHandlerReportsTotals(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.ReplayConcurrency = 1
+	})
+	storage.SetClock(clock.Real())
+	writeReplayRecords(t, storage, 5)
+
+	summaries := make(chan ReplaySummary, 1)
+	storage.SetReplayCompletedHandler(func(summary ReplaySummary) {
+		summaries <- summary
+	})
+
+	consumer := &rejectingConsumer{reject: map[string]bool{"record-2": true}}
+	before := time.Now()
+	if err := storage.StartReplay(context.Background(), consumer, ReplayLimit{}); err != nil {
+		t.Fatalf("failed to start replay: %v", err)
+	}
+
+	var summary ReplaySummary
+	select {
+	case summary = <-summaries:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the handler to be called once the replay finished")
+	}
+
+	if summary.Outcome != ReplayOutcomeCompleted || summary.Shadow {
+		t.Fatalf("expected a completed replay, got %s (shadow %v)", summary.Outcome, summary.Shadow)
+	}
+	if summary.Directory != storage.config.Directory {
+		t.Fatalf("expected the summary to name %s, got %s", storage.config.Directory, summary.Directory)
+	}
+	if got := consumer.received(); summary.Records != int64(len(got)) || summary.Records != 4 {
+		t.Fatalf("expected 4 records replayed, got %d with %v consumed", summary.Records, got)
+	}
+	if want := int64(4 * len("record-0")); summary.Bytes != want {
+		t.Fatalf("expected %d bytes replayed, got %d", want, summary.Bytes)
+	}
+	if summary.Failures != 1 {
+		t.Fatalf("expected 1 failure, got %d", summary.Failures)
+	}
+	if summary.StartedAt.Before(before) || summary.Duration < 0 || summary.StartedAt.Add(summary.Duration).After(time.Now()) {
+		t.Fatalf("unexpected start %v and duration %v", summary.StartedAt, summary.Duration)
+	}
+	if storage.IsReplayActive() {
+		t.Fatal("expected the replay to be inactive once the handler is called")
+	}
+}
@@ -0,0 +1,48 @@
+package enhanceddlq
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkDirectory verifies the DLQ directory is usable before the exporter
+// starts, so a misconfigured mount fails at startup rather than on the first
+// write under load. It writes and removes a probe file, then checks there is
+// room for at least one full DLQ file.
+func checkDirectory(directory string, fileSizeLimitMiB int) error {
+	// The probe name doesn't match the DLQ file pattern, so a leftover probe
+	// is never replayed
+	probePath := filepath.Join(directory, fmt.Sprintf(".probe-%d", os.Getpid()))
+
+	probe, err := os.OpenFile(probePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("DLQ directory %s is not writable: %w", directory, err)
+	}
+	_, err = probe.Write([]byte("probe"))
+	if err == nil {
+		err = probe.Sync()
+	}
+	if closeErr := probe.Close(); err == nil {
+		err = closeErr
+	}
+	if removeErr := os.Remove(probePath); err == nil {
+		err = removeErr
+	}
+	if err != nil {
+		return fmt.Errorf("DLQ directory %s is not writable: %w", directory, err)
+	}
+
+	// Check the free space where the platform reports it
+	free, ok, err := availableBytes(directory)
+	if err != nil {
+		return fmt.Errorf("failed to check free space in DLQ directory %s: %w", directory, err)
+	}
+	required := int64(fileSizeLimitMiB) * 1024 * 1024
+	if ok && free < required {
+		return fmt.Errorf("DLQ directory %s has %d MiB free, less than file_size_limit_mib (%d MiB)",
+			directory, free/(1024*1024), fileSizeLimitMiB)
+	}
+
+	return nil
+}
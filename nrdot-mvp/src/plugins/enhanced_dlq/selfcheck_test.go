@@ -0,0 +1,49 @@
+package enhanceddlq
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSelfCheckFailsOnReadOnlyDirectory(t *testing.T) {
+	config := CreateDefaultConfig().(*Config)
+	config.Directory = t.TempDir()
+	defer makeUnwritable(t, config.Directory)()
+
+	err := checkDirectory(config.Directory, config.FileSizeLimitMiB)
+	if err == nil || !strings.Contains(err.Error(), "not writable") {
+		t.Fatalf("expected the directory to be reported unwritable, got %v", err)
+	}
+
+	// The storage refuses to start rather than failing on its first write
+	if _, err := NewDLQStorage(config, zap.NewNop(), "metrics"); err == nil {
+		t.Fatal("expected the storage to fail on a read-only directory")
+	}
+}
+
+func TestSelfCheckPassesOnWritableDirectory(t *testing.T) {
+	directory := t.TempDir()
+	if err := checkDirectory(directory, 1); err != nil {
+		t.Fatalf("expected the directory to pass the self-check, got %v", err)
+	}
+
+	// The probe file is removed
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected the probe to be removed, found %d entries", len(entries))
+	}
+
+	// A file size limit beyond the free space fails where it is reported
+	if _, ok, _ := availableBytes(directory); ok {
+		err := checkDirectory(directory, 1<<40)
+		if err == nil || !strings.Contains(err.Error(), "free") {
+			t.Fatalf("expected the directory to be reported nearly full, got %v", err)
+		}
+	}
+}
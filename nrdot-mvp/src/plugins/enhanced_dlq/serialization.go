@@ -46,92 +46,53 @@ func deserializeHeader(data []byte) (byte, time.Time, uint64, error) {
 	if len(data) < HeaderSize {
 		return 0, time.Time{}, 0, errors.New("data too short for header")
 	}
-	
+
 	recordType := data[0]
 	timestamp := time.Unix(0, int64(binary.BigEndian.Uint64(data[1:9])))
 	dataSize := binary.BigEndian.Uint64(data[9:17])
-	
+
 	return recordType, timestamp, dataSize, nil
 }
 
-// SerializeMetrics serializes metrics to bytes.
+// SerializeMetrics serializes metrics to a protobuf record, keeping every
+// timestamp exactly.
 func (s *Serializer) SerializeMetrics(md pmetric.Metrics) ([]byte, error) {
-	// Create buffer for the entire record
-	var buf bytes.Buffer
-	
-	// Placeholder for actual serialization in a real implementation
-	// In a real implementation, this would use Protocol Buffers or a similar format
-	// For simplicity, we'll use a mock implementation
-	
-	// Write metrics data size as placeholder
-	dataSize := uint64(1024) // Placeholder size
-	
-	// Write header
-	header := serializeHeader(RecordTypeMetrics, time.Now(), dataSize)
-	if _, err := buf.Write(header); err != nil {
-		return nil, fmt.Errorf("failed to write header: %w", err)
-	}
-	
-	// Write metrics data (placeholder)
-	mockData := make([]byte, dataSize)
-	if _, err := buf.Write(mockData); err != nil {
+	payload, err := serializeMetrics(md, SerializationFormatProtobuf)
+	if err != nil {
 		return nil, fmt.Errorf("failed to write metrics data: %w", err)
 	}
-	
-	return buf.Bytes(), nil
+	return serializeRecord(RecordTypeMetrics, payload)
 }
 
-// SerializeTraces serializes traces to bytes.
+// SerializeTraces serializes traces to a protobuf record, keeping every
+// timestamp exactly.
 func (s *Serializer) SerializeTraces(td ptrace.Traces) ([]byte, error) {
-	// Create buffer for the entire record
-	var buf bytes.Buffer
-	
-	// Placeholder for actual serialization in a real implementation
-	// In a real implementation, this would use Protocol Buffers or a similar format
-	// For simplicity, we'll use a mock implementation
-	
-	// Write traces data size as placeholder
-	dataSize := uint64(1024) // Placeholder size
-	
-	// Write header
-	header := serializeHeader(RecordTypeTraces, time.Now(), dataSize)
-	if _, err := buf.Write(header); err != nil {
-		return nil, fmt.Errorf("failed to write header: %w", err)
-	}
-	
-	// Write traces data (placeholder)
-	mockData := make([]byte, dataSize)
-	if _, err := buf.Write(mockData); err != nil {
+	payload, err := serializeTraces(td, SerializationFormatProtobuf)
+	if err != nil {
 		return nil, fmt.Errorf("failed to write traces data: %w", err)
 	}
-	
-	return buf.Bytes(), nil
+	return serializeRecord(RecordTypeTraces, payload)
 }
 
-// SerializeLogs serializes logs to bytes.
+// SerializeLogs serializes logs to a protobuf record, keeping every
+// timestamp exactly.
 func (s *Serializer) SerializeLogs(ld plog.Logs) ([]byte, error) {
-	// Create buffer for the entire record
-	var buf bytes.Buffer
-	
-	// Placeholder for actual serialization in a real implementation
-	// In a real implementation, this would use Protocol Buffers or a similar format
-	// For simplicity, we'll use a mock implementation
-	
-	// Write logs data size as placeholder
-	dataSize := uint64(1024) // Placeholder size
-	
-	// Write header
-	header := serializeHeader(RecordTypeLogs, time.Now(), dataSize)
-	if _, err := buf.Write(header); err != nil {
-		return nil, fmt.Errorf("failed to write header: %w", err)
-	}
-	
-	// Write logs data (placeholder)
-	mockData := make([]byte, dataSize)
-	if _, err := buf.Write(mockData); err != nil {
+	payload, err := serializeLogs(ld, SerializationFormatProtobuf)
+	if err != nil {
 		return nil, fmt.Errorf("failed to write logs data: %w", err)
 	}
-	
+	return serializeRecord(RecordTypeLogs, payload)
+}
+
+// serializeRecord prefixes a payload with its record header.
+func serializeRecord(recordType byte, payload []byte) ([]byte, error) {
+	if len(payload) > MaxRecordSize {
+		return nil, fmt.Errorf("record size too large: %d > %d", len(payload), MaxRecordSize)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(serializeHeader(recordType, time.Now(), uint64(len(payload))))
+	buf.Write(payload)
 	return buf.Bytes(), nil
 }
 
@@ -140,90 +101,110 @@ func (d *Deserializer) DeserializeRecord(data []byte) (*DLQRecord, error) {
 	if len(data) < HeaderSize {
 		return nil, errors.New("data too short for header")
 	}
-	
+
 	// Deserialize header
 	recordType, timestamp, dataSize, err := deserializeHeader(data)
 	if err != nil {
 		return nil, err
 	}
-	
+
+	// Check if the record type is known
+	switch recordType {
+	case RecordTypeMetrics, RecordTypeTraces, RecordTypeLogs:
+	default:
+		return nil, fmt.Errorf("unknown record type: %d", recordType)
+	}
+
 	// Check if data size is valid
 	if dataSize > MaxRecordSize {
 		return nil, fmt.Errorf("record size too large: %d > %d", dataSize, MaxRecordSize)
 	}
-	
+
 	// Check if data size matches expected size
 	if uint64(len(data)-HeaderSize) != dataSize {
 		return nil, fmt.Errorf("data size mismatch: expected %d, got %d", dataSize, len(data)-HeaderSize)
 	}
-	
+
 	// Create DLQ record
 	record := &DLQRecord{
 		Timestamp: timestamp,
 		Data:      data[HeaderSize:],
 		// Hash is set elsewhere
 	}
-	
+
 	return record, nil
 }
 
-// DeserializeMetrics deserializes metrics from bytes.
+// DeserializeMetrics deserializes metrics from the data of a record.
 func (d *Deserializer) DeserializeMetrics(data []byte) (pmetric.Metrics, error) {
-	// In a real implementation, this would deserialize the bytes to metrics
-	// For simplicity, we'll just return empty metrics
-	return pmetric.NewMetrics(), nil
+	return deserializeMetrics(data, SerializationFormatProtobuf)
 }
 
-// DeserializeTraces deserializes traces from bytes.
+// DeserializeTraces deserializes traces from the data of a record.
 func (d *Deserializer) DeserializeTraces(data []byte) (ptrace.Traces, error) {
-	// In a real implementation, this would deserialize the bytes to traces
-	// For simplicity, we'll just return empty traces
-	return ptrace.NewTraces(), nil
+	return deserializeTraces(data, SerializationFormatProtobuf)
 }
 
-// DeserializeLogs deserializes logs from bytes.
+// DeserializeLogs deserializes logs from the data of a record.
 func (d *Deserializer) DeserializeLogs(data []byte) (plog.Logs, error) {
-	// In a real implementation, this would deserialize the bytes to logs
-	// For simplicity, we'll just return empty logs
-	return plog.NewLogs(), nil
+	return deserializeLogs(data, SerializationFormatProtobuf)
 }
 
-// Helper functions to wrap the serializer/deserializer
+// Serialization formats of DLQ records.
+const (
+	SerializationFormatProtobuf = "protobuf"
+	SerializationFormatJSON     = "json"
+)
 
-// serializeMetrics is a helper function to serialize metrics.
-func serializeMetrics(md pmetric.Metrics) ([]byte, error) {
-	serializer := &Serializer{}
-	return serializer.SerializeMetrics(md)
+// serializeMetrics encodes metrics in the given serialization format.
+func serializeMetrics(md pmetric.Metrics, format string) ([]byte, error) {
+	if format == SerializationFormatJSON {
+		return (&pmetric.JSONMarshaler{}).MarshalMetrics(md)
+	}
+	return (&pmetric.ProtoMarshaler{}).MarshalMetrics(md)
 }
 
-// deserializeMetrics is a helper function to deserialize metrics.
-func deserializeMetrics(data []byte) (pmetric.Metrics, error) {
-	deserializer := &Deserializer{}
-	return deserializer.DeserializeMetrics(data)
+// deserializeMetrics decodes metrics encoded in the given serialization
+// format. Records without a format are protobuf.
+func deserializeMetrics(data []byte, format string) (pmetric.Metrics, error) {
+	if format == SerializationFormatJSON {
+		return (&pmetric.JSONUnmarshaler{}).UnmarshalMetrics(data)
+	}
+	return (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(data)
 }
 
-// serializeTraces is a helper function to serialize traces.
-func serializeTraces(td ptrace.Traces) ([]byte, error) {
-	serializer := &Serializer{}
-	return serializer.SerializeTraces(td)
+// serializeTraces encodes traces in the given serialization format.
+func serializeTraces(td ptrace.Traces, format string) ([]byte, error) {
+	if format == SerializationFormatJSON {
+		return (&ptrace.JSONMarshaler{}).MarshalTraces(td)
+	}
+	return (&ptrace.ProtoMarshaler{}).MarshalTraces(td)
 }
 
-// deserializeTraces is a helper function to deserialize traces.
-func deserializeTraces(data []byte) (ptrace.Traces, error) {
-	deserializer := &Deserializer{}
-	return deserializer.DeserializeTraces(data)
+// deserializeTraces decodes traces encoded in the given serialization
+// format. Records without a format are protobuf.
+func deserializeTraces(data []byte, format string) (ptrace.Traces, error) {
+	if format == SerializationFormatJSON {
+		return (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(data)
+	}
+	return (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(data)
 }
 
-// serializeLogs is a helper function to serialize logs.
-func serializeLogs(ld plog.Logs) ([]byte, error) {
-	serializer := &Serializer{}
-	return serializer.SerializeLogs(ld)
+// serializeLogs encodes logs in the given serialization format.
+func serializeLogs(ld plog.Logs, format string) ([]byte, error) {
+	if format == SerializationFormatJSON {
+		return (&plog.JSONMarshaler{}).MarshalLogs(ld)
+	}
+	return (&plog.ProtoMarshaler{}).MarshalLogs(ld)
 }
 
-// deserializeLogs is a helper function to deserialize logs.
-func deserializeLogs(data []byte) (plog.Logs, error) {
-	deserializer := &Deserializer{}
-	return deserializer.DeserializeLogs(data)
+// deserializeLogs decodes logs encoded in the given serialization format.
+// Records without a format are protobuf.
+func deserializeLogs(data []byte, format string) (plog.Logs, error) {
+	if format == SerializationFormatJSON {
+		return (&plog.JSONUnmarshaler{}).UnmarshalLogs(data)
+	}
+	return (&plog.ProtoUnmarshaler{}).UnmarshalLogs(data)
 }
 
 // ReadDLQRecord reads a DLQ record from a reader.
@@ -236,30 +217,30 @@ func ReadDLQRecord(reader io.Reader) (*DLQRecord, error) {
 		}
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
-	
+
 	// Deserialize header
 	_, timestamp, dataSize, err := deserializeHeader(header)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	// Check if data size is valid
 	if dataSize > MaxRecordSize {
 		return nil, fmt.Errorf("record size too large: %d > %d", dataSize, MaxRecordSize)
 	}
-	
+
 	// Read data
 	data := make([]byte, dataSize)
 	if _, err := io.ReadFull(reader, data); err != nil {
 		return nil, fmt.Errorf("failed to read data: %w", err)
 	}
-	
+
 	// Create DLQ record
 	record := &DLQRecord{
 		Timestamp: timestamp,
 		Data:      data,
 		// Hash is set elsewhere
 	}
-	
+
 	return record, nil
 }
@@ -0,0 +1,150 @@
+package enhanceddlq
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// Timestamps with every nanosecond digit set, so any rounding shows
+const (
+	testStart    = pcommon.Timestamp(1700000000123456789)
+	testTime     = pcommon.Timestamp(1700000001987654321)
+	testExemplar = pcommon.Timestamp(1700000001000000001)
+)
+
+func testMetrics() pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	metric := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("requests")
+	dp := metric.SetEmptySum().DataPoints().AppendEmpty()
+	dp.SetStartTimestamp(testStart)
+	dp.SetTimestamp(testTime)
+	dp.SetIntValue(42)
+	dp.Exemplars().AppendEmpty().SetTimestamp(testExemplar)
+	return md
+}
+
+func testTraces() ptrace.Traces {
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetName("checkout")
+	span.SetStartTimestamp(testStart)
+	span.SetEndTimestamp(testTime)
+	span.Events().AppendEmpty().SetTimestamp(testExemplar)
+	return td
+}
+
+func testLogs() plog.Logs {
+	ld := plog.NewLogs()
+	record := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	record.Body().SetStr("payment declined")
+	record.SetTimestamp(testStart)
+	record.SetObservedTimestamp(testTime)
+	return ld
+}
+
+func TestMetricsRoundTripKeepsTimestamps(t *testing.T) {
+	md := testMetrics()
+
+	serialized, err := (&Serializer{}).SerializeMetrics(md)
+	if err != nil {
+		t.Fatalf("failed to serialize metrics: %v", err)
+	}
+	record, err := (&Deserializer{}).DeserializeRecord(serialized)
+	if err != nil {
+		t.Fatalf("failed to deserialize record: %v", err)
+	}
+	decoded, err := (&Deserializer{}).DeserializeMetrics(record.Data)
+	if err != nil {
+		t.Fatalf("failed to deserialize metrics: %v", err)
+	}
+
+	if err := compareTimestamps(metricTimestamps(md), metricTimestamps(decoded)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTracesRoundTripKeepsTimestamps(t *testing.T) {
+	td := testTraces()
+
+	serialized, err := (&Serializer{}).SerializeTraces(td)
+	if err != nil {
+		t.Fatalf("failed to serialize traces: %v", err)
+	}
+	record, err := (&Deserializer{}).DeserializeRecord(serialized)
+	if err != nil {
+		t.Fatalf("failed to deserialize record: %v", err)
+	}
+	decoded, err := (&Deserializer{}).DeserializeTraces(record.Data)
+	if err != nil {
+		t.Fatalf("failed to deserialize traces: %v", err)
+	}
+
+	if err := compareTimestamps(traceTimestamps(td), traceTimestamps(decoded)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogsRoundTripKeepsTimestamps(t *testing.T) {
+	ld := testLogs()
+
+	serialized, err := (&Serializer{}).SerializeLogs(ld)
+	if err != nil {
+		t.Fatalf("failed to serialize logs: %v", err)
+	}
+	record, err := (&Deserializer{}).DeserializeRecord(serialized)
+	if err != nil {
+		t.Fatalf("failed to deserialize record: %v", err)
+	}
+	decoded, err := (&Deserializer{}).DeserializeLogs(record.Data)
+	if err != nil {
+		t.Fatalf("failed to deserialize logs: %v", err)
+	}
+
+	if err := compareTimestamps(logTimestamps(ld), logTimestamps(decoded)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONRoundTripKeepsTimestamps(t *testing.T) {
+	md := testMetrics()
+	serialized, err := serializeMetrics(md, SerializationFormatJSON)
+	if err != nil {
+		t.Fatalf("failed to serialize metrics: %v", err)
+	}
+	if err := verifyMetricTimestamps(md, serialized, SerializationFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	td := testTraces()
+	serialized, err = serializeTraces(td, SerializationFormatJSON)
+	if err != nil {
+		t.Fatalf("failed to serialize traces: %v", err)
+	}
+	if err := verifyTraceTimestamps(td, serialized, SerializationFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	ld := testLogs()
+	serialized, err = serializeLogs(ld, SerializationFormatJSON)
+	if err != nil {
+		t.Fatalf("failed to serialize logs: %v", err)
+	}
+	if err := verifyLogTimestamps(ld, serialized, SerializationFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeserializeRecordRejectsUnknownType(t *testing.T) {
+	serialized, err := serializeRecord(9, []byte("payload"))
+	if err != nil {
+		t.Fatalf("failed to serialize record: %v", err)
+	}
+	if _, err := (&Deserializer{}).DeserializeRecord(serialized); err == nil {
+		t.Fatal("expected a record of unknown type to be rejected")
+	}
+}
@@ -1,19 +1,32 @@
 package enhanceddlq
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
 )
 
+// ErrRecordTooLarge is returned when a record exceeds MaxRecordSize.
+var ErrRecordTooLarge = errors.New("DLQ record exceeds maximum record size")
+
 // DLQStorage manages the file-based DLQ storage operations.
 type DLQStorage struct {
 	config           *Config
@@ -22,21 +35,92 @@ type DLQStorage struct {
 	currentFileSize  int64
 	currentFilePath  string
 	currentFileMutex sync.Mutex
-	
+
+	// Clock for file naming, record timestamps and retention, which SetClock
+	// can replace while the storage's loops run
+	clock      clock.Clock
+	clockMutex sync.RWMutex
+
+	// Prefix of this storage's files, the configured prefix plus the signal,
+	// so storages for different signals sharing a directory don't collide
+	filePrefix string
+
+	// Whether this storage also adopts the files named without a signal,
+	// see Config.LegacyFilesSignal
+	adoptUnsignaled bool
+
+	// Sequence number of the current file, guarded by currentFileMutex
+	fileSequence int64
+
+	// Files created by this storage, or adopted as DLQ files at startup.
+	// Retention only ever deletes these.
+	ownedFiles map[string]bool
+	ownedMutex sync.Mutex
+
+	// Size cap shared with the other storages of a partitioned DLQ, nil to
+	// cap this storage alone. Guarded by currentFileMutex.
+	sizeBudget *sizeBudget
+
 	// Metrics
 	totalWrittenBytes int64
 	totalWrittenItems int64
 	totalFiles        int64
-	
+
+	totalVerificationFailures int64
+	oversizedDropped          int64
+	dedupedWrites             int64
+
+	// Hashes of recently written records, nil unless deduplication is enabled
+	dedup *writeDedup
+
+	// DLQ files currently open for writing or replay
+	openFiles int64
+
+	// Durations of the writes to the DLQ files
+	writeLatency prometheus.Histogram
+
 	// Replay state
 	replayActive     bool
 	replayMutex      sync.Mutex
 	rateLimiter      *RateLimiter
+	adaptiveRate     *adaptiveRate
 	replayInterleave *InterleaveController
+
+	// Whether the DLQ files are being compacted, which no replay may overlap
+	compacting bool
+
+	// Where the last limited or stopped replay ended, nil to replay from the start
+	replayCheckpoint *replayCheckpoint
+
+	// Closed to stop the active replay, and closed by the replay once it has
+	// finished
+	replayStop chan struct{}
+	replayDone chan struct{}
+
+	// Called with a summary once a replay has finished, nil if unset
+	replayCompleted ReplayCompletedHandler
+
+	// Guards the file records rejected during replay are captured in
+	failedMutex sync.Mutex
+
+	// Fallback used while the DLQ directory is unwritable
+	fallback *WriteFallback
+
+	// Pending records when SyncPolicy is "interval", nil otherwise
+	batch *writeBatch
+
+	// Bounded buffer absorbing write bursts, nil unless MemoryBufferMiB is set
+	buffer *writeBuffer
+
+	// Stops the background loops, which Shutdown waits for before the final
+	// flush
+	cancel context.CancelFunc
+	loops  sync.WaitGroup
 }
 
 // RateLimiter controls the replay rate to avoid overwhelming the system.
 type RateLimiter struct {
+	clock          clock.Clock
 	bytesPerSecond int64
 	lastTime       time.Time
 	bytesConsumed  int64
@@ -45,103 +129,355 @@ type RateLimiter struct {
 
 // InterleaveController manages the interleaving of replay and live traffic.
 type InterleaveController struct {
-	ratio          int
-	replayCounter  int
-	liveCounter    int
-	mutex          sync.Mutex
-	replayAllowed  bool
-	liveAllowed    bool
+	ratio         int
+	replayCounter int
+	liveCounter   int
+	mutex         sync.Mutex
+	replayAllowed bool
+	liveAllowed   bool
+
+	// Live traffic estimate, letting replay stop waiting for its turn while
+	// live traffic is idle
+	live *liveRate
+
+	// Whether the replay share adapts to the live rate, see
+	// Config.AdaptiveInterleave
+	adaptive bool
 }
 
-// NewDLQStorage creates a new DLQ storage manager.
-func NewDLQStorage(config *Config, logger *zap.Logger) (*DLQStorage, error) {
+// NewDLQStorage creates a new DLQ storage manager for a signal.
+func NewDLQStorage(config *Config, logger *zap.Logger, signal string) (*DLQStorage, error) {
 	// Create directory if it doesn't exist
 	if err := os.MkdirAll(config.Directory, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create DLQ directory: %w", err)
 	}
-	
+
+	// Fail fast if the directory is unwritable or nearly full
+	if err := checkDirectory(config.Directory, config.FileSizeLimitMiB); err != nil {
+		return nil, err
+	}
+
 	// Create rate limiter
+	realClock := clock.Real()
 	rateLimiter := &RateLimiter{
+		clock:          realClock,
 		bytesPerSecond: int64(config.ReplayRateMiBSec * 1024 * 1024),
-		lastTime:       time.Now(),
+		lastTime:       realClock.Now(),
 	}
-	
+
 	// Create interleave controller
 	interleave := &InterleaveController{
 		ratio:         config.InterleaveRatio,
 		replayAllowed: true,
 		liveAllowed:   true,
+		live:          newLiveRate(realClock, config.InterleaveLiveRateHigh),
+		adaptive:      config.AdaptiveInterleave,
 	}
-	
+
 	storage := &DLQStorage{
 		config:           config,
 		logger:           logger,
+		clock:            realClock,
+		filePrefix:       fmt.Sprintf("%s-%s", config.FilePrefix, signal),
+		adoptUnsignaled:  config.LegacyFilesSignal == signal,
 		rateLimiter:      rateLimiter,
 		replayInterleave: interleave,
+		fallback:         NewWriteFallback(config, realClock),
+		ownedFiles:       make(map[string]bool),
+		dedup:            newWriteDedup(config),
+		writeLatency:     newWriteLatencyHistogram(),
+	}
+
+	// Tune the replay rate to backend health if enabled
+	if config.AdaptiveReplayRate {
+		storage.adaptiveRate = newAdaptiveRate(rateLimiter, realClock, config.ReplayMinRateMiBSec, config.ReplayRateMiBSec)
+	}
+
+	// Remove the temporary files of a compaction interrupted by a crash
+	storage.removeCompactionLeftovers()
+
+	// Adopt the DLQ files left by a previous run so retention can manage them
+	files, err := storage.ListDLQFiles()
+	if err != nil {
+		return nil, err
+	}
+	storage.adoptExistingFiles(files)
+
+	// Remove expired files left by a previous run before writing new ones
+	if err := storage.cleanupOldFiles(); err != nil {
+		logger.Error("Failed to clean up old DLQ files", zap.Error(err))
 	}
-	
+
+	// Continue the file sequence from the files already on disk
+	storage.fileSequence = lastFileSequence(files)
+
 	// Initialize the current file
 	if err := storage.rotateFileIfNeeded(); err != nil {
 		return nil, fmt.Errorf("failed to initialize DLQ file: %w", err)
 	}
-	
+
+	// Background loops run until Shutdown
+	var ctx context.Context
+	ctx, storage.cancel = context.WithCancel(context.Background())
+
+	// Start the background batch writer when records are synced on an interval
+	if config.SyncPolicy == SyncPolicyInterval {
+		storage.batch = newWriteBatch(config, ctx.Done())
+		storage.startLoop(ctx, storage.batchLoop)
+	}
+
+	// Start the background buffer writer when bursts are absorbed in memory
+	if config.MemoryBufferMiB > 0 {
+		storage.buffer = newWriteBuffer(config.MemoryBufferMiB)
+		storage.startLoop(ctx, storage.bufferLoop)
+	}
+
 	// Start a background cleanup goroutine
-	go storage.cleanupLoop(context.Background())
-	
+	storage.startLoop(ctx, storage.cleanupLoop)
+
+	// Merge small files on a schedule if enabled
+	if config.CompactIntervalSec > 0 {
+		storage.startLoop(ctx, storage.compactLoop)
+	}
+
+	// Start a background goroutine to retry the directory while the fallback is engaged
+	storage.startLoop(ctx, storage.fallbackRetryLoop)
+
 	return storage, nil
 }
 
+// startLoop runs a background loop until Shutdown cancels its context.
+func (s *DLQStorage) startLoop(ctx context.Context, loop func(context.Context)) {
+	s.loops.Add(1)
+	go func() {
+		defer s.loops.Done()
+		loop(ctx)
+	}()
+}
+
+// SetClock replaces the clock used for file naming, record timestamps,
+// retention, replay rate limiting and the write fallback.
+func (s *DLQStorage) SetClock(c clock.Clock) {
+	s.clockMutex.Lock()
+	s.clock = c
+	s.clockMutex.Unlock()
+
+	s.rateLimiter.mutex.Lock()
+	s.rateLimiter.clock = c
+	s.rateLimiter.mutex.Unlock()
+
+	s.fallback.mutex.Lock()
+	s.fallback.clock = c
+	s.fallback.mutex.Unlock()
+
+	s.replayInterleave.mutex.Lock()
+	s.replayInterleave.live.clock = c
+	s.replayInterleave.live.reset()
+	s.replayInterleave.mutex.Unlock()
+
+	if s.adaptiveRate != nil {
+		s.adaptiveRate.mutex.Lock()
+		s.adaptiveRate.clock = c
+		s.adaptiveRate.mutex.Unlock()
+	}
+}
+
+// getClock returns the clock the storage currently uses.
+func (s *DLQStorage) getClock() clock.Clock {
+	s.clockMutex.RLock()
+	defer s.clockMutex.RUnlock()
+	return s.clock
+}
+
+// setSizeBudget caps this storage's files together with the other storages
+// sharing the budget, instead of on their own.
+func (s *DLQStorage) setSizeBudget(budget *sizeBudget) {
+	budget.update(s, 0)
+
+	s.currentFileMutex.Lock()
+	s.sizeBudget = budget
+	s.currentFileMutex.Unlock()
+}
+
 // rotateFileIfNeeded checks if a new file is needed and creates one if necessary.
 func (s *DLQStorage) rotateFileIfNeeded() error {
 	s.currentFileMutex.Lock()
 	defer s.currentFileMutex.Unlock()
-	
+
 	// Check if we have a file and it's below the size limit
 	if s.currentFile != nil && s.currentFileSize < int64(s.config.FileSizeLimitMiB)*1024*1024 {
 		return nil
 	}
-	
+
 	// Close the current file if it exists
 	if s.currentFile != nil {
-		if err := s.currentFile.Close(); err != nil {
+		if err := s.closeFile(s.currentFile); err != nil {
 			s.logger.Error("Failed to close current DLQ file", zap.Error(err))
 		}
 		s.currentFile = nil
 	}
-	
-	// Create a new file
-	timestamp := time.Now().UTC().Format("20060102-150405.000")
-	filename := fmt.Sprintf("%s-%s.dlq", s.config.FilePrefix, timestamp)
+
+	// Create a new file, numbered so files created in the same millisecond
+	// never collide and always replay in creation order
+	s.fileSequence++
+	timestamp := s.getClock().Now().UTC().Format("20060102-150405.000")
+	filename := dlqFileName(s.filePrefix, s.fileSequence, timestamp)
 	filepath := filepath.Join(s.config.Directory, filename)
-	
-	file, err := os.OpenFile(filepath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	file, err := s.openFile(filepath, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to create new DLQ file: %w", err)
 	}
-	
+
 	s.currentFile = file
 	s.currentFilePath = filepath
 	s.currentFileSize = 0
 	s.totalFiles++
-	
-	s.logger.Info("Created new DLQ file", 
+	s.trackFile(filepath)
+
+	s.logger.Info("Created new DLQ file",
 		zap.String("path", filepath),
 		zap.Int64("totalFiles", s.totalFiles),
 	)
-	
+
 	return nil
 }
 
 // Write writes data to the DLQ with SHA-256 verification.
+// The priority carried by the context (see ContextWithPriority) is recorded in
+// the record header so replay can process higher priorities first.
+// When writes fail persistently, data is handed to the configured fallback
+// until the DLQ directory becomes writable again.
 func (s *DLQStorage) Write(ctx context.Context, data []byte) error {
+	// Reject records that could never be replayed
+	if len(data) > MaxRecordSize {
+		atomic.AddInt64(&s.oversizedDropped, 1)
+		s.logger.Error("Rejected oversized DLQ record",
+			zap.String("signal", signalFromContext(ctx)),
+			zap.Int("size", len(data)),
+			zap.Int("maxRecordSize", MaxRecordSize),
+		)
+		return fmt.Errorf("%w: %d > %d", ErrRecordTooLarge, len(data), MaxRecordSize)
+	}
+
+	// Collapse a record identical to one written within the dedup window
+	now := s.getClock().Now()
+	hash, duplicate := s.dedup.duplicate(data, now)
+	if duplicate {
+		atomic.AddInt64(&s.dedupedWrites, 1)
+		s.logger.Debug("Skipped duplicate DLQ record",
+			zap.String("signal", signalFromContext(ctx)),
+			zap.Int("size", len(data)),
+		)
+		return nil
+	}
+
+	priority := PriorityFromContext(ctx)
+
+	// The record is only remembered once it is stored, so a failed write
+	// can be retried
+	if s.fallback.IsActive() {
+		s.fallback.Store(data, priority)
+		s.dedup.remember(hash, now)
+		return nil
+	}
+
+	// Leave the write to the background batch writer
+	if s.batch != nil {
+		if err := s.batch.add(ctx, data, priority); err != nil {
+			return err
+		}
+		s.dedup.remember(hash, now)
+		return nil
+	}
+
+	// Leave the write to the background buffer writer
+	if s.buffer != nil {
+		s.buffer.add(data, priority)
+		s.dedup.remember(hash, now)
+		return nil
+	}
+
+	if err := s.writeRecord(ctx, data, priority); err != nil {
+		if !s.fallback.RecordFailure() {
+			return err
+		}
+
+		s.logger.Error("DLQ directory is unwritable, engaging fallback",
+			zap.Error(err),
+			zap.String("directory", s.config.Directory),
+			zap.String("fallbackMode", s.config.FallbackMode),
+			zap.Int("retryIntervalSec", s.config.WriteRetryIntervalSec),
+		)
+		s.closeCurrentFile()
+		s.fallback.Store(data, priority)
+		s.dedup.remember(hash, now)
+		return nil
+	}
+
+	s.fallback.RecordSuccess()
+	s.dedup.remember(hash, now)
+	return nil
+}
+
+// writeRecord writes a single record to the current DLQ file.
+func (s *DLQStorage) writeRecord(ctx context.Context, data []byte, priority string) error {
+	return s.writeRecords([]fallbackRecord{{data: data, priority: priority}})
+}
+
+// writeRecords writes records to the current DLQ file with a single write and
+// a single sync.
+func (s *DLQStorage) writeRecords(records []fallbackRecord) error {
 	// Ensure we have a valid file to write to
 	if err := s.rotateFileIfNeeded(); err != nil {
 		return err
 	}
-	
+
 	s.currentFileMutex.Lock()
 	defer s.currentFileMutex.Unlock()
-	
+
+	var buf bytes.Buffer
+	var dataBytes int64
+	for _, record := range records {
+		s.encodeRecord(&buf, record.data, record.priority)
+		dataBytes += int64(len(record.data))
+	}
+
+	// Time the write and sync, whether or not they succeed
+	started := s.getClock().Now()
+	defer func() {
+		s.writeLatency.Observe(s.getClock().Since(started).Seconds())
+	}()
+
+	// Write the records
+	n, err := s.currentFile.Write(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to write DLQ records: %w", err)
+	}
+
+	// Ensure data is synced to disk
+	if err := s.currentFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync DLQ file to disk: %w", err)
+	}
+
+	// Update stats
+	s.currentFileSize += int64(n)
+	atomic.AddInt64(&s.totalWrittenBytes, dataBytes)
+	atomic.AddInt64(&s.totalWrittenItems, int64(len(records)))
+
+	return nil
+}
+
+// encodeRecord frames data as a DLQ record, with a header carrying the
+// timestamp, priority and serialization format and a footer carrying the
+// SHA-256 hash.
+func (s *DLQStorage) encodeRecord(buf *bytes.Buffer, data []byte, priority string) {
+	s.encodeRecordAt(buf, data, priority, s.config.SerializationFormat, s.getClock().Now().UTC().UnixNano())
+}
+
+// encodeRecordAt frames data as a DLQ record with the given serialization
+// format and timestamp.
+func (s *DLQStorage) encodeRecordAt(buf *bytes.Buffer, data []byte, priority string, format string, timestamp int64) {
 	// Calculate SHA-256 hash if enabled
 	var hash string
 	if s.config.VerifySHA256 {
@@ -149,152 +485,393 @@ func (s *DLQStorage) Write(ctx context.Context, data []byte) error {
 		h.Write(data)
 		hash = hex.EncodeToString(h.Sum(nil))
 	}
-	
-	// Prepare the record header
-	timestamp := time.Now().UTC().UnixNano()
-	header := fmt.Sprintf("--- DLQ RECORD START %d ---\n", timestamp)
+
+	// Prepare the record header. The data length lets readers take the data
+	// as is, even if it contains something that looks like a footer.
+	header := fmt.Sprintf("--- DLQ RECORD START %d LENGTH:%d", timestamp, len(data))
+	if priority != "" {
+		header += fmt.Sprintf(" PRIORITY:%s", priority)
+	}
+	if format != "" {
+		header += fmt.Sprintf(" FORMAT:%s", format)
+	}
+	header += " ---\n"
 	footer := fmt.Sprintf("--- DLQ RECORD END %d", timestamp)
-	
+
 	if s.config.VerifySHA256 {
 		footer += fmt.Sprintf(" SHA256:%s", hash)
 	}
 	footer += " ---\n"
-	
-	// Write the record
-	if _, err := s.currentFile.WriteString(header); err != nil {
-		return fmt.Errorf("failed to write DLQ record header: %w", err)
+
+	buf.WriteString(header)
+	buf.Write(data)
+	buf.WriteString("\n" + footer)
+}
+
+// closeCurrentFile closes the current DLQ file so the next write opens a new one.
+func (s *DLQStorage) closeCurrentFile() {
+	s.currentFileMutex.Lock()
+	defer s.currentFileMutex.Unlock()
+
+	if s.currentFile != nil {
+		if err := s.closeFile(s.currentFile); err != nil {
+			s.logger.Warn("Failed to close DLQ file", zap.Error(err))
+		}
+		s.currentFile = nil
 	}
-	
-	n, err := s.currentFile.Write(data)
+}
+
+// openFile opens a DLQ file and counts it as open until closeFile is called.
+func (s *DLQStorage) openFile(path string, flag int, perm os.FileMode) (*os.File, error) {
+	file, err := os.OpenFile(path, flag, perm)
 	if err != nil {
-		return fmt.Errorf("failed to write DLQ data: %w", err)
+		return nil, err
 	}
-	
-	if _, err := s.currentFile.WriteString("\n" + footer); err != nil {
-		return fmt.Errorf("failed to write DLQ record footer: %w", err)
+	atomic.AddInt64(&s.openFiles, 1)
+	return file, nil
+}
+
+// closeFile closes a file opened with openFile. The descriptor is released
+// even when Close returns an error, so the file is no longer counted as open
+// either way and must not be closed again.
+func (s *DLQStorage) closeFile(file *os.File) error {
+	atomic.AddInt64(&s.openFiles, -1)
+	return file.Close()
+}
+
+// OpenFiles returns the number of DLQ files currently open.
+func (s *DLQStorage) OpenFiles() int64 {
+	return atomic.LoadInt64(&s.openFiles)
+}
+
+// fallbackRetryLoop periodically checks whether the DLQ directory is writable
+// again while the fallback is engaged.
+func (s *DLQStorage) fallbackRetryLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.config.WriteRetryIntervalSec) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.fallback.IsActive() {
+				s.retryDirectory(ctx)
+			}
+		}
 	}
-	
-	// Ensure data is synced to disk
-	if err := s.currentFile.Sync(); err != nil {
-		return fmt.Errorf("failed to sync DLQ file to disk: %w", err)
+}
+
+// retryDirectory attempts to resume writing to the DLQ directory, flushing any
+// data buffered by the fallback.
+func (s *DLQStorage) retryDirectory(ctx context.Context) {
+	if err := os.MkdirAll(s.config.Directory, 0755); err != nil {
+		s.logger.Warn("DLQ directory is still unavailable", zap.Error(err))
+		return
 	}
-	
-	// Update stats
-	s.currentFileSize += int64(n + len(header) + len(footer) + 1) // +1 for newline
-	s.totalWrittenBytes += int64(n)
-	s.totalWrittenItems++
-	
-	return nil
+
+	if err := s.rotateFileIfNeeded(); err != nil {
+		s.logger.Warn("DLQ directory is still unwritable", zap.Error(err))
+		return
+	}
+
+	buffered := s.fallback.Recover()
+	for i, record := range buffered {
+		if err := s.writeRecord(ctx, record.data, record.priority); err != nil {
+			s.logger.Warn("Failed to flush buffered DLQ data, re-engaging fallback",
+				zap.Error(err),
+				zap.Int("remaining", len(buffered)-i),
+			)
+			s.closeCurrentFile()
+			s.fallback.Reengage(buffered[i:])
+			return
+		}
+	}
+
+	s.logger.Info("DLQ directory is writable again, fallback disengaged",
+		zap.String("directory", s.config.Directory),
+		zap.Int("flushedRecords", len(buffered)),
+	)
 }
 
-// ListDLQFiles returns a list of all DLQ files in the storage directory.
+// ListDLQFiles returns a list of all DLQ files in the storage directory,
+// oldest first.
 func (s *DLQStorage) ListDLQFiles() ([]string, error) {
 	// Get all files in the directory
-	pattern := filepath.Join(s.config.Directory, fmt.Sprintf("%s-*.dlq", s.config.FilePrefix))
+	pattern := filepath.Join(s.config.Directory, fmt.Sprintf("%s-*.dlq", s.filePrefix))
 	files, err := filepath.Glob(pattern)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list DLQ files: %w", err)
 	}
-	
+
+	// Files named without a signal sort before all others
+	if s.adoptUnsignaled {
+		unsignaled, err := s.listUnsignaledFiles("")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, unsignaled...)
+	}
+
+	sortDLQFiles(files)
 	return files, nil
 }
 
-// StartReplay begins replaying data from the DLQ at the configured rate.
-func (s *DLQStorage) StartReplay(ctx context.Context, consumer DLQConsumer) error {
+// listUnsignaledFiles returns the files in the storage directory named
+// without a signal, with the given suffix after ".dlq".
+func (s *DLQStorage) listUnsignaledFiles(suffix string) ([]string, error) {
+	pattern := filepath.Join(s.config.Directory, fmt.Sprintf("%s-*.dlq%s", s.config.FilePrefix, suffix))
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list DLQ files: %w", err)
+	}
+
+	// The pattern also matches the files of every signal
+	var files []string
+	for _, file := range matches {
+		if isUnsignaledFile(strings.TrimSuffix(file, suffix), s.config.FilePrefix) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// StartReplay begins replaying data from the DLQ at the configured rate. The
+// replay stops once it reaches the limit, and the next replay resumes from
+// the record after the last one replayed.
+func (s *DLQStorage) StartReplay(ctx context.Context, consumer DLQConsumer, limit ReplayLimit) error {
 	s.replayMutex.Lock()
 	defer s.replayMutex.Unlock()
-	
+
 	if s.replayActive {
 		return fmt.Errorf("replay is already active")
 	}
-	
+	if s.compacting {
+		return fmt.Errorf("DLQ files are being compacted")
+	}
+
 	// List all DLQ files
 	files, err := s.ListDLQFiles()
 	if err != nil {
 		return err
 	}
-	
+
 	if len(files) == 0 {
 		return nil // Nothing to replay
 	}
-	
+
 	s.replayActive = true
 	s.replayInterleave.Reset()
 	s.rateLimiter.Reset()
-	
+	if s.adaptiveRate != nil {
+		s.adaptiveRate.reset()
+	}
+
+	shadow := isShadowReplay(ctx, s.config)
+	checkpoint := s.replayCheckpoint
+	budget := &replayBudget{limit: limit}
+	startedAt := s.getClock().Now()
+	totals := &replayTotals{}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	s.replayStop = stop
+	s.replayDone = done
+
+	// Records are consumed under a context the deadline cancels, so it also
+	// ends a stalled wait or a consumer that never returns
+	replayCtx, cancelReplay := context.WithCancel(ctx)
+	if s.config.MaxReplayDurationSec > 0 {
+		go s.enforceReplayDeadline(stop, done, cancelReplay)
+	}
+
 	// Start replay in background
 	go func() {
-		s.logger.Info("Starting DLQ replay", 
+		defer close(done)
+		defer cancelReplay()
+
+		// Wait for a free slot if other exporters are replaying this directory
+		release, err := acquireReplaySlot(ctx, stop, s.config.Directory, s.config.MaxConcurrentReplays)
+		if err != nil {
+			s.logger.Info("DLQ replay cancelled while waiting for a replay slot", zap.Error(err))
+			s.markReplayCompleted()
+			s.notifyReplayCompleted(ReplayOutcomeCancelled, startedAt, totals, shadow)
+			return
+		}
+		defer release()
+
+		s.logger.Info("Starting DLQ replay",
 			zap.Int("fileCount", len(files)),
 			zap.Float64("rateMiBSec", s.config.ReplayRateMiBSec),
 			zap.Int("interleaveRatio", s.config.InterleaveRatio),
+			zap.Int64("limitRecords", limit.Records),
+			zap.Int64("limitBytes", limit.Bytes),
+			zap.Bool("resuming", checkpoint != nil),
+			zap.Bool("shadow", shadow),
 		)
-		
+
 		// Create worker pool for replay
 		var wg sync.WaitGroup
-		recordCh := make(chan *DLQRecord, 1000)
-		
-		// Start worker goroutines
-		for i := 0; i < s.config.ReplayConcurrency; i++ {
+		recordCh := make(chan replayItem, 1000)
+
+		// Start worker goroutines. Once the replay is stopped, workers leave
+		// the rest queued, and a worker stopped while waiting to deliver its
+		// record hands back its position, so the checkpoint never skips it.
+		// Ordered replays read in parallel instead and deliver through a
+		// single worker.
+		workers := s.config.ReplayConcurrency
+		if s.orderedReplay() {
+			workers = 1
+		}
+		unfinished := make(chan replayCheckpoint, workers)
+		for i := 0; i < workers; i++ {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				for record := range recordCh {
-					// Wait for rate limiter
-					s.rateLimiter.Wait(len(record.Data))
-					
-					// Wait for interleave controller
-					for !s.replayInterleave.AllowReplay() {
-						time.Sleep(1 * time.Millisecond)
-						
-						// Check if context is cancelled
-						select {
-						case <-ctx.Done():
+				for {
+					var item replayItem
+					var ok bool
+					select {
+					case <-stop:
+						return
+					case item, ok = <-recordCh:
+						if !ok {
 							return
-						default:
 						}
 					}
-					
-					// Process the record
-					if err := consumer.ConsumeDLQRecord(ctx, record); err != nil {
-						s.logger.Error("Failed to consume DLQ record", 
-							zap.Error(err),
-							zap.Time("timestamp", record.Timestamp),
-						)
+					capture := s.config.CaptureReplayFailures && !shadow
+					if !s.consumeReplayRecord(replayCtx, stop, consumer, item.record, totals, capture) {
+						unfinished <- item.position
+						return
 					}
 				}
 			}()
 		}
-		
-		// Read files and send records to workers
-		for _, file := range files {
-			if err := s.replayFile(ctx, file, recordCh); err != nil {
-				s.logger.Error("Failed to replay DLQ file", 
-					zap.Error(err),
-					zap.String("file", file),
-				)
+
+		// Read files and send records to workers, one pass per priority so
+		// higher priorities are replayed first
+		for passIndex, pass := range s.replayPasses() {
+			if s.orderedReplay() {
+				resume, halted, err := s.replayPassOrdered(ctx, files, passIndex, pass, checkpoint, recordCh, budget, stop)
+				if err != nil {
+					close(recordCh)
+					wg.Wait()
+					s.markReplayCompleted()
+					s.notifyReplayCompleted(ReplayOutcomeCancelled, startedAt, totals, shadow)
+					return
+				}
+				if halted {
+					next := drainReplay(recordCh, unfinished, &wg, resume)
+					s.finishReplay(next, shadow)
+					s.logger.Info("DLQ replay stopped",
+						zap.Bool("limitReached", budget.exhausted()),
+						zap.String("resumeFile", next.file),
+						zap.Int64("resumeOffset", next.offset),
+					)
+					s.notifyReplayCompleted(ReplayOutcomeStopped, startedAt, totals, shadow)
+					return
+				}
+				continue
 			}
-			
-			// Check if context is cancelled
-			select {
-			case <-ctx.Done():
-				close(recordCh)
-				wg.Wait()
-				s.markReplayCompleted()
-				return
-			default:
+
+			for _, file := range files {
+				skip, offset := checkpoint.skip(passIndex, file)
+				if skip {
+					continue
+				}
+
+				offset, halted, err := s.replayFile(ctx, file, passIndex, recordCh, pass, offset, budget, stop)
+				if err != nil {
+					s.logger.Error("Failed to replay DLQ file",
+						zap.Error(err),
+						zap.String("file", file),
+					)
+				}
+
+				// Stop at the limit or when stopped, keeping where to resume from
+				if halted {
+					next := drainReplay(recordCh, unfinished, &wg, &replayCheckpoint{pass: passIndex, file: file, offset: offset})
+					s.finishReplay(next, shadow)
+					s.logger.Info("DLQ replay stopped",
+						zap.Bool("limitReached", budget.exhausted()),
+						zap.String("resumeFile", next.file),
+						zap.Int64("resumeOffset", next.offset),
+					)
+					s.notifyReplayCompleted(ReplayOutcomeStopped, startedAt, totals, shadow)
+					return
+				}
+
+				// Check if context is cancelled
+				select {
+				case <-ctx.Done():
+					close(recordCh)
+					wg.Wait()
+					s.markReplayCompleted()
+					s.notifyReplayCompleted(ReplayOutcomeCancelled, startedAt, totals, shadow)
+					return
+				default:
+				}
 			}
 		}
-		
-		close(recordCh)
-		wg.Wait()
-		s.markReplayCompleted()
+
+		// A stop after the last record was read can still leave records queued
+		if next := drainReplay(recordCh, unfinished, &wg, nil); next != nil {
+			s.finishReplay(next, shadow)
+			s.logger.Info("DLQ replay stopped",
+				zap.String("resumeFile", next.file),
+				zap.Int64("resumeOffset", next.offset),
+			)
+			s.notifyReplayCompleted(ReplayOutcomeStopped, startedAt, totals, shadow)
+			return
+		}
+
+		s.finishReplay(nil, shadow)
 		s.logger.Info("DLQ replay completed")
+		s.notifyReplayCompleted(ReplayOutcomeCompleted, startedAt, totals, shadow)
 	}()
-	
+
 	return nil
 }
 
+// consumeReplayRecord hands a replayed record to the consumer once the rate
+// limiter and the interleave controller allow it, recording the outcome and,
+// with capture, keeping the record for a failed replay if the consumer fails.
+// It returns false without consuming the record if the replay is cancelled
+// or stopped while waiting, or if the replay is cancelled while the consumer
+// has the record, which then counts as not consumed.
+func (s *DLQStorage) consumeReplayRecord(ctx context.Context, stop <-chan struct{}, consumer DLQConsumer, record *DLQRecord, totals *replayTotals, capture bool, fields ...zap.Field) bool {
+	s.rateLimiter.Wait(len(record.Data))
+
+	for !s.replayInterleave.AllowReplay() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-stop:
+			return false
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	err := consumer.ConsumeDLQRecord(ctx, record)
+	if err != nil && ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		s.logger.Error("Failed to consume DLQ record", append(fields,
+			zap.Error(err),
+			zap.Time("timestamp", record.Timestamp),
+		)...)
+		if capture {
+			s.captureFailure(record)
+		}
+	}
+	if s.adaptiveRate != nil {
+		s.adaptiveRate.record(err)
+	}
+	totals.record(len(record.Data), err)
+	return true
+}
+
 // markReplayCompleted marks the replay as completed.
 func (s *DLQStorage) markReplayCompleted() {
 	s.replayMutex.Lock()
@@ -302,13 +879,292 @@ func (s *DLQStorage) markReplayCompleted() {
 	s.replayActive = false
 }
 
-// replayFile replays a single DLQ file, parsing records and sending them to the channel.
-func (s *DLQStorage) replayFile(ctx context.Context, filePath string, recordCh chan<- *DLQRecord) error {
-	// Implementation omitted for brevity
-	// This would parse the file, extract records, verify SHA-256 if enabled,
-	// and send each record to the recordCh channel
-	
-	return nil
+// drainReplay closes the record channel, waits for the workers to exit and
+// returns where the next replay should resume: the earliest record a worker
+// gave up on or still queued, which no worker consumed, or resume if it
+// comes first or there are none.
+func drainReplay(recordCh chan replayItem, unfinished chan replayCheckpoint, wg *sync.WaitGroup, resume *replayCheckpoint) *replayCheckpoint {
+	close(recordCh)
+	wg.Wait()
+	close(unfinished)
+
+	for position := range unfinished {
+		position := position
+		resume = earliestCheckpoint(resume, &position)
+	}
+	for item := range recordCh {
+		item := item
+		resume = earliestCheckpoint(resume, &item.position)
+	}
+	return resume
+}
+
+// finishReplay marks the replay as completed and records where the next
+// replay starts from. Shadow replays forward nothing, so they leave the
+// checkpoint where it was.
+func (s *DLQStorage) finishReplay(checkpoint *replayCheckpoint, shadow bool) {
+	s.replayMutex.Lock()
+	defer s.replayMutex.Unlock()
+	s.replayActive = false
+	if !shadow {
+		s.replayCheckpoint = checkpoint
+	}
+}
+
+// replayPass selects the records replayed in a single pass over the DLQ files.
+type replayPass func(priority string) bool
+
+// replayPasses returns the replay passes in the configured priority order,
+// followed by a final pass for records with an unlisted or no priority.
+func (s *DLQStorage) replayPasses() []replayPass {
+	listed := make(map[string]bool, len(s.config.ReplayPriorityOrder))
+	passes := make([]replayPass, 0, len(s.config.ReplayPriorityOrder)+1)
+
+	for _, priority := range s.config.ReplayPriorityOrder {
+		priority := priority
+		listed[priority] = true
+		passes = append(passes, func(p string) bool { return p == priority })
+	}
+
+	passes = append(passes, func(p string) bool { return !listed[p] })
+	return passes
+}
+
+// replayFile replays a single DLQ file from the given offset, parsing records
+// and sending those selected by the pass to the channel. It stops early once
+// the budget is exhausted or the replay is stopped, returning the offset of
+// the next record not sent and whether it stopped early.
+func (s *DLQStorage) replayFile(ctx context.Context, filePath string, passIndex int, recordCh chan<- replayItem, pass replayPass, offset int64, budget *replayBudget, stop <-chan struct{}) (int64, bool, error) {
+	file, err := s.openFile(filePath, os.O_RDONLY, 0)
+	if err != nil {
+		return offset, false, fmt.Errorf("failed to open DLQ file: %w", err)
+	}
+	defer func() {
+		if err := s.closeFile(file); err != nil {
+			s.logger.Warn("Failed to close replayed DLQ file", zap.Error(err), zap.String("file", filePath))
+		}
+	}()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, false, fmt.Errorf("failed to seek DLQ file: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		if budget.exhausted() {
+			return offset, true, nil
+		}
+		select {
+		case <-stop:
+			return offset, true, nil
+		default:
+		}
+
+		start := offset
+		record, size, err := readStoredRecord(reader)
+		if err == io.EOF {
+			return offset, false, nil
+		}
+		if err != nil {
+			return offset, false, err
+		}
+		offset += size
+
+		if !pass(record.Priority) {
+			continue
+		}
+
+		// Verify the record if a hash was stored
+		if s.config.VerifySHA256 && record.Hash != "" {
+			sum := sha256.Sum256(record.Data)
+			if hex.EncodeToString(sum[:]) != record.Hash {
+				atomic.AddInt64(&s.totalVerificationFailures, 1)
+				s.logger.Warn("DLQ record failed SHA-256 verification",
+					zap.String("file", filePath),
+					zap.Time("timestamp", record.Timestamp),
+				)
+				continue
+			}
+		}
+
+		item := replayItem{
+			record:   record,
+			position: replayCheckpoint{pass: passIndex, file: filePath, offset: start},
+		}
+		select {
+		case recordCh <- item:
+			budget.consume(len(record.Data))
+		case <-stop:
+			return start, true, nil
+		case <-ctx.Done():
+			return offset, false, ctx.Err()
+		}
+	}
+}
+
+// readStoredRecord reads the next record in the on-disk format written by
+// writeRecord, returning the record and its encoded size:
+//
+//	--- DLQ RECORD START <ts> LENGTH:<length>[ PRIORITY:<priority>][ FORMAT:<format>] ---
+//	<data>
+//	--- DLQ RECORD END <ts>[ SHA256:<hash>] ---
+//
+// Records written before the header carried the data length end at the
+// first footer line instead.
+func readStoredRecord(reader *bufio.Reader) (*DLQRecord, int64, error) {
+	headerLine, err := reader.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && headerLine == "" {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, fmt.Errorf("failed to read DLQ record header: %w", err)
+	}
+	size := int64(len(headerLine))
+
+	fields := strings.Fields(headerLine)
+	if len(fields) < 6 || fields[1] != "DLQ" || fields[3] != "START" {
+		return nil, 0, fmt.Errorf("malformed DLQ record header: %q", headerLine)
+	}
+
+	timestamp, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("malformed DLQ record timestamp: %w", err)
+	}
+
+	record := &DLQRecord{
+		Timestamp: time.Unix(0, timestamp),
+	}
+	length, hasLength, err := recordDataLength(fields)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, field := range fields[5:] {
+		if strings.HasPrefix(field, "PRIORITY:") {
+			record.Priority = strings.TrimPrefix(field, "PRIORITY:")
+		}
+		if strings.HasPrefix(field, "FORMAT:") {
+			record.Format = strings.TrimPrefix(field, "FORMAT:")
+		}
+	}
+
+	if hasLength {
+		// Read the data and the newline written between it and the footer
+		data := make([]byte, length+1)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, 0, fmt.Errorf("truncated DLQ record: %w", err)
+		}
+		if data[length] != '\n' {
+			return nil, 0, fmt.Errorf("malformed DLQ record: data doesn't match its length %d", length)
+		}
+		size += length + 1
+
+		footerLine, err := reader.ReadBytes('\n')
+		if err != nil {
+			return nil, 0, fmt.Errorf("truncated DLQ record: %w", err)
+		}
+		if !bytes.HasPrefix(footerLine, recordEndMarker) {
+			return nil, 0, fmt.Errorf("malformed DLQ record footer: %q", footerLine)
+		}
+		size += int64(len(footerLine))
+		record.Hash = recordHash(footerLine)
+		record.Data = data[:length]
+
+		return record, size, nil
+	}
+
+	// Accumulate data lines until the footer
+	var data bytes.Buffer
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return nil, 0, fmt.Errorf("truncated DLQ record: %w", err)
+		}
+		size += int64(len(line))
+
+		if bytes.HasPrefix(line, recordEndMarker) {
+			record.Hash = recordHash(line)
+			break
+		}
+
+		data.Write(line)
+	}
+
+	// Drop the newline written between the data and the footer
+	record.Data = bytes.TrimSuffix(data.Bytes(), []byte("\n"))
+
+	return record, size, nil
+}
+
+// recordDataLength returns the data length carried by the fields of a record
+// header, and whether it carries one.
+func recordDataLength(fields []string) (int64, bool, error) {
+	for _, field := range fields {
+		if !strings.HasPrefix(field, "LENGTH:") {
+			continue
+		}
+		length, err := strconv.ParseInt(strings.TrimPrefix(field, "LENGTH:"), 10, 64)
+		if err != nil || length < 0 {
+			return 0, false, fmt.Errorf("malformed DLQ record length: %q", field)
+		}
+		return length, true, nil
+	}
+	return 0, false, nil
+}
+
+// recordHash returns the SHA-256 hash carried by a record footer, if any.
+func recordHash(footerLine []byte) string {
+	for _, field := range strings.Fields(string(footerLine)) {
+		if strings.HasPrefix(field, "SHA256:") {
+			return strings.TrimPrefix(field, "SHA256:")
+		}
+	}
+	return ""
+}
+
+// WrittenRecords returns the number of records written to the DLQ files.
+func (s *DLQStorage) WrittenRecords() int64 {
+	return atomic.LoadInt64(&s.totalWrittenItems)
+}
+
+// WrittenBytes returns the number of bytes written to the DLQ files.
+func (s *DLQStorage) WrittenBytes() int64 {
+	return atomic.LoadInt64(&s.totalWrittenBytes)
+}
+
+// VerificationFailures returns the number of replayed records that failed
+// SHA-256 verification.
+func (s *DLQStorage) VerificationFailures() int64 {
+	return atomic.LoadInt64(&s.totalVerificationFailures)
+}
+
+// DedupedWrites returns the number of writes skipped as duplicates of a
+// record written within the dedup window.
+func (s *DLQStorage) DedupedWrites() int64 {
+	return atomic.LoadInt64(&s.dedupedWrites)
+}
+
+// OversizedDropped returns the number of records rejected for exceeding MaxRecordSize.
+func (s *DLQStorage) OversizedDropped() int64 {
+	return atomic.LoadInt64(&s.oversizedDropped)
+}
+
+// ReplayRate returns the current replay rate limit in bytes per second.
+func (s *DLQStorage) ReplayRate() int64 {
+	return s.rateLimiter.Rate()
+}
+
+// InFlightBytes returns the bytes held in memory waiting to be written to
+// the DLQ files: the write batch, the write buffer and the fallback buffer.
+func (s *DLQStorage) InFlightBytes() int64 {
+	total := s.fallback.Stats().BufferedBytes
+	if s.batch != nil {
+		total += int64(s.batch.pendingBytes())
+	}
+	if s.buffer != nil {
+		buffered, _ := s.buffer.stats()
+		total += int64(buffered)
+	}
+	return total
 }
 
 // IsReplayActive returns whether a replay is currently active.
@@ -318,38 +1174,111 @@ func (s *DLQStorage) IsReplayActive() bool {
 	return s.replayActive
 }
 
-// StopReplay stops an active replay operation.
+// StopReplay stops an active replay operation and waits for it to finish.
+// Records being consumed are finished, those still waiting to be delivered
+// are left, and the replay keeps a checkpoint at the first record not
+// consumed so the next replay delivers the rest.
 func (s *DLQStorage) StopReplay() {
 	s.replayMutex.Lock()
-	defer s.replayMutex.Unlock()
-	s.replayActive = false
+	stop, done := s.replayStop, s.replayDone
+	s.replayStop = nil
+	s.replayMutex.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// enforceReplayDeadline stops the replay using stop once it has run for
+// MaxReplayDurationSec, unless it finishes or is stopped first, and cancels
+// the context records are consumed under so no record holds the replay up.
+// The replay then checkpoints just as if StopReplay had been called, at the
+// first record not consumed.
+func (s *DLQStorage) enforceReplayDeadline(stop chan struct{}, done <-chan struct{}, cancel context.CancelFunc) {
+	maxDuration := time.Duration(s.config.MaxReplayDurationSec) * time.Second
+	select {
+	case <-s.getClock().After(maxDuration):
+	case <-done:
+		return
+	}
+
+	s.replayMutex.Lock()
+	if s.replayStop != stop {
+		// Already stopped
+		s.replayMutex.Unlock()
+		return
+	}
+	s.replayStop = nil
+	s.replayMutex.Unlock()
+
+	s.logger.Warn("DLQ replay reached max_replay_duration_sec, stopping",
+		zap.Int("maxReplayDurationSec", s.config.MaxReplayDurationSec),
+	)
+	close(stop)
+	cancel()
 }
 
-// Shutdown closes the DLQ storage.
+// Shutdown stops the background loops, writes out the records still pending
+// and closes the DLQ storage.
 func (s *DLQStorage) Shutdown() error {
+	// Stop the loops first, so none of them writes or reopens a file after
+	// the final flush
+	s.cancel()
+	s.loops.Wait()
+
+	// Write out any records still waiting for the batch writer
+	if s.batch != nil {
+		s.flushBatch()
+	}
+
+	// Write out whatever the write buffer still holds, stopping at a failure
+	if s.buffer != nil {
+		for s.drainBuffer() {
+		}
+	}
+
 	s.currentFileMutex.Lock()
 	defer s.currentFileMutex.Unlock()
-	
+
+	if s.sizeBudget != nil {
+		s.sizeBudget.remove(s)
+		s.sizeBudget = nil
+	}
+
 	if s.currentFile != nil {
-		if err := s.currentFile.Close(); err != nil {
+		err := s.closeFile(s.currentFile)
+		s.currentFile = nil
+		if err != nil {
 			return fmt.Errorf("failed to close DLQ file: %w", err)
 		}
-		s.currentFile = nil
 	}
-	
+
 	return nil
 }
 
-// cleanupLoop periodically cleans up old DLQ files based on retention policy.
+// cleanupInterval is the average time between cleanups, and cleanupJitter
+// the fraction it's randomly varied by so collectors started together don't
+// clean up in lockstep.
+const (
+	cleanupInterval = 1 * time.Hour
+	cleanupJitter   = 0.1
+)
+
+// cleanupLoop periodically cleans up old DLQ files based on the retention
+// policy and size cap, at a jittered interval.
 func (s *DLQStorage) cleanupLoop(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
-	
 	for {
+		jitter := (rand.Float64()*2 - 1) * cleanupJitter
+		interval := time.Duration(float64(cleanupInterval) * (1 + jitter))
+
+		clk := s.getClock()
+
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-clk.After(interval):
 			if err := s.cleanupOldFiles(); err != nil {
 				s.logger.Error("Failed to clean up old DLQ files", zap.Error(err))
 			}
@@ -357,46 +1286,103 @@ func (s *DLQStorage) cleanupLoop(ctx context.Context) {
 	}
 }
 
-// cleanupOldFiles removes DLQ files that exceed the retention period.
+// cleanupOldFiles removes DLQ files that exceed the retention period, then
+// removes the oldest files until the DLQ fits within MaxTotalSizeMiB. The
+// file currently being written is never removed.
 func (s *DLQStorage) cleanupOldFiles() error {
 	// Get all DLQ files
 	files, err := s.ListDLQFiles()
 	if err != nil {
 		return err
 	}
-	
+
+	s.currentFileMutex.Lock()
+	currentPath := s.currentFilePath
+	now := s.getClock().Now()
+	budget := s.sizeBudget
+	s.currentFileMutex.Unlock()
+
 	// Calculate cutoff time
-	cutoff := time.Now().Add(-time.Duration(s.config.RetentionHours) * time.Hour)
-	
+	cutoff := now.Add(-time.Duration(s.config.RetentionHours) * time.Hour)
+
+	// Files kept after retention, oldest first, for the size cap
+	var kept []string
+	var keptSizes []int64
+	var totalSize int64
+
 	for _, file := range files {
+		// Never delete the file being written, or a file this storage didn't create
+		if file == currentPath || !s.ownsFile(file) {
+			continue
+		}
+
 		// Get file info
 		info, err := os.Stat(file)
 		if err != nil {
-			s.logger.Warn("Failed to get file info during cleanup", 
+			s.logger.Warn("Failed to get file info during cleanup",
 				zap.Error(err),
 				zap.String("file", file),
 			)
 			continue
 		}
-		
+
 		// Check if file is older than retention period
 		if info.ModTime().Before(cutoff) {
 			if err := os.Remove(file); err != nil {
-				s.logger.Warn("Failed to delete old DLQ file", 
+				s.logger.Warn("Failed to delete old DLQ file",
 					zap.Error(err),
 					zap.String("file", file),
 				)
 				continue
 			}
-			
-			s.logger.Info("Deleted old DLQ file", 
+			s.untrackFile(file)
+
+			s.logger.Info("Deleted old DLQ file",
 				zap.String("file", file),
 				zap.Time("modTime", info.ModTime()),
 				zap.Time("cutoff", cutoff),
 			)
+			continue
+		}
+
+		kept = append(kept, file)
+		keptSizes = append(keptSizes, info.Size())
+		totalSize += info.Size()
+	}
+
+	if s.config.MaxTotalSizeMiB <= 0 {
+		return nil
+	}
+
+	// Files are listed in creation order, so the oldest files are removed first
+	maxSize := int64(s.config.MaxTotalSizeMiB) * 1024 * 1024
+	if budget != nil {
+		maxSize = budget.allowance(s, totalSize)
+	}
+	for i, file := range kept {
+		if totalSize <= maxSize {
+			break
+		}
+		if err := os.Remove(file); err != nil {
+			s.logger.Warn("Failed to delete DLQ file over size cap",
+				zap.Error(err),
+				zap.String("file", file),
+			)
+			continue
+		}
+		totalSize -= keptSizes[i]
+		s.untrackFile(file)
+		if budget != nil {
+			budget.update(s, totalSize)
 		}
+
+		s.logger.Info("Deleted DLQ file over size cap",
+			zap.String("file", file),
+			zap.Int64("size", keptSizes[i]),
+			zap.Int("maxTotalSizeMiB", s.config.MaxTotalSizeMiB),
+		)
 	}
-	
+
 	return nil
 }
 
@@ -405,6 +1391,8 @@ type DLQRecord struct {
 	Timestamp time.Time
 	Data      []byte
 	Hash      string
+	Priority  string
+	Format    string // serialization format, empty for protobuf
 }
 
 // DLQConsumer interface for consuming DLQ records.
@@ -416,28 +1404,45 @@ type DLQConsumer interface {
 func (r *RateLimiter) Reset() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	r.lastTime = time.Now()
+	r.lastTime = r.clock.Now()
+	r.bytesConsumed = 0
+}
+
+// SetRate changes the rate limit, restarting the accounting of bytes
+// consumed so the new rate applies from now on.
+func (r *RateLimiter) SetRate(bytesPerSecond int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.bytesPerSecond = bytesPerSecond
+	r.lastTime = r.clock.Now()
 	r.bytesConsumed = 0
 }
 
+// Rate returns the current rate limit in bytes per second.
+func (r *RateLimiter) Rate() int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.bytesPerSecond
+}
+
 // Wait waits until the rate limit allows processing the specified number of bytes.
 func (r *RateLimiter) Wait(bytes int) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	
+
 	// Calculate how long we should wait
 	r.bytesConsumed += int64(bytes)
 	expectedDuration := time.Duration(float64(r.bytesConsumed) / float64(r.bytesPerSecond) * float64(time.Second))
-	elapsedTime := time.Since(r.lastTime)
-	
+	elapsedTime := r.clock.Since(r.lastTime)
+
 	if expectedDuration > elapsedTime {
 		// Need to wait
-		time.Sleep(expectedDuration - elapsedTime)
+		<-r.clock.After(expectedDuration - elapsedTime)
 	}
-	
+
 	// If too much time has passed, reset the counters
 	if elapsedTime > time.Second*2 {
-		r.lastTime = time.Now()
+		r.lastTime = r.clock.Now()
 		r.bytesConsumed = int64(bytes)
 	}
 }
@@ -450,29 +1455,39 @@ func (i *InterleaveController) Reset() {
 	i.liveCounter = 0
 	i.replayAllowed = true
 	i.liveAllowed = true
+	i.live.reset()
 }
 
 // AllowReplay returns whether replay processing is allowed at this time.
 func (i *InterleaveController) AllowReplay() bool {
 	i.mutex.Lock()
 	defer i.mutex.Unlock()
-	
+
 	// Check if replay is allowed
 	if !i.replayAllowed {
+		// Replay needn't wait for live traffic that isn't arriving
+		if i.live.idle() {
+			return true
+		}
+
 		// Need to wait for live traffic
 		return false
 	}
-	
+
 	// Increment replay counter
 	i.replayCounter++
-	
+
 	// Check if we need to switch to live traffic
-	if i.replayCounter >= i.ratio {
+	share := i.ratio
+	if i.adaptive {
+		share = i.live.replayShare(i.ratio)
+	}
+	if i.replayCounter >= share {
 		i.replayAllowed = false
 		i.liveAllowed = true
 		i.replayCounter = 0
 	}
-	
+
 	return true
 }
 
@@ -480,22 +1495,24 @@ func (i *InterleaveController) AllowReplay() bool {
 func (i *InterleaveController) AllowLive() bool {
 	i.mutex.Lock()
 	defer i.mutex.Unlock()
-	
+
+	i.live.observe()
+
 	// Check if live traffic is allowed
 	if !i.liveAllowed {
 		// Need to wait for replay
 		return false
 	}
-	
+
 	// Increment live counter
 	i.liveCounter++
-	
+
 	// Check if we need to switch to replay
 	if i.liveCounter >= i.ratio {
 		i.liveAllowed = false
 		i.replayAllowed = true
 		i.liveCounter = 0
 	}
-	
+
 	return true
 }
@@ -0,0 +1,318 @@
+package enhanceddlq
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// newTestStorage creates a storage for the metrics signal in a temporary
+// directory, on a fake clock. configure, if not nil, adjusts the default
+// configuration first.
+func newTestStorage(t testing.TB, configure func(*Config)) (*DLQStorage, *clock.FakeClock) {
+	t.Helper()
+
+	config := CreateDefaultConfig().(*Config)
+	config.Directory = t.TempDir()
+	if configure != nil {
+		configure(config)
+	}
+
+	storage, err := NewDLQStorage(config, zap.NewNop(), "metrics")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() {
+		storage.Shutdown()
+	})
+
+	fake := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	storage.SetClock(fake)
+	return storage, fake
+}
+
+// readAllRecords returns the records in the storage's DLQ files, in file
+// order.
+func readAllRecords(t testing.TB, storage *DLQStorage) []*DLQRecord {
+	t.Helper()
+
+	files, err := storage.ListDLQFiles()
+	if err != nil {
+		t.Fatalf("failed to list DLQ files: %v", err)
+	}
+
+	var records []*DLQRecord
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open DLQ file: %v", err)
+		}
+		reader := bufio.NewReader(file)
+		for {
+			record, _, err := readStoredRecord(reader)
+			if err != nil {
+				break
+			}
+			records = append(records, record)
+		}
+		file.Close()
+	}
+	return records
+}
+
+// writeCount returns the number of writes to the DLQ files the storage has
+// made, each a single write() and fsync.
+func writeCount(t testing.TB, storage *DLQStorage) uint64 {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(storage.writeLatency)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	return families[0].GetMetric()[0].GetHistogram().GetSampleCount()
+}
+
+func TestReplayEmitsCriticalBeforeNormal(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.ReplayConcurrency = 1
+	})
+	storage.SetClock(clock.Real())
+
+	// Spilled normal and critical records arrive interleaved
+	for i := 0; i < 6; i++ {
+		priority := "normal"
+		if i%2 == 1 {
+			priority = "critical"
+		}
+		ctx := ContextWithPriority(context.Background(), priority)
+		if err := storage.Write(ctx, []byte(fmt.Sprintf("%s-%d", priority, i))); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+	rotate(t, storage)
+
+	collector := &recordCollector{}
+	if err := storage.StartReplay(context.Background(), collector, ReplayLimit{}); err != nil {
+		t.Fatalf("failed to start replay: %v", err)
+	}
+	waitFor(t, "the replay to finish", func() bool { return !storage.IsReplayActive() })
+
+	expected := []string{"critical-1", "critical-3", "critical-5", "normal-0", "normal-2", "normal-4"}
+	got := collector.received()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected the critical records to be replayed first, got %v", got)
+		}
+	}
+}
+
+func TestWriteRejectsOversizedRecord(t *testing.T) {
+	storage, _ := newTestStorage(t, nil)
+
+	err := storage.Write(context.Background(), make([]byte, MaxRecordSize+1))
+	if !errors.Is(err, ErrRecordTooLarge) {
+		t.Fatalf("expected the oversized record to be rejected, got %v", err)
+	}
+	if dropped := storage.OversizedDropped(); dropped != 1 {
+		t.Fatalf("expected 1 oversized record counted, got %d", dropped)
+	}
+
+	// Records within the limit are still written
+	if err := storage.Write(context.Background(), []byte("record")); err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+	rotate(t, storage)
+	if records := readAllRecords(t, storage); len(records) != 1 || string(records[0].Data) != "record" {
+		t.Fatalf("expected only the small record to be stored, got %d records", len(records))
+	}
+}
+
+func TestRecordDataMayContainFooter(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.VerifySHA256 = true
+	})
+
+	tricky := "before\n--- DLQ RECORD END 1 ---\nafter"
+	for _, data := range []string{tricky, "next"} {
+		if err := storage.Write(context.Background(), []byte(data)); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+	rotate(t, storage)
+
+	records := readAllRecords(t, storage)
+	if len(records) != 2 || string(records[0].Data) != tricky || string(records[1].Data) != "next" {
+		t.Fatalf("expected both records to be read whole, got %d records", len(records))
+	}
+	inventory, err := storage.ListInventory()
+	if err != nil {
+		t.Fatalf("failed to list inventory: %v", err)
+	}
+	if inventory[0].Records != 2 {
+		t.Fatalf("expected the inventory to count 2 records, got %d", inventory[0].Records)
+	}
+
+	// Records written without a length still end at the first footer
+	legacy := "--- DLQ RECORD START 1 ---\nold\n--- DLQ RECORD END 1 ---\n"
+	record, size, err := readStoredRecord(bufio.NewReader(strings.NewReader(legacy)))
+	if err != nil {
+		t.Fatalf("failed to read legacy record: %v", err)
+	}
+	if string(record.Data) != "old" || size != int64(len(legacy)) {
+		t.Fatalf("expected the legacy record to be read, got %q of %d bytes", record.Data, size)
+	}
+}
+
+func TestOpenFilesGaugeReturnsToBaseline(t *testing.T) {
+	e := newStartedMetricsExporter(t, "open-files", nil)
+	storage := e.storage
+	openFiles := func() float64 {
+		t.Helper()
+		value, ok := dlqMetricValue(t, e.id, "nrdot_mvp_dlq_open_files")
+		if !ok {
+			t.Fatal("expected the open files gauge to be registered")
+		}
+		return value
+	}
+	baseline := openFiles()
+
+	// Only the file being written stays open across rotations
+	for i := 0; i < 100; i++ {
+		if err := storage.Write(context.Background(), []byte(fmt.Sprintf("record-%d", i))); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+		rotate(t, storage)
+	}
+	if got := openFiles(); got != baseline {
+		t.Fatalf("expected only the current file to stay open, got %v open files", got)
+	}
+
+	// Replayed files are closed once read
+	replayed := &recordCollector{}
+	if err := storage.StartReplay(context.Background(), replayed, ReplayLimit{}); err != nil {
+		t.Fatalf("failed to start replay: %v", err)
+	}
+	waitFor(t, "the replay to finish", func() bool { return !storage.IsReplayActive() })
+	if got := len(replayed.received()); got != 100 {
+		t.Fatalf("expected 100 records to be replayed, got %d", got)
+	}
+
+	if got := openFiles(); got != baseline {
+		t.Fatalf("expected the open files gauge to return to %v, got %v", baseline, got)
+	}
+
+	if err := storage.Shutdown(); err != nil {
+		t.Fatalf("failed to shut down storage: %v", err)
+	}
+	if got := openFiles(); got != 0 {
+		t.Fatalf("expected no open files after shutdown, got %v", got)
+	}
+}
+
+func TestStartupCleanupRemovesExpiredFile(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.RetentionHours = 24
+	})
+	writeReplayRecords(t, storage, 1)
+	files, err := storage.ListDLQFiles()
+	if err != nil {
+		t.Fatalf("failed to list DLQ files: %v", err)
+	}
+	if err := storage.Shutdown(); err != nil {
+		t.Fatalf("failed to shut down storage: %v", err)
+	}
+
+	// The file written by the previous run expired while it was down
+	expired := files[0]
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(expired, old, old); err != nil {
+		t.Fatalf("failed to age DLQ file: %v", err)
+	}
+
+	restarted, err := NewDLQStorage(storage.config, zap.NewNop(), "metrics")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer restarted.Shutdown()
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Fatalf("expected the expired file to be removed at startup, got %v", err)
+	}
+}
+
+// benchmarkBurst writes a burst of records and reports the writes to the DLQ
+// files made per burst.
+func benchmarkBurst(b *testing.B, configure func(*Config)) {
+	const burst = 1000
+	record := make([]byte, 1024)
+
+	var writes uint64
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		storage, _ := newTestStorage(b, configure)
+		b.StartTimer()
+
+		for j := 0; j < burst; j++ {
+			if err := storage.Write(context.Background(), record); err != nil {
+				b.Fatalf("failed to write record: %v", err)
+			}
+		}
+		if err := storage.Shutdown(); err != nil {
+			b.Fatalf("failed to shut down storage: %v", err)
+		}
+		writes += writeCount(b, storage)
+	}
+	b.ReportMetric(float64(writes)/float64(b.N), "writes/burst")
+}
+
+func BenchmarkBurstSyncAlways(b *testing.B) {
+	benchmarkBurst(b, nil)
+}
+
+func BenchmarkBurstSyncInterval(b *testing.B) {
+	benchmarkBurst(b, func(config *Config) {
+		config.SyncPolicy = SyncPolicyInterval
+		config.FlushIntervalMs = 1000
+		config.MaxBatchRecords = 256
+	})
+}
+
+func TestSetClockWhileWriting(t *testing.T) {
+	storage, fake := newTestStorage(t, nil)
+
+	// Swapping the clock while records are written and the storage's loops
+	// run must not race with them, run with -race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			if err := storage.Write(context.Background(), []byte(fmt.Sprintf("record-%d", i))); err != nil {
+				t.Errorf("failed to write record: %v", err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		fake.Advance(time.Second)
+		storage.SetClock(fake)
+	}
+	<-done
+
+	if got := len(readAllRecords(t, storage)); got != 50 {
+		t.Fatalf("expected 50 records, got %d", got)
+	}
+}
@@ -0,0 +1,148 @@
+package enhanceddlq
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// metricTimestamps returns every timestamp in the metrics, in traversal
+// order: the start and observation time of each data point followed by the
+// times of its exemplars.
+func metricTimestamps(md pmetric.Metrics) []pcommon.Timestamp {
+	var timestamps []pcommon.Timestamp
+	addExemplars := func(exemplars pmetric.ExemplarSlice) {
+		for i := 0; i < exemplars.Len(); i++ {
+			timestamps = append(timestamps, exemplars.At(i).Timestamp())
+		}
+	}
+
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			metrics := sms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+				switch metric.Type() {
+				case pmetric.MetricTypeGauge:
+					dps := metric.Gauge().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						timestamps = append(timestamps, dps.At(l).StartTimestamp(), dps.At(l).Timestamp())
+						addExemplars(dps.At(l).Exemplars())
+					}
+				case pmetric.MetricTypeSum:
+					dps := metric.Sum().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						timestamps = append(timestamps, dps.At(l).StartTimestamp(), dps.At(l).Timestamp())
+						addExemplars(dps.At(l).Exemplars())
+					}
+				case pmetric.MetricTypeHistogram:
+					dps := metric.Histogram().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						timestamps = append(timestamps, dps.At(l).StartTimestamp(), dps.At(l).Timestamp())
+						addExemplars(dps.At(l).Exemplars())
+					}
+				case pmetric.MetricTypeExponentialHistogram:
+					dps := metric.ExponentialHistogram().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						timestamps = append(timestamps, dps.At(l).StartTimestamp(), dps.At(l).Timestamp())
+						addExemplars(dps.At(l).Exemplars())
+					}
+				case pmetric.MetricTypeSummary:
+					dps := metric.Summary().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						timestamps = append(timestamps, dps.At(l).StartTimestamp(), dps.At(l).Timestamp())
+					}
+				}
+			}
+		}
+	}
+	return timestamps
+}
+
+// traceTimestamps returns every timestamp in the traces, in traversal order:
+// the start and end time of each span followed by the times of its events.
+func traceTimestamps(td ptrace.Traces) []pcommon.Timestamp {
+	var timestamps []pcommon.Timestamp
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		sss := rss.At(i).ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			spans := sss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				timestamps = append(timestamps, span.StartTimestamp(), span.EndTimestamp())
+				for l := 0; l < span.Events().Len(); l++ {
+					timestamps = append(timestamps, span.Events().At(l).Timestamp())
+				}
+			}
+		}
+	}
+	return timestamps
+}
+
+// logTimestamps returns every timestamp in the logs, in traversal order: the
+// time and observed time of each log record.
+func logTimestamps(ld plog.Logs) []pcommon.Timestamp {
+	var timestamps []pcommon.Timestamp
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		sls := rls.At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			records := sls.At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				timestamps = append(timestamps, records.At(k).Timestamp(), records.At(k).ObservedTimestamp())
+			}
+		}
+	}
+	return timestamps
+}
+
+// compareTimestamps returns an error describing the first timestamp that
+// differs, to the nanosecond, between the original data and the data decoded
+// from its serialized form.
+func compareTimestamps(original []pcommon.Timestamp, decoded []pcommon.Timestamp) error {
+	if len(original) != len(decoded) {
+		return fmt.Errorf("serialized data has %d timestamps, expected %d", len(decoded), len(original))
+	}
+	for i := range original {
+		if original[i] != decoded[i] {
+			return fmt.Errorf("timestamp %d changed from %d to %d when serialized", i, uint64(original[i]), uint64(decoded[i]))
+		}
+	}
+	return nil
+}
+
+// verifyMetricTimestamps decodes serialized metrics and checks that every
+// timestamp of the original survived.
+func verifyMetricTimestamps(md pmetric.Metrics, serialized []byte, format string) error {
+	decoded, err := deserializeMetrics(serialized, format)
+	if err != nil {
+		return fmt.Errorf("failed to decode serialized metrics: %w", err)
+	}
+	return compareTimestamps(metricTimestamps(md), metricTimestamps(decoded))
+}
+
+// verifyTraceTimestamps decodes serialized traces and checks that every
+// timestamp of the original survived.
+func verifyTraceTimestamps(td ptrace.Traces, serialized []byte, format string) error {
+	decoded, err := deserializeTraces(serialized, format)
+	if err != nil {
+		return fmt.Errorf("failed to decode serialized traces: %w", err)
+	}
+	return compareTimestamps(traceTimestamps(td), traceTimestamps(decoded))
+}
+
+// verifyLogTimestamps decodes serialized logs and checks that every
+// timestamp of the original survived.
+func verifyLogTimestamps(ld plog.Logs, serialized []byte, format string) error {
+	decoded, err := deserializeLogs(serialized, format)
+	if err != nil {
+		return fmt.Errorf("failed to decode serialized logs: %w", err)
+	}
+	return compareTimestamps(logTimestamps(ld), logTimestamps(decoded))
+}
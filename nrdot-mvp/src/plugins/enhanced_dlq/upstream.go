@@ -0,0 +1,193 @@
+package enhanceddlq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// UpstreamConfig defines the OTLP/HTTP endpoint data is exported to before
+// it is written to the DLQ.
+type UpstreamConfig struct {
+	// Endpoint is the base URL of the OTLP/HTTP receiver, such as
+	// "http://collector:4318". The signal path is appended. An empty endpoint
+	// disables the upstream export and all data is written to the DLQ.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// TimeoutMs is the timeout for each export request
+	TimeoutMs int `mapstructure:"timeout_ms"`
+
+	// Headers are added to each export request
+	Headers map[string]string `mapstructure:"headers"`
+}
+
+// How long the upstream is skipped after a failed export. The backoff
+// doubles with each failed probe up to the maximum, and resets once an
+// export succeeds.
+const (
+	upstreamBackoffInitial = 1 * time.Second
+	upstreamBackoffMax     = 1 * time.Minute
+)
+
+// errUpstreamBackoff is returned while the upstream is skipped after a
+// failure, so the data goes straight to the DLQ.
+var errUpstreamBackoff = errors.New("upstream is backing off after a failed export")
+
+// errNoReplayTarget is returned when replaying without an upstream endpoint,
+// which would otherwise drop every replayed record.
+var errNoReplayTarget = errors.New("no upstream endpoint configured to replay to")
+
+// otlpUpstream exports data to an OTLP/HTTP endpoint using protobuf encoding.
+type otlpUpstream struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+
+	// Backoff after failed exports, so a down endpoint doesn't hold every
+	// batch for the timeout before it reaches the DLQ
+	clock   clock.Clock
+	backoff time.Duration
+	retryAt time.Time
+	mutex   sync.Mutex
+}
+
+// newOTLPUpstream creates an upstream exporter, or returns nil if no
+// endpoint is configured.
+func newOTLPUpstream(config UpstreamConfig) *otlpUpstream {
+	if config.Endpoint == "" {
+		return nil
+	}
+
+	return &otlpUpstream{
+		endpoint: strings.TrimSuffix(config.Endpoint, "/"),
+		headers:  config.Headers,
+		client: &http.Client{
+			Timeout: time.Duration(config.TimeoutMs) * time.Millisecond,
+		},
+		clock: clock.Real(),
+	}
+}
+
+// allow returns whether an export may be attempted. While backing off it
+// returns false until the backoff ends, then lets a single export through to
+// probe the endpoint, pushing the next probe out in case that one fails too.
+func (u *otlpUpstream) allow() bool {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if u.backoff == 0 {
+		return true
+	}
+	now := u.clock.Now()
+	if now.Before(u.retryAt) {
+		return false
+	}
+	u.retryAt = now.Add(u.backoff)
+	return true
+}
+
+// record updates the backoff with the outcome of an export.
+func (u *otlpUpstream) record(err error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if err == nil {
+		u.backoff = 0
+		return
+	}
+	switch {
+	case u.backoff == 0:
+		u.backoff = upstreamBackoffInitial
+	case u.backoff < upstreamBackoffMax:
+		u.backoff = min(u.backoff*2, upstreamBackoffMax)
+	}
+	u.retryAt = u.clock.Now().Add(u.backoff)
+}
+
+// ExportMetrics exports metrics to the endpoint.
+func (u *otlpUpstream) ExportMetrics(ctx context.Context, md pmetric.Metrics) error {
+	body, err := pmetricotlp.NewExportRequestFromMetrics(md).MarshalProto()
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+	return u.export(ctx, "/v1/metrics", body)
+}
+
+// ExportTraces exports traces to the endpoint.
+func (u *otlpUpstream) ExportTraces(ctx context.Context, td ptrace.Traces) error {
+	body, err := ptraceotlp.NewExportRequestFromTraces(td).MarshalProto()
+	if err != nil {
+		return fmt.Errorf("failed to encode traces: %w", err)
+	}
+	return u.export(ctx, "/v1/traces", body)
+}
+
+// ExportLogs exports logs to the endpoint.
+func (u *otlpUpstream) ExportLogs(ctx context.Context, ld plog.Logs) error {
+	body, err := plogotlp.NewExportRequestFromLogs(ld).MarshalProto()
+	if err != nil {
+		return fmt.Errorf("failed to encode logs: %w", err)
+	}
+	return u.export(ctx, "/v1/logs", body)
+}
+
+// export posts an encoded export request. Any response other than 2xx is an
+// error. While backing off after a failure, it fails without a request,
+// except for replayed data, which the replay already paces.
+func (u *otlpUpstream) export(ctx context.Context, path string, body []byte) error {
+	if !IsReplay(ctx) && !u.allow() {
+		return errUpstreamBackoff
+	}
+	err := u.post(ctx, path, body)
+	if ctx.Err() == nil {
+		// A cancelled caller says nothing about the endpoint
+		u.record(err)
+	}
+	return err
+}
+
+// post sends an encoded export request to the endpoint.
+func (u *otlpUpstream) post(ctx context.Context, path string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range u.headers {
+		req.Header.Set(k, v)
+	}
+	if IsReplay(ctx) {
+		// Lets a collector behind the endpoint queue replayed data behind
+		// live data
+		req.Header.Set(ReplayHeader, "true")
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export to %s: %w", u.endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection can be reused
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("export to %s failed with status %d", u.endpoint, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package enhanceddlq
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+func TestUpstreamBacksOffWhileDown(t *testing.T) {
+	var requests int64
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	upstream := newOTLPUpstream(UpstreamConfig{Endpoint: server.URL, TimeoutMs: 1000})
+	fake := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	upstream.clock = fake
+	md := pmetric.NewMetrics()
+
+	if err := upstream.ExportMetrics(context.Background(), md); err == nil || errors.Is(err, errUpstreamBackoff) {
+		t.Fatalf("expected the first export to reach the endpoint and fail, got %v", err)
+	}
+
+	// Further batches skip the endpoint until the backoff ends
+	for i := 0; i < 10; i++ {
+		if err := upstream.ExportMetrics(context.Background(), md); !errors.Is(err, errUpstreamBackoff) {
+			t.Fatalf("expected the export to be skipped while backing off, got %v", err)
+		}
+	}
+	if got := atomic.LoadInt64(&requests); got != 1 {
+		t.Fatalf("expected 1 request while backing off, got %d", got)
+	}
+
+	// A failed probe doubles the backoff
+	fake.Advance(upstreamBackoffInitial)
+	upstream.ExportMetrics(context.Background(), md)
+	fake.Advance(upstreamBackoffInitial)
+	if err := upstream.ExportMetrics(context.Background(), md); !errors.Is(err, errUpstreamBackoff) {
+		t.Fatalf("expected the backoff to double after a failed probe, got %v", err)
+	}
+
+	// A successful probe resets it
+	healthy.Store(true)
+	fake.Advance(upstreamBackoffInitial)
+	if err := upstream.ExportMetrics(context.Background(), md); err != nil {
+		t.Fatalf("expected the probe to succeed, got %v", err)
+	}
+	if err := upstream.ExportMetrics(context.Background(), md); err != nil {
+		t.Fatalf("expected exports to go through once the endpoint is back, got %v", err)
+	}
+	if got := atomic.LoadInt64(&requests); got != 4 {
+		t.Fatalf("expected 4 requests, got %d", got)
+	}
+}
+
+func TestReplayedRecordsReachUpstreamWhileBackingOff(t *testing.T) {
+	var requests, replayed int64
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		if r.Header.Get(ReplayHeader) != "" {
+			atomic.AddInt64(&replayed, 1)
+		}
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	upstream := newOTLPUpstream(UpstreamConfig{Endpoint: server.URL, TimeoutMs: 1000})
+	upstream.clock = clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	upstream.ExportMetrics(context.Background(), pmetric.NewMetrics())
+
+	// Replay sends records back to the upstream, which the backoff doesn't
+	// hold up
+	healthy.Store(true)
+	data, err := serializeMetrics(pmetric.NewMetrics(), SerializationFormatProtobuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := &DLQRecord{Data: data, Format: SerializationFormatProtobuf}
+	replayConsumer := &metricsReplayConsumer{logger: zap.NewNop(), upstream: upstream}
+	if err := replayConsumer.ConsumeDLQRecord(context.Background(), record); err != nil {
+		t.Fatalf("expected the replayed record to be exported, got %v", err)
+	}
+	if got := atomic.LoadInt64(&requests); got != 2 {
+		t.Fatalf("expected 2 requests, got %d", got)
+	}
+
+	// Only the replayed export is marked as replayed
+	if got := atomic.LoadInt64(&replayed); got != 1 {
+		t.Fatalf("expected 1 request with the replay header, got %d", got)
+	}
+
+	// And a successful replayed export ends the backoff for live data
+	if err := upstream.ExportMetrics(context.Background(), pmetric.NewMetrics()); err != nil {
+		t.Fatalf("expected live exports to resume, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package enhanceddlq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// writeBuffer is a bounded in-memory queue in front of the DLQ files. It
+// absorbs write bursts faster than the disk can take, and a background writer
+// drains it to disk. Once full, the oldest records are dropped to make room.
+type writeBuffer struct {
+	maxBytes int
+
+	// Records waiting to be written, oldest first
+	records []fallbackRecord
+	bytes   int
+	dropped int64
+	mutex   sync.Mutex
+
+	// Signals the buffer writer that records are waiting
+	notify chan struct{}
+}
+
+// newWriteBuffer creates a write buffer holding at most maxMiB of records.
+func newWriteBuffer(maxMiB int) *writeBuffer {
+	return &writeBuffer{
+		maxBytes: maxMiB * 1024 * 1024,
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+// add appends a record, dropping the oldest records if the buffer would
+// exceed its limit, and signals the buffer writer.
+func (b *writeBuffer) add(data []byte, priority string) {
+	b.mutex.Lock()
+	b.records = append(b.records, fallbackRecord{data: data, priority: priority})
+	b.bytes += len(data)
+	b.evictLocked()
+	b.mutex.Unlock()
+
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+// evictLocked drops the oldest records until the buffer is within its limit,
+// always keeping the newest record. The caller must hold the mutex.
+func (b *writeBuffer) evictLocked() {
+	evict := 0
+	for b.bytes > b.maxBytes && evict < len(b.records)-1 {
+		b.bytes -= len(b.records[evict].data)
+		evict++
+	}
+	if evict == 0 {
+		return
+	}
+
+	b.dropped += int64(evict)
+	b.records = append([]fallbackRecord(nil), b.records[evict:]...)
+}
+
+// take removes and returns the oldest records, up to maxRecords records or
+// maxBytes of data but always at least one if any are waiting.
+func (b *writeBuffer) take(maxRecords int, maxBytes int) []fallbackRecord {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	n, size := 0, 0
+	for n < len(b.records) && n < maxRecords {
+		if n > 0 && size+len(b.records[n].data) > maxBytes {
+			break
+		}
+		size += len(b.records[n].data)
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+
+	records := append([]fallbackRecord(nil), b.records[:n]...)
+	b.records = b.records[n:]
+	b.bytes -= size
+	return records
+}
+
+// putBack returns records that failed to write to the front of the buffer,
+// dropping the oldest if newer records have filled it in the meantime.
+func (b *writeBuffer) putBack(records []fallbackRecord) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, record := range records {
+		b.bytes += len(record.data)
+	}
+	b.records = append(records, b.records...)
+	b.evictLocked()
+}
+
+// stats returns the bytes waiting to be written and the number of records
+// dropped so far.
+func (b *writeBuffer) stats() (int, int64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.bytes, b.dropped
+}
+
+// bufferLoop drains the write buffer to disk in batches of up to
+// MaxBatchRecords records and MaxBatchBytes bytes, waiting a flush interval
+// before retrying after a failed write.
+func (s *DLQStorage) bufferLoop(ctx context.Context) {
+	retryInterval := time.Duration(s.config.FlushIntervalMs) * time.Millisecond
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.buffer.notify:
+		}
+
+		for s.drainBuffer() {
+		}
+
+		// Records are still waiting after a failed write
+		if bytes, _ := s.buffer.stats(); bytes > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryInterval):
+			}
+			select {
+			case s.buffer.notify <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// drainBuffer writes the next batch from the write buffer, and returns
+// whether it wrote one. Records that fail to write are put back for the
+// next attempt, or handed to the fallback once the failures engage it.
+func (s *DLQStorage) drainBuffer() bool {
+	records := s.buffer.take(s.config.MaxBatchRecords, s.config.MaxBatchBytes)
+	if len(records) == 0 {
+		return false
+	}
+
+	if s.fallback.IsActive() {
+		for _, record := range records {
+			s.fallback.Store(record.data, record.priority)
+		}
+		return true
+	}
+
+	if err := s.writeRecords(records); err != nil {
+		if !s.fallback.RecordFailure() {
+			s.logger.Warn("Failed to write buffered DLQ records, retrying",
+				zap.Error(err),
+				zap.Int("records", len(records)),
+			)
+			s.buffer.putBack(records)
+			return false
+		}
+
+		s.logger.Error("DLQ directory is unwritable, engaging fallback",
+			zap.Error(err),
+			zap.String("directory", s.config.Directory),
+			zap.String("fallbackMode", s.config.FallbackMode),
+			zap.Int("retryIntervalSec", s.config.WriteRetryIntervalSec),
+		)
+		s.closeCurrentFile()
+		for _, record := range records {
+			s.fallback.Store(record.data, record.priority)
+		}
+		return true
+	}
+
+	s.fallback.RecordSuccess()
+	return true
+}
+
+// MemoryBufferStats returns the bytes waiting in the write buffer and the
+// number of records it has dropped, both 0 if it is disabled.
+func (s *DLQStorage) MemoryBufferStats() (int, int64) {
+	if s.buffer == nil {
+		return 0, 0
+	}
+	return s.buffer.stats()
+}
@@ -0,0 +1,50 @@
+package enhanceddlq
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWriteBufferAbsorbsBurstUpToCap(t *testing.T) {
+	storage, _ := newTestStorage(t, func(config *Config) {
+		config.MemoryBufferMiB = 1
+	})
+
+	record := make([]byte, 64*1024)
+	write := func(n int) {
+		for i := 0; i < n; i++ {
+			if err := storage.Write(context.Background(), record); err != nil {
+				t.Fatalf("failed to write record: %v", err)
+			}
+		}
+	}
+
+	// Stall the disk writer, as a disk that can't keep up would
+	storage.currentFileMutex.Lock()
+
+	// A burst within the buffer is absorbed without blocking or dropping
+	write(8)
+	if _, dropped := storage.MemoryBufferStats(); dropped != 0 {
+		storage.currentFileMutex.Unlock()
+		t.Fatalf("expected a burst within the cap to be absorbed, %d records dropped", dropped)
+	}
+
+	// Beyond the cap, the oldest buffered records make room
+	write(16)
+	bytes, dropped := storage.MemoryBufferStats()
+	storage.currentFileMutex.Unlock()
+	if dropped == 0 {
+		t.Fatal("expected records to be dropped once the buffer was full")
+	}
+	if bytes > 1024*1024 {
+		t.Fatalf("expected at most 1 MiB buffered, got %d bytes", bytes)
+	}
+
+	// Everything not dropped reaches the disk
+	if err := storage.Shutdown(); err != nil {
+		t.Fatalf("failed to shut down storage: %v", err)
+	}
+	if got := len(readAllRecords(t, storage)); int64(got) != 24-dropped {
+		t.Fatalf("expected %d records on disk, got %d", 24-dropped, got)
+	}
+}
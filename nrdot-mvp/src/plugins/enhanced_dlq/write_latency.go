@@ -0,0 +1,21 @@
+package enhanceddlq
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// writeLatencyBuckets are the upper bounds, in seconds, of the write latency
+// histogram, from a write absorbed by the page cache to a stalled fsync.
+var writeLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// newWriteLatencyHistogram creates the histogram of the time taken by writes
+// to the DLQ files, each a single write and fsync of one or more records.
+func newWriteLatencyHistogram() prometheus.Histogram {
+	return prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "write_duration_seconds",
+		Help:      "Time taken to write and fsync records to the DLQ files",
+		Buckets:   writeLatencyBuckets,
+	})
+}
@@ -0,0 +1,41 @@
+package enhanceddlq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestWriteLatencyRecordsWrites(t *testing.T) {
+	storage, _ := newTestStorage(t, nil)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(storage.writeLatency)
+
+	for i := 0; i < 3; i++ {
+		if err := storage.Write(context.Background(), []byte("record")); err != nil {
+			t.Fatalf("failed to write record: %v", err)
+		}
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	if len(families) != 1 {
+		t.Fatalf("expected one metric family, got %d", len(families))
+	}
+	if name := families[0].GetName(); name != "nrdot_mvp_dlq_write_duration_seconds" {
+		t.Errorf("unexpected histogram name %q", name)
+	}
+
+	histogram := families[0].GetMetric()[0].GetHistogram()
+	if count := histogram.GetSampleCount(); count != 3 {
+		t.Errorf("expected 3 writes observed, got %d", count)
+	}
+	// The storage is on a fake clock, so no time passes during the writes
+	if sum := histogram.GetSampleSum(); sum != 0 {
+		t.Errorf("expected write durations from the storage clock, got a sum of %v", sum)
+	}
+}
@@ -0,0 +1,219 @@
+// Package droplog provides a sampled, rate-limited structured log of dropped
+// telemetry, shared by the plugins so every drop is logged in the same format.
+package droplog
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// topAttributes is the number of attributes included in each log entry. The
+// fingerprint covers all of them.
+const topAttributes = 5
+
+// Config defines the drop log settings embedded in each plugin's configuration.
+type Config struct {
+	// Enabled turns on logging of dropped items
+	Enabled bool `mapstructure:"enabled"`
+
+	// SampleRate is the fraction of drops that are logged
+	// Default: 0.01
+	SampleRate float64 `mapstructure:"sample_rate"`
+
+	// MaxPerSecond caps the number of drops logged per second
+	// Default: 10
+	MaxPerSecond int `mapstructure:"max_per_second"`
+}
+
+// Validate validates the drop log configuration and applies defaults.
+func (cfg *Config) Validate() error {
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 0.01
+	} else if cfg.SampleRate > 1 {
+		return fmt.Errorf("drop_log sample_rate must be <= 1")
+	}
+
+	if cfg.MaxPerSecond <= 0 {
+		cfg.MaxPerSecond = 10
+	}
+
+	return nil
+}
+
+// DefaultConfig returns the default drop log configuration.
+func DefaultConfig() Config {
+	return Config{
+		Enabled:      false,
+		SampleRate:   0.01,
+		MaxPerSecond: 10,
+	}
+}
+
+// Logger logs a sample of dropped items. A nil Logger logs nothing, so
+// callers don't need to check whether drop logging is enabled.
+type Logger struct {
+	logger    *zap.Logger
+	component string
+	config    Config
+	clock     clock.Clock
+
+	// Drops seen, used to log a deterministic fraction of them
+	seen int64
+
+	// Logs emitted in the current second, and drops sampled but not logged
+	// because the cap was reached
+	windowStart time.Time
+	logged      int
+	suppressed  int64
+
+	mutex sync.Mutex
+}
+
+// New creates a drop logger for a component, or returns nil if drop logging
+// is disabled.
+func New(logger *zap.Logger, component string, config Config) *Logger {
+	if !config.Enabled {
+		return nil
+	}
+
+	return &Logger{
+		logger:    logger,
+		component: component,
+		config:    config,
+		clock:     clock.Real(),
+	}
+}
+
+// SetClock replaces the clock used to rate limit the log.
+func (l *Logger) SetClock(c clock.Clock) {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.clock = c
+}
+
+// LogData logs a dropped batch of metrics, traces or logs, fingerprinted by
+// the attributes of its first resource.
+func (l *Logger) LogData(reason string, data interface{}) {
+	if l == nil {
+		return
+	}
+
+	var signal string
+	var items int
+	attrs := pcommon.NewMap()
+
+	switch d := data.(type) {
+	case pmetric.Metrics:
+		signal, items = "metrics", d.DataPointCount()
+		if d.ResourceMetrics().Len() > 0 {
+			attrs = d.ResourceMetrics().At(0).Resource().Attributes()
+		}
+	case ptrace.Traces:
+		signal, items = "traces", d.SpanCount()
+		if d.ResourceSpans().Len() > 0 {
+			attrs = d.ResourceSpans().At(0).Resource().Attributes()
+		}
+	case plog.Logs:
+		signal, items = "logs", d.LogRecordCount()
+		if d.ResourceLogs().Len() > 0 {
+			attrs = d.ResourceLogs().At(0).Resource().Attributes()
+		}
+	default:
+		signal = fmt.Sprintf("%T", data)
+	}
+
+	attributes := make([]string, 0, attrs.Len())
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		attributes = append(attributes, k+"="+v.AsString())
+		return true
+	})
+	sort.Strings(attributes)
+
+	l.log(signal, reason, items, attributes)
+}
+
+// LogKeySet logs a dropped series identified by its key-set, a sorted list of
+// comma-separated name=value pairs.
+func (l *Logger) LogKeySet(signal string, reason string, key string) {
+	if l == nil {
+		return
+	}
+
+	var attributes []string
+	if key != "" {
+		attributes = strings.Split(key, ",")
+	}
+
+	l.log(signal, reason, 1, attributes)
+}
+
+// log writes the entry if the drop is sampled and the rate cap allows it.
+// attributes must be sorted.
+func (l *Logger) log(signal string, reason string, items int, attributes []string) {
+	l.mutex.Lock()
+
+	// Log a drop each time the sampled fraction of drops seen reaches the next whole number
+	l.seen++
+	if int64(float64(l.seen)*l.config.SampleRate) == int64(float64(l.seen-1)*l.config.SampleRate) {
+		l.mutex.Unlock()
+		return
+	}
+
+	now := l.clock.Now()
+	if now.Sub(l.windowStart) >= time.Second {
+		l.windowStart = now
+		l.logged = 0
+	}
+	if l.logged >= l.config.MaxPerSecond {
+		l.suppressed++
+		l.mutex.Unlock()
+		return
+	}
+	l.logged++
+	suppressed := l.suppressed
+	l.suppressed = 0
+	l.mutex.Unlock()
+
+	top := attributes
+	if len(top) > topAttributes {
+		top = top[:topAttributes]
+	}
+
+	l.logger.Info("Dropped telemetry",
+		zap.String("component", l.component),
+		zap.String("signal", signal),
+		zap.String("reason", reason),
+		zap.Int("items", items),
+		zap.String("fingerprint", fingerprint(attributes)),
+		zap.Strings("attributes", top),
+		zap.Int64("suppressed", suppressed),
+	)
+}
+
+// fingerprint returns a stable hash of the sorted attributes so drops of the
+// same series can be correlated.
+func fingerprint(attributes []string) string {
+	h := fnv.New64a()
+	for _, attr := range attributes {
+		h.Write([]byte(attr))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
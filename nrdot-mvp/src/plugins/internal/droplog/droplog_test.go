@@ -0,0 +1,73 @@
+package droplog
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+func TestDropsLoggedAtSampleRate(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := New(zap.New(core), "cardinality_limiter", Config{Enabled: true, SampleRate: 0.25, MaxPerSecond: 1000})
+	l.SetClock(clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	for i := 0; i < 100; i++ {
+		l.LogKeySet("metrics", "lru", "host.name=host-1,service.name=checkout")
+	}
+
+	entries := logs.FilterMessage("Dropped telemetry").All()
+	if len(entries) != 25 {
+		t.Fatalf("expected 25 of 100 drops to be logged, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	for key, expected := range map[string]interface{}{
+		"component":   "cardinality_limiter",
+		"signal":      "metrics",
+		"reason":      "lru",
+		"items":       int64(1),
+		"fingerprint": fingerprint([]string{"host.name=host-1", "service.name=checkout"}),
+		"suppressed":  int64(0),
+	} {
+		if fields[key] != expected {
+			t.Fatalf("expected %s to be %v, got %v", key, expected, fields[key])
+		}
+	}
+	if attributes, ok := fields["attributes"].([]interface{}); !ok || len(attributes) != 2 || attributes[0] != "host.name=host-1" {
+		t.Fatalf("expected the key-set attributes, got %v", fields["attributes"])
+	}
+}
+
+func TestDropLogRateLimited(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := New(zap.New(core), "adaptive_priority_queue", Config{Enabled: true, SampleRate: 1, MaxPerSecond: 2})
+	fake := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	l.SetClock(fake)
+
+	for i := 0; i < 10; i++ {
+		l.LogKeySet("metrics", "queue_full", "")
+	}
+	if got := logs.Len(); got != 2 {
+		t.Fatalf("expected 2 drops logged in the first second, got %d", got)
+	}
+
+	// The next second's first entry reports the drops suppressed before it
+	fake.Advance(time.Second)
+	l.LogKeySet("metrics", "queue_full", "")
+	entries := logs.All()
+	if len(entries) != 3 || entries[2].ContextMap()["suppressed"] != int64(8) {
+		t.Fatalf("expected a third entry reporting 8 suppressed drops, got %d entries", len(entries))
+	}
+}
+
+func TestDisabledDropLogIsNil(t *testing.T) {
+	l := New(zap.NewNop(), "adaptive_degradation_manager", DefaultConfig())
+	if l != nil {
+		t.Fatal("expected no logger when drop logging is disabled")
+	}
+	l.LogKeySet("metrics", "sampled", "service.name=checkout")
+}
@@ -0,0 +1,61 @@
+// Package health lets plugins publish the outcomes of their downstream sends
+// and the data they hold in memory, so other plugins in the same collector
+// can react to a failing backend or growing memory pressure.
+package health
+
+import (
+	"sync"
+)
+
+// OutcomeSource reports cumulative counts of successful and failed sends to
+// a downstream consumer.
+type OutcomeSource interface {
+	// Outcomes returns the number of successful and failed sends so far.
+	Outcomes() (successes, errors int64)
+}
+
+var (
+	sources      = make(map[string][]OutcomeSource)
+	sourcesMutex sync.Mutex
+)
+
+// Register publishes a source under a name, usually the component ID of the
+// plugin. Several sources may share a name, such as one per pipeline, and
+// their outcomes are added together. The returned function unregisters it.
+func Register(name string, source OutcomeSource) func() {
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+
+	sources[name] = append(sources[name], source)
+
+	return func() {
+		sourcesMutex.Lock()
+		defer sourcesMutex.Unlock()
+
+		registered := sources[name]
+		for i, s := range registered {
+			if s == source {
+				sources[name] = append(registered[:i:i], registered[i+1:]...)
+				break
+			}
+		}
+		if len(sources[name]) == 0 {
+			delete(sources, name)
+		}
+	}
+}
+
+// Outcomes returns the combined outcomes of the sources registered under a
+// name, and whether any are registered.
+func Outcomes(name string) (successes, errors int64, ok bool) {
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+
+	registered, ok := sources[name]
+	for _, source := range registered {
+		s, e := source.Outcomes()
+		successes += s
+		errors += e
+	}
+	return successes, errors, ok
+}
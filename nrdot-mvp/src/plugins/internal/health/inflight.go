@@ -0,0 +1,65 @@
+package health
+
+import (
+	"sync"
+)
+
+// InFlightSource reports the estimated size of the telemetry a plugin holds
+// in memory, such as queued batches or records waiting to be written.
+type InFlightSource interface {
+	InFlightBytes() int64
+}
+
+var (
+	inFlightSources      = make(map[string][]InFlightSource)
+	inFlightSourcesMutex sync.Mutex
+)
+
+// RegisterInFlight publishes an in-flight source under a name, usually the
+// component ID of the plugin. The returned function unregisters it.
+func RegisterInFlight(name string, source InFlightSource) func() {
+	inFlightSourcesMutex.Lock()
+	defer inFlightSourcesMutex.Unlock()
+
+	inFlightSources[name] = append(inFlightSources[name], source)
+
+	return func() {
+		inFlightSourcesMutex.Lock()
+		defer inFlightSourcesMutex.Unlock()
+
+		registered := inFlightSources[name]
+		for i, s := range registered {
+			if s == source {
+				inFlightSources[name] = append(registered[:i:i], registered[i+1:]...)
+				break
+			}
+		}
+		if len(inFlightSources[name]) == 0 {
+			delete(inFlightSources, name)
+		}
+	}
+}
+
+// TotalInFlight returns a source reporting the combined in-flight bytes of
+// every registered source.
+func TotalInFlight() InFlightSource {
+	return totalInFlight{}
+}
+
+// totalInFlight sums the registered in-flight sources.
+type totalInFlight struct{}
+
+// InFlightBytes returns the combined in-flight bytes of the registered
+// sources.
+func (totalInFlight) InFlightBytes() int64 {
+	inFlightSourcesMutex.Lock()
+	defer inFlightSourcesMutex.Unlock()
+
+	var total int64
+	for _, registered := range inFlightSources {
+		for _, source := range registered {
+			total += source.InFlightBytes()
+		}
+	}
+	return total
+}
@@ -0,0 +1,61 @@
+package health
+
+import (
+	"sort"
+	"sync"
+)
+
+// ReplaySource reports whether a plugin is replaying stored data, such as a
+// DLQ exporter recovering from an outage.
+type ReplaySource interface {
+	IsReplayActive() bool
+}
+
+var (
+	replaySources      = make(map[string][]ReplaySource)
+	replaySourcesMutex sync.Mutex
+)
+
+// RegisterReplay publishes a replay source under a name, usually the
+// component ID of the plugin. The returned function unregisters it.
+func RegisterReplay(name string, source ReplaySource) func() {
+	replaySourcesMutex.Lock()
+	defer replaySourcesMutex.Unlock()
+
+	replaySources[name] = append(replaySources[name], source)
+
+	return func() {
+		replaySourcesMutex.Lock()
+		defer replaySourcesMutex.Unlock()
+
+		registered := replaySources[name]
+		for i, s := range registered {
+			if s == source {
+				replaySources[name] = append(registered[:i:i], registered[i+1:]...)
+				break
+			}
+		}
+		if len(replaySources[name]) == 0 {
+			delete(replaySources, name)
+		}
+	}
+}
+
+// ActiveReplays returns the names of the registered sources currently
+// replaying, in name order.
+func ActiveReplays() []string {
+	replaySourcesMutex.Lock()
+	defer replaySourcesMutex.Unlock()
+
+	var active []string
+	for name, registered := range replaySources {
+		for _, source := range registered {
+			if source.IsReplayActive() {
+				active = append(active, name)
+				break
+			}
+		}
+	}
+	sort.Strings(active)
+	return active
+}
@@ -0,0 +1,164 @@
+// Package priority classifies telemetry by the priority carried in a resource
+// attribute, and provides the critical data policy shared by the plugins so
+// data classified critical is never discarded by any of them.
+package priority
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// Priority levels, highest first.
+const (
+	Critical = "critical"
+	High     = "high"
+	Normal   = "normal"
+)
+
+// order lists the priority levels from highest to lowest.
+var order = []string{Critical, High, Normal}
+
+// DefaultAttribute is the resource attribute carrying the priority of
+// telemetry unless configured otherwise.
+const DefaultAttribute = "nrdot.priority"
+
+// Config defines the critical data policy embedded in each plugin's
+// configuration.
+type Config struct {
+	// NeverDrop keeps data classified critical out of every drop and
+	// sampling decision. Where data would still be lost, it is written to the
+	// DLQ instead.
+	NeverDrop bool `mapstructure:"never_drop"`
+
+	// Attribute is the resource attribute whose value classifies the data
+	// Default: "nrdot.priority"
+	Attribute string `mapstructure:"attribute"`
+}
+
+// Validate validates the critical data policy and applies defaults.
+func (cfg *Config) Validate() error {
+	if cfg.Attribute == "" {
+		cfg.Attribute = DefaultAttribute
+	}
+	return nil
+}
+
+// DefaultConfig returns the default critical data policy.
+func DefaultConfig() Config {
+	return Config{
+		NeverDrop: false,
+		Attribute: DefaultAttribute,
+	}
+}
+
+// Of returns the priority of a resource, the lower-cased value of the
+// configured attribute, or "" if the resource doesn't carry one.
+func (cfg *Config) Of(resource pcommon.Resource) string {
+	value, ok := resource.Attributes().Get(cfg.attribute())
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(value.AsString()))
+}
+
+// Protected returns whether the policy keeps a resource's data from being
+// dropped.
+func (cfg *Config) Protected(resource pcommon.Resource) bool {
+	return cfg.NeverDrop && cfg.Of(resource) == Critical
+}
+
+// Highest returns the highest known priority in the list, or "" if none is
+// known.
+func Highest(priorities []string) string {
+	for _, level := range order {
+		for _, priority := range priorities {
+			if priority == level {
+				return level
+			}
+		}
+	}
+	return ""
+}
+
+// MetricsPriority returns the highest priority of the resources in a batch
+// of metrics, or "" if none carries a known priority.
+func (cfg *Config) MetricsPriority(md pmetric.Metrics) string {
+	priorities := make([]string, 0, md.ResourceMetrics().Len())
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		priorities = append(priorities, cfg.Of(md.ResourceMetrics().At(i).Resource()))
+	}
+	return Highest(priorities)
+}
+
+// TracesPriority returns the highest priority of the resources in a batch
+// of traces, or "" if none carries a known priority.
+func (cfg *Config) TracesPriority(td ptrace.Traces) string {
+	priorities := make([]string, 0, td.ResourceSpans().Len())
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		priorities = append(priorities, cfg.Of(td.ResourceSpans().At(i).Resource()))
+	}
+	return Highest(priorities)
+}
+
+// SplitMetrics removes the resources protected by the policy from a batch
+// of metrics and returns them. Nothing is removed unless NeverDrop is set.
+func (cfg *Config) SplitMetrics(md pmetric.Metrics) pmetric.Metrics {
+	protected := pmetric.NewMetrics()
+	if !cfg.NeverDrop {
+		return protected
+	}
+	md.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		if !cfg.Protected(rm.Resource()) {
+			return false
+		}
+		rm.MoveTo(protected.ResourceMetrics().AppendEmpty())
+		return true
+	})
+	return protected
+}
+
+// SplitTraces removes the resources protected by the policy from a batch of
+// traces and returns them. Nothing is removed unless NeverDrop is set.
+func (cfg *Config) SplitTraces(td ptrace.Traces) ptrace.Traces {
+	protected := ptrace.NewTraces()
+	if !cfg.NeverDrop {
+		return protected
+	}
+	td.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		if !cfg.Protected(rs.Resource()) {
+			return false
+		}
+		rs.MoveTo(protected.ResourceSpans().AppendEmpty())
+		return true
+	})
+	return protected
+}
+
+// SplitLogs removes the resources protected by the policy from a batch of
+// logs and returns them. Nothing is removed unless NeverDrop is set.
+func (cfg *Config) SplitLogs(ld plog.Logs) plog.Logs {
+	protected := plog.NewLogs()
+	if !cfg.NeverDrop {
+		return protected
+	}
+	ld.ResourceLogs().RemoveIf(func(rl plog.ResourceLogs) bool {
+		if !cfg.Protected(rl.Resource()) {
+			return false
+		}
+		rl.MoveTo(protected.ResourceLogs().AppendEmpty())
+		return true
+	})
+	return protected
+}
+
+// attribute returns the configured attribute, or the default if unset.
+func (cfg *Config) attribute() string {
+	if cfg.Attribute == "" {
+		return DefaultAttribute
+	}
+	return cfg.Attribute
+}
@@ -0,0 +1,198 @@
+// Package promreg registers the Prometheus metrics of plugin instances with
+// the default registerer, labelled with what tells the instances apart, such
+// as their component ID and signal, so several instances can publish the
+// same metrics side by side.
+package promreg
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// sharedCollector is a collector registered on behalf of every instance with
+// the same labels, such as the same processor in several pipelines.
+type sharedCollector struct {
+	collector prometheus.Collector
+	refs      int
+}
+
+// sharedKey identifies a shared collector.
+type sharedKey struct {
+	registerer prometheus.Registerer
+	labels     string
+	name       string
+}
+
+var (
+	sharedMutex      sync.Mutex
+	sharedCollectors = make(map[sharedKey]*sharedCollector)
+)
+
+// Registry registers the metrics of a plugin instance. Instances with the
+// same labels share each metric: the first to register it registers it,
+// later ones get the registered collector, and it stays registered until
+// every instance sharing it has unregistered. A collector reading the
+// instance's state, such as a GaugeFunc, therefore keeps reading the state
+// of the instance that registered it first.
+type Registry struct {
+	logger     *zap.Logger
+	base       prometheus.Registerer
+	registerer prometheus.Registerer
+	labels     string
+
+	// Collectors this instance holds a reference to, released by Unregister.
+	// Guarded by sharedMutex.
+	held []sharedKey
+}
+
+// New creates the registry of a plugin instance, adding labels to each of its
+// metrics.
+func New(logger *zap.Logger, labels prometheus.Labels) *Registry {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	for _, name := range names {
+		key.WriteString(name)
+		key.WriteByte('=')
+		key.WriteString(labels[name])
+		key.WriteByte(0)
+	}
+
+	return &Registry{
+		logger:     logger,
+		base:       prometheus.DefaultRegisterer,
+		registerer: prometheus.WrapRegistererWith(labels, prometheus.DefaultRegisterer),
+		labels:     key.String(),
+	}
+}
+
+// Register registers collector, which collects the metric called name, and
+// returns the collector to use: collector itself, or the one registered
+// first by an instance with the same labels. A collector that can't be
+// registered is logged and returned as is, so the instance works unpublished.
+func (r *Registry) Register(name string, collector prometheus.Collector) prometheus.Collector {
+	sharedMutex.Lock()
+	defer sharedMutex.Unlock()
+
+	key := sharedKey{registerer: r.base, labels: r.labels, name: name}
+	if shared, ok := sharedCollectors[key]; ok {
+		shared.refs++
+		r.held = append(r.held, key)
+		return shared.collector
+	}
+
+	if err := r.registerer.Register(collector); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			// Registered by someone else, who is left to unregister it
+			return already.ExistingCollector
+		}
+		r.logger.Warn("Failed to register metric", zap.String("name", name), zap.Error(err))
+		return collector
+	}
+
+	sharedCollectors[key] = &sharedCollector{collector: collector, refs: 1}
+	r.held = append(r.held, key)
+	return collector
+}
+
+// Counter creates and registers a counter.
+func (r *Registry) Counter(opts prometheus.CounterOpts) prometheus.Counter {
+	counter := prometheus.NewCounter(opts)
+	registered, ok := r.Register(counterName(opts), counter).(prometheus.Counter)
+	if !ok {
+		r.typeMismatch(counterName(opts))
+		return counter
+	}
+	return registered
+}
+
+// CounterVec creates and registers a counter with the given variable labels.
+func (r *Registry) CounterVec(opts prometheus.CounterOpts, labels ...string) *prometheus.CounterVec {
+	vec := prometheus.NewCounterVec(opts, labels)
+	registered, ok := r.Register(counterName(opts), vec).(*prometheus.CounterVec)
+	if !ok {
+		r.typeMismatch(counterName(opts))
+		return vec
+	}
+	return registered
+}
+
+// CounterFunc creates and registers a counter whose value is read from fn at
+// scrape time.
+func (r *Registry) CounterFunc(opts prometheus.CounterOpts, fn func() float64) {
+	r.Register(counterName(opts), prometheus.NewCounterFunc(opts, fn))
+}
+
+// Gauge creates and registers a gauge.
+func (r *Registry) Gauge(opts prometheus.GaugeOpts) prometheus.Gauge {
+	gauge := prometheus.NewGauge(opts)
+	registered, ok := r.Register(gaugeName(opts), gauge).(prometheus.Gauge)
+	if !ok {
+		r.typeMismatch(gaugeName(opts))
+		return gauge
+	}
+	return registered
+}
+
+// GaugeVec creates and registers a gauge with the given variable labels.
+func (r *Registry) GaugeVec(opts prometheus.GaugeOpts, labels ...string) *prometheus.GaugeVec {
+	vec := prometheus.NewGaugeVec(opts, labels)
+	registered, ok := r.Register(gaugeName(opts), vec).(*prometheus.GaugeVec)
+	if !ok {
+		r.typeMismatch(gaugeName(opts))
+		return vec
+	}
+	return registered
+}
+
+// GaugeFunc creates and registers a gauge whose value is read from fn at
+// scrape time.
+func (r *Registry) GaugeFunc(opts prometheus.GaugeOpts, fn func() float64) {
+	r.Register(gaugeName(opts), prometheus.NewGaugeFunc(opts, fn))
+}
+
+// typeMismatch logs that a metric is registered as another type, in which
+// case the caller keeps its own collector unpublished.
+func (r *Registry) typeMismatch(name string) {
+	r.logger.Warn("Metric registered with another type", zap.String("name", name))
+}
+
+// Unregister releases the collectors this instance holds, unregistering those
+// no other instance still shares.
+func (r *Registry) Unregister() {
+	sharedMutex.Lock()
+	defer sharedMutex.Unlock()
+
+	for _, key := range r.held {
+		shared := sharedCollectors[key]
+		if shared == nil {
+			continue
+		}
+		shared.refs--
+		if shared.refs == 0 {
+			r.registerer.Unregister(shared.collector)
+			delete(sharedCollectors, key)
+		}
+	}
+	r.held = nil
+}
+
+// counterName returns the fully-qualified name of a counter.
+func counterName(opts prometheus.CounterOpts) string {
+	return prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+}
+
+// gaugeName returns the fully-qualified name of a gauge.
+func gaugeName(opts prometheus.GaugeOpts) string {
+	return prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+}
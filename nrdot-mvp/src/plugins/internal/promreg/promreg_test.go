@@ -0,0 +1,64 @@
+package promreg
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+// useRegistry makes a fresh registry the default registerer for the test.
+func useRegistry(t *testing.T) *prometheus.Registry {
+	t.Helper()
+	registerer := prometheus.DefaultRegisterer
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	t.Cleanup(func() { prometheus.DefaultRegisterer = registerer })
+	return registry
+}
+
+func TestSharedCollectorOutlivesFirstInstance(t *testing.T) {
+	registry := useRegistry(t)
+	labels := prometheus.Labels{"processor": "cardinalitylimiter"}
+	opts := prometheus.CounterOpts{Name: "test_shared_total", Help: "Shared counter"}
+
+	// The same processor in two pipelines shares its counter
+	first := New(zap.NewNop(), labels)
+	second := New(zap.NewNop(), labels)
+	counter := first.Counter(opts)
+	if shared := second.Counter(opts); shared != counter {
+		t.Fatal("expected the second instance to share the registered counter")
+	}
+	counter.Inc()
+
+	first.Unregister()
+	if count, err := testutil.GatherAndCount(registry, "test_shared_total"); err != nil || count != 1 {
+		t.Fatalf("expected the counter to stay registered while shared, got %d (%v)", count, err)
+	}
+
+	second.Unregister()
+	if count, err := testutil.GatherAndCount(registry, "test_shared_total"); err != nil || count != 0 {
+		t.Fatalf("expected the counter to be unregistered by the last instance, got %d (%v)", count, err)
+	}
+}
+
+func TestInstancesWithDistinctLabelsPublishSideBySide(t *testing.T) {
+	registry := useRegistry(t)
+	opts := prometheus.GaugeOpts{Namespace: "test", Name: "level", Help: "Level"}
+
+	metrics := New(zap.NewNop(), prometheus.Labels{"processor": "adm", "signal": "metrics"})
+	traces := New(zap.NewNop(), prometheus.Labels{"processor": "adm", "signal": "traces"})
+	metrics.Gauge(opts).Set(1)
+	traces.GaugeFunc(opts, func() float64 { return 2 })
+
+	if count, err := testutil.GatherAndCount(registry, "test_level"); err != nil || count != 2 {
+		t.Fatalf("expected a series per instance, got %d (%v)", count, err)
+	}
+
+	metrics.Unregister()
+	traces.Unregister()
+	if count, err := testutil.GatherAndCount(registry, "test_level"); err != nil || count != 0 {
+		t.Fatalf("expected both series to be unregistered, got %d (%v)", count, err)
+	}
+}
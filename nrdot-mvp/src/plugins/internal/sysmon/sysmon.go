@@ -0,0 +1,92 @@
+// Package sysmon samples process resource usage once for all the plugins in
+// a collector, so each doesn't have to stop the world to read memory stats.
+package sysmon
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/clock"
+)
+
+// sampleInterval is how long a memory sample is reused before it is read again.
+const sampleInterval = time.Second
+
+// MemorySource reports how close the process is to its memory limit.
+type MemorySource interface {
+	// MemoryUtilization returns the memory in use as a percentage of the limit.
+	MemoryUtilization() float64
+
+	// MemoryLimited returns whether a soft memory limit is set. Without one
+	// the utilization doesn't say how close the process is to running out.
+	MemoryLimited() bool
+}
+
+// SystemMonitor samples the process memory usage, caching each sample for
+// sampleInterval.
+type SystemMonitor struct {
+	clock clock.Clock
+
+	sampledAt   time.Time
+	utilization float64
+	mutex       sync.Mutex
+}
+
+var (
+	shared     *SystemMonitor
+	sharedOnce sync.Once
+)
+
+// Shared returns the monitor shared by all plugins in the process.
+func Shared() *SystemMonitor {
+	sharedOnce.Do(func() {
+		shared = New(clock.Real())
+	})
+	return shared
+}
+
+// New creates a monitor using the given clock to age samples.
+func New(clk clock.Clock) *SystemMonitor {
+	return &SystemMonitor{clock: clk}
+}
+
+// MemoryUtilization returns the heap and stack memory in use as a percentage
+// of the soft memory limit set with GOMEMLIMIT, or of the memory obtained from
+// the OS if no limit is set.
+func (m *SystemMonitor) MemoryUtilization() float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.clock.Now()
+	if !m.sampledAt.IsZero() && now.Sub(m.sampledAt) < sampleInterval {
+		return m.utilization
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	limit := float64(memStats.Sys)
+	if memLimit := memoryLimit(); memLimit != math.MaxInt64 {
+		limit = float64(memLimit)
+	}
+
+	used := float64(memStats.HeapInuse + memStats.StackInuse)
+	m.utilization = used / limit * 100
+	m.sampledAt = now
+	return m.utilization
+}
+
+// MemoryLimited returns whether a soft memory limit is set with GOMEMLIMIT or
+// debug.SetMemoryLimit.
+func (m *SystemMonitor) MemoryLimited() bool {
+	return memoryLimit() != math.MaxInt64
+}
+
+// memoryLimit returns the soft memory limit, math.MaxInt64 if none is set.
+// Reading it is cheap and doesn't stop the world.
+func memoryLimit() int64 {
+	return debug.SetMemoryLimit(-1)
+}
@@ -0,0 +1,47 @@
+package readiness
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// Config defines the configuration for the readiness extension.
+type Config struct {
+	// Endpoint is the address the readiness endpoint listens on.
+	// Default: ":13134"
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Path is the HTTP path of the readiness endpoint.
+	// Default: "/ready"
+	Path string `mapstructure:"path"`
+
+	// NotReadyDuringReplay reports not-ready while any DLQ exporter is
+	// replaying, so load balancers drain the collector during recovery.
+	// Default: false
+	NotReadyDuringReplay bool `mapstructure:"not_ready_during_replay"`
+}
+
+// Validate validates the extension configuration.
+func (cfg *Config) Validate() error {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = ":13134"
+	}
+
+	if cfg.Path == "" {
+		cfg.Path = "/ready"
+	} else if !strings.HasPrefix(cfg.Path, "/") {
+		return fmt.Errorf("path '%s' must start with '/'", cfg.Path)
+	}
+
+	return nil
+}
+
+// CreateDefaultConfig creates the default configuration for the extension.
+func CreateDefaultConfig() component.Config {
+	return &Config{
+		Endpoint: ":13134",
+		Path:     "/ready",
+	}
+}
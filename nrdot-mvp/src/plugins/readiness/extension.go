@@ -0,0 +1,96 @@
+package readiness
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/health"
+)
+
+// readinessExtension serves an HTTP readiness endpoint for load balancers.
+type readinessExtension struct {
+	logger *zap.Logger
+	config *Config
+	server *http.Server
+
+	// Returns the names of the plugins currently replaying
+	activeReplays func() []string
+}
+
+// readinessResponse is the body of a readiness response.
+type readinessResponse struct {
+	Status    string   `json:"status"`
+	Reason    string   `json:"reason,omitempty"`
+	Replaying []string `json:"replaying,omitempty"`
+}
+
+// newReadinessExtension creates a new readiness extension.
+func newReadinessExtension(logger *zap.Logger, config *Config) *readinessExtension {
+	return &readinessExtension{
+		logger:        logger,
+		config:        config,
+		activeReplays: health.ActiveReplays,
+	}
+}
+
+// Start starts serving the readiness endpoint.
+func (e *readinessExtension) Start(ctx context.Context, host component.Host) error {
+	listener, err := net.Listen("tcp", e.config.Endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", e.config.Endpoint, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(e.config.Path, e.handleReady)
+	e.server = &http.Server{Handler: mux}
+
+	go func() {
+		if err := e.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.logger.Error("Readiness endpoint stopped", zap.Error(err))
+		}
+	}()
+
+	e.logger.Info("Serving readiness endpoint",
+		zap.String("endpoint", e.config.Endpoint),
+		zap.String("path", e.config.Path),
+		zap.Bool("notReadyDuringReplay", e.config.NotReadyDuringReplay),
+	)
+	return nil
+}
+
+// Shutdown stops serving the readiness endpoint.
+func (e *readinessExtension) Shutdown(ctx context.Context) error {
+	if e.server == nil {
+		return nil
+	}
+	return e.server.Shutdown(ctx)
+}
+
+// handleReady reports ready, or not ready while a DLQ replay is active and
+// NotReadyDuringReplay is set.
+func (e *readinessExtension) handleReady(w http.ResponseWriter, r *http.Request) {
+	response := readinessResponse{Status: "ready"}
+	statusCode := http.StatusOK
+
+	if e.config.NotReadyDuringReplay {
+		if replaying := e.activeReplays(); len(replaying) > 0 {
+			response = readinessResponse{
+				Status:    "not ready",
+				Reason:    "dlq_replay",
+				Replaying: replaying,
+			}
+			statusCode = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,71 @@
+package readiness
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/src/plugins/internal/health"
+)
+
+// fakeReplay is a replay source toggled by the test.
+type fakeReplay struct {
+	active atomic.Bool
+}
+
+func (f *fakeReplay) IsReplayActive() bool {
+	return f.active.Load()
+}
+
+// ready requests readiness and returns the status code and response.
+func ready(t *testing.T, e *readinessExtension) (int, readinessResponse) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	e.handleReady(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+	var response readinessResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode readiness response: %v", err)
+	}
+	return rec.Code, response
+}
+
+func TestReadinessFlipsDuringReplay(t *testing.T) {
+	replay := &fakeReplay{}
+	unregister := health.RegisterReplay("enhanced_dlq/metrics", replay)
+	defer unregister()
+
+	config := CreateDefaultConfig().(*Config)
+	config.NotReadyDuringReplay = true
+	e := newReadinessExtension(zap.NewNop(), config)
+
+	if code, response := ready(t, e); code != http.StatusOK || response.Status != "ready" {
+		t.Fatalf("expected ready before the replay, got %d %+v", code, response)
+	}
+
+	replay.active.Store(true)
+	code, response := ready(t, e)
+	if code != http.StatusServiceUnavailable || response.Reason != "dlq_replay" {
+		t.Fatalf("expected not ready during the replay, got %d %+v", code, response)
+	}
+	if len(response.Replaying) != 1 || response.Replaying[0] != "enhanced_dlq/metrics" {
+		t.Fatalf("expected the replaying exporter to be named, got %v", response.Replaying)
+	}
+
+	// Without the option, a replay doesn't affect readiness
+	config.NotReadyDuringReplay = false
+	if code, _ := ready(t, e); code != http.StatusOK {
+		t.Fatalf("expected ready with not_ready_during_replay unset, got %d", code)
+	}
+	config.NotReadyDuringReplay = true
+
+	replay.active.Store(false)
+	if code, response := ready(t, e); code != http.StatusOK || response.Status != "ready" {
+		t.Fatalf("expected ready once the replay finished, got %d %+v", code, response)
+	}
+}
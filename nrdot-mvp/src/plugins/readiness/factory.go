@@ -0,0 +1,33 @@
+package readiness
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/extension"
+)
+
+const (
+	// The type of the extension.
+	typeStr = "readiness"
+)
+
+// NewFactory creates a new factory for the readiness extension.
+func NewFactory() extension.Factory {
+	return extension.NewFactory(
+		typeStr,
+		CreateDefaultConfig,
+		createExtension,
+		component.StabilityLevelAlpha,
+	)
+}
+
+// createExtension creates a new readiness extension based on the config.
+func createExtension(
+	ctx context.Context,
+	set extension.CreateSettings,
+	cfg component.Config,
+) (extension.Extension, error) {
+	extensionConfig := cfg.(*Config)
+	return newReadinessExtension(set.Logger, extensionConfig), nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Full method names of the OTLP export RPCs, used as the path label of the
+// request metrics.
+const (
+	grpcMetricsPath = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+	grpcTracesPath  = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+	grpcLogsPath    = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+)
+
+// startGRPCServer starts the OTLP gRPC server for metrics, traces and logs.
+func startGRPCServer() {
+	addr := fmt.Sprintf(":%d", config.GRPCPort)
+	logger.Info("Starting gRPC server", zap.String("addr", addr))
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Fatal("Failed to listen for gRPC", zap.Error(err))
+	}
+
+	server := newGRPCServer()
+	grpcServer.Store(server)
+
+	if err := server.Serve(listener); err != nil {
+		logger.Fatal("Failed to start gRPC server", zap.Error(err))
+	}
+}
+
+// newGRPCServer creates a gRPC server with the OTLP services registered.
+func newGRPCServer() *grpc.Server {
+	// Let requests up to the size limit through so they are rejected the
+	// same way as over HTTP rather than by the gRPC default limit
+	var options []grpc.ServerOption
+	if config.MaxRequestSize > 0 {
+		options = append(options, grpc.MaxRecvMsgSize(int(config.MaxRequestSize)+1))
+	}
+
+	server := grpc.NewServer(options...)
+	pmetricotlp.RegisterGRPCServer(server, &metricsGRPCService{})
+	ptraceotlp.RegisterGRPCServer(server, &tracesGRPCService{})
+	plogotlp.RegisterGRPCServer(server, &logsGRPCService{})
+	return server
+}
+
+// handleGRPCExport applies the same concurrency limit, outage, size limit,
+// latency and error simulation as handleOTLP to a gRPC export request of the
+// given size, returning the status error to respond with.
+func handleGRPCExport(path string, size int) error {
+	// Use one configuration snapshot for the whole request
+	cfg := liveConfig.Load()
+
+	select {
+	case requestSemaphore <- struct{}{}:
+		defer func() { <-requestSemaphore }()
+	default:
+		promRequestsFailed.WithLabelValues(path, "grpc", "too_many_requests").Inc()
+		return status.Error(codes.ResourceExhausted, "too many requests")
+	}
+
+	endRequest := beginRequest()
+	defer endRequest()
+
+	atomic.AddInt64(&requestsTotal, 1)
+	promRequestsTotal.WithLabelValues(path, "grpc").Inc()
+
+	if isInOutage(signalOfPath(path)) {
+		promRequestsFailed.WithLabelValues(path, "grpc", "outage").Inc()
+		atomic.AddInt64(&requestsFailed, 1)
+		return status.Error(codes.Unavailable, "service unavailable: simulated outage")
+	}
+
+	if cfg.MaxRequestSize > 0 && int64(size) > cfg.MaxRequestSize {
+		promRequestsFailed.WithLabelValues(path, "grpc", "too_large").Inc()
+		atomic.AddInt64(&requestsFailed, 1)
+		return status.Error(codes.ResourceExhausted, "request too large")
+	}
+
+	startTime := time.Now()
+
+	atomic.AddInt64(&bytesTotal, int64(size))
+	promBytesReceived.Add(float64(size))
+
+	if latency := simulatedLatency(cfg); latency > 0 {
+		time.Sleep(latency)
+	}
+
+	if cfg.ErrorRate > 0 && rand.Intn(100) < cfg.ErrorRate {
+		promRequestsFailed.WithLabelValues(path, "grpc", "simulated_error").Inc()
+		atomic.AddInt64(&requestsFailed, 1)
+		return status.Error(codes.Internal, "simulated error")
+	}
+
+	latency := time.Since(startTime)
+	promRequestLatency.WithLabelValues(path, "grpc").Observe(float64(latency.Milliseconds()))
+
+	if requestLogs.Sample(cfg.VerboseLogging, cfg.VerboseLogSampleRate) {
+		logger.Info("Processed gRPC request",
+			zap.String("path", path),
+			zap.Int("bytes", size),
+			zap.Duration("latency", latency),
+		)
+	}
+	return nil
+}
+
+// metricsGRPCService implements the OTLP metrics service.
+type metricsGRPCService struct {
+	pmetricotlp.UnimplementedGRPCServer
+}
+
+// Export receives a metrics export request.
+func (s *metricsGRPCService) Export(_ context.Context, req pmetricotlp.ExportRequest) (pmetricotlp.ExportResponse, error) {
+	size := (&pmetric.ProtoMarshaler{}).MetricsSize(req.Metrics())
+	return pmetricotlp.NewExportResponse(), handleGRPCExport(grpcMetricsPath, size)
+}
+
+// tracesGRPCService implements the OTLP traces service.
+type tracesGRPCService struct {
+	ptraceotlp.UnimplementedGRPCServer
+}
+
+// Export receives a traces export request.
+func (s *tracesGRPCService) Export(_ context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	size := (&ptrace.ProtoMarshaler{}).TracesSize(req.Traces())
+	return ptraceotlp.NewExportResponse(), handleGRPCExport(grpcTracesPath, size)
+}
+
+// logsGRPCService implements the OTLP logs service.
+type logsGRPCService struct {
+	plogotlp.UnimplementedGRPCServer
+}
+
+// Export receives a logs export request.
+func (s *logsGRPCService) Export(_ context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	size := (&plog.ProtoMarshaler{}).LogsSize(req.Logs())
+	return plogotlp.NewExportResponse(), handleGRPCExport(grpcLogsPath, size)
+}
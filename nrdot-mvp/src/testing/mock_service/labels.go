@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+)
+
+// otherLabel is the path or method label of requests outside the known set,
+// so unexpected requests can't grow the request metrics without bound.
+const otherLabel = "other"
+
+// Paths and methods counted by name in the request metrics.
+var (
+	knownPaths   = map[string]bool{"/v1/metrics": true, "/v1/traces": true, "/v1/logs": true}
+	knownMethods = map[string]bool{
+		http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+		http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+	}
+)
+
+// pathLabel returns the path label of a request.
+func pathLabel(r *http.Request) string {
+	if knownPaths[r.URL.Path] {
+		return r.URL.Path
+	}
+	return otherLabel
+}
+
+// methodLabel returns the method label of a request.
+func methodLabel(r *http.Request) string {
+	if knownMethods[r.Method] {
+		return r.Method
+	}
+	return otherLabel
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRandomPathsKeepLabelsBounded(t *testing.T) {
+	setupTestService(t)
+
+	// Random paths and methods, alongside the known ones
+	methods := []string{http.MethodPost, http.MethodGet, "BREW", "PROPFIND"}
+	for i := 0; i < 200; i++ {
+		path := fmt.Sprintf("/v1/%d/%x", i, rand.Int63())
+		if i%10 == 0 {
+			path = "/v1/metrics"
+		}
+		req := httptest.NewRequest(methods[i%len(methods)], path, strings.NewReader("payload"))
+		handleOTLP(httptest.NewRecorder(), req)
+	}
+
+	// Known paths and methods keep their own labels, the rest share "other"
+	if got := testutil.CollectAndCount(promRequestsTotal); got > 6 {
+		t.Fatalf("expected at most 6 path and method label pairs, got %d", got)
+	}
+	if got := testutil.ToFloat64(promRequestsTotal.WithLabelValues(otherLabel, otherLabel)); got != 90 {
+		t.Fatalf("expected 90 requests with an unknown path and method, got %v", got)
+	}
+	if got := testutil.ToFloat64(promRequestsTotal.WithLabelValues("/v1/metrics", http.MethodPost)); got != 10 {
+		t.Fatalf("expected 10 known requests to keep their labels, got %v", got)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Latency distributions of the artificial latency.
+const (
+	LatencyUniform = "uniform"
+	LatencyNormal  = "normal"
+	LatencyPareto  = "pareto"
+)
+
+// paretoShape is the shape of the pareto distribution. Lower values give
+// longer tails; at 2 the p99 is about 20 times the median above LatencyMin.
+const paretoShape = 2.0
+
+// validateLatencyDistribution returns an error for an unknown distribution.
+func validateLatencyDistribution(distribution string) error {
+	switch distribution {
+	case "", LatencyUniform, LatencyNormal, LatencyPareto:
+		return nil
+	}
+	return fmt.Errorf("invalid latency_distribution '%s', must be '%s', '%s' or '%s'",
+		distribution, LatencyUniform, LatencyNormal, LatencyPareto)
+}
+
+// simulatedLatency returns the artificial latency of a request, drawn from
+// the configured distribution, or 0 if LatencyMax is not set.
+func simulatedLatency(cfg *Config) time.Duration {
+	if cfg.LatencyMax <= 0 {
+		return 0
+	}
+
+	low := float64(cfg.LatencyMin)
+	spread := float64(cfg.LatencyMax - cfg.LatencyMin)
+	if spread < 0 {
+		spread = 0
+	}
+
+	var latency float64
+	switch cfg.LatencyDistribution {
+	case LatencyNormal:
+		// Centered between min and max, with 99.7% of requests in range
+		latency = low + spread/2 + rand.NormFloat64()*spread/6
+		if latency < low {
+			latency = low
+		}
+	case LatencyPareto:
+		// Lomax with its median halfway between min and max, capped at 100
+		// times max so a single draw can't stall the request indefinitely
+		scale := spread / 2 / (math.Pow(2, 1/paretoShape) - 1)
+		latency = low + scale*(math.Pow(1-rand.Float64(), -1/paretoShape)-1)
+		if limit := 100 * float64(cfg.LatencyMax); latency > limit {
+			latency = limit
+		}
+	default:
+		latency = low + rand.Float64()*spread
+	}
+
+	return time.Duration(latency * float64(time.Millisecond))
+}
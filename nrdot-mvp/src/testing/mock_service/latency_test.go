@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// latencyPercentiles draws latencies from the configured distribution and
+// returns their median and p99.
+func latencyPercentiles(cfg *Config, draws int) (time.Duration, time.Duration) {
+	latencies := make([]time.Duration, draws)
+	for i := range latencies {
+		latencies[i] = simulatedLatency(cfg)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies[draws/2], latencies[draws*99/100]
+}
+
+func TestLatencyDistributionTails(t *testing.T) {
+	for _, tc := range []struct {
+		distribution string
+		minRatio     float64 // The least p99 over the median
+		maxRatio     float64 // The most p99 over the median
+	}{
+		// Bounded by max, so the p99 is under twice the median of 30ms
+		{distribution: LatencyUniform, minRatio: 1.4, maxRatio: 1.8},
+		{distribution: LatencyNormal, minRatio: 1.3, maxRatio: 1.8},
+		// The long tail puts the p99 far above max
+		{distribution: LatencyPareto, minRatio: 8},
+	} {
+		t.Run(tc.distribution, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.LatencyMin = 10
+			cfg.LatencyMax = 50
+			cfg.LatencyDistribution = tc.distribution
+
+			median, p99 := latencyPercentiles(cfg, 20000)
+			if median < 25*time.Millisecond || median > 35*time.Millisecond {
+				t.Fatalf("expected the median to be about 30ms, got %v", median)
+			}
+			ratio := float64(p99) / float64(median)
+			if ratio < tc.minRatio || (tc.maxRatio > 0 && ratio > tc.maxRatio) {
+				t.Fatalf("expected p99 %v over median %v to be in [%v, %v], got %.2f",
+					p99, median, tc.minRatio, tc.maxRatio, ratio)
+			}
+			if tc.distribution != LatencyPareto && p99 > 50*time.Millisecond {
+				t.Fatalf("expected the p99 to stay within max, got %v", p99)
+			}
+		})
+	}
+}
+
+func TestLatencyDistributionValidated(t *testing.T) {
+	for distribution, valid := range map[string]bool{
+		"":             true,
+		LatencyUniform: true,
+		LatencyNormal:  true,
+		LatencyPareto:  true,
+		"exponential":  false,
+	} {
+		if err := validateLatencyDistribution(distribution); (err == nil) != valid {
+			t.Errorf("validateLatencyDistribution(%q) = %v, want valid %v", distribution, err, valid)
+		}
+	}
+}
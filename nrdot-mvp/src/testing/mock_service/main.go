@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -10,7 +9,6 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -18,48 +16,72 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
+
+	"github.com/yourusername/nrdot-mvp/internal/mockutil"
 )
 
 // Configuration for the mock service
 type Config struct {
 	// HTTP port to listen on
 	Port int `json:"port"`
-	
+
 	// Prometheus metrics port
 	MetricsPort int `json:"metrics_port"`
-	
+
+	// OTLP gRPC port, 0 to serve OTLP/HTTP only
+	GRPCPort int `json:"grpc_port"`
+
 	// Artificial latency in milliseconds (min-max)
 	LatencyMin int `json:"latency_min"`
 	LatencyMax int `json:"latency_max"`
-	
+
+	// Distribution of the artificial latency: "uniform" between min and max,
+	// "normal" centered between them, or "pareto" with a long tail above max
+	LatencyDistribution string `json:"latency_distribution"`
+
 	// Error rate percentage (0-100)
 	ErrorRate int `json:"error_rate"`
-	
+
 	// Whether to support the outage simulation mode
 	SupportOutageSimulation bool `json:"support_outage_simulation"`
-	
+
 	// Whether to validate request data
 	ValidateRequests bool `json:"validate_requests"`
-	
+
 	// Maximum request size in bytes
 	MaxRequestSize int64 `json:"max_request_size"`
-	
+
 	// How many requests to process before responding
 	SimultaneousRequests int `json:"simultaneous_requests"`
+
+	// Whether to log processed requests
+	VerboseLogging bool `json:"verbose_logging"`
+
+	// Log only 1 in this many requests when verbose, so high request rates
+	// don't flood the output
+	VerboseLogSampleRate int `json:"verbose_log_sample_rate"`
+
+	// Seconds to wait on shutdown for requests in progress to complete
+	// before exiting, 0 to exit straight away
+	ShutdownGracePeriodSec int `json:"shutdown_grace_period_sec"`
 }
 
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
-		Port:                  8080,
-		MetricsPort:           8081,
-		LatencyMin:            0,
-		LatencyMax:            50,
-		ErrorRate:             0,
+		Port:                    8080,
+		MetricsPort:             8081,
+		GRPCPort:                0,
+		LatencyMin:              0,
+		LatencyMax:              50,
+		LatencyDistribution:     LatencyUniform,
+		ErrorRate:               0,
 		SupportOutageSimulation: true,
-		ValidateRequests:      true,
-		MaxRequestSize:        10 * 1024 * 1024, // 10 MiB
-		SimultaneousRequests:  100,
+		ValidateRequests:        true,
+		MaxRequestSize:          10 * 1024 * 1024, // 10 MiB
+		SimultaneousRequests:    100,
+		VerboseLogSampleRate:    1,
+		ShutdownGracePeriodSec:  10,
 	}
 }
 
@@ -67,24 +89,31 @@ func DefaultConfig() *Config {
 var (
 	logger *zap.Logger
 	config *Config
-	
+
+	// Path of the configuration file, re-read on SIGHUP
+	configPath string
+
+	// Configuration in effect for request handling, swapped atomically on reload
+	liveConfig atomic.Pointer[Config]
+
 	// Runtime state
-	inOutage       bool
-	outageEndTime  time.Time
 	requestsTotal  int64
 	requestsFailed int64
 	bytesTotal     int64
-	
+
+	// Picks the requests logged with verbose logging
+	requestLogs mockutil.LogSampler
+
 	// Request throttle for simulating max simultaneous requests
 	requestSemaphore chan struct{}
-	
+
 	// Prometheus metrics
 	promRequestsTotal   *prometheus.CounterVec
 	promRequestsFailed  *prometheus.CounterVec
 	promRequestLatency  *prometheus.HistogramVec
-	promBytesReceived   *prometheus.Counter
-	promOutageStatus    *prometheus.Gauge
-	promCurrentRequests *prometheus.Gauge
+	promBytesReceived   prometheus.Counter
+	promOutageStatus    prometheus.Gauge
+	promCurrentRequests prometheus.Gauge
 )
 
 func main() {
@@ -92,8 +121,9 @@ func main() {
 	configFile := flag.String("config", "", "Path to configuration file")
 	port := flag.Int("port", 0, "HTTP port to listen on")
 	metricsPort := flag.Int("metrics-port", 0, "Prometheus metrics port")
+	grpcPort := flag.Int("grpc-port", 0, "OTLP gRPC port")
 	flag.Parse()
-	
+
 	// Initialize logger
 	var err error
 	logger, err = zap.NewProduction()
@@ -102,7 +132,7 @@ func main() {
 		os.Exit(1)
 	}
 	defer logger.Sync()
-	
+
 	// Load configuration
 	config = DefaultConfig()
 	if *configFile != "" {
@@ -110,7 +140,7 @@ func main() {
 			logger.Fatal("Failed to load configuration", zap.Error(err))
 		}
 	}
-	
+
 	// Override with command-line flags
 	if *port > 0 {
 		config.Port = *port
@@ -118,24 +148,31 @@ func main() {
 	if *metricsPort > 0 {
 		config.MetricsPort = *metricsPort
 	}
-	
+	if *grpcPort > 0 {
+		config.GRPCPort = *grpcPort
+	}
+
 	// Override from environment
-	if portStr := os.Getenv("PORT"); portStr != "" {
-		if port, err := fmt.Sscanf(portStr, "%d", &config.Port); err != nil {
-			logger.Warn("Invalid PORT environment variable", zap.Error(err))
-		}
+	if port, ok := mockutil.EnvInt("PORT", 1, 65535, logger.Sugar().Warnf); ok {
+		config.Port = port
 	}
-	
+
+	configPath = *configFile
+	liveConfig.Store(config)
+
 	// Initialize request semaphore
 	requestSemaphore = make(chan struct{}, config.SimultaneousRequests)
-	
+
 	// Initialize Prometheus metrics
 	initPrometheusMetrics()
-	
+
 	// Start HTTP servers
 	go startMetricsServer()
 	go startHTTPServer()
-	
+	if config.GRPCPort > 0 {
+		go startGRPCServer()
+	}
+
 	// Wait for shutdown signal
 	waitForShutdown()
 }
@@ -147,15 +184,69 @@ func loadConfig(path string, config *Config) error {
 	if err != nil {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
-	
+
 	// Parse JSON
 	if err := json.Unmarshal(data, config); err != nil {
 		return fmt.Errorf("failed to parse config file: %w", err)
 	}
-	
+
+	if err := validateLatencyDistribution(config.LatencyDistribution); err != nil {
+		return err
+	}
+	if config.ShutdownGracePeriodSec < 0 {
+		return fmt.Errorf("shutdown_grace_period_sec must not be negative, got %d", config.ShutdownGracePeriodSec)
+	}
+
 	return nil
 }
 
+// reloadConfig re-reads the configuration file and applies the settings that
+// can change at runtime. Settings that need a restart keep their current
+// values and are logged as ignored.
+func reloadConfig() {
+	if configPath == "" {
+		logger.Warn("Received SIGHUP but no configuration file was given, nothing to reload")
+		return
+	}
+
+	current := liveConfig.Load()
+	updated := *current
+	if err := loadConfig(configPath, &updated); err != nil {
+		logger.Error("Failed to reload configuration, keeping current settings", zap.Error(err))
+		return
+	}
+
+	// Listeners and the request semaphore are set up once at startup
+	if updated.Port != current.Port {
+		logger.Warn("Ignoring port change until restart", zap.Int("port", updated.Port))
+		updated.Port = current.Port
+	}
+	if updated.MetricsPort != current.MetricsPort {
+		logger.Warn("Ignoring metrics_port change until restart", zap.Int("metrics_port", updated.MetricsPort))
+		updated.MetricsPort = current.MetricsPort
+	}
+	if updated.SimultaneousRequests != current.SimultaneousRequests {
+		logger.Warn("Ignoring simultaneous_requests change until restart",
+			zap.Int("simultaneous_requests", updated.SimultaneousRequests))
+		updated.SimultaneousRequests = current.SimultaneousRequests
+	}
+
+	liveConfig.Store(&updated)
+
+	logger.Info("Reloaded configuration",
+		zap.Int("latencyMin", updated.LatencyMin),
+		zap.Int("latencyMax", updated.LatencyMax),
+		zap.String("latencyDistribution", updated.LatencyDistribution),
+		zap.Int("errorRate", updated.ErrorRate),
+		zap.Bool("validateRequests", updated.ValidateRequests),
+		zap.Int64("maxRequestSize", updated.MaxRequestSize),
+		zap.Bool("supportOutageSimulation", updated.SupportOutageSimulation),
+		zap.Bool("verboseLogging", updated.VerboseLogging),
+		zap.Int("verboseLogSampleRate", updated.VerboseLogSampleRate),
+		zap.Int("shutdownGracePeriodSec", updated.ShutdownGracePeriodSec),
+	)
+}
+
 // initPrometheusMetrics initializes Prometheus metrics.
 func initPrometheusMetrics() {
 	promRequestsTotal = prometheus.NewCounterVec(
@@ -165,7 +256,7 @@ func initPrometheusMetrics() {
 		},
 		[]string{"path", "method"},
 	)
-	
+
 	promRequestsFailed = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "mock_service_requests_failed_total",
@@ -173,7 +264,7 @@ func initPrometheusMetrics() {
 		},
 		[]string{"path", "method", "reason"},
 	)
-	
+
 	promRequestLatency = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "mock_service_request_latency_ms",
@@ -182,28 +273,28 @@ func initPrometheusMetrics() {
 		},
 		[]string{"path", "method"},
 	)
-	
+
 	promBytesReceived = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "mock_service_bytes_received_total",
 			Help: "Total number of bytes received",
 		},
 	)
-	
+
 	promOutageStatus = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "mock_service_outage_status",
 			Help: "Whether the service is in an outage state (0 = normal, 1 = outage)",
 		},
 	)
-	
+
 	promCurrentRequests = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "mock_service_current_requests",
 			Help: "Current number of active requests",
 		},
 	)
-	
+
 	// Register metrics
 	prometheus.MustRegister(
 		promRequestsTotal,
@@ -219,9 +310,9 @@ func initPrometheusMetrics() {
 func startMetricsServer() {
 	addr := fmt.Sprintf(":%d", config.MetricsPort)
 	logger.Info("Starting metrics server", zap.String("addr", addr))
-	
+
 	http.Handle("/metrics", promhttp.Handler())
-	
+
 	if err := http.ListenAndServe(addr, nil); err != nil {
 		logger.Fatal("Failed to start metrics server", zap.Error(err))
 	}
@@ -231,10 +322,10 @@ func startMetricsServer() {
 func startHTTPServer() {
 	addr := fmt.Sprintf(":%d", config.Port)
 	logger.Info("Starting HTTP server", zap.String("addr", addr))
-	
+
 	// Create router
 	mux := http.NewServeMux()
-	
+
 	// Register handlers
 	mux.HandleFunc("/v1/metrics", handleOTLP)
 	mux.HandleFunc("/v1/traces", handleOTLP)
@@ -242,13 +333,14 @@ func startHTTPServer() {
 	mux.HandleFunc("/healthz", handleHealthCheck)
 	mux.HandleFunc("/readyz", handleReadyCheck)
 	mux.HandleFunc("/outage", handleOutageControl)
-	
+
 	// Start server
 	server := &http.Server{
 		Addr:    addr,
 		Handler: mux,
 	}
-	
+	httpServer.Store(server)
+
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		logger.Fatal("Failed to start HTTP server", zap.Error(err))
 	}
@@ -256,6 +348,9 @@ func startHTTPServer() {
 
 // handleOTLP handles OTLP requests.
 func handleOTLP(w http.ResponseWriter, r *http.Request) {
+	// Use one configuration snapshot for the whole request
+	cfg := liveConfig.Load()
+
 	// Acquire semaphore
 	select {
 	case requestSemaphore <- struct{}{}:
@@ -267,82 +362,88 @@ func handleOTLP(w http.ResponseWriter, r *http.Request) {
 	default:
 		// Semaphore full, return service unavailable
 		http.Error(w, "Service unavailable: too many requests", http.StatusServiceUnavailable)
-		promRequestsFailed.WithLabelValues(r.URL.Path, r.Method, "too_many_requests").Inc()
+		promRequestsFailed.WithLabelValues(pathLabel(r), methodLabel(r), "too_many_requests").Inc()
 		return
 	}
-	
-	// Update current requests gauge
-	promCurrentRequests.Inc()
-	defer promCurrentRequests.Dec()
-	
+
+	// Count the request as active, so shutdown waits for it
+	endRequest := beginRequest()
+	defer endRequest()
+
 	// Record request
 	atomic.AddInt64(&requestsTotal, 1)
-	promRequestsTotal.WithLabelValues(r.URL.Path, r.Method).Inc()
-	
-	// Check if we're in an outage
-	if isInOutage() {
+	promRequestsTotal.WithLabelValues(pathLabel(r), methodLabel(r)).Inc()
+
+	// Check if this signal is in an outage
+	if isInOutage(signalOfPath(r.URL.Path)) {
 		http.Error(w, "Service unavailable: simulated outage", http.StatusServiceUnavailable)
-		promRequestsFailed.WithLabelValues(r.URL.Path, r.Method, "outage").Inc()
+		promRequestsFailed.WithLabelValues(pathLabel(r), methodLabel(r), "outage").Inc()
 		atomic.AddInt64(&requestsFailed, 1)
 		return
 	}
-	
+
 	// Check request size
-	if config.MaxRequestSize > 0 && r.ContentLength > config.MaxRequestSize {
+	if cfg.MaxRequestSize > 0 && r.ContentLength > cfg.MaxRequestSize {
 		http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
-		promRequestsFailed.WithLabelValues(r.URL.Path, r.Method, "too_large").Inc()
+		promRequestsFailed.WithLabelValues(pathLabel(r), methodLabel(r), "too_large").Inc()
 		atomic.AddInt64(&requestsFailed, 1)
 		return
 	}
-	
+
 	// Start timing request
 	startTime := time.Now()
-	
+
 	// Read request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		promRequestsFailed.WithLabelValues(r.URL.Path, r.Method, "read_error").Inc()
+		promRequestsFailed.WithLabelValues(pathLabel(r), methodLabel(r), "read_error").Inc()
 		atomic.AddInt64(&requestsFailed, 1)
 		return
 	}
-	
+
 	// Record bytes received
 	bodySize := int64(len(body))
 	atomic.AddInt64(&bytesTotal, bodySize)
 	promBytesReceived.Add(float64(bodySize))
-	
+
 	// Validate request if enabled
-	if config.ValidateRequests {
+	if cfg.ValidateRequests {
 		if !validateOTLP(r.URL.Path, body) {
 			http.Error(w, "Invalid request format", http.StatusBadRequest)
-			promRequestsFailed.WithLabelValues(r.URL.Path, r.Method, "invalid_format").Inc()
+			promRequestsFailed.WithLabelValues(pathLabel(r), methodLabel(r), "invalid_format").Inc()
 			atomic.AddInt64(&requestsFailed, 1)
 			return
 		}
 	}
-	
+
 	// Add artificial latency
-	if config.LatencyMax > 0 {
-		latency := config.LatencyMin
-		if config.LatencyMax > config.LatencyMin {
-			latency += rand.Intn(config.LatencyMax - config.LatencyMin)
-		}
-		time.Sleep(time.Duration(latency) * time.Millisecond)
+	if latency := simulatedLatency(cfg); latency > 0 {
+		time.Sleep(latency)
 	}
-	
+
 	// Simulate error if configured
-	if config.ErrorRate > 0 && rand.Intn(100) < config.ErrorRate {
+	if cfg.ErrorRate > 0 && rand.Intn(100) < cfg.ErrorRate {
 		http.Error(w, "Simulated error", http.StatusInternalServerError)
-		promRequestsFailed.WithLabelValues(r.URL.Path, r.Method, "simulated_error").Inc()
+		promRequestsFailed.WithLabelValues(pathLabel(r), methodLabel(r), "simulated_error").Inc()
 		atomic.AddInt64(&requestsFailed, 1)
 		return
 	}
-	
+
 	// Calculate request latency
 	latency := time.Since(startTime)
-	promRequestLatency.WithLabelValues(r.URL.Path, r.Method).Observe(float64(latency.Milliseconds()))
-	
+	promRequestLatency.WithLabelValues(pathLabel(r), methodLabel(r)).Observe(float64(latency.Milliseconds()))
+
+	// Log a sample of requests if verbose
+	if requestLogs.Sample(cfg.VerboseLogging, cfg.VerboseLogSampleRate) {
+		logger.Info("Processed request",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Int64("bytes", bodySize),
+			zap.Duration("latency", latency),
+		)
+	}
+
 	// Respond with success
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"accepted":true}`))
@@ -356,7 +457,7 @@ func validateOTLP(path string, body []byte) bool {
 		logger.Debug("Invalid JSON in request", zap.Error(err))
 		return false
 	}
-	
+
 	// In a real implementation, we would validate the OTLP format more thoroughly
 	return true
 }
@@ -370,13 +471,13 @@ func handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 
 // handleReadyCheck handles readiness check requests.
 func handleReadyCheck(w http.ResponseWriter, r *http.Request) {
-	// Return not ready if in outage
-	if isInOutage() {
+	// Return not ready if every signal is in an outage
+	if isInOutage(OutageSignalAll) {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		w.Write([]byte(`{"status":"not ready","reason":"outage"}`))
 		return
 	}
-	
+
 	// Otherwise return ready
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status":"ready"}`))
@@ -385,109 +486,127 @@ func handleReadyCheck(w http.ResponseWriter, r *http.Request) {
 // handleOutageControl handles outage control requests.
 func handleOutageControl(w http.ResponseWriter, r *http.Request) {
 	// Check if outage simulation is supported
-	if !config.SupportOutageSimulation {
+	if !liveConfig.Load().SupportOutageSimulation {
 		http.Error(w, "Outage simulation not supported", http.StatusBadRequest)
 		return
 	}
-	
+
 	// Check HTTP method
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	
+
 	// Parse request body
 	var req struct {
 		Action   string `json:"action"`
 		Duration int    `json:"duration_seconds"`
+		Signal   string `json:"signal"`
+
+		// Seconds the end of the outage is randomly delayed by, at most
+		RecoveryJitter int `json:"recovery_jitter_seconds"`
+
+		// Seconds a flapping outage is down and up in each cycle
+		FlapDown int `json:"flap_down_seconds"`
+		FlapUp   int `json:"flap_up_seconds"`
 	}
-	
+
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	
+
+	// Without a signal the outage affects every signal
+	if req.Signal == "" {
+		req.Signal = OutageSignalAll
+	}
+	if !validOutageSignal(req.Signal) {
+		http.Error(w, "Invalid signal", http.StatusBadRequest)
+		return
+	}
+	if req.RecoveryJitter < 0 || req.FlapDown < 0 || req.FlapUp < 0 {
+		http.Error(w, "Invalid outage timing", http.StatusBadRequest)
+		return
+	}
+
 	// Handle action
 	switch req.Action {
 	case "start":
 		if req.Duration <= 0 {
 			req.Duration = 60 // Default to 60 seconds
 		}
-		
+
 		// Start outage
-		startOutage(req.Duration)
+		o := newOutage(req.Duration, req.RecoveryJitter)
+		startOutage(req.Signal, o)
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(fmt.Sprintf(`{"status":"outage_started","duration_seconds":%d}`, req.Duration)))
-		
+		w.Write([]byte(fmt.Sprintf(`{"status":"outage_started","signal":%q,"duration_seconds":%d,"end_time":%q}`,
+			req.Signal, req.Duration, o.end.Format(time.RFC3339))))
+
+	case "flap":
+		if req.Duration <= 0 {
+			req.Duration = 60 // Default to 60 seconds
+		}
+		if req.FlapDown <= 0 {
+			req.FlapDown = 10
+		}
+		if req.FlapUp <= 0 {
+			req.FlapUp = 10
+		}
+
+		// Start a flapping outage
+		o := newOutage(req.Duration, req.RecoveryJitter)
+		o.down = time.Duration(req.FlapDown) * time.Second
+		o.up = time.Duration(req.FlapUp) * time.Second
+		startOutage(req.Signal, o)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf(`{"status":"flapping_started","signal":%q,"duration_seconds":%d,"flap_down_seconds":%d,"flap_up_seconds":%d,"end_time":%q}`,
+			req.Signal, req.Duration, req.FlapDown, req.FlapUp, o.end.Format(time.RFC3339))))
+
 	case "stop":
 		// Stop outage
-		stopOutage()
+		stopOutage(req.Signal)
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"outage_stopped"}`))
-		
+		w.Write([]byte(fmt.Sprintf(`{"status":"outage_stopped","signal":%q}`, req.Signal)))
+
 	default:
 		http.Error(w, "Invalid action", http.StatusBadRequest)
 	}
 }
 
-// startOutage starts a simulated outage for the specified duration.
-func startOutage(durationSeconds int) {
-	inOutage = true
-	outageEndTime = time.Now().Add(time.Duration(durationSeconds) * time.Second)
-	promOutageStatus.Set(1)
-	
-	logger.Info("Started simulated outage",
-		zap.Int("duration_seconds", durationSeconds),
-		zap.Time("end_time", outageEndTime),
-	)
-	
-	// Start a goroutine to automatically end the outage
-	go func() {
-		time.Sleep(time.Duration(durationSeconds) * time.Second)
-		stopOutage()
-	}()
-}
-
-// stopOutage stops the current simulated outage.
-func stopOutage() {
-	if !inOutage {
-		return
-	}
-	
-	inOutage = false
-	promOutageStatus.Set(0)
-	
-	logger.Info("Stopped simulated outage")
-}
-
-// isInOutage checks if the service is currently in a simulated outage.
-func isInOutage() bool {
-	if !inOutage {
-		return false
-	}
-	
-	// Check if outage has expired
-	if time.Now().After(outageEndTime) {
-		stopOutage()
-		return false
+// awaitShutdownSignal reloads the configuration on each SIGHUP received and
+// returns the first other signal.
+func awaitShutdownSignal(sigCh <-chan os.Signal) os.Signal {
+	sig := <-sigCh
+	for sig == syscall.SIGHUP {
+		reloadConfig()
+		sig = <-sigCh
 	}
-	
-	return true
+	return sig
 }
 
-// waitForShutdown waits for a shutdown signal.
+// waitForShutdown waits for a shutdown signal, reloading the configuration
+// on SIGHUP.
 func waitForShutdown() {
 	// Set up signal handling
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
 	// Wait for signal
-	sig := <-sigCh
+	sig := awaitShutdownSignal(sigCh)
 	logger.Info("Received shutdown signal", zap.String("signal", sig.String()))
-	
-	// Give ongoing requests a chance to complete
-	logger.Info("Waiting for ongoing requests to complete...")
-	time.Sleep(1 * time.Second)
-	
+
+	// Stop accepting requests and give ongoing ones a chance to complete
+	grace := time.Duration(liveConfig.Load().ShutdownGracePeriodSec) * time.Second
+	logger.Info("Waiting for ongoing requests to complete...",
+		zap.Int64("activeRequests", activeRequests.Load()),
+		zap.Duration("gracePeriod", grace),
+	)
+	if !drainRequests(grace) {
+		logger.Warn("Shutdown grace period expired with requests still in progress",
+			zap.Int64("activeRequests", activeRequests.Load()),
+		)
+	}
+
 	logger.Info("Shutdown complete")
 }
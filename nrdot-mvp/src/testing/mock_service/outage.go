@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Signals a simulated outage can be limited to.
+const (
+	OutageSignalAll     = "all"
+	OutageSignalMetrics = "metrics"
+	OutageSignalTraces  = "traces"
+	OutageSignalLogs    = "logs"
+)
+
+// Simulated outages, keyed by signal. An outage of OutageSignalAll affects
+// every signal.
+var (
+	outageMutex sync.Mutex
+	outages     = make(map[string]outage)
+)
+
+// outage is a simulated outage, down for the whole time between its start
+// and end unless it flaps.
+type outage struct {
+	start time.Time
+	end   time.Time
+
+	// Time down and then up in each cycle of a flapping outage, zero for a
+	// steady outage
+	down time.Duration
+	up   time.Duration
+}
+
+// newOutage returns a steady outage starting now and lasting the given
+// duration, plus a random delay of up to jitterSeconds so a fleet of clients
+// doesn't see the backend recover at the same instant.
+func newOutage(durationSeconds int, jitterSeconds int) outage {
+	start := time.Now()
+	duration := time.Duration(durationSeconds) * time.Second
+	if jitterSeconds > 0 {
+		duration += time.Duration(rand.Int63n(int64(jitterSeconds) * int64(time.Second)))
+	}
+	return outage{start: start, end: start.Add(duration)}
+}
+
+// active returns whether the outage makes the backend unavailable at the
+// given time.
+func (o outage) active(now time.Time) bool {
+	if now.Before(o.start) || !now.Before(o.end) {
+		return false
+	}
+	if o.down <= 0 {
+		return true
+	}
+	return now.Sub(o.start)%(o.down+o.up) < o.down
+}
+
+// validOutageSignal returns whether an outage can be limited to a signal.
+func validOutageSignal(signal string) bool {
+	switch signal {
+	case OutageSignalAll, OutageSignalMetrics, OutageSignalTraces, OutageSignalLogs:
+		return true
+	}
+	return false
+}
+
+// signalOfPath returns the signal an OTLP HTTP or gRPC request path carries,
+// empty if it carries none.
+func signalOfPath(path string) string {
+	switch path {
+	case "/v1/metrics", grpcMetricsPath:
+		return OutageSignalMetrics
+	case "/v1/traces", grpcTracesPath:
+		return OutageSignalTraces
+	case "/v1/logs", grpcLogsPath:
+		return OutageSignalLogs
+	}
+	return ""
+}
+
+// startOutage starts a simulated outage of a signal, or of every signal.
+// An outage of the signal already in progress is replaced.
+func startOutage(signal string, o outage) {
+	outageMutex.Lock()
+	outages[signal] = o
+	outageMutex.Unlock()
+	promOutageStatus.Set(1)
+
+	logger.Info("Started simulated outage",
+		zap.String("signal", signal),
+		zap.Time("end_time", o.end),
+		zap.Duration("flap_down", o.down),
+		zap.Duration("flap_up", o.up),
+	)
+
+	// End the outage once it expires, unless it was replaced since
+	time.AfterFunc(time.Until(o.end), expireOutages)
+}
+
+// stopOutage stops the simulated outage of a signal. Stopping
+// OutageSignalAll stops the outages of every signal.
+func stopOutage(signal string) {
+	outageMutex.Lock()
+	defer outageMutex.Unlock()
+
+	if signal == OutageSignalAll {
+		for s := range outages {
+			delete(outages, s)
+		}
+	} else {
+		delete(outages, signal)
+	}
+	updateOutageStatus()
+
+	logger.Info("Stopped simulated outage", zap.String("signal", signal))
+}
+
+// expireOutages ends the simulated outages whose time has passed.
+func expireOutages() {
+	outageMutex.Lock()
+	defer outageMutex.Unlock()
+
+	now := time.Now()
+	for signal, o := range outages {
+		if !now.Before(o.end) {
+			delete(outages, signal)
+			logger.Info("Simulated outage ended", zap.String("signal", signal))
+		}
+	}
+	updateOutageStatus()
+}
+
+// updateOutageStatus sets the outage gauge from the outages in progress,
+// including flapping outages in their up phase. The caller must hold
+// outageMutex.
+func updateOutageStatus() {
+	if len(outages) > 0 {
+		promOutageStatus.Set(1)
+	} else {
+		promOutageStatus.Set(0)
+	}
+}
+
+// isInOutage checks if a signal is currently down in a simulated outage,
+// either of its own or of every signal. For OutageSignalAll it checks only for an
+// outage of every signal.
+func isInOutage(signal string) bool {
+	outageMutex.Lock()
+	defer outageMutex.Unlock()
+
+	now := time.Now()
+	for _, s := range []string{OutageSignalAll, signal} {
+		if o, exists := outages[s]; exists && o.active(now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// setupTestService configures the service to answer every OTLP request at
+// once, with fresh request metrics and no outage.
+func setupTestService(t *testing.T) {
+	t.Helper()
+
+	registerer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	t.Cleanup(func() { prometheus.DefaultRegisterer = registerer })
+
+	logger = zap.NewNop()
+	config = DefaultConfig()
+	config.LatencyMax = 0
+	config.ErrorRate = 0
+	config.ValidateRequests = false
+	config.SupportOutageSimulation = true
+	liveConfig.Store(config)
+	requestSemaphore = make(chan struct{}, config.SimultaneousRequests)
+	initPrometheusMetrics()
+	t.Cleanup(func() { stopOutage(OutageSignalAll) })
+}
+
+// controlOutage sends an outage control request, returning its status.
+func controlOutage(body string) int {
+	recorder := httptest.NewRecorder()
+	handleOutageControl(recorder, httptest.NewRequest(http.MethodPost, "/outage", strings.NewReader(body)))
+	return recorder.Code
+}
+
+// signalStatuses returns the status of a request to each signal's path.
+func signalStatuses() map[string]int {
+	statuses := make(map[string]int)
+	for _, signal := range []string{OutageSignalMetrics, OutageSignalTraces, OutageSignalLogs} {
+		recorder := httptest.NewRecorder()
+		handleOTLP(recorder, httptest.NewRequest(http.MethodPost, "/v1/"+signal, strings.NewReader("payload")))
+		statuses[signal] = recorder.Code
+	}
+	return statuses
+}
+
+func TestMetricsOnlyOutage(t *testing.T) {
+	setupTestService(t)
+
+	if code := controlOutage(`{"action": "start", "signal": "metrics", "duration_seconds": 60}`); code != http.StatusOK {
+		t.Fatalf("expected the outage to start, got %d", code)
+	}
+	statuses := signalStatuses()
+	if statuses["metrics"] != http.StatusServiceUnavailable || statuses["traces"] != http.StatusOK || statuses["logs"] != http.StatusOK {
+		t.Fatalf("expected only metrics to be unavailable, got %v", statuses)
+	}
+
+	// An outage of every signal takes traces and logs down too, and
+	// stopping it ends the metrics outage as well
+	if code := controlOutage(`{"action": "start", "duration_seconds": 60}`); code != http.StatusOK {
+		t.Fatalf("expected the outage to start, got %d", code)
+	}
+	for signal, status := range signalStatuses() {
+		if status != http.StatusServiceUnavailable {
+			t.Fatalf("expected %s to be unavailable, got %d", signal, status)
+		}
+	}
+	if code := controlOutage(`{"action": "stop", "signal": "all"}`); code != http.StatusOK {
+		t.Fatalf("expected the outage to stop, got %d", code)
+	}
+	for signal, status := range signalStatuses() {
+		if status != http.StatusOK {
+			t.Fatalf("expected %s to recover, got %d", signal, status)
+		}
+	}
+
+	if code := controlOutage(`{"action": "start", "signal": "profiles"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown signal to be rejected, got %d", code)
+	}
+}
+
+func TestOutageEndsWithinJitterWindow(t *testing.T) {
+	ends := make(map[time.Time]bool)
+	for i := 0; i < 50; i++ {
+		o := newOutage(10, 5)
+		earliest, latest := o.start.Add(10*time.Second), o.start.Add(15*time.Second)
+		if o.end.Before(earliest) || !o.end.Before(latest) {
+			t.Fatalf("expected the outage to end between %v and %v, got %v", earliest, latest, o.end)
+		}
+		ends[o.end] = true
+
+		// The outage holds until its end and not after
+		if !o.active(o.end.Add(-time.Millisecond)) || o.active(o.end) {
+			t.Fatalf("expected the outage to last until %v", o.end)
+		}
+	}
+	if len(ends) < 2 {
+		t.Fatal("expected the recovery to be randomized")
+	}
+
+	// Without jitter the outage ends exactly on time
+	if o := newOutage(10, 0); !o.end.Equal(o.start.Add(10 * time.Second)) {
+		t.Fatalf("expected the outage to end after 10s, got %v", o.end.Sub(o.start))
+	}
+}
+
+func TestFlappingOutageTogglesRepeatedly(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	o := outage{start: start, end: start.Add(time.Minute), down: 10 * time.Second, up: 5 * time.Second}
+
+	// Sample the middle of each phase: down, up, down, up, ...
+	var states []bool
+	toggles := 0
+	for at := 5 * time.Second; at < time.Minute; at += 7500 * time.Millisecond {
+		state := o.active(start.Add(at))
+		if len(states) > 0 && state != states[len(states)-1] {
+			toggles++
+		}
+		states = append(states, state)
+	}
+	if toggles < 4 {
+		t.Fatalf("expected the outage to toggle repeatedly, got %v", states)
+	}
+
+	for offset, want := range map[time.Duration]bool{
+		0:                true,
+		9 * time.Second:  true,
+		10 * time.Second: false,
+		14 * time.Second: false,
+		15 * time.Second: true,
+		time.Minute:      false,
+	} {
+		if got := o.active(start.Add(offset)); got != want {
+			t.Errorf("expected the outage to be active %v after %v, got %v", want, offset, got)
+		}
+	}
+}
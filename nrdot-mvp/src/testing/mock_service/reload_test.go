@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+func TestSIGHUPAppliesNewErrorRate(t *testing.T) {
+	registerer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	defer func() { prometheus.DefaultRegisterer = registerer }()
+
+	logger = zap.NewNop()
+	config = DefaultConfig()
+	config.LatencyMax = 0
+	config.ErrorRate = 0
+	config.ValidateRequests = false
+	liveConfig.Store(config)
+	requestSemaphore = make(chan struct{}, config.SimultaneousRequests)
+	initPrometheusMetrics()
+
+	configPath = filepath.Join(t.TempDir(), "config.json")
+	defer func() { configPath = "" }()
+	if err := os.WriteFile(configPath, []byte(`{"error_rate": 100, "latency_min": 0, "latency_max": 0, "port": 1}`), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+	shutdown := make(chan os.Signal, 1)
+	go func() { shutdown <- awaitShutdownSignal(sigCh) }()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("failed to send SIGHUP: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for liveConfig.Load().ErrorRate != 100 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the configuration to be reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The new error rate fails every request, the port change waits for a restart
+	recorder := httptest.NewRecorder()
+	handleOTLP(recorder, httptest.NewRequest(http.MethodPost, "/v1/metrics", nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected a simulated error, got status %d", recorder.Code)
+	}
+	if port := liveConfig.Load().Port; port != config.Port {
+		t.Fatalf("expected the port change to be ignored, got port %d", port)
+	}
+
+	sigCh <- syscall.SIGTERM
+	if sig := <-shutdown; sig != syscall.SIGTERM {
+		t.Fatalf("expected SIGTERM to end the wait, got %v", sig)
+	}
+}
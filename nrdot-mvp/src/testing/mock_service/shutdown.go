@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+var (
+	// OTLP requests being handled, over HTTP and gRPC
+	activeRequests atomic.Int64
+
+	// OTLP servers, drained on shutdown. The gRPC server is nil unless
+	// grpc_port is set.
+	httpServer atomic.Pointer[http.Server]
+	grpcServer atomic.Pointer[grpc.Server]
+)
+
+// beginRequest counts an OTLP request as active until the returned function
+// is called.
+func beginRequest() func() {
+	activeRequests.Add(1)
+	promCurrentRequests.Inc()
+	return func() {
+		promCurrentRequests.Dec()
+		activeRequests.Add(-1)
+	}
+}
+
+// drainRequests stops the OTLP servers from accepting requests and waits up
+// to the grace period for the active ones to complete. It returns whether
+// they all completed.
+func drainRequests(grace time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	if server := httpServer.Load(); server != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				logger.Warn("HTTP server did not drain", zap.Error(err))
+			}
+		}()
+	}
+	if server := grpcServer.Load(); server != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopped := make(chan struct{})
+			go func() {
+				server.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				server.Stop()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return activeRequests.Load() == 0
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+func TestDrainRequestsWaitsForSlowRequest(t *testing.T) {
+	logger = zap.NewNop()
+	promCurrentRequests = prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_current_requests"})
+
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/metrics", func(w http.ResponseWriter, r *http.Request) {
+		endRequest := beginRequest()
+		defer endRequest()
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: mux}
+	httpServer.Store(server)
+	defer httpServer.Store(nil)
+	go server.Serve(listener)
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Post("http://"+listener.Addr().String()+"/v1/metrics", "application/x-protobuf", nil)
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-started
+
+	if !drainRequests(5 * time.Second) {
+		t.Fatal("expected the slow request to complete within the grace period")
+	}
+	if active := activeRequests.Load(); active != 0 {
+		t.Errorf("expected no active requests after draining, got %d", active)
+	}
+	if code := <-status; code != http.StatusOK {
+		t.Errorf("expected the slow request to succeed, got status %d", code)
+	}
+}
+
+func TestDrainRequestsGivesUpAfterGracePeriod(t *testing.T) {
+	logger = zap.NewNop()
+	promCurrentRequests = prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_current_requests"})
+
+	// A request that never completes
+	endRequest := beginRequest()
+	defer endRequest()
+
+	if drainRequests(50 * time.Millisecond) {
+		t.Fatal("expected draining to report the request still in progress")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -18,30 +19,38 @@ import (
 type OutageConfig struct {
 	// Target service to simulate outage for
 	TargetService string `json:"target_service"`
-	
+
 	// Target URL for the outage control endpoint
 	TargetURL string `json:"target_url"`
-	
+
 	// Duration of the outage in seconds
 	OutageDuration int `json:"outage_duration"`
-	
+
 	// Type of outage to simulate
 	OutageType string `json:"outage_type"`
-	
+
 	// Whether to wait for the outage to complete before exiting
 	WaitForCompletion bool `json:"wait_for_completion"`
-	
+
 	// Whether to verify DLQ functionality after the outage
 	VerifyDLQ bool `json:"verify_dlq"`
-	
+
 	// Location of the DLQ files to verify
 	DLQDirectory string `json:"dlq_directory"`
-	
+
 	// Docker container to target (if using container_stop outage type)
 	DockerContainer string `json:"docker_container"`
-	
-	// Whether to restart the container automatically after outage
+
+	// Whether to restart the container automatically after outage. When
+	// false the container stays stopped until it is restarted manually.
 	AutoRestart bool `json:"auto_restart"`
+
+	// Seconds after stopping the container before it is restarted, 0 to
+	// restart after the outage duration
+	RestartDelaySeconds int `json:"restart_delay_seconds"`
+
+	// File to write the JSON outage report to, empty to skip the report
+	ReportPath string `json:"report_path"`
 }
 
 // DefaultConfig returns a default configuration.
@@ -56,6 +65,7 @@ func DefaultConfig() *OutageConfig {
 		DLQDirectory:      "/var/lib/otel/dlq",
 		DockerContainer:   "nrdot-mvp_mock-service_1",
 		AutoRestart:       true,
+		ReportPath:        "",
 	}
 }
 
@@ -63,6 +73,20 @@ func DefaultConfig() *OutageConfig {
 var (
 	logger *zap.Logger
 	config *OutageConfig
+	report *OutageReport
+
+	// Runs external commands such as docker and iptables, replaceable so the
+	// outage logic can run without them
+	runCommand = func(name string, args ...string) error {
+		return exec.Command(name, args...).Run()
+	}
+	lookPath = exec.LookPath
+
+	// Waits before ending an outage, replaceable so tests don't wait
+	sleep = time.Sleep
+
+	// Scheduled container restarts that haven't run yet
+	pendingRestarts sync.WaitGroup
 )
 
 func main() {
@@ -72,8 +96,11 @@ func main() {
 	outageType := flag.String("type", "", "Type of outage to simulate (api, container_stop, network)")
 	duration := flag.Int("duration", 0, "Duration of the outage in seconds")
 	targetURL := flag.String("url", "", "Target URL for outage control")
+	reportPath := flag.String("report", "", "Path to write the JSON outage report to")
+	restartDelay := flag.Int("restart-delay", 0, "Seconds before restarting a stopped container (default: outage duration)")
+	manualRestart := flag.Bool("manual-restart", false, "Leave a stopped container down instead of restarting it")
 	flag.Parse()
-	
+
 	// Initialize logger
 	var err error
 	logger, err = zap.NewProduction()
@@ -82,7 +109,7 @@ func main() {
 		os.Exit(1)
 	}
 	defer logger.Sync()
-	
+
 	// Load configuration
 	config = DefaultConfig()
 	if *configFile != "" {
@@ -90,7 +117,7 @@ func main() {
 			logger.Fatal("Failed to load configuration", zap.Error(err))
 		}
 	}
-	
+
 	// Override with command-line flags
 	if *targetService != "" {
 		config.TargetService = *targetService
@@ -104,7 +131,16 @@ func main() {
 	if *targetURL != "" {
 		config.TargetURL = *targetURL
 	}
-	
+	if *reportPath != "" {
+		config.ReportPath = *reportPath
+	}
+	if *restartDelay > 0 {
+		config.RestartDelaySeconds = *restartDelay
+	}
+	if *manualRestart {
+		config.AutoRestart = false
+	}
+
 	// Override from environment
 	if envTarget := os.Getenv("TARGET_SERVICE"); envTarget != "" {
 		config.TargetService = envTarget
@@ -115,7 +151,10 @@ func main() {
 	if envURL := os.Getenv("TARGET_URL"); envURL != "" {
 		config.TargetURL = envURL
 	}
-	
+	if envReport := os.Getenv("REPORT_PATH"); envReport != "" {
+		config.ReportPath = envReport
+	}
+
 	// Log configuration
 	logger.Info("Starting outage simulation",
 		zap.String("targetService", config.TargetService),
@@ -123,29 +162,58 @@ func main() {
 		zap.Int("duration", config.OutageDuration),
 		zap.String("targetURL", config.TargetURL),
 	)
-	
+
+	report = newOutageReport(config)
+
 	// Simulate outage
 	if err := simulateOutage(); err != nil {
+		report.addEvent("outage_failed", err.Error())
+		finishReport(err)
 		logger.Fatal("Failed to simulate outage", zap.Error(err))
 	}
-	
+
 	// Wait for completion if configured
 	if config.WaitForCompletion {
 		logger.Info("Waiting for outage to complete...",
 			zap.Int("durationSeconds", config.OutageDuration),
 		)
 		time.Sleep(time.Duration(config.OutageDuration) * time.Second)
+
+		// A restart delay beyond the outage duration keeps the container down longer
+		pendingRestarts.Wait()
 		logger.Info("Outage completed")
+		report.addEvent("outage_completed", "")
 	}
-	
+
 	// Verify DLQ if configured
+	var runErr error
 	if config.VerifyDLQ {
 		if err := verifyDLQ(); err != nil {
 			logger.Error("DLQ verification failed", zap.Error(err))
+			report.addEvent("dlq_verification_failed", err.Error())
+			runErr = err
 		} else {
 			logger.Info("DLQ verification successful")
+			report.addEvent("dlq_verified", "")
 		}
 	}
+
+	finishReport(runErr)
+}
+
+// finishReport records the outcome and writes the report if a path is
+// configured.
+func finishReport(err error) {
+	report.finish(err)
+	if config.ReportPath == "" {
+		return
+	}
+
+	if err := report.write(config.ReportPath); err != nil {
+		logger.Error("Failed to write outage report", zap.Error(err))
+		return
+	}
+	logger.Info("Wrote outage report", zap.String("path", config.ReportPath))
 }
 
 // loadConfig loads the configuration from a JSON file.
@@ -155,12 +223,12 @@ func loadConfig(path string, config *OutageConfig) error {
 	if err != nil {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
-	
+
 	// Parse JSON
 	if err := json.Unmarshal(data, config); err != nil {
 		return fmt.Errorf("failed to parse config file: %w", err)
 	}
-	
+
 	return nil
 }
 
@@ -182,86 +250,107 @@ func simulateOutage() error {
 func simulateAPIOutage() error {
 	// Create request payload
 	payload := map[string]interface{}{
-		"action":          "start",
+		"action":           "start",
 		"duration_seconds": config.OutageDuration,
 	}
-	
+
 	// Convert to JSON
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
-	
+
 	// Send request
 	resp, err := http.Post(config.TargetURL, "application/json", bytes.NewBuffer(data))
 	if err != nil {
 		return fmt.Errorf("failed to send outage request: %w", err)
 	}
 	defer resp.Body.Close()
-	
+
 	// Check response
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("outage request failed with status: %d", resp.StatusCode)
 	}
-	
-	logger.Info("API outage started", 
+
+	logger.Info("API outage started",
 		zap.Int("duration", config.OutageDuration),
 		zap.String("targetURL", config.TargetURL),
 	)
-	
+	report.addEvent("outage_started", config.TargetURL)
+
 	return nil
 }
 
 // simulateContainerStopOutage simulates an outage by stopping a Docker container.
 func simulateContainerStopOutage() error {
 	// Check if Docker is available
-	if _, err := exec.LookPath("docker"); err != nil {
+	if _, err := lookPath("docker"); err != nil {
 		return fmt.Errorf("docker command not found: %w", err)
 	}
-	
+
 	// Stop the container
-	stopCmd := exec.Command("docker", "stop", config.DockerContainer)
-	if err := stopCmd.Run(); err != nil {
+	if err := runCommand("docker", "stop", config.DockerContainer); err != nil {
 		return fmt.Errorf("failed to stop container: %w", err)
 	}
-	
-	logger.Info("Container stopped", 
+
+	logger.Info("Container stopped",
 		zap.String("container", config.DockerContainer),
 		zap.Int("duration", config.OutageDuration),
 	)
-	
-	// If auto-restart is enabled, schedule the restart
-	if config.AutoRestart {
-		go func() {
-			// Wait for outage duration
-			time.Sleep(time.Duration(config.OutageDuration) * time.Second)
-			
-			// Restart the container
-			startCmd := exec.Command("docker", "start", config.DockerContainer)
-			if err := startCmd.Run(); err != nil {
-				logger.Error("Failed to restart container", 
-					zap.String("container", config.DockerContainer),
-					zap.Error(err),
-				)
-				return
-			}
-			
-			logger.Info("Container restarted", 
+	report.addEvent("outage_started", config.DockerContainer)
+
+	// Without auto-restart the container stays down until restarted manually
+	if !config.AutoRestart {
+		logger.Info("Auto-restart disabled, container must be restarted manually",
+			zap.String("container", config.DockerContainer),
+		)
+		report.addEvent("restart_manual", config.DockerContainer)
+		return nil
+	}
+
+	// Schedule the restart
+	pendingRestarts.Add(1)
+	go func() {
+		defer pendingRestarts.Done()
+
+		// Wait for the restart delay
+		sleep(restartDelay())
+
+		// Restart the container
+		if err := runCommand("docker", "start", config.DockerContainer); err != nil {
+			logger.Error("Failed to restart container",
 				zap.String("container", config.DockerContainer),
+				zap.Error(err),
 			)
-		}()
-	}
-	
+			report.addEvent("container_restart_failed", err.Error())
+			return
+		}
+
+		logger.Info("Container restarted",
+			zap.String("container", config.DockerContainer),
+		)
+		report.addEvent("container_restarted", config.DockerContainer)
+	}()
+
 	return nil
 }
 
+// restartDelay returns how long a stopped container stays down before it is
+// restarted.
+func restartDelay() time.Duration {
+	if config.RestartDelaySeconds > 0 {
+		return time.Duration(config.RestartDelaySeconds) * time.Second
+	}
+	return time.Duration(config.OutageDuration) * time.Second
+}
+
 // simulateNetworkOutage simulates a network outage using iptables (Linux only).
 func simulateNetworkOutage() error {
 	// Check if iptables is available
-	if _, err := exec.LookPath("iptables"); err != nil {
+	if _, err := lookPath("iptables"); err != nil {
 		return fmt.Errorf("iptables command not found (requires Linux): %w", err)
 	}
-	
+
 	// Parse target service to extract host and port
 	parts := strings.Split(config.TargetService, ":")
 	host := parts[0]
@@ -269,90 +358,115 @@ func simulateNetworkOutage() error {
 	if len(parts) > 1 {
 		port = parts[1]
 	}
-	
+
 	// Add iptables rule to block traffic
-	blockCmd := exec.Command("iptables", "-A", "OUTPUT", "-d", host, "-p", "tcp", "--dport", port, "-j", "DROP")
-	if err := blockCmd.Run(); err != nil {
+	blockArgs := []string{"-A", "OUTPUT", "-d", host, "-p", "tcp", "--dport", port, "-j", "DROP"}
+	if err := runCommand("iptables", blockArgs...); err != nil {
 		return fmt.Errorf("failed to add iptables rule: %w", err)
 	}
-	
-	logger.Info("Network outage started", 
+
+	logger.Info("Network outage started",
 		zap.String("host", host),
 		zap.String("port", port),
 		zap.Int("duration", config.OutageDuration),
 	)
-	
+	report.addEvent("outage_started", host+":"+port)
+
 	// Schedule rule removal
 	go func() {
 		// Wait for outage duration
-		time.Sleep(time.Duration(config.OutageDuration) * time.Second)
-		
+		sleep(time.Duration(config.OutageDuration) * time.Second)
+
 		// Remove iptables rule
-		unblockCmd := exec.Command("iptables", "-D", "OUTPUT", "-d", host, "-p", "tcp", "--dport", port, "-j", "DROP")
-		if err := unblockCmd.Run(); err != nil {
-			logger.Error("Failed to remove iptables rule", 
+		unblockArgs := []string{"-D", "OUTPUT", "-d", host, "-p", "tcp", "--dport", port, "-j", "DROP"}
+		if err := runCommand("iptables", unblockArgs...); err != nil {
+			logger.Error("Failed to remove iptables rule",
 				zap.String("host", host),
 				zap.String("port", port),
 				zap.Error(err),
 			)
+			report.addEvent("network_restore_failed", err.Error())
 			return
 		}
-		
-		logger.Info("Network outage ended", 
+
+		logger.Info("Network outage ended",
 			zap.String("host", host),
 			zap.String("port", port),
 		)
+		report.addEvent("network_restored", host+":"+port)
 	}()
-	
+
 	return nil
 }
 
-// verifyDLQ verifies that data was properly saved to the DLQ during the outage.
+// verifyDLQ verifies that data was properly saved to the DLQ during the
+// outage, recording what it found in the report.
 func verifyDLQ() error {
+	verification := &DLQVerification{Directory: config.DLQDirectory, Files: []DLQFileReport{}}
+	defer report.setDLQ(verification)
+
+	err := checkDLQ(verification)
+	if err != nil {
+		verification.Error = err.Error()
+	}
+	verification.Verified = err == nil
+	return err
+}
+
+// checkDLQ checks the DLQ directory holds DLQ files, adding them to the
+// verification.
+func checkDLQ(verification *DLQVerification) error {
 	// In a real implementation, this would check that data was properly written to the DLQ
 	// during the outage and verify the integrity using SHA-256
-	
+
 	// This is a placeholder implementation
 	logger.Info("Verifying DLQ", zap.String("directory", config.DLQDirectory))
-	
+
 	// Check if DLQ directory exists
 	info, err := os.Stat(config.DLQDirectory)
 	if err != nil {
 		return fmt.Errorf("failed to access DLQ directory: %w", err)
 	}
-	
+
 	if !info.IsDir() {
 		return fmt.Errorf("DLQ path is not a directory: %s", config.DLQDirectory)
 	}
-	
+
 	// List files in DLQ directory
 	files, err := os.ReadDir(config.DLQDirectory)
 	if err != nil {
 		return fmt.Errorf("failed to read DLQ directory: %w", err)
 	}
-	
+
 	// Check if there are any DLQ files
 	var dlqFiles []string
 	for _, file := range files {
 		if !file.IsDir() && strings.HasSuffix(file.Name(), ".dlq") {
 			dlqFiles = append(dlqFiles, file.Name())
+
+			fileReport := DLQFileReport{Name: file.Name()}
+			if fileInfo, err := file.Info(); err == nil {
+				fileReport.SizeBytes = fileInfo.Size()
+				fileReport.ModTime = fileInfo.ModTime().UTC()
+			}
+			verification.Files = append(verification.Files, fileReport)
 		}
 	}
-	
+
 	if len(dlqFiles) == 0 {
 		return fmt.Errorf("no DLQ files found in directory: %s", config.DLQDirectory)
 	}
-	
-	logger.Info("Found DLQ files", 
+
+	logger.Info("Found DLQ files",
 		zap.Int("count", len(dlqFiles)),
 		zap.Strings("files", dlqFiles),
 	)
-	
+
 	// In a full implementation, we would:
 	// 1. Read each DLQ file
 	// 2. Verify the SHA-256 signatures
 	// 3. Check timestamps to ensure data was written during the outage
 	// 4. Verify the content format
-	
+
 	return nil
 }
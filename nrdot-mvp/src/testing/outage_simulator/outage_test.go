@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeCommands records the commands run and the delays waited.
+type fakeCommands struct {
+	mutex    sync.Mutex
+	commands []string
+	delays   []time.Duration
+}
+
+// useFakeCommands replaces the command runner, lookup and sleep for the
+// duration of the test.
+func useFakeCommands(t *testing.T) *fakeCommands {
+	t.Helper()
+
+	fake := &fakeCommands{}
+	savedRun, savedLookPath, savedSleep := runCommand, lookPath, sleep
+	runCommand = func(name string, args ...string) error {
+		fake.mutex.Lock()
+		defer fake.mutex.Unlock()
+		fake.commands = append(fake.commands, name+" "+strings.Join(args, " "))
+		return nil
+	}
+	lookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+	sleep = func(d time.Duration) {
+		fake.mutex.Lock()
+		defer fake.mutex.Unlock()
+		fake.delays = append(fake.delays, d)
+	}
+	t.Cleanup(func() {
+		runCommand, lookPath, sleep = savedRun, savedLookPath, savedSleep
+	})
+	return fake
+}
+
+func TestContainerRestartedAfterRestartDelay(t *testing.T) {
+	fake := useFakeCommands(t)
+	logger = zap.NewNop()
+	config = DefaultConfig()
+	config.OutageType = "container_stop"
+	config.DockerContainer = "collector"
+	config.OutageDuration = 60
+	config.RestartDelaySeconds = 90
+	report = newOutageReport(config)
+
+	if err := simulateOutage(); err != nil {
+		t.Fatalf("failed to simulate outage: %v", err)
+	}
+	pendingRestarts.Wait()
+
+	// The container stays down for the restart delay, not the outage duration
+	if len(fake.delays) != 1 || fake.delays[0] != 90*time.Second {
+		t.Fatalf("expected a single 90s wait before restarting, got %v", fake.delays)
+	}
+	if len(fake.commands) != 2 || fake.commands[0] != "docker stop collector" || fake.commands[1] != "docker start collector" {
+		t.Fatalf("expected the container to be stopped then started, got %v", fake.commands)
+	}
+
+	// Without a restart delay, the outage duration is used
+	config.RestartDelaySeconds = 0
+	if got := restartDelay(); got != 60*time.Second {
+		t.Fatalf("expected the outage duration as the restart delay, got %v", got)
+	}
+}
+
+func TestContainerNotRestartedWhenManual(t *testing.T) {
+	fake := useFakeCommands(t)
+	logger = zap.NewNop()
+	config = DefaultConfig()
+	config.OutageType = "container_stop"
+	config.DockerContainer = "collector"
+	config.AutoRestart = false
+	report = newOutageReport(config)
+
+	if err := simulateOutage(); err != nil {
+		t.Fatalf("failed to simulate outage: %v", err)
+	}
+	pendingRestarts.Wait()
+
+	if len(fake.commands) != 1 || fake.commands[0] != "docker stop collector" {
+		t.Fatalf("expected the container to be stopped only, got %v", fake.commands)
+	}
+	if len(fake.delays) != 0 {
+		t.Fatalf("expected no restart to be scheduled, got waits %v", fake.delays)
+	}
+	if last := report.Timeline[len(report.Timeline)-1]; last.Event != "restart_manual" {
+		t.Fatalf("expected the manual restart to be reported, got %+v", report.Timeline)
+	}
+}
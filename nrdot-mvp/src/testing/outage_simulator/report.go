@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// OutageReport is the machine-readable summary of a simulation run, written
+// to the report path for CI to consume.
+type OutageReport struct {
+	OutageType      string `json:"outage_type"`
+	TargetService   string `json:"target_service"`
+	TargetURL       string `json:"target_url"`
+	DurationSeconds int    `json:"duration_seconds"`
+
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// Timeline of the run, in the order events happened
+	Timeline []ReportEvent `json:"timeline"`
+
+	// DLQ verification details, nil if verification was disabled
+	DLQ *DLQVerification `json:"dlq_verification,omitempty"`
+
+	// Whether the outage was simulated and, if enabled, the DLQ verified
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+
+	mutex sync.Mutex
+}
+
+// ReportEvent is a single step in the report timeline.
+type ReportEvent struct {
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// DLQVerification records what the DLQ check found.
+type DLQVerification struct {
+	Directory string          `json:"directory"`
+	Verified  bool            `json:"verified"`
+	Files     []DLQFileReport `json:"files"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// DLQFileReport describes a DLQ file found during verification.
+type DLQFileReport struct {
+	Name      string    `json:"name"`
+	SizeBytes int64     `json:"size_bytes"`
+	ModTime   time.Time `json:"mod_time"`
+}
+
+// newOutageReport starts a report for the configured outage.
+func newOutageReport(cfg *OutageConfig) *OutageReport {
+	return &OutageReport{
+		OutageType:      cfg.OutageType,
+		TargetService:   cfg.TargetService,
+		TargetURL:       cfg.TargetURL,
+		DurationSeconds: cfg.OutageDuration,
+		StartedAt:       time.Now().UTC(),
+		Timeline:        []ReportEvent{},
+	}
+}
+
+// addEvent appends an event to the timeline. It is safe to call from the
+// goroutines that end an outage.
+func (r *OutageReport) addEvent(event string, detail string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Timeline = append(r.Timeline, ReportEvent{
+		Time:   time.Now().UTC(),
+		Event:  event,
+		Detail: detail,
+	})
+}
+
+// setDLQ records the DLQ verification result.
+func (r *OutageReport) setDLQ(verification *DLQVerification) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.DLQ = verification
+}
+
+// finish records the outcome of the run.
+func (r *OutageReport) finish(err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	finishedAt := time.Now().UTC()
+	r.FinishedAt = &finishedAt
+	r.Success = err == nil
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// write writes the report as JSON to the path, replacing it atomically so CI
+// never reads a partial report.
+func (r *OutageReport) write(path string) error {
+	r.mutex.Lock()
+	data, err := json.MarshalIndent(r, "", "  ")
+	r.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode outage report: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create report directory: %w", err)
+		}
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write outage report: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write outage report: %w", err)
+	}
+	return nil
+}
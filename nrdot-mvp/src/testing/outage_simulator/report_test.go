@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestReportAfterAPIOutage(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer server.Close()
+
+	dlqDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dlqDir, "otel-dlq-metrics-0000000001-20240101-000000.000.dlq"), []byte("record"), 0644); err != nil {
+		t.Fatalf("failed to write DLQ file: %v", err)
+	}
+
+	logger = zap.NewNop()
+	config = DefaultConfig()
+	config.TargetURL = server.URL
+	config.OutageDuration = 5
+	config.DLQDirectory = dlqDir
+	config.ReportPath = filepath.Join(t.TempDir(), "reports", "outage.json")
+	report = newOutageReport(config)
+
+	// Run the outage as main does, without waiting for it to complete
+	if err := simulateOutage(); err != nil {
+		t.Fatalf("failed to simulate outage: %v", err)
+	}
+	if payload["action"] != "start" || payload["duration_seconds"] != float64(5) {
+		t.Fatalf("unexpected outage request %v", payload)
+	}
+	if err := verifyDLQ(); err != nil {
+		t.Fatalf("failed to verify DLQ: %v", err)
+	}
+	report.addEvent("dlq_verified", "")
+	finishReport(nil)
+
+	data, err := os.ReadFile(config.ReportPath)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	var written OutageReport
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+
+	if written.OutageType != "api" || written.TargetURL != server.URL || written.DurationSeconds != 5 {
+		t.Fatalf("unexpected outage in report: %s against %s for %ds",
+			written.OutageType, written.TargetURL, written.DurationSeconds)
+	}
+	if !written.Success || written.Error != "" {
+		t.Fatalf("expected a successful run, got error %q", written.Error)
+	}
+	if written.StartedAt.IsZero() || written.FinishedAt == nil || written.FinishedAt.Before(written.StartedAt) {
+		t.Fatalf("expected start and finish times in order, got %v and %v", written.StartedAt, written.FinishedAt)
+	}
+
+	if len(written.Timeline) != 2 ||
+		written.Timeline[0].Event != "outage_started" || written.Timeline[0].Detail != server.URL ||
+		written.Timeline[1].Event != "dlq_verified" {
+		t.Fatalf("unexpected timeline %+v", written.Timeline)
+	}
+
+	dlq := written.DLQ
+	if dlq == nil || !dlq.Verified || dlq.Directory != dlqDir {
+		t.Fatalf("expected the DLQ to be verified, got %+v", dlq)
+	}
+	if len(dlq.Files) != 1 || dlq.Files[0].Name != "otel-dlq-metrics-0000000001-20240101-000000.000.dlq" || dlq.Files[0].SizeBytes != 6 {
+		t.Fatalf("expected the DLQ file in the report, got %+v", dlq.Files)
+	}
+
+	// No temporary file is left next to the report
+	if _, err := os.Stat(config.ReportPath + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("expected no temporary report file, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// aborted stops the workers before the run's duration has elapsed, once the
+// success rate has fallen below the abort threshold.
+var aborted atomic.Bool
+
+// Why the run was aborted, empty unless aborted is set. Guarded by
+// abortMutex.
+var (
+	abortReason string
+	abortMutex  sync.Mutex
+)
+
+// outcomeBucket counts the requests completed in one second.
+type outcomeBucket struct {
+	second    int64
+	succeeded int64
+	failed    int64
+}
+
+// outcomeWindow counts request outcomes over the last few seconds, one
+// bucket per second reused as the window rolls on. The caller must hold
+// statsMutex.
+type outcomeWindow struct {
+	buckets []outcomeBucket
+}
+
+// recentOutcomes covers the abort window, nil unless aborting is enabled.
+var recentOutcomes *outcomeWindow
+
+// newOutcomeWindow creates a window covering the given number of seconds.
+func newOutcomeWindow(seconds int) *outcomeWindow {
+	return &outcomeWindow{buckets: make([]outcomeBucket, seconds)}
+}
+
+// record counts a request completed at the given time.
+func (w *outcomeWindow) record(now time.Time, success bool) {
+	if w == nil {
+		return
+	}
+
+	second := now.Unix()
+	bucket := &w.buckets[second%int64(len(w.buckets))]
+	if bucket.second != second {
+		*bucket = outcomeBucket{second: second}
+	}
+	if success {
+		bucket.succeeded++
+	} else {
+		bucket.failed++
+	}
+}
+
+// totals returns the requests that succeeded and failed within the window
+// ending at the given time.
+func (w *outcomeWindow) totals(now time.Time) (int64, int64) {
+	oldest := now.Unix() - int64(len(w.buckets)) + 1
+	var succeeded, failed int64
+	for _, bucket := range w.buckets {
+		if bucket.second >= oldest {
+			succeeded += bucket.succeeded
+			failed += bucket.failed
+		}
+	}
+	return succeeded, failed
+}
+
+// abortMonitor stops the run early if, over a full abort window, fewer than
+// the configured percentage of requests succeeded, such as when the target
+// stops responding. Windows without requests, such as while paused, never
+// abort the run.
+func abortMonitor(cfg *Config) {
+	window := time.Duration(cfg.AbortWindowSec) * time.Second
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if now.After(endTime) {
+			return
+		}
+		if now.Sub(startTime) < window {
+			continue
+		}
+
+		statsMutex.Lock()
+		succeeded, failed := recentOutcomes.totals(now)
+		statsMutex.Unlock()
+
+		total := succeeded + failed
+		if total == 0 {
+			continue
+		}
+		successPercent := float64(succeeded) / float64(total) * 100
+		if successPercent >= cfg.AbortMinSuccessPercent {
+			continue
+		}
+
+		reason := fmt.Sprintf("success rate %.2f%% over the last %ds is below abort_min_success_percent %.2f%%",
+			successPercent, cfg.AbortWindowSec, cfg.AbortMinSuccessPercent)
+		abortMutex.Lock()
+		abortReason = reason
+		abortMutex.Unlock()
+		aborted.Store(true)
+
+		logger.Warn("Aborting workload early",
+			zap.String("reason", reason),
+			zap.Int64("succeeded", succeeded),
+			zap.Int64("failed", failed),
+		)
+		return
+	}
+}
+
+// abortedReason returns why the run was aborted, or an empty string if it
+// wasn't.
+func abortedReason() string {
+	abortMutex.Lock()
+	defer abortMutex.Unlock()
+	return abortReason
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnresponsiveTargetAbortsEarly(t *testing.T) {
+	started := time.Now()
+	output, code := runGenerator(t, http.StatusServiceUnavailable,
+		"-duration 60 -abort-window 1 -abort-min-success-percent 50")
+
+	if elapsed := time.Since(started); elapsed > 15*time.Second {
+		t.Fatalf("expected the run to stop well before its 60s duration, took %v", elapsed)
+	}
+	if code == 0 {
+		t.Fatalf("expected an aborted run to exit non-zero, got 0:\n%s", output)
+	}
+	for _, want := range []string{
+		"Aborting workload early",
+		"run aborted early: success rate 0.00% over the last 1s is below abort_min_success_percent 50.00%",
+	} {
+		if !strings.Contains(output, want) {
+			t.Fatalf("expected the output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestOutcomeWindowRollsOver(t *testing.T) {
+	window := newOutcomeWindow(3)
+	base := time.Unix(1000, 0)
+
+	window.record(base, false)
+	window.record(base.Add(time.Second), true)
+	window.record(base.Add(2*time.Second), true)
+	if succeeded, failed := window.totals(base.Add(2 * time.Second)); succeeded != 2 || failed != 1 {
+		t.Fatalf("expected 2 successes and 1 failure in the window, got %d and %d", succeeded, failed)
+	}
+
+	// The oldest second leaves the window and its bucket is reused
+	window.record(base.Add(3*time.Second), true)
+	if succeeded, failed := window.totals(base.Add(3 * time.Second)); succeeded != 3 || failed != 0 {
+		t.Fatalf("expected 3 successes and no failures in the window, got %d and %d", succeeded, failed)
+	}
+}
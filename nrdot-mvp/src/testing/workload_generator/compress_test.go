@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// capturedRequest is what a capturing server saw of the last request.
+type capturedRequest struct {
+	contentEncoding string
+	body            []byte
+}
+
+func TestGzipPayloadDecodesToOriginal(t *testing.T) {
+	logger = zap.NewNop()
+	var captured capturedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		captured = capturedRequest{contentEncoding: r.Header.Get("Content-Encoding"), body: body}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resource := map[string]string{"service.name": "checkout"}
+	for _, compress := range []string{CompressNone, CompressGzip} {
+		t.Run(compress, func(t *testing.T) {
+			config = DefaultConfig()
+			config.TargetURL = server.URL
+			config.Compress = compress
+			if err := config.Validate(); err != nil {
+				t.Fatalf("invalid config: %v", err)
+			}
+			initTargets(config)
+
+			payload := generateMetricsPayload(resource, 1)
+			statsMutex.Lock()
+			before := bytesTotal
+			statsMutex.Unlock()
+			if !sendOTLP(OTLPMetricsPath, payload, "") {
+				t.Fatal("expected the request to be accepted")
+			}
+
+			body := captured.body
+			if compress == CompressGzip {
+				if captured.contentEncoding != "gzip" {
+					t.Fatalf("expected a gzip Content-Encoding, got %q", captured.contentEncoding)
+				}
+				reader, err := gzip.NewReader(bytes.NewReader(captured.body))
+				if err != nil {
+					t.Fatalf("expected a gzip body: %v", err)
+				}
+				if body, err = io.ReadAll(reader); err != nil {
+					t.Fatalf("failed to decompress body: %v", err)
+				}
+			} else if captured.contentEncoding != "" {
+				t.Fatalf("expected no Content-Encoding, got %q", captured.contentEncoding)
+			}
+			if !bytes.Equal(body, payload) {
+				t.Fatalf("expected the body to decode to the original payload, got %s", body)
+			}
+
+			// The bytes counted are those on the wire
+			statsMutex.Lock()
+			sent := bytesTotal - before
+			statsMutex.Unlock()
+			if sent != int64(len(captured.body)) {
+				t.Fatalf("expected %d bytes to be counted, got %d", len(captured.body), sent)
+			}
+		})
+	}
+
+	config = DefaultConfig()
+	config.Compress = "zstd"
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected an unknown compression to be rejected")
+	}
+}
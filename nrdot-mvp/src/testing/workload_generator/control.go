@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// paused stops the workers from sending while set. The run's duration keeps
+// counting down while paused.
+var paused atomic.Bool
+
+// startControlServer starts the HTTP server taking pause and resume requests.
+func startControlServer(port int) {
+	addr := fmt.Sprintf(":%d", port)
+	logger.Info("Starting control server", zap.String("addr", addr))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pause", handlePauseControl(true))
+	mux.HandleFunc("/resume", handlePauseControl(false))
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Fatal("Failed to start control server", zap.Error(err))
+	}
+}
+
+// handlePauseControl returns a handler that pauses or resumes sending.
+func handlePauseControl(pause bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if paused.Swap(pause) != pause {
+			if pause {
+				logger.Info("Paused sending")
+			} else {
+				logger.Info("Resumed sending")
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf(`{"paused":%t}`, pause)))
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// countingServer counts the requests it receives.
+func countingServer(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// control sends a pause or resume request to the control handler.
+func control(t *testing.T, pause bool) {
+	t.Helper()
+
+	path := "/resume"
+	if pause {
+		path = "/pause"
+	}
+	recorder := httptest.NewRecorder()
+	handlePauseControl(pause)(recorder, httptest.NewRequest(http.MethodPost, path, nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %s to succeed, got %d", path, recorder.Code)
+	}
+}
+
+func TestPauseStopsSendingUntilResumed(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger = zap.New(core)
+	server, requests := countingServer(t)
+
+	config = DefaultConfig()
+	config.TargetURL = server.URL
+	config.Workers = 1
+	config.RateLimit = 100
+	config.SendTraces = false
+	config.SendLogs = false
+	liveConfig.Store(config)
+	initTargets(config)
+	startTime = time.Now()
+	endTime = startTime.Add(time.Minute)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go worker(0, &wg)
+	t.Cleanup(func() {
+		aborted.Store(true)
+		wg.Wait()
+		aborted.Store(false)
+		paused.Store(false)
+	})
+
+	// rate returns the requests received per second over the next period
+	rate := func(period time.Duration) float64 {
+		before := requests.Load()
+		time.Sleep(period)
+		return float64(requests.Load()-before) / period.Seconds()
+	}
+
+	if got := rate(300 * time.Millisecond); got < 30 {
+		t.Fatalf("expected about 100 requests per second before pausing, got %.0f", got)
+	}
+
+	// Once an in-flight request finishes, nothing more is sent
+	control(t, true)
+	time.Sleep(50 * time.Millisecond)
+	if got := rate(300 * time.Millisecond); got > 0 {
+		t.Fatalf("expected no requests while paused, got %.0f per second", got)
+	}
+	printStats(false)
+	entries := logs.FilterMessage("Workload stats (progress)").AllUntimed()
+	if len(entries) != 1 || entries[0].ContextMap()["paused"] != true {
+		t.Fatalf("expected the stats to report the paused state, got %v", entries)
+	}
+
+	control(t, false)
+	if got := rate(300 * time.Millisecond); got < 30 {
+		t.Fatalf("expected sending to resume, got %.0f requests per second", got)
+	}
+
+	// Only a change of state is logged
+	control(t, false)
+	if got := logs.FilterMessageSnippet("sending").Len(); got != 2 {
+		var messages []string
+		for _, entry := range logs.FilterMessageSnippet("sending").All() {
+			messages = append(messages, entry.Message)
+		}
+		t.Fatalf("expected one pause and one resume to be logged, got %s", strings.Join(messages, ", "))
+	}
+}
+
+func TestPauseControlRequiresPost(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	handlePauseControl(true)(recorder, httptest.NewRequest(http.MethodGet, "/pause", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected a GET to be rejected, got %d", recorder.Code)
+	}
+	if paused.Load() {
+		t.Fatal("expected a rejected request not to pause sending")
+	}
+}
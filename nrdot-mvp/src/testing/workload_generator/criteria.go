@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Latencies are counted in buckets whose bounds grow by latencyBucketGrowth,
+// so percentiles are accurate to within 2% however long the run, in fixed
+// memory. The buckets reach past an hour; longer latencies fall in the last.
+const (
+	latencyBucketGrowth = 1.02
+	latencyBuckets      = 1200
+)
+
+// latencyHistogram counts request latencies in exponentially growing buckets.
+type latencyHistogram struct {
+	counts [latencyBuckets]int64
+	total  int64
+}
+
+// record counts a latency in microseconds.
+func (h *latencyHistogram) record(micros int64) {
+	bucket := 0
+	if micros >= 1 {
+		bucket = int(math.Log(float64(micros))/math.Log(latencyBucketGrowth)) + 1
+	}
+	if bucket >= latencyBuckets {
+		bucket = latencyBuckets - 1
+	}
+	h.counts[bucket]++
+	h.total++
+}
+
+// percentile returns the upper bound of the bucket holding the latency below
+// which the given fraction of the counted latencies fall, or 0 if none were
+// counted.
+func (h *latencyHistogram) percentile(fraction float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(fraction * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for bucket, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			micros := math.Pow(latencyBucketGrowth, float64(bucket))
+			return time.Duration(micros * float64(time.Microsecond))
+		}
+	}
+	return 0
+}
+
+// latencyPercentile returns the latency below which the given fraction of
+// successful requests completed, or 0 if none succeeded. The caller must
+// hold statsMutex.
+func latencyPercentile(fraction float64) time.Duration {
+	return latencies.percentile(fraction)
+}
+
+// checkSuccessCriteria compares the run's failure rate and p99 latency with
+// the configured maximums, and returns an error describing every maximum
+// that was exceeded and why the run was aborted, if it was.
+func checkSuccessCriteria(cfg *Config) error {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	var violations []string
+
+	if reason := abortedReason(); reason != "" {
+		violations = append(violations, "run aborted early: "+reason)
+	}
+
+	if cfg.MaxFailurePercent > 0 {
+		var failurePercent float64
+		if total := requestsSent + requestsFailed; total > 0 {
+			failurePercent = float64(requestsFailed) / float64(total) * 100
+		}
+		if failurePercent > cfg.MaxFailurePercent {
+			violations = append(violations, fmt.Sprintf("failure rate %.2f%% exceeds max_failure_percent %.2f%%",
+				failurePercent, cfg.MaxFailurePercent))
+		}
+	}
+
+	if cfg.MaxP99Ms > 0 {
+		p99 := latencyPercentile(0.99)
+		if p99 > time.Duration(cfg.MaxP99Ms)*time.Millisecond {
+			violations = append(violations, fmt.Sprintf("p99 latency %dms exceeds max_p99_ms %dms",
+				p99.Milliseconds(), cfg.MaxP99Ms))
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("%s", strings.Join(violations, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the generator itself when a test starts it as a subprocess,
+// so its exit code can be checked.
+func TestMain(m *testing.M) {
+	if target := os.Getenv("WORKLOAD_GENERATOR_TEST_TARGET"); target != "" {
+		os.Args = append([]string{"workload_generator", "-profile", "criteria_test", "-target-url", target, "-workers", "1"},
+			strings.Fields(os.Getenv("WORKLOAD_GENERATOR_TEST_ARGS"))...)
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runGenerator runs the generator with the given flags against a server
+// answering every request with status, returning its output and exit code.
+func runGenerator(t *testing.T, status int, args string) (string, int) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), "WORKLOAD_GENERATOR_TEST_TARGET="+server.URL, "WORKLOAD_GENERATOR_TEST_ARGS="+args)
+	output, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return string(output), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("failed to run the generator: %v", err)
+	}
+	return string(output), 0
+}
+
+func TestHighFailureRateExitsNonZero(t *testing.T) {
+	output, code := runGenerator(t, http.StatusInternalServerError, "-duration 1 -max-failure-percent 10")
+	if code == 0 {
+		t.Fatalf("expected a non-zero exit against a failing server, got 0:\n%s", output)
+	}
+	if !strings.Contains(output, "Workload success criteria not met") || !strings.Contains(output, "exceeds max_failure_percent") {
+		t.Fatalf("expected the exceeded failure rate to be reported, got:\n%s", output)
+	}
+
+	// The same run against a healthy server succeeds
+	output, code = runGenerator(t, http.StatusOK, "-duration 1 -max-failure-percent 10")
+	if code != 0 {
+		t.Fatalf("expected a zero exit against a healthy server, got %d:\n%s", code, output)
+	}
+}
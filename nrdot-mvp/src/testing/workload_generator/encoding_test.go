@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+)
+
+func TestProtobufPayloadDecodesToMetrics(t *testing.T) {
+	config = DefaultConfig()
+	config.Encoding = EncodingProtobuf
+	config.DimensionsPerMetric = 2
+
+	resource := map[string]string{"service.name": "checkout", "host.name": "host-1"}
+	encoded, contentType, err := encodePayload(OTLPMetricsPath, generateMetricsPayload(resource, 7))
+	if err != nil {
+		t.Fatalf("failed to encode payload: %v", err)
+	}
+	if contentType != "application/x-protobuf" {
+		t.Fatalf("expected the protobuf content type, got %q", contentType)
+	}
+
+	req := pmetricotlp.NewExportRequest()
+	if err := req.UnmarshalProto(encoded); err != nil {
+		t.Fatalf("failed to decode protobuf payload: %v", err)
+	}
+	md := req.Metrics()
+	if md.ResourceMetrics().Len() != 1 || md.DataPointCount() != 1 {
+		t.Fatalf("expected 1 resource with 1 data point, got %d resources and %d data points",
+			md.ResourceMetrics().Len(), md.DataPointCount())
+	}
+	rm := md.ResourceMetrics().At(0)
+	for key, expected := range resource {
+		if value, _ := rm.Resource().Attributes().Get(key); value.AsString() != expected {
+			t.Fatalf("expected %s to be %q, got %q", key, expected, value.AsString())
+		}
+	}
+
+	attrs := rm.ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0).Attributes()
+	if sequence, _ := attrs.Get(SequenceAttribute); sequence.Int() != 7 {
+		t.Fatalf("expected sequence 7, got %v", sequence.AsString())
+	}
+	if attrs.Len() != 3 {
+		t.Fatalf("expected the sequence and 2 dimensions, got %v", attrs.AsRaw())
+	}
+}
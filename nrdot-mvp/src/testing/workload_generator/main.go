@@ -2,17 +2,24 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"go.uber.org/zap"
 )
 
@@ -20,72 +27,121 @@ import (
 type Config struct {
 	// Target URL for sending data
 	TargetURL string `json:"target_url"`
-	
+
+	// Target URLs to spread load over, such as a cluster of collectors.
+	// Takes precedence over TargetURL when set.
+	TargetURLs []string `json:"target_urls"`
+
+	// Strategy for picking the target of each request, "round_robin",
+	// "random" or "weighted"
+	TargetStrategy string `json:"target_strategy"`
+
+	// Relative weight of each target URL with the "weighted" strategy
+	TargetWeights []int `json:"target_weights"`
+
 	// Number of concurrent workers
 	Workers int `json:"workers"`
-	
+
 	// Rate limit (requests per second)
 	RateLimit int `json:"rate_limit"`
-	
+
 	// Duration of the test in seconds
 	Duration int `json:"duration"`
-	
+
 	// Send metrics
 	SendMetrics bool `json:"send_metrics"`
-	
+
 	// Send traces
 	SendTraces bool `json:"send_traces"`
-	
+
 	// Send logs
 	SendLogs bool `json:"send_logs"`
-	
+
 	// Number of unique services to simulate
 	UniqueServices int `json:"unique_services"`
-	
+
 	// Number of unique hosts to simulate
 	UniqueHosts int `json:"unique_hosts"`
-	
+
 	// Number of unique instances to simulate
 	UniqueInstances int `json:"unique_instances"`
-	
+
 	// Number of unique metrics to generate
 	UniqueMetrics int `json:"unique_metrics"`
-	
+
 	// Number of unique traces to generate
 	UniqueTraces int `json:"unique_traces"`
-	
+
 	// Number of unique logs to generate
 	UniqueLogs int `json:"unique_logs"`
-	
+
 	// Number of dimensions per metric
 	DimensionsPerMetric int `json:"dimensions_per_metric"`
-	
+
 	// Percentage of metrics that are critical priority (0-100)
 	CriticalPercent int `json:"critical_percent"`
-	
+
 	// Percentage of metrics that are high priority (0-100)
 	HighPercent int `json:"high_percent"`
-	
+
+	// Rules deriving the priority of each payload from its resource
+	// attributes, first match wins. When set, they replace the random
+	// critical_percent and high_percent rolls.
+	PriorityRules []PriorityRule `json:"priority_rules"`
+
 	// Whether to introduce a random spike in cardinality
 	CardinalitySpike bool `json:"cardinality_spike"`
-	
+
 	// If true, spike occurs at a random time. If false, occurs at SpikeTime
 	RandomSpikeTime bool `json:"random_spike_time"`
-	
+
 	// Time in seconds when to introduce the spike
 	SpikeTime int `json:"spike_time"`
-	
+
 	// Duration of the spike in seconds
 	SpikeDuration int `json:"spike_duration"`
-	
+
 	// Factor to multiply cardinality during spike
 	SpikeFactor int `json:"spike_factor"`
+
+	// Whether to tag each metrics data point with a sequence ID
+	SequenceIDs bool `json:"sequence_ids"`
+
+	// File to write the accepted sequence IDs to when the run completes
+	SequenceFile string `json:"sequence_file"`
+
+	// Payload encoding, "json" or "protobuf"
+	Encoding string `json:"encoding"`
+
+	// Payload compression, "none" or "gzip"
+	Compress string `json:"compress"`
+
+	// Failure rate in percent above which the run exits non-zero, 0 to
+	// ignore failures
+	MaxFailurePercent float64 `json:"max_failure_percent"`
+
+	// p99 latency of successful requests in milliseconds above which the run
+	// exits non-zero, 0 to ignore latency
+	MaxP99Ms int `json:"max_p99_ms"`
+
+	// Port of the HTTP server taking POST /pause and /resume requests, 0 to
+	// disable it
+	ControlPort int `json:"control_port"`
+
+	// Seconds of the rolling window the success rate is measured over to
+	// stop the run early, 0 to always run for the full duration
+	AbortWindowSec int `json:"abort_window_sec"`
+
+	// Success rate in percent over the abort window below which the run is
+	// stopped early
+	AbortMinSuccessPercent float64 `json:"abort_min_success_percent"`
 }
 
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
 		TargetURL:           "http://localhost:4318",
+		TargetStrategy:      TargetRoundRobin,
 		Workers:             10,
 		RateLimit:           1000,
 		Duration:            300,
@@ -106,21 +162,134 @@ func DefaultConfig() *Config {
 		SpikeTime:           60,
 		SpikeDuration:       30,
 		SpikeFactor:         10,
+		SequenceIDs:         false,
+		SequenceFile:        "",
+		Encoding:            EncodingJSON,
+		Compress:            CompressNone,
 	}
 }
 
+// Validate checks that the configuration describes a runnable workload and
+// returns an error naming the first invalid field.
+func (c *Config) Validate() error {
+	if c.TargetURL == "" && len(c.TargetURLs) == 0 {
+		return fmt.Errorf("target_url or target_urls must be set")
+	}
+	if err := c.validateTargets(); err != nil {
+		return err
+	}
+	if c.Workers <= 0 {
+		return fmt.Errorf("workers must be greater than 0, got %d", c.Workers)
+	}
+	if c.RateLimit < c.Workers {
+		return fmt.Errorf("rate_limit (%d) must be at least workers (%d)", c.RateLimit, c.Workers)
+	}
+	if c.Duration <= 0 {
+		return fmt.Errorf("duration must be greater than 0, got %d", c.Duration)
+	}
+
+	// Each simulated dimension needs at least one value to pick from
+	counts := []struct {
+		name  string
+		value int
+	}{
+		{"unique_services", c.UniqueServices},
+		{"unique_hosts", c.UniqueHosts},
+		{"unique_instances", c.UniqueInstances},
+		{"unique_metrics", c.UniqueMetrics},
+		{"unique_traces", c.UniqueTraces},
+		{"unique_logs", c.UniqueLogs},
+	}
+	for _, count := range counts {
+		if count.value <= 0 {
+			return fmt.Errorf("%s must be greater than 0, got %d", count.name, count.value)
+		}
+	}
+	if c.DimensionsPerMetric < 0 {
+		return fmt.Errorf("dimensions_per_metric must not be negative, got %d", c.DimensionsPerMetric)
+	}
+
+	if c.CriticalPercent < 0 || c.CriticalPercent > 100 {
+		return fmt.Errorf("critical_percent must be between 0 and 100, got %d", c.CriticalPercent)
+	}
+	if c.HighPercent < 0 || c.HighPercent > 100 {
+		return fmt.Errorf("high_percent must be between 0 and 100, got %d", c.HighPercent)
+	}
+	if c.CriticalPercent+c.HighPercent > 100 {
+		return fmt.Errorf("critical_percent (%d) and high_percent (%d) must not sum to more than 100", c.CriticalPercent, c.HighPercent)
+	}
+	if err := c.validatePriorityRules(); err != nil {
+		return err
+	}
+
+	if c.CardinalitySpike {
+		if c.SpikeTime < 0 {
+			return fmt.Errorf("spike_time must not be negative, got %d", c.SpikeTime)
+		}
+		if c.SpikeDuration <= 0 {
+			return fmt.Errorf("spike_duration must be greater than 0, got %d", c.SpikeDuration)
+		}
+		if c.SpikeFactor < 1 {
+			return fmt.Errorf("spike_factor must be at least 1, got %d", c.SpikeFactor)
+		}
+	}
+
+	if c.Encoding != EncodingJSON && c.Encoding != EncodingProtobuf {
+		return fmt.Errorf("encoding must be %q or %q, got %q", EncodingJSON, EncodingProtobuf, c.Encoding)
+	}
+	if c.Compress != CompressNone && c.Compress != CompressGzip {
+		return fmt.Errorf("compress must be %q or %q, got %q", CompressNone, CompressGzip, c.Compress)
+	}
+
+	if c.MaxFailurePercent < 0 || c.MaxFailurePercent > 100 {
+		return fmt.Errorf("max_failure_percent must be between 0 and 100, got %g", c.MaxFailurePercent)
+	}
+	if c.MaxP99Ms < 0 {
+		return fmt.Errorf("max_p99_ms must not be negative, got %d", c.MaxP99Ms)
+	}
+	if c.ControlPort < 0 || c.ControlPort > 65535 {
+		return fmt.Errorf("control_port must be between 0 and 65535, got %d", c.ControlPort)
+	}
+	if c.AbortWindowSec < 0 {
+		return fmt.Errorf("abort_window_sec must not be negative, got %d", c.AbortWindowSec)
+	}
+	if c.AbortMinSuccessPercent < 0 || c.AbortMinSuccessPercent > 100 {
+		return fmt.Errorf("abort_min_success_percent must be between 0 and 100, got %g", c.AbortMinSuccessPercent)
+	}
+	if c.AbortWindowSec > 0 && c.AbortMinSuccessPercent == 0 {
+		return fmt.Errorf("abort_min_success_percent must be set when abort_window_sec is")
+	}
+
+	return nil
+}
+
 // Constants
 const (
 	OTLPMetricsPath = "/v1/metrics"
 	OTLPTracesPath  = "/v1/traces"
 	OTLPLogsPath    = "/v1/logs"
+
+	// SequenceAttribute is the data point attribute carrying the sequence ID
+	SequenceAttribute = "nrdot.sequence"
+
+	// Payload encodings
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+
+	// Payload compressions
+	CompressNone = "none"
+	CompressGzip = "gzip"
 )
 
 // Global variables
 var (
 	logger *zap.Logger
 	config *Config
-	
+
+	// Configuration in effect for generating load, swapped atomically when
+	// the profile is reloaded on SIGHUP
+	liveConfig atomic.Pointer[Config]
+
 	// Runtime state
 	startTime      time.Time
 	endTime        time.Time
@@ -128,24 +297,40 @@ var (
 	requestsFailed int64
 	bytesTotal     int64
 	latencyTotal   int64
+	latencies      latencyHistogram // of successful requests
 	statsMutex     sync.Mutex
-	
+
 	// Workload state
 	inSpike          bool
 	spikeStartTime   time.Time
 	spikeEndTime     time.Time
 	normalDimensions int
 	spikeDimensions  int
+
+	// Sequence state
+	lastSequence      int64
+	acceptedSequences []int64
 )
 
 func main() {
 	// Parse command line flags
 	profileName := flag.String("profile", "default", "Name of the workload profile to use")
 	targetURL := flag.String("target-url", "", "Target URL for the OTLP endpoint")
+	targetURLs := flag.String("target-urls", "", "Comma-separated target URLs to spread load over")
+	targetStrategy := flag.String("target-strategy", "", "Strategy for picking a target URL (round_robin, random, weighted)")
 	workers := flag.Int("workers", 0, "Number of concurrent workers")
 	duration := flag.Int("duration", 0, "Duration of the test in seconds")
+	sequenceIDs := flag.Bool("sequence-ids", false, "Tag each metrics data point with a sequence ID")
+	sequenceFile := flag.String("sequence-file", "", "File to write the accepted sequence IDs to")
+	encoding := flag.String("encoding", "", "Payload encoding (json, protobuf)")
+	compress := flag.String("compress", "", "Payload compression (none, gzip)")
+	maxFailurePercent := flag.Float64("max-failure-percent", 0, "Exit non-zero if more than this percentage of requests fail")
+	maxP99Ms := flag.Int("max-p99-ms", 0, "Exit non-zero if the p99 latency exceeds this many milliseconds")
+	controlPort := flag.Int("control-port", 0, "Port of the pause/resume control server")
+	abortWindow := flag.Int("abort-window", 0, "Seconds of the rolling window measured to stop the run early")
+	abortMinSuccess := flag.Float64("abort-min-success-percent", 0, "Stop the run early if fewer than this percentage of requests succeed over the abort window")
 	flag.Parse()
-	
+
 	// Initialize logger
 	var err error
 	logger, err = zap.NewProduction()
@@ -154,48 +339,91 @@ func main() {
 		os.Exit(1)
 	}
 	defer logger.Sync()
-	
+
 	// Load configuration from profile
 	config, err = loadProfile(*profileName)
 	if err != nil {
 		logger.Fatal("Failed to load profile", zap.Error(err))
 	}
-	
+
 	// Override configuration with command line flags
 	if *targetURL != "" {
 		config.TargetURL = *targetURL
 	}
+	if *targetURLs != "" {
+		config.TargetURLs = strings.Split(*targetURLs, ",")
+	}
+	if *targetStrategy != "" {
+		config.TargetStrategy = *targetStrategy
+	}
 	if *workers > 0 {
 		config.Workers = *workers
 	}
 	if *duration > 0 {
 		config.Duration = *duration
 	}
-	
+	if *sequenceIDs {
+		config.SequenceIDs = true
+	}
+	if *sequenceFile != "" {
+		config.SequenceFile = *sequenceFile
+	}
+	if *encoding != "" {
+		config.Encoding = *encoding
+	}
+	if *compress != "" {
+		config.Compress = *compress
+	}
+	if *maxFailurePercent > 0 {
+		config.MaxFailurePercent = *maxFailurePercent
+	}
+	if *maxP99Ms > 0 {
+		config.MaxP99Ms = *maxP99Ms
+	}
+	if *controlPort > 0 {
+		config.ControlPort = *controlPort
+	}
+	if *abortWindow > 0 {
+		config.AbortWindowSec = *abortWindow
+	}
+	if *abortMinSuccess > 0 {
+		config.AbortMinSuccessPercent = *abortMinSuccess
+	}
+	if err := config.Validate(); err != nil {
+		logger.Fatal("Invalid configuration", zap.Error(err))
+	}
+
 	// Check if target URL is from environment variable
 	if envURL := os.Getenv("TARGET_URL"); envURL != "" {
 		config.TargetURL = envURL
 	}
-	
+
+	// Spread requests over the target URLs
+	initTargets(config)
+
+	// Reload safe settings from the profile on SIGHUP
+	liveConfig.Store(config)
+	go watchReload(*profileName)
+
 	// Initialize workload state
 	startTime = time.Now()
 	endTime = startTime.Add(time.Duration(config.Duration) * time.Second)
-	
+
 	// Set up cardinality spike if enabled
 	if config.CardinalitySpike {
 		normalDimensions = config.DimensionsPerMetric
 		spikeDimensions = normalDimensions * config.SpikeFactor
-		
+
 		var spikeDelay time.Duration
 		if config.RandomSpikeTime {
 			spikeDelay = time.Duration(rand.Intn(config.Duration-config.SpikeDuration)) * time.Second
 		} else {
 			spikeDelay = time.Duration(config.SpikeTime) * time.Second
 		}
-		
+
 		spikeStartTime = startTime.Add(spikeDelay)
 		spikeEndTime = spikeStartTime.Add(time.Duration(config.SpikeDuration) * time.Second)
-		
+
 		logger.Info("Cardinality spike scheduled",
 			zap.Time("startTime", spikeStartTime),
 			zap.Time("endTime", spikeEndTime),
@@ -203,33 +431,59 @@ func main() {
 			zap.Int("spikeDimensions", spikeDimensions),
 		)
 	}
-	
+
 	// Log configuration
 	logger.Info("Starting workload generator",
-		zap.String("targetURL", config.TargetURL),
+		zap.Strings("targetURLs", config.targetURLs()),
+		zap.String("targetStrategy", config.TargetStrategy),
 		zap.Int("workers", config.Workers),
 		zap.Int("rateLimit", config.RateLimit),
 		zap.Int("duration", config.Duration),
+		zap.String("encoding", config.Encoding),
+		zap.String("compress", config.Compress),
 		zap.Time("startTime", startTime),
 		zap.Time("endTime", endTime),
 	)
-	
+
 	// Start stats reporter
 	go statsReporter()
-	
+
+	// Accept pause and resume requests if enabled
+	if config.ControlPort > 0 {
+		go startControlServer(config.ControlPort)
+	}
+
+	// Stop early if the target stops accepting requests
+	if config.AbortWindowSec > 0 {
+		recentOutcomes = newOutcomeWindow(config.AbortWindowSec)
+		go abortMonitor(config)
+	}
+
 	// Start workers
 	var wg sync.WaitGroup
 	for i := 0; i < config.Workers; i++ {
 		wg.Add(1)
 		go worker(i, &wg)
 	}
-	
+
 	// Wait for completion
 	wg.Wait()
-	
+
 	// Print final stats
 	printStats(true)
-	
+
+	// Write the accepted sequence IDs for delivery verification
+	if config.SequenceIDs && config.SequenceFile != "" {
+		if err := writeSequenceFile(config.SequenceFile); err != nil {
+			logger.Fatal("Failed to write sequence file", zap.Error(err))
+		}
+	}
+
+	// Fail the run, such as a CI job, if it missed the success criteria
+	if err := checkSuccessCriteria(config); err != nil {
+		logger.Fatal("Workload success criteria not met", zap.Error(err))
+	}
+
 	logger.Info("Workload generation completed")
 }
 
@@ -237,7 +491,7 @@ func main() {
 func loadProfile(name string) (*Config, error) {
 	// Default config
 	config := DefaultConfig()
-	
+
 	// Try to load from file
 	profilePath := fmt.Sprintf("profiles/%s.json", name)
 	data, err := os.ReadFile(profilePath)
@@ -247,16 +501,27 @@ func loadProfile(name string) (*Config, error) {
 			zap.String("profile", name),
 			zap.Error(err),
 		)
-		return applyEnvironmentOverrides(config), nil
+		return validateProfile(name, applyEnvironmentOverrides(config))
 	}
-	
-	// Parse JSON
-	if err := json.Unmarshal(data, config); err != nil {
-		return nil, fmt.Errorf("failed to parse profile file: %w", err)
+
+	// Parse JSON, rejecting unknown fields so a typo isn't silently ignored
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("failed to parse profile file %s: %w", profilePath, err)
 	}
-	
+
 	// Apply environment overrides
-	return applyEnvironmentOverrides(config), nil
+	return validateProfile(name, applyEnvironmentOverrides(config))
+}
+
+// validateProfile returns the loaded profile, or an error naming the profile
+// if it is invalid.
+func validateProfile(name string, config *Config) (*Config, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid profile %q: %w", name, err)
+	}
+	return config, nil
 }
 
 // applyEnvironmentOverrides applies environment variable overrides to the config.
@@ -270,7 +535,7 @@ func applyEnvironmentOverrides(config *Config) *Config {
 		}
 		return defaultVal
 	}
-	
+
 	// Helper function to parse bool from environment
 	getEnvBool := func(key string, defaultVal bool) bool {
 		if val, exists := os.LookupEnv(key); exists {
@@ -278,40 +543,147 @@ func applyEnvironmentOverrides(config *Config) *Config {
 		}
 		return defaultVal
 	}
-	
+
 	// Apply overrides
 	if val, exists := os.LookupEnv("TARGET_URL"); exists {
 		config.TargetURL = val
 	}
-	
+
 	config.Workers = getEnvInt("WORKERS", config.Workers)
 	config.RateLimit = getEnvInt("RATE_LIMIT", config.RateLimit)
 	config.Duration = getEnvInt("DURATION", config.Duration)
 	config.SendMetrics = getEnvBool("SEND_METRICS", config.SendMetrics)
 	config.SendTraces = getEnvBool("SEND_TRACES", config.SendTraces)
 	config.SendLogs = getEnvBool("SEND_LOGS", config.SendLogs)
-	
+	config.SequenceIDs = getEnvBool("SEQUENCE_IDS", config.SequenceIDs)
+	config.ControlPort = getEnvInt("CONTROL_PORT", config.ControlPort)
+	if val, exists := os.LookupEnv("SEQUENCE_FILE"); exists {
+		config.SequenceFile = val
+	}
+	if val, exists := os.LookupEnv("ENCODING"); exists {
+		config.Encoding = val
+	}
+	if val, exists := os.LookupEnv("COMPRESS"); exists {
+		config.Compress = val
+	}
+
 	return config
 }
 
+// watchReload reloads the profile each time the process receives SIGHUP.
+func watchReload(profileName string) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+
+	for range sigCh {
+		reloadProfile(profileName)
+	}
+}
+
+// reloadProfile re-reads the profile and applies the settings that can change
+// while the workload is running. Other settings keep their current values and
+// changes to them are logged as ignored.
+func reloadProfile(profileName string) {
+	loaded, err := loadProfile(profileName)
+	if err != nil {
+		logger.Error("Failed to reload profile, keeping current settings", zap.Error(err))
+		return
+	}
+
+	current := liveConfig.Load()
+	updated := *current
+	updated.RateLimit = loaded.RateLimit
+	updated.CriticalPercent = loaded.CriticalPercent
+	updated.HighPercent = loaded.HighPercent
+	updated.PriorityRules = loaded.PriorityRules
+	updated.SendMetrics = loaded.SendMetrics
+	updated.SendTraces = loaded.SendTraces
+	updated.SendLogs = loaded.SendLogs
+
+	// Workers, the schedule and the target are fixed at startup
+	if loaded.Workers != current.Workers {
+		logger.Warn("Ignoring workers change until restart", zap.Int("workers", loaded.Workers))
+	}
+	if loaded.Duration != current.Duration {
+		logger.Warn("Ignoring duration change until restart", zap.Int("duration", loaded.Duration))
+	}
+	if loaded.TargetURL != current.TargetURL {
+		logger.Warn("Ignoring target_url change until restart", zap.String("targetURL", loaded.TargetURL))
+	}
+	if strings.Join(loaded.TargetURLs, ",") != strings.Join(current.TargetURLs, ",") {
+		logger.Warn("Ignoring target_urls change until restart", zap.Strings("targetURLs", loaded.TargetURLs))
+	}
+	if loaded.CardinalitySpike != current.CardinalitySpike {
+		logger.Warn("Ignoring cardinality_spike change until restart", zap.Bool("cardinalitySpike", loaded.CardinalitySpike))
+	}
+	if loaded.SequenceIDs != current.SequenceIDs {
+		logger.Warn("Ignoring sequence_ids change until restart", zap.Bool("sequenceIDs", loaded.SequenceIDs))
+	}
+
+	liveConfig.Store(&updated)
+
+	logger.Info("Reloaded profile",
+		zap.String("profile", profileName),
+		zap.Int("rateLimit", updated.RateLimit),
+		zap.Int("criticalPercent", updated.CriticalPercent),
+		zap.Int("highPercent", updated.HighPercent),
+		zap.Int("priorityRules", len(updated.PriorityRules)),
+		zap.Bool("sendMetrics", updated.SendMetrics),
+		zap.Bool("sendTraces", updated.SendTraces),
+		zap.Bool("sendLogs", updated.SendLogs),
+	)
+}
+
+// minRequestInterval bounds how often a single worker sends, so a very high
+// rate limit can't produce a zero or negative ticker interval.
+const minRequestInterval = time.Microsecond
+
+// requestInterval returns the interval between requests for each worker to
+// achieve the configured rate limit. Workers share the rate, so with more
+// workers than requests per second each worker sends less than once a second.
+// A non-positive rate or worker count falls back to one request per second.
+func requestInterval(cfg *Config) time.Duration {
+	if cfg.RateLimit <= 0 || cfg.Workers <= 0 {
+		return time.Second
+	}
+
+	perWorkerRate := float64(cfg.RateLimit) / float64(cfg.Workers)
+	interval := time.Duration(float64(time.Second) / perWorkerRate)
+	if interval < minRequestInterval {
+		interval = minRequestInterval
+	}
+	return interval
+}
+
 // worker is a goroutine that generates and sends workload.
 func worker(id int, wg *sync.WaitGroup) {
 	defer wg.Done()
-	
+
 	logger.Info("Worker started", zap.Int("workerID", id))
-	
+
 	// Calculate interval between requests to achieve rate limit
-	interval := time.Duration(1000000000 / (config.RateLimit / config.Workers)) * time.Nanosecond
-	
+	interval := requestInterval(liveConfig.Load())
+
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
-	
+
 	for range ticker.C {
-		// Check if test duration has elapsed
-		if time.Now().After(endTime) {
+		// Check if test duration has elapsed or the run was aborted
+		if time.Now().After(endTime) || aborted.Load() {
 			break
 		}
-		
+
+		// Skip sending while paused
+		if paused.Load() {
+			continue
+		}
+
+		// Pick up a rate limit change from a reload
+		if newInterval := requestInterval(liveConfig.Load()); newInterval != interval {
+			interval = newInterval
+			ticker.Reset(interval)
+		}
+
 		// Update spike status
 		if config.CardinalitySpike {
 			now := time.Now()
@@ -329,35 +701,36 @@ func worker(id int, wg *sync.WaitGroup) {
 				)
 			}
 		}
-		
+
 		// Send telemetry data
 		sendData()
 	}
-	
+
 	logger.Info("Worker finished", zap.Int("workerID", id))
 }
 
 // sendData generates and sends telemetry data.
 func sendData() {
 	// Determine what to send based on configuration and random selection
+	cfg := liveConfig.Load()
 	sendTypes := make([]string, 0, 3)
-	if config.SendMetrics {
+	if cfg.SendMetrics {
 		sendTypes = append(sendTypes, "metrics")
 	}
-	if config.SendTraces {
+	if cfg.SendTraces {
 		sendTypes = append(sendTypes, "traces")
 	}
-	if config.SendLogs {
+	if cfg.SendLogs {
 		sendTypes = append(sendTypes, "logs")
 	}
-	
+
 	if len(sendTypes) == 0 {
 		return
 	}
-	
+
 	// Randomly select one type to send
 	dataType := sendTypes[rand.Intn(len(sendTypes))]
-	
+
 	switch dataType {
 	case "metrics":
 		sendMetrics()
@@ -370,64 +743,151 @@ func sendData() {
 
 // sendMetrics generates and sends metrics data.
 func sendMetrics() {
+	// Assign a sequence ID if enabled
+	var sequence int64
+	if config.SequenceIDs {
+		sequence = atomic.AddInt64(&lastSequence, 1)
+	}
+
 	// Generate metrics data
-	payload := generateMetricsPayload()
-	
+	resource := generateResource()
+	payload := generateMetricsPayload(resource, sequence)
+
 	// Send to OTLP endpoint
-	sendOTLP(OTLPMetricsPath, payload)
+	if sendOTLP(OTLPMetricsPath, payload, determinePriority(resource)) && sequence > 0 {
+		recordAcceptedSequence(sequence)
+	}
 }
 
 // sendTraces generates and sends traces data.
 func sendTraces() {
 	// Generate traces data
 	payload := generateTracesPayload()
-	
-	// Send to OTLP endpoint
-	sendOTLP(OTLPTracesPath, payload)
+
+	// Send to OTLP endpoint. The placeholder payload has no resource.
+	sendOTLP(OTLPTracesPath, payload, determinePriority(nil))
 }
 
 // sendLogs generates and sends logs data.
 func sendLogs() {
 	// Generate logs data
 	payload := generateLogsPayload()
-	
-	// Send to OTLP endpoint
-	sendOTLP(OTLPLogsPath, payload)
+
+	// Send to OTLP endpoint. The placeholder payload has no resource.
+	sendOTLP(OTLPLogsPath, payload, determinePriority(nil))
 }
 
-// sendOTLP sends data to the OTLP endpoint.
-func sendOTLP(path string, payload []byte) {
-	url := config.TargetURL + path
-	
+// encodePayload converts an OTLP JSON payload to the configured encoding and
+// returns it with its content type.
+func encodePayload(path string, payload []byte) ([]byte, string, error) {
+	if config.Encoding != EncodingProtobuf {
+		return payload, "application/json", nil
+	}
+
+	var (
+		encoded []byte
+		err     error
+	)
+	switch path {
+	case OTLPMetricsPath:
+		req := pmetricotlp.NewExportRequest()
+		if err = req.UnmarshalJSON(payload); err == nil {
+			encoded, err = req.MarshalProto()
+		}
+	case OTLPTracesPath:
+		req := ptraceotlp.NewExportRequest()
+		if err = req.UnmarshalJSON(payload); err == nil {
+			encoded, err = req.MarshalProto()
+		}
+	case OTLPLogsPath:
+		req := plogotlp.NewExportRequest()
+		if err = req.UnmarshalJSON(payload); err == nil {
+			encoded, err = req.MarshalProto()
+		}
+	default:
+		return nil, "", fmt.Errorf("unknown OTLP path: %s", path)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode payload as protobuf: %w", err)
+	}
+
+	return encoded, "application/x-protobuf", nil
+}
+
+// compressPayload compresses an encoded payload with the configured
+// compression and returns it with its content encoding, empty if the payload
+// is sent as is.
+func compressPayload(payload []byte) ([]byte, string, error) {
+	if config.Compress != CompressGzip {
+		return payload, "", nil
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(payload); err != nil {
+		return nil, "", fmt.Errorf("failed to gzip payload: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to gzip payload: %w", err)
+	}
+
+	return buf.Bytes(), "gzip", nil
+}
+
+// sendOTLP sends data to the OTLP endpoint with the given X-Priority. It
+// returns whether the request was accepted.
+func sendOTLP(path string, payload []byte, priorityLevel string) bool {
+	target := pickTarget(config.TargetStrategy)
+	url := target.url + path
+
+	// Encode the payload
+	payload, contentType, err := encodePayload(path, payload)
+	if err != nil {
+		logger.Error("Failed to encode payload", zap.Error(err))
+		recordFailure()
+		return false
+	}
+
+	// Compress the payload, so the bytes recorded are those on the wire
+	payload, contentEncoding, err := compressPayload(payload)
+	if err != nil {
+		logger.Error("Failed to compress payload", zap.Error(err))
+		recordFailure()
+		return false
+	}
+
 	// Record request time
 	startTime := time.Now()
-	
+
 	// Create request
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
 	if err != nil {
 		logger.Error("Failed to create request", zap.Error(err))
+		target.recordFailure()
 		recordFailure()
-		return
+		return false
 	}
-	
+
 	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	
-	// Determine priority level
-	priorityLevel := determinePriority()
+	req.Header.Set("Content-Type", contentType)
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+
+	// Set the priority of the payload
 	if priorityLevel != "" {
 		req.Header.Set("X-Priority", priorityLevel)
 	}
-	
+
 	// Send request
 	client := &http.Client{
 		Timeout: 10 * time.Second,
 	}
 	resp, err := client.Do(req)
-	
+
 	// Calculate latency
 	latency := time.Since(startTime)
-	
+
 	// Handle errors
 	if err != nil {
 		logger.Error("Request failed",
@@ -435,11 +895,12 @@ func sendOTLP(path string, payload []byte) {
 			zap.String("url", url),
 			zap.Duration("latency", latency),
 		)
+		target.recordFailure()
 		recordFailure()
-		return
+		return false
 	}
 	defer resp.Body.Close()
-	
+
 	// Check response
 	if resp.StatusCode != http.StatusOK {
 		logger.Error("Request failed",
@@ -447,36 +908,45 @@ func sendOTLP(path string, payload []byte) {
 			zap.String("url", url),
 			zap.Duration("latency", latency),
 		)
+		target.recordFailure()
 		recordFailure()
-		return
+		return false
 	}
-	
+
 	// Record success
 	recordSuccess(len(payload), latency)
+	target.recordSuccess(latency)
+	return true
 }
 
-// determinePriority randomly assigns a priority level based on configuration.
-func determinePriority() string {
-	roll := rand.Intn(100)
-	
-	if roll < config.CriticalPercent {
-		return "critical"
-	} else if roll < config.CriticalPercent+config.HighPercent {
-		return "high"
-	}
-	
-	return "normal"
+// generateResource picks the resource attributes of a payload.
+func generateResource() map[string]string {
+	return map[string]string{
+		"service.name": fmt.Sprintf("service-%d", rand.Intn(config.UniqueServices)),
+		"host.name":    fmt.Sprintf("host-%d", rand.Intn(config.UniqueHosts)),
+	}
 }
 
-// generateMetricsPayload generates a metrics payload.
-func generateMetricsPayload() []byte {
+// generateMetricsPayload generates a metrics payload for a resource. A
+// non-zero sequence is added to the data point attributes.
+func generateMetricsPayload(resource map[string]string, sequence int64) []byte {
 	// In a real implementation, this would generate actual OTLP metrics
 	// For simplicity, we'll just return a placeholder
 	dimensions := config.DimensionsPerMetric
 	if inSpike {
 		dimensions = spikeDimensions
 	}
-	
+
+	attributes := generateAttributes(dimensions)
+	if sequence > 0 {
+		sequenceAttr := fmt.Sprintf(`{"key": "%s", "value": {"intValue": "%d"}}`, SequenceAttribute, sequence)
+		if attributes == "" {
+			attributes = sequenceAttr
+		} else {
+			attributes = sequenceAttr + "," + attributes
+		}
+	}
+
 	// Generate a payload with the specified dimensions
 	// This is a simplified placeholder
 	payload := fmt.Sprintf(`{
@@ -484,8 +954,8 @@ func generateMetricsPayload() []byte {
 			{
 				"resource": {
 					"attributes": [
-						{"key": "service.name", "value": {"stringValue": "service-%d"}},
-						{"key": "host.name", "value": {"stringValue": "host-%d"}}
+						{"key": "service.name", "value": {"stringValue": %q}},
+						{"key": "host.name", "value": {"stringValue": %q}}
 					]
 				},
 				"scopeMetrics": [
@@ -511,26 +981,26 @@ func generateMetricsPayload() []byte {
 			}
 		]
 	}`,
-		rand.Intn(config.UniqueServices),
-		rand.Intn(config.UniqueHosts),
+		resource["service.name"],
+		resource["host.name"],
 		rand.Intn(config.UniqueMetrics),
 		time.Now().UnixNano(),
 		rand.Float64()*100,
-		generateAttributes(dimensions),
+		attributes,
 	)
-	
+
 	return []byte(payload)
 }
 
 // generateAttributes generates random attributes for metrics.
 func generateAttributes(count int) string {
 	attrs := make([]string, count)
-	
+
 	for i := 0; i < count; i++ {
-		attrs[i] = fmt.Sprintf(`{"key": "dim%d", "value": {"stringValue": "val-%d"}}`, 
+		attrs[i] = fmt.Sprintf(`{"key": "dim%d", "value": {"stringValue": "val-%d"}}`,
 			i, rand.Intn(1000))
 	}
-	
+
 	return strings.Join(attrs, ",")
 }
 
@@ -552,30 +1022,64 @@ func generateLogsPayload() []byte {
 func recordSuccess(bytes int, latency time.Duration) {
 	statsMutex.Lock()
 	defer statsMutex.Unlock()
-	
+
 	requestsSent++
 	bytesTotal += int64(bytes)
 	latencyTotal += latency.Microseconds()
+	latencies.record(latency.Microseconds())
+	recentOutcomes.record(time.Now(), true)
+}
+
+// recordAcceptedSequence records a sequence ID the target accepted.
+func recordAcceptedSequence(sequence int64) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	acceptedSequences = append(acceptedSequences, sequence)
+}
+
+// writeSequenceFile writes the accepted sequence IDs as a JSON array.
+func writeSequenceFile(path string) error {
+	statsMutex.Lock()
+	data, err := json.Marshal(acceptedSequences)
+	count := len(acceptedSequences)
+	statsMutex.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal sequence IDs: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write sequence file: %w", err)
+	}
+
+	logger.Info("Wrote accepted sequence IDs",
+		zap.String("path", path),
+		zap.Int("count", count),
+	)
+
+	return nil
 }
 
 // recordFailure records a failed request.
 func recordFailure() {
 	statsMutex.Lock()
 	defer statsMutex.Unlock()
-	
+
 	requestsFailed++
+	recentOutcomes.record(time.Now(), false)
 }
 
 // statsReporter periodically reports statistics.
 func statsReporter() {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
-	
+
 	for range ticker.C {
-		if time.Now().After(endTime) {
+		if time.Now().After(endTime) || aborted.Load() {
 			return
 		}
-		
+
 		printStats(false)
 	}
 }
@@ -584,27 +1088,32 @@ func statsReporter() {
 func printStats(final bool) {
 	statsMutex.Lock()
 	defer statsMutex.Unlock()
-	
+
 	elapsed := time.Since(startTime)
 	rps := float64(requestsSent) / elapsed.Seconds()
-	
+
 	var avgLatency float64
 	if requestsSent > 0 {
 		avgLatency = float64(latencyTotal) / float64(requestsSent)
 	}
-	
+
 	status := "progress"
 	if final {
 		status = "final"
 	}
-	
+
 	logger.Info(fmt.Sprintf("Workload stats (%s)", status),
 		zap.Duration("elapsed", elapsed),
 		zap.Int64("requestsSent", requestsSent),
 		zap.Int64("requestsFailed", requestsFailed),
 		zap.Float64("rps", rps),
 		zap.Float64("avgLatencyMs", avgLatency/1000),
+		zap.Float64("p99LatencyMs", float64(latencyPercentile(0.99).Microseconds())/1000),
 		zap.Int64("bytesTotal", bytesTotal),
 		zap.Bool("inCardinalitySpike", inSpike),
+		zap.Bool("paused", paused.Load()),
+		zap.String("abortReason", abortedReason()),
 	)
+
+	logTargetStats()
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Priorities sent in the X-Priority header.
+const (
+	PriorityCritical = "critical"
+	PriorityHigh     = "high"
+	PriorityNormal   = "normal"
+)
+
+// PriorityRule assigns a priority to payloads whose resource carries an
+// attribute with a given value, such as service.name "service-0".
+type PriorityRule struct {
+	// Resource attribute key to match
+	Attribute string `json:"attribute"`
+
+	// Value the attribute must have
+	Value string `json:"value"`
+
+	// Priority of matching payloads, "critical", "high" or "normal"
+	Priority string `json:"priority"`
+}
+
+// validatePriorityRules checks the attribute and priority of each rule.
+func (c *Config) validatePriorityRules() error {
+	for i, rule := range c.PriorityRules {
+		if rule.Attribute == "" {
+			return fmt.Errorf("priority_rules[%d].attribute must not be empty", i)
+		}
+		switch rule.Priority {
+		case PriorityCritical, PriorityHigh, PriorityNormal:
+		default:
+			return fmt.Errorf("priority_rules[%d].priority must be %q, %q or %q, got %q",
+				i, PriorityCritical, PriorityHigh, PriorityNormal, rule.Priority)
+		}
+	}
+	return nil
+}
+
+// determinePriority returns the priority of a payload with the given
+// resource attributes. With priority rules the first rule matching the
+// resource decides, and payloads matching none are normal; otherwise the
+// priority is rolled randomly from critical_percent and high_percent.
+func determinePriority(resource map[string]string) string {
+	cfg := liveConfig.Load()
+
+	if len(cfg.PriorityRules) > 0 {
+		for _, rule := range cfg.PriorityRules {
+			if value, ok := resource[rule.Attribute]; ok && value == rule.Value {
+				return rule.Priority
+			}
+		}
+		return PriorityNormal
+	}
+
+	roll := rand.Intn(100)
+	if roll < cfg.CriticalPercent {
+		return PriorityCritical
+	} else if roll < cfg.CriticalPercent+cfg.HighPercent {
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
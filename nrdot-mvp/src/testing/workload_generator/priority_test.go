@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.uber.org/zap"
+)
+
+func TestCriticalServicePayloadsCarryCriticalHeader(t *testing.T) {
+	logger = zap.NewNop()
+	var (
+		mu         sync.Mutex
+		priorities = make(map[string]map[string]int) // Service name to priority counts
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := pmetricotlp.NewExportRequest()
+		if err := req.UnmarshalJSON(body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		service, _ := req.Metrics().ResourceMetrics().At(0).Resource().Attributes().Get("service.name")
+
+		mu.Lock()
+		defer mu.Unlock()
+		if priorities[service.Str()] == nil {
+			priorities[service.Str()] = make(map[string]int)
+		}
+		priorities[service.Str()][r.Header.Get("X-Priority")]++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config = DefaultConfig()
+	config.TargetURL = server.URL
+	config.UniqueServices = 3
+	// The rules decide instead of the random roll
+	config.CriticalPercent = 100
+	config.HighPercent = 0
+	config.PriorityRules = []PriorityRule{
+		{Attribute: "service.name", Value: "service-0", Priority: PriorityCritical},
+		{Attribute: "service.name", Value: "service-1", Priority: PriorityHigh},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	liveConfig.Store(config)
+	t.Cleanup(func() { liveConfig.Store(DefaultConfig()) })
+	initTargets(config)
+
+	for i := 0; i < 100; i++ {
+		sendMetrics()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for service, want := range map[string]string{
+		"service-0": PriorityCritical,
+		"service-1": PriorityHigh,
+		"service-2": PriorityNormal,
+	} {
+		counts := priorities[service]
+		if counts[want] == 0 || len(counts) != 1 {
+			t.Errorf("expected every %s payload to be %s, got %v", service, want, counts)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestInvalidProfilesRejected(t *testing.T) {
+	logger = zap.NewNop()
+
+	// Profiles are loaded relative to the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "profiles"), 0755); err != nil {
+		t.Fatalf("failed to create profiles directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to change directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for name, test := range map[string]struct {
+		profile string
+		err     string
+	}{
+		"negative_workers": {`{"workers": -1}`, "workers must be greater than 0, got -1"},
+		"zero_workers":     {`{"workers": 0}`, "workers must be greater than 0, got 0"},
+		"rate_below":       {`{"workers": 10, "rate_limit": 5}`, "rate_limit (5) must be at least workers (10)"},
+		"critical_range":   {`{"critical_percent": 101}`, "critical_percent must be between 0 and 100, got 101"},
+		"high_negative":    {`{"high_percent": -5}`, "high_percent must be between 0 and 100, got -5"},
+		"percent_sum":      {`{"critical_percent": 60, "high_percent": 50}`, "critical_percent (60) and high_percent (50) must not sum to more than 100"},
+		"misspelled_field": {`{"wokers": 4}`, `unknown field "wokers"`},
+	} {
+		path := filepath.Join("profiles", name+".json")
+		if err := os.WriteFile(path, []byte(test.profile), 0644); err != nil {
+			t.Fatalf("failed to write profile: %v", err)
+		}
+
+		_, err := loadProfile(name)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("profile %s: expected an error containing %q, got %v", name, test.err, err)
+		}
+	}
+
+	// A valid profile loads
+	if err := os.WriteFile(filepath.Join("profiles", "valid.json"), []byte(`{"workers": 2, "rate_limit": 10}`), 0644); err != nil {
+		t.Fatalf("failed to write profile: %v", err)
+	}
+	loaded, err := loadProfile("valid")
+	if err != nil {
+		t.Fatalf("expected the valid profile to load, got %v", err)
+	}
+	if loaded.Workers != 2 || loaded.RateLimit != 10 {
+		t.Fatalf("expected the profile's settings, got %d workers at %d/s", loaded.Workers, loaded.RateLimit)
+	}
+}
+
+func TestRequestIntervalWithMoreWorkersThanRate(t *testing.T) {
+	for _, test := range []struct {
+		workers   int
+		rateLimit int
+		want      time.Duration
+	}{
+		// Each of 10 workers sends every 5 seconds for 2 requests a second
+		{workers: 10, rateLimit: 2, want: 5 * time.Second},
+		{workers: 3, rateLimit: 2, want: 1500 * time.Millisecond},
+		{workers: 4, rateLimit: 100, want: 40 * time.Millisecond},
+		{workers: 1, rateLimit: 0, want: time.Second},
+		{workers: 0, rateLimit: 10, want: time.Second},
+		{workers: 1, rateLimit: 1 << 40, want: minRequestInterval},
+	} {
+		cfg := DefaultConfig()
+		cfg.Workers = test.workers
+		cfg.RateLimit = test.rateLimit
+
+		interval := requestInterval(cfg)
+		if interval != test.want {
+			t.Errorf("%d workers at %d/s: expected an interval of %v, got %v", test.workers, test.rateLimit, test.want, interval)
+		}
+
+		// The interval is always usable as a ticker period
+		time.NewTicker(interval).Stop()
+	}
+}
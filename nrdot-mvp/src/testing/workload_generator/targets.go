@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Strategies for spreading requests over the target URLs.
+const (
+	TargetRoundRobin = "round_robin"
+	TargetRandom     = "random"
+	TargetWeighted   = "weighted"
+)
+
+// target is an OTLP endpoint receiving load, with its own request stats so
+// a slow or failing instance stands out.
+type target struct {
+	url    string
+	weight int
+
+	sent         atomic.Int64
+	failed       atomic.Int64
+	latencyTotal atomic.Int64 // microseconds, of successful requests
+}
+
+var (
+	// Endpoints receiving load, fixed at startup
+	targets []*target
+
+	// Index of the next target with round-robin
+	nextTarget atomic.Uint64
+)
+
+// targetURLs returns the configured target URLs, falling back to TargetURL
+// when TargetURLs is empty.
+func (c *Config) targetURLs() []string {
+	if len(c.TargetURLs) > 0 {
+		return c.TargetURLs
+	}
+	return []string{c.TargetURL}
+}
+
+// validateTargets checks the target URLs, strategy and weights.
+func (c *Config) validateTargets() error {
+	urls := c.targetURLs()
+	for i, url := range urls {
+		if url == "" {
+			return fmt.Errorf("target_urls[%d] must not be empty", i)
+		}
+	}
+
+	switch c.TargetStrategy {
+	case "", TargetRoundRobin, TargetRandom:
+	case TargetWeighted:
+		if len(c.TargetWeights) != len(urls) {
+			return fmt.Errorf("target_weights must have one weight per target URL, got %d for %d URLs",
+				len(c.TargetWeights), len(urls))
+		}
+		for i, weight := range c.TargetWeights {
+			if weight <= 0 {
+				return fmt.Errorf("target_weights[%d] must be greater than 0, got %d", i, weight)
+			}
+		}
+	default:
+		return fmt.Errorf("target_strategy must be %q, %q or %q, got %q",
+			TargetRoundRobin, TargetRandom, TargetWeighted, c.TargetStrategy)
+	}
+
+	return nil
+}
+
+// initTargets creates the targets from the configuration.
+func initTargets(cfg *Config) {
+	urls := cfg.targetURLs()
+	targets = make([]*target, len(urls))
+	for i, url := range urls {
+		weight := 1
+		if cfg.TargetStrategy == TargetWeighted {
+			weight = cfg.TargetWeights[i]
+		}
+		targets[i] = &target{url: url, weight: weight}
+	}
+}
+
+// pickTarget returns the target of the next request according to the
+// strategy.
+func pickTarget(strategy string) *target {
+	if len(targets) == 1 {
+		return targets[0]
+	}
+
+	switch strategy {
+	case TargetRandom:
+		return targets[rand.Intn(len(targets))]
+	case TargetWeighted:
+		total := 0
+		for _, t := range targets {
+			total += t.weight
+		}
+		roll := rand.Intn(total)
+		for _, t := range targets {
+			if roll < t.weight {
+				return t
+			}
+			roll -= t.weight
+		}
+		return targets[len(targets)-1]
+	default:
+		return targets[(nextTarget.Add(1)-1)%uint64(len(targets))]
+	}
+}
+
+// recordSuccess records a successful request to the target.
+func (t *target) recordSuccess(latency time.Duration) {
+	t.sent.Add(1)
+	t.latencyTotal.Add(latency.Microseconds())
+}
+
+// recordFailure records a failed request to the target.
+func (t *target) recordFailure() {
+	t.failed.Add(1)
+}
+
+// logTargetStats logs the request stats of each target when there is more
+// than one.
+func logTargetStats() {
+	if len(targets) < 2 {
+		return
+	}
+
+	for _, t := range targets {
+		sent := t.sent.Load()
+
+		var avgLatency float64
+		if sent > 0 {
+			avgLatency = float64(t.latencyTotal.Load()) / float64(sent)
+		}
+
+		logger.Info("Target stats",
+			zap.String("targetURL", t.url),
+			zap.Int64("requestsSent", sent),
+			zap.Int64("requestsFailed", t.failed.Load()),
+			zap.Float64("avgLatencyMs", avgLatency/1000),
+		)
+	}
+}
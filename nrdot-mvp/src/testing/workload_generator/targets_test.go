@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRoundRobinSpreadsRequestsEvenly(t *testing.T) {
+	logger = zap.NewNop()
+	first, firstRequests := countingServer(t)
+	second, secondRequests := countingServer(t)
+
+	config = DefaultConfig()
+	config.TargetURLs = []string{first.URL, second.URL}
+	config.TargetStrategy = TargetRoundRobin
+	liveConfig.Store(config)
+	initTargets(config)
+
+	// Concurrent senders still alternate between the targets
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				sendData()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := firstRequests.Load() + secondRequests.Load(); got != 100 {
+		t.Fatalf("expected 100 requests in total, got %d", got)
+	}
+	if firstRequests.Load() != 50 || secondRequests.Load() != 50 {
+		t.Fatalf("expected 50 requests to each target, got %d and %d", firstRequests.Load(), secondRequests.Load())
+	}
+
+	// Each target's stats count only its own requests
+	for i, target := range targets {
+		if got := target.sent.Load(); got != 50 || target.failed.Load() != 0 {
+			t.Fatalf("expected target %d to count 50 requests without failures, got %d sent and %d failed",
+				i, got, target.failed.Load())
+		}
+	}
+}
+
+func TestWeightedTargetsFollowWeights(t *testing.T) {
+	config = DefaultConfig()
+	config.TargetURLs = []string{"http://a", "http://b"}
+	config.TargetStrategy = TargetWeighted
+	config.TargetWeights = []int{3, 1}
+	initTargets(config)
+
+	picked := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		picked[pickTarget(config.TargetStrategy).url]++
+	}
+	if share := float64(picked["http://a"]) / 4000; math.Abs(share-0.75) > 0.05 {
+		t.Fatalf("expected about 75%% of requests to the heavier target, got %.2f", share)
+	}
+}
+
+func TestFailingTargetStandsOut(t *testing.T) {
+	logger = zap.NewNop()
+	healthy, _ := countingServer(t)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	config = DefaultConfig()
+	config.TargetURLs = []string{healthy.URL, failing.URL}
+	liveConfig.Store(config)
+	initTargets(config)
+
+	for i := 0; i < 10; i++ {
+		sendData()
+	}
+	if targets[0].sent.Load() != 5 || targets[0].failed.Load() != 0 {
+		t.Fatalf("expected the healthy target to count 5 successes, got %d sent and %d failed",
+			targets[0].sent.Load(), targets[0].failed.Load())
+	}
+	if targets[1].sent.Load() != 0 || targets[1].failed.Load() != 5 {
+		t.Fatalf("expected the failing target to count 5 failures, got %d sent and %d failed",
+			targets[1].sent.Load(), targets[1].failed.Load())
+	}
+}